@@ -0,0 +1,189 @@
+package amqapi
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/avivsinai/agent-message-queue/internal/format"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+	"github.com/avivsinai/agent-message-queue/internal/mailbox"
+	"github.com/avivsinai/agent-message-queue/internal/sessionguard"
+)
+
+// Client is bound to one mailbox in one root. It is safe for concurrent use;
+// every operation revalidates the pinned root before touching it.
+type Client struct {
+	root   *fsq.DeliveryRoot
+	path   string
+	me     string
+	strict bool
+}
+
+// Open resolves options, applies the session-pin policy, and pins the target
+// root. The caller's mailbox must already exist.
+func Open(options OptionsV1) (*Client, error) {
+	rawRoot := strings.TrimSpace(options.Root)
+	if rawRoot == "" {
+		return nil, fmt.Errorf("%w: root is required", ErrInvalidRequest)
+	}
+	base, err := filepath.Abs(rawRoot)
+	if err != nil {
+		return nil, err
+	}
+	me := strings.TrimSpace(options.Me)
+	if err := format.ValidateHandle("me", me); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	target := base
+	session := strings.TrimSpace(options.Session)
+	if session != "" {
+		if err := validateSessionName(session); err != nil {
+			return nil, err
+		}
+		target = filepath.Join(base, session)
+	}
+	if err := guardSessionPin(base, target, session != "", options.IgnoreSessionPin); err != nil {
+		return nil, err
+	}
+	identity, err := fsq.SnapshotDeliveryRoot(target)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: root %s", ErrNotFound, target)
+		}
+		return nil, err
+	}
+	root, err := fsq.OpenDeliveryRoot(target, identity)
+	if err != nil {
+		return nil, err
+	}
+	client := &Client{root: root, path: target, me: me, strict: options.Strict}
+	if err := client.requireMailbox(me); err != nil {
+		_ = root.Close()
+		return nil, err
+	}
+	if err := client.validateKnown(me); err != nil {
+		_ = root.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// Close releases the pinned root.
+func (c *Client) Close() error {
+	return c.root.Close()
+}
+
+// Root is the absolute mailbox root the client is bound to.
+func (c *Client) Root() string {
+	return c.path
+}
+
+// Me is the handle the client sends and consumes as.
+func (c *Client) Me() string {
+	return c.me
+}
+
+// requireMailbox applies the CLI's mailbox check: inbox/new, inbox/cur, and
+// dlq/new must all be directories.
+func (c *Client) requireMailbox(handle string) error {
+	err := mailbox.Require(c.root, handle)
+	var missing *mailbox.MissingError
+	if errors.As(err, &missing) {
+		return fmt.Errorf("%w: %v in %s", ErrNotFound, missing, c.path)
+	}
+	return err
+}
+
+// knownAgents returns the CLI roster (configured agents plus the reserved
+// human handle), or nil when the root has no config (no roster validation).
+func (c *Client) knownAgents() (map[string]struct{}, error) {
+	agents, err := mailbox.KnownAgents(c.root, c.strict, nil)
+	if err != nil {
+		return nil, err
+	}
+	return mailbox.KnownSet(agents), nil
+}
+
+// validateKnown rejects handles outside the configured roster in strict
+// mode. Non-strict clients accept any well-formed handle.
+func (c *Client) validateKnown(handles ...string) error {
+	if !c.strict {
+		return nil
+	}
+	agents, err := mailbox.KnownAgents(c.root, c.strict, nil)
+	if err != nil {
+		return err
+	}
+	if err := mailbox.ValidateKnown(agents, nil, c.strict, nil, handles...); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	return nil
+}
+
+// headerValidator is the CLI's header validator for this client's root and
+// strictness.
+func (c *Client) headerValidator() (*mailbox.HeaderValidator, error) {
+	return mailbox.NewHeaderValidator(c.root, c.strict)
+}
+
+// validateHeader applies the CLI's header rules; strict clients also enforce
+// the schema version and roster membership.
+func (c *Client) validateHeader(header format.Header) error {
+	validator, err := c.headerValidator()
+	if err != nil {
+		return err
+	}
+	return validator.Validate(header)
+}
+
+// guardSessionPin evaluates the caller's AM_SESSION/AM_BASE_ROOT pin through
+// the shared session-guard policy table. An explicit Root never overrides a
+// conflicting pin; IgnoreSessionPin does, as with --ignore-session-pin.
+func guardSessionPin(base, target string, routed, ignorePin bool) error {
+	relation := sessionguard.TargetUnbound
+	var detail string
+	pin, err := sessionguard.LoadPin(nil)
+	state := pin.State()
+	if err != nil {
+		var pinErr *sessionguard.PinError
+		if !errors.As(err, &pinErr) {
+			return err
+		}
+		state, detail = sessionguard.PinInvalid, pinErr.Message
+	} else if pin.Present {
+		// A routed session resolves under the pinned base, as amq --session does.
+		check, compare := pin, target
+		if routed {
+			check, compare = pin.BaseOnly(), base
+		}
+		relation, detail = sessionguard.TargetMatch, fmt.Sprintf("target root %s is pinned to %s", compare, check.ExpectedRoot)
+		if err := check.Verify(compare, fsq.StableTreeIdentity); err != nil {
+			relation, detail = sessionguard.TargetMismatch, err.Error()
+		}
+	}
+	decision := sessionguard.Decide(sessionguard.Input{
+		Kind:     sessionguard.KindMailbox,
+		Channel:  sessionguard.ChannelExit5,
+		Pin:      state,
+		Relation: relation,
+		Flags: sessionguard.Flags{
+			Routed:       routed && relation == sessionguard.TargetMatch,
+			IgnorePin:    ignorePin,
+			ExplicitRoot: true,
+		},
+	})
+	if decision.Verdict == sessionguard.Allow {
+		return nil
+	}
+	return fmt.Errorf("%w: %s (%s)", ErrSessionContext, detail, decision.Row)
+}
+
+func validateSessionName(name string) error {
+	if err := sessionguard.ValidateSessionName(name); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	return nil
+}
@@ -0,0 +1,432 @@
+package amqapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/breaker"
+	"github.com/avivsinai/agent-message-queue/internal/config"
+	"github.com/avivsinai/agent-message-queue/internal/format"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+	"github.com/avivsinai/agent-message-queue/internal/presence"
+)
+
+func newTestRoot(t *testing.T, agents ...string) string {
+	t.Helper()
+	for _, key := range []string{"AM_SESSION", "AM_ROOT_ID", "AM_BASE_ROOT", "AM_BASE_ROOT_ID"} {
+		t.Setenv(key, "")
+		_ = os.Unsetenv(key)
+	}
+	root := filepath.Join(t.TempDir(), ".agent-mail")
+	if err := fsq.EnsureRootDirs(root); err != nil {
+		t.Fatal(err)
+	}
+	for _, agent := range agents {
+		if err := fsq.EnsureAgentDirs(root, agent); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := config.Config{Version: 1, Agents: agents}
+	if err := config.WriteConfig(filepath.Join(root, "meta", "config.json"), cfg, true); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func openTestClient(t *testing.T, options OptionsV1) *Client {
+	t.Helper()
+	client, err := Open(options)
+	if err != nil {
+		t.Fatalf("Open(%+v): %v", options, err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func TestSendDrainReceiptReplyThreadRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	root := newTestRoot(t, "alice", "bob")
+	alice := openTestClient(t, OptionsV1{Root: root, Me: "alice", Strict: true})
+	bob := openTestClient(t, OptionsV1{Root: root, Me: "bob", Strict: true})
+
+	sent, err := alice.Send(ctx, SendRequestV1{
+		RequestVersion: RequestVersionV1, To: []string{"bob"}, Subject: "Review", Body: "please look",
+		Kind: "review_request", Priority: "normal",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent.Outcome != SendDelivered || sent.Thread != "p2p/alice__bob" {
+		t.Fatalf("send = %+v", sent)
+	}
+	assertMatchesPublishedSchema(t, "SendResultV1", sent)
+
+	watched, err := bob.Watch(ctx, WatchRequestV1{TimeoutMillis: 1000})
+	if err != nil || watched.Event != "messages" || len(watched.Messages) != 1 {
+		t.Fatalf("watch = %+v, %v", watched, err)
+	}
+	listed, err := bob.List(ctx, ListRequestV1{})
+	if err != nil || listed.Box != BoxNew || len(listed.Messages) != 1 || listed.Messages[0].ID != sent.ID {
+		t.Fatalf("list = %+v, %v", listed, err)
+	}
+
+	drained, err := bob.Drain(ctx, DrainRequestV1{IncludeBody: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drained.Messages) != 1 || strings.TrimSpace(drained.Messages[0].Body) != "please look" || len(drained.Failed) != 0 {
+		t.Fatalf("drain = %+v", drained)
+	}
+	assertMatchesPublishedSchema(t, "DrainResultV1", drained)
+
+	waited, err := alice.WaitReceipt(ctx, WaitReceiptRequestV1{ID: sent.ID, Consumer: "bob", TimeoutMillis: 1000})
+	if err != nil || waited.Event != "matched" || waited.Receipt == nil || waited.Receipt.Stage != "drained" {
+		t.Fatalf("wait receipt = %+v, %v", waited, err)
+	}
+
+	replied, err := bob.Reply(ctx, ReplyRequestV1{ID: sent.ID, Body: "lgtm"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replied.Thread != sent.Thread || replied.Subject != "Re: Review" || replied.To[0] != "alice" {
+		t.Fatalf("reply = %+v", replied)
+	}
+	read, err := alice.Read(ctx, ReadRequestV1{ID: replied.ID})
+	if err != nil || read.Message.Kind != "review_response" || strings.TrimSpace(read.Message.Body) != "lgtm" {
+		t.Fatalf("read = %+v, %v", read, err)
+	}
+
+	thread, err := alice.Thread(ctx, ThreadRequestV1{ID: sent.Thread, IncludeBody: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(thread.Messages) != 2 || thread.Messages[0].ID != sent.ID || thread.Messages[1].ID != replied.ID {
+		t.Fatalf("thread = %+v", thread)
+	}
+	assertMatchesPublishedSchema(t, "ThreadResultV1", thread)
+}
+
+func TestSendRefusesUnsupportedAndUnknownRecipients(t *testing.T) {
+	ctx := context.Background()
+	root := newTestRoot(t, "alice", "bob")
+	alice := openTestClient(t, OptionsV1{Root: root, Me: "alice", Strict: true})
+
+	if _, err := alice.Send(ctx, SendRequestV1{To: []string{"bob@other"}, Body: "x"}); !errors.Is(err, ErrUnsupportedRoute) {
+		t.Fatalf("cross-project send = %v, want ErrUnsupportedRoute", err)
+	}
+	if err := fsq.EnsureAgentDirs(root, "carol"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := alice.Send(ctx, SendRequestV1{To: []string{"carol"}, Body: "x"}); !errors.Is(err, ErrInvalidRequest) {
+		t.Fatalf("strict send to unconfigured handle = %v, want ErrInvalidRequest", err)
+	}
+	if _, err := alice.Send(ctx, SendRequestV1{RequestVersion: 2, To: []string{"bob"}, Body: "x"}); !errors.Is(err, ErrInvalidRequest) {
+		t.Fatalf("request version 2 = %v, want ErrInvalidRequest", err)
+	}
+
+	explained, err := alice.RouteExplain(ctx, RouteExplainRequestV1{To: []string{"bob", "carol", "dave", "bob@other"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reasons := map[string]string{}
+	for _, route := range explained.Routes {
+		reasons[route.Handle] = route.Reason
+	}
+	if reasons["bob"] != "" || reasons["carol"] != "not_in_roster" || reasons["dave"] != "mailbox_missing" ||
+		reasons["bob@other"] != "cross_project_or_session_route_requires_cli" {
+		t.Fatalf("route reasons = %v", reasons)
+	}
+	assertMatchesPublishedSchema(t, "RouteExplainResultV1", explained)
+}
+
+func TestRouteExplainDryRunsACLQuotaAndBreaker(t *testing.T) {
+	ctx := context.Background()
+	root := newTestRoot(t, "alice", "bob", "carol", "dave", "erin")
+	cfg := config.Config{
+		Version: 1,
+		Agents:  []string{"alice", "bob", "carol", "dave", "erin"},
+		ACL: &config.ACLConfig{Agents: map[string]config.ACLPolicy{
+			"bob": {Deny: []config.ACLRule{{From: []string{"alice"}}}},
+		}},
+		Quotas: &config.QuotaConfig{Agents: map[string]config.QuotaLimits{"carol": {MaxUndrained: 1}}},
+	}
+	if err := config.WriteConfig(filepath.Join(root, "meta", "config.json"), cfg, true); err != nil {
+		t.Fatal(err)
+	}
+	alice := openTestClient(t, OptionsV1{Root: root, Me: "alice"})
+	if _, err := alice.Send(ctx, SendRequestV1{To: []string{"carol"}, Body: "fills carol"}); err != nil {
+		t.Fatal(err)
+	}
+	identity, err := fsq.SnapshotDeliveryRoot(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deliveryFS, err := fsq.OpenDeliveryRoot(root, identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = deliveryFS.Close() }()
+	thresholds := config.BreakerConfig{Window: "2m", MaxAlternations: -1, MaxRepeats: -1, MaxDepth: 1}
+	for i := 0; i < 2; i++ {
+		if _, err := breaker.Admit(deliveryFS, thresholds, "p2p/alice__dave", "alice", "ping", fmt.Sprintf("held-%d.md", i), []byte("x"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	explained, err := alice.RouteExplain(ctx, RouteExplainRequestV1{To: []string{"bob", "carol", "dave", "erin"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]RouteV1{}
+	for _, route := range explained.Routes {
+		got[route.Handle] = route
+	}
+	for handle, reason := range map[string]string{"bob": "acl_denied", "carol": "quota_exceeded", "dave": "breaker_open", "erin": ""} {
+		if got[handle].Reason != reason || got[handle].Deliverable != (reason == "") {
+			t.Fatalf("route %s = %+v, want reason %q", handle, got[handle], reason)
+		}
+	}
+	assertMatchesPublishedSchema(t, "RouteExplainResultV1", explained)
+}
+
+func TestDrainMovesCorruptMessageToDLQ(t *testing.T) {
+	ctx := context.Background()
+	root := newTestRoot(t, "alice", "bob")
+	bob := openTestClient(t, OptionsV1{Root: root, Me: "bob"})
+	name := "2026-01-01T00-00-00.000000000Z_corrupt.md"
+	if err := os.WriteFile(filepath.Join(fsq.AgentInboxNew(root, "bob"), name), []byte("not a message"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	drained, err := bob.Drain(ctx, DrainRequestV1{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drained.Messages) != 0 || len(drained.Failed) != 1 || !drained.Failed[0].MovedToDLQ {
+		t.Fatalf("drain = %+v, want one DLQ failure", drained)
+	}
+	if _, err := os.Stat(filepath.Join(fsq.AgentInboxNew(root, "bob"), name)); !os.IsNotExist(err) {
+		t.Fatalf("corrupt message still in inbox/new: %v", err)
+	}
+	watched, err := bob.Watch(ctx, WatchRequestV1{TimeoutMillis: 1})
+	if err != nil || watched.Event != "timeout" {
+		t.Fatalf("watch after drain = %+v, %v", watched, err)
+	}
+}
+
+func TestDrainClaimsBridgedMessageByFilename(t *testing.T) {
+	ctx := context.Background()
+	root := newTestRoot(t, "alice", "bob")
+	bob := openTestClient(t, OptionsV1{Root: root, Me: "bob"})
+	header := format.Header{
+		Schema:  format.CurrentSchema,
+		ID:      "2026-01-01T00-00-00.000000000Z_remote",
+		From:    "alice",
+		To:      []string{"bob"},
+		Thread:  "p2p/alice__bob",
+		Created: "2026-01-01T00:00:00Z",
+	}
+	data, err := format.Message{Header: header, Body: "over the bridge"}.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The bridge names inbound files after the transfer, not the message ID.
+	name := "xfer-hosta-t1.md"
+	if err := os.WriteFile(filepath.Join(fsq.AgentInboxNew(root, "bob"), name), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	drained, err := bob.Drain(ctx, DrainRequestV1{IncludeBody: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drained.Messages) != 1 || drained.Messages[0].ID != header.ID || len(drained.Failed) != 0 {
+		t.Fatalf("drain = %+v, want the bridged message", drained)
+	}
+	if _, err := os.Stat(filepath.Join(fsq.AgentInboxCur(root, "bob"), name)); err != nil {
+		t.Fatalf("bridged message not claimed into inbox/cur: %v", err)
+	}
+}
+
+func TestOpenHonorsSessionPin(t *testing.T) {
+	base := newTestRoot(t)
+	session := filepath.Join(base, "collab")
+	if err := fsq.EnsureRootDirs(session); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsq.EnsureAgentDirs(session, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsq.EnsureAgentDirs(base, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AM_BASE_ROOT", base)
+	t.Setenv("AM_SESSION", "collab")
+
+	if _, err := Open(OptionsV1{Root: base, Me: "alice"}); !errors.Is(err, ErrSessionContext) {
+		t.Fatalf("open base root under session pin = %v, want ErrSessionContext", err)
+	}
+	openTestClient(t, OptionsV1{Root: session, Me: "alice"})
+	openTestClient(t, OptionsV1{Root: base, Session: "collab", Me: "alice"})
+	openTestClient(t, OptionsV1{Root: base, Me: "alice", IgnoreSessionPin: true})
+}
+
+func TestOpenComparesIdentityPinsByPhysicalRoot(t *testing.T) {
+	base := newTestRoot(t, "alice")
+	session := filepath.Join(base, "collab")
+	if err := fsq.EnsureRootDirs(session); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsq.EnsureAgentDirs(session, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	baseID, err := fsq.StableTreeIdentity(base)
+	if err != nil {
+		t.Skipf("tree identity unavailable: %v", err)
+	}
+	sessionID, err := fsq.StableTreeIdentity(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AM_BASE_ROOT", base)
+	t.Setenv("AM_SESSION", "collab")
+	t.Setenv("AM_BASE_ROOT_ID", baseID)
+	t.Setenv("AM_ROOT_ID", sessionID)
+	openTestClient(t, OptionsV1{Root: session, Me: "alice"})
+
+	// Same path, different physical root: the identity pin refuses it.
+	t.Setenv("AM_ROOT_ID", baseID)
+	if _, err := Open(OptionsV1{Root: session, Me: "alice"}); !errors.Is(err, ErrSessionContext) {
+		t.Fatalf("open with a stale identity pin = %v, want ErrSessionContext", err)
+	}
+	t.Setenv("AM_ROOT_ID", "")
+	if _, err := Open(OptionsV1{Root: session, Me: "alice"}); !errors.Is(err, ErrSessionContext) {
+		t.Fatalf("open with an incomplete identity pin = %v, want ErrSessionContext", err)
+	}
+}
+
+func TestSendRoutesByCapabilityAndForwardsToDelegates(t *testing.T) {
+	ctx := context.Background()
+	root := newTestRoot(t, "alice", "bob", "carol")
+	now := time.Now()
+	for _, handle := range []string{"bob", "carol"} {
+		p := presence.New(handle, presence.StatusActive, "", now)
+		p.Capabilities = []string{"go"}
+		if err := presence.Write(root, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	alice := openTestClient(t, OptionsV1{Root: root, Me: "alice", Strict: true})
+
+	var chosen []string
+	for range 2 {
+		sent, err := alice.Send(ctx, SendRequestV1{RequestVersion: RequestVersionV1, ToCapability: "go", Pick: PickRoundRobin, Body: "build it"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sent.Outcome != SendDelivered || sent.Capability == nil || len(sent.To) != 1 || sent.To[0] != sent.Capability.Chosen {
+			t.Fatalf("capability send = %+v", sent)
+		}
+		assertMatchesPublishedSchema(t, "SendResultV1", sent)
+		chosen = append(chosen, sent.Capability.Chosen)
+	}
+	if chosen[0] != "bob" || chosen[1] != "carol" {
+		t.Fatalf("round-robin picks = %v, want bob then carol", chosen)
+	}
+	if _, err := alice.Send(ctx, SendRequestV1{RequestVersion: RequestVersionV1, ToCapability: "rust", Body: "x"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("unadvertised capability error = %v, want ErrNotFound", err)
+	}
+	if _, err := alice.Send(ctx, SendRequestV1{RequestVersion: RequestVersionV1, To: []string{"bob"}, ToCapability: "go", Body: "x"}); !errors.Is(err, ErrInvalidRequest) {
+		t.Fatalf("to with to_capability error = %v, want ErrInvalidRequest", err)
+	}
+
+	away := presence.New("carol", "away", "", now)
+	away.Delegate = "bob"
+	away.DelegateMode = presence.DelegateCopy
+	if err := presence.Write(root, away); err != nil {
+		t.Fatal(err)
+	}
+	sent, err := alice.Send(ctx, SendRequestV1{RequestVersion: RequestVersionV1, To: []string{"carol"}, Body: "while you are out"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sent.Delegated) != 1 || sent.Delegated[0].For != "carol" || sent.Delegated[0].To != "bob" {
+		t.Fatalf("delegated = %+v, want carol forwarded to bob", sent.Delegated)
+	}
+	assertMatchesPublishedSchema(t, "SendResultV1", sent)
+	for _, handle := range []string{"carol", "bob"} {
+		if _, err := os.Stat(filepath.Join(root, "agents", handle, "inbox", "new", sent.ID+".md")); err != nil {
+			t.Fatalf("%s copy: %v", handle, err)
+		}
+	}
+}
+
+func TestThreadNeverReadsOutsidePinnedRoot(t *testing.T) {
+	ctx := context.Background()
+	root := newTestRoot(t, "alice", "bob")
+	alice := openTestClient(t, OptionsV1{Root: root, Me: "alice"})
+	sent, err := alice.Send(ctx, SendRequestV1{RequestVersion: RequestVersionV1, To: []string{"bob"}, Body: "real"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Point bob's inbox/cur at a directory outside the root holding a forged
+	// message on the same thread. Thread reads through the pinned root, so it
+	// may refuse the link but must never follow it.
+	outside := t.TempDir()
+	forged := "---json\n{\"schema\":1,\"id\":\"forged\",\"from\":\"alice\",\"to\":[\"bob\"],\"thread\":\"p2p/alice__bob\",\"created\":\"2020-01-01T00:00:00Z\"}\n---\nforged\n"
+	if err := os.WriteFile(filepath.Join(outside, "forged.md"), []byte(forged), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cur := filepath.Join(root, "agents", "bob", "inbox", "cur")
+	if err := os.Remove(cur); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, cur); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := alice.Thread(ctx, ThreadRequestV1{RequestVersion: RequestVersionV1, ID: "p2p/alice__bob", IncludeBody: true})
+	if err != nil {
+		return
+	}
+	for _, msg := range got.Messages {
+		if msg.ID != sent.ID {
+			t.Fatalf("thread read %s from outside the pinned root", msg.ID)
+		}
+	}
+}
+
+func TestDrainAndReadRefuseQueueHandles(t *testing.T) {
+	ctx := context.Background()
+	root := newTestRoot(t, "alice", "bob", "work")
+	path := filepath.Join(root, "meta", "config.json")
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Queues = map[string]config.QueueConfig{"work": {Members: []string{"bob"}}}
+	if err := config.WriteConfig(path, cfg, true); err != nil {
+		t.Fatal(err)
+	}
+	alice := openTestClient(t, OptionsV1{Root: root, Me: "alice", Strict: true})
+	sent, err := alice.Send(ctx, SendRequestV1{RequestVersion: RequestVersionV1, To: []string{"work"}, Body: "take me"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queue := openTestClient(t, OptionsV1{Root: root, Me: "work", Strict: true})
+	if _, err := queue.Drain(ctx, DrainRequestV1{RequestVersion: RequestVersionV1}); !errors.Is(err, ErrInvalidRequest) {
+		t.Fatalf("Drain on a queue handle error = %v, want ErrInvalidRequest", err)
+	}
+	if _, err := queue.Read(ctx, ReadRequestV1{RequestVersion: RequestVersionV1, ID: sent.ID}); !errors.Is(err, ErrInvalidRequest) {
+		t.Fatalf("Read on a queue handle error = %v, want ErrInvalidRequest", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "agents", "work", "inbox", "new", sent.ID+".md")); err != nil {
+		t.Fatalf("queue item left inbox/new without a claim: %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package amqapi
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/avivsinai/agent-message-queue/internal/semver"
+)
+
+const ContractSemverV1 = "0.69.0"
+
+// compatibilityFeaturesV1 is the canonical negotiation order; keep it stable.
+var compatibilityFeaturesV1 = []string{
+	FeatureSend,
+	FeatureReply,
+	FeatureList,
+	FeatureRead,
+	FeatureDrain,
+	FeatureWatch,
+	FeatureWaitReceipt,
+	FeatureThread,
+	FeatureRouteExplain,
+	FeatureSendCapability,
+}
+
+func Compatibility() CompatibilityV1 {
+	return CompatibilityV1{
+		ContractSemver:  ContractSemverV1,
+		RequestVersions: []int{RequestVersionV1},
+		ResultVersions:  []int{ResultVersionV1},
+		Features:        slices.Clone(compatibilityFeaturesV1),
+	}
+}
+
+// Negotiate fails closed on any version or feature this build cannot honor,
+// with the same range grammar as launchapi.Negotiate.
+func Negotiate(requirement RequirementV1) (NegotiatedV1, error) {
+	if !semver.RangeContains(requirement.ContractSemver, ContractSemverV1) {
+		return NegotiatedV1{}, fmt.Errorf("contract semver %q does not include %s", requirement.ContractSemver, ContractSemverV1)
+	}
+	if requirement.RequestVersion != RequestVersionV1 {
+		return NegotiatedV1{}, fmt.Errorf("unsupported request version %d", requirement.RequestVersion)
+	}
+	if requirement.ResultVersion != ResultVersionV1 {
+		return NegotiatedV1{}, fmt.Errorf("unsupported result version %d", requirement.ResultVersion)
+	}
+	seen := make(map[string]struct{}, len(requirement.Features))
+	for _, feature := range requirement.Features {
+		if _, ok := seen[feature]; ok {
+			return NegotiatedV1{}, fmt.Errorf("duplicate required feature %q", feature)
+		}
+		seen[feature] = struct{}{}
+		if !slices.Contains(compatibilityFeaturesV1, feature) {
+			return NegotiatedV1{}, fmt.Errorf("unsupported required feature %q", feature)
+		}
+	}
+	features := make([]string, 0, len(requirement.Features))
+	for _, feature := range compatibilityFeaturesV1 {
+		if _, ok := seen[feature]; ok {
+			features = append(features, feature)
+		}
+	}
+	return NegotiatedV1{
+		ContractSemver: ContractSemverV1,
+		RequestVersion: RequestVersionV1,
+		ResultVersion:  ResultVersionV1,
+		Features:       features,
+	}, nil
+}
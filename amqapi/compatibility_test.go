@@ -0,0 +1,55 @@
+package amqapi
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompatibilityAndNegotiateV1(t *testing.T) {
+	compatibility := Compatibility()
+	if compatibility.ContractSemver != ContractSemverV1 ||
+		!reflect.DeepEqual(compatibility.RequestVersions, []int{1}) ||
+		!reflect.DeepEqual(compatibility.ResultVersions, []int{1}) {
+		t.Fatalf("Compatibility() = %#v", compatibility)
+	}
+	compatibility.Features[0] = "mutated"
+	if Compatibility().Features[0] != FeatureSend {
+		t.Fatal("Compatibility returned shared mutable feature storage")
+	}
+
+	negotiated, err := Negotiate(RequirementV1{
+		ContractSemver: ">=0.69.0 <0.70.0",
+		RequestVersion: 1,
+		ResultVersion:  1,
+		Features:       []string{FeatureWaitReceipt, FeatureSend},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(negotiated.Features, []string{FeatureSend, FeatureWaitReceipt}) {
+		t.Fatalf("negotiated features = %v, want canonical order", negotiated.Features)
+	}
+}
+
+func TestNegotiateV1FailsClosed(t *testing.T) {
+	tests := []struct {
+		name        string
+		requirement RequirementV1
+		want        string
+	}{
+		{name: "older contract", requirement: RequirementV1{ContractSemver: "<0.69.0", RequestVersion: 1, ResultVersion: 1}, want: "does not include"},
+		{name: "malformed range", requirement: RequirementV1{ContractSemver: "^0.69", RequestVersion: 1, ResultVersion: 1}, want: "does not include"},
+		{name: "request version", requirement: RequirementV1{ContractSemver: "0.69.0", RequestVersion: 2, ResultVersion: 1}, want: "request version"},
+		{name: "result version", requirement: RequirementV1{ContractSemver: "0.69.0", RequestVersion: 1, ResultVersion: 2}, want: "result version"},
+		{name: "unknown feature", requirement: RequirementV1{ContractSemver: "0.69.0", RequestVersion: 1, ResultVersion: 1, Features: []string{"bridge_v1"}}, want: "unsupported required feature"},
+		{name: "duplicate feature", requirement: RequirementV1{ContractSemver: "0.69.0", RequestVersion: 1, ResultVersion: 1, Features: []string{FeatureSend, FeatureSend}}, want: "duplicate required feature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Negotiate(tt.requirement); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Negotiate() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,556 @@
+package amqapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/acl"
+	"github.com/avivsinai/agent-message-queue/internal/config"
+	"github.com/avivsinai/agent-message-queue/internal/delivery"
+	"github.com/avivsinai/agent-message-queue/internal/format"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+	"github.com/avivsinai/agent-message-queue/internal/mailbox"
+	"github.com/avivsinai/agent-message-queue/internal/presence"
+	"github.com/avivsinai/agent-message-queue/internal/quota"
+	"github.com/avivsinai/agent-message-queue/internal/receipt"
+)
+
+// pollInterval paces Watch and WaitReceipt.
+var pollInterval = 250 * time.Millisecond
+
+// Send delivers a new message. With no Thread, a single-recipient send uses
+// the canonical p2p/<a>__<b> thread, exactly as amq send does. With
+// ToCapability instead of To, the recipient is the live agent advertising
+// that capability, chosen by Pick. The send runs the same pipeline as amq
+// send: recipient ACLs, out-of-office delegation, quotas, and the per-thread
+// loop breaker.
+func (c *Client) Send(ctx context.Context, request SendRequestV1) (SendResultV1, error) {
+	if err := checkRequestVersion(request.RequestVersion); err != nil {
+		return SendResultV1{}, err
+	}
+	var pick *delivery.CapabilityPick
+	recipients := make([]string, 0, len(request.To))
+	seen := map[string]bool{}
+	for _, raw := range request.To {
+		handle := strings.TrimSpace(raw)
+		if strings.Contains(handle, "@") {
+			return SendResultV1{}, fmt.Errorf("%w: %q; use the amq CLI for cross-project or cross-session sends", ErrUnsupportedRoute, raw)
+		}
+		if err := format.ValidateHandle("recipient", handle); err != nil {
+			return SendResultV1{}, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+		}
+		if !seen[handle] {
+			seen[handle] = true
+			recipients = append(recipients, handle)
+		}
+	}
+	msgContext := request.Context
+	if capability := strings.TrimSpace(request.ToCapability); capability != "" || request.Pick != "" {
+		if capability == "" {
+			return SendResultV1{}, fmt.Errorf("%w: pick requires to_capability", ErrInvalidRequest)
+		}
+		if len(recipients) > 0 {
+			return SendResultV1{}, fmt.Errorf("%w: use to or to_capability, not both", ErrInvalidRequest)
+		}
+		decision, err := c.pickCapability(capability, request.Pick)
+		if err != nil {
+			return SendResultV1{}, err
+		}
+		if _, taken := request.Context[delivery.RoutingContextKey]; taken {
+			return SendResultV1{}, fmt.Errorf("%w: context key %q is reserved for to_capability", ErrInvalidRequest, delivery.RoutingContextKey)
+		}
+		msgContext = make(map[string]any, len(request.Context)+1)
+		for key, value := range request.Context {
+			msgContext[key] = value
+		}
+		msgContext[delivery.RoutingContextKey] = decision.ContextValue()
+		pick = &decision
+		recipients = []string{decision.Chosen}
+	}
+	if len(recipients) == 0 {
+		return SendResultV1{}, fmt.Errorf("%w: at least one recipient is required", ErrInvalidRequest)
+	}
+	threadID := strings.TrimSpace(request.Thread)
+	if threadID == "" {
+		if len(recipients) != 1 {
+			return SendResultV1{}, fmt.Errorf("%w: thread is required when sending to multiple recipients", ErrInvalidRequest)
+		}
+		threadID = canonicalP2P(c.me, recipients[0])
+	}
+	header := format.Header{
+		To:      recipients,
+		Thread:  threadID,
+		Subject: strings.TrimSpace(request.Subject),
+		Refs:    request.Refs,
+		Labels:  request.Labels,
+		Context: msgContext,
+	}
+	return c.deliver(ctx, header, request.Kind, request.Priority, request.Body, request.OnFull, pick)
+}
+
+// pickCapability chooses the recipient of a ToCapability send. The client
+// never picks itself. Presence is judged by its age alone; the CLI also
+// counts a live wake notifier.
+func (c *Client) pickCapability(capability string, pick PickV1) (delivery.CapabilityPick, error) {
+	switch pick {
+	case "":
+		pick = PickLeastLoaded
+	case PickLeastLoaded, PickRoundRobin:
+	default:
+		return delivery.CapabilityPick{}, fmt.Errorf("%w: pick must be %s or %s", ErrInvalidRequest, PickLeastLoaded, PickRoundRobin)
+	}
+	decision, err := delivery.PickCapability(c.root, c.root, capability, string(pick), c.me, nil)
+	var none *delivery.NoCandidateError
+	if errors.As(err, &none) {
+		return decision, fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+	return decision, err
+}
+
+// Reply answers a message in the client's inbox on its thread, deriving the
+// subject and kind the way amq reply does.
+func (c *Client) Reply(ctx context.Context, request ReplyRequestV1) (SendResultV1, error) {
+	if err := checkRequestVersion(request.RequestVersion); err != nil {
+		return SendResultV1{}, err
+	}
+	original, _, _, err := c.findMessage(request.ID)
+	if err != nil {
+		return SendResultV1{}, err
+	}
+	if original.Header.ReplyTo != "" && strings.Contains(original.Header.ReplyTo, "@") {
+		return SendResultV1{}, fmt.Errorf("%w: original reply_to %q", ErrUnsupportedRoute, original.Header.ReplyTo)
+	}
+	recipient := original.Header.From
+	subject := strings.TrimSpace(request.Subject)
+	if subject == "" {
+		switch origSubject := original.Header.Subject; {
+		case origSubject == "":
+			subject = "Re: (no subject)"
+		case !strings.HasPrefix(strings.ToLower(origSubject), "re:"):
+			subject = "Re: " + origSubject
+		default:
+			subject = origSubject
+		}
+	}
+	kind := strings.TrimSpace(request.Kind)
+	if kind == "" {
+		switch original.Header.Kind {
+		case format.KindReviewRequest:
+			kind = format.KindReviewResponse
+		case format.KindQuestion:
+			kind = format.KindAnswer
+		default:
+			kind = original.Header.Kind
+		}
+	}
+	header := format.Header{
+		To:      []string{recipient},
+		Thread:  original.Header.Thread,
+		Subject: subject,
+		Refs:    append(append([]string(nil), original.Header.Refs...), original.Header.ID),
+		Labels:  request.Labels,
+		Context: request.Context,
+	}
+	return c.deliver(ctx, header, kind, request.Priority, request.Body, request.OnFull, nil)
+}
+
+func (c *Client) deliver(ctx context.Context, header format.Header, kind, priority, body string, onFull OnFullPolicyV1, pick *delivery.CapabilityPick) (SendResultV1, error) {
+	kind = strings.TrimSpace(kind)
+	priority = strings.TrimSpace(priority)
+	if !format.IsValidKind(kind) {
+		return SendResultV1{}, fmt.Errorf("%w: kind must be one of: %s", ErrInvalidRequest, format.ValidKindsList())
+	}
+	if !format.IsValidPriority(priority) {
+		return SendResultV1{}, fmt.Errorf("%w: priority must be one of: urgent, normal, low", ErrInvalidRequest)
+	}
+	if kind != "" && priority == "" {
+		priority = format.PriorityNormal
+	}
+	switch onFull {
+	case "", OnFullFail, OnFullWait, OnFullDropLow:
+	default:
+		return SendResultV1{}, fmt.Errorf("%w: on_full must be one of: fail, wait, drop-low", ErrInvalidRequest)
+	}
+	if err := c.validateKnown(header.To...); err != nil {
+		return SendResultV1{}, err
+	}
+	for _, recipient := range header.To {
+		if err := c.requireMailbox(recipient); err != nil {
+			return SendResultV1{}, err
+		}
+	}
+
+	now := time.Now()
+	id, err := format.NewMessageID(now)
+	if err != nil {
+		return SendResultV1{}, err
+	}
+	header.Schema = format.CurrentSchema
+	header.ID = id
+	header.From = c.me
+	header.Created = now.UTC().Format(time.RFC3339Nano)
+	header.Kind = kind
+	header.Priority = priority
+	if err := c.validateHeader(header); err != nil {
+		return SendResultV1{}, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	msg := format.Message{Header: header, Body: body}
+	result := SendResultV1{
+		ResultVersion: ResultVersionV1,
+		ID:            id,
+		Thread:        header.Thread,
+		To:            header.To,
+		Subject:       header.Subject,
+		Capability:    capabilityPickOf(pick),
+	}
+
+	delivered, err := delivery.Local{
+		ConfigFS:   c.root,
+		DeliveryFS: c.root,
+		OnFull:     string(onFull),
+		Capability: pick,
+	}.Deliver(ctx, msg)
+	if err != nil {
+		var denied *acl.DeniedError
+		if errors.As(err, &denied) {
+			return SendResultV1{}, fmt.Errorf("%w: %w", ErrDeliveryDenied, err)
+		}
+		var exceeded *quota.ExceededError
+		if errors.As(err, &exceeded) {
+			return SendResultV1{}, fmt.Errorf("%w: %w", ErrQuotaExceeded, err)
+		}
+		return SendResultV1{}, err
+	}
+	result.Warnings = delivered.Warnings
+	switch {
+	case delivered.Dropped:
+		result.Outcome = SendDropped
+	case delivered.Held:
+		result.Outcome = SendHeld
+	default:
+		result.Outcome = SendDelivered
+		for _, hop := range delivered.Hops {
+			result.Delegated = append(result.Delegated, DelegationV1(hop))
+		}
+		if delivered.OutboxErr != nil {
+			result.OutboxError = delivered.OutboxErr.Error()
+		}
+	}
+	return result, nil
+}
+
+func capabilityPickOf(pick *delivery.CapabilityPick) *CapabilityPickV1 {
+	if pick == nil {
+		return nil
+	}
+	out := &CapabilityPickV1{
+		Capability: pick.Capability,
+		Pick:       PickV1(pick.Pick),
+		Chosen:     pick.Chosen,
+		Reason:     pick.Reason,
+		Candidates: make([]CapabilityCandidateV1, 0, len(pick.Candidates)),
+	}
+	for _, candidate := range pick.Candidates {
+		out.Candidates = append(out.Candidates, CapabilityCandidateV1{
+			Handle:         candidate.Handle,
+			InboxDepth:     candidate.InboxDepth,
+			LastSeen:       candidate.LastSeen,
+			Status:         candidate.Status,
+			PresenceSource: candidate.PresenceSource,
+			Live:           candidate.Live,
+		})
+	}
+	return out
+}
+
+// List returns message summaries from inbox/new (default) or inbox/cur,
+// oldest first, without moving anything.
+func (c *Client) List(_ context.Context, request ListRequestV1) (ListResultV1, error) {
+	if err := checkRequestVersion(request.RequestVersion); err != nil {
+		return ListResultV1{}, err
+	}
+	box := request.Box
+	if box == "" {
+		box = BoxNew
+	}
+	if box != BoxNew && box != BoxCur {
+		return ListResultV1{}, fmt.Errorf("%w: box must be new or cur", ErrInvalidRequest)
+	}
+	if request.Limit < 0 {
+		return ListResultV1{}, fmt.Errorf("%w: limit must be >= 0", ErrInvalidRequest)
+	}
+	messages, err := c.scanBox(box)
+	if err != nil {
+		return ListResultV1{}, err
+	}
+	if request.Limit > 0 && len(messages) > request.Limit {
+		messages = messages[:request.Limit]
+	}
+	result := ListResultV1{ResultVersion: ResultVersionV1, Box: box, Messages: make([]MessageSummaryV1, 0, len(messages))}
+	for _, msg := range messages {
+		result.Messages = append(result.Messages, summaryOf(msg.Header))
+	}
+	return result, nil
+}
+
+// Read returns one message by id. A message in inbox/new is validated, moved
+// to inbox/cur, and acknowledged with a drained receipt; an invalid one is
+// moved to the DLQ instead, matching amq read.
+func (c *Client) Read(_ context.Context, request ReadRequestV1) (ReadResultV1, error) {
+	if err := checkRequestVersion(request.RequestVersion); err != nil {
+		return ReadResultV1{}, err
+	}
+	if err := c.refuseQueue(); err != nil {
+		return ReadResultV1{}, err
+	}
+	msg, box, filename, err := c.findMessage(request.ID)
+	if err != nil {
+		return ReadResultV1{}, err
+	}
+	if box == BoxNew {
+		if err := c.claim(filename, msg.Header); err != nil {
+			return ReadResultV1{}, err
+		}
+	}
+	return ReadResultV1{ResultVersion: ResultVersionV1, Box: box, Message: messageOf(msg, true)}, nil
+}
+
+// Drain claims up to Limit new messages (0 = all) with the same claim-first
+// drain as amq drain: each message is moved to inbox/cur before it is parsed,
+// and one that then fails parsing or validation is moved to the DLQ and
+// reported in Failed. Failures count toward Limit.
+func (c *Client) Drain(_ context.Context, request DrainRequestV1) (DrainResultV1, error) {
+	if err := checkRequestVersion(request.RequestVersion); err != nil {
+		return DrainResultV1{}, err
+	}
+	if request.Limit < 0 {
+		return DrainResultV1{}, fmt.Errorf("%w: limit must be >= 0", ErrInvalidRequest)
+	}
+	if err := c.refuseQueue(); err != nil {
+		return DrainResultV1{}, err
+	}
+	validator, err := c.headerValidator()
+	if err != nil {
+		return DrainResultV1{}, err
+	}
+	items, drainErr := mailbox.Drain(c.root, c.me, mailbox.DrainOptions{
+		IncludeBody: request.IncludeBody,
+		Limit:       request.Limit,
+		Validator:   validator,
+	})
+	var vanished *mailbox.VanishedError
+	if errors.As(drainErr, &vanished) {
+		drainErr = fmt.Errorf("%w: %w", ErrNotFound, drainErr)
+	}
+	result := DrainResultV1{ResultVersion: ResultVersionV1, Messages: []MessageV1{}, Failed: []DrainFailureV1{}}
+	for _, item := range items {
+		if item.ParseError != "" {
+			result.Failed = append(result.Failed, DrainFailureV1{
+				ID:         item.ID,
+				Reason:     item.FailureReason,
+				Detail:     item.ParseError,
+				MovedToDLQ: item.MovedToDLQ,
+			})
+			continue
+		}
+		result.Messages = append(result.Messages, messageOfItem(item))
+	}
+	if len(result.Messages) > 0 {
+		_ = presence.ReturnDeliveryRoot(c.root, c.me)
+	}
+	return result, drainErr
+}
+
+// scanBox parses every valid message in one inbox leaf, oldest first. It
+// only peeks: invalid messages are skipped and left for Drain or Read.
+func (c *Client) scanBox(box BoxV1) ([]format.Message, error) {
+	dir := filepath.Join("agents", c.me, "inbox", string(box))
+	entries, err := c.root.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var messages []format.Message
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || fsq.ValidateMessageFilename(entry.Name()) != nil {
+			continue
+		}
+		msg, err := c.readMessage(filepath.Join(dir, entry.Name()))
+		if err != nil || c.validateHeader(msg.Header) != nil {
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	sort.SliceStable(messages, func(i, j int) bool {
+		if messages[i].Header.Created != messages[j].Header.Created {
+			return messages[i].Header.Created < messages[j].Header.Created
+		}
+		return messages[i].Header.ID < messages[j].Header.ID
+	})
+	return messages, nil
+}
+
+type scanFailure struct {
+	filename string
+	reason   string
+	detail   string
+	header   *format.Header
+}
+
+func (c *Client) readMessage(path string) (format.Message, error) {
+	data, err := c.root.ReadRegularNoFollow(path)
+	if err != nil {
+		return format.Message{}, err
+	}
+	if len(data) > format.MaxMessageSize {
+		return format.Message{}, fmt.Errorf("%w: %d bytes", format.ErrMessageTooLarge, len(data))
+	}
+	return format.ParseMessage(data)
+}
+
+// findMessage locates id in inbox/new, then inbox/cur, returning the message,
+// its box, and its filename. Invalid messages in inbox/new are moved to the
+// DLQ and reported as errors.
+func (c *Client) findMessage(rawID string) (format.Message, BoxV1, string, error) {
+	id := strings.TrimSuffix(strings.TrimSpace(rawID), ".md")
+	if _, err := format.SafeBaseName(id); err != nil {
+		return format.Message{}, "", "", fmt.Errorf("%w: id: %v", ErrInvalidRequest, err)
+	}
+	filename := id + ".md"
+	for _, box := range []BoxV1{BoxNew, BoxCur} {
+		path := filepath.Join("agents", c.me, "inbox", string(box), filename)
+		msg, err := c.readMessage(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		failure := scanFailure{filename: filename}
+		if err != nil {
+			failure.reason, failure.detail = "parse_error", err.Error()
+		} else if verr := c.validateHeader(msg.Header); verr != nil {
+			header := msg.Header
+			failure.reason, failure.detail, failure.header = "invalid_header", "invalid header: "+verr.Error(), &header
+		} else {
+			return msg, box, filename, nil
+		}
+		if box == BoxNew {
+			c.moveToDLQ(failure)
+		}
+		return format.Message{}, "", "", fmt.Errorf("message %s: %s", id, failure.detail)
+	}
+	return format.Message{}, "", "", fmt.Errorf("%w: message %s", ErrNotFound, id)
+}
+
+// refuseQueue rejects Read and Drain for a client opened as a queue handle.
+// Queue items are taken with amq claim, which records a lease; an item drained
+// without one is never returned to the queue if its consumer goes away.
+func (c *Client) refuseQueue() error {
+	cfg, err := config.ReadConfig(c.root)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if _, queue := cfg.Queues[c.me]; queue {
+		return fmt.Errorf("%w: %q is a queue handle; take its items with amq claim", ErrInvalidRequest, c.me)
+	}
+	return nil
+}
+
+// claim moves a validated message from inbox/new to inbox/cur by its
+// filename and emits the drained receipt the sender may be waiting on.
+func (c *Client) claim(filename string, header format.Header) error {
+	if err := fsq.MoveNewToCur(c.root, c.me, filename); err != nil {
+		var committed *fsq.CommittedDurabilityError
+		if !errors.As(err, &committed) {
+			return err
+		}
+	}
+	_ = receipt.EmitDeliveryRoot(c.root, receipt.New(header.ID, header.Thread, header.From, c.me, receipt.StageDrained, ""))
+	return nil
+}
+
+func (c *Client) moveToDLQ(failure scanFailure) DrainFailureV1 {
+	id := strings.TrimSuffix(failure.filename, ".md")
+	out := DrainFailureV1{ID: id, Reason: failure.reason, Detail: failure.detail}
+	dlqPath, err := fsq.MoveToDLQ(c.root, c.me, failure.filename, id, failure.reason, failure.detail)
+	var committed *fsq.CommittedDurabilityError
+	if dlqPath == "" || (err != nil && !errors.As(err, &committed)) {
+		return out
+	}
+	out.MovedToDLQ = true
+	sender, thread := "", ""
+	if failure.header != nil {
+		sender, thread = failure.header.From, failure.header.Thread
+	}
+	_ = receipt.EmitDeliveryRoot(c.root, receipt.New(id, thread, sender, c.me, receipt.StageDLQ, failure.detail))
+	return out
+}
+
+func summaryOf(header format.Header) MessageSummaryV1 {
+	return MessageSummaryV1{
+		ID:       header.ID,
+		From:     header.From,
+		To:       header.To,
+		Thread:   header.Thread,
+		Subject:  header.Subject,
+		Created:  header.Created,
+		Priority: header.Priority,
+		Kind:     header.Kind,
+		Labels:   header.Labels,
+	}
+}
+
+func messageOfItem(item mailbox.Item) MessageV1 {
+	return MessageV1{
+		MessageSummaryV1: MessageSummaryV1{
+			ID:       item.ID,
+			From:     item.From,
+			To:       item.To,
+			Thread:   item.Thread,
+			Subject:  item.Subject,
+			Created:  item.Created,
+			Priority: item.Priority,
+			Kind:     item.Kind,
+			Labels:   item.Labels,
+		},
+		Refs:    item.Refs,
+		Context: item.Context,
+		ReplyTo: item.ReplyTo,
+		Body:    item.Body,
+	}
+}
+
+func messageOf(msg format.Message, includeBody bool) MessageV1 {
+	out := MessageV1{
+		MessageSummaryV1: summaryOf(msg.Header),
+		Refs:             msg.Header.Refs,
+		Context:          msg.Header.Context,
+		ReplyTo:          msg.Header.ReplyTo,
+	}
+	if includeBody {
+		out.Body = msg.Body
+	}
+	return out
+}
+
+func canonicalP2P(a, b string) string {
+	a = strings.ToLower(strings.TrimSpace(a))
+	b = strings.ToLower(strings.TrimSpace(b))
+	if a > b {
+		a, b = b, a
+	}
+	return "p2p/" + a + "__" + b
+}
+
+func checkRequestVersion(version int) error {
+	if version != 0 && version != RequestVersionV1 {
+		return fmt.Errorf("%w: unsupported request version %d", ErrInvalidRequest, version)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package amqapi
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+func TestSchemaObjectsAreFailClosedAndMatchGoTypes(t *testing.T) {
+	defs := objectMap(t, loadSchemaDocument(t)["$defs"], "$defs")
+	for name, raw := range defs {
+		def := objectMap(t, raw, "$defs."+name)
+		if def["type"] == "object" && def["additionalProperties"] != false {
+			t.Errorf("$defs.%s does not set additionalProperties: false", name)
+		}
+	}
+	for definition, typ := range map[string]reflect.Type{
+		"CompatibilityV1":       reflect.TypeOf(CompatibilityV1{}),
+		"RequirementV1":         reflect.TypeOf(RequirementV1{}),
+		"NegotiatedV1":          reflect.TypeOf(NegotiatedV1{}),
+		"OptionsV1":             reflect.TypeOf(OptionsV1{}),
+		"SendRequestV1":         reflect.TypeOf(SendRequestV1{}),
+		"ReplyRequestV1":        reflect.TypeOf(ReplyRequestV1{}),
+		"SendResultV1":          reflect.TypeOf(SendResultV1{}),
+		"CapabilityPickV1":      reflect.TypeOf(CapabilityPickV1{}),
+		"CapabilityCandidateV1": reflect.TypeOf(CapabilityCandidateV1{}),
+		"DelegationV1":          reflect.TypeOf(DelegationV1{}),
+		"ListRequestV1":         reflect.TypeOf(ListRequestV1{}),
+		"ListResultV1":          reflect.TypeOf(ListResultV1{}),
+		"MessageSummaryV1":      reflect.TypeOf(MessageSummaryV1{}),
+		"MessageV1":             reflect.TypeOf(MessageV1{}),
+		"ReadRequestV1":         reflect.TypeOf(ReadRequestV1{}),
+		"ReadResultV1":          reflect.TypeOf(ReadResultV1{}),
+		"DrainRequestV1":        reflect.TypeOf(DrainRequestV1{}),
+		"DrainResultV1":         reflect.TypeOf(DrainResultV1{}),
+		"DrainFailureV1":        reflect.TypeOf(DrainFailureV1{}),
+		"WatchRequestV1":        reflect.TypeOf(WatchRequestV1{}),
+		"WatchResultV1":         reflect.TypeOf(WatchResultV1{}),
+		"WaitReceiptRequestV1":  reflect.TypeOf(WaitReceiptRequestV1{}),
+		"ReceiptV1":             reflect.TypeOf(ReceiptV1{}),
+		"WaitReceiptResultV1":   reflect.TypeOf(WaitReceiptResultV1{}),
+		"ThreadRequestV1":       reflect.TypeOf(ThreadRequestV1{}),
+		"ThreadResultV1":        reflect.TypeOf(ThreadResultV1{}),
+		"RouteExplainRequestV1": reflect.TypeOf(RouteExplainRequestV1{}),
+		"RouteExplainResultV1":  reflect.TypeOf(RouteExplainResultV1{}),
+		"RouteV1":               reflect.TypeOf(RouteV1{}),
+	} {
+		properties := objectMap(t, objectMap(t, defs[definition], "$defs."+definition)["properties"], "$defs."+definition+".properties")
+		got := make([]string, 0, len(properties))
+		for name := range properties {
+			got = append(got, name)
+		}
+		sort.Strings(got)
+		want := jsonFieldNames(typ)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s fields = %v, want %v", definition, got, want)
+		}
+	}
+}
+
+// jsonFieldNames flattens embedded structs the way encoding/json does.
+func jsonFieldNames(typ reflect.Type) []string {
+	names := make([]string, 0, typ.NumField())
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Anonymous && field.Tag.Get("json") == "" {
+			names = append(names, jsonFieldNames(field.Type)...)
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func assertMatchesPublishedSchema(t *testing.T, definition string, result any) {
+	t.Helper()
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource("amq-api-v1.schema.json", loadSchemaDocument(t)); err != nil {
+		t.Fatal(err)
+	}
+	schema, err := compiler.Compile("amq-api-v1.schema.json#/$defs/" + definition)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var document any
+	if err := json.Unmarshal(raw, &document); err != nil {
+		t.Fatal(err)
+	}
+	if err := schema.Validate(document); err != nil {
+		t.Fatalf("live %s rejected by published schema: %v\n%s", definition, err, raw)
+	}
+}
+
+func loadSchemaDocument(t *testing.T) map[string]any {
+	t.Helper()
+	raw, err := os.ReadFile("../schemas/amq-api-v1.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var document any
+	if err := json.Unmarshal(raw, &document); err != nil {
+		t.Fatal(err)
+	}
+	return objectMap(t, document, "schema")
+}
+
+func objectMap(t *testing.T, value any, context string) map[string]any {
+	t.Helper()
+	object, ok := value.(map[string]any)
+	if !ok {
+		t.Fatalf("%s = %#v, want object", context, value)
+	}
+	return object
+}
@@ -0,0 +1,315 @@
+// Package amqapi defines AMQ's versioned public messaging contract. It gives
+// Go orchestrators the operations behind amq send, reply, list, read, drain,
+// watch, receipts wait, thread, and route explain without a subprocess. Every
+// call runs against a pinned delivery root, the shared session-pin policy,
+// and the same header validation the CLI enforces.
+//
+// The v1 contract is local: a Client is bound to one mailbox root. Cross-
+// project and cross-session routing, bridges, and integrations remain CLI
+// features.
+package amqapi
+
+import "errors"
+
+const (
+	RequestVersionV1 = 1
+	ResultVersionV1  = 1
+)
+
+// Feature names advertised by Compatibility and accepted by Negotiate.
+const (
+	FeatureSend         = "send_v1"
+	FeatureReply        = "reply_v1"
+	FeatureList         = "list_v1"
+	FeatureRead         = "read_v1"
+	FeatureDrain        = "drain_v1"
+	FeatureWatch        = "watch_v1"
+	FeatureWaitReceipt  = "wait_receipt_v1"
+	FeatureThread       = "thread_v1"
+	FeatureRouteExplain = "route_explain_v1"
+
+	// FeatureSendCapability is Send with ToCapability: the recipient is
+	// the live agent advertising a capability, as amq send --to-capability.
+	FeatureSendCapability = "send_capability_v1"
+)
+
+// Sentinel errors. Errors for these conditions wrap them so callers can use
+// errors.Is; other failures, such as I/O errors, are returned unwrapped.
+var (
+	ErrNotFound         = errors.New("amqapi: not found")
+	ErrInvalidRequest   = errors.New("amqapi: invalid request")
+	ErrSessionContext   = errors.New("amqapi: session context mismatch")
+	ErrQuotaExceeded    = errors.New("amqapi: mailbox quota exceeded")
+	ErrDeliveryDenied   = errors.New("amqapi: delivery refused by recipient ACL")
+	ErrUnsupportedRoute = errors.New("amqapi: route not supported by the local contract")
+)
+
+type OnFullPolicyV1 string
+
+const (
+	OnFullFail    OnFullPolicyV1 = "fail"
+	OnFullWait    OnFullPolicyV1 = "wait"
+	OnFullDropLow OnFullPolicyV1 = "drop-low"
+)
+
+type SendOutcomeV1 string
+
+const (
+	SendDelivered SendOutcomeV1 = "delivered"
+	SendHeld      SendOutcomeV1 = "held"
+	SendDropped   SendOutcomeV1 = "dropped"
+)
+
+// PickV1 chooses among live agents advertising a capability.
+type PickV1 string
+
+const (
+	PickLeastLoaded PickV1 = "least-loaded"
+	PickRoundRobin  PickV1 = "round-robin"
+)
+
+type BoxV1 string
+
+const (
+	BoxNew BoxV1 = "new"
+	BoxCur BoxV1 = "cur"
+)
+
+type CompatibilityV1 struct {
+	ContractSemver  string   `json:"contract_semver"`
+	RequestVersions []int    `json:"request_versions"`
+	ResultVersions  []int    `json:"result_versions"`
+	Features        []string `json:"features"`
+}
+
+type RequirementV1 struct {
+	ContractSemver string   `json:"contract_semver"`
+	RequestVersion int      `json:"request_version"`
+	ResultVersion  int      `json:"result_version"`
+	Features       []string `json:"features"`
+}
+
+type NegotiatedV1 struct {
+	ContractSemver string   `json:"contract_semver"`
+	RequestVersion int      `json:"request_version"`
+	ResultVersion  int      `json:"result_version"`
+	Features       []string `json:"features"`
+}
+
+// OptionsV1 binds a Client to one mailbox. Root is required. With Session,
+// Root is the base root and the client targets Root/<session>.
+type OptionsV1 struct {
+	Root             string `json:"root"`
+	Session          string `json:"session,omitempty"`
+	Me               string `json:"me"`
+	Strict           bool   `json:"strict,omitempty"`
+	IgnoreSessionPin bool   `json:"ignore_session_pin,omitempty"`
+}
+
+// SendRequestV1 names its recipients in To, or in ToCapability to route to
+// one live agent advertising that capability.
+type SendRequestV1 struct {
+	RequestVersion int            `json:"request_version"`
+	To             []string       `json:"to,omitempty"`
+	ToCapability   string         `json:"to_capability,omitempty"`
+	Pick           PickV1         `json:"pick,omitempty"`
+	Thread         string         `json:"thread,omitempty"`
+	Subject        string         `json:"subject,omitempty"`
+	Body           string         `json:"body"`
+	Kind           string         `json:"kind,omitempty"`
+	Priority       string         `json:"priority,omitempty"`
+	Labels         []string       `json:"labels,omitempty"`
+	Refs           []string       `json:"refs,omitempty"`
+	Context        map[string]any `json:"context,omitempty"`
+	OnFull         OnFullPolicyV1 `json:"on_full,omitempty"`
+}
+
+type ReplyRequestV1 struct {
+	RequestVersion int            `json:"request_version"`
+	ID             string         `json:"id"`
+	Subject        string         `json:"subject,omitempty"`
+	Body           string         `json:"body"`
+	Kind           string         `json:"kind,omitempty"`
+	Priority       string         `json:"priority,omitempty"`
+	Labels         []string       `json:"labels,omitempty"`
+	Context        map[string]any `json:"context,omitempty"`
+	OnFull         OnFullPolicyV1 `json:"on_full,omitempty"`
+}
+
+type SendResultV1 struct {
+	ResultVersion int           `json:"result_version"`
+	ID            string        `json:"id"`
+	Thread        string        `json:"thread"`
+	To            []string      `json:"to"`
+	Subject       string        `json:"subject,omitempty"`
+	Outcome       SendOutcomeV1 `json:"outcome"`
+	OutboxError   string        `json:"outbox_error,omitempty"`
+
+	Capability *CapabilityPickV1 `json:"capability,omitempty"`
+	Delegated  []DelegationV1    `json:"delegated,omitempty"`
+	// Warnings are best-effort steps that failed after delivery, such as
+	// an away reply that did not fit the sender's quota.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// CapabilityPickV1 records which agent a ToCapability send chose and why.
+type CapabilityPickV1 struct {
+	Capability string                  `json:"capability"`
+	Pick       PickV1                  `json:"pick"`
+	Chosen     string                  `json:"chosen"`
+	Reason     string                  `json:"reason"`
+	Candidates []CapabilityCandidateV1 `json:"candidates"`
+}
+
+type CapabilityCandidateV1 struct {
+	Handle         string `json:"handle"`
+	InboxDepth     int    `json:"inbox_depth"`
+	LastSeen       string `json:"last_seen,omitempty"`
+	Status         string `json:"derived_status"`
+	PresenceSource string `json:"presence_source,omitempty"`
+	Live           bool   `json:"live"`
+}
+
+// DelegationV1 is an out-of-office forward: the message for For went to
+// its delegate To, in copy or move mode.
+type DelegationV1 struct {
+	For         string `json:"for"`
+	To          string `json:"to"`
+	Mode        string `json:"mode"`
+	Until       string `json:"until,omitempty"`
+	ForwardedAt string `json:"forwarded_at,omitempty"`
+}
+
+type ListRequestV1 struct {
+	RequestVersion int   `json:"request_version"`
+	Box            BoxV1 `json:"box,omitempty"`
+	Limit          int   `json:"limit,omitempty"`
+}
+
+type ListResultV1 struct {
+	ResultVersion int                `json:"result_version"`
+	Box           BoxV1              `json:"box"`
+	Messages      []MessageSummaryV1 `json:"messages"`
+}
+
+type MessageSummaryV1 struct {
+	ID       string   `json:"id"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+	Thread   string   `json:"thread"`
+	Subject  string   `json:"subject"`
+	Created  string   `json:"created"`
+	Priority string   `json:"priority,omitempty"`
+	Kind     string   `json:"kind,omitempty"`
+	Labels   []string `json:"labels,omitempty"`
+}
+
+type MessageV1 struct {
+	MessageSummaryV1
+	Refs    []string       `json:"refs,omitempty"`
+	Context map[string]any `json:"context,omitempty"`
+	ReplyTo string         `json:"reply_to,omitempty"`
+	Body    string         `json:"body"`
+}
+
+type ReadRequestV1 struct {
+	RequestVersion int    `json:"request_version"`
+	ID             string `json:"id"`
+}
+
+type ReadResultV1 struct {
+	ResultVersion int       `json:"result_version"`
+	Box           BoxV1     `json:"box"`
+	Message       MessageV1 `json:"message"`
+}
+
+type DrainRequestV1 struct {
+	RequestVersion int  `json:"request_version"`
+	Limit          int  `json:"limit,omitempty"`
+	IncludeBody    bool `json:"include_body,omitempty"`
+}
+
+type DrainResultV1 struct {
+	ResultVersion int              `json:"result_version"`
+	Messages      []MessageV1      `json:"messages"`
+	Failed        []DrainFailureV1 `json:"failed"`
+}
+
+// DrainFailureV1 is an inbox message that could not be parsed or validated
+// and was moved to the dead letter queue instead of being delivered.
+type DrainFailureV1 struct {
+	ID         string `json:"id"`
+	Reason     string `json:"reason"`
+	Detail     string `json:"detail"`
+	MovedToDLQ bool   `json:"moved_to_dlq"`
+}
+
+type WatchRequestV1 struct {
+	RequestVersion int `json:"request_version"`
+	TimeoutMillis  int `json:"timeout_ms,omitempty"`
+}
+
+type WatchResultV1 struct {
+	ResultVersion int                `json:"result_version"`
+	Event         string             `json:"event"`
+	Messages      []MessageSummaryV1 `json:"messages"`
+}
+
+type WaitReceiptRequestV1 struct {
+	RequestVersion int    `json:"request_version"`
+	ID             string `json:"id"`
+	Consumer       string `json:"consumer"`
+	Stage          string `json:"stage,omitempty"`
+	TimeoutMillis  int    `json:"timeout_ms,omitempty"`
+}
+
+type ReceiptV1 struct {
+	MsgID     string `json:"msg_id"`
+	Thread    string `json:"thread,omitempty"`
+	Sender    string `json:"sender"`
+	Consumer  string `json:"consumer"`
+	Stage     string `json:"stage"`
+	EmittedAt string `json:"emitted_at"`
+	Detail    string `json:"detail,omitempty"`
+}
+
+type WaitReceiptResultV1 struct {
+	ResultVersion int        `json:"result_version"`
+	Event         string     `json:"event"`
+	Receipt       *ReceiptV1 `json:"receipt,omitempty"`
+}
+
+type ThreadRequestV1 struct {
+	RequestVersion int      `json:"request_version"`
+	ID             string   `json:"id"`
+	Agents         []string `json:"agents,omitempty"`
+	IncludeBody    bool     `json:"include_body,omitempty"`
+	Limit          int      `json:"limit,omitempty"`
+}
+
+type ThreadResultV1 struct {
+	ResultVersion int         `json:"result_version"`
+	Thread        string      `json:"thread"`
+	Messages      []MessageV1 `json:"messages"`
+}
+
+type RouteExplainRequestV1 struct {
+	RequestVersion int      `json:"request_version"`
+	To             []string `json:"to"`
+}
+
+type RouteExplainResultV1 struct {
+	ResultVersion int       `json:"result_version"`
+	Root          string    `json:"root"`
+	Routes        []RouteV1 `json:"routes"`
+}
+
+// RouteV1 explains where one recipient would be delivered.
+type RouteV1 struct {
+	Handle      string `json:"handle"`
+	Mailbox     string `json:"mailbox"`
+	Configured  bool   `json:"configured"`
+	Exists      bool   `json:"exists"`
+	Deliverable bool   `json:"deliverable"`
+	Reason      string `json:"reason,omitempty"`
+}
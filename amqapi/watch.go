@@ -0,0 +1,259 @@
+package amqapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/acl"
+	"github.com/avivsinai/agent-message-queue/internal/breaker"
+	"github.com/avivsinai/agent-message-queue/internal/config"
+	"github.com/avivsinai/agent-message-queue/internal/format"
+	"github.com/avivsinai/agent-message-queue/internal/quota"
+	"github.com/avivsinai/agent-message-queue/internal/receipt"
+	"github.com/avivsinai/agent-message-queue/internal/thread"
+)
+
+// defaultWaitTimeout matches amq receipts wait --timeout.
+const defaultWaitTimeout = 60 * time.Second
+
+// Watch blocks until inbox/new holds at least one valid message, TimeoutMillis
+// elapses, or ctx is done. It never claims messages; follow with Drain.
+func (c *Client) Watch(ctx context.Context, request WatchRequestV1) (WatchResultV1, error) {
+	if err := checkRequestVersion(request.RequestVersion); err != nil {
+		return WatchResultV1{}, err
+	}
+	if request.TimeoutMillis < 0 {
+		return WatchResultV1{}, fmt.Errorf("%w: timeout_ms must be >= 0", ErrInvalidRequest)
+	}
+	ctx, cancel := withOptionalTimeout(ctx, request.TimeoutMillis)
+	defer cancel()
+	for {
+		messages, err := c.scanBox(BoxNew)
+		if err != nil {
+			return WatchResultV1{}, err
+		}
+		if len(messages) > 0 {
+			result := WatchResultV1{ResultVersion: ResultVersionV1, Event: "messages", Messages: make([]MessageSummaryV1, 0, len(messages))}
+			for _, msg := range messages {
+				result.Messages = append(result.Messages, summaryOf(msg.Header))
+			}
+			return result, nil
+		}
+		if done, err := waitPoll(ctx); done {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return WatchResultV1{ResultVersion: ResultVersionV1, Event: "timeout", Messages: []MessageSummaryV1{}}, nil
+			}
+			return WatchResultV1{}, err
+		}
+	}
+}
+
+// WaitReceipt waits for Consumer's receipt for message ID at Stage (default
+// drained). A timeout is reported as Event "timeout", not as an error.
+func (c *Client) WaitReceipt(ctx context.Context, request WaitReceiptRequestV1) (WaitReceiptResultV1, error) {
+	if err := checkRequestVersion(request.RequestVersion); err != nil {
+		return WaitReceiptResultV1{}, err
+	}
+	id := strings.TrimSuffix(strings.TrimSpace(request.ID), ".md")
+	if _, err := format.SafeBaseName(id); err != nil {
+		return WaitReceiptResultV1{}, fmt.Errorf("%w: id: %v", ErrInvalidRequest, err)
+	}
+	consumer := strings.TrimSpace(request.Consumer)
+	if err := format.ValidateHandle("consumer", consumer); err != nil {
+		return WaitReceiptResultV1{}, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	stage := strings.TrimSpace(request.Stage)
+	if stage == "" {
+		stage = receipt.StageDrained
+	}
+	if stage != receipt.StageDrained && stage != receipt.StageDLQ {
+		return WaitReceiptResultV1{}, fmt.Errorf("%w: stage must be drained or dlq", ErrInvalidRequest)
+	}
+	if request.TimeoutMillis < 0 {
+		return WaitReceiptResultV1{}, fmt.Errorf("%w: timeout_ms must be >= 0", ErrInvalidRequest)
+	}
+	timeoutMillis := request.TimeoutMillis
+	if timeoutMillis == 0 {
+		timeoutMillis = int(defaultWaitTimeout / time.Millisecond)
+	}
+	ctx, cancel := withOptionalTimeout(ctx, timeoutMillis)
+	defer cancel()
+	path := filepath.Join("agents", consumer, "receipts", fmt.Sprintf("%s__%s__%s.json", id, consumer, stage))
+	for {
+		r, err := receipt.ReadDeliveryRoot(c.root, path)
+		if err == nil {
+			return WaitReceiptResultV1{ResultVersion: ResultVersionV1, Event: "matched", Receipt: &ReceiptV1{
+				MsgID: r.MsgID, Thread: r.Thread, Sender: r.Sender, Consumer: r.Consumer,
+				Stage: r.Stage, EmittedAt: r.EmittedAt, Detail: r.Detail,
+			}}, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return WaitReceiptResultV1{}, err
+		}
+		if done, err := waitPoll(ctx); done {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return WaitReceiptResultV1{ResultVersion: ResultVersionV1, Event: "timeout"}, nil
+			}
+			return WaitReceiptResultV1{}, err
+		}
+	}
+}
+
+// Thread collects a thread across the given agents' inboxes and outboxes
+// (default: the configured roster, else every mailbox), oldest first.
+func (c *Client) Thread(_ context.Context, request ThreadRequestV1) (ThreadResultV1, error) {
+	if err := checkRequestVersion(request.RequestVersion); err != nil {
+		return ThreadResultV1{}, err
+	}
+	threadID := strings.TrimSpace(request.ID)
+	if threadID == "" {
+		return ThreadResultV1{}, fmt.Errorf("%w: thread id is required", ErrInvalidRequest)
+	}
+	if request.Limit < 0 {
+		return ThreadResultV1{}, fmt.Errorf("%w: limit must be >= 0", ErrInvalidRequest)
+	}
+	agents := request.Agents
+	for _, agent := range agents {
+		if err := format.ValidateHandle("agent", agent); err != nil {
+			return ThreadResultV1{}, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+		}
+	}
+	if len(agents) == 0 {
+		if cfg, err := config.ReadConfig(c.root); err == nil && len(cfg.Agents) > 0 {
+			agents = cfg.Agents
+		} else {
+			listed, listErr := c.root.ReadDir("agents")
+			if listErr != nil && !errors.Is(listErr, os.ErrNotExist) {
+				return ThreadResultV1{}, fmt.Errorf("list agents: %w", listErr)
+			}
+			for _, entry := range listed {
+				if entry.IsDir() {
+					agents = append(agents, entry.Name())
+				}
+			}
+		}
+	}
+	entries, err := thread.CollectDeliveryRoot(c.root, threadID, agents, request.IncludeBody, func(string, error) error { return nil })
+	if err != nil {
+		return ThreadResultV1{}, err
+	}
+	if request.Limit > 0 && len(entries) > request.Limit {
+		entries = entries[len(entries)-request.Limit:]
+	}
+	result := ThreadResultV1{ResultVersion: ResultVersionV1, Thread: threadID, Messages: make([]MessageV1, 0, len(entries))}
+	for _, entry := range entries {
+		result.Messages = append(result.Messages, MessageV1{
+			MessageSummaryV1: MessageSummaryV1{
+				ID: entry.ID, From: entry.From, To: entry.To, Thread: entry.Thread, Subject: entry.Subject,
+				Created: entry.Created, Priority: entry.Priority, Kind: entry.Kind, Labels: entry.Labels,
+			},
+			Body: entry.Body,
+		})
+	}
+	return result, nil
+}
+
+// RouteExplain reports, without delivering, whether each recipient resolves
+// to a mailbox in this root and whether a Send would be accepted: the
+// recipient's ACL, its quota, and the loop breaker on the canonical p2p
+// thread are checked as a dry run.
+func (c *Client) RouteExplain(_ context.Context, request RouteExplainRequestV1) (RouteExplainResultV1, error) {
+	if err := checkRequestVersion(request.RequestVersion); err != nil {
+		return RouteExplainResultV1{}, err
+	}
+	if len(request.To) == 0 {
+		return RouteExplainResultV1{}, fmt.Errorf("%w: at least one recipient is required", ErrInvalidRequest)
+	}
+	known, err := c.knownAgents()
+	if err != nil {
+		return RouteExplainResultV1{}, err
+	}
+	now := time.Now()
+	result := RouteExplainResultV1{ResultVersion: ResultVersionV1, Root: c.path, Routes: make([]RouteV1, 0, len(request.To))}
+	for _, raw := range request.To {
+		handle := strings.TrimSpace(raw)
+		route := RouteV1{Handle: handle}
+		if strings.Contains(handle, "@") {
+			route.Reason = "cross_project_or_session_route_requires_cli"
+			result.Routes = append(result.Routes, route)
+			continue
+		}
+		if err := format.ValidateHandle("recipient", handle); err != nil {
+			route.Reason = "invalid_handle"
+			result.Routes = append(result.Routes, route)
+			continue
+		}
+		route.Mailbox = filepath.Join(c.path, "agents", handle)
+		_, route.Configured = known[handle]
+		route.Exists = c.requireMailbox(handle) == nil
+		switch {
+		case !route.Exists:
+			route.Reason = "mailbox_missing"
+		case c.strict && known != nil && !route.Configured:
+			route.Reason = "not_in_roster"
+		default:
+			route.Reason, err = c.admissionReason(handle, now)
+			if err != nil {
+				return RouteExplainResultV1{}, err
+			}
+			route.Deliverable = route.Reason == ""
+		}
+		result.Routes = append(result.Routes, route)
+	}
+	return result, nil
+}
+
+// admissionReason dry-runs the checks Send makes before it writes anything
+// for a message to handle, and returns the first refusal as a route reason.
+// Quotas are checked for a message of zero bytes under on_full=fail.
+func (c *Client) admissionReason(handle string, now time.Time) (string, error) {
+	header := format.Header{From: c.me, To: []string{handle}, Thread: canonicalP2P(c.me, handle)}
+	if err := acl.Authorize(c.root, header); err != nil {
+		var denied *acl.DeniedError
+		if errors.As(err, &denied) {
+			return "acl_denied", nil
+		}
+		return "", err
+	}
+	cfg, err := quota.Load(c.root)
+	if err != nil {
+		return "", err
+	}
+	if err := quota.Check(c.root, cfg, c.me, header.To, 0, now); err != nil {
+		var exceeded *quota.ExceededError
+		if errors.As(err, &exceeded) {
+			return "quota_exceeded", nil
+		}
+		return "", err
+	}
+	state, err := breaker.Lookup(c.root, header.Thread)
+	if err != nil && !errors.Is(err, breaker.ErrNotFound) {
+		return "", err
+	}
+	if err == nil && state.Status == breaker.StatusOpen {
+		return "breaker_open", nil
+	}
+	return "", nil
+}
+
+func withOptionalTimeout(ctx context.Context, millis int) (context.Context, context.CancelFunc) {
+	if millis > 0 {
+		return context.WithTimeout(ctx, time.Duration(millis)*time.Millisecond)
+	}
+	return context.WithCancel(ctx)
+}
+
+// waitPoll sleeps one poll interval, reporting done when ctx ends first.
+func waitPoll(ctx context.Context) (bool, error) {
+	select {
+	case <-ctx.Done():
+		return true, ctx.Err()
+	case <-time.After(pollInterval):
+		return false, nil
+	}
+}
@@ -92,6 +92,11 @@ Environment:
   AM_SESSION       pinned session name
   AM_ROOT_ID       identity token authenticating AM_ROOT
   AM_BASE_ROOT_ID  identity token authenticating AM_BASE_ROOT
+  AMQ_ACP_REPLY_TIMEOUT
+                   opt-in reply wait (for example 5m, at most 1h): session/prompt
+                   streams replies that reference the prompt as session/update
+                   chunks until they stop, the timeout expires, or the client
+                   sends session/cancel
 
 BUZZ_* variables are read only to refuse agents!=1 and non-owner inbound, then
 stripped before any message is written. A deployment lease is upstream; this
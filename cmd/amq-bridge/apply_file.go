@@ -83,14 +83,18 @@ func runApplyFile(args []string) error {
 	if destHost != localHost {
 		return fmt.Errorf("bridge dest_alias host %q is not local host-id %q", destHost, localHost)
 	}
-	public, generation, err := bridge.LoadTrustedFromDeliveryRoot(root, env.SourceHost)
+	trusted, err := bridge.LoadTrustedKeysFromDeliveryRoot(root, env.SourceHost)
 	if err != nil {
 		return fmt.Errorf("authenticate source host %q: %w", env.SourceHost, err)
 	}
-	if err := bridge.VerifyEnvelope(env, public, generation); err != nil {
+	if err := bridge.VerifyEnvelopeTrusted(env, trusted, time.Now()); err != nil {
 		return fmt.Errorf("authenticate transfer %s: %w", env.TransferID, err)
 	}
-	applyResult, err := bridge.ApplyEnvelope(root, localHost, destAgent, env)
+	opened, err := openEnvelope(root, env)
+	if err != nil {
+		return err
+	}
+	applyResult, err := bridge.ApplyEnvelope(root, localHost, destAgent, opened)
 	if err != nil {
 		return fmt.Errorf("apply transfer %s: %w", env.TransferID, err)
 	}
@@ -100,6 +104,9 @@ func runApplyFile(args []string) error {
 		PayloadSHA256:   env.PayloadSHA256,
 		Replayed:        applyResult.Replayed,
 		SourceMessageID: env.SourceMessageID,
+		SourceHost:      env.SourceHost,
+		SourceHandle:    env.SourceHandle,
+		DestAlias:       env.DestAlias,
 		CommittedPath:   applyResult.Path,
 		EmittedAt:       time.Now().UTC().Format(time.RFC3339Nano),
 	}
@@ -150,3 +157,21 @@ func readApplyFile(root *fsq.DeliveryRoot, rel string) ([]byte, error) {
 	}
 	return data, nil
 }
+
+// openEnvelope decrypts a verified v2 envelope with the local box keys. A v1
+// envelope needs no identity and is returned as is; a v2 envelope that does
+// not decrypt fails closed before anything reaches the Maildir.
+func openEnvelope(root *fsq.DeliveryRoot, env bridge.Envelope) (bridge.Envelope, error) {
+	if env.Version == bridge.EnvelopeVersion {
+		return env, nil
+	}
+	keys, err := bridge.LoadBoxKeysFromDeliveryRoot(root)
+	if err != nil {
+		return bridge.Envelope{}, fmt.Errorf("open transfer %s: %w", env.TransferID, err)
+	}
+	opened, err := bridge.OpenEnvelope(env, keys)
+	if err != nil {
+		return bridge.Envelope{}, fmt.Errorf("open transfer %s: %w", env.TransferID, err)
+	}
+	return opened, nil
+}
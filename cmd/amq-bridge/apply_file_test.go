@@ -42,7 +42,8 @@ func TestApplyFileReplayAndConflictPreserveMaildirAndReceipt(t *testing.T) {
 	root := newBridgeRoot(t, "claude")
 	ensureHostID(t, root, "mac")
 	ensureTrusted(t, root, "grok-host")
-	env := applyFileTestEnvelope(t, "replay-file", []byte("first"))
+	first := testMessage(t, "replay-file-message", "replay-file-thread", "codex", "first")
+	env := applyFileTestEnvelope(t, "replay-file", first)
 	path := writeApplyFileEnvelope(t, root, "envelope.json", env)
 
 	if err := runApplyFile([]string{"--root", root, "--file", path}); err != nil {
@@ -59,14 +60,14 @@ func TestApplyFileReplayAndConflictPreserveMaildirAndReceipt(t *testing.T) {
 		t.Fatalf("inbox entries after replay = %d, want 1", len(entries))
 	}
 
-	conflict := applyFileTestEnvelope(t, env.TransferID, []byte("different"))
+	conflict := applyFileTestEnvelope(t, env.TransferID, testMessage(t, "replay-file-message", "replay-file-thread", "codex", "different"))
 	writeApplyFileEnvelope(t, root, "envelope.json", conflict)
 	err = runApplyFile([]string{"--root", root, "--file", path})
 	if !errors.Is(err, os.ErrExist) {
 		t.Fatalf("conflict error = %v, want EEXIST", err)
 	}
 	got, err := os.ReadFile(filepath.Join(root, "agents", "claude", "inbox", "new", bridge.TransferFilename(env.SourceHost, env.TransferID)))
-	if err != nil || string(got) != "first" {
+	if err != nil || string(got) != string(first) {
 		t.Fatalf("conflict changed committed payload: %q %v", got, err)
 	}
 	receipt := readApplyReceipt(t, root, env)
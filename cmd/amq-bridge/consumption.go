@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/bridge"
+	"github.com/avivsinai/agent-message-queue/internal/format"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+	"github.com/avivsinai/agent-message-queue/internal/receipt"
+)
+
+// ForwardResult lists the receipt envelopes accepted by the rendezvous in one
+// cycle.
+type ForwardResult struct {
+	Receipts []Receipt `json:"receipts,omitempty"`
+}
+
+// ForwardReceiptsOnce sends at most BatchSize signed receipt envelopes back
+// to the source of transfers this host committed, one for each local drained
+// or dlq receipt that has not been forwarded yet. A host without a bridge
+// identity cannot sign and forwards nothing.
+func (c *Courier) ForwardReceiptsOnce(ctx context.Context) (ForwardResult, error) {
+	var result ForwardResult
+	root, err := c.openDeliveryRoot()
+	if err != nil {
+		return result, err
+	}
+	defer func() { _ = root.Close() }()
+
+	committedSuffix := "__" + string(ReceiptDestinationMaildirCommit) + ".json"
+	var identity *bridge.HostKey
+	for _, dir := range c.inboundReceiptDirs() {
+		entries, err := root.ReadDir(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return result, fmt.Errorf("read bridge receipts: %w", err)
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || !strings.HasPrefix(name, "xfer-") || !strings.HasSuffix(name, committedSuffix) {
+				continue
+			}
+			done, err := c.forwardCommitted(ctx, root, filepath.Join(dir, name), &identity, &result)
+			if err != nil || done {
+				return result, err
+			}
+		}
+	}
+	return result, nil
+}
+
+// inboundReceiptDirs are the directories that may hold commit receipts for
+// transfers from a peer: the flat directory, and with routes each inbound
+// peer's own.
+func (c *Courier) inboundReceiptDirs() []string {
+	dirs := []string{c.receiptRelDir}
+	hosts := make([]string, 0, len(c.routes))
+	for host, route := range c.routes {
+		if route.Inbound {
+			hosts = append(hosts, host)
+		}
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		dirs = append(dirs, c.receiptDir(host))
+	}
+	return dirs
+}
+
+// forwardCommitted forwards the not yet forwarded drained and dlq receipts
+// for one committed transfer. done is set once the batch is full or this
+// host has no identity to sign with.
+func (c *Courier) forwardCommitted(ctx context.Context, root *fsq.DeliveryRoot, path string, identity **bridge.HostKey, result *ForwardResult) (bool, error) {
+	name := filepath.Base(path)
+	committed, err := readBridgeReceipt(root, path)
+	if err != nil {
+		return false, err
+	}
+	// Receipts written before routing was recorded have no return
+	// address; the source never learns about those transfers.
+	if committed.Kind != "" || committed.SourceHost == "" || committed.SourceHandle == "" || committed.DestAlias == "" {
+		return false, nil
+	}
+	if _, ok := c.allowedSource[committed.SourceHost]; !ok {
+		return false, nil
+	}
+	_, consumer, err := bridge.ParseAlias(committed.DestAlias)
+	if err != nil {
+		return false, fmt.Errorf("receipt %s: %w", name, err)
+	}
+	for _, stage := range []string{receipt.StageDrained, receipt.StageDLQ} {
+		forwardID := bridge.ReceiptTransferID(committed.TransferID, stage)
+		if _, err := c.findReceipt(root, committed.SourceHost, receiptFilename(forwardID, ReceiptTransportAccepted)); err == nil {
+			continue
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return false, err
+		}
+		local, ok, err := localConsumptionReceipt(root, committed, consumer, stage)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			continue
+		}
+		if *identity == nil {
+			key, err := bridge.LoadIdentity(c.cfg.Root)
+			if errors.Is(err, os.ErrNotExist) {
+				return true, nil
+			}
+			if err != nil {
+				return false, err
+			}
+			*identity = &key
+		}
+		env, err := bridge.NewReceiptEnvelope(c.hostID, (*identity).Generation, committed.SourceHost+"/"+committed.SourceHandle, bridge.ConsumptionReceipt{
+			TransferID: committed.TransferID,
+			MsgID:      committed.SourceMessageID,
+			Thread:     local.Thread,
+			Sender:     committed.SourceHandle,
+			Consumer:   consumer,
+			Stage:      stage,
+			EmittedAt:  local.EmittedAt,
+			Detail:     local.Detail,
+		})
+		if err != nil {
+			return false, err
+		}
+		if err := c.sealForPeer(&env, **identity); err != nil {
+			return false, fmt.Errorf("encrypt receipt for %s: %w", committed.TransferID, err)
+		}
+		if err := bridge.SignEnvelope(&env, **identity); err != nil {
+			return false, fmt.Errorf("sign receipt for %s: %w", committed.TransferID, err)
+		}
+		transport, err := c.transportTo(committed.SourceHost)
+		if err != nil {
+			return false, err
+		}
+		remote, err := c.postEnvelope(ctx, transport, env)
+		if err != nil {
+			return false, fmt.Errorf("forward %s receipt for %s: %w", stage, committed.TransferID, err)
+		}
+		forwarded := Receipt{
+			Stage:           ReceiptTransportAccepted,
+			TransferID:      remote.TransferID,
+			PayloadSHA256:   remote.PayloadSHA256,
+			SourceMessageID: env.SourceMessageID,
+			SourceHost:      env.SourceHost,
+			SourceHandle:    env.SourceHandle,
+			DestAlias:       env.DestAlias,
+			Kind:            bridge.EnvelopeKindReceipt,
+			EmittedAt:       time.Now().UTC().Format(time.RFC3339Nano),
+		}
+		if err := c.writeReceipt(root, forwarded); err != nil {
+			return false, fmt.Errorf("write transport receipt for %s: %w", forwardID, err)
+		}
+		result.Receipts = append(result.Receipts, forwarded)
+		if len(result.Receipts) >= c.cfg.BatchSize {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// localConsumptionReceipt finds the consumer's receipt for a committed
+// transfer. AMQ names receipts by header id; a message that failed to parse
+// is named by its transfer filename instead.
+func localConsumptionReceipt(root *fsq.DeliveryRoot, committed Receipt, consumer, stage string) (receipt.Receipt, bool, error) {
+	ids := []string{
+		committed.SourceMessageID,
+		strings.TrimSuffix(bridge.TransferFilename(committed.SourceHost, committed.TransferID), ".md"),
+	}
+	for _, id := range ids {
+		path := filepath.Join("agents", consumer, "receipts", fmt.Sprintf("%s__%s__%s.json", id, consumer, stage))
+		local, err := receipt.ReadDeliveryRoot(root, path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return receipt.Receipt{}, false, fmt.Errorf("read receipt %s: %w", root.DisplayPath(path), err)
+		}
+		if local.Consumer != consumer || local.Stage != stage {
+			return receipt.Receipt{}, false, fmt.Errorf("receipt %s does not match its filename", root.DisplayPath(path))
+		}
+		return local, true, nil
+	}
+	return receipt.Receipt{}, false, nil
+}
+
+// applyConsumptionReceipt records a verified, opened receipt envelope under
+// bridge/remote-receipts/<host>/<consumer> on this host. It is accepted only
+// for a transfer this host pushed: the forward transfer id must be the one
+// this host derives for the message and the remote consumer, and a local
+// transport_accepted receipt for it must exist.
+func (c *Courier) applyConsumptionReceipt(root *fsq.DeliveryRoot, env bridge.Envelope, localAgent string) (Receipt, error) {
+	consumed, err := bridge.ParseConsumptionReceipt(env)
+	if err != nil {
+		return Receipt{}, err
+	}
+	if consumed.Sender != localAgent {
+		return Receipt{}, fmt.Errorf("receipt sender %q is not local agent %q", consumed.Sender, localAgent)
+	}
+	remoteAlias := env.SourceHost + "/" + consumed.Consumer
+	if consumed.TransferID != transferIDFor(c.localHost, consumed.MsgID, remoteAlias) {
+		return Receipt{}, fmt.Errorf("transfer %s is not %s sent to %s", consumed.TransferID, consumed.MsgID, remoteAlias)
+	}
+	var sent Receipt
+	sentPath, err := c.findReceipt(root, env.SourceHost, receiptFilename(consumed.TransferID, ReceiptTransportAccepted))
+	if err == nil {
+		sent, err = readBridgeReceipt(root, sentPath)
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return Receipt{}, fmt.Errorf("no transport_accepted receipt for transfer %s", consumed.TransferID)
+	}
+	if err != nil {
+		return Receipt{}, err
+	}
+	if sent.TransferID != consumed.TransferID || sent.SourceMessageID != consumed.MsgID {
+		return Receipt{}, fmt.Errorf("receipt %s conflicts with transfer", root.DisplayPath(sentPath))
+	}
+
+	detail := "amq-bridge: " + consumed.Stage + " on " + env.SourceHost
+	if consumed.Detail != "" {
+		detail += "; " + consumed.Detail
+	}
+	local := receipt.Receipt{
+		Schema:    format.CurrentSchema,
+		MsgID:     consumed.MsgID,
+		Thread:    consumed.Thread,
+		Sender:    consumed.Sender,
+		Consumer:  consumed.Consumer,
+		Stage:     consumed.Stage,
+		EmittedAt: consumed.EmittedAt,
+		Detail:    detail,
+	}
+	data, err := local.Marshal()
+	if err != nil {
+		return Receipt{}, err
+	}
+	localPath := bridge.RemoteReceiptPath(env.SourceHost, local.Consumer, local.MsgID, local.Stage)
+	_, existedErr := root.Stat(localPath)
+	if _, err := root.WriteFileAtomic(filepath.Dir(localPath), filepath.Base(localPath), data, 0o600); err != nil {
+		return Receipt{}, err
+	}
+	return Receipt{
+		Stage:           ReceiptDestinationMaildirCommit,
+		TransferID:      env.TransferID,
+		Replayed:        existedErr == nil,
+		SourceMessageID: consumed.MsgID,
+		SourceHost:      env.SourceHost,
+		SourceHandle:    env.SourceHandle,
+		DestAlias:       env.DestAlias,
+		Kind:            bridge.EnvelopeKindReceipt,
+		CommittedPath:   root.DisplayPath(localPath),
+		EmittedAt:       time.Now().UTC().Format(time.RFC3339Nano),
+	}, nil
+}
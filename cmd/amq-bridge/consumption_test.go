@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/bridge"
+	"github.com/avivsinai/agent-message-queue/internal/receipt"
+)
+
+func TestDrainedReceiptFlowsBackToSource(t *testing.T) {
+	store, _ := newTestRendezvous(t, t.TempDir(), defaultBridgeBatchSize)
+	server := httptest.NewServer(store)
+	t.Cleanup(server.Close)
+	senderRoot := newBridgeRoot(t, "codex")
+	receiverRoot := newBridgeRoot(t, "claude")
+	ensureHostID(t, receiverRoot, "mac")
+	ensureIdentity(t, receiverRoot, "mac")
+
+	spool := filepath.Join(senderRoot, "bridge", "outbox", "codex", "new")
+	if err := os.MkdirAll(spool, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	message := testMessage(t, "msg-drain", "thread-drain", "codex", "please drain me")
+	if err := os.WriteFile(filepath.Join(spool, "drain.md"), message, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sender := testCourier(t, Config{
+		Root: senderRoot, RendezvousURL: server.URL, SourceHost: "grok-host", SourceHandle: "codex",
+		DestAlias: "mac/claude", ReceiveAlias: "grok-host/codex",
+		AllowedDestAliases: []string{"mac/claude", "grok-host/codex"}, AllowedSourceHosts: []string{"mac"},
+	})
+	receiver := testCourier(t, Config{
+		Root: receiverRoot, RendezvousURL: server.URL, DestAlias: "mac/claude",
+		AllowedDestAliases: []string{"mac/claude"}, AllowedSourceHosts: []string{"grok-host"},
+	})
+	if _, err := sender.RunOnce(context.Background(), ModeBoth); err != nil {
+		t.Fatal(err)
+	}
+	run, err := receiver.RunOnce(context.Background(), ModePoll)
+	if err != nil || len(run.Poll.Receipts) != 1 || len(run.Forward.Receipts) != 0 {
+		t.Fatalf("receiver cycle before drain = %+v, %v; want a commit and nothing to forward", run, err)
+	}
+
+	// The destination agent drains the bridged message.
+	if err := receipt.EmitDeliveryRoot(mustOpenRoot(t, receiverRoot),
+		receipt.New("msg-drain", "thread-drain", "codex", "claude", receipt.StageDrained, "")); err != nil {
+		t.Fatal(err)
+	}
+	run, err = receiver.RunOnce(context.Background(), ModePoll)
+	if err != nil || len(run.Forward.Receipts) != 1 || run.Forward.Receipts[0].Kind != bridge.EnvelopeKindReceipt {
+		t.Fatalf("receiver cycle after drain = %+v, %v; want one forwarded receipt", run, err)
+	}
+	if again, err := receiver.ForwardReceiptsOnce(context.Background()); err != nil || len(again.Receipts) != 0 {
+		t.Fatalf("second forward = %+v, %v; want the receipt forwarded once", again, err)
+	}
+
+	poll, err := sender.PollOnce(context.Background())
+	if err != nil || len(poll.Receipts) != 1 || poll.Receipts[0].Kind != bridge.EnvelopeKindReceipt {
+		t.Fatalf("sender poll = %+v, %v; want one applied receipt", poll, err)
+	}
+	got, err := receipt.WaitForPath(filepath.Join(senderRoot, bridge.RemoteReceiptPath("mac", "claude", "msg-drain", receipt.StageDrained)), time.Second, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("source-side drained receipt: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(senderRoot, "agents", "claude")); !os.IsNotExist(err) {
+		t.Fatalf("remote receipt created a local agent directory: %v", err)
+	}
+	if got.Sender != "codex" || got.Thread != "thread-drain" || !strings.Contains(got.Detail, "mac") {
+		t.Fatalf("source-side receipt = %+v, want codex's message drained by claude on mac", got)
+	}
+	if entries, err := os.ReadDir(filepath.Join(senderRoot, "agents", "codex", "inbox", "new")); err != nil || len(entries) != 0 {
+		t.Fatalf("receipt envelope reached the sender inbox: %d entries (%v)", len(entries), err)
+	}
+}
+
+func TestReceiptForUnsentTransferIsRefused(t *testing.T) {
+	senderRoot := newBridgeRoot(t, "codex")
+	mac := testHostKey("mac", defaultKeyGeneration)
+	env, err := bridge.NewReceiptEnvelope("mac", mac.Generation, "grok-host/codex", bridge.ConsumptionReceipt{
+		TransferID: transferIDFor("grok-host", "msg-never-sent", "mac/claude"),
+		MsgID:      "msg-never-sent",
+		Sender:     "codex",
+		Consumer:   "claude",
+		Stage:      receipt.StageDrained,
+		EmittedAt:  time.Now().UTC().Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bridge.SignEnvelope(&env, mac); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := bridge.MarshalEnvelope(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			writeJSON(w, http.StatusOK, pollResponse{Envelopes: []json.RawMessage{raw}})
+			return
+		}
+		http.Error(w, "ack should not run", http.StatusInternalServerError)
+	}))
+	t.Cleanup(server.Close)
+	sender := testCourier(t, Config{
+		Root: senderRoot, RendezvousURL: server.URL, SourceHost: "grok-host", SourceHandle: "codex",
+		DestAlias: "mac/claude", ReceiveAlias: "grok-host/codex",
+		AllowedDestAliases: []string{"mac/claude", "grok-host/codex"}, AllowedSourceHosts: []string{"mac"},
+	})
+	if _, err := sender.PollOnce(context.Background()); err == nil || !strings.Contains(err.Error(), "no transport_accepted") {
+		t.Fatalf("receipt for an unsent transfer = %v, want refusal", err)
+	}
+	if _, err := os.Stat(filepath.Join(senderRoot, "agents", "claude")); !os.IsNotExist(err) {
+		t.Fatalf("refused receipt created a consumer directory: %v", err)
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/avivsinai/agent-message-queue/internal/acl"
 	"github.com/avivsinai/agent-message-queue/internal/bridge"
 	"github.com/avivsinai/agent-message-queue/internal/format"
 	"github.com/avivsinai/agent-message-queue/internal/fsq"
@@ -38,6 +39,10 @@ type ReceiptStage string
 const (
 	ReceiptTransportAccepted        ReceiptStage = "transport_accepted"
 	ReceiptDestinationMaildirCommit ReceiptStage = "destination_maildir_committed"
+	// ReceiptDestinationRefused acknowledges a transfer the destination
+	// quarantined instead of committing. It retires the transfer on the
+	// transport without claiming delivery.
+	ReceiptDestinationRefused ReceiptStage = "destination_refused"
 )
 
 // Receipt is the local, durable observation emitted by amq-bridge. The
@@ -48,6 +53,10 @@ type Receipt struct {
 	PayloadSHA256   string       `json:"payload_sha256"`
 	Replayed        bool         `json:"replayed,omitempty"`
 	SourceMessageID string       `json:"source_message_id,omitempty"`
+	SourceHost      string       `json:"source_host,omitempty"`
+	SourceHandle    string       `json:"source_handle,omitempty"`
+	DestAlias       string       `json:"dest_alias,omitempty"`
+	Kind            string       `json:"kind,omitempty"`
 	CommittedPath   string       `json:"committed_path,omitempty"`
 	EmittedAt       string       `json:"emitted_at"`
 }
@@ -79,13 +88,27 @@ const (
 	ModePoll Mode = "poll"
 )
 
+// Transport selects how envelopes move between couriers.
+type Transport string
+
+const (
+	TransportHTTPS Transport = "https"
+	TransportDir   Transport = "dir"
+)
+
 // Config is the operator-owned bridge configuration. The default local spool
 // is <AMQ root>/bridge/outbox/<source handle>/new. Files in that directory
 // must be complete AMQ message files; accepted files are moved to its sibling
 // sent directory. This is a small bridge spool, not Maildir synchronisation.
+//
+// Routes, when set, replace Transport, RendezvousURL, SharedDir,
+// AllowedSourceHosts, and the destination half of AllowedDestAliases: each
+// peer host is reached over its own route and may only send over it.
 type Config struct {
 	Root               string
+	Transport          Transport
 	RendezvousURL      string
+	SharedDir          string
 	SourceHost         string
 	SourceHandle       string
 	DestAlias          string
@@ -98,6 +121,7 @@ type Config struct {
 	KeyGeneration      string
 	BatchSize          int
 	HTTPClient         *http.Client
+	Routes             []bridge.Route
 }
 
 type Courier struct {
@@ -107,10 +131,31 @@ type Courier struct {
 	allowedSource map[string]struct{}
 	receiveAlias  string
 	localHost     string
-	localAgent    string
 	hostID        string
 	identity      *bridge.HostKey
 	receiptRelDir string
+	shared        *sharedDir
+	routes        map[string]peerRoute
+}
+
+// peerTransport is one rendezvous URL or shared directory.
+type peerTransport struct {
+	rendezvous string
+	shared     *sharedDir
+}
+
+func (t peerTransport) String() string {
+	if t.shared != nil {
+		return "dir " + t.shared.path
+	}
+	return "https " + t.rendezvous
+}
+
+// peerRoute is a validated bridge.Route.
+type peerRoute struct {
+	bridge.Route
+	transport   peerTransport
+	allowedDest map[string]struct{}
 }
 
 type PushResult struct {
@@ -118,18 +163,39 @@ type PushResult struct {
 }
 
 type PollResult struct {
-	Receipts []Receipt `json:"receipts,omitempty"`
+	Receipts    []Receipt    `json:"receipts,omitempty"`
+	Quarantined []Quarantine `json:"quarantined,omitempty"`
+}
+
+// Quarantine records an authenticated inbound envelope that this host
+// refused. It is kept under bridge/quarantine/ and acknowledged, so one
+// refused transfer does not block the transport behind it.
+type Quarantine struct {
+	TransferID    string          `json:"transfer_id"`
+	SourceHost    string          `json:"source_host"`
+	SourceHandle  string          `json:"source_handle,omitempty"`
+	DestAlias     string          `json:"dest_alias"`
+	Reason        string          `json:"reason"`
+	QuarantinedAt string          `json:"quarantined_at"`
+	Envelope      json.RawMessage `json:"envelope,omitempty"`
 }
 
+const quarantineRelDir = "bridge/quarantine"
+
 type RunResult struct {
-	Push PushResult `json:"push"`
-	Poll PollResult `json:"poll"`
+	Push    PushResult    `json:"push"`
+	Poll    PollResult    `json:"poll"`
+	Forward ForwardResult `json:"forward"`
 }
 
 type spoolItem struct {
-	name string
-	data []byte
-	env  bridge.Envelope
+	name      string
+	data      []byte
+	env       bridge.Envelope
+	transport peerTransport
+	// pinned is set when env was loaded from the item's envelope sidecar
+	// rather than sealed afresh this cycle.
+	pinned bool
 }
 
 // NewCourier validates the static routing policy before any network or
@@ -144,11 +210,47 @@ func NewCourier(cfg Config) (*Courier, error) {
 		return nil, fmt.Errorf("resolve bridge root: %w", err)
 	}
 	cfg.Root = root
-	if strings.TrimSpace(cfg.RendezvousURL) == "" {
-		return nil, fmt.Errorf("rendezvous URL is required")
+	var routes map[string]peerRoute
+	if len(cfg.Routes) > 0 {
+		if cfg.Transport != "" || cfg.RendezvousURL != "" || cfg.SharedDir != "" {
+			return nil, fmt.Errorf("routes replace --transport, --rendezvous, and --shared-dir")
+		}
+		if len(cfg.AllowedSourceHosts) > 0 {
+			return nil, fmt.Errorf("routes replace --allow-source-host; mark the peer's route inbound")
+		}
+		if routes, err = routeSet(cfg.Root, cfg.Routes); err != nil {
+			return nil, err
+		}
 	}
-	if err := validateRendezvousURL(cfg.RendezvousURL); err != nil {
-		return nil, err
+	if cfg.Transport == "" && routes == nil {
+		cfg.Transport = TransportHTTPS
+	}
+	var shared *sharedDir
+	switch cfg.Transport {
+	case "":
+	case TransportHTTPS:
+		if strings.TrimSpace(cfg.RendezvousURL) == "" {
+			return nil, fmt.Errorf("rendezvous URL is required")
+		}
+		if cfg.SharedDir != "" {
+			return nil, fmt.Errorf("shared directory is only used with the dir transport")
+		}
+		if err := validateRendezvousURL(cfg.RendezvousURL); err != nil {
+			return nil, err
+		}
+	case TransportDir:
+		if strings.TrimSpace(cfg.SharedDir) == "" {
+			return nil, fmt.Errorf("shared directory is required for the dir transport")
+		}
+		if cfg.RendezvousURL != "" {
+			return nil, fmt.Errorf("rendezvous URL is not used with the dir transport")
+		}
+		if shared, err = sharedDirOutside(cfg.Root, cfg.SharedDir); err != nil {
+			return nil, err
+		}
+		cfg.SharedDir = shared.path
+	default:
+		return nil, fmt.Errorf("invalid transport %q; want https or dir", cfg.Transport)
 	}
 	if cfg.KeyGeneration == "" {
 		cfg.KeyGeneration = defaultKeyGeneration
@@ -174,32 +276,51 @@ func NewCourier(cfg Config) (*Courier, error) {
 		}
 	}
 
-	if strings.TrimSpace(cfg.DestAlias) == "" {
+	if strings.TrimSpace(cfg.DestAlias) == "" && routes == nil {
 		return nil, fmt.Errorf("destination alias is required")
 	}
-	if _, _, err := bridge.ParseAlias(cfg.DestAlias); err != nil {
-		return nil, fmt.Errorf("destination alias: %w", err)
+	if cfg.DestAlias != "" {
+		if _, _, err := bridge.ParseAlias(cfg.DestAlias); err != nil {
+			return nil, fmt.Errorf("destination alias: %w", err)
+		}
 	}
 	allowedDest, err := aliasSet(cfg.AllowedDestAliases, "destination")
 	if err != nil {
 		return nil, err
 	}
+	for _, route := range routes {
+		for alias := range route.allowedDest {
+			allowedDest[alias] = struct{}{}
+		}
+	}
 	if len(allowedDest) == 0 {
 		return nil, fmt.Errorf("at least one destination alias must be allowlisted")
 	}
-	if _, ok := allowedDest[cfg.DestAlias]; !ok {
+	if _, ok := allowedDest[cfg.DestAlias]; !ok && cfg.DestAlias != "" {
 		return nil, fmt.Errorf("destination alias %q is not in the allowlist", cfg.DestAlias)
 	}
 	receiveAlias := strings.TrimSpace(cfg.ReceiveAlias)
 	if receiveAlias == "" {
 		receiveAlias = cfg.DestAlias
+	}
+	if receiveAlias == "" {
+		return nil, fmt.Errorf("receive alias is required with routes")
 	} else if _, ok := allowedDest[receiveAlias]; !ok {
 		return nil, fmt.Errorf("receive alias %q is not in the allowlist", receiveAlias)
 	}
-	localHost, localAgent, err := bridge.ParseAlias(receiveAlias)
+	localHost, _, err := bridge.ParseAlias(receiveAlias)
 	if err != nil {
 		return nil, fmt.Errorf("receive alias: %w", err)
 	}
+	for host, route := range routes {
+		if route.ReceiveAlias == "" {
+			continue
+		}
+		if aliasHost, _, _ := bridge.ParseAlias(route.ReceiveAlias); aliasHost != localHost {
+			return nil, fmt.Errorf("route for host %q receive_alias %q is not on local host %q", host, route.ReceiveAlias, localHost)
+		}
+		allowedDest[route.ReceiveAlias] = struct{}{}
+	}
 	if cfg.LocalHost != "" {
 		if err := fsq.ValidateHandle(cfg.LocalHost); err != nil {
 			return nil, fmt.Errorf("local host: %w", err)
@@ -212,6 +333,11 @@ func NewCourier(cfg Config) (*Courier, error) {
 	if err != nil {
 		return nil, err
 	}
+	for host, route := range routes {
+		if route.Inbound {
+			allowedSource[host] = struct{}{}
+		}
+	}
 	if cfg.SourceHost != "" {
 		if err := fsq.ValidateHandle(cfg.SourceHost); err != nil {
 			return nil, fmt.Errorf("source host: %w", err)
@@ -269,13 +395,120 @@ func NewCourier(cfg Config) (*Courier, error) {
 		allowedSource: allowedSource,
 		receiveAlias:  receiveAlias,
 		localHost:     localHost,
-		localAgent:    localAgent,
 		hostID:        hostID,
 		identity:      identity,
 		receiptRelDir: receiptRelDir,
+		shared:        shared,
+		routes:        routes,
 	}, nil
 }
 
+func sharedDirOutside(root, raw string) (*sharedDir, error) {
+	path, err := filepath.Abs(raw)
+	if err != nil {
+		return nil, fmt.Errorf("resolve shared directory: %w", err)
+	}
+	if _, err := rootRelativePath(root, path); err == nil || path == root {
+		return nil, fmt.Errorf("shared directory must be outside the AMQ root")
+	}
+	return &sharedDir{path: path}, nil
+}
+
+// routeSet validates a routing table and applies the same transport policy
+// as the single-peer flags to each route.
+func routeSet(root string, routes []bridge.Route) (map[string]peerRoute, error) {
+	if err := bridge.ValidateRoutes(routes); err != nil {
+		return nil, err
+	}
+	set := make(map[string]peerRoute, len(routes))
+	for _, route := range routes {
+		peer := peerRoute{Route: route, allowedDest: make(map[string]struct{}, len(route.DestAliases))}
+		if route.SharedDir != "" {
+			shared, err := sharedDirOutside(root, route.SharedDir)
+			if err != nil {
+				return nil, fmt.Errorf("route for host %q: %w", route.Host, err)
+			}
+			peer.transport.shared = shared
+		} else {
+			if err := validateRendezvousURL(route.Rendezvous); err != nil {
+				return nil, fmt.Errorf("route for host %q: %w", route.Host, err)
+			}
+			peer.transport.rendezvous = route.Rendezvous
+		}
+		for _, alias := range route.DestAliases {
+			peer.allowedDest[alias] = struct{}{}
+		}
+		set[route.Host] = peer
+	}
+	return set, nil
+}
+
+// transportTo returns the transport that reaches host: its route, or the
+// single configured transport when there is no routing table.
+func (c *Courier) transportTo(host string) (peerTransport, error) {
+	if c.routes == nil {
+		return peerTransport{rendezvous: c.cfg.RendezvousURL, shared: c.shared}, nil
+	}
+	route, ok := c.routes[host]
+	if !ok {
+		return peerTransport{}, fmt.Errorf("no route to host %q", host)
+	}
+	return route.transport, nil
+}
+
+// inboundTransport is one transport to poll for one local receive alias, and
+// the source hosts allowed to send to that alias over it.
+type inboundTransport struct {
+	transport peerTransport
+	alias     string
+	sources   map[string]struct{}
+}
+
+// receiveAliasFor is the local alias host sends to: its route's receive
+// alias, or the courier's own.
+func (c *Courier) receiveAliasFor(host string) string {
+	if route, ok := c.routes[host]; ok && route.ReceiveAlias != "" {
+		return route.ReceiveAlias
+	}
+	return c.receiveAlias
+}
+
+// inboundTransports groups inbound routes by transport and receive alias so
+// that hosts sharing one rendezvous or shared directory are polled once per
+// alias.
+func (c *Courier) inboundTransports() []inboundTransport {
+	if c.routes == nil {
+		return []inboundTransport{{
+			transport: peerTransport{rendezvous: c.cfg.RendezvousURL, shared: c.shared},
+			alias:     c.receiveAlias,
+			sources:   c.allowedSource,
+		}}
+	}
+	hosts := make([]string, 0, len(c.routes))
+	for host := range c.routes {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	var inbound []inboundTransport
+	index := map[string]int{}
+	for _, host := range hosts {
+		route := c.routes[host]
+		if !route.Inbound {
+			continue
+		}
+		alias := c.receiveAliasFor(host)
+		key := route.transport.String() + " " + alias
+		i, ok := index[key]
+		if !ok {
+			i = len(inbound)
+			index[key] = i
+			inbound = append(inbound, inboundTransport{transport: route.transport, alias: alias, sources: map[string]struct{}{}})
+		}
+		inbound[i].sources[host] = struct{}{}
+	}
+	return inbound
+}
+
 func aliasSet(values []string, kind string) (map[string]struct{}, error) {
 	set := make(map[string]struct{}, len(values))
 	for _, raw := range values {
@@ -370,7 +603,8 @@ func (c *Courier) openDeliveryRoot() (*fsq.DeliveryRoot, error) {
 // PushOnce posts at most BatchSize complete spool messages. A source file is
 // moved to sent only after the rendezvous returns a matching
 // transport_accepted receipt. A 200 response with any other stage is an
-// error, never a successful drain.
+// error, never a successful drain. With the dir transport the file stays in
+// the spool until the destination's ack file appears.
 func (c *Courier) PushOnce(ctx context.Context) (PushResult, error) {
 	var result PushResult
 	if err := c.requirePushConfig(); err != nil {
@@ -393,12 +627,18 @@ func (c *Courier) PushOnce(ctx context.Context) (PushResult, error) {
 	defer func() { _ = root.Close() }()
 
 	for _, item := range items {
+		posted := false
 		receipt, err := c.existingReceipt(root, item.env, ReceiptTransportAccepted)
 		if err != nil {
 			if !errors.Is(err, os.ErrNotExist) {
 				return result, fmt.Errorf("read transport receipt for %s: %w", item.name, err)
 			}
-			remote, postErr := c.postEnvelope(ctx, item.env)
+			if !item.pinned {
+				if err := pinEnvelope(filepath.Join(c.cfg.SpoolDir, item.name), item.data, item.env); err != nil {
+					return result, fmt.Errorf("pin envelope for %s: %w", item.name, err)
+				}
+			}
+			remote, postErr := c.postEnvelope(ctx, item.transport, item.env)
 			if postErr != nil {
 				return result, fmt.Errorf("push %s: %w", item.name, postErr)
 			}
@@ -407,15 +647,39 @@ func (c *Courier) PushOnce(ctx context.Context) (PushResult, error) {
 				TransferID:      remote.TransferID,
 				PayloadSHA256:   remote.PayloadSHA256,
 				SourceMessageID: item.env.SourceMessageID,
+				SourceHost:      item.env.SourceHost,
+				SourceHandle:    item.env.SourceHandle,
+				DestAlias:       item.env.DestAlias,
 				EmittedAt:       time.Now().UTC().Format(time.RFC3339Nano),
 			}
 			if err := c.writeReceipt(root, receipt); err != nil {
 				return result, fmt.Errorf("write transport receipt for %s: %w", item.name, err)
 			}
+			posted = true
 		}
 		if receipt.TransferID != item.env.TransferID || !strings.EqualFold(receipt.PayloadSHA256, item.env.PayloadSHA256) {
 			return result, fmt.Errorf("transport receipt conflicts with spool item %s", item.name)
 		}
+		if shared := item.transport.shared; shared != nil {
+			stage, err := shared.settled(item.env)
+			if err != nil {
+				return result, fmt.Errorf("check shared ack for %s: %w", item.name, err)
+			}
+			switch stage {
+			case "":
+				if posted {
+					result.Receipts = append(result.Receipts, receipt)
+				}
+				continue
+			case ReceiptDestinationRefused:
+				refused, err := c.recordRefused(root, item)
+				if err != nil {
+					return result, err
+				}
+				result.Receipts = append(result.Receipts, refused)
+				continue
+			}
+		}
 		if err := c.moveToSent(item.name, item.data); err != nil {
 			return result, fmt.Errorf("archive accepted spool item %s: %w", item.name, err)
 		}
@@ -424,70 +688,152 @@ func (c *Courier) PushOnce(ctx context.Context) (PushResult, error) {
 	return result, nil
 }
 
-// PollOnce fetches at most BatchSize envelopes, validates their destination
-// policy, applies them through internal/bridge, and ACKs only after the local
-// Maildir commit succeeds.
+// PollOnce fetches at most BatchSize envelopes from each inbound transport,
+// validates their destination policy, applies them through internal/bridge,
+// and ACKs only after the local Maildir commit succeeds. A receipt envelope
+// is applied into the local receipts of a transfer this host sent instead.
+// With routes, a source host is accepted only over its own route.
 func (c *Courier) PollOnce(ctx context.Context) (PollResult, error) {
 	var result PollResult
 	if err := c.guardPollIdentity(); err != nil {
 		return result, err
 	}
-	envelopes, err := c.pollEnvelopes(ctx)
+	for _, inbound := range c.inboundTransports() {
+		if err := c.pollTransport(ctx, inbound, &result); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func (c *Courier) pollTransport(ctx context.Context, inbound inboundTransport, result *PollResult) error {
+	envelopes, err := c.pollEnvelopes(ctx, inbound.transport, inbound.alias)
 	if err != nil {
-		return result, err
+		return err
 	}
 	if len(envelopes) == 0 {
-		return result, nil
+		return nil
 	}
 	root, err := c.openDeliveryRoot()
 	if err != nil {
-		return result, err
+		return err
 	}
 	defer func() { _ = root.Close() }()
+	_, localAgent, err := bridge.ParseAlias(inbound.alias)
+	if err != nil {
+		return err
+	}
 
 	for _, env := range envelopes {
-		if env.DestAlias != c.receiveAlias {
-			return result, fmt.Errorf("inbound destination alias %q does not match configured receiver %q", env.DestAlias, c.receiveAlias)
+		if env.DestAlias != inbound.alias {
+			return fmt.Errorf("inbound destination alias %q does not match configured receiver %q", env.DestAlias, inbound.alias)
 		}
 		if _, ok := c.allowedDest[env.DestAlias]; !ok {
-			return result, fmt.Errorf("inbound destination alias %q is not allowlisted", env.DestAlias)
+			return fmt.Errorf("inbound destination alias %q is not allowlisted", env.DestAlias)
 		}
-		if _, ok := c.allowedSource[env.SourceHost]; !ok {
-			return result, fmt.Errorf("inbound source host %q is not allowlisted", env.SourceHost)
+		if _, ok := inbound.sources[env.SourceHost]; !ok {
+			if c.routes != nil {
+				// A peer's envelope on another peer's transport is set aside
+				// rather than failing the poll, so it cannot wedge the
+				// envelopes behind it.
+				reason := fmt.Sprintf("source host %q is not routed over %s", env.SourceHost, inbound.transport)
+				if err := c.quarantine(ctx, root, inbound.transport, env, reason, result); err != nil {
+					return err
+				}
+				continue
+			}
+			return fmt.Errorf("inbound source host %q is not allowlisted", env.SourceHost)
 		}
-		pub, generation, err := bridge.LoadTrusted(c.cfg.Root, env.SourceHost)
+		trusted, err := bridge.LoadTrustedKeys(c.cfg.Root, env.SourceHost)
 		if err != nil {
-			return result, fmt.Errorf("authenticate source host %q: %w", env.SourceHost, err)
+			return fmt.Errorf("authenticate source host %q: %w", env.SourceHost, err)
 		}
-		if err := bridge.VerifyEnvelope(env, pub, generation); err != nil {
-			return result, fmt.Errorf("authenticate transfer %s: %w", env.TransferID, err)
+		if err := bridge.VerifyEnvelopeTrusted(env, trusted, time.Now()); err != nil {
+			return fmt.Errorf("authenticate transfer %s: %w", env.TransferID, err)
 		}
-		applyResult, err := bridge.ApplyEnvelope(root, c.localHost, c.localAgent, env)
+		opened, err := openEnvelope(root, env)
 		if err != nil {
-			return result, fmt.Errorf("apply transfer %s: %w", env.TransferID, err)
+			return err
 		}
-		receipt := Receipt{
-			Stage:           ReceiptDestinationMaildirCommit,
-			TransferID:      env.TransferID,
-			PayloadSHA256:   env.PayloadSHA256,
-			Replayed:        applyResult.Replayed,
-			CommittedPath:   applyResult.Path,
-			SourceMessageID: env.SourceMessageID,
-			EmittedAt:       time.Now().UTC().Format(time.RFC3339Nano),
+		var receipt Receipt
+		if opened.Kind == bridge.EnvelopeKindReceipt {
+			receipt, err = c.applyConsumptionReceipt(root, opened, localAgent)
+			if err != nil {
+				return fmt.Errorf("apply receipt %s: %w", env.TransferID, err)
+			}
+			receipt.PayloadSHA256 = env.PayloadSHA256
+		} else {
+			applyResult, err := bridge.ApplyEnvelope(root, c.localHost, localAgent, opened)
+			var denied *acl.DeniedError
+			if errors.As(err, &denied) {
+				if err := c.quarantine(ctx, root, inbound.transport, env, denied.Error(), result); err != nil {
+					return err
+				}
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("apply transfer %s: %w", env.TransferID, err)
+			}
+			receipt = Receipt{
+				Stage:           ReceiptDestinationMaildirCommit,
+				TransferID:      env.TransferID,
+				PayloadSHA256:   env.PayloadSHA256,
+				Replayed:        applyResult.Replayed,
+				CommittedPath:   applyResult.Path,
+				SourceMessageID: env.SourceMessageID,
+				SourceHost:      env.SourceHost,
+				SourceHandle:    env.SourceHandle,
+				DestAlias:       env.DestAlias,
+				EmittedAt:       time.Now().UTC().Format(time.RFC3339Nano),
+			}
 		}
 		if err := c.writeReceipt(root, receipt); err != nil {
-			return result, fmt.Errorf("write destination receipt for %s: %w", env.TransferID, err)
+			return fmt.Errorf("write destination receipt for %s: %w", env.TransferID, err)
 		}
-		if err := c.ackEnvelope(ctx, env); err != nil {
-			return result, fmt.Errorf("ack transfer %s: %w", env.TransferID, err)
+		if err := c.ackEnvelope(ctx, inbound.transport, env, ReceiptDestinationMaildirCommit); err != nil {
+			return fmt.Errorf("ack transfer %s: %w", env.TransferID, err)
 		}
 		result.Receipts = append(result.Receipts, receipt)
 	}
-	return result, nil
+	return nil
+}
+
+// quarantine keeps a refused envelope under bridge/quarantine/ with its
+// reason, then acknowledges it as destination_refused so the transport moves
+// on without the sender taking it for a commit. No destination receipt is
+// written: nothing was committed.
+func (c *Courier) quarantine(ctx context.Context, root *fsq.DeliveryRoot, t peerTransport, env bridge.Envelope, reason string, result *PollResult) error {
+	raw, err := bridge.MarshalEnvelope(env)
+	if err != nil {
+		return err
+	}
+	record := Quarantine{
+		TransferID:    env.TransferID,
+		SourceHost:    env.SourceHost,
+		SourceHandle:  env.SourceHandle,
+		DestAlias:     env.DestAlias,
+		Reason:        reason,
+		QuarantinedAt: time.Now().UTC().Format(time.RFC3339Nano),
+		Envelope:      raw,
+	}
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	if _, err := root.WriteFileAtomic(quarantineRelDir, env.TransferID+".json", append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("quarantine transfer %s: %w", env.TransferID, err)
+	}
+	if err := c.ackEnvelope(ctx, t, env, ReceiptDestinationRefused); err != nil {
+		return fmt.Errorf("ack quarantined transfer %s: %w", env.TransferID, err)
+	}
+	record.Envelope = nil
+	result.Quarantined = append(result.Quarantined, record)
+	return nil
 }
 
 // RunOnce executes one bounded push/poll cycle. It never treats the push
-// receipt as proof that a destination Maildir was committed.
+// receipt as proof that a destination Maildir was committed. A cycle that
+// polls also forwards new drained/dlq receipts for transfers it committed.
 func (c *Courier) RunOnce(ctx context.Context, mode Mode) (RunResult, error) {
 	var result RunResult
 	if mode != ModeBoth && mode != ModePush && mode != ModePoll {
@@ -499,6 +845,9 @@ func (c *Courier) RunOnce(ctx context.Context, mode Mode) (RunResult, error) {
 		if err != nil {
 			return result, err
 		}
+		if err := c.recordCycle("push", c.cfg.SourceHandle, len(push.Receipts)); err != nil {
+			return result, err
+		}
 	}
 	if mode == ModeBoth || mode == ModePoll {
 		poll, err := c.PollOnce(ctx)
@@ -506,10 +855,52 @@ func (c *Courier) RunOnce(ctx context.Context, mode Mode) (RunResult, error) {
 		if err != nil {
 			return result, err
 		}
+		forward, err := c.ForwardReceiptsOnce(ctx)
+		result.Forward = forward
+		if err != nil {
+			return result, err
+		}
+		if err := c.recordCycle("poll", c.receiveAlias, len(poll.Receipts)+len(forward.Receipts)); err != nil {
+			return result, err
+		}
 	}
 	return result, nil
 }
 
+// cycleState is the last successful push or poll of one courier. It lives in
+// bridge/state/ so amq-bridge status can tell an idle bridge from a stopped
+// one.
+type cycleState struct {
+	Direction string `json:"direction"`
+	Name      string `json:"name"`
+	At        string `json:"at"`
+	Receipts  int    `json:"receipts"`
+}
+
+const cycleStateRelDir = "bridge/state"
+
+func (c *Courier) recordCycle(direction, name string, receipts int) error {
+	data, err := json.MarshalIndent(cycleState{
+		Direction: direction,
+		Name:      name,
+		At:        time.Now().UTC().Format(time.RFC3339Nano),
+		Receipts:  receipts,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	root, err := c.openDeliveryRoot()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = root.Close() }()
+	filename := direction + "-" + strings.ReplaceAll(name, "/", "-") + ".json"
+	if _, err := root.WriteFileAtomic(cycleStateRelDir, filename, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("record %s cycle: %w", direction, err)
+	}
+	return nil
+}
+
 func (c *Courier) readSpool() ([]spoolItem, error) {
 	if err := os.MkdirAll(c.cfg.SpoolDir, 0o700); err != nil {
 		return nil, fmt.Errorf("create bridge spool: %w", err)
@@ -574,24 +965,115 @@ func (c *Courier) readSpool() ([]spoolItem, error) {
 		if destErr != nil {
 			return nil, fmt.Errorf("spool file %q dest: %w", name, destErr)
 		}
+		destHost, _, _ := bridge.ParseAlias(destAlias)
+		transport, err := c.transportTo(destHost)
+		if err != nil {
+			return nil, fmt.Errorf("spool file %q: %w", name, err)
+		}
 		env := envelopeForMessage(c.cfg, destAlias, message.Header.ID, message.Header.Thread, data)
 		if c.identity == nil {
 			return nil, fmt.Errorf("push requires a local host identity")
 		}
+		pinned, err := readPinnedEnvelope(path, env)
+		if err != nil {
+			return nil, fmt.Errorf("spool file %q: %w", name, err)
+		}
+		if pinned != nil {
+			env = *pinned
+		} else if err := c.sealForPeer(&env, *c.identity); err != nil {
+			return nil, fmt.Errorf("encrypt spool file %q: %w", name, err)
+		}
 		if err := bridge.SignEnvelope(&env, *c.identity); err != nil {
 			return nil, fmt.Errorf("sign spool file %q: %w", name, err)
 		}
-		items = append(items, spoolItem{name: name, data: data, env: env})
+		items = append(items, spoolItem{name: name, data: data, env: env, transport: transport, pinned: pinned != nil})
 	}
 	return items, nil
 }
 
+// sealForPeer encrypts env to the destination host when that host's trusted
+// record publishes a box key. Peers without one keep receiving v1.
+func (c *Courier) sealForPeer(env *bridge.Envelope, sender bridge.HostKey) error {
+	destHost, _, err := bridge.ParseAlias(env.DestAlias)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(bridge.TrustedPath(c.cfg.Root, destHost)); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	trusted, err := bridge.LoadTrustedKeys(c.cfg.Root, destHost)
+	if err != nil {
+		return err
+	}
+	generation, box, ok := bridge.PeerBoxKey(trusted, time.Now())
+	if !ok {
+		return nil
+	}
+	return bridge.SealEnvelope(env, sender, generation, box)
+}
+
+// envelopeSidecarPath is where a spool message's envelope is pinned on its
+// first push. Sealing depends on the peer's trusted box key, so resealing on
+// every cycle would turn a trust change into a new payload digest that no
+// longer matches the transport receipt, wedging the item.
+func envelopeSidecarPath(messagePath string) string {
+	return strings.TrimSuffix(messagePath, ".md") + ".envelope"
+}
+
+// pinnedEnvelope is the envelope sidecar: the envelope as first pushed and
+// the digest of the plaintext it was built from.
+type pinnedEnvelope struct {
+	PlaintextSHA256 string          `json:"plaintext_sha256"`
+	Envelope        bridge.Envelope `json:"envelope"`
+}
+
+// pinEnvelope records env as the envelope for the spool message at
+// messagePath whose bytes are plaintext.
+func pinEnvelope(messagePath string, plaintext []byte, env bridge.Envelope) error {
+	digest := sha256.Sum256(plaintext)
+	env.Signature = ""
+	data, err := json.Marshal(pinnedEnvelope{PlaintextSHA256: hex.EncodeToString(digest[:]), Envelope: env})
+	if err != nil {
+		return err
+	}
+	sidecar := envelopeSidecarPath(messagePath)
+	_, err = fsq.WriteFileAtomic(filepath.Dir(sidecar), filepath.Base(sidecar), data, 0o600)
+	return err
+}
+
+// readPinnedEnvelope returns the envelope pinned for the spool message that
+// fresh was built from, or nil when the message has not been pushed yet. The
+// pin must describe the same transfer of the same bytes; it is re-signed with
+// the current identity on every cycle.
+func readPinnedEnvelope(messagePath string, fresh bridge.Envelope) (*bridge.Envelope, error) {
+	data, err := fsq.ReadRegularNoFollow(envelopeSidecarPath(messagePath))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read envelope sidecar: %w", err)
+	}
+	var pinned pinnedEnvelope
+	if err := json.Unmarshal(data, &pinned); err != nil {
+		return nil, fmt.Errorf("invalid envelope sidecar: %w", err)
+	}
+	env := pinned.Envelope
+	if !strings.EqualFold(pinned.PlaintextSHA256, fresh.PayloadSHA256) ||
+		env.TransferID != fresh.TransferID || env.SourceHost != fresh.SourceHost ||
+		env.SourceHandle != fresh.SourceHandle || env.DestAlias != fresh.DestAlias ||
+		env.SourceMessageID != fresh.SourceMessageID || env.ThreadID != fresh.ThreadID {
+		return nil, fmt.Errorf("envelope sidecar does not match the spool message")
+	}
+	env.KeyGeneration = fresh.KeyGeneration
+	env.Signature = ""
+	return &env, nil
+}
+
 func envelopeForMessage(cfg Config, destAlias, messageID, threadID string, payload []byte) bridge.Envelope {
 	digest := sha256.Sum256(payload)
-	transferDigest := sha256.Sum256([]byte(cfg.SourceHost + "\x00" + messageID + "\x00" + destAlias))
 	return bridge.Envelope{
 		Version:         bridge.EnvelopeVersion,
-		TransferID:      "xfer-" + hex.EncodeToString(transferDigest[:16]),
+		TransferID:      transferIDFor(cfg.SourceHost, messageID, destAlias),
 		SourceHost:      cfg.SourceHost,
 		SourceHandle:    cfg.SourceHandle,
 		DestAlias:       destAlias,
@@ -603,11 +1085,21 @@ func envelopeForMessage(cfg Config, destAlias, messageID, threadID string, paylo
 	}
 }
 
+// transferIDFor is the stable transfer id of one message to one destination,
+// so a retried push is the same transfer.
+func transferIDFor(sourceHost, messageID, destAlias string) string {
+	digest := sha256.Sum256([]byte(sourceHost + "\x00" + messageID + "\x00" + destAlias))
+	return "xfer-" + hex.EncodeToString(digest[:16])
+}
+
 func (c *Courier) destAliasForSpool(messagePath string) (string, error) {
 	path := bridge.DestSidecarPath(messagePath)
 	info, err := os.Lstat(path)
 	if errors.Is(err, os.ErrNotExist) {
-		return c.cfg.DestAlias, nil
+		if c.cfg.DestAlias == "" {
+			return "", fmt.Errorf("no dest sidecar and no default destination alias")
+		}
+		return c.checkRoutedDest(c.cfg.DestAlias)
 	}
 	if err != nil {
 		return "", err
@@ -626,16 +1118,35 @@ func (c *Courier) destAliasForSpool(messagePath string) (string, error) {
 	if _, ok := c.allowedDest[alias]; !ok {
 		return "", fmt.Errorf("destination alias %q is not allowlisted", alias)
 	}
+	return c.checkRoutedDest(alias)
+}
+
+// checkRoutedDest enforces the per-peer allowlist: with routes, an alias is
+// sent only if its host's route lists it.
+func (c *Courier) checkRoutedDest(alias string) (string, error) {
+	if c.routes == nil {
+		return alias, nil
+	}
+	host, _, err := bridge.ParseAlias(alias)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := c.routes[host].allowedDest[alias]; !ok {
+		return "", fmt.Errorf("destination alias %q is not in the route for host %q", alias, host)
+	}
 	return alias, nil
 }
 
-func (c *Courier) postEnvelope(ctx context.Context, env bridge.Envelope) (wireReceipt, error) {
+func (c *Courier) postEnvelope(ctx context.Context, t peerTransport, env bridge.Envelope) (wireReceipt, error) {
+	if t.shared != nil {
+		return t.shared.post(env)
+	}
 	body, err := bridge.MarshalEnvelope(env)
 	if err != nil {
 		return wireReceipt{}, err
 	}
 	var response transportResponse
-	if err := c.requestJSON(ctx, http.MethodPost, transfersPath, nil, body, &response); err != nil {
+	if err := c.requestJSON(ctx, t.rendezvous, http.MethodPost, transfersPath, nil, body, &response); err != nil {
 		return wireReceipt{}, err
 	}
 	if err := validateWireReceipt(response.Receipt, ReceiptTransportAccepted, env); err != nil {
@@ -644,12 +1155,15 @@ func (c *Courier) postEnvelope(ctx context.Context, env bridge.Envelope) (wireRe
 	return response.Receipt, nil
 }
 
-func (c *Courier) pollEnvelopes(ctx context.Context) ([]bridge.Envelope, error) {
+func (c *Courier) pollEnvelopes(ctx context.Context, t peerTransport, alias string) ([]bridge.Envelope, error) {
+	if t.shared != nil {
+		return t.shared.poll(alias, c.cfg.BatchSize)
+	}
 	query := url.Values{}
-	query.Set("dest_alias", c.receiveAlias)
+	query.Set("dest_alias", alias)
 	query.Set("limit", fmt.Sprintf("%d", c.cfg.BatchSize))
 	var response pollResponse
-	if err := c.requestJSON(ctx, http.MethodGet, transfersPath, query, nil, &response); err != nil {
+	if err := c.requestJSON(ctx, t.rendezvous, http.MethodGet, transfersPath, query, nil, &response); err != nil {
 		return nil, err
 	}
 	if len(response.Envelopes) > c.cfg.BatchSize {
@@ -666,10 +1180,15 @@ func (c *Courier) pollEnvelopes(ctx context.Context) ([]bridge.Envelope, error)
 	return envelopes, nil
 }
 
-func (c *Courier) ackEnvelope(ctx context.Context, env bridge.Envelope) error {
+// ackEnvelope retires env on its transport with stage: a Maildir commit, or
+// a refusal for a quarantined transfer.
+func (c *Courier) ackEnvelope(ctx context.Context, t peerTransport, env bridge.Envelope, stage ReceiptStage) error {
+	if t.shared != nil {
+		return t.shared.ack(env, stage)
+	}
 	path := transfersPath + "/" + url.PathEscape(env.TransferID) + "/ack"
 	body, err := json.Marshal(ackRequest{Receipt: wireReceipt{
-		Stage:         ReceiptDestinationMaildirCommit,
+		Stage:         stage,
 		TransferID:    env.TransferID,
 		PayloadSHA256: env.PayloadSHA256,
 	}})
@@ -677,10 +1196,10 @@ func (c *Courier) ackEnvelope(ctx context.Context, env bridge.Envelope) error {
 		return err
 	}
 	var response transportResponse
-	if err := c.requestJSON(ctx, http.MethodPost, path, nil, body, &response); err != nil {
+	if err := c.requestJSON(ctx, t.rendezvous, http.MethodPost, path, nil, body, &response); err != nil {
 		return err
 	}
-	if err := validateWireReceipt(response.Receipt, ReceiptDestinationMaildirCommit, env); err != nil {
+	if err := validateWireReceipt(response.Receipt, stage, env); err != nil {
 		return err
 	}
 	return nil
@@ -699,8 +1218,8 @@ func validateWireReceipt(receipt wireReceipt, want ReceiptStage, env bridge.Enve
 	return nil
 }
 
-func (c *Courier) requestJSON(ctx context.Context, method, requestPath string, query url.Values, body []byte, out any) error {
-	endpoint, err := rendezvousEndpoint(c.cfg.RendezvousURL, requestPath, query)
+func (c *Courier) requestJSON(ctx context.Context, rendezvous, method, requestPath string, query url.Values, body []byte, out any) error {
+	endpoint, err := rendezvousEndpoint(rendezvous, requestPath, query)
 	if err != nil {
 		return err
 	}
@@ -768,6 +1287,9 @@ func rendezvousEndpoint(raw, requestPath string, query url.Values) (string, erro
 
 func (c *Courier) guardPollIdentity() error {
 	if len(c.allowedSource) == 0 {
+		if c.routes != nil {
+			return fmt.Errorf("poll requires at least one inbound route")
+		}
 		return fmt.Errorf("poll requires a non-empty --allow-source-host allowlist")
 	}
 	if c.localHost != c.hostID {
@@ -780,7 +1302,21 @@ func (c *Courier) guardPollIdentity() error {
 }
 
 func (c *Courier) existingReceipt(root *fsq.DeliveryRoot, env bridge.Envelope, stage ReceiptStage) (Receipt, error) {
-	path := filepath.Join(c.receiptRelDir, receiptFilename(env.TransferID, stage))
+	path, err := c.findReceipt(root, c.receiptPeer(env.SourceHost, env.DestAlias), receiptFilename(env.TransferID, stage))
+	if err != nil {
+		return Receipt{}, err
+	}
+	receipt, err := readBridgeReceipt(root, path)
+	if err != nil {
+		return Receipt{}, err
+	}
+	if receipt.Stage != stage || receipt.TransferID != env.TransferID || !strings.EqualFold(receipt.PayloadSHA256, env.PayloadSHA256) {
+		return Receipt{}, fmt.Errorf("receipt %s conflicts with transfer", root.DisplayPath(path))
+	}
+	return receipt, nil
+}
+
+func readBridgeReceipt(root *fsq.DeliveryRoot, path string) (Receipt, error) {
 	data, err := root.ReadRegularNoFollow(path)
 	if err != nil {
 		return Receipt{}, err
@@ -789,14 +1325,44 @@ func (c *Courier) existingReceipt(root *fsq.DeliveryRoot, env bridge.Envelope, s
 	if err := json.Unmarshal(data, &receipt); err != nil {
 		return Receipt{}, fmt.Errorf("parse receipt %s: %w", root.DisplayPath(path), err)
 	}
-	if receipt.Stage != stage || receipt.TransferID != env.TransferID || !strings.EqualFold(receipt.PayloadSHA256, env.PayloadSHA256) {
-		return Receipt{}, fmt.Errorf("receipt %s conflicts with transfer", root.DisplayPath(path))
-	}
 	return receipt, nil
 }
 
 func (c *Courier) writeReceipt(root *fsq.DeliveryRoot, receipt Receipt) error {
-	return writeBridgeReceipt(root, c.receiptRelDir, receipt)
+	return writeBridgeReceipt(root, c.receiptDir(c.receiptPeer(receipt.SourceHost, receipt.DestAlias)), receipt)
+}
+
+// receiptDir is where receipts for transfers with peer are kept: with
+// routes, each peer has its own bridge/receipts/<peer>/ directory.
+func (c *Courier) receiptDir(peer string) string {
+	if c.routes == nil || peer == "" {
+		return c.receiptRelDir
+	}
+	return filepath.Join(c.receiptRelDir, peer)
+}
+
+// receiptPeer is the other host of a transfer from sourceHost to destAlias.
+func (c *Courier) receiptPeer(sourceHost, destAlias string) string {
+	if sourceHost != c.hostID {
+		return sourceHost
+	}
+	host, _, _ := bridge.ParseAlias(destAlias)
+	return host
+}
+
+// findReceipt returns the path of the receipt filename for a transfer with
+// peer. A receipt written before routes were adopted is still found in the
+// flat directory.
+func (c *Courier) findReceipt(root *fsq.DeliveryRoot, peer, filename string) (string, error) {
+	path := filepath.Join(c.receiptDir(peer), filename)
+	_, err := root.Stat(path)
+	if errors.Is(err, os.ErrNotExist) && path != filepath.Join(c.receiptRelDir, filename) {
+		legacy := filepath.Join(c.receiptRelDir, filename)
+		if _, legacyErr := root.Stat(legacy); legacyErr == nil {
+			return legacy, nil
+		}
+	}
+	return path, err
 }
 
 func writeBridgeReceipt(root *fsq.DeliveryRoot, receiptRelDir string, receipt Receipt) error {
@@ -833,8 +1399,36 @@ func receiptFilename(transferID string, stage ReceiptStage) string {
 	return transferID + "__" + string(stage) + ".json"
 }
 
+// recordRefused writes the sender's destination_refused receipt for a spool
+// item the destination quarantined and archives the item under refused/.
+func (c *Courier) recordRefused(root *fsq.DeliveryRoot, item spoolItem) (Receipt, error) {
+	receipt := Receipt{
+		Stage:           ReceiptDestinationRefused,
+		TransferID:      item.env.TransferID,
+		PayloadSHA256:   item.env.PayloadSHA256,
+		SourceMessageID: item.env.SourceMessageID,
+		SourceHost:      item.env.SourceHost,
+		SourceHandle:    item.env.SourceHandle,
+		DestAlias:       item.env.DestAlias,
+		EmittedAt:       time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	if err := c.writeReceipt(root, receipt); err != nil {
+		return Receipt{}, fmt.Errorf("write refused receipt for %s: %w", item.name, err)
+	}
+	if err := c.archiveSpoolItem("refused", item.name, item.data); err != nil {
+		return Receipt{}, fmt.Errorf("archive refused spool item %s: %w", item.name, err)
+	}
+	return receipt, nil
+}
+
 func (c *Courier) moveToSent(name string, data []byte) error {
-	sentDir := filepath.Join(filepath.Dir(c.cfg.SpoolDir), "sent")
+	return c.archiveSpoolItem("sent", name, data)
+}
+
+// archiveSpoolItem moves a settled spool item and its sidecars out of the
+// spool into the sibling archive directory leaf.
+func (c *Courier) archiveSpoolItem(leaf, name string, data []byte) error {
+	sentDir := filepath.Join(filepath.Dir(c.cfg.SpoolDir), leaf)
 	if err := os.MkdirAll(sentDir, 0o700); err != nil {
 		return err
 	}
@@ -849,11 +1443,11 @@ func (c *Courier) moveToSent(name string, data []byte) error {
 			return readErr
 		}
 		if string(existing) != string(data) {
-			return fmt.Errorf("sent archive %s conflicts with source bytes", destination)
+			return fmt.Errorf("%s archive %s conflicts with source bytes", leaf, destination)
 		}
 	}
 	if err := syncDirectory(sentDir); err != nil {
-		return fmt.Errorf("sync sent archive: %w", err)
+		return fmt.Errorf("sync %s archive: %w", leaf, err)
 	}
 	if err := os.Remove(source); err != nil && !os.IsNotExist(err) {
 		return err
@@ -861,6 +1455,9 @@ func (c *Courier) moveToSent(name string, data []byte) error {
 	if err := os.Remove(bridge.DestSidecarPath(source)); err != nil && !os.IsNotExist(err) {
 		return err
 	}
+	if err := os.Remove(envelopeSidecarPath(source)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
 	if err := syncDirectory(c.cfg.SpoolDir); err != nil {
 		return fmt.Errorf("sync bridge spool: %w", err)
 	}
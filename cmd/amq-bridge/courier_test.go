@@ -17,6 +17,7 @@ import (
 	"time"
 
 	"github.com/avivsinai/agent-message-queue/internal/bridge"
+	"github.com/avivsinai/agent-message-queue/internal/config"
 	"github.com/avivsinai/agent-message-queue/internal/format"
 	"github.com/avivsinai/agent-message-queue/internal/fsq"
 )
@@ -27,6 +28,7 @@ type fakeRendezvous struct {
 	accepted       map[string]bridge.Envelope
 	postCount      int
 	ackCount       int
+	ackStages      map[string]ReceiptStage
 	dropFirstAck   bool
 	wrongPostStage bool
 	seenRaw        [][]byte
@@ -34,7 +36,7 @@ type fakeRendezvous struct {
 
 func newFakeRendezvous(t *testing.T) (*fakeRendezvous, *httptest.Server) {
 	t.Helper()
-	fake := &fakeRendezvous{accepted: make(map[string]bridge.Envelope)}
+	fake := &fakeRendezvous{accepted: make(map[string]bridge.Envelope), ackStages: make(map[string]ReceiptStage)}
 	server := httptest.NewServer(http.HandlerFunc(fake.handle))
 	t.Cleanup(server.Close)
 	return fake, server
@@ -133,7 +135,7 @@ func (f *fakeRendezvous) handleAck(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "ack conflict", http.StatusConflict)
 		return
 	}
-	if request.Receipt.Stage != ReceiptDestinationMaildirCommit {
+	if request.Receipt.Stage != ReceiptDestinationMaildirCommit && request.Receipt.Stage != ReceiptDestinationRefused {
 		http.Error(w, "wrong ack stage", http.StatusBadRequest)
 		return
 	}
@@ -142,6 +144,7 @@ func (f *fakeRendezvous) handleAck(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "simulated lost ack", http.StatusServiceUnavailable)
 		return
 	}
+	f.ackStages[env.TransferID] = request.Receipt.Stage
 	for i, queued := range f.queue {
 		if queued.TransferID == env.TransferID {
 			f.queue = append(f.queue[:i], f.queue[i+1:]...)
@@ -240,6 +243,60 @@ func TestPushPollAndTypedReceipts(t *testing.T) {
 	}
 }
 
+// TestPollQuarantinesACLRefusalAndContinues proves a transfer the local ACL
+// refuses is kept under bridge/quarantine/ and ACKed as destination_refused
+// instead of blocking the transport, and that nothing reaches the inbox.
+func TestPollQuarantinesACLRefusalAndContinues(t *testing.T) {
+	fake, server := newFakeRendezvous(t)
+	senderRoot := newBridgeRoot(t, "codex")
+	receiverRoot := newBridgeRoot(t, "claude")
+	cfg := config.Config{ACL: &config.ACLConfig{Agents: map[string]config.ACLPolicy{
+		"claude": {Deny: []config.ACLRule{{From: []string{"codex"}}}},
+	}}}
+	if err := config.WriteConfig(filepath.Join(receiverRoot, "meta", "config.json"), cfg, true); err != nil {
+		t.Fatal(err)
+	}
+	spool := filepath.Join(senderRoot, "bridge", "outbox", "codex", "new")
+	if err := os.MkdirAll(spool, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(spool, "denied.md"), testMessage(t, "msg-denied", "thread-denied", "codex", "let me in"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sender := testCourier(t, Config{
+		Root: senderRoot, RendezvousURL: server.URL, SourceHost: "grok-host", SourceHandle: "codex",
+		DestAlias: "mac/claude", AllowedDestAliases: []string{"mac/claude"},
+	})
+	push, err := sender.PushOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver := testCourier(t, Config{
+		Root: receiverRoot, RendezvousURL: server.URL, DestAlias: "mac/claude",
+		AllowedDestAliases: []string{"mac/claude"}, AllowedSourceHosts: []string{"grok-host"},
+	})
+	poll, err := receiver.PollOnce(context.Background())
+	if err != nil {
+		t.Fatalf("PollOnce with a refused transfer: %v", err)
+	}
+	if len(poll.Receipts) != 0 || len(poll.Quarantined) != 1 || !strings.Contains(poll.Quarantined[0].Reason, "ACL") {
+		t.Fatalf("poll = %#v, want one quarantined transfer and no receipts", poll)
+	}
+	transferID := push.Receipts[0].TransferID
+	if _, err := os.Stat(filepath.Join(receiverRoot, quarantineRelDir, transferID+".json")); err != nil {
+		t.Fatalf("quarantine record missing: %v", err)
+	}
+	if entries, _ := os.ReadDir(fsq.AgentInboxNew(receiverRoot, "claude")); len(entries) != 0 {
+		t.Fatalf("refused transfer committed: %d inbox entries", len(entries))
+	}
+	fake.mu.Lock()
+	queued, acks, stage := len(fake.queue), fake.ackCount, fake.ackStages[transferID]
+	fake.mu.Unlock()
+	if queued != 0 || acks != 1 || stage != ReceiptDestinationRefused {
+		t.Fatalf("rendezvous queue=%d acks=%d stage=%q, want the refused transfer acknowledged as refused", queued, acks, stage)
+	}
+}
+
 func TestPushUsesDestSidecarNotCourierDestAlias(t *testing.T) {
 	fake, server := newFakeRendezvous(t)
 	root := newBridgeRoot(t, "codex")
@@ -631,6 +688,11 @@ func testCourier(t *testing.T, cfg Config) *Courier {
 	for _, host := range cfg.AllowedSourceHosts {
 		ensureTrusted(t, cfg.Root, strings.TrimSpace(host))
 	}
+	for _, route := range cfg.Routes {
+		if route.Inbound {
+			ensureTrusted(t, cfg.Root, route.Host)
+		}
+	}
 	courier, err := NewCourier(cfg)
 	if err != nil {
 		t.Fatal(err)
@@ -659,7 +721,7 @@ func testMessage(t *testing.T, id, thread, from, body string) []byte {
 
 func testEnvelope(t *testing.T, transferID string) bridge.Envelope {
 	t.Helper()
-	payload := []byte("poll payload")
+	payload := testMessage(t, transferID+"-message", transferID+"-thread", "codex", "poll payload")
 	digest := sha256.Sum256(payload)
 	env := bridge.Envelope{
 		Version:         bridge.EnvelopeVersion,
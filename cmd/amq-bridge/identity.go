@@ -4,20 +4,24 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/avivsinai/agent-message-queue/internal/bridge"
 )
 
 func runIdentity(args []string) error {
 	if len(args) == 0 {
-		return fmt.Errorf("identity subcommand is required (init or public)")
+		return fmt.Errorf("identity subcommand is required (init, public, or rotate)")
 	}
 	switch args[0] {
 	case "init":
 		return runIdentityInit(args[1:])
 	case "public":
 		return runIdentityPublic(args[1:])
+	case "rotate":
+		return runIdentityRotate(args[1:])
 	default:
 		return fmt.Errorf("unknown identity subcommand %q", args[0])
 	}
@@ -48,8 +52,7 @@ func runIdentityInit(args []string) error {
 	if err := bridge.WriteIdentity(*root, key); err != nil {
 		return err
 	}
-	fmt.Printf("host=%s generation=%s public=%x\n", hostID, key.Generation, key.Public())
-	return nil
+	return printIdentityKey(hostID, key)
 }
 
 func runIdentityPublic(args []string) error {
@@ -69,10 +72,92 @@ func runIdentityPublic(args []string) error {
 	if err != nil {
 		return err
 	}
-	key, err := bridge.LoadIdentity(*root)
+	key, retired, err := bridge.LoadIdentityKeys(*root)
+	if err != nil {
+		return err
+	}
+	if err := printIdentityKey(hostID, key); err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, old := range retired {
+		if old.ValidAt(now) {
+			fmt.Printf("host=%s generation=%s public=%x not_after=%s%s\n", hostID, old.Generation, old.Public, old.NotAfter.UTC().Format(time.RFC3339), boxField(old.Box))
+		}
+	}
+	return nil
+}
+
+// runIdentityRotate mints a new signing generation. The previous generation
+// is retired rather than deleted so peers can keep verifying envelopes it
+// already signed until --overlap elapses.
+func runIdentityRotate(args []string) error {
+	fs := flag.NewFlagSet("amq-bridge identity rotate", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	root := fs.String("root", os.Getenv("AM_ROOT"), "local AMQ root")
+	generation := fs.String("generation", "", "new host key generation label (default: current generation + 1)")
+	overlap := fs.Duration("overlap", 24*time.Hour, "how long the previous generation stays trusted")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if strings.TrimSpace(*root) == "" {
+		return fmt.Errorf("bridge root is required")
+	}
+	hostID, err := bridge.LoadHostID(*root)
+	if err != nil {
+		return err
+	}
+	next := strings.TrimSpace(*generation)
+	if next == "" {
+		current, err := bridge.LoadIdentity(*root)
+		if err != nil {
+			return err
+		}
+		n, err := strconv.Atoi(current.Generation)
+		if err != nil {
+			return fmt.Errorf("--generation is required when the current generation %q is not numeric", current.Generation)
+		}
+		next = strconv.Itoa(n + 1)
+	}
+	now := time.Now()
+	key, retired, err := bridge.RotateIdentity(*root, next, *overlap, now)
 	if err != nil {
 		return err
 	}
-	fmt.Printf("host=%s generation=%s public=%x\n", hostID, key.Generation, key.Public())
+	previous := retired[0]
+	notAfter := previous.NotAfter.UTC().Format(time.RFC3339)
+	for _, entry := range []bridge.TrustAuditEntry{
+		{Action: "rotate", Host: hostID, Generation: key.Generation, Public: fmt.Sprintf("%x", key.Public())},
+		{Action: "retire", Host: hostID, Generation: previous.Generation, Public: fmt.Sprintf("%x", previous.Public), NotAfter: notAfter},
+	} {
+		if err := bridge.AppendTrustAudit(*root, entry); err != nil {
+			return err
+		}
+	}
+	if err := printIdentityKey(hostID, key); err != nil {
+		return err
+	}
+	fmt.Printf("host=%s generation=%s public=%x not_after=%s%s\n", hostID, previous.Generation, previous.Public, notAfter, boxField(previous.Box))
 	return nil
 }
+
+// printIdentityKey prints the public record a peer registers with trust add,
+// including the box key that lets it send encrypted envelopes.
+func printIdentityKey(hostID string, key bridge.HostKey) error {
+	box, err := key.BoxKey()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("host=%s generation=%s public=%x%s\n", hostID, key.Generation, key.Public(), boxField(box.PublicKey().Bytes()))
+	return nil
+}
+
+func boxField(box []byte) string {
+	if len(box) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" box=%x", box)
+}
@@ -11,6 +11,8 @@ import (
 	"strings"
 	"syscall"
 	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/bridge"
 )
 
 var version = "dev"
@@ -67,6 +69,48 @@ func main() {
 		}
 		return
 	}
+	if len(args) > 0 && args[0] == "trust" {
+		if err := runTrust(args[1:]); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return
+			}
+			fmt.Fprintln(os.Stderr, "amq-bridge trust:", err)
+			if isUsageError(err) {
+				os.Exit(2)
+			}
+			os.Exit(1)
+		}
+		return
+	}
+	if len(args) > 0 && args[0] == "rendezvous" {
+		if err := runRendezvous(args[1:]); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return
+			}
+			fmt.Fprintln(os.Stderr, "amq-bridge rendezvous:", err)
+			if isUsageError(err) {
+				os.Exit(2)
+			}
+			os.Exit(1)
+		}
+		return
+	}
+	if len(args) > 0 && args[0] == "status" {
+		if err := runStatus(args[1:]); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return
+			}
+			if errors.Is(err, errNeedsAttention) {
+				os.Exit(3)
+			}
+			fmt.Fprintln(os.Stderr, "amq-bridge status:", err)
+			if isUsageError(err) {
+				os.Exit(2)
+			}
+			os.Exit(1)
+		}
+		return
+	}
 	opts, err := parseFlags(args)
 	if err != nil {
 		if errors.Is(err, flag.ErrHelp) {
@@ -110,7 +154,9 @@ func parseFlags(args []string) (cliOptions, error) {
 	fs := flag.NewFlagSet("amq-bridge", flag.ContinueOnError)
 	fs.SetOutput(os.Stderr)
 	root := fs.String("root", os.Getenv("AM_ROOT"), "local AMQ root")
+	transport := fs.String("transport", "", "envelope transport: https (default) or dir")
 	rendezvous := fs.String("rendezvous", "", "HTTPS rendezvous base URL")
+	sharedDirFlag := fs.String("shared-dir", "", "shared directory for --transport dir, outside the AMQ root")
 	sourceHost := fs.String("source-host", "", "authenticated local host alias used on outbound envelopes")
 	sourceHandle := fs.String("source-handle", "", "local allowlisted handle whose bridge spool is drained")
 	destAlias := fs.String("dest-alias", "", "receiver-owned destination alias host/agent")
@@ -118,6 +164,7 @@ func parseFlags(args []string) (cliOptions, error) {
 	localHost := fs.String("local-host", "", "local host identity; defaults to receive-alias host")
 	allowDest := fs.String("allow-dest", "", "comma-separated receiver-owned destination aliases")
 	allowSource := fs.String("allow-source-host", "", "inbound source host allowlist (required for poll)")
+	routesPath := fs.String("routes", "", "private mode-0600 routing table JSON mapping peer hosts to transports")
 	spool := fs.String("spool", "", "bridge spool new directory (default: <root>/bridge/outbox/<source-handle>/new)")
 	receipts := fs.String("receipt-dir", "", "bridge receipt directory below the AMQ root")
 	keyGeneration := fs.String("key-generation", defaultKeyGeneration, "envelope key generation label")
@@ -135,10 +182,20 @@ func parseFlags(args []string) (cliOptions, error) {
 	if parsedMode != ModeBoth && parsedMode != ModePush && parsedMode != ModePoll {
 		return cliOptions{}, fmt.Errorf("invalid --mode %q; want both, push, or poll", *mode)
 	}
+	var routes []bridge.Route
+	if strings.TrimSpace(*routesPath) != "" {
+		table, err := bridge.LoadRoutingTable(*routesPath)
+		if err != nil {
+			return cliOptions{}, err
+		}
+		routes = table.Routes
+	}
 	return cliOptions{
 		cfg: Config{
 			Root:               *root,
+			Transport:          Transport(strings.TrimSpace(*transport)),
 			RendezvousURL:      *rendezvous,
+			SharedDir:          strings.TrimSpace(*sharedDirFlag),
 			SourceHost:         *sourceHost,
 			SourceHandle:       *sourceHandle,
 			DestAlias:          strings.TrimSpace(*destAlias),
@@ -150,6 +207,7 @@ func parseFlags(args []string) (cliOptions, error) {
 			ReceiptDir:         *receipts,
 			KeyGeneration:      *keyGeneration,
 			BatchSize:          *batch,
+			Routes:             routes,
 		},
 		mode:     parsedMode,
 		once:     *once,
@@ -208,5 +266,10 @@ func writeRunResult(result RunResult) error {
 			return err
 		}
 	}
+	for _, receipt := range result.Forward.Receipts {
+		if err := encoder.Encode(receipt); err != nil {
+			return err
+		}
+	}
 	return nil
 }
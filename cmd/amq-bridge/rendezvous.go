@@ -0,0 +1,473 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/bridge"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+	"github.com/avivsinai/agent-message-queue/internal/lock"
+)
+
+const (
+	defaultRendezvousListen = "127.0.0.1:8787"
+	defaultRendezvousLease  = time.Minute
+)
+
+// Rendezvous is the reference /v1/transfers blob store. It reads only the
+// routing fields of an envelope (transfer_id, source_host, dest_alias,
+// payload_sha256) and stores and returns the envelope bytes unchanged; it
+// never decodes the AMQ payload or checks signatures, which stay the
+// receiving courier's job.
+//
+// Layout under dir:
+//
+//	queue/<transfer_id>.envelope  exact envelope bytes as posted
+//	queue/<transfer_id>.json      routing metadata and lease
+//	acked/<transfer_id>.json      tombstone that keeps replays idempotent
+//
+// Every request holds an exclusive lock on dir/.lock, so several processes
+// may share one directory.
+type Rendezvous struct {
+	dir      string
+	lease    time.Duration
+	maxBatch int
+	now      func() time.Time
+}
+
+type rendezvousRecord struct {
+	TransferID    string `json:"transfer_id"`
+	SourceHost    string `json:"source_host"`
+	DestAlias     string `json:"dest_alias"`
+	PayloadSHA256 string `json:"payload_sha256"`
+	AcceptedAt    string `json:"accepted_at"`
+	LeasedUntil   string `json:"leased_until,omitempty"`
+	Deliveries    int    `json:"deliveries,omitempty"`
+	AckedAt       string `json:"acked_at,omitempty"`
+	// AckStage is destination_refused for a quarantined transfer; empty
+	// means destination_maildir_committed.
+	AckStage ReceiptStage `json:"ack_stage,omitempty"`
+}
+
+// rendezvousRouting is everything the rendezvous reads from an envelope.
+type rendezvousRouting struct {
+	TransferID    string `json:"transfer_id"`
+	SourceHost    string `json:"source_host"`
+	DestAlias     string `json:"dest_alias"`
+	PayloadSHA256 string `json:"payload_sha256"`
+}
+
+type rendezvousError struct {
+	status int
+	msg    string
+}
+
+func (e *rendezvousError) Error() string { return e.msg }
+
+func rendezvousErrorf(status int, format string, args ...any) error {
+	return &rendezvousError{status: status, msg: fmt.Sprintf(format, args...)}
+}
+
+// NewRendezvous opens (creating if needed) a file-backed rendezvous store.
+func NewRendezvous(dir string, lease time.Duration, maxBatch int) (*Rendezvous, error) {
+	if strings.TrimSpace(dir) == "" {
+		return nil, fmt.Errorf("rendezvous directory is required")
+	}
+	if lease <= 0 {
+		return nil, fmt.Errorf("rendezvous lease must be positive")
+	}
+	if maxBatch < 1 {
+		return nil, fmt.Errorf("rendezvous max batch must be positive")
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("resolve rendezvous directory: %w", err)
+	}
+	for _, sub := range []string{"queue", "acked"} {
+		if err := os.MkdirAll(filepath.Join(abs, sub), 0o700); err != nil {
+			return nil, fmt.Errorf("create rendezvous directory: %w", err)
+		}
+	}
+	return &Rendezvous{dir: abs, lease: lease, maxBatch: maxBatch, now: time.Now}, nil
+}
+
+func (r *Rendezvous) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var (
+		value any
+		err   error
+	)
+	switch {
+	case req.URL.Path == transfersPath && req.Method == http.MethodPost:
+		value, err = r.handlePush(w, req)
+	case req.URL.Path == transfersPath && req.Method == http.MethodGet:
+		value, err = r.handlePoll(req)
+	case strings.HasPrefix(req.URL.Path, transfersPath+"/") && strings.HasSuffix(req.URL.Path, "/ack"):
+		if req.Method != http.MethodPost {
+			err = rendezvousErrorf(http.StatusMethodNotAllowed, "ack requires POST")
+			break
+		}
+		id := strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, transfersPath+"/"), "/ack")
+		value, err = r.handleAck(w, req, id)
+	case req.URL.Path == transfersPath:
+		err = rendezvousErrorf(http.StatusMethodNotAllowed, "method %s not allowed", req.Method)
+	default:
+		err = rendezvousErrorf(http.StatusNotFound, "not found")
+	}
+	if err != nil {
+		var typed *rendezvousError
+		if !errors.As(err, &typed) {
+			typed = &rendezvousError{status: http.StatusInternalServerError, msg: err.Error()}
+		}
+		http.Error(w, typed.msg, typed.status)
+		return
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(append(data, '\n'))
+}
+
+// handlePush stores one envelope. Re-posting the same transfer with the same
+// digest is accepted again without a second queue entry, even after it was
+// acknowledged; the same transfer with another digest is a conflict.
+func (r *Rendezvous) handlePush(w http.ResponseWriter, req *http.Request) (any, error) {
+	raw, err := readRendezvousBody(w, req)
+	if err != nil {
+		return nil, err
+	}
+	var routing rendezvousRouting
+	if err := json.Unmarshal(raw, &routing); err != nil {
+		return nil, rendezvousErrorf(http.StatusBadRequest, "envelope is not a JSON object: %v", err)
+	}
+	if err := validateRendezvousRouting(routing); err != nil {
+		return nil, rendezvousErrorf(http.StatusBadRequest, "%v", err)
+	}
+	receipt := wireReceipt{
+		Stage:         ReceiptTransportAccepted,
+		TransferID:    routing.TransferID,
+		PayloadSHA256: routing.PayloadSHA256,
+	}
+	err = r.withLock(func() error {
+		for _, sub := range []string{"acked", "queue"} {
+			prior, err := r.readRecord(sub, routing.TransferID)
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if !strings.EqualFold(prior.PayloadSHA256, routing.PayloadSHA256) {
+				return rendezvousErrorf(http.StatusConflict, "transfer %s already holds a different payload digest", routing.TransferID)
+			}
+			return nil
+		}
+		queueDir := filepath.Join(r.dir, "queue")
+		if _, err := fsq.WriteFileAtomic(queueDir, routing.TransferID+".envelope", raw, 0o600); err != nil {
+			return err
+		}
+		return r.writeRecord("queue", rendezvousRecord{
+			TransferID:    routing.TransferID,
+			SourceHost:    routing.SourceHost,
+			DestAlias:     routing.DestAlias,
+			PayloadSHA256: routing.PayloadSHA256,
+			AcceptedAt:    r.now().UTC().Format(time.RFC3339Nano),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transportResponse{Receipt: receipt}, nil
+}
+
+// handlePoll leases up to limit (at most maxBatch) unleased envelopes for
+// dest_alias, oldest first. An envelope that is not acknowledged before its
+// lease ends is returned again by a later poll.
+func (r *Rendezvous) handlePoll(req *http.Request) (any, error) {
+	query := req.URL.Query()
+	destAlias := query.Get("dest_alias")
+	if _, _, err := bridge.ParseAlias(destAlias); err != nil {
+		return nil, rendezvousErrorf(http.StatusBadRequest, "%v", err)
+	}
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit < 1 {
+		return nil, rendezvousErrorf(http.StatusBadRequest, "limit must be a positive integer")
+	}
+	if limit > r.maxBatch {
+		limit = r.maxBatch
+	}
+	response := pollResponse{Envelopes: []json.RawMessage{}}
+	err = r.withLock(func() error {
+		records, err := r.queuedRecords()
+		if err != nil {
+			return err
+		}
+		now := r.now().UTC()
+		for _, record := range records {
+			if len(response.Envelopes) == limit {
+				break
+			}
+			if record.DestAlias != destAlias {
+				continue
+			}
+			if until, err := time.Parse(time.RFC3339Nano, record.LeasedUntil); err == nil && now.Before(until) {
+				continue
+			}
+			raw, err := fsq.ReadRegularNoFollow(filepath.Join(r.dir, "queue", record.TransferID+".envelope"))
+			if err != nil {
+				return err
+			}
+			record.LeasedUntil = now.Add(r.lease).Format(time.RFC3339Nano)
+			record.Deliveries++
+			if err := r.writeRecord("queue", record); err != nil {
+				return err
+			}
+			response.Envelopes = append(response.Envelopes, json.RawMessage(raw))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// handleAck retires a transfer once the receiver reports its Maildir commit.
+// A repeated ACK for a retired transfer succeeds, so a lost ACK response can
+// be retried.
+func (r *Rendezvous) handleAck(w http.ResponseWriter, req *http.Request, transferID string) (any, error) {
+	if err := validateRendezvousTransferID(transferID); err != nil {
+		return nil, rendezvousErrorf(http.StatusBadRequest, "%v", err)
+	}
+	raw, err := readRendezvousBody(w, req)
+	if err != nil {
+		return nil, err
+	}
+	var request ackRequest
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		return nil, rendezvousErrorf(http.StatusBadRequest, "ack body: %v", err)
+	}
+	if request.Receipt.Stage != ReceiptDestinationMaildirCommit && request.Receipt.Stage != ReceiptDestinationRefused {
+		return nil, rendezvousErrorf(http.StatusBadRequest, "ack stage must be %s or %s", ReceiptDestinationMaildirCommit, ReceiptDestinationRefused)
+	}
+	ackStage := request.Receipt.Stage
+	if ackStage == ReceiptDestinationMaildirCommit {
+		ackStage = ""
+	}
+	if request.Receipt.TransferID != transferID {
+		return nil, rendezvousErrorf(http.StatusBadRequest, "ack transfer_id does not match the URL")
+	}
+	err = r.withLock(func() error {
+		if prior, err := r.readRecord("acked", transferID); err == nil {
+			if !strings.EqualFold(prior.PayloadSHA256, request.Receipt.PayloadSHA256) {
+				return rendezvousErrorf(http.StatusConflict, "ack digest does not match transfer %s", transferID)
+			}
+			if prior.AckStage != ackStage {
+				return rendezvousErrorf(http.StatusConflict, "transfer %s was already acknowledged with another stage", transferID)
+			}
+			return nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		record, err := r.readRecord("queue", transferID)
+		if errors.Is(err, os.ErrNotExist) {
+			return rendezvousErrorf(http.StatusNotFound, "unknown transfer %s", transferID)
+		}
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(record.PayloadSHA256, request.Receipt.PayloadSHA256) {
+			return rendezvousErrorf(http.StatusConflict, "ack digest does not match transfer %s", transferID)
+		}
+		record.LeasedUntil = ""
+		record.AckedAt = r.now().UTC().Format(time.RFC3339Nano)
+		record.AckStage = ackStage
+		if err := r.writeRecord("acked", record); err != nil {
+			return err
+		}
+		queueDir := filepath.Join(r.dir, "queue")
+		for _, name := range []string{transferID + ".json", transferID + ".envelope"} {
+			if err := os.Remove(filepath.Join(queueDir, name)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		return fsq.SyncDir(queueDir)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transportResponse(request), nil
+}
+
+func (r *Rendezvous) withLock(fn func() error) error {
+	return lock.WithExclusiveFileLock(filepath.Join(r.dir, ".lock"), fn)
+}
+
+func (r *Rendezvous) readRecord(sub, transferID string) (rendezvousRecord, error) {
+	data, err := fsq.ReadRegularNoFollow(filepath.Join(r.dir, sub, transferID+".json"))
+	if err != nil {
+		return rendezvousRecord{}, err
+	}
+	var record rendezvousRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return rendezvousRecord{}, fmt.Errorf("parse rendezvous record %s/%s: %w", sub, transferID, err)
+	}
+	return record, nil
+}
+
+func (r *Rendezvous) writeRecord(sub string, record rendezvousRecord) error {
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fsq.WriteFileAtomic(filepath.Join(r.dir, sub), record.TransferID+".json", append(data, '\n'), 0o600)
+	return err
+}
+
+// queuedRecords returns unacknowledged transfers in acceptance order.
+func (r *Rendezvous) queuedRecords() ([]rendezvousRecord, error) {
+	entries, err := os.ReadDir(filepath.Join(r.dir, "queue"))
+	if err != nil {
+		return nil, err
+	}
+	var records []rendezvousRecord
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		record, err := r.readRecord("queue", strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		ti, _ := time.Parse(time.RFC3339Nano, records[i].AcceptedAt)
+		tj, _ := time.Parse(time.RFC3339Nano, records[j].AcceptedAt)
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return records[i].TransferID < records[j].TransferID
+	})
+	return records, nil
+}
+
+func readRendezvousBody(w http.ResponseWriter, req *http.Request) ([]byte, error) {
+	defer func() { _ = req.Body.Close() }()
+	body := http.MaxBytesReader(w, req.Body, maxHTTPBodySize)
+	data, err := io.ReadAll(body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, rendezvousErrorf(http.StatusRequestEntityTooLarge, "request body exceeds %d bytes", maxHTTPBodySize)
+		}
+		return nil, rendezvousErrorf(http.StatusBadRequest, "read request body: %v", err)
+	}
+	return data, nil
+}
+
+func validateRendezvousRouting(routing rendezvousRouting) error {
+	if err := validateRendezvousTransferID(routing.TransferID); err != nil {
+		return err
+	}
+	if err := fsq.ValidateHandle(routing.SourceHost); err != nil {
+		return fmt.Errorf("envelope source_host: %w", err)
+	}
+	if _, _, err := bridge.ParseAlias(routing.DestAlias); err != nil {
+		return err
+	}
+	if digest, err := hex.DecodeString(routing.PayloadSHA256); err != nil || len(digest) != 32 {
+		return fmt.Errorf("envelope payload_sha256 must be 64 hex characters")
+	}
+	return nil
+}
+
+// validateRendezvousTransferID keeps transfer ids safe as file names; it is
+// the same shape bridge.ValidateEnvelope accepts.
+func validateRendezvousTransferID(id string) error {
+	if strings.ContainsAny(id, "/\\.") || strings.HasPrefix(id, "-") {
+		return fmt.Errorf("envelope transfer_id is invalid")
+	}
+	if err := fsq.ValidateHandle(id); err != nil {
+		return fmt.Errorf("envelope transfer_id: %w", err)
+	}
+	return nil
+}
+
+// validateRendezvousListen refuses any listen address that is not loopback:
+// the reference rendezvous is for one-machine and CI cycles, not a relay.
+func validateRendezvousListen(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("listen address %q: %w", addr, err)
+	}
+	if !isLoopbackHost(host) {
+		return fmt.Errorf("listen address %q must be loopback (127.0.0.1, ::1, or localhost)", addr)
+	}
+	return nil
+}
+
+func runRendezvous(args []string) error {
+	fs := flag.NewFlagSet("amq-bridge rendezvous", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	dir := fs.String("dir", "", "directory that holds queued envelopes, leases, and ACK tombstones")
+	listen := fs.String("listen", defaultRendezvousListen, "loopback address to listen on (port 0 picks a free port)")
+	lease := fs.Duration("lease", defaultRendezvousLease, "how long a polled envelope is hidden before it is offered again")
+	maxBatch := fs.Int("max-batch", defaultBridgeBatchSize, "maximum envelopes returned by one poll")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if strings.TrimSpace(*dir) == "" {
+		return fmt.Errorf("rendezvous directory is required")
+	}
+	if err := validateRendezvousListen(*listen); err != nil {
+		return err
+	}
+	store, err := NewRendezvous(*dir, *lease, *maxBatch)
+	if err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Handler: store, ReadHeaderTimeout: 10 * time.Second}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+	fmt.Printf("rendezvous=http://%s dir=%s\n", listener.Addr(), store.dir)
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
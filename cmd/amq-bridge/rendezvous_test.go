@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/bridge"
+)
+
+func newTestRendezvous(t *testing.T, dir string, maxBatch int) (*Rendezvous, *time.Time) {
+	t.Helper()
+	store, err := NewRendezvous(dir, time.Minute, maxBatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 8, 20, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	return store, &now
+}
+
+func rendezvousRequest(t *testing.T, handler http.Handler, method, target string, body []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(string(body)))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func pollRendezvousIDs(t *testing.T, handler http.Handler, limit string) []string {
+	t.Helper()
+	recorder := rendezvousRequest(t, handler, http.MethodGet, transfersPath+"?dest_alias=mac/claude&limit="+limit, nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("poll status = %d: %s", recorder.Code, recorder.Body)
+	}
+	var response pollResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, raw := range response.Envelopes {
+		env, err := bridge.UnmarshalEnvelope(raw)
+		if err != nil {
+			t.Fatalf("polled envelope changed in the store: %v", err)
+		}
+		ids = append(ids, env.TransferID)
+	}
+	return ids
+}
+
+func TestRendezvousCarriesRealCourierCycle(t *testing.T) {
+	store, _ := newTestRendezvous(t, t.TempDir(), defaultBridgeBatchSize)
+	server := httptest.NewServer(store)
+	t.Cleanup(server.Close)
+	senderRoot := newBridgeRoot(t, "codex")
+	receiverRoot := newBridgeRoot(t, "claude")
+
+	spool := filepath.Join(senderRoot, "bridge", "outbox", "codex", "new")
+	if err := os.MkdirAll(spool, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	message := testMessage(t, "msg-rv", "thread-rv", "codex", "through the rendezvous")
+	if err := os.WriteFile(filepath.Join(spool, "rv.md"), message, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sender := testCourier(t, Config{
+		Root: senderRoot, RendezvousURL: server.URL, SourceHost: "grok-host", SourceHandle: "codex",
+		DestAlias: "mac/claude", AllowedDestAliases: []string{"mac/claude"},
+	})
+	push, err := sender.PushOnce(context.Background())
+	if err != nil || len(push.Receipts) != 1 {
+		t.Fatalf("PushOnce = %#v, %v", push, err)
+	}
+
+	receiver := testCourier(t, Config{
+		Root: receiverRoot, RendezvousURL: server.URL, DestAlias: "mac/claude",
+		AllowedDestAliases: []string{"mac/claude"}, AllowedSourceHosts: []string{"grok-host"},
+	})
+	poll, err := receiver.PollOnce(context.Background())
+	if err != nil || len(poll.Receipts) != 1 || poll.Receipts[0].Replayed {
+		t.Fatalf("PollOnce = %#v, %v", poll, err)
+	}
+	committed, err := os.ReadFile(filepath.Join(receiverRoot, "agents", "claude", "inbox", "new",
+		bridge.TransferFilename("grok-host", push.Receipts[0].TransferID)))
+	if err != nil || string(committed) != string(message) {
+		t.Fatalf("committed message = %q (%v), want the pushed bytes", committed, err)
+	}
+	if again, err := receiver.PollOnce(context.Background()); err != nil || len(again.Receipts) != 0 {
+		t.Fatalf("second PollOnce = %#v, %v; want nothing after ACK", again, err)
+	}
+}
+
+func TestRendezvousLeasesReplaysAndConflicts(t *testing.T) {
+	dir := t.TempDir()
+	store, now := newTestRendezvous(t, dir, 2)
+	post := func(env bridge.Envelope) *httptest.ResponseRecorder {
+		t.Helper()
+		raw, err := bridge.MarshalEnvelope(env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rendezvousRequest(t, store, http.MethodPost, transfersPath, raw)
+	}
+	ack := func(id, digest string) int {
+		t.Helper()
+		body, _ := json.Marshal(ackRequest{Receipt: wireReceipt{Stage: ReceiptDestinationMaildirCommit, TransferID: id, PayloadSHA256: digest}})
+		return rendezvousRequest(t, store, http.MethodPost, transfersPath+"/"+id+"/ack", body).Code
+	}
+
+	first := testEnvelope(t, "xfer-a")
+	for _, env := range []bridge.Envelope{first, testEnvelope(t, "xfer-b"), testEnvelope(t, "xfer-c")} {
+		*now = now.Add(time.Second)
+		if recorder := post(env); recorder.Code != http.StatusOK {
+			t.Fatalf("post %s = %d: %s", env.TransferID, recorder.Code, recorder.Body)
+		}
+	}
+	if recorder := post(first); recorder.Code != http.StatusOK {
+		t.Fatalf("identical re-post = %d, want accepted", recorder.Code)
+	}
+	conflict := first
+	conflict.PayloadSHA256 = strings.Repeat("0", 64)
+	conflict.Payload = nil
+	raw, _ := json.Marshal(conflict)
+	if code := rendezvousRequest(t, store, http.MethodPost, transfersPath, raw).Code; code != http.StatusConflict {
+		t.Fatalf("conflicting post = %d, want 409", code)
+	}
+
+	// Bounded batch, oldest first; leased envelopes stay hidden, even from a
+	// second process on the same directory.
+	if got := pollRendezvousIDs(t, store, "10"); strings.Join(got, ",") != "xfer-a,xfer-b" {
+		t.Fatalf("first poll = %v, want the two oldest", got)
+	}
+	reopened, _ := newTestRendezvous(t, dir, 2)
+	reopened.now = store.now
+	if got := pollRendezvousIDs(t, reopened, "10"); strings.Join(got, ",") != "xfer-c" {
+		t.Fatalf("second poll = %v, want only the unleased transfer", got)
+	}
+
+	if code := ack("xfer-a", strings.Repeat("0", 64)); code != http.StatusConflict {
+		t.Fatalf("ack with wrong digest = %d, want 409", code)
+	}
+	if code := ack("xfer-a", first.PayloadSHA256); code != http.StatusOK {
+		t.Fatalf("ack = %d", code)
+	}
+	if code := ack("xfer-a", first.PayloadSHA256); code != http.StatusOK {
+		t.Fatalf("repeated ack = %d, want idempotent success", code)
+	}
+	if code := ack("xfer-missing", first.PayloadSHA256); code != http.StatusNotFound {
+		t.Fatalf("ack of unknown transfer = %d, want 404", code)
+	}
+
+	// A lost ACK is replayed once the lease ends; the acknowledged transfer
+	// is not, even when its sender posts it again.
+	if recorder := post(first); recorder.Code != http.StatusOK {
+		t.Fatalf("post after ack = %d, want accepted", recorder.Code)
+	}
+	*now = now.Add(2 * time.Minute)
+	if got := pollRendezvousIDs(t, store, "10"); strings.Join(got, ",") != "xfer-b,xfer-c" {
+		t.Fatalf("poll after lease expiry = %v, want the unacknowledged transfers", got)
+	}
+}
+
+func TestRendezvousListenMustBeLoopback(t *testing.T) {
+	for addr, ok := range map[string]bool{
+		"127.0.0.1:0":    true,
+		"[::1]:8787":     true,
+		"localhost:8787": true,
+		"0.0.0.0:8787":   false,
+		":8787":          false,
+		"192.0.2.1:8787": false,
+	} {
+		if err := validateRendezvousListen(addr); (err == nil) != ok {
+			t.Errorf("validateRendezvousListen(%q) = %v, want ok=%v", addr, err, ok)
+		}
+	}
+}
+
+func TestRendezvousOnlySeesCiphertextForBoxPeer(t *testing.T) {
+	dir := t.TempDir()
+	store, now := newTestRendezvous(t, dir, defaultBridgeBatchSize)
+	server := httptest.NewServer(store)
+	t.Cleanup(server.Close)
+	senderRoot := newBridgeRoot(t, "codex")
+	receiverRoot := newBridgeRoot(t, "claude")
+	ensureHostID(t, receiverRoot, "mac")
+	ensureIdentity(t, receiverRoot, "mac")
+	receiverKey := testHostKey("mac", defaultKeyGeneration)
+	box, err := receiverKey.BoxKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The sender negotiates v2 because the receiver's trusted record
+	// publishes a box key.
+	ensureHostID(t, senderRoot, "grok-host")
+	if err := bridge.WriteTrustedKeys(senderRoot, "mac", []bridge.TrustedKey{{
+		Generation: defaultKeyGeneration, Public: receiverKey.Public(), Box: box.PublicKey().Bytes(),
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	spool := filepath.Join(senderRoot, "bridge", "outbox", "codex", "new")
+	if err := os.MkdirAll(spool, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	message := testMessage(t, "msg-sealed", "thread-sealed", "codex", "for the destination only")
+	if err := os.WriteFile(filepath.Join(spool, "sealed.md"), message, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sender := testCourier(t, Config{
+		Root: senderRoot, RendezvousURL: server.URL, SourceHost: "grok-host", SourceHandle: "codex",
+		DestAlias: "mac/claude", AllowedDestAliases: []string{"mac/claude"},
+	})
+	push, err := sender.PushOnce(context.Background())
+	if err != nil || len(push.Receipts) != 1 {
+		t.Fatalf("PushOnce = %#v, %v", push, err)
+	}
+	stored, err := os.ReadFile(filepath.Join(dir, "queue", push.Receipts[0].TransferID+".envelope"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := bridge.UnmarshalEnvelope(stored)
+	if err != nil || env.Version != bridge.EncryptedEnvelopeVersion || strings.Contains(string(env.Payload), "destination only") {
+		t.Fatalf("stored envelope = v%d (%v), want v2 ciphertext only", env.Version, err)
+	}
+
+	receiver := testCourier(t, Config{
+		Root: receiverRoot, RendezvousURL: server.URL, DestAlias: "mac/claude",
+		AllowedDestAliases: []string{"mac/claude"}, AllowedSourceHosts: []string{"grok-host"},
+	})
+	// A receiver that cannot decrypt fails closed: nothing is committed or
+	// acknowledged, and the envelope replays once the box key is right.
+	if err := os.Remove(bridge.IdentityPath(receiverRoot)); err != nil {
+		t.Fatal(err)
+	}
+	if err := bridge.WriteIdentity(receiverRoot, testHostKey("someone-else", defaultKeyGeneration)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := receiver.PollOnce(context.Background()); err == nil || !strings.Contains(err.Error(), "decrypt") {
+		t.Fatalf("poll with the wrong box key = %v, want decrypt failure", err)
+	}
+	inbox := filepath.Join(receiverRoot, "agents", "claude", "inbox", "new")
+	if entries, err := os.ReadDir(inbox); err != nil || len(entries) != 0 {
+		t.Fatalf("inbox after failed decrypt = %d entries (%v), want none", len(entries), err)
+	}
+	if err := os.Remove(bridge.IdentityPath(receiverRoot)); err != nil {
+		t.Fatal(err)
+	}
+	ensureIdentity(t, receiverRoot, "mac")
+	*now = now.Add(2 * time.Minute)
+	poll, err := receiver.PollOnce(context.Background())
+	if err != nil || len(poll.Receipts) != 1 || poll.Receipts[0].PayloadSHA256 != env.PayloadSHA256 {
+		t.Fatalf("PollOnce = %#v, %v; want one receipt for the ciphertext digest", poll, err)
+	}
+	committed, err := os.ReadFile(poll.Receipts[0].CommittedPath)
+	if err != nil || string(committed) != string(message) {
+		t.Fatalf("committed message = %q (%v), want the plaintext", committed, err)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/avivsinai/agent-message-queue/internal/bridge"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+)
+
+func TestRoutedCourierServesSeveralPeers(t *testing.T) {
+	shared := t.TempDir()
+	fake, server := newFakeRendezvous(t)
+	root := newBridgeRoot(t, "codex")
+	spool := filepath.Join(root, "bridge", "outbox", "codex", "new")
+	if err := os.MkdirAll(spool, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	for name, dest := range map[string]string{"to-grok": "grok/claude", "to-linux": "linux/claude"} {
+		message := testMessage(t, "msg-"+name, "thread-"+name, "codex", "routed")
+		if err := os.WriteFile(filepath.Join(spool, name+".md"), message, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(spool, name+".dest"), []byte(dest+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	courier := testCourier(t, Config{
+		Root: root, SourceHost: "mac", SourceHandle: "codex", ReceiveAlias: "mac/codex",
+		AllowedDestAliases: []string{"mac/codex"},
+		Routes: []bridge.Route{
+			{Host: "grok", SharedDir: shared, DestAliases: []string{"grok/claude"}, Inbound: true},
+			{Host: "linux", Rendezvous: server.URL, DestAliases: []string{"linux/claude"}, Inbound: true},
+		},
+	})
+
+	push, err := courier.PushOnce(context.Background())
+	if err != nil || len(push.Receipts) != 2 {
+		t.Fatalf("PushOnce = %+v, %v; want one receipt per peer", push, err)
+	}
+	if len(fake.queue) != 1 || fake.queue[0].DestAlias != "linux/claude" {
+		t.Fatalf("rendezvous queue = %+v, want only the linux transfer", fake.queue)
+	}
+	outgoing, err := os.ReadDir(filepath.Join(shared, "grok", "outgoing"))
+	if err != nil || len(outgoing) != 1 {
+		t.Fatalf("grok shared outgoing = %d entries (%v), want one", len(outgoing), err)
+	}
+	if _, err := os.Stat(filepath.Join(spool, "to-grok.md")); err != nil {
+		t.Fatalf("dir-routed item left the spool before its ack: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(spool, "to-linux.md")); !os.IsNotExist(err) {
+		t.Fatalf("https-routed item still in the spool: %v", err)
+	}
+	for _, r := range push.Receipts {
+		host, _, _ := bridge.ParseAlias(r.DestAlias)
+		if _, err := os.Stat(filepath.Join(root, "bridge", "receipts", host, receiptFilename(r.TransferID, r.Stage))); err != nil {
+			t.Fatalf("receipt for %s is not under its route's directory: %v", r.DestAlias, err)
+		}
+	}
+	fake.queue = nil
+
+	// Each peer sends back over its own route.
+	fromGrok := peerEnvelope(t, "grok", "msg-from-grok")
+	if _, err := (sharedDir{path: shared}).post(fromGrok); err != nil {
+		t.Fatal(err)
+	}
+	fromLinux := peerEnvelope(t, "linux", "msg-from-linux")
+	fake.accepted[fromLinux.TransferID] = fromLinux
+	fake.queue = append(fake.queue, fromLinux)
+	poll, err := courier.PollOnce(context.Background())
+	if err != nil || len(poll.Receipts) != 2 {
+		t.Fatalf("PollOnce = %+v, %v; want both peers applied", poll, err)
+	}
+	for _, r := range poll.Receipts {
+		if _, err := os.Stat(filepath.Join(root, "bridge", "receipts", r.SourceHost, receiptFilename(r.TransferID, r.Stage))); err != nil {
+			t.Fatalf("commit receipt from %s is not under its route's directory: %v", r.SourceHost, err)
+		}
+	}
+
+	// A peer may not use another peer's transport: its envelope is
+	// quarantined and the rest of the transport still drains.
+	stray := peerEnvelope(t, "linux", "msg-stray")
+	if _, err := (sharedDir{path: shared}).post(stray); err != nil {
+		t.Fatal(err)
+	}
+	next := peerEnvelope(t, "grok", "msg-after-stray")
+	if _, err := (sharedDir{path: shared}).post(next); err != nil {
+		t.Fatal(err)
+	}
+	poll, err = courier.PollOnce(context.Background())
+	if err != nil {
+		t.Fatalf("PollOnce with a misrouted envelope: %v", err)
+	}
+	if len(poll.Quarantined) != 1 || poll.Quarantined[0].TransferID != stray.TransferID || !strings.Contains(poll.Quarantined[0].Reason, "not routed over") {
+		t.Fatalf("quarantined = %+v, want the linux envelope sent over the grok route", poll.Quarantined)
+	}
+	if len(poll.Receipts) != 1 || poll.Receipts[0].TransferID != next.TransferID {
+		t.Fatalf("receipts = %+v, want grok's next envelope applied", poll.Receipts)
+	}
+	if _, err := os.Stat(filepath.Join(root, "bridge", "quarantine", stray.TransferID+".json")); err != nil {
+		t.Fatalf("quarantine record: %v", err)
+	}
+}
+
+func TestRoutedCourierRefusesUnroutedDestination(t *testing.T) {
+	root := newBridgeRoot(t, "codex")
+	spool := filepath.Join(root, "bridge", "outbox", "codex", "new")
+	if err := os.MkdirAll(spool, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(spool, "m.md"), testMessage(t, "msg-m", "thread-m", "codex", "x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(spool, "m.dest"), []byte("grok/codex\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	courier := testCourier(t, Config{
+		Root: root, SourceHost: "mac", SourceHandle: "codex", ReceiveAlias: "mac/codex",
+		AllowedDestAliases: []string{"mac/codex", "grok/codex"},
+		Routes:             []bridge.Route{{Host: "grok", SharedDir: t.TempDir(), DestAliases: []string{"grok/claude"}}},
+	})
+	if _, err := courier.PushOnce(context.Background()); err == nil || !strings.Contains(err.Error(), "not in the route") {
+		t.Fatalf("push outside the route allowlist = %v, want refusal", err)
+	}
+
+	if _, err := NewCourier(Config{
+		Root: root, RendezvousURL: "https://relay.example", ReceiveAlias: "mac/codex",
+		AllowedDestAliases: []string{"mac/codex"},
+		Routes:             []bridge.Route{{Host: "grok", SharedDir: t.TempDir(), Inbound: true}},
+	}); err == nil || !strings.Contains(err.Error(), "routes replace") {
+		t.Fatalf("routes with --rendezvous = %v, want refusal", err)
+	}
+}
+
+func TestRoutedCourierReceivesOnEachRoutesAlias(t *testing.T) {
+	grokShared, linuxShared := t.TempDir(), t.TempDir()
+	root := newBridgeRoot(t, "codex")
+	if err := fsq.EnsureAgentDirs(root, "cursor"); err != nil {
+		t.Fatal(err)
+	}
+	courier := testCourier(t, Config{
+		Root: root, SourceHost: "mac", SourceHandle: "codex", ReceiveAlias: "mac/codex",
+		AllowedDestAliases: []string{"mac/codex"},
+		Routes: []bridge.Route{
+			{Host: "grok", SharedDir: grokShared, Inbound: true},
+			{Host: "linux", SharedDir: linuxShared, Inbound: true, ReceiveAlias: "mac/cursor"},
+		},
+	})
+
+	fromGrok := peerEnvelopeTo(t, "grok", "mac/codex", "msg-grok-codex")
+	if _, err := (sharedDir{path: grokShared}).post(fromGrok); err != nil {
+		t.Fatal(err)
+	}
+	fromLinux := peerEnvelopeTo(t, "linux", "mac/cursor", "msg-linux-cursor")
+	if _, err := (sharedDir{path: linuxShared}).post(fromLinux); err != nil {
+		t.Fatal(err)
+	}
+	poll, err := courier.PollOnce(context.Background())
+	if err != nil || len(poll.Receipts) != 2 {
+		t.Fatalf("PollOnce = %+v, %v; want one commit per route", poll, err)
+	}
+	for agent, env := range map[string]bridge.Envelope{"codex": fromGrok, "cursor": fromLinux} {
+		name := bridge.TransferFilename(env.SourceHost, env.TransferID)
+		if _, err := os.Stat(filepath.Join(fsq.AgentInboxNew(root, agent), name)); err != nil {
+			t.Fatalf("%s's transfer is not in %s's inbox: %v", env.SourceHost, agent, err)
+		}
+	}
+
+	// linux may only reach its own receive alias.
+	stray := peerEnvelopeTo(t, "linux", "mac/codex", "msg-linux-codex")
+	if _, err := (sharedDir{path: linuxShared}).post(stray); err != nil {
+		t.Fatal(err)
+	}
+	poll, err = courier.PollOnce(context.Background())
+	if err != nil || len(poll.Receipts) != 0 {
+		t.Fatalf("PollOnce = %+v, %v; want linux's envelope to mac/codex left unapplied", poll, err)
+	}
+
+	if _, err := NewCourier(Config{
+		Root: root, SourceHost: "mac", ReceiveAlias: "mac/codex", AllowedDestAliases: []string{"mac/codex"},
+		Routes: []bridge.Route{{Host: "linux", SharedDir: linuxShared, Inbound: true, ReceiveAlias: "ci/cursor"}},
+	}); err == nil || !strings.Contains(err.Error(), "not on local host") {
+		t.Fatalf("foreign route receive alias = %v, want refusal", err)
+	}
+}
+
+func peerEnvelope(t *testing.T, host, messageID string) bridge.Envelope {
+	t.Helper()
+	return peerEnvelopeTo(t, host, "mac/codex", messageID)
+}
+
+func peerEnvelopeTo(t *testing.T, host, destAlias, messageID string) bridge.Envelope {
+	t.Helper()
+	message := testMessage(t, messageID, "thread-"+messageID, "claude", "reply")
+	env := envelopeForMessage(Config{SourceHost: host, SourceHandle: "claude", KeyGeneration: defaultKeyGeneration}, destAlias, messageID, "thread-"+messageID, message)
+	if err := bridge.SignEnvelope(&env, testHostKey(host, defaultKeyGeneration)); err != nil {
+		t.Fatal(err)
+	}
+	return env
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/avivsinai/agent-message-queue/internal/bridge"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+)
+
+const (
+	sharedOutgoingDir = "outgoing"
+	sharedAckedDir    = "acked"
+	sharedFileSuffix  = ".json"
+)
+
+// sharedDir is the dir transport: a folder both hosts can write, such as a
+// synced folder or a mounted volume. Envelopes for host H are written to
+// <shared>/H/outgoing/<transfer_id>.json and H answers each commit with
+// <shared>/H/acked/<transfer_id>.json. Sync tools may show a file before it
+// is complete or keep conflict copies under other names, so anything that
+// is hidden, misnamed, or does not decode yet is skipped and retried on the
+// next cycle.
+type sharedDir struct {
+	path string
+}
+
+func (s sharedDir) hostDir(host, sub string) string {
+	return filepath.Join(s.path, host, sub)
+}
+
+// post writes env for its destination host. An envelope already there with
+// the same digest is an idempotent replay; a different digest is a conflict.
+func (s sharedDir) post(env bridge.Envelope) (wireReceipt, error) {
+	destHost, _, err := bridge.ParseAlias(env.DestAlias)
+	if err != nil {
+		return wireReceipt{}, err
+	}
+	dir := s.hostDir(destHost, sharedOutgoingDir)
+	name := env.TransferID + sharedFileSuffix
+	existing, err := readSharedEnvelope(filepath.Join(dir, name))
+	switch {
+	case err == nil && !strings.EqualFold(existing.PayloadSHA256, env.PayloadSHA256):
+		return wireReceipt{}, fmt.Errorf("shared directory already holds transfer %s with a different digest", env.TransferID)
+	case err == nil:
+	case errors.Is(err, os.ErrNotExist) || errors.Is(err, errSharedIncomplete):
+		data, marshalErr := bridge.MarshalEnvelope(env)
+		if marshalErr != nil {
+			return wireReceipt{}, marshalErr
+		}
+		if _, err := fsq.WriteFileAtomic(dir, name, data, 0o600); err != nil {
+			return wireReceipt{}, fmt.Errorf("write shared envelope: %w", err)
+		}
+	default:
+		return wireReceipt{}, err
+	}
+	return wireReceipt{Stage: ReceiptTransportAccepted, TransferID: env.TransferID, PayloadSHA256: env.PayloadSHA256}, nil
+}
+
+// poll returns up to limit complete envelopes addressed to receiveAlias that
+// have not been acknowledged yet, in filename order. Envelopes for other
+// agents on the same host are left for their own courier.
+func (s sharedDir) poll(receiveAlias string, limit int) ([]bridge.Envelope, error) {
+	host, _, err := bridge.ParseAlias(receiveAlias)
+	if err != nil {
+		return nil, err
+	}
+	dir := s.hostDir(host, sharedOutgoingDir)
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read shared directory: %w", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	var envelopes []bridge.Envelope
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, sharedFileSuffix) {
+			continue
+		}
+		env, err := readSharedEnvelope(filepath.Join(dir, name))
+		if errors.Is(err, errSharedIncomplete) || errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		// A copy under another name, e.g. a sync conflict, is not the transfer.
+		if name != env.TransferID+sharedFileSuffix || env.DestAlias != receiveAlias {
+			continue
+		}
+		stage, err := s.ackStage(host, env)
+		if err != nil {
+			return nil, err
+		}
+		if stage != "" {
+			continue
+		}
+		envelopes = append(envelopes, env)
+		if len(envelopes) >= limit {
+			break
+		}
+	}
+	return envelopes, nil
+}
+
+// ack records the outcome of env for its sender: the local Maildir commit,
+// or its refusal into quarantine.
+func (s sharedDir) ack(env bridge.Envelope, stage ReceiptStage) error {
+	destHost, _, err := bridge.ParseAlias(env.DestAlias)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(wireReceipt{
+		Stage:         stage,
+		TransferID:    env.TransferID,
+		PayloadSHA256: env.PayloadSHA256,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fsq.WriteFileAtomic(s.hostDir(destHost, sharedAckedDir), env.TransferID+sharedFileSuffix, append(data, '\n'), 0o600)
+	return err
+}
+
+// ackStage returns the stage host acknowledged env with, or "" when it has
+// not yet. An ack that does not decode yet is not an ack; one for a
+// different digest or an unknown stage is a conflict.
+func (s sharedDir) ackStage(host string, env bridge.Envelope) (ReceiptStage, error) {
+	path := filepath.Join(s.hostDir(host, sharedAckedDir), env.TransferID+sharedFileSuffix)
+	data, err := fsq.ReadRegularNoFollow(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	var receipt wireReceipt
+	if err := json.Unmarshal(data, &receipt); err != nil {
+		return "", nil
+	}
+	want := ReceiptDestinationMaildirCommit
+	if receipt.Stage == ReceiptDestinationRefused {
+		want = ReceiptDestinationRefused
+	}
+	if err := validateWireReceipt(receipt, want, env); err != nil {
+		return "", fmt.Errorf("shared ack %s: %w", path, err)
+	}
+	return receipt.Stage, nil
+}
+
+// settled returns the stage the destination acknowledged env with and, once
+// it has, removes the sender's envelope file. The ack stays as a tombstone so
+// a resurrected copy of the envelope is not applied again.
+func (s sharedDir) settled(env bridge.Envelope) (ReceiptStage, error) {
+	destHost, _, err := bridge.ParseAlias(env.DestAlias)
+	if err != nil {
+		return "", err
+	}
+	stage, err := s.ackStage(destHost, env)
+	if err != nil || stage == "" {
+		return "", err
+	}
+	dir := s.hostDir(destHost, sharedOutgoingDir)
+	if err := os.Remove(filepath.Join(dir, env.TransferID+sharedFileSuffix)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	if err := fsq.SyncDir(dir); err != nil {
+		return "", err
+	}
+	return stage, nil
+}
+
+var errSharedIncomplete = errors.New("shared envelope is incomplete")
+
+func readSharedEnvelope(path string) (bridge.Envelope, error) {
+	file, info, err := fsq.OpenRegularNoFollow(path)
+	if err != nil {
+		return bridge.Envelope{}, err
+	}
+	defer func() { _ = file.Close() }()
+	if info.Size() > maxHTTPBodySize {
+		return bridge.Envelope{}, fmt.Errorf("shared envelope %s exceeds %d bytes", path, maxHTTPBodySize)
+	}
+	data, err := io.ReadAll(io.LimitReader(file, maxHTTPBodySize+1))
+	if err != nil {
+		return bridge.Envelope{}, err
+	}
+	if len(data) > maxHTTPBodySize {
+		return bridge.Envelope{}, fmt.Errorf("shared envelope %s exceeds %d bytes", path, maxHTTPBodySize)
+	}
+	// A sync tool may still be filling the file in place.
+	if !json.Valid(data) {
+		return bridge.Envelope{}, errSharedIncomplete
+	}
+	env, err := bridge.UnmarshalEnvelope(data)
+	if err != nil {
+		return bridge.Envelope{}, fmt.Errorf("decode shared envelope %s: %w", path, err)
+	}
+	return env, nil
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/bridge"
+	"github.com/avivsinai/agent-message-queue/internal/config"
+)
+
+func TestSharedDirTransportAppliesAcksAndArchives(t *testing.T) {
+	shared := t.TempDir()
+	senderRoot := newBridgeRoot(t, "codex")
+	receiverRoot := newBridgeRoot(t, "claude")
+	spool := filepath.Join(senderRoot, "bridge", "outbox", "codex", "new")
+	if err := os.MkdirAll(spool, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	message := testMessage(t, "msg-shared", "thread-shared", "codex", "over the synced folder")
+	if err := os.WriteFile(filepath.Join(spool, "shared.md"), message, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sender := testCourier(t, Config{
+		Root: senderRoot, Transport: TransportDir, SharedDir: shared, SourceHost: "grok-host", SourceHandle: "codex",
+		DestAlias: "mac/claude", AllowedDestAliases: []string{"mac/claude"},
+	})
+	receiver := testCourier(t, Config{
+		Root: receiverRoot, Transport: TransportDir, SharedDir: shared, DestAlias: "mac/claude",
+		AllowedDestAliases: []string{"mac/claude"}, AllowedSourceHosts: []string{"grok-host"},
+	})
+
+	push, err := sender.PushOnce(context.Background())
+	if err != nil || len(push.Receipts) != 1 || push.Receipts[0].Stage != ReceiptTransportAccepted {
+		t.Fatalf("PushOnce = %+v, %v; want one transport_accepted receipt", push, err)
+	}
+	transferID := push.Receipts[0].TransferID
+	outgoing := filepath.Join(shared, "mac", "outgoing")
+	envelopeFile := filepath.Join(outgoing, transferID+".json")
+	written, err := os.ReadFile(envelopeFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(spool, "shared.md")); err != nil {
+		t.Fatalf("spool drained before the destination acked: %v", err)
+	}
+
+	// Sync tools leave temp files, half-written files, and conflict copies.
+	debris := map[string]string{
+		".shared.json.syncthing.tmp":      string(written),
+		"xfer-partial.json":               string(written[:len(written)/2]),
+		transferID + " (conflict 1).json": string(written),
+		transferID + ".json.partial":      string(written),
+		strings.Repeat("z", 8) + ".json~": string(written),
+	}
+	for name, data := range debris {
+		if err := os.WriteFile(filepath.Join(outgoing, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	poll, err := receiver.PollOnce(context.Background())
+	if err != nil || len(poll.Receipts) != 1 || poll.Receipts[0].TransferID != transferID {
+		t.Fatalf("PollOnce = %+v, %v; want exactly the one transfer", poll, err)
+	}
+	if committed, err := os.ReadFile(poll.Receipts[0].CommittedPath); err != nil || string(committed) != string(message) {
+		t.Fatalf("committed message = %q (%v)", committed, err)
+	}
+	if _, err := os.Stat(filepath.Join(shared, "mac", "acked", transferID+".json")); err != nil {
+		t.Fatalf("ack file: %v", err)
+	}
+	if again, err := receiver.PollOnce(context.Background()); err != nil || len(again.Receipts) != 0 {
+		t.Fatalf("poll after ack = %+v, %v; want nothing replayed", again, err)
+	}
+
+	if _, err := sender.PushOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(senderRoot, "bridge", "outbox", "codex", "sent", "shared.md")); err != nil {
+		t.Fatalf("acked spool item was not archived: %v", err)
+	}
+	if _, err := os.Stat(envelopeFile); !os.IsNotExist(err) {
+		t.Fatalf("delivered envelope still in the shared directory: %v", err)
+	}
+
+	// A copy resurrected by the sync tool is a duplicate, not a new message.
+	if err := os.WriteFile(envelopeFile, written, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if again, err := receiver.PollOnce(context.Background()); err != nil || len(again.Receipts) != 0 {
+		t.Fatalf("poll of a resurrected envelope = %+v, %v; want it skipped", again, err)
+	}
+	entries, err := os.ReadDir(filepath.Join(receiverRoot, "agents", "claude", "inbox", "new"))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("inbox = %d entries (%v), want one", len(entries), err)
+	}
+}
+
+// TestSharedDirRefusalIsNotRecordedAsDelivered proves a transfer the
+// destination quarantines is acknowledged as destination_refused, and the
+// sender archives it under refused/ with a matching receipt instead of
+// treating it as delivered.
+func TestSharedDirRefusalIsNotRecordedAsDelivered(t *testing.T) {
+	shared := t.TempDir()
+	senderRoot := newBridgeRoot(t, "codex")
+	receiverRoot := newBridgeRoot(t, "claude")
+	cfg := config.Config{ACL: &config.ACLConfig{Agents: map[string]config.ACLPolicy{
+		"claude": {Deny: []config.ACLRule{{From: []string{"codex"}}}},
+	}}}
+	if err := config.WriteConfig(filepath.Join(receiverRoot, "meta", "config.json"), cfg, true); err != nil {
+		t.Fatal(err)
+	}
+	spool := filepath.Join(senderRoot, "bridge", "outbox", "codex", "new")
+	if err := os.MkdirAll(spool, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(spool, "denied.md"), testMessage(t, "msg-denied", "thread-denied", "codex", "let me in"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sender := testCourier(t, Config{
+		Root: senderRoot, Transport: TransportDir, SharedDir: shared, SourceHost: "grok-host", SourceHandle: "codex",
+		DestAlias: "mac/claude", AllowedDestAliases: []string{"mac/claude"},
+	})
+	receiver := testCourier(t, Config{
+		Root: receiverRoot, Transport: TransportDir, SharedDir: shared, DestAlias: "mac/claude",
+		AllowedDestAliases: []string{"mac/claude"}, AllowedSourceHosts: []string{"grok-host"},
+	})
+
+	push, err := sender.PushOnce(context.Background())
+	if err != nil || len(push.Receipts) != 1 {
+		t.Fatalf("PushOnce = %+v, %v", push, err)
+	}
+	transferID := push.Receipts[0].TransferID
+	poll, err := receiver.PollOnce(context.Background())
+	if err != nil || len(poll.Quarantined) != 1 || len(poll.Receipts) != 0 {
+		t.Fatalf("PollOnce = %+v, %v; want one quarantined transfer", poll, err)
+	}
+
+	settle, err := sender.PushOnce(context.Background())
+	if err != nil || len(settle.Receipts) != 1 || settle.Receipts[0].Stage != ReceiptDestinationRefused {
+		t.Fatalf("PushOnce after refusal = %+v, %v; want a destination_refused receipt", settle, err)
+	}
+	outbox := filepath.Join(senderRoot, "bridge", "outbox", "codex")
+	if _, err := os.Stat(filepath.Join(outbox, "refused", "denied.md")); err != nil {
+		t.Fatalf("refused spool item was not archived under refused/: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outbox, "sent", "denied.md")); !os.IsNotExist(err) {
+		t.Fatalf("refused spool item archived as sent: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(senderRoot, "bridge", "receipts", receiptFilename(transferID, ReceiptDestinationRefused))); err != nil {
+		t.Fatalf("sender has no destination_refused receipt: %v", err)
+	}
+	status, err := collectStatus(senderRoot, filepath.Join(senderRoot, "bridge", "receipts"), nil, time.Hour, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Conflicts) != 0 || len(status.AwaitingCommit) != 0 || len(status.Refused) != 1 || status.Status != "attention" {
+		t.Fatalf("status = %+v; want one refused transfer needing attention", status)
+	}
+}
+
+func TestSharedDirMustBeOutsideRoot(t *testing.T) {
+	root := newBridgeRoot(t, "claude")
+	ensureHostID(t, root, "mac")
+	for _, shared := range []string{root, filepath.Join(root, "bridge", "shared")} {
+		_, err := NewCourier(Config{
+			Root: root, Transport: TransportDir, SharedDir: shared, DestAlias: "mac/claude",
+			AllowedDestAliases: []string{"mac/claude"},
+		})
+		if err == nil || !strings.Contains(err.Error(), "outside the AMQ root") {
+			t.Fatalf("shared dir %s = %v, want refusal", shared, err)
+		}
+	}
+	if _, err := NewCourier(Config{
+		Root: root, Transport: TransportDir, SharedDir: t.TempDir(), RendezvousURL: "https://relay.example",
+		DestAlias: "mac/claude", AllowedDestAliases: []string{"mac/claude"},
+	}); err == nil {
+		t.Fatal("dir transport accepted a rendezvous URL")
+	}
+}
+
+// TestSpoolItemKeepsItsEnvelopeAcrossTrustChange proves an item pushed before
+// the peer published a box key is not resealed while it waits for the ack:
+// the pinned envelope keeps matching its transport receipt.
+func TestSpoolItemKeepsItsEnvelopeAcrossTrustChange(t *testing.T) {
+	shared := t.TempDir()
+	senderRoot := newBridgeRoot(t, "codex")
+	receiverRoot := newBridgeRoot(t, "claude")
+	spool := filepath.Join(senderRoot, "bridge", "outbox", "codex", "new")
+	if err := os.MkdirAll(spool, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	message := testMessage(t, "msg-pinned", "thread-pinned", "codex", "sent before the box key")
+	if err := os.WriteFile(filepath.Join(spool, "pinned.md"), message, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sender := testCourier(t, Config{
+		Root: senderRoot, Transport: TransportDir, SharedDir: shared, SourceHost: "grok-host", SourceHandle: "codex",
+		DestAlias: "mac/claude", AllowedDestAliases: []string{"mac/claude"},
+	})
+	receiver := testCourier(t, Config{
+		Root: receiverRoot, Transport: TransportDir, SharedDir: shared, DestAlias: "mac/claude",
+		AllowedDestAliases: []string{"mac/claude"}, AllowedSourceHosts: []string{"grok-host"},
+	})
+	push, err := sender.PushOnce(context.Background())
+	if err != nil || len(push.Receipts) != 1 {
+		t.Fatalf("PushOnce = %+v, %v", push, err)
+	}
+	if _, err := os.Stat(filepath.Join(spool, "pinned.envelope")); err != nil {
+		t.Fatalf("envelope sidecar: %v", err)
+	}
+
+	// The peer now publishes a box key; a fresh seal would change the digest.
+	receiverKey := testHostKey("mac", defaultKeyGeneration)
+	box, err := receiverKey.BoxKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bridge.WriteTrustedKeys(senderRoot, "mac", []bridge.TrustedKey{{
+		Generation: defaultKeyGeneration, Public: receiverKey.Public(), Box: box.PublicKey().Bytes(),
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sender.PushOnce(context.Background()); err != nil {
+		t.Fatalf("push after trust change: %v", err)
+	}
+
+	poll, err := receiver.PollOnce(context.Background())
+	if err != nil || len(poll.Receipts) != 1 || poll.Receipts[0].PayloadSHA256 != push.Receipts[0].PayloadSHA256 {
+		t.Fatalf("PollOnce = %+v, %v; want the originally pushed envelope", poll, err)
+	}
+	if _, err := sender.PushOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(senderRoot, "bridge", "outbox", "codex", "sent", "pinned.md")); err != nil {
+		t.Fatalf("acked spool item was not archived: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(spool, "pinned.envelope")); !os.IsNotExist(err) {
+		t.Fatalf("envelope sidecar left behind: %v", err)
+	}
+}
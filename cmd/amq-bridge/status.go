@@ -0,0 +1,650 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/bridge"
+	"github.com/avivsinai/agent-message-queue/internal/format"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+	"github.com/avivsinai/agent-message-queue/internal/receipt"
+)
+
+// errNeedsAttention makes amq-bridge status exit 3: the report was produced,
+// and something in it is stuck or conflicting.
+var errNeedsAttention = errors.New("bridge needs attention")
+
+type bridgeStatus struct {
+	Root           string           `json:"root"`
+	HostID         string           `json:"host_id,omitempty"`
+	Generation     string           `json:"identity_generation,omitempty"`
+	Status         string           `json:"status"`
+	Attention      []string         `json:"attention,omitempty"`
+	Spools         []spoolStatus    `json:"spools"`
+	Drop           dropStatus       `json:"drop"`
+	AwaitingCommit []transferStatus `json:"awaiting_commit"`
+	Refused        []transferStatus `json:"refused"`
+	Uncertain      []transferStatus `json:"uncertain"`
+	Conflicts      []conflictStatus `json:"conflicts"`
+	LastPush       []cycleState     `json:"last_push"`
+	LastPoll       []cycleState     `json:"last_poll"`
+	Peers          []peerStatus     `json:"peers"`
+	Routes         []routeStatus    `json:"routes,omitempty"`
+}
+
+// routeStatus is one routing table entry with the bridge receipts of its
+// peer: transfers sent to it, transfers still awaiting its commit, and
+// transfers committed here from it.
+type routeStatus struct {
+	Host           string   `json:"host"`
+	Transport      string   `json:"transport"`
+	DestAliases    []string `json:"dest_aliases"`
+	Inbound        bool     `json:"inbound"`
+	Sent           int      `json:"sent"`
+	AwaitingCommit int      `json:"awaiting_commit"`
+	Received       int      `json:"received"`
+}
+
+type spoolStatus struct {
+	Handle         string  `json:"handle"`
+	Pending        int     `json:"pending"`
+	OldestPending  string  `json:"oldest_pending,omitempty"`
+	OldestAge      float64 `json:"oldest_age_seconds,omitempty"`
+	Sent           int     `json:"sent"`
+	Refused        int     `json:"refused"`
+	Sidecars       int     `json:"dest_sidecars"`
+	OrphanSidecars int     `json:"orphan_sidecars"`
+}
+
+type dropStatus struct {
+	Pending   int     `json:"pending"`
+	Applied   int     `json:"applied"`
+	OldestAge float64 `json:"oldest_pending_age_seconds,omitempty"`
+}
+
+type transferStatus struct {
+	TransferID      string  `json:"transfer_id"`
+	SourceMessageID string  `json:"source_message_id,omitempty"`
+	DestAlias       string  `json:"dest_alias,omitempty"`
+	AcceptedAt      string  `json:"accepted_at"`
+	Age             float64 `json:"age_seconds"`
+}
+
+type conflictStatus struct {
+	Path   string `json:"path"`
+	Detail string `json:"detail"`
+}
+
+type peerStatus struct {
+	Host        string          `json:"host"`
+	Generations []peerKeyStatus `json:"generations"`
+	Error       string          `json:"error,omitempty"`
+}
+
+type peerKeyStatus struct {
+	Generation string `json:"generation"`
+	State      string `json:"state"`
+	NotAfter   string `json:"not_after,omitempty"`
+	Box        bool   `json:"box"`
+}
+
+// runStatus reports what is queued, stuck, or conflicting on this bridge
+// host from local files only. It exits 0 when healthy and 3 when an item is
+// older than --stale, a transfer is uncertain or conflicting, or a trusted
+// peer has no valid generation.
+func runStatus(args []string) error {
+	fs := flag.NewFlagSet("amq-bridge status", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	root := fs.String("root", os.Getenv("AM_ROOT"), "local AMQ root")
+	receipts := fs.String("receipt-dir", "", "bridge receipt directory below the AMQ root")
+	stale := fs.Duration("stale", time.Hour, "pending age that needs attention")
+	routesPath := fs.String("routes", "", "routing table JSON to report per route")
+	jsonOut := fs.Bool("json", false, "emit JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if strings.TrimSpace(*root) == "" {
+		return fmt.Errorf("bridge root is required")
+	}
+	if *stale <= 0 {
+		return fmt.Errorf("--stale must be positive")
+	}
+	rootPath, err := filepath.Abs(strings.TrimSpace(*root))
+	if err != nil {
+		return fmt.Errorf("resolve bridge root: %w", err)
+	}
+	receiptDir := filepath.Join(rootPath, "bridge", "receipts")
+	if *receipts != "" {
+		if receiptDir, err = filepath.Abs(*receipts); err != nil {
+			return fmt.Errorf("resolve bridge receipt directory: %w", err)
+		}
+		if _, err := rootRelativePath(rootPath, receiptDir); err != nil {
+			return fmt.Errorf("receipt directory: %w", err)
+		}
+	}
+	var routes []bridge.Route
+	if strings.TrimSpace(*routesPath) != "" {
+		table, err := bridge.LoadRoutingTable(*routesPath)
+		if err != nil {
+			return err
+		}
+		routes = table.Routes
+	}
+	status, err := collectStatus(rootPath, receiptDir, routes, *stale, time.Now())
+	if err != nil {
+		return err
+	}
+	if *jsonOut {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(status); err != nil {
+			return err
+		}
+	} else {
+		printStatus(status)
+	}
+	if status.Status != "ok" {
+		return errNeedsAttention
+	}
+	return nil
+}
+
+func collectStatus(root, receiptDir string, routes []bridge.Route, stale time.Duration, now time.Time) (bridgeStatus, error) {
+	status := bridgeStatus{
+		Root:           root,
+		Spools:         []spoolStatus{},
+		AwaitingCommit: []transferStatus{},
+		Refused:        []transferStatus{},
+		Uncertain:      []transferStatus{},
+		Conflicts:      []conflictStatus{},
+		LastPush:       []cycleState{},
+		LastPoll:       []cycleState{},
+		Peers:          []peerStatus{},
+	}
+	if hostID, err := bridge.LoadHostID(root); err == nil {
+		status.HostID = hostID
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return status, err
+	}
+	if key, err := bridge.LoadIdentity(root); err == nil {
+		status.Generation = key.Generation
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return status, err
+	}
+
+	receipts, err := scanStatusReceipts(receiptDir, &status)
+	if err != nil {
+		return status, err
+	}
+	accepted := receipts[ReceiptTransportAccepted]
+	committed := receipts[ReceiptDestinationMaildirCommit]
+	refused := receipts[ReceiptDestinationRefused]
+	inSpool := map[string]bool{}
+	if err := scanStatusSpools(root, now, stale, accepted, inSpool, &status); err != nil {
+		return status, err
+	}
+	if err := scanStatusDrop(root, now, stale, committed, &status); err != nil {
+		return status, err
+	}
+
+	ids := make([]string, 0, len(accepted))
+	for id := range accepted {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		r := accepted[id]
+		if r.Kind != "" {
+			continue
+		}
+		transfer := transferStatus{TransferID: id, SourceMessageID: r.SourceMessageID, DestAlias: r.DestAlias, AcceptedAt: r.EmittedAt}
+		if at, err := time.Parse(time.RFC3339Nano, r.EmittedAt); err == nil {
+			transfer.Age = now.Sub(at).Seconds()
+		}
+		if inSpool[id] {
+			status.Uncertain = append(status.Uncertain, transfer)
+			if transfer.Age >= stale.Seconds() {
+				status.Attention = append(status.Attention, fmt.Sprintf("transfer %s accepted but still in the spool", id))
+			}
+			continue
+		}
+		if _, ok := committed[id]; ok {
+			continue
+		}
+		// The destination quarantined it: nothing was committed, and the
+		// courier will not retry.
+		if _, ok := refused[id]; ok {
+			status.Refused = append(status.Refused, transfer)
+			status.Attention = append(status.Attention, fmt.Sprintf("transfer %s was refused by its destination", id))
+			continue
+		}
+		// A drained or dlq receipt sent back by the destination implies the
+		// commit.
+		_, drained := committed[bridge.ReceiptTransferID(id, receipt.StageDrained)]
+		_, dlq := committed[bridge.ReceiptTransferID(id, receipt.StageDLQ)]
+		if !drained && !dlq {
+			status.AwaitingCommit = append(status.AwaitingCommit, transfer)
+		}
+	}
+	for _, conflict := range status.Conflicts {
+		status.Attention = append(status.Attention, "conflict: "+conflict.Detail)
+	}
+
+	status.Routes = routeStatuses(routes, receipts, status.AwaitingCommit)
+
+	if err := scanStatusCycles(root, &status); err != nil {
+		return status, err
+	}
+	if err := scanStatusPeers(root, now, &status); err != nil {
+		return status, err
+	}
+	status.Status = "ok"
+	if len(status.Attention) > 0 {
+		status.Status = "attention"
+	}
+	return status, nil
+}
+
+// scanStatusReceipts indexes bridge receipts by stage and transfer id, from
+// the receipt directory and the per-route <host>/ directories below it. A
+// receipt that does not parse or disagrees with its filename is a conflict.
+func scanStatusReceipts(dir string, status *bridgeStatus) (map[ReceiptStage]map[string]Receipt, error) {
+	byStage := map[ReceiptStage]map[string]Receipt{
+		ReceiptTransportAccepted:        {},
+		ReceiptDestinationMaildirCommit: {},
+		ReceiptDestinationRefused:       {},
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return byStage, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read bridge receipts: %w", err)
+	}
+	var paths []string
+	found := map[string]string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() && fsq.ValidateHandle(name) == nil {
+			routed, err := os.ReadDir(filepath.Join(dir, name))
+			if err != nil {
+				return nil, fmt.Errorf("read bridge receipts for route %s: %w", name, err)
+			}
+			for _, r := range routed {
+				if !r.IsDir() {
+					paths = append(paths, filepath.Join(dir, name, r.Name()))
+				}
+			}
+			continue
+		}
+		if !entry.IsDir() {
+			paths = append(paths, filepath.Join(dir, name))
+		}
+	}
+	for _, path := range paths {
+		name := filepath.Base(path)
+		if strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		data, err := fsq.ReadRegularNoFollow(path)
+		if err != nil {
+			status.Conflicts = append(status.Conflicts, conflictStatus{Path: path, Detail: err.Error()})
+			continue
+		}
+		var r Receipt
+		if err := json.Unmarshal(data, &r); err != nil {
+			status.Conflicts = append(status.Conflicts, conflictStatus{Path: path, Detail: "receipt does not parse: " + err.Error()})
+			continue
+		}
+		stage, ok := byStage[r.Stage]
+		if !ok || name != receiptFilename(r.TransferID, r.Stage) {
+			status.Conflicts = append(status.Conflicts, conflictStatus{Path: path, Detail: "receipt does not match its filename"})
+			continue
+		}
+		stage[r.TransferID] = r
+		found[name] = path
+	}
+	for id, sent := range byStage[ReceiptTransportAccepted] {
+		if got, ok := byStage[ReceiptDestinationMaildirCommit][id]; ok && !strings.EqualFold(got.PayloadSHA256, sent.PayloadSHA256) {
+			status.Conflicts = append(status.Conflicts, conflictStatus{
+				Path:   found[receiptFilename(id, ReceiptDestinationMaildirCommit)],
+				Detail: fmt.Sprintf("transfer %s was accepted and committed with different digests", id),
+			})
+		}
+	}
+	return byStage, nil
+}
+
+// scanStatusSpools reports bridge/outbox/<handle>/{new,sent,refused}. A message in
+// new/ whose id already has a transport_accepted receipt was handed to the
+// transport but not archived: the courier stopped in between, or a dir
+// transport is still waiting for the ack.
+func scanStatusSpools(root string, now time.Time, stale time.Duration, accepted map[string]Receipt, inSpool map[string]bool, status *bridgeStatus) error {
+	outbox := filepath.Join(root, "bridge", "outbox")
+	handles, err := os.ReadDir(outbox)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read bridge outbox: %w", err)
+	}
+	acceptedByMessage := map[string][]string{}
+	for id, r := range accepted {
+		if r.SourceMessageID != "" && r.Kind == "" {
+			acceptedByMessage[r.SourceMessageID] = append(acceptedByMessage[r.SourceMessageID], id)
+		}
+	}
+	for _, handle := range handles {
+		if !handle.IsDir() || fsq.ValidateHandle(handle.Name()) != nil {
+			continue
+		}
+		spool := spoolStatus{Handle: handle.Name()}
+		newDir := filepath.Join(outbox, handle.Name(), "new")
+		entries, err := os.ReadDir(newDir)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("read bridge spool: %w", err)
+		}
+		messages := map[string]bool{}
+		var oldest time.Time
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || strings.HasPrefix(name, ".") {
+				continue
+			}
+			if strings.HasSuffix(name, ".dest") {
+				spool.Sidecars++
+				continue
+			}
+			if !strings.HasSuffix(name, ".md") {
+				continue
+			}
+			messages[filepath.Base(bridge.DestSidecarPath(name))] = true
+			spool.Pending++
+			path := filepath.Join(newDir, name)
+			if info, err := entry.Info(); err == nil && (oldest.IsZero() || info.ModTime().Before(oldest)) {
+				oldest = info.ModTime()
+			}
+			if id, ok := spoolMessageID(path); ok {
+				for _, transferID := range acceptedByMessage[id] {
+					inSpool[transferID] = true
+				}
+			}
+			if sent, err := fsq.ReadRegularNoFollow(filepath.Join(outbox, handle.Name(), "sent", name)); err == nil {
+				if data, err := fsq.ReadRegularNoFollow(path); err == nil && string(data) != string(sent) {
+					status.Conflicts = append(status.Conflicts, conflictStatus{Path: path, Detail: "spool file differs from its sent archive"})
+				}
+			}
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if strings.HasSuffix(name, ".dest") && !messages[name] {
+				spool.OrphanSidecars++
+			}
+		}
+		if sent, err := os.ReadDir(filepath.Join(outbox, handle.Name(), "sent")); err == nil {
+			for _, entry := range sent {
+				if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".md") {
+					spool.Sent++
+				}
+			}
+		}
+		if refused, err := os.ReadDir(filepath.Join(outbox, handle.Name(), "refused")); err == nil {
+			for _, entry := range refused {
+				if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".md") {
+					spool.Refused++
+				}
+			}
+		}
+		if !oldest.IsZero() {
+			spool.OldestPending = oldest.UTC().Format(time.RFC3339)
+			spool.OldestAge = now.Sub(oldest).Seconds()
+			if now.Sub(oldest) >= stale {
+				status.Attention = append(status.Attention, fmt.Sprintf("spool %s has pending messages older than %s", handle.Name(), stale))
+			}
+		}
+		status.Spools = append(status.Spools, spool)
+	}
+	return nil
+}
+
+func spoolMessageID(path string) (string, bool) {
+	file, _, err := fsq.OpenRegularNoFollow(path)
+	if err != nil {
+		return "", false
+	}
+	defer func() { _ = file.Close() }()
+	header, err := format.ReadHeader(file)
+	if err != nil || header.ID == "" {
+		return "", false
+	}
+	return header.ID, true
+}
+
+// scanStatusDrop counts bridge/drop envelopes; one is applied once its
+// destination_maildir_committed receipt exists.
+func scanStatusDrop(root string, now time.Time, stale time.Duration, committed map[string]Receipt, status *bridgeStatus) error {
+	dir := filepath.Join(root, applyDropRelDir)
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read bridge drop: %w", err)
+	}
+	var oldest time.Time
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		data, err := fsq.ReadRegularNoFollow(filepath.Join(dir, entry.Name()))
+		if err == nil {
+			if env, err := bridge.UnmarshalEnvelope(data); err == nil {
+				if _, ok := committed[env.TransferID]; ok {
+					status.Drop.Applied++
+					continue
+				}
+			}
+		}
+		status.Drop.Pending++
+		if info, err := entry.Info(); err == nil && (oldest.IsZero() || info.ModTime().Before(oldest)) {
+			oldest = info.ModTime()
+		}
+	}
+	if !oldest.IsZero() {
+		status.Drop.OldestAge = now.Sub(oldest).Seconds()
+		if now.Sub(oldest) >= stale {
+			status.Attention = append(status.Attention, fmt.Sprintf("bridge/drop has envelopes not applied for %s", stale))
+		}
+	}
+	return nil
+}
+
+func routeStatuses(routes []bridge.Route, receipts map[ReceiptStage]map[string]Receipt, awaiting []transferStatus) []routeStatus {
+	statuses := make([]routeStatus, 0, len(routes))
+	for _, route := range routes {
+		rs := routeStatus{Host: route.Host, Transport: "https " + route.Rendezvous, DestAliases: route.DestAliases, Inbound: route.Inbound}
+		if route.SharedDir != "" {
+			rs.Transport = "dir " + route.SharedDir
+		}
+		if rs.DestAliases == nil {
+			rs.DestAliases = []string{}
+		}
+		for _, r := range receipts[ReceiptTransportAccepted] {
+			if host, _, err := bridge.ParseAlias(r.DestAlias); err == nil && host == route.Host && r.Kind == "" {
+				rs.Sent++
+			}
+		}
+		for _, transfer := range awaiting {
+			if host, _, err := bridge.ParseAlias(transfer.DestAlias); err == nil && host == route.Host {
+				rs.AwaitingCommit++
+			}
+		}
+		for _, r := range receipts[ReceiptDestinationMaildirCommit] {
+			if r.SourceHost == route.Host && r.Kind == "" {
+				rs.Received++
+			}
+		}
+		statuses = append(statuses, rs)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Host < statuses[j].Host })
+	return statuses
+}
+
+func scanStatusCycles(root string, status *bridgeStatus) error {
+	dir := filepath.Join(root, filepath.FromSlash(cycleStateRelDir))
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read bridge state: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := fsq.ReadRegularNoFollow(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		var state cycleState
+		if err := json.Unmarshal(data, &state); err != nil {
+			return fmt.Errorf("parse %s: %w", filepath.Join(dir, entry.Name()), err)
+		}
+		switch state.Direction {
+		case "push":
+			status.LastPush = append(status.LastPush, state)
+		case "poll":
+			status.LastPoll = append(status.LastPoll, state)
+		}
+	}
+	return nil
+}
+
+func scanStatusPeers(root string, now time.Time, status *bridgeStatus) error {
+	entries, err := os.ReadDir(filepath.Join(root, "bridge", bridge.TrustedDirName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read trusted peers: %w", err)
+	}
+	for _, entry := range entries {
+		host := entry.Name()
+		if entry.IsDir() || fsq.ValidateHandle(host) != nil {
+			continue
+		}
+		peer := peerStatus{Host: host, Generations: []peerKeyStatus{}}
+		keys, err := bridge.LoadTrustedKeys(root, host)
+		if err != nil {
+			peer.Error = err.Error()
+			status.Attention = append(status.Attention, fmt.Sprintf("trusted peer %s: %v", host, err))
+			status.Peers = append(status.Peers, peer)
+			continue
+		}
+		valid := false
+		for _, key := range keys {
+			state := "valid"
+			if !key.ValidAt(now) {
+				state = "expired"
+			} else {
+				valid = true
+			}
+			pk := peerKeyStatus{Generation: key.Generation, State: state, Box: len(key.Box) > 0}
+			if !key.NotAfter.IsZero() {
+				pk.NotAfter = key.NotAfter.UTC().Format(time.RFC3339)
+			}
+			peer.Generations = append(peer.Generations, pk)
+		}
+		if !valid {
+			status.Attention = append(status.Attention, fmt.Sprintf("trusted peer %s has no valid generation", host))
+		}
+		status.Peers = append(status.Peers, peer)
+	}
+	return nil
+}
+
+func printStatus(status bridgeStatus) {
+	line := "host=" + valueOrDash(status.HostID) + " identity_generation=" + valueOrDash(status.Generation)
+	fmt.Println(line)
+	for _, spool := range status.Spools {
+		line := fmt.Sprintf("spool handle=%s pending=%d sent=%d refused=%d dest_sidecars=%d orphan_sidecars=%d",
+			spool.Handle, spool.Pending, spool.Sent, spool.Refused, spool.Sidecars, spool.OrphanSidecars)
+		if spool.OldestPending != "" {
+			line += " oldest_age=" + ageString(spool.OldestAge)
+		}
+		fmt.Println(line)
+	}
+	line = fmt.Sprintf("drop pending=%d applied=%d", status.Drop.Pending, status.Drop.Applied)
+	if status.Drop.Pending > 0 {
+		line += " oldest_age=" + ageString(status.Drop.OldestAge)
+	}
+	fmt.Println(line)
+	for _, transfer := range status.AwaitingCommit {
+		fmt.Printf("awaiting_commit transfer=%s message=%s dest=%s age=%s\n",
+			transfer.TransferID, valueOrDash(transfer.SourceMessageID), valueOrDash(transfer.DestAlias), ageString(transfer.Age))
+	}
+	for _, transfer := range status.Refused {
+		fmt.Printf("refused transfer=%s message=%s dest=%s age=%s\n",
+			transfer.TransferID, valueOrDash(transfer.SourceMessageID), valueOrDash(transfer.DestAlias), ageString(transfer.Age))
+	}
+	for _, transfer := range status.Uncertain {
+		fmt.Printf("uncertain transfer=%s message=%s dest=%s age=%s\n",
+			transfer.TransferID, valueOrDash(transfer.SourceMessageID), valueOrDash(transfer.DestAlias), ageString(transfer.Age))
+	}
+	for _, conflict := range status.Conflicts {
+		fmt.Printf("conflict path=%s detail=%q\n", conflict.Path, conflict.Detail)
+	}
+	for _, state := range status.LastPush {
+		fmt.Printf("last_push name=%s at=%s receipts=%d\n", state.Name, state.At, state.Receipts)
+	}
+	for _, state := range status.LastPoll {
+		fmt.Printf("last_poll name=%s at=%s receipts=%d\n", state.Name, state.At, state.Receipts)
+	}
+	for _, peer := range status.Peers {
+		if peer.Error != "" {
+			fmt.Printf("peer host=%s error=%q\n", peer.Host, peer.Error)
+			continue
+		}
+		for _, key := range peer.Generations {
+			line := fmt.Sprintf("peer host=%s generation=%s state=%s", peer.Host, key.Generation, key.State)
+			if key.NotAfter != "" {
+				line += " not_after=" + key.NotAfter
+			}
+			if key.Box {
+				line += " box=yes"
+			}
+			fmt.Println(line)
+		}
+	}
+	for _, route := range status.Routes {
+		fmt.Printf("route host=%s transport=%q dest=%s inbound=%t sent=%d awaiting_commit=%d received=%d\n",
+			route.Host, route.Transport, valueOrDash(strings.Join(route.DestAliases, ",")), route.Inbound,
+			route.Sent, route.AwaitingCommit, route.Received)
+	}
+	for _, reason := range status.Attention {
+		fmt.Printf("attention %s\n", reason)
+	}
+	fmt.Println("status=" + status.Status)
+}
+
+func valueOrDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+func ageString(seconds float64) string {
+	return (time.Duration(seconds) * time.Second).Truncate(time.Second).String()
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/bridge"
+)
+
+func TestStatusReportsUncertainTransfersAndExpiredPeers(t *testing.T) {
+	shared := t.TempDir()
+	senderRoot := newBridgeRoot(t, "codex")
+	receiverRoot := newBridgeRoot(t, "claude")
+	spool := filepath.Join(senderRoot, "bridge", "outbox", "codex", "new")
+	if err := os.MkdirAll(spool, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	message := testMessage(t, "msg-status", "thread-status", "codex", "watch me")
+	if err := os.WriteFile(filepath.Join(spool, "status.md"), message, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(spool, "gone.dest"), []byte("mac/claude\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sender := testCourier(t, Config{
+		Root: senderRoot, Transport: TransportDir, SharedDir: shared, SourceHost: "grok-host", SourceHandle: "codex",
+		DestAlias: "mac/claude", AllowedDestAliases: []string{"mac/claude"},
+	})
+	receiver := testCourier(t, Config{
+		Root: receiverRoot, Transport: TransportDir, SharedDir: shared, DestAlias: "mac/claude",
+		AllowedDestAliases: []string{"mac/claude"}, AllowedSourceHosts: []string{"grok-host"},
+	})
+	receiptDir := filepath.Join(senderRoot, "bridge", "receipts")
+
+	// Posted to the shared directory, not yet acked: the spool item stays.
+	if _, err := sender.RunOnce(context.Background(), ModePush); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	status, err := collectStatus(senderRoot, receiptDir, nil, time.Hour, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Status != "ok" || status.HostID != "grok-host" {
+		t.Fatalf("fresh status = %s host %q (%v), want ok", status.Status, status.HostID, status.Attention)
+	}
+	if len(status.Spools) != 1 || status.Spools[0].Pending != 1 || status.Spools[0].OrphanSidecars != 1 {
+		t.Fatalf("spools = %+v, want one pending message and one orphan sidecar", status.Spools)
+	}
+	if len(status.Uncertain) != 1 || status.Uncertain[0].SourceMessageID != "msg-status" {
+		t.Fatalf("uncertain = %+v, want the posted transfer", status.Uncertain)
+	}
+	if len(status.LastPush) != 1 || status.LastPush[0].Name != "codex" || status.LastPush[0].Receipts != 1 {
+		t.Fatalf("last push = %+v", status.LastPush)
+	}
+	late, err := collectStatus(senderRoot, receiptDir, nil, time.Hour, now.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if late.Status != "attention" || len(late.Attention) != 2 {
+		t.Fatalf("stale status = %s %v, want the old spool item and uncertain transfer", late.Status, late.Attention)
+	}
+
+	if _, err := receiver.PollOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sender.RunOnce(context.Background(), ModePush); err != nil {
+		t.Fatal(err)
+	}
+	status, err = collectStatus(senderRoot, receiptDir, nil, time.Hour, now.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Status != "ok" || status.Spools[0].Pending != 0 || status.Spools[0].Sent != 1 || len(status.Uncertain) != 0 {
+		t.Fatalf("archived status = %+v", status)
+	}
+	// The sender only learns about the commit through a forwarded receipt.
+	if len(status.AwaitingCommit) != 1 {
+		t.Fatalf("awaiting commit = %+v, want the archived transfer", status.AwaitingCommit)
+	}
+
+	expired := testHostKey("mac", "1")
+	if err := bridge.WriteTrustedKeys(senderRoot, "mac", []bridge.TrustedKey{{
+		Generation: "1", Public: expired.Public(), NotAfter: now.Add(-time.Minute),
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	status, err = collectStatus(senderRoot, receiptDir, nil, time.Hour, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Status != "attention" || len(status.Peers) != 1 || status.Peers[0].Generations[0].State != "expired" {
+		t.Fatalf("peer status = %s %+v, want an expired peer flagged", status.Status, status.Peers)
+	}
+}
+
+func TestStatusFlagsReceiptThatDisagreesWithFilename(t *testing.T) {
+	root := newBridgeRoot(t, "claude")
+	receiptDir := filepath.Join(root, "bridge", "receipts")
+	if err := os.MkdirAll(receiptDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	data := []byte(`{"stage":"transport_accepted","transfer_id":"xfer-a","payload_sha256":"00"}`)
+	if err := os.WriteFile(filepath.Join(receiptDir, receiptFilename("xfer-b", ReceiptTransportAccepted)), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	status, err := collectStatus(root, receiptDir, nil, time.Hour, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Status != "attention" || len(status.Conflicts) != 1 {
+		t.Fatalf("status = %s conflicts %+v, want one conflict", status.Status, status.Conflicts)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/bridge"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+)
+
+// runTrust manages bridge/trusted/<host>. Every change is appended to
+// bridge/trust-audit.jsonl so rotation and revocation leave a record instead
+// of a hand-copied file.
+func runTrust(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("trust subcommand is required (add, revoke, or list)")
+	}
+	switch args[0] {
+	case "add":
+		return runTrustAdd(args[1:])
+	case "revoke":
+		return runTrustRevoke(args[1:])
+	case "list":
+		return runTrustList(args[1:])
+	default:
+		return fmt.Errorf("unknown trust subcommand %q", args[0])
+	}
+}
+
+func runTrustAdd(args []string) error {
+	fs := flag.NewFlagSet("amq-bridge trust add", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	root := fs.String("root", os.Getenv("AM_ROOT"), "local AMQ root")
+	host := fs.String("host", "", "peer host-id")
+	generation := fs.String("generation", "", "peer key generation label")
+	public := fs.String("public", "", "peer Ed25519 public key (hex, from identity public)")
+	notAfter := fs.String("not-after", "", "stop trusting this generation at an RFC3339 time or after a duration")
+	box := fs.String("box", "", "peer X25519 box key (hex); when set, envelopes to the peer are encrypted")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if strings.TrimSpace(*root) == "" {
+		return fmt.Errorf("bridge root is required")
+	}
+	if *host == "" || *generation == "" || *public == "" {
+		return fmt.Errorf("--host, --generation, and --public are required")
+	}
+	pub, err := hex.DecodeString(strings.TrimSpace(*public))
+	if err != nil {
+		return fmt.Errorf("--public: %w", err)
+	}
+	now := time.Now()
+	key := bridge.TrustedKey{Generation: *generation, Public: pub}
+	if *box != "" {
+		if key.Box, err = hex.DecodeString(strings.TrimSpace(*box)); err != nil {
+			return fmt.Errorf("--box: %w", err)
+		}
+	}
+	if *notAfter != "" {
+		if key.NotAfter, err = parseNotAfter(*notAfter, now); err != nil {
+			return err
+		}
+	}
+	keys, err := loadTrustedKeysIfPresent(*root, *host)
+	if err != nil {
+		return err
+	}
+	// Re-adding a known key replaces its deadline, and its box key when --box
+	// is given; a new generation is listed first as the newest.
+	updated := make([]bridge.TrustedKey, 0, len(keys)+1)
+	found := false
+	for _, existing := range keys {
+		if existing.Generation == key.Generation {
+			if !existing.Public.Equal(key.Public) {
+				return fmt.Errorf("host %s generation %s is already trusted with a different public key; revoke it first", *host, key.Generation)
+			}
+			if key.Box == nil {
+				key.Box = existing.Box
+			}
+			existing, found = key, true
+		}
+		updated = append(updated, existing)
+	}
+	if !found {
+		updated = append([]bridge.TrustedKey{key}, updated...)
+	}
+	if err := bridge.WriteTrustedKeys(*root, *host, updated); err != nil {
+		return err
+	}
+	entry := bridge.TrustAuditEntry{Action: "add", Host: *host, Generation: key.Generation, Public: hex.EncodeToString(key.Public), Box: hex.EncodeToString(key.Box)}
+	if !key.NotAfter.IsZero() {
+		entry.NotAfter = key.NotAfter.UTC().Format(time.RFC3339)
+	}
+	if err := bridge.AppendTrustAudit(*root, entry); err != nil {
+		return err
+	}
+	printTrustedKey(*host, key, now)
+	return nil
+}
+
+func runTrustRevoke(args []string) error {
+	fs := flag.NewFlagSet("amq-bridge trust revoke", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	root := fs.String("root", os.Getenv("AM_ROOT"), "local AMQ root")
+	host := fs.String("host", "", "peer host-id")
+	generation := fs.String("generation", "", "revoke only this generation (default: every generation of --host)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if strings.TrimSpace(*root) == "" {
+		return fmt.Errorf("bridge root is required")
+	}
+	if *host == "" {
+		return fmt.Errorf("--host is required")
+	}
+	keys, err := loadTrustedKeysIfPresent(*root, *host)
+	if err != nil {
+		return err
+	}
+	var kept, revoked []bridge.TrustedKey
+	for _, key := range keys {
+		if *generation == "" || key.Generation == *generation {
+			revoked = append(revoked, key)
+		} else {
+			kept = append(kept, key)
+		}
+	}
+	if len(revoked) == 0 {
+		if *generation == "" {
+			return fmt.Errorf("host %s is not trusted", *host)
+		}
+		return fmt.Errorf("host %s generation %s is not trusted", *host, *generation)
+	}
+	if err := bridge.WriteTrustedKeys(*root, *host, kept); err != nil {
+		return err
+	}
+	for _, key := range revoked {
+		entry := bridge.TrustAuditEntry{Action: "revoke", Host: *host, Generation: key.Generation, Public: hex.EncodeToString(key.Public)}
+		if err := bridge.AppendTrustAudit(*root, entry); err != nil {
+			return err
+		}
+		fmt.Printf("host=%s generation=%s revoked\n", *host, key.Generation)
+	}
+	return nil
+}
+
+func runTrustList(args []string) error {
+	fs := flag.NewFlagSet("amq-bridge trust list", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	root := fs.String("root", os.Getenv("AM_ROOT"), "local AMQ root")
+	host := fs.String("host", "", "list only this peer host-id")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if strings.TrimSpace(*root) == "" {
+		return fmt.Errorf("bridge root is required")
+	}
+	hosts := []string{*host}
+	if *host == "" {
+		entries, err := os.ReadDir(filepath.Join(*root, "bridge", bridge.TrustedDirName))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		hosts = hosts[:0]
+		for _, entry := range entries {
+			if fsq.ValidateHandle(entry.Name()) == nil {
+				hosts = append(hosts, entry.Name())
+			}
+		}
+		sort.Strings(hosts)
+	}
+	now := time.Now()
+	for _, h := range hosts {
+		keys, err := bridge.LoadTrustedKeys(*root, h)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			printTrustedKey(h, key, now)
+		}
+	}
+	return nil
+}
+
+func loadTrustedKeysIfPresent(root, host string) ([]bridge.TrustedKey, error) {
+	if err := fsq.ValidateHandle(host); err != nil {
+		return nil, fmt.Errorf("--host: %w", err)
+	}
+	if _, err := os.Lstat(bridge.TrustedPath(root, host)); os.IsNotExist(err) {
+		return nil, nil
+	}
+	return bridge.LoadTrustedKeys(root, host)
+}
+
+// parseNotAfter accepts an absolute RFC3339 deadline or a duration from now.
+func parseNotAfter(raw string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(raw); err == nil {
+		if d <= 0 {
+			return time.Time{}, fmt.Errorf("--not-after duration must be positive")
+		}
+		return now.Add(d).UTC().Truncate(time.Second), nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("--not-after must be RFC3339 or a duration: %q", raw)
+	}
+	return t, nil
+}
+
+func printTrustedKey(host string, key bridge.TrustedKey, now time.Time) {
+	state := "valid"
+	if !key.ValidAt(now) {
+		state = "expired"
+	}
+	line := fmt.Sprintf("host=%s generation=%s public=%x", host, key.Generation, key.Public)
+	if !key.NotAfter.IsZero() {
+		line += " not_after=" + key.NotAfter.UTC().Format(time.RFC3339)
+	}
+	line += boxField(key.Box)
+	fmt.Println(line + " state=" + state)
+}
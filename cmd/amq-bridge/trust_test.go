@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/bridge"
+)
+
+func TestTrustAddRevokeAcrossRotation(t *testing.T) {
+	senderRoot := newBridgeRoot(t, "codex")
+	ensureHostID(t, senderRoot, "grok-host")
+	ensureIdentity(t, senderRoot, "grok-host")
+	receiverRoot := newBridgeRoot(t, "claude")
+	ensureTrusted(t, receiverRoot, "grok-host")
+
+	// An envelope signed with generation 1 is still in the rendezvous when
+	// the sender rotates.
+	inFlight := testEnvelope(t, "xfer-in-flight")
+	if err := runIdentityRotate([]string{"--root", senderRoot, "--overlap", "1h"}); err != nil {
+		t.Fatal(err)
+	}
+	active, retired, err := bridge.LoadIdentityKeys(senderRoot)
+	if err != nil || active.Generation != "2" || len(retired) != 1 {
+		t.Fatalf("rotated identity = %+v %+v %v, want generation 2 with 1 retired", active, retired, err)
+	}
+	if err := runTrustAdd([]string{"--root", receiverRoot, "--host", "grok-host", "--generation", "2",
+		"--public", fmt.Sprintf("%x", active.Public())}); err != nil {
+		t.Fatal(err)
+	}
+	if err := runTrustAdd([]string{"--root", receiverRoot, "--host", "grok-host", "--generation", "1",
+		"--public", fmt.Sprintf("%x", retired[0].Public), "--not-after", retired[0].NotAfter.Format(time.RFC3339)}); err != nil {
+		t.Fatal(err)
+	}
+	other := testHostKey("someone-else", "2")
+	if err := runTrustAdd([]string{"--root", receiverRoot, "--host", "grok-host", "--generation", "2",
+		"--public", fmt.Sprintf("%x", other.Public())}); err == nil || !strings.Contains(err.Error(), "different public key") {
+		t.Fatalf("replacing a trusted generation's key = %v, want refusal", err)
+	}
+	keys, err := bridge.LoadTrustedKeys(receiverRoot, "grok-host")
+	if err != nil || len(keys) != 2 || keys[0].Generation != "2" || keys[1].NotAfter.IsZero() {
+		t.Fatalf("trusted keys = %+v %v, want generation 2 then 1 with a deadline", keys, err)
+	}
+
+	fresh := testEnvelope(t, "xfer-fresh")
+	fresh.KeyGeneration = active.Generation
+	if err := bridge.SignEnvelope(&fresh, active); err != nil {
+		t.Fatal(err)
+	}
+	poll := func(envs ...bridge.Envelope) (PollResult, error) {
+		t.Helper()
+		var raws []json.RawMessage
+		for _, env := range envs {
+			raw, err := bridge.MarshalEnvelope(env)
+			if err != nil {
+				t.Fatal(err)
+			}
+			raws = append(raws, raw)
+		}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				writeJSON(w, http.StatusOK, pollResponse{Envelopes: raws})
+				return
+			}
+			var ack ackRequest
+			_ = json.NewDecoder(r.Body).Decode(&ack)
+			writeJSON(w, http.StatusOK, transportResponse{Receipt: ack.Receipt})
+		}))
+		defer server.Close()
+		courier := testCourier(t, Config{
+			Root: receiverRoot, RendezvousURL: server.URL, DestAlias: "mac/claude",
+			AllowedDestAliases: []string{"mac/claude"}, AllowedSourceHosts: []string{"grok-host"},
+		})
+		return courier.PollOnce(context.Background())
+	}
+	if result, err := poll(inFlight, fresh); err != nil || len(result.Receipts) != 2 {
+		t.Fatalf("poll across the overlap = %+v, %v; want both generations accepted", result, err)
+	}
+
+	if err := runTrustRevoke([]string{"--root", receiverRoot, "--host", "grok-host", "--generation", "1"}); err != nil {
+		t.Fatal(err)
+	}
+	late := testEnvelope(t, "xfer-late")
+	if _, err := poll(late); err == nil || !strings.Contains(err.Error(), "not a trusted generation") {
+		t.Fatalf("poll after revoke = %v, want the revoked generation refused", err)
+	}
+
+	file, err := os.Open(bridge.TrustAuditPath(receiverRoot))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var actions []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry bridge.TrustAuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatal(err)
+		}
+		actions = append(actions, entry.Action+":"+entry.Generation)
+	}
+	if got := strings.Join(actions, ","); got != "add:2,add:1,revoke:1" {
+		t.Fatalf("trust audit = %s, want each successful change recorded", got)
+	}
+}
+
+func TestTrustAddWithoutBoxKeepsTheBoxKey(t *testing.T) {
+	root := newBridgeRoot(t, "claude")
+	peer := testHostKey("grok-host", "1")
+	box, err := peer.BoxKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"--root", root, "--host", "grok-host", "--generation", "1", "--public", fmt.Sprintf("%x", peer.Public())}
+	if err := runTrustAdd(append(args, "--box", fmt.Sprintf("%x", box.PublicKey().Bytes()))); err != nil {
+		t.Fatal(err)
+	}
+	// Extending the deadline must not silently downgrade the peer to v1.
+	if err := runTrustAdd(append(args, "--not-after", "24h")); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := bridge.LoadTrustedKeys(root, "grok-host")
+	if err != nil || len(keys) != 1 || keys[0].NotAfter.IsZero() || string(keys[0].Box) != string(box.PublicKey().Bytes()) {
+		t.Fatalf("trusted keys = %+v %v, want the box key kept with the new deadline", keys, err)
+	}
+}
@@ -0,0 +1,132 @@
+package acl
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/avivsinai/agent-message-queue/internal/config"
+	"github.com/avivsinai/agent-message-queue/internal/format"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+)
+
+// Denial describes why one recipient refused a delivery.
+type Denial struct {
+	Agent  string `json:"agent"`
+	Rule   string `json:"rule"`
+	Reason string `json:"reason"`
+}
+
+// DeniedError reports that one or more recipients' policies refuse a delivery.
+type DeniedError struct {
+	Denials []Denial
+}
+
+func (e *DeniedError) Error() string {
+	parts := make([]string, 0, len(e.Denials))
+	for _, d := range e.Denials {
+		parts = append(parts, fmt.Sprintf("%s (%s: %s)", d.Agent, d.Rule, d.Reason))
+	}
+	return "delivery refused by recipient ACL: " + strings.Join(parts, ", ")
+}
+
+// Load reads the ACL configuration from root. A missing config file or acl
+// section yields nil, which disables enforcement.
+func Load(root *fsq.DeliveryRoot) (*config.ACLConfig, error) {
+	cfg, err := config.ReadConfig(root)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read acl config: %w", err)
+	}
+	return cfg.ACL, nil
+}
+
+// Check evaluates header against the policy of every recipient in header.To.
+// It returns a *DeniedError listing each refusing recipient.
+func Check(cfg *config.ACLConfig, header format.Header) error {
+	if cfg == nil {
+		return nil
+	}
+	var denials []Denial
+	for _, agent := range header.To {
+		policy, ok := cfg.For(agent)
+		if !ok {
+			continue
+		}
+		if denial, denied := Evaluate(policy, agent, header); denied {
+			denials = append(denials, denial)
+		}
+	}
+	if len(denials) > 0 {
+		return &DeniedError{Denials: denials}
+	}
+	return nil
+}
+
+// Evaluate applies one recipient policy to header.
+func Evaluate(policy config.ACLPolicy, agent string, header format.Header) (Denial, bool) {
+	for i, rule := range policy.Deny {
+		if Matches(rule, header) {
+			return Denial{
+				Agent:  agent,
+				Rule:   fmt.Sprintf("deny[%d]", i),
+				Reason: fmt.Sprintf("%s matches a deny rule", describeSender(header)),
+			}, true
+		}
+	}
+	if len(policy.Allow) == 0 {
+		return Denial{}, false
+	}
+	for _, rule := range policy.Allow {
+		if Matches(rule, header) {
+			return Denial{}, false
+		}
+	}
+	return Denial{
+		Agent:  agent,
+		Rule:   "allow",
+		Reason: fmt.Sprintf("%s matches no allow rule", describeSender(header)),
+	}, true
+}
+
+// Matches reports whether every non-empty field of rule matches header.
+func Matches(rule config.ACLRule, header format.Header) bool {
+	project := header.FromProject
+	if project == "" {
+		project = config.ACLLocalProject
+	}
+	priority := header.Priority
+	if priority == "" {
+		priority = format.PriorityNormal
+	}
+	return matchField(rule.From, header.From) &&
+		matchField(rule.Projects, project) &&
+		matchField(rule.Kinds, header.Kind) &&
+		matchField(rule.Priorities, priority)
+}
+
+func matchField(values []string, got string) bool {
+	return len(values) == 0 || slices.Contains(values, got)
+}
+
+func describeSender(header format.Header) string {
+	sender := header.From
+	if header.FromProject != "" {
+		sender += "@" + header.FromProject
+	}
+	details := []string{}
+	if header.Kind != "" {
+		details = append(details, "kind "+header.Kind)
+	}
+	if header.Priority != "" {
+		details = append(details, "priority "+header.Priority)
+	}
+	if len(details) == 0 {
+		return sender
+	}
+	return sender + " (" + strings.Join(details, ", ") + ")"
+}
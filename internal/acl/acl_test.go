@@ -0,0 +1,114 @@
+package acl
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/avivsinai/agent-message-queue/internal/config"
+	"github.com/avivsinai/agent-message-queue/internal/format"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+)
+
+func opsOnlyFromClaudeAndInfra() *config.ACLConfig {
+	return &config.ACLConfig{
+		Agents: map[string]config.ACLPolicy{
+			"ops": {
+				Allow: []config.ACLRule{
+					{From: []string{"claude"}, Projects: []string{config.ACLLocalProject}},
+					{Projects: []string{"infra"}},
+				},
+				Deny: []config.ACLRule{
+					{Projects: []string{"infra"}, Priorities: []string{format.PriorityUrgent}},
+				},
+			},
+		},
+	}
+}
+
+func TestCheckAppliesAllowAndDenyRules(t *testing.T) {
+	cfg := opsOnlyFromClaudeAndInfra()
+	tests := []struct {
+		name   string
+		header format.Header
+		denied string
+	}{
+		{name: "local claude", header: format.Header{From: "claude", To: []string{"ops"}}},
+		{name: "infra peer", header: format.Header{From: "codex", FromProject: "infra", To: []string{"ops"}}},
+		{name: "local codex", header: format.Header{From: "codex", To: []string{"ops"}}, denied: "allow"},
+		{name: "remote claude", header: format.Header{From: "claude", FromProject: "web", To: []string{"ops"}}, denied: "allow"},
+		{name: "urgent infra", header: format.Header{From: "codex", FromProject: "infra", Priority: format.PriorityUrgent, To: []string{"ops"}}, denied: "deny[0]"},
+		{name: "unrestricted recipient", header: format.Header{From: "codex", To: []string{"claude"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Check(cfg, tt.header)
+			if tt.denied == "" {
+				if err != nil {
+					t.Fatalf("Check = %v, want allowed", err)
+				}
+				return
+			}
+			var denied *DeniedError
+			if !errors.As(err, &denied) {
+				t.Fatalf("Check = %v, want *DeniedError", err)
+			}
+			if len(denied.Denials) != 1 || denied.Denials[0].Agent != "ops" || denied.Denials[0].Rule != tt.denied {
+				t.Fatalf("denials = %+v, want ops %s", denied.Denials, tt.denied)
+			}
+		})
+	}
+}
+
+func TestDefaultPolicyAppliesWithoutAgentEntry(t *testing.T) {
+	cfg := &config.ACLConfig{
+		Default: &config.ACLPolicy{Deny: []config.ACLRule{{Kinds: []string{format.KindTodo}}}},
+		Agents:  map[string]config.ACLPolicy{"lead": {}},
+	}
+	todo := format.Header{From: "codex", Kind: format.KindTodo, To: []string{"claude", "lead"}}
+	var denied *DeniedError
+	if err := Check(cfg, todo); !errors.As(err, &denied) || len(denied.Denials) != 1 || denied.Denials[0].Agent != "claude" {
+		t.Fatalf("Check = %v, want only claude refused", err)
+	}
+	if err := Check(nil, todo); err != nil {
+		t.Fatalf("nil config Check = %v", err)
+	}
+}
+
+func TestDeliverToInboxesRefusesBeforeWriting(t *testing.T) {
+	root := t.TempDir()
+	if err := fsq.EnsureAgentDirs(root, "ops"); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.WriteConfig(filepath.Join(root, "meta", "config.json"), config.Config{ACL: opsOnlyFromClaudeAndInfra()}, true); err != nil {
+		t.Fatal(err)
+	}
+	identity, err := fsq.SnapshotDeliveryRoot(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deliveryRoot, err := fsq.OpenDeliveryRoot(root, identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = deliveryRoot.Close() })
+
+	// header.To is replaced by the actual recipients, so a copy addressed
+	// elsewhere is still checked against the inbox it lands in.
+	header := format.Header{From: "codex", To: []string{"claude"}}
+	var denied *DeniedError
+	if _, err := DeliverToInboxes(deliveryRoot, deliveryRoot, header, []string{"ops"}, "m1.md", []byte("x")); !errors.As(err, &denied) {
+		t.Fatalf("DeliverToInboxes = %v, want *DeniedError", err)
+	}
+	if _, err := DeliverToExistingInbox(deliveryRoot, deliveryRoot, header, "ops", "m1.md", []byte("x")); !errors.As(err, &denied) {
+		t.Fatalf("DeliverToExistingInbox = %v, want *DeniedError", err)
+	}
+	if entries, _ := os.ReadDir(fsq.AgentInboxNew(root, "ops")); len(entries) != 0 {
+		t.Fatalf("refused delivery wrote %d inbox entries", len(entries))
+	}
+	header.From = "claude"
+	if _, err := DeliverToInboxes(deliveryRoot, deliveryRoot, header, []string{"ops"}, "m2.md", []byte("x")); err != nil {
+		t.Fatalf("allowed DeliverToInboxes = %v", err)
+	}
+}
@@ -0,0 +1,38 @@
+package acl
+
+import (
+	"github.com/avivsinai/agent-message-queue/internal/format"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+)
+
+// Authorize applies the recipient ACLs configured in configFS to header. A
+// refusal is a *DeniedError.
+func Authorize(configFS *fsq.DeliveryRoot, header format.Header) error {
+	cfg, err := Load(configFS)
+	if err != nil {
+		return err
+	}
+	return Check(cfg, header)
+}
+
+// DeliverToInboxes is the ACL-checked form of fsq.DeliverToInboxes: header is
+// authorized for every recipient before data is written to any inbox in
+// deliveryFS. Every local delivery goes through here or
+// DeliverToExistingInbox, so no path skips the recipient's policy.
+func DeliverToInboxes(configFS, deliveryFS *fsq.DeliveryRoot, header format.Header, recipients []string, filename string, data []byte) (map[string]string, error) {
+	header.To = recipients
+	if err := Authorize(configFS, header); err != nil {
+		return nil, err
+	}
+	return fsq.DeliverToInboxes(deliveryFS, recipients, filename, data)
+}
+
+// DeliverToExistingInbox is the ACL-checked form of
+// fsq.DeliverToExistingInbox for one recipient.
+func DeliverToExistingInbox(configFS, deliveryFS *fsq.DeliveryRoot, header format.Header, agent, filename string, data []byte) (string, error) {
+	header.To = []string{agent}
+	if err := Authorize(configFS, header); err != nil {
+		return "", err
+	}
+	return fsq.DeliverToExistingInbox(deliveryFS, agent, filename, data)
+}
@@ -0,0 +1,7 @@
+// Package acl enforces the per-recipient delivery policies configured under
+// "acl" in meta/config.json. Rules match on sender handle, sender project
+// (from_project), kind, and priority. Checks run before delivery so a refused
+// message never reaches an inbox or the DLQ. DeliverToInboxes and
+// DeliverToExistingInbox wrap the fsq delivery primitives with that check;
+// every local delivery path uses them.
+package acl
@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/avivsinai/agent-message-queue/internal/fsq"
 )
@@ -24,8 +25,15 @@ const (
 	EnvSession    = "AM_SESSION"
 	EnvRootID     = "AM_ROOT_ID"
 	EnvBaseRootID = "AM_BASE_ROOT_ID"
+
+	// EnvReplyTimeout opts into waiting for the recipient's reply inside
+	// session/prompt. Unset keeps the queue-and-return behavior.
+	EnvReplyTimeout = "AMQ_ACP_REPLY_TIMEOUT"
 )
 
+// MaxReplyTimeout bounds how long one prompt turn may wait for a reply.
+const MaxReplyTimeout = time.Hour
+
 // ContextError marks a fail-closed routing refusal so the caller can report the
 // repository's context-mismatch exit code.
 type ContextError struct {
@@ -45,6 +53,10 @@ type Config struct {
 	Root string
 	Me   string
 	To   string
+
+	// ReplyTimeout, when positive, makes session/prompt wait this long for
+	// replies that reference the delivered prompt.
+	ReplyTimeout time.Duration
 }
 
 // LoadConfig resolves the routing context from the environment. An absent root,
@@ -72,7 +84,28 @@ func LoadConfig() (Config, error) {
 	if err := verifySessionPin(root); err != nil {
 		return Config{}, err
 	}
-	return Config{Root: root, Me: me, To: to}, nil
+	replyTimeout, err := loadReplyTimeout()
+	if err != nil {
+		return Config{}, err
+	}
+	return Config{Root: root, Me: me, To: to, ReplyTimeout: replyTimeout}, nil
+}
+
+// loadReplyTimeout parses the opt-in reply wait. It is not routing, so a bad
+// value is an ordinary configuration error rather than a context refusal.
+func loadReplyTimeout() (time.Duration, error) {
+	raw := strings.TrimSpace(os.Getenv(EnvReplyTimeout))
+	if raw == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s=%q: %v", EnvReplyTimeout, raw, err)
+	}
+	if timeout <= 0 || timeout > MaxReplyTimeout {
+		return 0, fmt.Errorf("invalid %s=%q: must be positive and at most %s", EnvReplyTimeout, raw, MaxReplyTimeout)
+	}
+	return timeout, nil
 }
 
 // verifySessionPin authenticates an inherited pin against the target root. Pin
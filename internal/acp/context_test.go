@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/avivsinai/agent-message-queue/internal/fsq"
 )
@@ -13,7 +14,7 @@ import (
 // declares its own complete environment.
 func clearContextEnv(t *testing.T) {
 	t.Helper()
-	for _, name := range []string{EnvRoot, EnvMe, EnvTo, EnvBaseRoot, EnvSession, EnvRootID, EnvBaseRootID} {
+	for _, name := range []string{EnvRoot, EnvMe, EnvTo, EnvBaseRoot, EnvSession, EnvRootID, EnvBaseRootID, EnvReplyTimeout} {
 		if err := os.Unsetenv(name); err != nil {
 			t.Fatalf("unset %s: %v", name, err)
 		}
@@ -104,6 +105,33 @@ func TestLoadConfigAcceptsUnpinnedRoot(t *testing.T) {
 	}
 }
 
+func TestLoadConfigParsesReplyTimeout(t *testing.T) {
+	clearContextEnv(t)
+	t.Setenv(EnvRoot, t.TempDir())
+	t.Setenv(EnvMe, testMe)
+	t.Setenv(EnvTo, testTo)
+
+	t.Setenv(EnvReplyTimeout, "90s")
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.ReplyTimeout != 90*time.Second {
+		t.Fatalf("ReplyTimeout = %s, want 90s", cfg.ReplyTimeout)
+	}
+
+	// The wait is bounded: zero, negative, unparsable, and unbounded values
+	// are refused instead of silently meaning "forever".
+	for _, value := range []string{"0s", "-1m", "soon", "2h"} {
+		t.Setenv(EnvReplyTimeout, value)
+		err := mustLoadConfigError(t)
+		var contextErr *ContextError
+		if errors.As(err, &contextErr) {
+			t.Fatalf("%s=%q refused as a context mismatch: %v", EnvReplyTimeout, value, err)
+		}
+	}
+}
+
 // TestLoadConfigRefusesRootThatContradictsSessionPin is the load-bearing
 // negative case. An implementation that simply trusted AM_ROOT would happily
 // deliver into a foreign tree while the shell was pinned somewhere else.
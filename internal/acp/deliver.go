@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/avivsinai/agent-message-queue/internal/acl"
 	"github.com/avivsinai/agent-message-queue/internal/format"
 	"github.com/avivsinai/agent-message-queue/internal/fsq"
 )
@@ -15,6 +16,11 @@ import (
 // reading a mailbox can tell where the message came from.
 const PromptSubject = "ACP prompt"
 
+// SessionLabelPrefix starts the label that stamps a prompt with the ACP
+// session that sent it. Every session shares the peer thread, so the label is
+// what session/load replays by.
+const SessionLabelPrefix = "acp-session:"
+
 // Delivery is the durable outcome of one prompt turn. The message is queued in
 // the recipient's inbox; nothing here proves the recipient consumed it.
 type Delivery struct {
@@ -35,8 +41,9 @@ type Delivery struct {
 // ordinary Maildir tmp -> new delivery, so amq list and amq drain observe it
 // exactly like any other message. Recipient and root always come from Config;
 // prompt text, including a Buzz [Context] section, is never treated as
-// authentication or a routing override.
-func DeliverPrompt(cfg Config, body, eventID string) (Delivery, error) {
+// authentication or a routing override. A non-empty sessionID is stamped on
+// the message as a SessionLabelPrefix label.
+func DeliverPrompt(cfg Config, sessionID, body, eventID string) (Delivery, error) {
 	body = strings.TrimRight(body, "\n")
 	if strings.TrimSpace(body) == "" {
 		return Delivery{}, fmt.Errorf("prompt contains no text content")
@@ -66,6 +73,9 @@ func DeliverPrompt(cfg Config, body, eventID string) (Delivery, error) {
 	}
 	thread := p2pThread(cfg.Me, cfg.To)
 	labels := []string{"acp"}
+	if sessionID != "" {
+		labels = append(labels, SessionLabelPrefix+sessionID)
+	}
 	if eventID != "" {
 		labels = append(labels, "nostr:"+eventID)
 	}
@@ -100,7 +110,7 @@ func DeliverPrompt(cfg Config, body, eventID string) (Delivery, error) {
 	}
 	defer func() { _ = root.Close() }()
 
-	_, err = fsq.DeliverToInboxes(root, []string{cfg.To}, id+".md", data)
+	_, err = acl.DeliverToInboxes(root, root, message.Header, []string{cfg.To}, id+".md", data)
 	egress := EgressConfirmed
 	if err != nil {
 		var uncertain *fsq.CommittedDurabilityError
@@ -0,0 +1,187 @@
+package acp
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/format"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+)
+
+// StopReasonCancelled ends a prompt turn whose reply wait was aborted by
+// session/cancel or by the client closing stdin.
+const StopReasonCancelled = "cancelled"
+
+// Reply wait outcomes reported in _meta.amq.reply. None of them says the
+// recipient finished its work; they only describe what reached the inbox.
+const (
+	ReplyReceived  = "received"
+	ReplyTimedOut  = "timed_out"
+	ReplyCancelled = "cancelled"
+)
+
+// defaultReplyPoll is how often the sender's inbox is rescanned while a prompt
+// turn waits. Only file names not seen by an earlier scan are read.
+const defaultReplyPoll = 250 * time.Millisecond
+
+// Reply is one message that answers a delivered prompt.
+type Reply struct {
+	MessageID string
+	From      string
+	Created   string
+	Body      string
+}
+
+// FindReplies lists messages in the sender's inbox that belong to thread and
+// reference messageID, oldest first. The scan is read-only: replies stay in
+// new or cur exactly where the mailbox owner expects them, because this
+// companion never drains a mailbox. Unreadable or malformed files are skipped;
+// they are not replies this companion can vouch for.
+func FindReplies(cfg Config, thread, messageID string) ([]Reply, error) {
+	scanner, err := newReplyScanner(cfg, thread, messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = scanner.close() }()
+	return scanner.scan()
+}
+
+// replyScanner reads the sender's inbox through one pinned delivery root for
+// the length of a reply wait. It remembers every file name it has looked at,
+// so each poll opens only messages that arrived since the last one; a reply
+// moved from new to cur keeps its name and is not read twice.
+type replyScanner struct {
+	root      *fsq.DeliveryRoot
+	dirs      []string
+	thread    string
+	messageID string
+	checked   map[string]bool
+}
+
+func newReplyScanner(cfg Config, thread, messageID string) (*replyScanner, error) {
+	identity, err := fsq.SnapshotDeliveryRoot(cfg.Root)
+	if err != nil {
+		return nil, err
+	}
+	root, err := fsq.OpenDeliveryRoot(cfg.Root, identity)
+	if err != nil {
+		return nil, err
+	}
+	inbox := filepath.Join("agents", cfg.Me, "inbox")
+	return &replyScanner{
+		root:      root,
+		dirs:      []string{filepath.Join(inbox, "new"), filepath.Join(inbox, "cur")},
+		thread:    thread,
+		messageID: messageID,
+		checked:   make(map[string]bool),
+	}, nil
+}
+
+func (s *replyScanner) close() error {
+	return s.root.Close()
+}
+
+// scan returns the replies among files not seen by an earlier scan, oldest
+// first.
+func (s *replyScanner) scan() ([]Reply, error) {
+	var replies []Reply
+	for _, dir := range s.dirs {
+		entries, err := s.root.ReadDir(dir)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if s.checked[name] || !entry.Type().IsRegular() || !strings.HasSuffix(name, ".md") {
+				continue
+			}
+			message, err := format.ReadMessageDeliveryRoot(s.root, filepath.Join(dir, name))
+			if errors.Is(err, os.ErrNotExist) {
+				// Moved from new to cur since ReadDir; cur is scanned next
+				// or on the following poll.
+				continue
+			}
+			s.checked[name] = true
+			if err != nil {
+				continue
+			}
+			header := message.Header
+			if header.Thread != s.thread || !slices.Contains(header.Refs, s.messageID) {
+				continue
+			}
+			replies = append(replies, Reply{
+				MessageID: header.ID,
+				From:      header.From,
+				Created:   header.Created,
+				Body:      strings.TrimRight(message.Body, "\n"),
+			})
+		}
+	}
+	sort.SliceStable(replies, func(i, j int) bool {
+		if replies[i].Created != replies[j].Created {
+			return replies[i].Created < replies[j].Created
+		}
+		return replies[i].MessageID < replies[j].MessageID
+	})
+	return replies, nil
+}
+
+// awaitReplies polls for replies to one delivery until at least one has been
+// streamed and a further scan finds nothing new, the timeout expires, or ctx
+// is cancelled. Each reply is handed to stream once, in order.
+func awaitReplies(ctx context.Context, cfg Config, delivery Delivery, poll time.Duration, stream func(Reply) error) (string, int, error) {
+	deadline := time.NewTimer(cfg.ReplyTimeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+
+	scanner, err := newReplyScanner(cfg, delivery.Thread, delivery.MessageID)
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() { _ = scanner.close() }()
+
+	seen := make(map[string]bool)
+	for {
+		replies, err := scanner.scan()
+		if err != nil {
+			return "", len(seen), err
+		}
+		fresh := 0
+		for _, reply := range replies {
+			if seen[reply.MessageID] {
+				continue
+			}
+			seen[reply.MessageID] = true
+			fresh++
+			if err := stream(reply); err != nil {
+				return "", len(seen), err
+			}
+		}
+		// One quiet poll after the first reply collects a follow-up sent in
+		// quick succession without holding the turn open for the full timeout.
+		if len(seen) > 0 && fresh == 0 {
+			return ReplyReceived, len(seen), nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ReplyCancelled, len(seen), nil
+		case <-deadline.C:
+			if len(seen) > 0 {
+				return ReplyReceived, len(seen), nil
+			}
+			return ReplyTimedOut, 0, nil
+		case <-ticker.C:
+		}
+	}
+}
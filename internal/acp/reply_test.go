@@ -0,0 +1,252 @@
+package acp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/format"
+)
+
+// liveConnection keeps one Serve running over pipes so a test can interleave
+// requests with mailbox changes, the way an editor holds stdio open.
+type liveConnection struct {
+	t      *testing.T
+	in     *io.PipeWriter
+	out    *bufio.Reader
+	served chan error
+}
+
+func connect(t *testing.T, server *Server) *liveConnection {
+	t.Helper()
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	conn := &liveConnection{t: t, in: inWriter, out: bufio.NewReader(outReader), served: make(chan error, 1)}
+	go func() {
+		err := server.Serve(inReader, outWriter)
+		_ = outWriter.Close()
+		conn.served <- err
+	}()
+	t.Cleanup(func() { _ = inWriter.Close() })
+	return conn
+}
+
+func (c *liveConnection) send(line string) {
+	c.t.Helper()
+	if _, err := fmt.Fprintln(c.in, line); err != nil {
+		c.t.Fatalf("write request: %v", err)
+	}
+}
+
+func (c *liveConnection) next() string {
+	c.t.Helper()
+	line, err := c.out.ReadString('\n')
+	if err != nil {
+		c.t.Fatalf("read line: %v", err)
+	}
+	return line
+}
+
+func (c *liveConnection) close() {
+	c.t.Helper()
+	if err := c.in.Close(); err != nil {
+		c.t.Fatal(err)
+	}
+	if err := <-c.served; err != nil {
+		c.t.Fatalf("Serve: %v", err)
+	}
+}
+
+func waitingServer(t *testing.T, timeout time.Duration) (Config, *Server, string) {
+	t.Helper()
+	cfg := testConfig(t)
+	cfg.ReplyTimeout = timeout
+	server, sessionID := initializedServer(t, cfg)
+	server.replyPoll = 10 * time.Millisecond
+	return cfg, server, sessionID
+}
+
+func promptLine(id int, sessionID, text string) string {
+	return fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"session/prompt","params":{"sessionId":%q,"prompt":[{"type":"text","text":%q}]}}`, id, sessionID, text)
+}
+
+// deliveredPromptID waits for the prompt to reach the recipient inbox and
+// returns its message id.
+func deliveredPromptID(t *testing.T, cfg Config) string {
+	t.Helper()
+	dir := filepath.Join(cfg.Root, "agents", testTo, "inbox", "new")
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		entries, _ := os.ReadDir(dir)
+		if len(entries) == 1 {
+			header, err := format.ReadHeaderFile(filepath.Join(dir, entries[0].Name()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return header.ID
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("prompt never reached the recipient inbox")
+	return ""
+}
+
+// writeInboxMessage drops a message from the recipient into the sender's inbox,
+// as amq reply would.
+func writeInboxMessage(t *testing.T, cfg Config, id, thread, body string, refs ...string) {
+	t.Helper()
+	data, err := format.Message{
+		Header: format.Header{
+			Schema:  format.CurrentSchema,
+			ID:      id,
+			From:    testTo,
+			To:      []string{testMe},
+			Thread:  thread,
+			Created: time.Now().UTC().Format(time.RFC3339Nano),
+			Refs:    refs,
+		},
+		Body: body,
+	}.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(cfg.Root, "agents", testMe, "inbox", "new")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, id+".md"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestSessionPromptStreamsReplyWhenWaiting proves the opt-in wait streams only
+// the reply that references the prompt, then ends the turn, and leaves the
+// reply in the sender's inbox for its owner.
+func TestSessionPromptStreamsReplyWhenWaiting(t *testing.T) {
+	cfg, server, sessionID := waitingServer(t, 5*time.Second)
+	conn := connect(t, server)
+
+	conn.send(promptLine(3, sessionID, "what changed?"))
+	promptID := deliveredPromptID(t, cfg)
+	writeInboxMessage(t, cfg, "msg-unrelated", "p2p/codex__cursor", "not an answer")
+	writeInboxMessage(t, cfg, "msg-reply", "p2p/codex__cursor", "the parser changed", promptID)
+
+	var update struct {
+		Method string `json:"method"`
+		Params struct {
+			SessionID string `json:"sessionId"`
+			Update    struct {
+				SessionUpdate string      `json:"sessionUpdate"`
+				Content       textContent `json:"content"`
+				Meta          updateMeta  `json:"_meta"`
+			} `json:"update"`
+		} `json:"params"`
+	}
+	if err := json.Unmarshal([]byte(conn.next()), &update); err != nil {
+		t.Fatal(err)
+	}
+	if update.Method != "session/update" || update.Params.SessionID != sessionID ||
+		update.Params.Update.SessionUpdate != "agent_message_chunk" {
+		t.Fatalf("update = %+v, want an agent_message_chunk for %s", update, sessionID)
+	}
+	if update.Params.Update.Content.Text != "the parser changed" || update.Params.Update.Meta.AMQ.MessageID != "msg-reply" {
+		t.Fatalf("update content = %+v meta %+v, want the referencing reply", update.Params.Update.Content, update.Params.Update.Meta)
+	}
+
+	result := decodeAMQ(t, conn.next())
+	if result.StopReason != StopReasonEndTurn || result.Meta.AMQ.Reply != ReplyReceived || result.Meta.AMQ.Replies != 1 {
+		t.Fatalf("result = %+v, want end_turn with one received reply", result)
+	}
+	conn.close()
+
+	if _, err := os.Stat(filepath.Join(cfg.Root, "agents", testMe, "inbox", "new", "msg-reply.md")); err != nil {
+		t.Fatalf("reply was moved out of inbox/new: %v", err)
+	}
+}
+
+// TestSessionCancelAbortsReplyWait proves session/cancel ends a waiting turn
+// with stopReason cancelled long before the timeout, and that the session is
+// reserved while it waits.
+func TestSessionCancelAbortsReplyWait(t *testing.T) {
+	cfg, server, sessionID := waitingServer(t, time.Minute)
+	conn := connect(t, server)
+
+	conn.send(promptLine(3, sessionID, "take your time"))
+	deliveredPromptID(t, cfg)
+	conn.send(promptLine(4, sessionID, "and another thing"))
+	if code := decodeError(t, conn.next()).Code; code != codeInvalidRequest {
+		t.Fatalf("overlapping prompt error code = %d, want %d", code, codeInvalidRequest)
+	}
+
+	started := time.Now()
+	conn.send(`{"jsonrpc":"2.0","method":"session/cancel","params":{"sessionId":"` + sessionID + `"}}`)
+	result := decodeAMQ(t, conn.next())
+	if result.StopReason != StopReasonCancelled || result.Meta.AMQ.Reply != ReplyCancelled {
+		t.Fatalf("result = %+v, want a cancelled turn", result)
+	}
+	if elapsed := time.Since(started); elapsed > 10*time.Second {
+		t.Fatalf("cancel took %s; the wait was not aborted", elapsed)
+	}
+	// The cancelled turn releases the session for the next prompt.
+	conn.send(promptLine(5, sessionID, "again"))
+	conn.send(`{"jsonrpc":"2.0","method":"session/cancel","params":{"sessionId":"` + sessionID + `"}}`)
+	if result := decodeAMQ(t, conn.next()); result.StopReason != StopReasonCancelled {
+		t.Fatalf("second turn stopReason = %q, want cancelled", result.StopReason)
+	}
+	conn.close()
+}
+
+func TestSessionPromptReplyWaitTimesOut(t *testing.T) {
+	_, server, sessionID := waitingServer(t, 50*time.Millisecond)
+	conn := connect(t, server)
+
+	conn.send(promptLine(3, sessionID, "anyone there?"))
+	result := decodeAMQ(t, conn.next())
+	if result.StopReason != StopReasonEndTurn || result.Meta.AMQ.Reply != ReplyTimedOut || result.Meta.AMQ.State != DeliveryStateQueued {
+		t.Fatalf("result = %+v, want end_turn with a timed out wait on a queued prompt", result)
+	}
+	conn.close()
+}
+
+// TestReplyScannerReadsNewNamesThroughPinnedRoot proves a reply wait reads
+// each inbox file once, even after it moves to cur, and never follows the
+// root path to a tree that replaced it mid-wait.
+func TestReplyScannerReadsNewNamesThroughPinnedRoot(t *testing.T) {
+	cfg := Config{Root: filepath.Join(t.TempDir(), "root"), Me: testMe, To: testTo}
+	thread := "p2p/codex__cursor"
+	writeInboxMessage(t, cfg, "msg-first", thread, "first", "msg-prompt")
+
+	scanner, err := newReplyScanner(cfg, thread, "msg-prompt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = scanner.close() }()
+	if replies, err := scanner.scan(); err != nil || len(replies) != 1 || replies[0].MessageID != "msg-first" {
+		t.Fatalf("first scan = %+v, %v; want msg-first", replies, err)
+	}
+
+	inbox := filepath.Join(cfg.Root, "agents", testMe, "inbox")
+	if err := os.MkdirAll(filepath.Join(inbox, "cur"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(inbox, "new", "msg-first.md"), filepath.Join(inbox, "cur", "msg-first.md")); err != nil {
+		t.Fatal(err)
+	}
+	writeInboxMessage(t, cfg, "msg-second", thread, "second", "msg-prompt")
+	if replies, err := scanner.scan(); err != nil || len(replies) != 1 || replies[0].MessageID != "msg-second" {
+		t.Fatalf("second scan = %+v, %v; want only msg-second", replies, err)
+	}
+
+	if err := os.Rename(cfg.Root, cfg.Root+"-moved"); err != nil {
+		t.Fatal(err)
+	}
+	writeInboxMessage(t, cfg, "msg-replaced", thread, "from another tree", "msg-prompt")
+	if replies, _ := scanner.scan(); len(replies) != 0 {
+		t.Fatalf("scan after the root was replaced = %+v, want nothing from the new tree", replies)
+	}
+}
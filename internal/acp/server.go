@@ -3,12 +3,15 @@ package acp
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/avivsinai/agent-message-queue/internal/format"
 )
@@ -18,7 +21,8 @@ import (
 // decides for itself whether to disconnect.
 const ProtocolVersion = 1
 
-// StopReasonEndTurn ends a prompt turn once the message is queued. The turn is
+// StopReasonEndTurn ends a prompt turn once the message is queued, or once the
+// opt-in reply wait has streamed the replies or run out of time. The turn is
 // genuinely over because this companion runs no model and requests no tools.
 const StopReasonEndTurn = "end_turn"
 
@@ -51,6 +55,13 @@ type response struct {
 	Error   *rpcError        `json:"error,omitempty"`
 }
 
+// notification is a server-to-client JSON-RPC message that expects no answer.
+type notification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params"`
+}
+
 type rpcError struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
@@ -65,27 +76,44 @@ func newRPCError(code int, format string, args ...any) *rpcError {
 }
 
 // Server is a single-connection ACP v1 agent. Requests are handled one at a
-// time in arrival order, so the session table needs no locking.
+// time in arrival order, so the session table needs no locking. The one
+// exception is the opt-in reply wait: it runs beside the reader so a later
+// session/cancel can reach it, and only the table of waiting turns and the
+// output stream are shared with it.
 type Server struct {
-	cfg      Config
-	version  string
-	sessions map[string]bool
-	ready    bool
+	cfg       Config
+	version   string
+	sessions  map[string]bool
+	ready     bool
+	replyPoll time.Duration
+
+	mu    sync.Mutex
+	turns map[string]context.CancelFunc
 }
 
 // NewServer builds a server bound to one already authenticated routing context.
 func NewServer(cfg Config, version string) *Server {
-	return &Server{cfg: cfg, version: version, sessions: make(map[string]bool)}
+	return &Server{
+		cfg:       cfg,
+		version:   version,
+		sessions:  make(map[string]bool),
+		replyPoll: defaultReplyPoll,
+		turns:     make(map[string]context.CancelFunc),
+	}
 }
 
 // Serve reads newline-delimited JSON-RPC objects from in and writes one
 // response line per request. Notifications produce no response. It returns once
-// in is exhausted.
+// in is exhausted; a prompt turn still waiting for a reply at that point is
+// cancelled, answered, and awaited first.
 func (s *Server) Serve(in io.Reader, out io.Writer) error {
 	scanner := bufio.NewScanner(in)
 	scanner.Buffer(make([]byte, 0, 64*1024), format.MaxMessageSize+1024)
-	writer := bufio.NewWriter(out)
-	encoder := json.NewEncoder(writer)
+	output := newLineWriter(out)
+
+	var waiting sync.WaitGroup
+	defer waiting.Wait()
+	defer s.cancelTurns()
 
 	for scanner.Scan() {
 		line := bytes.TrimSpace(scanner.Bytes())
@@ -96,15 +124,68 @@ func (s *Server) Serve(in io.Reader, out io.Writer) error {
 		if !ok {
 			continue
 		}
-		if err := encoder.Encode(resp); err != nil {
-			return err
+		if replay, isReplay := resp.Result.(*sessionReplay); isReplay {
+			// The history must reach the client before the load response.
+			for _, update := range replay.updates {
+				if err := output.write(update); err != nil {
+					return err
+				}
+			}
+			resp.Result = struct{}{}
+		}
+		if turn, isTurn := resp.Result.(*replyTurn); isTurn {
+			waiting.Add(1)
+			go func() {
+				defer waiting.Done()
+				s.finishTurn(turn, resp.ID, output)
+			}()
+			continue
 		}
-		// Flush per response so an interactive client is never left waiting.
-		if err := writer.Flush(); err != nil {
+		if err := output.write(resp); err != nil {
 			return err
 		}
 	}
-	return scanner.Err()
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	s.cancelTurns()
+	waiting.Wait()
+	return output.failed()
+}
+
+// lineWriter serializes JSON lines from the reader and from waiting prompt
+// turns. The first write error sticks so every writer observes it.
+type lineWriter struct {
+	mu      sync.Mutex
+	writer  *bufio.Writer
+	encoder *json.Encoder
+	err     error
+}
+
+func newLineWriter(out io.Writer) *lineWriter {
+	writer := bufio.NewWriter(out)
+	return &lineWriter{writer: writer, encoder: json.NewEncoder(writer)}
+}
+
+func (w *lineWriter) write(v any) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.encoder.Encode(v); err != nil {
+		w.err = err
+		return err
+	}
+	// Flush per line so an interactive client is never left waiting.
+	w.err = w.writer.Flush()
+	return w.err
+}
+
+func (w *lineWriter) failed() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
 }
 
 // handle turns one request line into at most one response.
@@ -119,6 +200,11 @@ func (s *Server) handle(line []byte) (response, bool) {
 
 	result, rpcErr := s.dispatch(req.Method, req.Params)
 	if req.ID == nil {
+		// A prompt sent as a notification has nobody to answer, so it does
+		// not hold its session waiting for a reply.
+		if turn, ok := result.(*replyTurn); ok {
+			s.endTurn(turn.sessionID)
+		}
 		return response{}, false
 	}
 	if rpcErr != nil {
@@ -133,6 +219,8 @@ func (s *Server) dispatch(method string, params json.RawMessage) (any, *rpcError
 		return s.initialize(params)
 	case "session/new":
 		return s.newSession(params)
+	case "session/load":
+		return s.loadSession(params)
 	case "session/prompt":
 		return s.prompt(params)
 	case "session/cancel":
@@ -160,7 +248,8 @@ type initializeResult struct {
 	AuthMethods       []any             `json:"authMethods"`
 }
 
-// agentCapabilities advertises the smallest honest v1 surface. Omitting
+// agentCapabilities advertises the smallest honest v1 surface. LoadSession is
+// true only once a session mapping has been persisted for this sender. Omitting
 // mcpCapabilities declares no MCP support. Every prompt capability is false:
 // those flags gate image, audio, and embedded context, while text and
 // resource_link are the v1 baseline every agent must accept. Filesystem and
@@ -193,7 +282,7 @@ func (s *Server) initialize(params json.RawMessage) (any, *rpcError) {
 	return initializeResult{
 		ProtocolVersion: ProtocolVersion,
 		AgentCapabilities: agentCapabilities{
-			LoadSession:        false,
+			LoadSession:        hasSessionRecords(s.cfg),
 			PromptCapabilities: promptCapabilities{},
 		},
 		AgentInfo: agentInfo{
@@ -227,10 +316,59 @@ func (s *Server) newSession(params json.RawMessage) (any, *rpcError) {
 	if err != nil {
 		return nil, newRPCError(codeInternalError, "generate session id: %v", err)
 	}
+	if err := rememberSession(s.cfg, id); err != nil {
+		return nil, newRPCError(codeInternalError, "persist session: %v", err)
+	}
 	s.sessions[id] = true
 	return newSessionResult{SessionID: id}, nil
 }
 
+type loadSessionParams struct {
+	SessionID  string          `json:"sessionId"`
+	Cwd        string          `json:"cwd"`
+	McpServers json.RawMessage `json:"mcpServers"`
+	Meta       json.RawMessage `json:"_meta"`
+}
+
+// sessionReplay carries the history Serve writes ahead of a session/load
+// response.
+type sessionReplay struct {
+	updates []notification
+}
+
+// loadSession restores a persisted session by replaying its AMQ thread. A
+// record made for another recipient is refused: prompts in the restored session
+// would go to AMQ_ACP_TO, not into the thread the client is shown.
+func (s *Server) loadSession(params json.RawMessage) (any, *rpcError) {
+	if !s.ready {
+		return nil, newRPCError(codeInvalidRequest, "initialize must complete before session/load")
+	}
+	var parsed loadSessionParams
+	if err := decodeParams(params, &parsed, false); err != nil {
+		return nil, err
+	}
+	rec, ok, err := loadSessionRecord(s.cfg, parsed.SessionID)
+	if err != nil {
+		return nil, newRPCError(codeInternalError, "load session %q: %v", parsed.SessionID, err)
+	}
+	if !ok {
+		return nil, newRPCError(codeInvalidParams, "unknown sessionId %q; no AMQ thread is recorded for it", parsed.SessionID)
+	}
+	if rec.To != s.cfg.To || rec.Thread != p2pThread(s.cfg.Me, s.cfg.To) {
+		return nil, newRPCError(
+			codeInvalidParams,
+			"session %q belongs to thread %s with %s; this companion delivers to %s",
+			parsed.SessionID, rec.Thread, rec.To, s.cfg.To,
+		)
+	}
+	updates, err := replaySession(s.cfg, rec)
+	if err != nil {
+		return nil, newRPCError(codeInternalError, "replay thread %s: %v", rec.Thread, err)
+	}
+	s.sessions[rec.SessionID] = true
+	return &sessionReplay{updates: updates}, nil
+}
+
 type promptParams struct {
 	SessionID string          `json:"sessionId"`
 	Prompt    []contentBlock  `json:"prompt"`
@@ -266,6 +404,8 @@ type amqDelivery struct {
 	Completed bool   `json:"completed"`
 	Egress    string `json:"egress"`
 	Duplicate bool   `json:"duplicate,omitempty"`
+	Reply     string `json:"reply,omitempty"`
+	Replies   int    `json:"replies,omitempty"`
 }
 
 func (s *Server) prompt(params json.RawMessage) (any, *rpcError) {
@@ -288,10 +428,31 @@ func (s *Server) prompt(params json.RawMessage) (any, *rpcError) {
 	if rpcErr != nil {
 		return nil, rpcErr
 	}
-	delivery, err := DeliverPrompt(s.cfg, text, eventID)
+
+	var ctx context.Context
+	if s.cfg.ReplyTimeout > 0 {
+		// Reserve the session before delivery so a second prompt cannot
+		// queue a message whose reply nobody would be waiting for.
+		var rpcErr *rpcError
+		if ctx, rpcErr = s.beginTurn(parsed.SessionID); rpcErr != nil {
+			return nil, rpcErr
+		}
+	}
+	delivery, err := DeliverPrompt(s.cfg, parsed.SessionID, text, eventID)
 	if err != nil {
+		if ctx != nil {
+			s.endTurn(parsed.SessionID)
+		}
 		return nil, newRPCError(codeInternalError, "deliver prompt to %s: %v", s.cfg.To, err)
 	}
+	result := deliveryResult(delivery)
+	if ctx == nil {
+		return result, nil
+	}
+	return &replyTurn{ctx: ctx, sessionID: parsed.SessionID, delivery: delivery, result: result}, nil
+}
+
+func deliveryResult(delivery Delivery) promptResult {
 	return promptResult{
 		StopReason: StopReasonEndTurn,
 		Meta: promptMeta{AMQ: amqDelivery{
@@ -307,7 +468,119 @@ func (s *Server) prompt(params json.RawMessage) (any, *rpcError) {
 			Egress:    delivery.Egress,
 			Duplicate: delivery.Duplicate,
 		}},
-	}, nil
+	}
+}
+
+// replyTurn is a delivered prompt whose response waits for the recipient's
+// replies. Serve answers it from its own goroutine.
+type replyTurn struct {
+	ctx       context.Context
+	sessionID string
+	delivery  Delivery
+	result    promptResult
+}
+
+type sessionUpdateParams struct {
+	SessionID string        `json:"sessionId"`
+	Update    sessionUpdate `json:"update"`
+}
+
+type sessionUpdate struct {
+	SessionUpdate string      `json:"sessionUpdate"`
+	Content       textContent `json:"content"`
+	Meta          *updateMeta `json:"_meta,omitempty"`
+}
+
+type textContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type updateMeta struct {
+	AMQ amqMessage `json:"amq"`
+}
+
+type amqMessage struct {
+	MessageID string `json:"messageId"`
+	From      string `json:"from"`
+}
+
+// messageChunk renders one AMQ message as an ACP session/update of the given
+// kind, user_message_chunk or agent_message_chunk.
+func messageChunk(sessionID, kind, messageID, from, body string) notification {
+	return notification{
+		JSONRPC: jsonRPCVersion,
+		Method:  "session/update",
+		Params: sessionUpdateParams{
+			SessionID: sessionID,
+			Update: sessionUpdate{
+				SessionUpdate: kind,
+				Content:       textContent{Type: "text", Text: body},
+				Meta:          &updateMeta{AMQ: amqMessage{MessageID: messageID, From: from}},
+			},
+		},
+	}
+}
+
+// finishTurn streams replies to a waiting prompt as agent message chunks and
+// then answers the prompt request itself. The turn is released before the
+// answer is written so the client may prompt again as soon as it reads it.
+func (s *Server) finishTurn(turn *replyTurn, id *json.RawMessage, output *lineWriter) {
+	state, count, err := awaitReplies(turn.ctx, s.cfg, turn.delivery, s.replyPoll, func(reply Reply) error {
+		return output.write(messageChunk(turn.sessionID, "agent_message_chunk", reply.MessageID, reply.From, reply.Body))
+	})
+	s.endTurn(turn.sessionID)
+	if err != nil {
+		_ = output.write(errorResponse(id, newRPCError(
+			codeInternalError, "wait for reply to %s: %v", turn.delivery.MessageID, err,
+		)))
+		return
+	}
+	result := turn.result
+	result.Meta.AMQ.Reply = state
+	result.Meta.AMQ.Replies = count
+	if state == ReplyCancelled {
+		result.StopReason = StopReasonCancelled
+	}
+	_ = output.write(response{JSONRPC: jsonRPCVersion, ID: id, Result: result})
+}
+
+func (s *Server) beginTurn(sessionID string) (context.Context, *rpcError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, busy := s.turns[sessionID]; busy {
+		return nil, newRPCError(codeInvalidRequest, "session %q already has a prompt turn waiting for a reply", sessionID)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.turns[sessionID] = cancel
+	return ctx, nil
+}
+
+func (s *Server) endTurn(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cancel, ok := s.turns[sessionID]; ok {
+		cancel()
+		delete(s.turns, sessionID)
+	}
+}
+
+// cancelTurn aborts a waiting turn but leaves its entry for finishTurn, which
+// still owes the client a cancelled response.
+func (s *Server) cancelTurn(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cancel, ok := s.turns[sessionID]; ok {
+		cancel()
+	}
+}
+
+func (s *Server) cancelTurns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, cancel := range s.turns {
+		cancel()
+	}
 }
 
 // promptText renders the v1 baseline block types: text passes through and
@@ -348,9 +621,10 @@ type cancelParams struct {
 	Meta      json.RawMessage `json:"_meta"`
 }
 
-// cancel acknowledges a cancellation. Prompt turns here complete synchronously
-// inside session/prompt, so by the time a cancel arrives there is no in-flight
-// model request or tool call to abort.
+// cancel aborts a prompt turn that is waiting for a reply; that turn then ends
+// with stopReason cancelled. Without the reply wait, prompt turns complete
+// synchronously inside session/prompt, so there is nothing in flight and the
+// cancel is only acknowledged. The queued message is never withdrawn.
 func (s *Server) cancel(params json.RawMessage) (any, *rpcError) {
 	var parsed cancelParams
 	if err := decodeParams(params, &parsed, false); err != nil {
@@ -359,6 +633,7 @@ func (s *Server) cancel(params json.RawMessage) (any, *rpcError) {
 	if !s.sessions[parsed.SessionID] {
 		return nil, newRPCError(codeInvalidParams, "unknown sessionId %q", parsed.SessionID)
 	}
+	s.cancelTurn(parsed.SessionID)
 	return struct{}{}, nil
 }
 
@@ -144,7 +144,7 @@ func TestInitializeAnswersProtocolVersionOne(t *testing.T) {
 		t.Fatalf("protocolVersion = %s, want 1", result.ProtocolVersion)
 	}
 	if result.AgentCapabilities.LoadSession {
-		t.Error("loadSession = true, want false: session/load is not implemented")
+		t.Error("loadSession = true, want false: no session mapping exists yet")
 	}
 	capabilities := result.AgentCapabilities.PromptCapabilities
 	if capabilities.Image || capabilities.Audio || capabilities.EmbeddedContext {
@@ -211,7 +211,7 @@ func TestInitializeAcceptsReservedMetaAndRicherCapabilities(t *testing.T) {
 
 func TestUnknownMethodReturnsMethodNotFound(t *testing.T) {
 	server := NewServer(testConfig(t), "test")
-	for _, method := range []string{"session/load", "fs/read_text_file", "terminal/create", "tools/call", "nonsense"} {
+	for _, method := range []string{"session/set_mode", "fs/read_text_file", "terminal/create", "tools/call", "nonsense"} {
 		t.Run(method, func(t *testing.T) {
 			line := serveOne(t, NewServer(server.cfg, "test"), `{"jsonrpc":"2.0","id":7,"method":"`+method+`","params":{}}`)
 			failure := decodeError(t, line)
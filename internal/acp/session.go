@@ -0,0 +1,172 @@
+package acp
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+	"github.com/avivsinai/agent-message-queue/internal/thread"
+)
+
+var sessionIDRe = regexp.MustCompile(`^acp_[0-9a-f]{32}$`)
+
+// sessionSchema 2 records sessions whose prompts carry a SessionLabelPrefix
+// label. A schema 1 session predates the label and replays the whole thread.
+const sessionSchema = 2
+
+// sessionRecord maps one ACP session to the AMQ thread its prompts are
+// delivered into. The thread, not this record, holds the conversation.
+type sessionRecord struct {
+	Schema    int    `json:"schema"`
+	SessionID string `json:"session_id"`
+	Thread    string `json:"thread"`
+	To        string `json:"to"`
+	Created   string `json:"created"`
+}
+
+func sessionJournalDir(me string) string {
+	return filepath.Join("agents", me, "outbox", "acp-sessions")
+}
+
+func sessionJournalPath(me, sessionID string) string {
+	return filepath.Join(sessionJournalDir(me), sessionID+".json")
+}
+
+func rememberSession(cfg Config, sessionID string) error {
+	identity, err := fsq.SnapshotDeliveryRoot(cfg.Root)
+	if err != nil {
+		return err
+	}
+	root, err := fsq.OpenDeliveryRoot(cfg.Root, identity)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = root.Close() }()
+
+	data, err := json.Marshal(sessionRecord{
+		Schema:    sessionSchema,
+		SessionID: sessionID,
+		Thread:    p2pThread(cfg.Me, cfg.To),
+		To:        cfg.To,
+		Created:   time.Now().UTC().Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		return err
+	}
+	return root.CreateExclusiveFile(sessionJournalPath(cfg.Me, sessionID), append(data, '\n'), 0o600)
+}
+
+func loadSessionRecord(cfg Config, sessionID string) (sessionRecord, bool, error) {
+	if !sessionIDRe.MatchString(sessionID) {
+		return sessionRecord{}, false, nil
+	}
+	identity, err := fsq.SnapshotDeliveryRoot(cfg.Root)
+	if err != nil {
+		return sessionRecord{}, false, err
+	}
+	root, err := fsq.OpenDeliveryRoot(cfg.Root, identity)
+	if err != nil {
+		return sessionRecord{}, false, err
+	}
+	defer func() { _ = root.Close() }()
+
+	data, err := root.ReadRegularNoFollow(sessionJournalPath(cfg.Me, sessionID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return sessionRecord{}, false, nil
+		}
+		return sessionRecord{}, false, err
+	}
+	var rec sessionRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return sessionRecord{}, false, err
+	}
+	if rec.SessionID != sessionID {
+		return sessionRecord{}, false, errors.New("session record does not match its filename")
+	}
+	return rec, true, nil
+}
+
+// hasSessionRecords reports whether any session mapping exists for this
+// sender. Without one there is nothing session/load could restore, so the
+// capability is not advertised.
+func hasSessionRecords(cfg Config) bool {
+	identity, err := fsq.SnapshotDeliveryRoot(cfg.Root)
+	if err != nil {
+		return false
+	}
+	root, err := fsq.OpenDeliveryRoot(cfg.Root, identity)
+	if err != nil {
+		return false
+	}
+	defer func() { _ = root.Close() }()
+
+	entries, err := root.ReadDir(sessionJournalDir(cfg.Me))
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && sessionIDRe.MatchString(strings.TrimSuffix(name, ".json")) {
+			return true
+		}
+	}
+	return false
+}
+
+// replaySession renders the session's part of the recorded thread as
+// session/update notifications in timestamp order: its own prompts, and any
+// message that refs one of them or a reply already kept. Messages from the
+// sender are the user's turns, everything else is the agent's. Messages that
+// cannot be parsed are skipped, matching how the other read-only thread views
+// treat them. The thread is read through the pinned delivery root.
+func replaySession(cfg Config, rec sessionRecord) ([]notification, error) {
+	identity, err := fsq.SnapshotDeliveryRoot(cfg.Root)
+	if err != nil {
+		return nil, err
+	}
+	root, err := fsq.OpenDeliveryRoot(cfg.Root, identity)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = root.Close() }()
+
+	entries, err := thread.CollectDeliveryRoot(root, rec.Thread, []string{cfg.Me, rec.To}, true, func(string, error) error { return nil })
+	if err != nil {
+		return nil, err
+	}
+	updates := make([]notification, 0, len(entries))
+	kept := make(map[string]bool)
+	for _, entry := range entries {
+		if rec.Schema >= sessionSchema && !inSession(entry, rec.SessionID, kept) {
+			continue
+		}
+		kept[entry.ID] = true
+		kind := "agent_message_chunk"
+		if entry.From == cfg.Me {
+			kind = "user_message_chunk"
+		}
+		updates = append(updates, messageChunk(rec.SessionID, kind, entry.ID, entry.From, strings.TrimRight(entry.Body, "\n")))
+	}
+	return updates, nil
+}
+
+// inSession reports whether entry is a prompt stamped with sessionID or refs
+// a message already kept for the session.
+func inSession(entry thread.Entry, sessionID string, kept map[string]bool) bool {
+	if slices.Contains(entry.Labels, SessionLabelPrefix+sessionID) {
+		return true
+	}
+	for _, ref := range entry.Refs {
+		if kept[ref] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package acp
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const initializeLine = `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":1,"clientCapabilities":{}}}`
+
+func loadLine(t *testing.T, sessionID string) string {
+	t.Helper()
+	return `{"jsonrpc":"2.0","id":2,"method":"session/load","params":{"sessionId":` + mustJSONString(t, sessionID) + `,"cwd":"/tmp","mcpServers":[]}}`
+}
+
+// TestSessionLoadReplaysThread proves a new companion process restores a
+// session from its persisted mapping: the capability is advertised, the
+// session's part of the AMQ thread is replayed as user and agent chunks ahead
+// of the response, and the restored session accepts prompts.
+func TestSessionLoadReplaysThread(t *testing.T) {
+	cfg := testConfig(t)
+	first, sessionID := initializedServer(t, cfg)
+	prompted := decodeAMQ(t, promptWithMeta(t, first, sessionID, "what changed?", ""))
+	writeInboxMessage(t, cfg, "msg-reply", "p2p/codex__cursor", "the parser changed", prompted.Meta.AMQ.MessageID)
+	writeInboxMessage(t, cfg, "msg-elsewhere", "p2p/codex__other", "another conversation")
+	// A second session shares the peer thread; neither its prompt nor the
+	// reply to it belongs in the first session's replay.
+	other, otherID := initializedServer(t, cfg)
+	otherPrompt := decodeAMQ(t, promptWithMeta(t, other, otherID, "unrelated question", ""))
+	writeInboxMessage(t, cfg, "msg-other-reply", "p2p/codex__cursor", "unrelated answer", otherPrompt.Meta.AMQ.MessageID)
+
+	reopened := NewServer(cfg, "test")
+	lines := serve(t, reopened, initializeLine, loadLine(t, sessionID))
+	if len(lines) != 4 {
+		t.Fatalf("Serve wrote %d lines, want initialize, two updates, and the load response: %v", len(lines), lines)
+	}
+	initialized := decodeResult[struct {
+		AgentCapabilities agentCapabilities `json:"agentCapabilities"`
+	}](t, lines[0])
+	if !initialized.AgentCapabilities.LoadSession {
+		t.Fatal("loadSession = false after a session mapping was persisted")
+	}
+
+	var updates []struct {
+		Method string              `json:"method"`
+		Params sessionUpdateParams `json:"params"`
+	}
+	for _, line := range lines[1:3] {
+		var update struct {
+			Method string              `json:"method"`
+			Params sessionUpdateParams `json:"params"`
+		}
+		if err := json.Unmarshal([]byte(line), &update); err != nil {
+			t.Fatal(err)
+		}
+		updates = append(updates, update)
+	}
+	if updates[0].Method != "session/update" || updates[0].Params.SessionID != sessionID ||
+		updates[0].Params.Update.SessionUpdate != "user_message_chunk" || updates[0].Params.Update.Content.Text != "what changed?" {
+		t.Fatalf("first update = %+v, want the prompt as a user chunk", updates[0])
+	}
+	if updates[1].Params.Update.SessionUpdate != "agent_message_chunk" || updates[1].Params.Update.Content.Text != "the parser changed" ||
+		updates[1].Params.Update.Meta.AMQ.MessageID != "msg-reply" {
+		t.Fatalf("second update = %+v, want the reply as an agent chunk", updates[1])
+	}
+	decodeResult[struct{}](t, lines[3])
+
+	again := decodeAMQ(t, promptWithMeta(t, reopened, sessionID, "and now?", ""))
+	if again.StopReason != StopReasonEndTurn || again.Meta.AMQ.Thread != "p2p/codex__cursor" {
+		t.Fatalf("prompt on restored session = %+v", again)
+	}
+}
+
+func TestSessionLoadRefusesUnknownAndForeignSessions(t *testing.T) {
+	cfg := testConfig(t)
+	_, sessionID := initializedServer(t, cfg)
+
+	for _, id := range []string{"acp_00000000000000000000000000000000", "../../codex/inbox/new/x", ""} {
+		lines := serve(t, NewServer(cfg, "test"), initializeLine, loadLine(t, id))
+		if code := decodeError(t, lines[1]).Code; code != codeInvalidParams {
+			t.Fatalf("load %q error code = %d, want %d", id, code, codeInvalidParams)
+		}
+	}
+
+	// The same sender pointed at another recipient must not adopt a session
+	// whose thread it would no longer deliver into.
+	retargeted := cfg
+	retargeted.To = "gemini"
+	lines := serve(t, NewServer(retargeted, "test"), initializeLine, loadLine(t, sessionID))
+	if code := decodeError(t, lines[1]).Code; code != codeInvalidParams {
+		t.Fatalf("retargeted load error code = %d, want %d", code, codeInvalidParams)
+	}
+
+	lines = serve(t, NewServer(cfg, "test"), loadLine(t, sessionID))
+	if code := decodeError(t, lines[0]).Code; code != codeInvalidRequest {
+		t.Fatalf("load before initialize error code = %d, want %d", code, codeInvalidRequest)
+	}
+}
@@ -0,0 +1,417 @@
+package breaker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/avivsinai/agent-message-queue/internal/acl"
+	"github.com/avivsinai/agent-message-queue/internal/config"
+	"github.com/avivsinai/agent-message-queue/internal/format"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+	"github.com/avivsinai/agent-message-queue/internal/lock"
+)
+
+// Breaker statuses.
+const (
+	StatusClosed = "closed"
+	StatusOpen   = "open"
+)
+
+// Trip reasons.
+const (
+	ReasonAlternation  = "alternation"
+	ReasonRepeatedBody = "repeated_body"
+	ReasonDepth        = "depth"
+)
+
+const (
+	breakersDir = "meta/breakers"
+	stateFile   = "state.json"
+	heldDir     = "held"
+	maxEvents   = 64
+)
+
+// ErrNotFound reports that a thread has no breaker state.
+var ErrNotFound = errors.New("no breaker state for thread")
+
+// Event is one recorded send in a thread.
+type Event struct {
+	From   string `json:"from"`
+	At     string `json:"at"`
+	Digest string `json:"digest"`
+}
+
+// State is the persisted breaker state for one thread.
+type State struct {
+	Thread string `json:"thread"`
+	Status string `json:"status"`
+	// Initiator is the first sender seen in the thread, reported by amq
+	// breaker list.
+	Initiator string `json:"initiator"`
+	// Depth counts the thread's messages within the window at the last send.
+	Depth     int     `json:"depth"`
+	Reason    string  `json:"reason,omitempty"`
+	Detail    string  `json:"detail,omitempty"`
+	TrippedAt string  `json:"tripped_at,omitempty"`
+	Recent    []Event `json:"recent,omitempty"`
+}
+
+// Info is a breaker as reported by List.
+type Info struct {
+	State
+	Held []string `json:"held"`
+}
+
+// Decision is the outcome of Admit.
+type Decision struct {
+	// Held is true when the message was placed in quarantine instead of
+	// being cleared for delivery.
+	Held bool
+	// Tripped is true when this message tripped the breaker.
+	Tripped bool
+	State   State
+}
+
+// ResetResult reports what Reset did with held messages.
+type ResetResult struct {
+	Thread    string   `json:"thread"`
+	Released  []string `json:"released,omitempty"`
+	Discarded []string `json:"discarded,omitempty"`
+}
+
+// Key returns the directory key for a thread id.
+func Key(thread string) string {
+	sum := sha256.Sum256([]byte(thread))
+	return hex.EncodeToString(sum[:8])
+}
+
+func threadDir(thread string) string {
+	return filepath.Join(breakersDir, Key(thread))
+}
+
+// Digest normalizes body so near-identical messages (differing only in case,
+// punctuation, digits, or whitespace) share a digest.
+func Digest(body string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(body) {
+		switch {
+		case unicode.IsLetter(r):
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = false
+			b.WriteRune(r)
+		default:
+			space = true
+		}
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Admit records a send of filename from sender in thread and decides whether
+// it may be delivered. When the breaker is open, or this send trips it, data
+// is written to the thread's held leaf and Decision.Held is set.
+func Admit(root *fsq.DeliveryRoot, thresholds config.BreakerConfig, thread, sender, body, filename string, data []byte, now time.Time) (Decision, error) {
+	var decision Decision
+	if thresholds.Disabled || thread == "" {
+		return decision, nil
+	}
+	err := withThreadLock(root, thread, func() error {
+		state, err := readState(root, thread)
+		if errors.Is(err, ErrNotFound) {
+			state = State{Thread: thread, Status: StatusClosed, Initiator: sender}
+		} else if err != nil {
+			return err
+		}
+		if state.Status != StatusOpen {
+			state.Recent = appendEvent(state.Recent, Event{
+				From:   sender,
+				At:     now.UTC().Format(time.RFC3339Nano),
+				Digest: Digest(body),
+			}, now.Add(-thresholds.WindowDuration()), max(maxEvents, thresholds.MaxDepth+1))
+			state.Depth = len(state.Recent)
+			if reason, detail := evaluate(state, thresholds); reason != "" {
+				state.Status = StatusOpen
+				state.Reason = reason
+				state.Detail = detail
+				state.TrippedAt = now.UTC().Format(time.RFC3339Nano)
+				decision.Tripped = true
+			}
+		}
+		if state.Status == StatusOpen {
+			if _, err := root.WriteFileAtomic(filepath.Join(threadDir(thread), heldDir), filename, data, 0o600); err != nil {
+				return fmt.Errorf("hold message: %w", err)
+			}
+			decision.Held = true
+		}
+		decision.State = state
+		return writeState(root, state)
+	})
+	return decision, err
+}
+
+// appendEvent drops events older than cutoff, appends event, and keeps at most
+// limit of the newest.
+func appendEvent(events []Event, event Event, cutoff time.Time, limit int) []Event {
+	kept := events[:0:0]
+	for _, e := range events {
+		at, err := time.Parse(time.RFC3339Nano, e.At)
+		if err == nil && at.Before(cutoff) {
+			continue
+		}
+		kept = append(kept, e)
+	}
+	kept = append(kept, event)
+	if len(kept) > limit {
+		kept = kept[len(kept)-limit:]
+	}
+	return kept
+}
+
+func evaluate(state State, t config.BreakerConfig) (string, string) {
+	if t.MaxDepth > 0 && state.Depth > t.MaxDepth {
+		return ReasonDepth, fmt.Sprintf("%d messages within %s (limit %d)", state.Depth, t.Window, t.MaxDepth)
+	}
+	events := state.Recent
+	senders := map[string]bool{}
+	alternations := 0
+	for i, e := range events {
+		senders[e.From] = true
+		if i > 0 && e.From != events[i-1].From {
+			alternations++
+		}
+	}
+	if t.MaxAlternations > 0 && alternations >= t.MaxAlternations {
+		return ReasonAlternation, fmt.Sprintf("%d sender alternations within %s (limit %d)", alternations, t.Window, t.MaxAlternations)
+	}
+	if t.MaxRepeats > 0 && len(senders) > 1 && len(events) > 0 {
+		last := events[len(events)-1].Digest
+		repeats := 0
+		for _, e := range events {
+			if e.Digest == last {
+				repeats++
+			}
+		}
+		if repeats >= t.MaxRepeats {
+			return ReasonRepeatedBody, fmt.Sprintf("%d near-identical bodies within %s (limit %d)", repeats, t.Window, t.MaxRepeats)
+		}
+	}
+	return "", ""
+}
+
+// List returns every breaker with state under root, open breakers first.
+func List(root *fsq.DeliveryRoot) ([]Info, error) {
+	entries, err := root.ReadDir(breakersDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var infos []Info
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		data, err := root.ReadRegularNoFollow(filepath.Join(breakersDir, entry.Name(), stateFile))
+		if err != nil {
+			continue
+		}
+		var state State
+		if err := json.Unmarshal(data, &state); err != nil || Key(state.Thread) != entry.Name() {
+			continue
+		}
+		held, err := heldFiles(root, state.Thread)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, Info{State: state, Held: held})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if (infos[i].Status == StatusOpen) != (infos[j].Status == StatusOpen) {
+			return infos[i].Status == StatusOpen
+		}
+		return infos[i].Thread < infos[j].Thread
+	})
+	return infos, nil
+}
+
+// Reset closes the breaker for thread and clears its history. Held messages
+// are delivered to their recipients when release is true and removed when
+// discard is true; with neither, Reset refuses while messages are held.
+// Released messages are checked against the recipient ACLs in configFS; a
+// refused one stays held and stops the release.
+func Reset(configFS, root *fsq.DeliveryRoot, thread string, release, discard bool) (ResetResult, error) {
+	result := ResetResult{Thread: thread}
+	if release && discard {
+		return result, fmt.Errorf("release and discard are mutually exclusive")
+	}
+	err := withThreadLock(root, thread, func() error {
+		state, err := readState(root, thread)
+		if err != nil {
+			return err
+		}
+		held, err := heldFiles(root, thread)
+		if err != nil {
+			return err
+		}
+		if len(held) > 0 && !release && !discard {
+			return fmt.Errorf("thread %s has %d held message(s); choose release or discard", thread, len(held))
+		}
+		dir := filepath.Join(threadDir(thread), heldDir)
+		for _, filename := range held {
+			path := filepath.Join(dir, filename)
+			if release {
+				data, err := root.ReadRegularNoFollow(path)
+				if err != nil {
+					return err
+				}
+				header, err := format.ParseHeader(data)
+				if err != nil {
+					return fmt.Errorf("parse held message %s: %w", filename, err)
+				}
+				if _, err := acl.DeliverToInboxes(configFS, root, header, header.To, filename, data); err != nil {
+					var committed *fsq.CommittedDurabilityError
+					if !errors.As(err, &committed) {
+						return fmt.Errorf("release held message %s: %w", filename, err)
+					}
+				}
+				result.Released = append(result.Released, filename)
+			} else {
+				result.Discarded = append(result.Discarded, filename)
+			}
+			if err := root.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+		state.Status = StatusClosed
+		state.Depth = 0
+		state.Reason = ""
+		state.Detail = ""
+		state.TrippedAt = ""
+		state.Recent = nil
+		return writeState(root, state)
+	})
+	return result, err
+}
+
+func heldFiles(root *fsq.DeliveryRoot, thread string) ([]string, error) {
+	entries, err := root.ReadDir(filepath.Join(threadDir(thread), heldDir))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	held := []string{}
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.HasSuffix(entry.Name(), ".md") {
+			held = append(held, entry.Name())
+		}
+	}
+	sort.Strings(held)
+	return held, nil
+}
+
+// Lookup returns the breaker state of thread, or ErrNotFound when no send
+// in it was recorded.
+func Lookup(root *fsq.DeliveryRoot, thread string) (State, error) {
+	return readState(root, thread)
+}
+
+func readState(root *fsq.DeliveryRoot, thread string) (State, error) {
+	data, err := root.ReadRegularNoFollow(filepath.Join(threadDir(thread), stateFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return State{}, ErrNotFound
+		}
+		return State{}, err
+	}
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return State{}, fmt.Errorf("parse breaker state: %w", err)
+	}
+	if state.Thread != thread {
+		return State{}, fmt.Errorf("breaker state for %q belongs to thread %q", thread, state.Thread)
+	}
+	return state, nil
+}
+
+func writeState(root *fsq.DeliveryRoot, state State) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = root.WriteFileAtomic(threadDir(state.Thread), stateFile, append(data, '\n'), 0o600)
+	return err
+}
+
+func withThreadLock(root *fsq.DeliveryRoot, thread string, fn func() error) error {
+	file, err := root.OpenLockFile(breakersDir, Key(thread)+".lock", 0o600)
+	if err != nil {
+		return fmt.Errorf("open breaker lock: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+	return lock.WithExclusiveFile(file, fn)
+}
+
+// Notify delivers an urgent status message describing a trip to the notify
+// handle, so the loop is seen without polling amq breaker list. The notice is
+// sent as the tripping sender; when that is the notify handle itself, nobody
+// else needs telling and nothing is sent. The notice is subject to the
+// recipient ACLs in configFS like any other message.
+func Notify(configFS, root *fsq.DeliveryRoot, notify, sender string, state State, now time.Time) error {
+	if notify == "" || notify == sender {
+		return nil
+	}
+	recipients := []string{notify}
+	id, err := format.NewMessageID(now)
+	if err != nil {
+		return err
+	}
+	msg := format.Message{
+		Header: format.Header{
+			Schema:   format.CurrentSchema,
+			ID:       id,
+			From:     sender,
+			To:       recipients,
+			Thread:   "breaker/" + Key(state.Thread),
+			Subject:  "Loop breaker tripped on " + state.Thread,
+			Created:  now.UTC().Format(time.RFC3339Nano),
+			Priority: format.PriorityUrgent,
+			Kind:     format.KindStatus,
+			Labels:   []string{"breaker"},
+			Context: map[string]any{
+				"breaker": map[string]any{
+					"thread":    state.Thread,
+					"reason":    state.Reason,
+					"initiator": state.Initiator,
+				},
+			},
+		},
+		Body: fmt.Sprintf(
+			"Thread %s (started by %s) looks like an agent loop: %s.\n\n"+
+				"New messages in this thread are held until the breaker is reset:\n\n"+
+				"    amq breaker list --open\n"+
+				"    amq breaker reset --thread %s --release   # or --discard\n",
+			state.Thread, state.Initiator, state.Detail, state.Thread,
+		),
+	}
+	data, err := msg.Marshal()
+	if err != nil {
+		return err
+	}
+	_, err = acl.DeliverToInboxes(configFS, root, msg.Header, recipients, id+".md", data)
+	return err
+}
@@ -0,0 +1,198 @@
+package breaker
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/config"
+	"github.com/avivsinai/agent-message-queue/internal/format"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+)
+
+func openRoot(t *testing.T) (string, *fsq.DeliveryRoot) {
+	t.Helper()
+	root := t.TempDir()
+	identity, err := fsq.SnapshotDeliveryRoot(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deliveryRoot, err := fsq.OpenDeliveryRoot(root, identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = deliveryRoot.Close() })
+	return root, deliveryRoot
+}
+
+func thresholds(t *testing.T, cfg config.BreakerConfig) config.BreakerConfig {
+	t.Helper()
+	out, err := cfg.Thresholds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func message(t *testing.T, id, from, to, body string) []byte {
+	t.Helper()
+	data, err := format.Message{
+		Header: format.Header{Schema: format.CurrentSchema, ID: id, From: from, To: []string{to}, Thread: "p2p/a__b"},
+		Body:   body,
+	}.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDigestMatchesNearIdenticalBodies(t *testing.T) {
+	if Digest("Ack #3, thanks!") != Digest("ack 4 thanks") {
+		t.Fatal("near-identical bodies should share a digest")
+	}
+	if Digest("ack") == Digest("nack") {
+		t.Fatal("different bodies should not share a digest")
+	}
+}
+
+func TestAdmitTripsOnAlternationAndHoldsUntilReset(t *testing.T) {
+	root, deliveryRoot := openRoot(t)
+	limits := thresholds(t, config.BreakerConfig{MaxAlternations: 3, MaxRepeats: -1})
+	now := time.Now()
+	senders := []string{"a", "b", "a", "b", "a"}
+	var tripped Decision
+	for i, sender := range senders {
+		to := "b"
+		if sender == "b" {
+			to = "a"
+		}
+		id := string(rune('1' + i))
+		decision, err := Admit(deliveryRoot, limits, "p2p/a__b", sender, "work item "+id, id+".md", message(t, id, sender, to, "x"), now)
+		if err != nil {
+			t.Fatalf("Admit %d: %v", i, err)
+		}
+		if i < 3 && decision.Held {
+			t.Fatalf("send %d held early: %+v", i, decision.State)
+		}
+		if decision.Tripped {
+			tripped = decision
+		}
+	}
+	if !tripped.Tripped || tripped.State.Reason != ReasonAlternation || tripped.State.Initiator != "a" {
+		t.Fatalf("tripped decision = %+v", tripped)
+	}
+
+	infos, err := List(deliveryRoot)
+	if err != nil || len(infos) != 1 || len(infos[0].Held) != 2 {
+		t.Fatalf("List = %+v, %v; want one breaker with two held", infos, err)
+	}
+
+	if _, err := Reset(deliveryRoot, deliveryRoot, "p2p/a__b", false, false); err == nil {
+		t.Fatal("Reset without release or discard should refuse while messages are held")
+	}
+	result, err := Reset(deliveryRoot, deliveryRoot, "p2p/a__b", true, false)
+	if err != nil {
+		t.Fatalf("Reset release: %v", err)
+	}
+	if len(result.Released) != 2 {
+		t.Fatalf("released = %v, want 2", result.Released)
+	}
+	if _, err := os.Stat(filepath.Join(fsq.AgentInboxNew(root, "a"), "4.md")); err != nil {
+		t.Fatalf("released message not delivered: %v", err)
+	}
+	decision, err := Admit(deliveryRoot, limits, "p2p/a__b", "b", "next", "6.md", message(t, "6", "b", "a", "next"), now)
+	if err != nil || decision.Held {
+		t.Fatalf("Admit after reset = %+v, %v; want delivered", decision, err)
+	}
+}
+
+func TestAdmitTripsOnRepeatedBodiesAndDepth(t *testing.T) {
+	_, deliveryRoot := openRoot(t)
+	now := time.Now()
+	repeats := thresholds(t, config.BreakerConfig{MaxAlternations: -1, MaxRepeats: 3})
+	var last Decision
+	for i, sender := range []string{"a", "b", "a"} {
+		var err error
+		id := string(rune('1' + i))
+		last, err = Admit(deliveryRoot, repeats, "t/repeat", sender, "OK, done.", id+".md", message(t, id, sender, "b", "x"), now)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !last.Tripped || last.State.Reason != ReasonRepeatedBody {
+		t.Fatalf("repeat decision = %+v", last.State)
+	}
+
+	depth := thresholds(t, config.BreakerConfig{MaxAlternations: -1, MaxRepeats: -1, MaxDepth: 2})
+	for i := 0; i < 3; i++ {
+		var err error
+		id := string(rune('a' + i))
+		last, err = Admit(deliveryRoot, depth, "t/depth", "a", "body "+id, id+".md", message(t, id, "a", "b", "x"), now)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !last.Tripped || last.State.Reason != ReasonDepth {
+		t.Fatalf("depth decision = %+v", last.State)
+	}
+
+	// Depth counts messages within the window, not over the thread's life.
+	for i := 0; i < 6; i++ {
+		id := string(rune('a' + i))
+		at := now.Add(time.Duration(i) * 3 * time.Minute)
+		decision, err := Admit(deliveryRoot, depth, "t/long", "a", "body "+id, id+".md", message(t, id, "a", "b", "x"), at)
+		if err != nil || decision.Held {
+			t.Fatalf("spaced send %d = %+v, %v; want delivered", i, decision.State, err)
+		}
+	}
+	if defaults := thresholds(t, config.BreakerConfig{}); defaults.MaxDepth != 0 {
+		t.Fatalf("default max depth = %d, want off", defaults.MaxDepth)
+	}
+	if _, err := Reset(deliveryRoot, deliveryRoot, "t/missing", false, true); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Reset missing = %v, want ErrNotFound", err)
+	}
+}
+
+func TestAdmitDisabledRecordsNothing(t *testing.T) {
+	_, deliveryRoot := openRoot(t)
+	decision, err := Admit(deliveryRoot, config.BreakerConfig{Disabled: true}, "t", "a", "x", "1.md", nil, time.Now())
+	if err != nil || decision.Held {
+		t.Fatalf("Admit disabled = %+v, %v", decision, err)
+	}
+	if infos, _ := List(deliveryRoot); len(infos) != 0 {
+		t.Fatalf("disabled breaker recorded state: %+v", infos)
+	}
+}
+
+func TestNotifyGoesToNotifyHandleNotTheLoop(t *testing.T) {
+	root, deliveryRoot := openRoot(t)
+	for _, agent := range []string{"a", "b", config.ReservedHumanHandle} {
+		if err := fsq.EnsureAgentDirs(root, agent); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defaults := thresholds(t, config.BreakerConfig{})
+	if defaults.Notify != config.ReservedHumanHandle {
+		t.Fatalf("default notify = %q, want %q", defaults.Notify, config.ReservedHumanHandle)
+	}
+	state := State{Thread: "p2p/a__b", Initiator: "a", Reason: ReasonAlternation, Detail: "12 alternations"}
+	if err := Notify(deliveryRoot, deliveryRoot, defaults.Notify, "b", state, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	for agent, want := range map[string]int{"a": 0, "b": 0, config.ReservedHumanHandle: 1} {
+		entries, err := os.ReadDir(fsq.AgentInboxNew(root, agent))
+		if err != nil || len(entries) != want {
+			t.Fatalf("%s inbox = %d entries, %v; want %d", agent, len(entries), err, want)
+		}
+	}
+
+	// A notify handle that is itself the tripping sender is not sent a notice.
+	if err := Notify(deliveryRoot, deliveryRoot, "b", "b", state, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(fsq.AgentInboxNew(root, "b")); len(entries) != 0 {
+		t.Fatalf("self-addressed notice delivered: %d entries", len(entries))
+	}
+}
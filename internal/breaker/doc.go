@@ -0,0 +1,5 @@
+// Package breaker detects ping-pong loops between agents and trips a
+// per-thread circuit breaker. Each thread keeps a small rolling log under
+// meta/breakers/<key>/; once the breaker is open, new messages for the
+// thread are held in its held/ leaf until an operator resets it.
+package breaker
@@ -6,6 +6,8 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/avivsinai/agent-message-queue/internal/acl"
+	"github.com/avivsinai/agent-message-queue/internal/format"
 	"github.com/avivsinai/agent-message-queue/internal/fsq"
 )
 
@@ -17,11 +19,20 @@ type ApplyResult struct {
 
 // ApplyEnvelope commits the payload into the local agent's inbox under a
 // stable transfer filename keyed by (source_host, transfer_id). The same
-// digest is idempotent; a different digest for that key is a conflict.
+// digest is idempotent; a different digest for that key is a conflict. An
+// encrypted envelope must go through OpenEnvelope first; its ciphertext is
+// never committed. The local agent's ACL applies to the payload header; a
+// refusal is an *acl.DeniedError and commits nothing.
 func ApplyEnvelope(root *fsq.DeliveryRoot, localHost, localAgent string, env Envelope) (ApplyResult, error) {
 	if err := ValidateEnvelope(env); err != nil {
 		return ApplyResult{}, err
 	}
+	if env.Version != EnvelopeVersion {
+		return ApplyResult{}, fmt.Errorf("bridge envelope v%d must be opened before apply", env.Version)
+	}
+	if env.Kind != "" {
+		return ApplyResult{}, fmt.Errorf("bridge %s envelope is not an AMQ message", env.Kind)
+	}
 	destHost, destAgent, err := ParseAlias(env.DestAlias)
 	if err != nil {
 		return ApplyResult{}, err
@@ -32,10 +43,14 @@ func ApplyEnvelope(root *fsq.DeliveryRoot, localHost, localAgent string, env Env
 	if destAgent != localAgent {
 		return ApplyResult{}, fmt.Errorf("bridge dest_alias agent %q is not local agent %q", destAgent, localAgent)
 	}
+	header, err := format.ParseHeader(env.Payload)
+	if err != nil {
+		return ApplyResult{}, fmt.Errorf("parse bridge payload: %w", err)
+	}
 	filename := TransferFilename(env.SourceHost, env.TransferID)
 	rel := filepath.Join("agents", localAgent, "inbox", "new", filename)
 	_, existedErr := root.Stat(rel)
-	path, err := fsq.DeliverToExistingInbox(root, localAgent, filename, env.Payload)
+	path, err := acl.DeliverToExistingInbox(root, root, header, localAgent, filename, env.Payload)
 	if err == nil {
 		return ApplyResult{Path: path, Replayed: existedErr == nil}, nil
 	}
@@ -9,6 +9,8 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/avivsinai/agent-message-queue/internal/acl"
+	"github.com/avivsinai/agent-message-queue/internal/config"
 	"github.com/avivsinai/agent-message-queue/internal/format"
 	"github.com/avivsinai/agent-message-queue/internal/fsq"
 )
@@ -30,6 +32,18 @@ func testEnvelope(payload []byte) Envelope {
 	}
 }
 
+func applyTestMessage(t *testing.T, body string) []byte {
+	t.Helper()
+	data, err := format.Message{
+		Header: format.Header{Schema: format.CurrentSchema, ID: "msg-1", From: "codex", To: []string{"claude"}, Thread: "thread-1"},
+		Body:   body,
+	}.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
 func TestUnmarshalEnvelopeRejectsUnknownFields(t *testing.T) {
 	_, err := UnmarshalEnvelope([]byte(`{"version":1,"transfer_id":"t1","source_host":"grok-host","source_handle":"codex","dest_alias":"mac/claude","source_message_id":"m","thread_id":"t","payload_sha256":"00","key_generation":"1","signature":"` + strings.Repeat("0", 128) + `","payload":"YQ==","root":"/etc"}`))
 	if err == nil {
@@ -132,7 +146,7 @@ func TestApplyEnvelopeReplayAndConflict(t *testing.T) {
 	}
 	t.Cleanup(func() { _ = root.Close() })
 
-	payload := []byte("hello-bridge")
+	payload := applyTestMessage(t, "hello-bridge")
 	first, err := ApplyEnvelope(root, "mac", "claude", testEnvelope(payload))
 	if err != nil || first.Replayed {
 		t.Fatalf("first apply: %#v %v", first, err)
@@ -146,7 +160,7 @@ func TestApplyEnvelopeReplayAndConflict(t *testing.T) {
 		t.Fatalf("replay: %#v %v", replay, err)
 	}
 
-	conflict := testEnvelope([]byte("other-digest"))
+	conflict := testEnvelope(applyTestMessage(t, "other-digest"))
 	conflict.TransferID = "t1"
 	_, err = ApplyEnvelope(root, "mac", "claude", conflict)
 	if !errors.Is(err, os.ErrExist) {
@@ -177,3 +191,34 @@ func TestApplyEnvelopeReplayAndConflict(t *testing.T) {
 		t.Fatal("distinct source hosts shared a transfer filename")
 	}
 }
+
+func TestApplyEnvelopeEnforcesLocalACL(t *testing.T) {
+	base := t.TempDir()
+	if err := fsq.EnsureAgentDirs(base, "claude"); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Config{ACL: &config.ACLConfig{Agents: map[string]config.ACLPolicy{
+		"claude": {Deny: []config.ACLRule{{From: []string{"codex"}}}},
+	}}}
+	if err := config.WriteConfig(filepath.Join(base, "meta", "config.json"), cfg, true); err != nil {
+		t.Fatal(err)
+	}
+	identity, err := fsq.SnapshotDeliveryRoot(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := fsq.OpenDeliveryRoot(base, identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = root.Close() })
+
+	_, err = ApplyEnvelope(root, "mac", "claude", testEnvelope(applyTestMessage(t, "blocked")))
+	var denied *acl.DeniedError
+	if !errors.As(err, &denied) {
+		t.Fatalf("apply from a denied sender = %v, want *acl.DeniedError", err)
+	}
+	if entries, _ := os.ReadDir(fsq.AgentInboxNew(base, "claude")); len(entries) != 0 {
+		t.Fatalf("denied payload committed: %d inbox entries", len(entries))
+	}
+}
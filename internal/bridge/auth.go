@@ -55,7 +55,7 @@ func GenerateHostKey(generation string) (HostKey, error) {
 }
 
 func CanonicalBytes(env Envelope) []byte {
-	return []byte(strings.Join([]string{
+	fields := []string{
 		"amq-bridge-envelope-v1",
 		"version=" + strconv.Itoa(env.Version),
 		"transfer_id=" + env.TransferID,
@@ -66,7 +66,17 @@ func CanonicalBytes(env Envelope) []byte {
 		"thread_id=" + env.ThreadID,
 		"payload_sha256=" + strings.ToLower(env.PayloadSHA256),
 		"key_generation=" + env.KeyGeneration,
-	}, "\n"))
+	}
+	if env.Kind != "" {
+		fields = append(fields, "kind="+env.Kind)
+	}
+	if env.Version == EncryptedEnvelopeVersion {
+		fields = append(fields,
+			"recipient_generation="+env.RecipientGeneration,
+			"ephemeral_key="+strings.ToLower(env.EphemeralKey),
+		)
+	}
+	return []byte(strings.Join(fields, "\n"))
 }
 
 func SignEnvelope(env *Envelope, key HostKey) error {
@@ -140,44 +150,101 @@ func WriteHostID(root, host string) error {
 }
 
 func LoadIdentity(root string) (HostKey, error) {
-	fields, err := readKeyFields(IdentityPath(root), "seed")
+	key, _, err := LoadIdentityKeys(root)
+	return key, err
+}
+
+// LoadIdentityKeys returns the active signing key and the retired
+// generations kept, public half only, after identity rotate.
+func LoadIdentityKeys(root string) (HostKey, []TrustedKey, error) {
+	path := IdentityPath(root)
+	data, err := readPrivateKeyFile(path)
+	if err != nil {
+		return HostKey{}, nil, fmt.Errorf("bridge identity: %w", err)
+	}
+	identity, err := parseIdentity(path, data)
+	if err != nil {
+		return HostKey{}, nil, err
+	}
+	retired := make([]TrustedKey, 0, len(identity.retired))
+	for _, old := range identity.retired {
+		retired = append(retired, old.TrustedKey)
+	}
+	return identity.active, retired, nil
+}
+
+// identityFile is a parsed bridge/identity. Retired generations keep their
+// box secret, when they had one, so envelopes sealed to them before a
+// rotation still open during the overlap.
+type identityFile struct {
+	active  HostKey
+	retired []retiredKey
+}
+
+type retiredKey struct {
+	TrustedKey
+	boxSecret []byte
+}
+
+func parseIdentity(path string, data []byte) (identityFile, error) {
+	records, err := parseKeyRecords(path, data)
 	if err != nil {
-		return HostKey{}, fmt.Errorf("bridge identity: %w", err)
+		return identityFile{}, fmt.Errorf("bridge identity: %w", err)
 	}
-	seed, err := hex.DecodeString(fields["seed"])
+	active := records[0]
+	if err := requireRecordFields(path, active, []string{"seed"}, nil); err != nil {
+		return identityFile{}, fmt.Errorf("bridge identity: %w", err)
+	}
+	seed, err := hex.DecodeString(active["seed"])
 	if err != nil {
-		return HostKey{}, fmt.Errorf("bridge identity seed: %w", err)
+		return identityFile{}, fmt.Errorf("bridge identity seed: %w", err)
 	}
 	if len(seed) != ed25519.SeedSize {
-		return HostKey{}, fmt.Errorf("bridge identity seed must be %d bytes", ed25519.SeedSize)
+		return identityFile{}, fmt.Errorf("bridge identity seed must be %d bytes", ed25519.SeedSize)
 	}
-	return HostKey{Generation: fields["generation"], Private: ed25519.NewKeyFromSeed(seed)}, nil
+	keys, err := trustedKeysFromRecords(path, records[1:], true)
+	if err != nil {
+		return identityFile{}, fmt.Errorf("bridge identity: %w", err)
+	}
+	identity := identityFile{active: HostKey{Generation: active["generation"], Private: ed25519.NewKeyFromSeed(seed)}}
+	for i, key := range keys {
+		old := retiredKey{TrustedKey: key}
+		if raw := records[i+1]["box_secret"]; raw != "" {
+			if old.boxSecret, err = hex.DecodeString(raw); err != nil || len(old.boxSecret) != boxKeySize {
+				return identityFile{}, fmt.Errorf("bridge identity generation %s box_secret is invalid", key.Generation)
+			}
+		}
+		identity.retired = append(identity.retired, old)
+	}
+	return identity, nil
 }
 
 func WriteIdentity(root string, key HostKey) error {
 	if err := os.MkdirAll(filepath.Join(root, "bridge"), 0o700); err != nil {
 		return fmt.Errorf("create bridge directory: %w", err)
 	}
+	return writePrivateFile(IdentityPath(root), identityBytes(key, nil))
+}
+
+func identityBytes(key HostKey, retired []retiredKey) []byte {
 	body := fmt.Sprintf("generation %s\nseed %s\n", key.Generation, hex.EncodeToString(key.Private.Seed()))
-	return writePrivateFile(IdentityPath(root), []byte(body))
+	for _, old := range retired {
+		body += "\n" + old.record()
+		if len(old.boxSecret) > 0 {
+			body += "box_secret " + hex.EncodeToString(old.boxSecret) + "\n"
+		}
+	}
+	return []byte(body)
 }
 
+// LoadTrusted returns the newest trusted generation for host. Verification
+// should use LoadTrustedKeys, which also sees overlapping generations.
 func LoadTrusted(root, host string) (ed25519.PublicKey, string, error) {
-	if err := fsq.ValidateHandle(host); err != nil {
-		return nil, "", fmt.Errorf("trusted source host: %w", err)
-	}
-	fields, err := readKeyFields(TrustedPath(root, host), "public")
-	if err != nil {
-		return nil, "", fmt.Errorf("trusted host %s: %w", host, err)
-	}
-	pub, err := hex.DecodeString(fields["public"])
+	keys, err := LoadTrustedKeys(root, host)
 	if err != nil {
-		return nil, "", fmt.Errorf("trusted host %s public key: %w", host, err)
+		return nil, "", err
 	}
-	if len(pub) != ed25519.PublicKeySize {
-		return nil, "", fmt.Errorf("trusted host %s public key must be %d bytes", host, ed25519.PublicKeySize)
-	}
-	return ed25519.PublicKey(pub), fields["generation"], nil
+	return keys[0].Public, keys[0].Generation, nil
 }
 
 // LoadTrustedFromDeliveryRoot reads a trusted public key through an
@@ -185,26 +252,11 @@ func LoadTrusted(root, host string) (ed25519.PublicKey, string, error) {
 // envelope claim until this exact trusted file is loaded and signature
 // verification succeeds.
 func LoadTrustedFromDeliveryRoot(root *fsq.DeliveryRoot, host string) (ed25519.PublicKey, string, error) {
-	if err := fsq.ValidateHandle(host); err != nil {
-		return nil, "", fmt.Errorf("trusted source host: %w", err)
-	}
-	rel := filepath.Join("bridge", TrustedDirName, host)
-	data, err := readPrivateKeyRootFile(root, rel)
-	if err != nil {
-		return nil, "", fmt.Errorf("trusted host %s: %w", host, err)
-	}
-	fields, err := parseKeyFields(root.DisplayPath(rel), data, "public")
+	keys, err := LoadTrustedKeysFromDeliveryRoot(root, host)
 	if err != nil {
-		return nil, "", fmt.Errorf("trusted host %s: %w", host, err)
+		return nil, "", err
 	}
-	pub, err := hex.DecodeString(fields["public"])
-	if err != nil {
-		return nil, "", fmt.Errorf("trusted host %s public key: %w", host, err)
-	}
-	if len(pub) != ed25519.PublicKeySize {
-		return nil, "", fmt.Errorf("trusted host %s public key must be %d bytes", host, ed25519.PublicKeySize)
-	}
-	return ed25519.PublicKey(pub), fields["generation"], nil
+	return keys[0].Public, keys[0].Generation, nil
 }
 
 func WriteTrusted(root, host string, pub ed25519.PublicKey, generation string) error {
@@ -221,41 +273,64 @@ func WriteTrusted(root, host string, pub ed25519.PublicKey, generation string) e
 	if err := os.MkdirAll(filepath.Join(root, "bridge", TrustedDirName), 0o700); err != nil {
 		return fmt.Errorf("create trusted directory: %w", err)
 	}
-	body := fmt.Sprintf("generation %s\npublic %s\n", generation, hex.EncodeToString(pub))
+	body := TrustedKey{Generation: generation, Public: pub}.record()
 	return writePrivateFile(TrustedPath(root, host), []byte(body))
 }
 
-func readKeyFields(path, secretField string) (map[string]string, error) {
-	data, err := readPrivateKeyFile(path)
-	if err != nil {
-		return nil, err
+// parseKeyRecords splits a key file into blank-line separated records of
+// "field value" lines. Every record names its generation.
+func parseKeyRecords(path string, data []byte) ([]map[string]string, error) {
+	var records []map[string]string
+	line := 0
+	for _, block := range strings.Split(strings.TrimSpace(string(data)), "\n\n") {
+		fields := map[string]string{}
+		for _, raw := range strings.Split(strings.TrimSpace(block), "\n") {
+			line++
+			key, value, ok := strings.Cut(strings.TrimSpace(raw), " ")
+			if !ok || key == "" || value == "" || strings.ContainsAny(value, " \t") {
+				return nil, fmt.Errorf("%s line %d is invalid", path, line)
+			}
+			if _, exists := fields[key]; exists {
+				return nil, fmt.Errorf("%s repeats field %q", path, key)
+			}
+			fields[key] = value
+		}
+		line++
+		generation := fields["generation"]
+		if generation == "" || generation != strings.TrimSpace(generation) {
+			return nil, fmt.Errorf("%s generation is invalid", path)
+		}
+		records = append(records, fields)
 	}
-	return parseKeyFields(path, data, secretField)
+	seen := map[string]bool{}
+	for _, record := range records {
+		if seen[record["generation"]] {
+			return nil, fmt.Errorf("%s repeats generation %q", path, record["generation"])
+		}
+		seen[record["generation"]] = true
+	}
+	return records, nil
 }
 
-func parseKeyFields(path string, data []byte, secretField string) (map[string]string, error) {
-	fields := map[string]string{}
-	for i, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
-		key, value, ok := strings.Cut(strings.TrimSpace(line), " ")
-		if !ok || key == "" || value == "" || strings.ContainsAny(value, " \t") {
-			return nil, fmt.Errorf("%s line %d is invalid", path, i+1)
-		}
-		if _, exists := fields[key]; exists {
-			return nil, fmt.Errorf("%s repeats field %q", path, key)
+// requireRecordFields checks one record has generation, every required
+// field, and nothing outside required and optional.
+func requireRecordFields(path string, record map[string]string, required, optional []string) error {
+	allowed := map[string]bool{"generation": true}
+	for _, field := range required {
+		if record[field] == "" {
+			return fmt.Errorf("%s missing %s", path, field)
 		}
-		fields[key] = value
+		allowed[field] = true
 	}
-	generation := fields["generation"]
-	if generation == "" || generation != strings.TrimSpace(generation) {
-		return nil, fmt.Errorf("%s generation is invalid", path)
+	for _, field := range optional {
+		allowed[field] = true
 	}
-	if fields[secretField] == "" {
-		return nil, fmt.Errorf("%s missing %s", path, secretField)
-	}
-	if len(fields) != 2 {
-		return nil, fmt.Errorf("%s has unknown fields", path)
+	for field := range record {
+		if !allowed[field] {
+			return fmt.Errorf("%s has unknown fields", path)
+		}
 	}
-	return fields, nil
+	return nil
 }
 
 func readPrivateKeyRootFile(root *fsq.DeliveryRoot, rel string) ([]byte, error) {
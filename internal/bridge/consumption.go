@@ -0,0 +1,120 @@
+package bridge
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/avivsinai/agent-message-queue/internal/receipt"
+)
+
+// EnvelopeKindReceipt marks an envelope whose payload is a
+// ConsumptionReceipt travelling back to the original sender rather than an
+// AMQ message. Such an envelope is never committed to a Maildir.
+const EnvelopeKindReceipt = "receipt"
+
+// ConsumptionReceipt is the destination's drained or dlq receipt for one
+// bridged transfer. TransferID names the forward transfer it answers.
+type ConsumptionReceipt struct {
+	TransferID string `json:"transfer_id"`
+	MsgID      string `json:"msg_id"`
+	Thread     string `json:"thread,omitempty"`
+	Sender     string `json:"sender"`
+	Consumer   string `json:"consumer"`
+	Stage      string `json:"stage"`
+	EmittedAt  string `json:"emitted_at"`
+	Detail     string `json:"detail,omitempty"`
+}
+
+// RemoteReceiptDir is the root-relative directory where the sending host
+// keeps the drained and dlq receipts consumer on host sent back. They live
+// under bridge/ so a remote consumer never appears as a local agent and two
+// hosts with the same handle never share a file.
+func RemoteReceiptDir(host, consumer string) string {
+	return filepath.Join("bridge", "remote-receipts", host, consumer)
+}
+
+// RemoteReceiptPath is the root-relative path of one remote receipt. The
+// file name matches a local receipt's msgid__consumer__stage.json.
+func RemoteReceiptPath(host, consumer, msgID, stage string) string {
+	return filepath.Join(RemoteReceiptDir(host, consumer), fmt.Sprintf("%s__%s__%s.json", msgID, consumer, stage))
+}
+
+// ReceiptTransferID is the transfer id of the receipt envelope answering
+// forwardTransferID at stage. It is deterministic so a re-forwarded receipt
+// is an idempotent replay.
+func ReceiptTransferID(forwardTransferID, stage string) string {
+	sum := sha256.Sum256([]byte(forwardTransferID + "\x00" + stage))
+	return "rcpt-" + hex.EncodeToString(sum[:16])
+}
+
+// NewReceiptEnvelope builds the unsigned receipt envelope a destination host
+// sends back to returnAlias (the forward transfer's source_host/source_handle).
+func NewReceiptEnvelope(localHost, generation, returnAlias string, r ConsumptionReceipt) (Envelope, error) {
+	if r.Stage != receipt.StageDrained && r.Stage != receipt.StageDLQ {
+		return Envelope{}, fmt.Errorf("consumption receipt stage %q is not forwarded", r.Stage)
+	}
+	payload, err := json.Marshal(r)
+	if err != nil {
+		return Envelope{}, err
+	}
+	thread := r.Thread
+	if thread == "" {
+		thread = r.MsgID
+	}
+	digest := sha256.Sum256(payload)
+	env := Envelope{
+		Version:         EnvelopeVersion,
+		Kind:            EnvelopeKindReceipt,
+		TransferID:      ReceiptTransferID(r.TransferID, r.Stage),
+		SourceHost:      localHost,
+		SourceHandle:    r.Consumer,
+		DestAlias:       returnAlias,
+		SourceMessageID: r.MsgID,
+		ThreadID:        thread,
+		PayloadSHA256:   hex.EncodeToString(digest[:]),
+		KeyGeneration:   generation,
+		Payload:         payload,
+	}
+	return env, nil
+}
+
+// ParseConsumptionReceipt decodes an opened receipt envelope and checks the
+// payload agrees with the signed envelope fields: the consumer is the signing
+// host's source_handle and the transfer id answers the named forward
+// transfer. It does not check that the forward transfer was ever sent; the
+// caller must match it against its own transport receipts.
+func ParseConsumptionReceipt(env Envelope) (ConsumptionReceipt, error) {
+	if env.Kind != EnvelopeKindReceipt {
+		return ConsumptionReceipt{}, fmt.Errorf("bridge envelope %s is not a receipt", env.TransferID)
+	}
+	if env.Version != EnvelopeVersion {
+		return ConsumptionReceipt{}, fmt.Errorf("bridge receipt envelope v%d must be opened first", env.Version)
+	}
+	dec := json.NewDecoder(bytes.NewReader(env.Payload))
+	dec.DisallowUnknownFields()
+	var r ConsumptionReceipt
+	if err := dec.Decode(&r); err != nil {
+		return ConsumptionReceipt{}, fmt.Errorf("bridge receipt payload: %w", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return ConsumptionReceipt{}, fmt.Errorf("bridge receipt payload has trailing data")
+	}
+	if r.Stage != receipt.StageDrained && r.Stage != receipt.StageDLQ {
+		return ConsumptionReceipt{}, fmt.Errorf("bridge receipt stage %q is not accepted", r.Stage)
+	}
+	if r.Consumer != env.SourceHandle || r.MsgID != env.SourceMessageID {
+		return ConsumptionReceipt{}, fmt.Errorf("bridge receipt payload does not match envelope %s", env.TransferID)
+	}
+	if err := validateTransferID(r.TransferID); err != nil {
+		return ConsumptionReceipt{}, err
+	}
+	if env.TransferID != ReceiptTransferID(r.TransferID, r.Stage) {
+		return ConsumptionReceipt{}, fmt.Errorf("bridge receipt transfer_id does not answer %s", r.TransferID)
+	}
+	return r, nil
+}
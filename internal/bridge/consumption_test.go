@@ -0,0 +1,54 @@
+package bridge
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/avivsinai/agent-message-queue/internal/receipt"
+)
+
+func TestReceiptEnvelopeBindsKindAndPayload(t *testing.T) {
+	key, err := GenerateHostKey("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	consumed := ConsumptionReceipt{
+		TransferID: "xfer-forward",
+		MsgID:      "msg-1",
+		Sender:     "codex",
+		Consumer:   "claude",
+		Stage:      receipt.StageDLQ,
+		EmittedAt:  "2026-09-01T12:00:00Z",
+	}
+	env, err := NewReceiptEnvelope("mac", "1", "grok/codex", consumed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SignEnvelope(&env, key); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ParseConsumptionReceipt(env); err != nil || got != consumed {
+		t.Fatalf("ParseConsumptionReceipt = %+v, %v; want the original receipt", got, err)
+	}
+
+	// Dropping the kind would turn the receipt into a message; the signature
+	// covers it.
+	message := env
+	message.Kind = ""
+	if err := VerifyEnvelope(message, key.Public(), "1"); err == nil {
+		t.Fatal("stripped kind still verified")
+	}
+	if _, err := ApplyEnvelope(nil, "grok", "codex", env); err == nil || !strings.Contains(err.Error(), "not an AMQ message") {
+		t.Fatalf("apply of a receipt envelope = %v, want refusal", err)
+	}
+
+	otherConsumer := env
+	otherConsumer.SourceHandle = "codex"
+	if _, err := ParseConsumptionReceipt(otherConsumer); err == nil {
+		t.Fatal("receipt accepted for a consumer other than the signing handle")
+	}
+	consumed.Stage = receipt.StageClaimed
+	if _, err := NewReceiptEnvelope("mac", "1", "grok/codex", consumed); err == nil {
+		t.Fatal("claimed receipt was forwarded")
+	}
+}
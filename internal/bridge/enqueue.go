@@ -28,6 +28,17 @@ func Enqueue(cfg EnqueueConfig, destAlias string, message []byte) (EnqueueResult
 	if _, ok := cfg.AllowedDestSet()[destAlias]; !ok {
 		return EnqueueResult{}, fmt.Errorf("destination alias %q is not in the allowlist", destAlias)
 	}
+	return Spool(cfg.Root, cfg.SourceHandle, destAlias, message)
+}
+
+// Spool writes one complete AMQ message from sourceHandle into root's bridge
+// outbound spool, bound to destAlias by a dest sidecar. It does not check an
+// allowlist; the courier refuses a sidecar alias it does not allow.
+func Spool(root, sourceHandle, destAlias string, message []byte) (EnqueueResult, error) {
+	destAlias = strings.TrimSpace(destAlias)
+	if _, _, err := ParseAlias(destAlias); err != nil {
+		return EnqueueResult{}, fmt.Errorf("destination alias: %w", err)
+	}
 	if len(message) == 0 {
 		return EnqueueResult{}, fmt.Errorf("message is empty")
 	}
@@ -45,8 +56,8 @@ func Enqueue(cfg EnqueueConfig, destAlias string, message []byte) (EnqueueResult
 	if parsed.Header.Thread == "" || strings.TrimSpace(parsed.Header.Thread) != parsed.Header.Thread {
 		return EnqueueResult{}, fmt.Errorf("message thread is invalid")
 	}
-	if parsed.Header.From != cfg.SourceHandle {
-		return EnqueueResult{}, fmt.Errorf("message sender %q does not match source handle %q", parsed.Header.From, cfg.SourceHandle)
+	if parsed.Header.From != sourceHandle {
+		return EnqueueResult{}, fmt.Errorf("message sender %q does not match source handle %q", parsed.Header.From, sourceHandle)
 	}
 
 	filename := parsed.Header.ID + ".md"
@@ -54,11 +65,11 @@ func Enqueue(cfg EnqueueConfig, destAlias string, message []byte) (EnqueueResult
 		return EnqueueResult{}, fmt.Errorf("message filename: %w", err)
 	}
 
-	root, err := filepath.Abs(cfg.Root)
+	root, err = filepath.Abs(root)
 	if err != nil {
 		return EnqueueResult{}, fmt.Errorf("resolve bridge root: %w", err)
 	}
-	spoolDir := filepath.Join(root, "bridge", "outbox", cfg.SourceHandle, "new")
+	spoolDir := filepath.Join(root, "bridge", "outbox", sourceHandle, "new")
 	if err := os.MkdirAll(spoolDir, 0o700); err != nil {
 		return EnqueueResult{}, fmt.Errorf("create bridge spool: %w", err)
 	}
@@ -20,55 +20,65 @@ type EnqueueConfig struct {
 	SourceHost         string   `json:"source_host"`
 	SourceHandle       string   `json:"source_handle"`
 	AllowedDestAliases []string `json:"allowed_dest_aliases"`
+	Routes             []Route  `json:"routes,omitempty"`
 }
 
 // LoadEnqueueConfig reads and validates a private mode-0600 enqueue config file.
 func LoadEnqueueConfig(path string) (EnqueueConfig, error) {
+	var cfg EnqueueConfig
+	if err := readPrivateConfig(path, "enqueue config", &cfg); err != nil {
+		return EnqueueConfig{}, err
+	}
+	if err := ValidateEnqueueConfig(cfg); err != nil {
+		return EnqueueConfig{}, err
+	}
+	return normalizeEnqueueConfig(cfg)
+}
+
+// readPrivateConfig strictly decodes one JSON object from a private,
+// non-symlink mode-0600 file.
+func readPrivateConfig(path, kind string, out any) error {
 	info, err := os.Lstat(path)
 	if err != nil {
-		return EnqueueConfig{}, fmt.Errorf("stat enqueue config: %w", err)
+		return fmt.Errorf("stat %s: %w", kind, err)
 	}
 	if info.Mode()&os.ModeSymlink != 0 {
-		return EnqueueConfig{}, fmt.Errorf("enqueue config %s must not be a symlink", path)
+		return fmt.Errorf("%s %s must not be a symlink", kind, path)
 	}
 	if !info.Mode().IsRegular() {
-		return EnqueueConfig{}, fmt.Errorf("enqueue config %s must be a regular file", path)
+		return fmt.Errorf("%s %s must be a regular file", kind, path)
 	}
 	if got := info.Mode().Perm(); got != 0o600 {
-		return EnqueueConfig{}, fmt.Errorf("enqueue config %s mode is %o, want 0600", path, got)
+		return fmt.Errorf("%s %s mode is %o, want 0600", kind, path, got)
 	}
 
 	file, _, err := fsq.OpenRegularNoFollow(path)
 	if err != nil {
-		return EnqueueConfig{}, fmt.Errorf("open enqueue config: %w", err)
+		return fmt.Errorf("open %s: %w", kind, err)
 	}
 	defer func() { _ = file.Close() }()
 
 	data, err := io.ReadAll(io.LimitReader(file, maxEnqueueConfigSize+1))
 	if err != nil {
-		return EnqueueConfig{}, fmt.Errorf("read enqueue config: %w", err)
+		return fmt.Errorf("read %s: %w", kind, err)
 	}
 	if len(data) > maxEnqueueConfigSize {
-		return EnqueueConfig{}, fmt.Errorf("enqueue config exceeds %d bytes", maxEnqueueConfigSize)
+		return fmt.Errorf("%s exceeds %d bytes", kind, maxEnqueueConfigSize)
 	}
 
 	dec := json.NewDecoder(bytes.NewReader(data))
 	dec.DisallowUnknownFields()
-	var cfg EnqueueConfig
-	if err := dec.Decode(&cfg); err != nil {
-		return EnqueueConfig{}, fmt.Errorf("parse enqueue config: %w", err)
+	if err := dec.Decode(out); err != nil {
+		return fmt.Errorf("parse %s: %w", kind, err)
 	}
 	var trailing any
 	if err := dec.Decode(&trailing); err != io.EOF {
 		if err == nil {
-			return EnqueueConfig{}, fmt.Errorf("enqueue config has trailing JSON")
+			return fmt.Errorf("%s has trailing JSON", kind)
 		}
-		return EnqueueConfig{}, fmt.Errorf("parse enqueue config trailer: %w", err)
+		return fmt.Errorf("parse %s trailer: %w", kind, err)
 	}
-	if err := ValidateEnqueueConfig(cfg); err != nil {
-		return EnqueueConfig{}, err
-	}
-	return normalizeEnqueueConfig(cfg)
+	return nil
 }
 
 // ValidateEnqueueConfig checks the decoded enqueue configuration.
@@ -82,6 +92,15 @@ func ValidateEnqueueConfig(cfg EnqueueConfig) error {
 	if err := fsq.ValidateHandle(cfg.SourceHandle); err != nil {
 		return fmt.Errorf("enqueue config source_handle: %w", err)
 	}
+	if len(cfg.Routes) > 0 {
+		if len(cfg.AllowedDestAliases) != 0 {
+			return fmt.Errorf("enqueue config allowed_dest_aliases must be empty with routes; list dest_aliases per route")
+		}
+		if err := ValidateRoutes(cfg.Routes); err != nil {
+			return fmt.Errorf("enqueue config %w", err)
+		}
+		return nil
+	}
 	if len(cfg.AllowedDestAliases) == 0 {
 		return fmt.Errorf("enqueue config allowed_dest_aliases must not be empty")
 	}
@@ -112,8 +131,14 @@ func normalizeEnqueueConfig(cfg EnqueueConfig) (EnqueueConfig, error) {
 }
 
 // AllowedDestSet returns the configured destination aliases as a lookup set.
+// With a routing table, these are the dest_aliases of every route.
 func (cfg EnqueueConfig) AllowedDestSet() map[string]struct{} {
 	set := make(map[string]struct{}, len(cfg.AllowedDestAliases))
+	for _, route := range cfg.Routes {
+		for _, alias := range route.DestAliases {
+			set[strings.TrimSpace(alias)] = struct{}{}
+		}
+	}
 	for _, alias := range cfg.AllowedDestAliases {
 		set[strings.TrimSpace(alias)] = struct{}{}
 	}
@@ -12,9 +12,17 @@ import (
 	"github.com/avivsinai/agent-message-queue/internal/fsq"
 )
 
-const EnvelopeVersion = 1
+const (
+	EnvelopeVersion = 1
+	// EncryptedEnvelopeVersion carries the payload sealed to the destination
+	// host's X25519 box key; see SealEnvelope.
+	EncryptedEnvelopeVersion = 2
+)
 
-// Envelope is the v1 amq-bridge wire unit. Extra JSON fields are rejected.
+// Envelope is the amq-bridge wire unit. Extra JSON fields are rejected. v1
+// carries the AMQ message in the clear; v2 adds recipient_generation and
+// ephemeral_key and carries ciphertext, which payload_sha256 and the
+// signature cover.
 type Envelope struct {
 	Version         int    `json:"version"`
 	TransferID      string `json:"transfer_id"`
@@ -27,6 +35,13 @@ type Envelope struct {
 	KeyGeneration   string `json:"key_generation"`
 	Signature       string `json:"signature"`
 	Payload         []byte `json:"payload"`
+
+	RecipientGeneration string `json:"recipient_generation,omitempty"`
+	EphemeralKey        string `json:"ephemeral_key,omitempty"`
+
+	// Kind is empty for an AMQ message and EnvelopeKindReceipt for a
+	// consumption receipt travelling back to the sender.
+	Kind string `json:"kind,omitempty"`
 }
 
 func MarshalEnvelope(env Envelope) ([]byte, error) {
@@ -57,9 +72,24 @@ func UnmarshalEnvelope(raw []byte) (Envelope, error) {
 }
 
 func ValidateEnvelope(env Envelope) error {
-	if env.Version != EnvelopeVersion {
+	switch env.Version {
+	case EnvelopeVersion:
+		if env.RecipientGeneration != "" || env.EphemeralKey != "" {
+			return fmt.Errorf("bridge envelope v1 must not carry encryption fields")
+		}
+	case EncryptedEnvelopeVersion:
+		if env.RecipientGeneration == "" || env.RecipientGeneration != strings.TrimSpace(env.RecipientGeneration) {
+			return fmt.Errorf("bridge envelope recipient_generation is invalid")
+		}
+		if key, err := hex.DecodeString(env.EphemeralKey); err != nil || len(key) != boxKeySize {
+			return fmt.Errorf("bridge envelope ephemeral_key is invalid")
+		}
+	default:
 		return fmt.Errorf("bridge envelope version %d is unsupported", env.Version)
 	}
+	if env.Kind != "" && env.Kind != EnvelopeKindReceipt {
+		return fmt.Errorf("bridge envelope kind %q is unsupported", env.Kind)
+	}
 	for _, field := range []struct {
 		name, value string
 	}{
@@ -0,0 +1,102 @@
+package bridge
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+)
+
+// Route maps one peer host to the transport that reaches it: a rendezvous
+// URL or a shared directory, never both. DestAliases are the aliases on Host
+// this side may send to. Inbound accepts envelopes signed by Host, and only
+// over this route's transport. ReceiveAlias is the local alias Host sends to;
+// empty means the courier's own receive alias.
+type Route struct {
+	Host         string   `json:"host"`
+	Rendezvous   string   `json:"rendezvous,omitempty"`
+	SharedDir    string   `json:"shared_dir,omitempty"`
+	DestAliases  []string `json:"dest_aliases,omitempty"`
+	Inbound      bool     `json:"inbound,omitempty"`
+	ReceiveAlias string   `json:"receive_alias,omitempty"`
+}
+
+// RoutingTable is the courier routes file: {"routes": [...]}.
+type RoutingTable struct {
+	Routes []Route `json:"routes"`
+}
+
+// LoadRoutingTable reads and validates a private mode-0600 routes file.
+func LoadRoutingTable(path string) (RoutingTable, error) {
+	var table RoutingTable
+	if err := readPrivateConfig(path, "routes file", &table); err != nil {
+		return RoutingTable{}, err
+	}
+	if len(table.Routes) == 0 {
+		return RoutingTable{}, fmt.Errorf("routes file %s has no routes", path)
+	}
+	if err := ValidateRoutes(table.Routes); err != nil {
+		return RoutingTable{}, fmt.Errorf("routes file %w", err)
+	}
+	return table, nil
+}
+
+// ValidateRoutes checks the shape of a routing table. Transport policy such
+// as the rendezvous scheme is enforced by the courier that uses it.
+func ValidateRoutes(routes []Route) error {
+	hosts := make(map[string]struct{}, len(routes))
+	for _, route := range routes {
+		if err := fsq.ValidateHandle(route.Host); err != nil {
+			return fmt.Errorf("route host: %w", err)
+		}
+		if _, ok := hosts[route.Host]; ok {
+			return fmt.Errorf("route for host %q is listed twice", route.Host)
+		}
+		hosts[route.Host] = struct{}{}
+		rendezvous := strings.TrimSpace(route.Rendezvous)
+		shared := strings.TrimSpace(route.SharedDir)
+		if (rendezvous == "") == (shared == "") {
+			return fmt.Errorf("route for host %q must set exactly one of rendezvous or shared_dir", route.Host)
+		}
+		if len(route.DestAliases) == 0 && !route.Inbound {
+			return fmt.Errorf("route for host %q has no dest_aliases and is not inbound", route.Host)
+		}
+		if route.ReceiveAlias != "" {
+			if !route.Inbound {
+				return fmt.Errorf("route for host %q sets receive_alias but is not inbound", route.Host)
+			}
+			host, _, err := ParseAlias(route.ReceiveAlias)
+			if err != nil {
+				return fmt.Errorf("route for host %q receive_alias: %w", route.Host, err)
+			}
+			if host == route.Host {
+				return fmt.Errorf("route for host %q receive_alias %q is on the peer, not this host", route.Host, route.ReceiveAlias)
+			}
+		}
+		seen := make(map[string]struct{}, len(route.DestAliases))
+		for _, alias := range route.DestAliases {
+			host, _, err := ParseAlias(alias)
+			if err != nil {
+				return fmt.Errorf("route for host %q dest_aliases: %w", route.Host, err)
+			}
+			if host != route.Host {
+				return fmt.Errorf("route for host %q lists dest alias %q on another host", route.Host, alias)
+			}
+			if _, ok := seen[alias]; ok {
+				return fmt.Errorf("route for host %q lists dest alias %q twice", route.Host, alias)
+			}
+			seen[alias] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// RouteFor returns the route for host.
+func RouteFor(routes []Route, host string) (Route, bool) {
+	for _, route := range routes {
+		if route.Host == host {
+			return route, true
+		}
+	}
+	return Route{}, false
+}
@@ -0,0 +1,66 @@
+package bridge
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+)
+
+func TestValidateRoutesRejectsAmbiguousRoutes(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		routes []Route
+		want   string
+	}{
+		{"both transports", []Route{{Host: "mac", Rendezvous: "https://relay.example", SharedDir: "/sync", Inbound: true}}, "exactly one"},
+		{"no transport", []Route{{Host: "mac", Inbound: true}}, "exactly one"},
+		{"duplicate host", []Route{{Host: "mac", SharedDir: "/a", Inbound: true}, {Host: "mac", SharedDir: "/b", Inbound: true}}, "twice"},
+		{"alias on another host", []Route{{Host: "mac", SharedDir: "/a", DestAliases: []string{"grok/claude"}}}, "another host"},
+		{"unused route", []Route{{Host: "mac", SharedDir: "/a"}}, "not inbound"},
+		{"receive alias without inbound", []Route{{Host: "mac", SharedDir: "/a", DestAliases: []string{"mac/claude"}, ReceiveAlias: "grok/codex"}}, "not inbound"},
+		{"receive alias on the peer", []Route{{Host: "mac", SharedDir: "/a", Inbound: true, ReceiveAlias: "mac/codex"}}, "on the peer"},
+	} {
+		if err := ValidateRoutes(test.routes); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: ValidateRoutes = %v, want %q", test.name, err, test.want)
+		}
+	}
+}
+
+func TestRoutedEnqueueConfigAllowsOnlyRoutedAliases(t *testing.T) {
+	root := t.TempDir()
+	if err := fsq.EnsureRootDirs(root); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(root, "enqueue.json")
+	config := `{
+  "root": "` + root + `",
+  "source_host": "grok-host",
+  "source_handle": "codex",
+  "allowed_dest_aliases": [],
+  "routes": [
+    {"host": "mac", "rendezvous": "https://relay.example", "dest_aliases": ["mac/claude"]},
+    {"host": "linux", "shared_dir": "/srv/amq-sync", "dest_aliases": ["linux/claude"], "inbound": true}
+  ]
+}`
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadEnqueueConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Enqueue(cfg, "linux/claude", testBridgeMessage(t, "msg-routed", "thread-routed", "codex", "hi")); err != nil {
+		t.Fatalf("routed enqueue = %v", err)
+	}
+	if _, err := Enqueue(cfg, "linux/cursor", testBridgeMessage(t, "msg-unrouted", "thread-unrouted", "codex", "hi")); err == nil || !strings.Contains(err.Error(), "allowlist") {
+		t.Fatalf("unrouted enqueue = %v, want allowlist refusal", err)
+	}
+
+	cfg.AllowedDestAliases = []string{"mac/claude"}
+	if err := ValidateEnqueueConfig(cfg); err == nil || !strings.Contains(err.Error(), "must be empty with routes") {
+		t.Fatalf("flat allowlist with routes = %v, want refusal", err)
+	}
+}
@@ -0,0 +1,202 @@
+package bridge
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+)
+
+const boxKeySize = 32
+
+// BoxKey is the generation's X25519 key for v2 envelopes. It is derived from
+// the Ed25519 seed, so every identity already has one and it rotates with
+// the signing generation.
+func (k HostKey) BoxKey() (*ecdh.PrivateKey, error) {
+	sum := sha256.Sum256(append([]byte("amq-bridge-box-v1\x00"), k.Private.Seed()...))
+	return ecdh.X25519().NewPrivateKey(sum[:])
+}
+
+// SealEnvelope turns an unsigned v1 envelope into v2 by encrypting its
+// payload to the recipient generation's box key. The ephemeral key is derived
+// from the sender seed, the transfer, and the plaintext digest, so resealing
+// the same spool item yields the same ciphertext and the same
+// payload_sha256; retries stay idempotent at the rendezvous. Sign afterwards:
+// the signature covers the ciphertext digest.
+func SealEnvelope(env *Envelope, sender HostKey, recipientGeneration string, recipientBox []byte) error {
+	if env == nil {
+		return fmt.Errorf("bridge envelope is required")
+	}
+	if env.Version != EnvelopeVersion {
+		return fmt.Errorf("bridge envelope v%d is already sealed", env.Version)
+	}
+	recipient, err := ecdh.X25519().NewPublicKey(recipientBox)
+	if err != nil {
+		return fmt.Errorf("recipient box key: %w", err)
+	}
+	seed := sha256.Sum256([]byte(strings.Join([]string{
+		"amq-bridge-ephemeral-v1",
+		hex.EncodeToString(sender.Private.Seed()),
+		env.TransferID,
+		strings.ToLower(env.PayloadSHA256),
+		recipientGeneration,
+		hex.EncodeToString(recipientBox),
+	}, "\x00")))
+	ephemeral, err := ecdh.X25519().NewPrivateKey(seed[:])
+	if err != nil {
+		return err
+	}
+	sealed := *env
+	sealed.Version = EncryptedEnvelopeVersion
+	sealed.RecipientGeneration = recipientGeneration
+	sealed.EphemeralKey = hex.EncodeToString(ephemeral.PublicKey().Bytes())
+	aead, err := envelopeAEAD(ephemeral, recipient, ephemeral.PublicKey().Bytes(), recipientBox)
+	if err != nil {
+		return err
+	}
+	sealed.Payload = aead.Seal(nil, make([]byte, aead.NonceSize()), env.Payload, sealedAAD(sealed))
+	digest := sha256.Sum256(sealed.Payload)
+	sealed.PayloadSHA256 = hex.EncodeToString(digest[:])
+	*env = sealed
+	return nil
+}
+
+// OpenEnvelope returns the plaintext v1 form of a verified envelope for
+// ApplyEnvelope. v1 input is returned unchanged. A v2 envelope whose
+// recipient generation has no local box key, or whose ciphertext does not
+// authenticate, is an error: nothing is applied. The returned envelope keeps
+// the wire signature only to stay well formed; verify before opening.
+func OpenEnvelope(env Envelope, boxKeys map[string]*ecdh.PrivateKey) (Envelope, error) {
+	if err := ValidateEnvelope(env); err != nil {
+		return Envelope{}, err
+	}
+	if env.Version == EnvelopeVersion {
+		return env, nil
+	}
+	key := boxKeys[env.RecipientGeneration]
+	if key == nil {
+		return Envelope{}, fmt.Errorf("no local box key for recipient_generation %q", env.RecipientGeneration)
+	}
+	rawEphemeral, err := hex.DecodeString(env.EphemeralKey)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("bridge envelope ephemeral_key: %w", err)
+	}
+	ephemeral, err := ecdh.X25519().NewPublicKey(rawEphemeral)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("bridge envelope ephemeral_key: %w", err)
+	}
+	aead, err := envelopeAEAD(key, ephemeral, rawEphemeral, key.PublicKey().Bytes())
+	if err != nil {
+		return Envelope{}, err
+	}
+	plain, err := aead.Open(nil, make([]byte, aead.NonceSize()), env.Payload, sealedAAD(env))
+	if err != nil {
+		return Envelope{}, fmt.Errorf("decrypt transfer %s: %w", env.TransferID, err)
+	}
+	opened := env
+	opened.Version = EnvelopeVersion
+	opened.RecipientGeneration = ""
+	opened.EphemeralKey = ""
+	opened.Payload = plain
+	digest := sha256.Sum256(plain)
+	opened.PayloadSHA256 = hex.EncodeToString(digest[:])
+	if err := ValidateEnvelope(opened); err != nil {
+		return Envelope{}, fmt.Errorf("decrypt transfer %s: %w", env.TransferID, err)
+	}
+	return opened, nil
+}
+
+// envelopeAEAD derives the AES-256-GCM key for one ephemeral/recipient
+// exchange. The key is unique per ephemeral key, so a fixed nonce is safe.
+func envelopeAEAD(private *ecdh.PrivateKey, peer *ecdh.PublicKey, ephemeral, recipient []byte) (cipher.AEAD, error) {
+	shared, err := private.ECDH(peer)
+	if err != nil {
+		return nil, fmt.Errorf("box key agreement: %w", err)
+	}
+	salt := append(append([]byte{}, ephemeral...), recipient...)
+	key, err := hkdf.Key(sha256.New, shared, salt, "amq-bridge-envelope-v2", 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealedAAD binds the ciphertext to its routing so it cannot be replayed
+// under another transfer, sender, or destination.
+func sealedAAD(env Envelope) []byte {
+	return []byte(strings.Join([]string{
+		"amq-bridge-envelope-v2",
+		env.TransferID,
+		env.SourceHost,
+		env.DestAlias,
+		env.RecipientGeneration,
+	}, "\n"))
+}
+
+// LoadBoxKeys returns the local box keys by generation: the active one and
+// every retired generation still inside its overlap.
+func LoadBoxKeys(root string) (map[string]*ecdh.PrivateKey, error) {
+	path := IdentityPath(root)
+	data, err := readPrivateKeyFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("bridge identity: %w", err)
+	}
+	return boxKeysFromIdentity(path, data, time.Now())
+}
+
+// LoadBoxKeysFromDeliveryRoot is LoadBoxKeys through an already-authorized
+// delivery-root capability.
+func LoadBoxKeysFromDeliveryRoot(root *fsq.DeliveryRoot) (map[string]*ecdh.PrivateKey, error) {
+	const rel = "bridge/identity"
+	data, err := readPrivateKeyRootFile(root, rel)
+	if err != nil {
+		return nil, fmt.Errorf("bridge identity: %w", err)
+	}
+	return boxKeysFromIdentity(root.DisplayPath(rel), data, time.Now())
+}
+
+func boxKeysFromIdentity(path string, data []byte, now time.Time) (map[string]*ecdh.PrivateKey, error) {
+	identity, err := parseIdentity(path, data)
+	if err != nil {
+		return nil, err
+	}
+	active, err := identity.active.BoxKey()
+	if err != nil {
+		return nil, err
+	}
+	keys := map[string]*ecdh.PrivateKey{identity.active.Generation: active}
+	for _, old := range identity.retired {
+		if len(old.boxSecret) == 0 || !old.ValidAt(now) {
+			continue
+		}
+		key, err := ecdh.X25519().NewPrivateKey(old.boxSecret)
+		if err != nil {
+			return nil, fmt.Errorf("bridge identity generation %s box_secret: %w", old.Generation, err)
+		}
+		keys[old.Generation] = key
+	}
+	return keys, nil
+}
+
+// PeerBoxKey picks the box key to seal envelopes for a peer: the newest
+// trusted generation that is still valid and publishes one. ok is false when
+// the peer has not published a box key, and the sender keeps using v1.
+func PeerBoxKey(keys []TrustedKey, now time.Time) (generation string, box []byte, ok bool) {
+	for _, key := range keys {
+		if len(key.Box) == boxKeySize && key.ValidAt(now) {
+			return key.Generation, key.Box, true
+		}
+	}
+	return "", nil, false
+}
@@ -0,0 +1,108 @@
+package bridge
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"strings"
+	"testing"
+
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+)
+
+func TestSealedEnvelopeRoundTripAndFailsClosed(t *testing.T) {
+	sender, err := GenerateHostKey("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipient, err := GenerateHostKey("7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	box, err := recipient.BoxKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := []byte("the relay must not read this")
+	seal := func() Envelope {
+		t.Helper()
+		env := testEnvelope(plaintext)
+		env.Signature = ""
+		if err := SealEnvelope(&env, sender, "7", box.PublicKey().Bytes()); err != nil {
+			t.Fatal(err)
+		}
+		if err := SignEnvelope(&env, sender); err != nil {
+			t.Fatal(err)
+		}
+		return env
+	}
+	env := seal()
+	if env.Version != EncryptedEnvelopeVersion || bytes.Contains(env.Payload, plaintext) {
+		t.Fatalf("sealed envelope = v%d payload %q, want v2 ciphertext", env.Version, env.Payload)
+	}
+	if again := seal(); again.PayloadSHA256 != env.PayloadSHA256 {
+		t.Fatal("resealing the same transfer changed the ciphertext digest")
+	}
+	raw, err := MarshalEnvelope(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := UnmarshalEnvelope(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyEnvelope(decoded, sender.Public(), "1"); err != nil {
+		t.Fatalf("signature over ciphertext: %v", err)
+	}
+	keys := map[string]*ecdh.PrivateKey{"7": box}
+	opened, err := OpenEnvelope(decoded, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened.Version != EnvelopeVersion || !bytes.Equal(opened.Payload, plaintext) {
+		t.Fatalf("opened = v%d %q, want the v1 plaintext", opened.Version, opened.Payload)
+	}
+
+	// The signature binds the recipient generation and ephemeral key.
+	moved := decoded
+	moved.RecipientGeneration = "8"
+	if err := VerifyEnvelope(moved, sender.Public(), "1"); err == nil {
+		t.Fatal("re-targeted recipient_generation still verified")
+	}
+	// Routing is bound into the ciphertext, not only the signature.
+	rerouted := decoded
+	rerouted.DestAlias = "mac/codex"
+	if _, err := OpenEnvelope(rerouted, keys); err == nil || !strings.Contains(err.Error(), "decrypt") {
+		t.Fatalf("rerouted ciphertext = %v, want decrypt failure", err)
+	}
+	other, err := GenerateHostKey("7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherBox, err := other.BoxKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenEnvelope(decoded, map[string]*ecdh.PrivateKey{"7": otherBox}); err == nil {
+		t.Fatal("envelope opened with the wrong box key")
+	}
+	if _, err := OpenEnvelope(decoded, map[string]*ecdh.PrivateKey{"1": box}); err == nil || !strings.Contains(err.Error(), "no local box key") {
+		t.Fatalf("unknown recipient generation = %v, want refusal", err)
+	}
+
+	base := t.TempDir()
+	if err := fsq.EnsureAgentDirs(base, "claude"); err != nil {
+		t.Fatal(err)
+	}
+	identity, err := fsq.SnapshotDeliveryRoot(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := fsq.OpenDeliveryRoot(base, identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = root.Close() })
+	if _, err := ApplyEnvelope(root, "mac", "claude", decoded); err == nil || !strings.Contains(err.Error(), "opened") {
+		t.Fatalf("apply of sealed envelope = %v, want refusal to commit ciphertext", err)
+	}
+}
@@ -0,0 +1,301 @@
+package bridge
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+)
+
+// TrustAuditFileName is the append-only log of trust and rotation changes.
+const TrustAuditFileName = "trust-audit.jsonl"
+
+// TrustedKey is one public generation of a host principal. A zero NotAfter
+// never expires; otherwise the generation verifies envelopes only until then.
+// Box is the generation's X25519 public key; a peer that publishes one
+// receives v2 encrypted envelopes.
+type TrustedKey struct {
+	Generation string
+	Public     ed25519.PublicKey
+	NotAfter   time.Time
+	Box        []byte
+}
+
+// ValidAt reports whether the generation may still verify envelopes at now.
+func (k TrustedKey) ValidAt(now time.Time) bool {
+	return k.NotAfter.IsZero() || now.Before(k.NotAfter)
+}
+
+func (k TrustedKey) record() string {
+	body := fmt.Sprintf("generation %s\npublic %s\n", k.Generation, hex.EncodeToString(k.Public))
+	if !k.NotAfter.IsZero() {
+		body += "not_after " + k.NotAfter.UTC().Format(time.RFC3339) + "\n"
+	}
+	if len(k.Box) > 0 {
+		body += "box " + hex.EncodeToString(k.Box) + "\n"
+	}
+	return body
+}
+
+func TrustAuditPath(root string) string {
+	return filepath.Join(root, "bridge", TrustAuditFileName)
+}
+
+// LoadTrustedKeys returns every trusted generation for host, newest first,
+// including expired ones; VerifyEnvelopeTrusted enforces not_after.
+func LoadTrustedKeys(root, host string) ([]TrustedKey, error) {
+	if err := fsq.ValidateHandle(host); err != nil {
+		return nil, fmt.Errorf("trusted source host: %w", err)
+	}
+	path := TrustedPath(root, host)
+	data, err := readPrivateKeyFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("trusted host %s: %w", host, err)
+	}
+	return parseTrustedKeys(host, path, data)
+}
+
+// LoadTrustedKeysFromDeliveryRoot is LoadTrustedKeys through an
+// already-authorized delivery-root capability.
+func LoadTrustedKeysFromDeliveryRoot(root *fsq.DeliveryRoot, host string) ([]TrustedKey, error) {
+	if err := fsq.ValidateHandle(host); err != nil {
+		return nil, fmt.Errorf("trusted source host: %w", err)
+	}
+	rel := filepath.Join("bridge", TrustedDirName, host)
+	data, err := readPrivateKeyRootFile(root, rel)
+	if err != nil {
+		return nil, fmt.Errorf("trusted host %s: %w", host, err)
+	}
+	return parseTrustedKeys(host, root.DisplayPath(rel), data)
+}
+
+func parseTrustedKeys(host, path string, data []byte) ([]TrustedKey, error) {
+	records, err := parseKeyRecords(path, data)
+	if err != nil {
+		return nil, fmt.Errorf("trusted host %s: %w", host, err)
+	}
+	keys, err := trustedKeysFromRecords(path, records, false)
+	if err != nil {
+		return nil, fmt.Errorf("trusted host %s: %w", host, err)
+	}
+	return keys, nil
+}
+
+// trustedKeysFromRecords parses public key records. Retired identity records
+// always carry not_after and may keep their box_secret; trusted peer records
+// may publish a box key.
+func trustedKeysFromRecords(path string, records []map[string]string, retired bool) ([]TrustedKey, error) {
+	keys := make([]TrustedKey, 0, len(records))
+	for _, record := range records {
+		required := []string{"public"}
+		optional := []string{"not_after", "box"}
+		if retired {
+			required, optional = []string{"public", "not_after"}, []string{"box", "box_secret"}
+		}
+		if err := requireRecordFields(path, record, required, optional); err != nil {
+			return nil, err
+		}
+		pub, err := hex.DecodeString(record["public"])
+		if err != nil {
+			return nil, fmt.Errorf("%s generation %s public key: %w", path, record["generation"], err)
+		}
+		if len(pub) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%s generation %s public key must be %d bytes", path, record["generation"], ed25519.PublicKeySize)
+		}
+		key := TrustedKey{Generation: record["generation"], Public: ed25519.PublicKey(pub)}
+		if raw := record["box"]; raw != "" {
+			if key.Box, err = hex.DecodeString(raw); err != nil || len(key.Box) != boxKeySize {
+				return nil, fmt.Errorf("%s generation %s box key must be %d hex bytes", path, key.Generation, boxKeySize)
+			}
+		}
+		if raw := record["not_after"]; raw != "" {
+			if key.NotAfter, err = time.Parse(time.RFC3339, raw); err != nil {
+				return nil, fmt.Errorf("%s generation %s not_after: %w", path, key.Generation, err)
+			}
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// WriteTrustedKeys replaces host's trusted file with keys, newest first. An
+// empty list removes the file, revoking the host entirely.
+func WriteTrustedKeys(root, host string, keys []TrustedKey) error {
+	if err := fsq.ValidateHandle(host); err != nil {
+		return fmt.Errorf("trusted source host: %w", err)
+	}
+	path := TrustedPath(root, host)
+	if len(keys) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return fsq.SyncDir(filepath.Dir(path))
+	}
+	seen := map[string]bool{}
+	records := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if strings.TrimSpace(key.Generation) == "" || strings.ContainsAny(key.Generation, " \t\n") {
+			return fmt.Errorf("trusted host generation %q is invalid", key.Generation)
+		}
+		if seen[key.Generation] {
+			return fmt.Errorf("trusted host %s repeats generation %q", host, key.Generation)
+		}
+		seen[key.Generation] = true
+		if len(key.Public) != ed25519.PublicKeySize {
+			return fmt.Errorf("trusted host public key must be %d bytes", ed25519.PublicKeySize)
+		}
+		if len(key.Box) != 0 && len(key.Box) != boxKeySize {
+			return fmt.Errorf("trusted host box key must be %d bytes", boxKeySize)
+		}
+		records = append(records, key.record())
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("create trusted directory: %w", err)
+	}
+	return replacePrivateFile(path, []byte(strings.Join(records, "\n")))
+}
+
+// VerifyEnvelopeTrusted verifies env against the trusted generation it names.
+// A generation past its not_after no longer verifies anything.
+func VerifyEnvelopeTrusted(env Envelope, keys []TrustedKey, now time.Time) error {
+	for _, key := range keys {
+		if key.Generation != env.KeyGeneration {
+			continue
+		}
+		if !key.ValidAt(now) {
+			return fmt.Errorf("envelope key_generation %q expired at %s", env.KeyGeneration, key.NotAfter.UTC().Format(time.RFC3339))
+		}
+		return VerifyEnvelope(env, key.Public, key.Generation)
+	}
+	return fmt.Errorf("envelope key_generation %q is not a trusted generation", env.KeyGeneration)
+}
+
+// RotateIdentity makes a fresh generation the signing key. The previous
+// generation's public key stays in the identity file with not_after =
+// now+overlap, so identity public keeps exporting it while envelopes it
+// signed are still in flight; retired generations already past their
+// deadline are dropped.
+func RotateIdentity(root, generation string, overlap time.Duration, now time.Time) (HostKey, []TrustedKey, error) {
+	if overlap < 0 {
+		return HostKey{}, nil, fmt.Errorf("rotation overlap must be >= 0")
+	}
+	path := IdentityPath(root)
+	data, err := readPrivateKeyFile(path)
+	if err != nil {
+		return HostKey{}, nil, fmt.Errorf("bridge identity: %w", err)
+	}
+	identity, err := parseIdentity(path, data)
+	if err != nil {
+		return HostKey{}, nil, err
+	}
+	next, err := GenerateHostKey(generation)
+	if err != nil {
+		return HostKey{}, nil, err
+	}
+	if strings.ContainsAny(next.Generation, " \t\n") {
+		return HostKey{}, nil, fmt.Errorf("key generation %q must not contain whitespace", next.Generation)
+	}
+	current := identity.active
+	box, err := current.BoxKey()
+	if err != nil {
+		return HostKey{}, nil, err
+	}
+	kept := []retiredKey{{
+		TrustedKey: TrustedKey{
+			Generation: current.Generation,
+			Public:     current.Public(),
+			NotAfter:   now.Add(overlap).UTC().Truncate(time.Second),
+			Box:        box.PublicKey().Bytes(),
+		},
+		boxSecret: box.Bytes(),
+	}}
+	for _, old := range identity.retired {
+		if old.ValidAt(now) {
+			kept = append(kept, old)
+		}
+	}
+	retired := make([]TrustedKey, 0, len(kept))
+	for _, old := range kept {
+		if old.Generation == next.Generation {
+			return HostKey{}, nil, fmt.Errorf("key generation %q was already used", next.Generation)
+		}
+		retired = append(retired, old.TrustedKey)
+	}
+	if err := replacePrivateFile(path, identityBytes(next, kept)); err != nil {
+		return HostKey{}, nil, err
+	}
+	return next, retired, nil
+}
+
+// TrustAuditEntry is one line of bridge/trust-audit.jsonl.
+type TrustAuditEntry struct {
+	At         string `json:"at"`
+	Action     string `json:"action"`
+	Host       string `json:"host"`
+	Generation string `json:"generation"`
+	Public     string `json:"public,omitempty"`
+	Box        string `json:"box,omitempty"`
+	NotAfter   string `json:"not_after,omitempty"`
+}
+
+// AppendTrustAudit records one trust or rotation change. Entries are only
+// ever appended.
+func AppendTrustAudit(root string, entry TrustAuditEntry) error {
+	if entry.At == "" {
+		entry.At = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	path := TrustAuditPath(root)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("create bridge directory: %w", err)
+	}
+	if info, err := os.Lstat(path); err == nil {
+		if err := validatePrivateKeyInfo(path, info); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// replacePrivateFile atomically replaces path with a new 0600 file.
+func replacePrivateFile(path string, data []byte) error {
+	if info, err := os.Lstat(path); err == nil {
+		if err := validatePrivateKeyInfo(path, info); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(path), fmt.Sprintf(".%s.tmp-%d", filepath.Base(path), time.Now().UnixNano()))
+	if err := writePrivateFile(tmp, data); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return fsq.SyncDir(filepath.Dir(path))
+}
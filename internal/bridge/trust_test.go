@@ -0,0 +1,101 @@
+package bridge
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotateIdentityKeepsPreviousGenerationUntilNotAfter(t *testing.T) {
+	root := t.TempDir()
+	if err := WriteHostID(root, "grok"); err != nil {
+		t.Fatal(err)
+	}
+	first, err := GenerateHostKey("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteIdentity(root, first); err != nil {
+		t.Fatal(err)
+	}
+	inFlight := testEnvelope([]byte("signed before rotation"))
+	if err := SignEnvelope(&inFlight, first); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2026, 9, 1, 12, 0, 0, 0, time.UTC)
+	second, retired, err := RotateIdentity(root, "2", time.Hour, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(retired) != 1 || retired[0].Generation != "1" || !retired[0].Public.Equal(first.Public()) ||
+		!retired[0].NotAfter.Equal(now.Add(time.Hour)) {
+		t.Fatalf("retired = %+v, want generation 1 until now+1h", retired)
+	}
+	active, kept, err := LoadIdentityKeys(root)
+	if err != nil || active.Generation != "2" || !active.Public().Equal(second.Public()) || len(kept) != 1 {
+		t.Fatalf("LoadIdentityKeys = %+v %+v %v, want generation 2 active and 1 retired", active, kept, err)
+	}
+	if info, err := os.Stat(IdentityPath(root)); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("rotated identity mode = %v (%v), want 0600", info.Mode(), err)
+	}
+	if _, _, err := RotateIdentity(root, "1", time.Hour, now); err == nil || !strings.Contains(err.Error(), "already used") {
+		t.Fatalf("reusing a retired generation = %v, want refusal", err)
+	}
+
+	// The receiver trusts both generations; the old one only until not_after.
+	trusted := []TrustedKey{{Generation: "2", Public: second.Public()}, retired[0]}
+	if err := WriteTrustedKeys(root, "grok", trusted); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := LoadTrustedKeys(root, "grok")
+	if err != nil || len(keys) != 2 || keys[0].Generation != "2" || !keys[1].NotAfter.Equal(retired[0].NotAfter) {
+		t.Fatalf("LoadTrustedKeys = %+v %v", keys, err)
+	}
+	if err := VerifyEnvelopeTrusted(inFlight, keys, now.Add(59*time.Minute)); err != nil {
+		t.Fatalf("in-flight envelope inside the overlap: %v", err)
+	}
+	if err := VerifyEnvelopeTrusted(inFlight, keys, now.Add(time.Hour)); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Fatalf("in-flight envelope after not_after = %v, want expired", err)
+	}
+	fresh := testEnvelope([]byte("signed after rotation"))
+	fresh.KeyGeneration = "2"
+	if err := SignEnvelope(&fresh, second); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyEnvelopeTrusted(fresh, keys, now.Add(48*time.Hour)); err != nil {
+		t.Fatalf("new generation: %v", err)
+	}
+	if err := VerifyEnvelopeTrusted(fresh, keys[1:], now); err == nil || !strings.Contains(err.Error(), "not a trusted generation") {
+		t.Fatalf("untrusted generation = %v, want refusal", err)
+	}
+
+	// Once the deadline passes, the next rotation drops the retired key.
+	_, retired, err = RotateIdentity(root, "3", time.Hour, now.Add(2*time.Hour))
+	if err != nil || len(retired) != 1 || retired[0].Generation != "2" {
+		t.Fatalf("second rotation retired = %+v %v, want only generation 2", retired, err)
+	}
+}
+
+func TestLoadTrustedKeysRejectsDuplicateGeneration(t *testing.T) {
+	root := t.TempDir()
+	key, err := GenerateHostKey("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteTrustedKeys(root, "grok", []TrustedKey{{Generation: "1", Public: key.Public()}, {Generation: "1", Public: key.Public()}}); err == nil {
+		t.Fatal("WriteTrustedKeys accepted a repeated generation")
+	}
+	if err := os.MkdirAll(filepath.Dir(TrustedPath(root, "grok")), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	record := TrustedKey{Generation: "1", Public: key.Public()}.record()
+	if err := os.WriteFile(TrustedPath(root, "grok"), []byte(record+"\n"+record), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTrustedKeys(root, "grok"); err == nil {
+		t.Fatal("LoadTrustedKeys accepted a repeated generation")
+	}
+}
@@ -0,0 +1,21 @@
+package cli
+
+import (
+	"errors"
+
+	"github.com/avivsinai/agent-message-queue/internal/acl"
+	"github.com/avivsinai/agent-message-queue/internal/format"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+)
+
+// authorizeDelivery applies the recipient ACLs from configFS to header. A
+// refusal is returned to the sender with ExitDeliveryDenied; nothing is
+// delivered or dead-lettered.
+func authorizeDelivery(configFS *fsq.DeliveryRoot, header format.Header) error {
+	err := acl.Authorize(configFS, header)
+	var denied *acl.DeniedError
+	if errors.As(err, &denied) {
+		return DeliveryDeniedError(denied)
+	}
+	return err
+}
@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/avivsinai/agent-message-queue/internal/config"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+)
+
+func setACLConfigForTest(t *testing.T, root string, policies *config.ACLConfig) {
+	t.Helper()
+	path := filepath.Join(root, "meta", "config.json")
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ACL = policies
+	if err := config.WriteConfig(path, cfg, true); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSendRefusedByRecipientACLWithTypedExitCode(t *testing.T) {
+	root := initializedSendMailboxRoot(t, "claude", "codex", "ops")
+	setACLConfigForTest(t, root, &config.ACLConfig{
+		Agents: map[string]config.ACLPolicy{
+			"ops": {Allow: []config.ACLRule{
+				{From: []string{"claude"}, Projects: []string{config.ACLLocalProject}},
+				{Projects: []string{"infra"}},
+			}},
+		},
+	})
+	send := func(from string) error {
+		_, _, err := captureEnvOutput(t, func() error {
+			return runSend([]string{"--root", root, "--me", from, "--to", "ops", "--body", "deploy?"})
+		})
+		return err
+	}
+
+	err := send("codex")
+	if code := GetExitCode(err); code != ExitDeliveryDenied {
+		t.Fatalf("codex send exit = %d (%v), want %d", code, err, ExitDeliveryDenied)
+	}
+	if !strings.Contains(err.Error(), "ops (allow: codex matches no allow rule)") {
+		t.Fatalf("refusal = %v", err)
+	}
+	if entries, _ := os.ReadDir(fsq.AgentInboxNew(root, "ops")); len(entries) != 0 {
+		t.Fatalf("refused message reached inbox: %d entries", len(entries))
+	}
+	if entries, _ := os.ReadDir(fsq.AgentOutboxSent(root, "codex")); len(entries) != 0 {
+		t.Fatalf("refused message recorded in outbox: %d entries", len(entries))
+	}
+
+	if err := send("claude"); err != nil {
+		t.Fatalf("claude send: %v", err)
+	}
+	if entries, _ := os.ReadDir(fsq.AgentInboxNew(root, "ops")); len(entries) != 1 {
+		t.Fatalf("ops inbox has %d messages, want 1", len(entries))
+	}
+
+	result := runRouteExplainJSONForTest(t, "--from-root", root, "--me", "codex", "--to", "ops")
+	if result.ACL == nil || result.ACL.Scope != "agent" || len(result.ACL.Policy.Allow) != 2 {
+		t.Fatalf("route explain acl = %+v, want the ops policy", result.ACL)
+	}
+	result = runRouteExplainJSONForTest(t, "--from-root", root, "--me", "codex", "--to", "claude")
+	if result.ACL != nil {
+		t.Fatalf("route explain acl for unrestricted claude = %+v, want nil", result.ACL)
+	}
+}
+
+// TestBreakerReleaseRespectsRecipientACL proves held mail is not a way
+// around the ACL: releasing it after the recipient's policy tightened is
+// refused with the typed exit code and the message stays held.
+func TestBreakerReleaseRespectsRecipientACL(t *testing.T) {
+	root := initializedSendMailboxRoot(t, "claude", "codex")
+	setBreakerConfigForTest(t, root, &config.BreakerConfig{MaxAlternations: 2, MaxRepeats: -1})
+	for i, pair := range [][2]string{{"codex", "claude"}, {"claude", "codex"}, {"codex", "claude"}} {
+		_, _, err := captureEnvOutput(t, func() error {
+			return runSend([]string{"--root", root, "--me", pair[0], "--to", pair[1], "--body", "turn " + pair[0]})
+		})
+		if i < 2 && err != nil {
+			t.Fatalf("send %d: %v", i, err)
+		}
+	}
+	setACLConfigForTest(t, root, &config.ACLConfig{
+		Agents: map[string]config.ACLPolicy{"claude": {Deny: []config.ACLRule{{From: []string{"codex"}}}}},
+	})
+
+	_, _, err := captureEnvOutput(t, func() error {
+		return runBreakerReset([]string{"--root", root, "--thread", "p2p/claude__codex", "--release"})
+	})
+	if code := GetExitCode(err); code != ExitDeliveryDenied {
+		t.Fatalf("release exit = %d (%v), want %d", code, err, ExitDeliveryDenied)
+	}
+	if entries, _ := os.ReadDir(fsq.AgentInboxNew(root, "claude")); len(entries) != 1 {
+		t.Fatalf("claude inbox = %d entries, want only the first send", len(entries))
+	}
+	if _, _, err := captureEnvOutput(t, func() error {
+		return runBreakerReset([]string{"--root", root, "--thread", "p2p/claude__codex", "--discard"})
+	}); err != nil {
+		t.Fatalf("discard after refused release: %v", err)
+	}
+}
@@ -0,0 +1,542 @@
+package cli
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+)
+
+const (
+	backupManifestName    = "amq-backup-manifest.json"
+	backupManifestVersion = 1
+
+	backupManifestMaxBytes = 64 << 20
+)
+
+// backupManifest is the first archive entry. Every other entry must be listed
+// with its size and digest; restore rejects anything else.
+type backupManifest struct {
+	Version    int          `json:"version"`
+	CreatedUTC string       `json:"created_utc"`
+	SourceRoot string       `json:"source_root"`
+	Files      []backupFile `json:"files"`
+	Excluded   int          `json:"excluded"`
+}
+
+type backupFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	Mode   uint32 `json:"mode"`
+	SHA256 string `json:"sha256"`
+}
+
+type backupResult struct {
+	Out            string `json:"out"`
+	Root           string `json:"root"`
+	Files          int    `json:"files"`
+	Bytes          int64  `json:"bytes"`
+	Excluded       int    `json:"excluded"`
+	ManifestSHA256 string `json:"manifest_sha256"`
+}
+
+type restoreResult struct {
+	Root   string   `json:"root"`
+	From   string   `json:"from"`
+	Files  int      `json:"files"`
+	Bytes  int64    `json:"bytes"`
+	Agents []string `json:"agents"`
+}
+
+// backupEphemeral reports whether a root-relative slash path is runtime state
+// that must not travel to another machine: wake locks and sidecars, advisory
+// lock files, launch bindings, atomic-write temporaries, and in-flight
+// deliveries in tmp leaves.
+func backupEphemeral(rel string) bool {
+	name := path.Base(rel)
+	dir := path.Dir(rel)
+	switch {
+	case strings.HasPrefix(name, ".wake."):
+		return true
+	case strings.HasSuffix(name, ".lock"):
+		return true
+	case strings.HasPrefix(name, ".") && strings.Contains(name, ".tmp-"):
+		return true
+	case path.Base(dir) == "tmp" && (path.Base(path.Dir(dir)) == "inbox" || path.Base(path.Dir(dir)) == "dlq"):
+		return true
+	}
+	return strings.HasPrefix(rel, "meta/launch/") || strings.Contains(rel, "/meta/launch/")
+}
+
+// collectBackupFiles walks root through pinned reads and returns the manifest
+// entries for every non-ephemeral regular file. Symlinks, sockets, and other
+// special files are never followed or archived.
+func collectBackupFiles(root *fsq.DeliveryRoot) ([]backupFile, int, error) {
+	var files []backupFile
+	excluded := 0
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		readDir := dir
+		if readDir == "" {
+			readDir = "."
+		}
+		entries, err := root.ReadDir(readDir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			rel := path.Join(dir, entry.Name())
+			switch {
+			case entry.IsDir():
+				if err := walk(rel); err != nil {
+					return err
+				}
+				continue
+			case !entry.Type().IsRegular(), backupEphemeral(rel):
+				excluded++
+				continue
+			}
+			data, err := root.ReadRegularNoFollow(filepath.FromSlash(rel))
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					continue
+				}
+				return err
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			sum := sha256.Sum256(data)
+			files = append(files, backupFile{
+				Path:   rel,
+				Size:   int64(len(data)),
+				Mode:   uint32(info.Mode().Perm()),
+				SHA256: hex.EncodeToString(sum[:]),
+			})
+		}
+		return nil
+	}
+	if err := walk(""); err != nil {
+		return nil, 0, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, excluded, nil
+}
+
+func runBackup(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	outFlag := fs.String("out", "", "Archive path to create (.tar.gz)")
+	sessionFlag := fs.String("session", "", "Back up this session under the resolved base root")
+	ignoreSessionPinFlag := fs.Bool("ignore-session-pin", false, "With explicit --root, ignore a conflicting AM_SESSION pin")
+
+	usage := usageWithFlags(fs, "amq backup --out <file.tar.gz> [--session <name>] [options]",
+		"",
+		"Snapshots the mailbox root into a gzip tarball with a manifest of per-file",
+		"sha256 digests. Wake locks, lock files, launch bindings, sockets, and",
+		"in-flight tmp files are excluded. Restore with amq restore.")
+	if handled, err := parseFlags(fs, args, usage); err != nil {
+		return err
+	} else if handled {
+		return nil
+	}
+	out := strings.TrimSpace(*outFlag)
+	if out == "" {
+		return UsageError("--out is required")
+	}
+	deliveryRoot, err := openMailboxCommandRoot("backup", common, *sessionFlag, *ignoreSessionPinFlag)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = deliveryRoot.Close() }()
+
+	outAbs, err := filepath.Abs(out)
+	if err != nil {
+		return err
+	}
+	rootAbs, err := filepath.Abs(deliveryRoot.Base())
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(rootAbs, outAbs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return UsageError("--out must be outside the root being backed up (%s)", rootAbs)
+	}
+	if _, err := os.Lstat(outAbs); err == nil {
+		return UsageError("--out %s already exists", out)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	files, excluded, err := collectBackupFiles(deliveryRoot)
+	if err != nil {
+		return fmt.Errorf("scan root: %w", err)
+	}
+	manifest := backupManifest{
+		Version:    backupManifestVersion,
+		CreatedUTC: time.Now().UTC().Format(time.RFC3339),
+		SourceRoot: rootAbs,
+		Files:      files,
+		Excluded:   excluded,
+	}
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	total, err := writeBackupArchive(deliveryRoot, outAbs, manifestData, files)
+	if err != nil {
+		return err
+	}
+	manifestSum := sha256.Sum256(manifestData)
+	result := backupResult{
+		Out:            outAbs,
+		Root:           rootAbs,
+		Files:          len(files),
+		Bytes:          total,
+		Excluded:       excluded,
+		ManifestSHA256: hex.EncodeToString(manifestSum[:]),
+	}
+	if common.JSON {
+		return writeJSON(os.Stdout, result)
+	}
+	return writeStdout("Backed up %d file(s) (%d bytes, %d excluded) from %s to %s\n", result.Files, result.Bytes, result.Excluded, result.Root, result.Out)
+}
+
+// writeBackupArchive re-reads every manifest file and fails if any changed
+// since the scan, so a completed archive is one consistent snapshot. The
+// archive is written to a temporary sibling and renamed into place.
+func writeBackupArchive(root *fsq.DeliveryRoot, out string, manifestData []byte, files []backupFile) (total int64, err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(out), "."+filepath.Base(out)+".tmp-*")
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+	gz := gzip.NewWriter(tmp)
+	tw := tar.NewWriter(gz)
+	modTime := time.Now().UTC()
+	if err := writeTarEntry(tw, backupManifestName, 0o600, modTime, manifestData); err != nil {
+		return 0, err
+	}
+	for _, file := range files {
+		data, err := root.ReadRegularNoFollow(filepath.FromSlash(file.Path))
+		if err != nil {
+			return 0, fmt.Errorf("read %s: %w", file.Path, err)
+		}
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != file.SHA256 {
+			return 0, fmt.Errorf("%s changed during backup; retry when the root is quiet", file.Path)
+		}
+		if err := writeTarEntry(tw, file.Path, os.FileMode(file.Mode), modTime, data); err != nil {
+			return 0, err
+		}
+		total += file.Size
+	}
+	if err := tw.Close(); err != nil {
+		return 0, err
+	}
+	if err := gz.Close(); err != nil {
+		return 0, err
+	}
+	if err := tmp.Sync(); err != nil {
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp.Name(), out); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+func writeTarEntry(tw *tar.Writer, name string, mode os.FileMode, modTime time.Time, data []byte) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     int64(mode.Perm()),
+		Size:     int64(len(data)),
+		ModTime:  modTime,
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+func runRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	fromFlag := fs.String("from", "", "Archive created by amq backup")
+	rootFlag := fs.String("root", "", "Empty or missing directory to restore into")
+	jsonFlag := fs.Bool("json", false, "Emit JSON output")
+
+	usage := usageWithFlags(fs, "amq restore --from <file.tar.gz> --root <empty dir> [--json]",
+		"",
+		"Verifies every archived file against the backup manifest before writing,",
+		"then restores into --root and recreates each agent's mailbox layout.",
+		"Refuses a --root that already contains anything, including a live root.")
+	if handled, err := parseFlags(fs, args, usage); err != nil {
+		return err
+	} else if handled {
+		return nil
+	}
+	from := strings.TrimSpace(*fromFlag)
+	target := strings.TrimSpace(*rootFlag)
+	if from == "" || target == "" {
+		return UsageError("--from and --root are required")
+	}
+	target, err := filepath.Abs(target)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := verifyBackupArchive(from)
+	if err != nil {
+		return err
+	}
+	if err := prepareRestoreTarget(target); err != nil {
+		return err
+	}
+	identity, err := fsq.SnapshotDeliveryRoot(target)
+	if err != nil {
+		return err
+	}
+	deliveryRoot, err := fsq.OpenDeliveryRoot(target, identity)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = deliveryRoot.Close() }()
+
+	total, err := extractBackupArchive(deliveryRoot, from, manifest)
+	if err != nil {
+		return fmt.Errorf("restore into %s: %w", target, err)
+	}
+	agents, err := recreateRestoredLayout(target, manifest)
+	if err != nil {
+		return err
+	}
+	result := restoreResult{Root: target, From: from, Files: len(manifest.Files), Bytes: total, Agents: agents}
+	if *jsonFlag {
+		return writeJSON(os.Stdout, result)
+	}
+	return writeStdout("Restored %d file(s) (%d bytes) for %d agent(s) into %s\n", result.Files, result.Bytes, len(agents), target)
+}
+
+// prepareRestoreTarget creates target if missing and refuses any existing
+// entry, so restore can never merge into or clobber a live root.
+func prepareRestoreTarget(target string) error {
+	info, err := os.Lstat(target)
+	if errors.Is(err, os.ErrNotExist) {
+		return os.MkdirAll(target, 0o700)
+	}
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return UsageError("--root %s is not a directory", target)
+	}
+	entries, err := os.ReadDir(target)
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		return UsageError("--root %s is not empty; refusing to restore over an existing root", target)
+	}
+	return nil
+}
+
+// openBackupArchive returns a tar reader positioned after a valid manifest.
+func openBackupArchive(from string) (*tar.Reader, backupManifest, func(), error) {
+	file, err := os.Open(from)
+	if err != nil {
+		return nil, backupManifest{}, nil, err
+	}
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, backupManifest{}, nil, fmt.Errorf("%s is not a gzip archive: %w", from, err)
+	}
+	closeFn := func() {
+		_ = gz.Close()
+		_ = file.Close()
+	}
+	tr := tar.NewReader(gz)
+	header, err := tr.Next()
+	if err != nil || header.Name != backupManifestName {
+		closeFn()
+		return nil, backupManifest{}, nil, fmt.Errorf("%s has no AMQ backup manifest", from)
+	}
+	data, err := io.ReadAll(io.LimitReader(tr, backupManifestMaxBytes))
+	if err != nil {
+		closeFn()
+		return nil, backupManifest{}, nil, err
+	}
+	var manifest backupManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		closeFn()
+		return nil, backupManifest{}, nil, fmt.Errorf("parse backup manifest: %w", err)
+	}
+	if manifest.Version != backupManifestVersion {
+		closeFn()
+		return nil, backupManifest{}, nil, fmt.Errorf("unsupported backup manifest version %d", manifest.Version)
+	}
+	return tr, manifest, closeFn, nil
+}
+
+// verifyBackupArchive checks every entry against the manifest without
+// writing anything.
+func verifyBackupArchive(from string) (backupManifest, error) {
+	_, manifest, err := walkBackupArchive(from, nil)
+	return manifest, err
+}
+
+func extractBackupArchive(root *fsq.DeliveryRoot, from string, expected backupManifest) (int64, error) {
+	total, manifest, err := walkBackupArchive(from, func(file backupFile, data []byte) error {
+		rel := filepath.FromSlash(file.Path)
+		_, err := root.WriteFileExclusive(filepath.Dir(rel), filepath.Base(rel), data, os.FileMode(file.Mode).Perm()|0o600)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(manifest.Files) != len(expected.Files) {
+		return 0, fmt.Errorf("archive changed between verification and restore")
+	}
+	return total, nil
+}
+
+// walkBackupArchive streams the archive, verifying each entry's path, size,
+// and digest against the manifest and that every listed file is present.
+func walkBackupArchive(from string, apply func(backupFile, []byte) error) (int64, backupManifest, error) {
+	tr, manifest, closeFn, err := openBackupArchive(from)
+	if err != nil {
+		return 0, backupManifest{}, err
+	}
+	defer closeFn()
+	expected := make(map[string]backupFile, len(manifest.Files))
+	for _, file := range manifest.Files {
+		if err := validateBackupPath(file.Path); err != nil {
+			return 0, manifest, err
+		}
+		expected[file.Path] = file
+	}
+	var total int64
+	seen := make(map[string]bool, len(manifest.Files))
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, manifest, err
+		}
+		file, ok := expected[header.Name]
+		if !ok || header.Typeflag != tar.TypeReg || seen[header.Name] {
+			return 0, manifest, fmt.Errorf("archive entry %q is not in the manifest", header.Name)
+		}
+		data, err := io.ReadAll(io.LimitReader(tr, file.Size+1))
+		if err != nil {
+			return 0, manifest, err
+		}
+		sum := sha256.Sum256(data)
+		if int64(len(data)) != file.Size || hex.EncodeToString(sum[:]) != file.SHA256 {
+			return 0, manifest, fmt.Errorf("archive entry %s does not match its manifest digest", file.Path)
+		}
+		if apply != nil {
+			if err := apply(file, data); err != nil {
+				return 0, manifest, fmt.Errorf("write %s: %w", file.Path, err)
+			}
+		}
+		seen[header.Name] = true
+		total += file.Size
+	}
+	if len(seen) != len(expected) {
+		return 0, manifest, fmt.Errorf("archive is missing %d file(s) listed in its manifest", len(expected)-len(seen))
+	}
+	return total, manifest, nil
+}
+
+func validateBackupPath(p string) error {
+	if p == "" || path.IsAbs(p) || path.Clean(p) != p || p == ".." || strings.HasPrefix(p, "../") || strings.Contains(p, "\\") {
+		return fmt.Errorf("manifest path %q is not a clean relative path", p)
+	}
+	if p == backupManifestName {
+		return fmt.Errorf("manifest lists its own name")
+	}
+	return nil
+}
+
+// recreateRestoredLayout rebuilds the full mailbox layout for every agent the
+// archive mentions, including empty leaves that a tarball cannot carry.
+// Session subtrees (<session>/agents/<handle>) are handled the same way.
+func recreateRestoredLayout(target string, manifest backupManifest) ([]string, error) {
+	roots := map[string]map[string]bool{}
+	for _, file := range manifest.Files {
+		parts := strings.Split(file.Path, "/")
+		for i := 0; i+2 < len(parts); i++ {
+			if parts[i] != "agents" || fsq.ValidateHandle(parts[i+1]) != nil {
+				continue
+			}
+			prefix := path.Join(parts[:i]...)
+			if roots[prefix] == nil {
+				roots[prefix] = map[string]bool{}
+			}
+			roots[prefix][parts[i+1]] = true
+			break
+		}
+	}
+	var restored []string
+	prefixes := make([]string, 0, len(roots))
+	for prefix := range roots {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		base := filepath.Join(target, filepath.FromSlash(prefix))
+		identity, err := fsq.SnapshotDeliveryRoot(base)
+		if err != nil {
+			return nil, err
+		}
+		deliveryRoot, err := fsq.OpenDeliveryRoot(base, identity)
+		if err != nil {
+			return nil, err
+		}
+		err = deliveryRoot.EnsureRootDirs()
+		agents := make([]string, 0, len(roots[prefix]))
+		for agent := range roots[prefix] {
+			agents = append(agents, agent)
+		}
+		sort.Strings(agents)
+		for _, agent := range agents {
+			if err == nil {
+				err = deliveryRoot.EnsureAgentDirs(agent)
+			}
+			restored = append(restored, path.Join(prefix, agent))
+		}
+		_ = deliveryRoot.Close()
+		if err != nil {
+			return nil, fmt.Errorf("recreate layout under %s: %w", base, err)
+		}
+	}
+	if restored == nil {
+		restored = []string{}
+	}
+	return restored, nil
+}
@@ -0,0 +1,204 @@
+package cli
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+)
+
+func TestBackupRestoreRoundTripSkipsEphemeralState(t *testing.T) {
+	root := initializedSendMailboxRoot(t, "alice", "bob")
+	if _, _, err := captureEnvOutput(t, func() error {
+		return runSend([]string{"--root", root, "--me", "alice", "--to", "bob", "--body", "hello"})
+	}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	for _, name := range []string{
+		filepath.Join("agents", "bob", ".wake.lock"),
+		filepath.Join("agents", "bob", "inbox", "tmp", "inflight.md"),
+		filepath.Join("meta", "launch", "binding.json"),
+	} {
+		if err := os.MkdirAll(filepath.Join(root, filepath.Dir(name)), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, name), []byte("ephemeral"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := filepath.Join(t.TempDir(), "root.tar.gz")
+	var backup backupResult
+	stdout, _, err := captureEnvOutput(t, func() error {
+		return runBackup([]string{"--root", root, "--out", out, "--json"})
+	})
+	if err != nil {
+		t.Fatalf("backup: %v", err)
+	}
+	if err := unmarshalJSONOutput(stdout, &backup); err != nil {
+		t.Fatal(err)
+	}
+	if backup.Files == 0 || backup.Excluded < 3 {
+		t.Fatalf("backup = %+v, want files and >= 3 exclusions", backup)
+	}
+	for _, name := range backupArchiveNames(t, out) {
+		if strings.Contains(name, ".wake.") || strings.Contains(name, "/tmp/") || strings.HasPrefix(name, "meta/launch/") {
+			t.Fatalf("archive contains ephemeral entry %s", name)
+		}
+	}
+
+	target := filepath.Join(t.TempDir(), "restored")
+	var restored restoreResult
+	stdout, _, err = captureEnvOutput(t, func() error {
+		return runRestore([]string{"--from", out, "--root", target, "--json"})
+	})
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if err := unmarshalJSONOutput(stdout, &restored); err != nil {
+		t.Fatal(err)
+	}
+	if restored.Files != backup.Files || strings.Join(restored.Agents, ",") != "alice,bob" {
+		t.Fatalf("restore = %+v, want %d files for alice,bob", restored, backup.Files)
+	}
+	srcEntries, err := os.ReadDir(fsq.AgentInboxNew(root, "bob"))
+	if err != nil || len(srcEntries) != 1 {
+		t.Fatalf("source inbox = %v, %v", srcEntries, err)
+	}
+	want, _ := os.ReadFile(filepath.Join(fsq.AgentInboxNew(root, "bob"), srcEntries[0].Name()))
+	got, err := os.ReadFile(filepath.Join(fsq.AgentInboxNew(target, "bob"), srcEntries[0].Name()))
+	if err != nil || string(got) != string(want) {
+		t.Fatalf("restored message = %q, %v; want %q", got, err, want)
+	}
+	// Empty leaves are recreated even though tar carried no entry for them.
+	if info, err := os.Stat(fsq.AgentInboxTmp(target, "bob")); err != nil || !info.IsDir() {
+		t.Fatalf("restored inbox/tmp = %v, %v", info, err)
+	}
+	if _, err := os.Stat(filepath.Join(target, "agents", "bob", ".wake.lock")); !os.IsNotExist(err) {
+		t.Fatalf("wake lock restored: %v", err)
+	}
+
+	// A live (non-empty) root is refused.
+	_, _, err = captureEnvOutput(t, func() error {
+		return runRestore([]string{"--from", out, "--root", root})
+	})
+	if err == nil || GetExitCode(err) != ExitUsage || !strings.Contains(err.Error(), "not empty") {
+		t.Fatalf("restore over live root = %v, want usage refusal", err)
+	}
+	// An existing --out is never overwritten.
+	_, _, err = captureEnvOutput(t, func() error {
+		return runBackup([]string{"--root", root, "--out", out})
+	})
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("backup over existing archive = %v", err)
+	}
+}
+
+func TestRestoreRejectsTamperedArchive(t *testing.T) {
+	root := initializedSendMailboxRoot(t, "alice", "bob")
+	if _, _, err := captureEnvOutput(t, func() error {
+		return runSend([]string{"--root", root, "--me", "alice", "--to", "bob", "--body", "hello"})
+	}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	out := filepath.Join(t.TempDir(), "root.tar.gz")
+	if _, _, err := captureEnvOutput(t, func() error {
+		return runBackup([]string{"--root", root, "--out", out})
+	}); err != nil {
+		t.Fatalf("backup: %v", err)
+	}
+
+	tampered := filepath.Join(t.TempDir(), "tampered.tar.gz")
+	rewriteBackupArchive(t, out, tampered, func(name string, data []byte) []byte {
+		if strings.HasPrefix(name, "agents/bob/inbox/new/") {
+			return []byte(strings.Replace(string(data), "hello", "HELLO", 1))
+		}
+		return data
+	})
+	target := filepath.Join(t.TempDir(), "restored")
+	_, _, err := captureEnvOutput(t, func() error {
+		return runRestore([]string{"--from", tampered, "--root", target})
+	})
+	if err == nil || !strings.Contains(err.Error(), "does not match its manifest digest") {
+		t.Fatalf("restore tampered = %v, want digest mismatch", err)
+	}
+	if _, statErr := os.Stat(target); !os.IsNotExist(statErr) {
+		t.Fatalf("tampered restore created target: %v", statErr)
+	}
+}
+
+func backupArchiveNames(t *testing.T, archive string) []string {
+	t.Helper()
+	var names []string
+	rewriteBackupArchive(t, archive, "", func(name string, data []byte) []byte {
+		names = append(names, name)
+		return data
+	})
+	if len(names) == 0 || names[0] != backupManifestName {
+		t.Fatalf("archive entries = %v, want manifest first", names)
+	}
+	return names
+}
+
+func rewriteBackupArchive(t *testing.T, src, dst string, edit func(string, []byte) []byte) {
+	t.Helper()
+	in, err := os.Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = in.Close() }()
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	var tw *tar.Writer
+	var gzOut *gzip.Writer
+	var outFile *os.File
+	if dst != "" {
+		if outFile, err = os.Create(dst); err != nil {
+			t.Fatal(err)
+		}
+		gzOut = gzip.NewWriter(outFile)
+		tw = tar.NewWriter(gzOut)
+	}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = edit(header.Name, data)
+		if tw != nil {
+			header.Size = int64(len(data))
+			if err := tw.WriteHeader(header); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tw.Write(data); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if tw != nil {
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := gzOut.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := outFile.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/avivsinai/agent-message-queue/internal/breaker"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+)
+
+func runBreaker(args []string) error {
+	if len(args) == 0 || isHelp(args[0]) {
+		return printGroupUsage(findCommand("breaker"))
+	}
+	switch args[0] {
+	case "list":
+		return runBreakerList(args[1:])
+	case "reset":
+		return runBreakerReset(args[1:])
+	default:
+		return formatUnknownSubcommand("breaker", args[0])
+	}
+}
+
+// openMailboxCommandRoot resolves, guards, and opens the mailbox root for a
+// root-wide command that does not act as a single agent.
+func openMailboxCommandRoot(command string, common *commonFlags, session string, ignoreSessionPin bool) (*fsq.DeliveryRoot, error) {
+	root, routed, err := resolveMailboxRoot(common, session)
+	if err != nil {
+		return nil, err
+	}
+	if err := validatePinOverride(common, ignoreSessionPin, routed); err != nil {
+		return nil, err
+	}
+	if err := guardMailboxContext(command, root, routed, ignoreSessionPin, common.rootExplicit()); err != nil {
+		return nil, err
+	}
+	identity, err := snapshotMailboxDeliveryRoot(root, routed, ignoreSessionPin)
+	if err != nil {
+		return nil, err
+	}
+	return fsq.OpenDeliveryRoot(root, identity)
+}
+
+// openMailboxCommandConfig selects the config authority send would use for
+// deliveryFS, so mail a command delivers obeys the same recipient ACLs.
+func openMailboxCommandConfig(deliveryFS *fsq.DeliveryRoot, ignoreSessionPin bool) (mailboxConfigSelection, error) {
+	pin, err := loadSessionPin()
+	if err != nil {
+		return mailboxConfigSelection{}, err
+	}
+	deliveryRoot := deliveryFS.DisplayPath("")
+	configBase, expectedBaseRootID := localMailboxConfigAuthority(deliveryRoot, pin, ignoreSessionPin)
+	return openMailboxConfigSelection(deliveryFS, deliveryRoot, configBase, expectedBaseRootID)
+}
+
+func runBreakerList(args []string) error {
+	fs := flag.NewFlagSet("breaker list", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	openFlag := fs.Bool("open", false, "List only tripped breakers")
+	sessionFlag := fs.String("session", "", "Target session under the resolved base root")
+	ignoreSessionPinFlag := fs.Bool("ignore-session-pin", false, "With explicit --root, ignore a conflicting AM_SESSION pin")
+
+	usage := usageWithFlags(fs, "amq breaker list [--open] [--session <name>] [options]")
+	if handled, err := parseFlags(fs, args, usage); err != nil {
+		return err
+	} else if handled {
+		return nil
+	}
+	deliveryRoot, err := openMailboxCommandRoot("breaker list", common, *sessionFlag, *ignoreSessionPinFlag)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = deliveryRoot.Close() }()
+
+	infos, err := breaker.List(deliveryRoot)
+	if err != nil {
+		return err
+	}
+	if *openFlag {
+		open := infos[:0]
+		for _, info := range infos {
+			if info.Status == breaker.StatusOpen {
+				open = append(open, info)
+			}
+		}
+		infos = open
+	}
+	if common.JSON {
+		if infos == nil {
+			infos = []breaker.Info{}
+		}
+		return writeJSON(os.Stdout, infos)
+	}
+	if len(infos) == 0 {
+		return writeStdoutLine("No thread breakers.")
+	}
+	for _, info := range infos {
+		line := fmt.Sprintf("[%s] %s  depth: %d  initiator: %s", info.Status, info.Thread, info.Depth, info.Initiator)
+		if info.Status == breaker.StatusOpen {
+			line += fmt.Sprintf("  reason: %s  held: %d", info.Reason, len(info.Held))
+		}
+		if err := writeStdoutLine(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runBreakerReset(args []string) error {
+	fs := flag.NewFlagSet("breaker reset", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	threadFlag := fs.String("thread", "", "Thread id whose breaker to reset")
+	releaseFlag := fs.Bool("release", false, "Deliver held messages to their recipients")
+	discardFlag := fs.Bool("discard", false, "Delete held messages")
+	sessionFlag := fs.String("session", "", "Target session under the resolved base root")
+	ignoreSessionPinFlag := fs.Bool("ignore-session-pin", false, "With explicit --root, ignore a conflicting AM_SESSION pin")
+
+	usage := usageWithFlags(fs, "amq breaker reset --thread <id> [--release | --discard] [--session <name>] [options]",
+		"",
+		"Closes the thread's loop breaker and clears its history. Held messages",
+		"must be either released (delivered) or discarded.")
+	if handled, err := parseFlags(fs, args, usage); err != nil {
+		return err
+	} else if handled {
+		return nil
+	}
+	thread := strings.TrimSpace(*threadFlag)
+	if thread == "" {
+		return UsageError("--thread is required")
+	}
+	if *releaseFlag && *discardFlag {
+		return UsageError("use only one of --release or --discard")
+	}
+	deliveryRoot, err := openMailboxCommandRoot("breaker reset", common, *sessionFlag, *ignoreSessionPinFlag)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = deliveryRoot.Close() }()
+	configSelection, err := openMailboxCommandConfig(deliveryRoot, *ignoreSessionPinFlag)
+	if err != nil {
+		return err
+	}
+	defer configSelection.Close()
+
+	result, err := breaker.Reset(configSelection.ConfigFS, deliveryRoot, thread, *releaseFlag, *discardFlag)
+	if errors.Is(err, breaker.ErrNotFound) {
+		return NotFoundError("no breaker state for thread %s", thread)
+	}
+	if err != nil {
+		return err
+	}
+	if common.JSON {
+		return writeJSON(os.Stdout, result)
+	}
+	return writeStdout("Reset breaker for %s (released: %d, discarded: %d)\n", thread, len(result.Released), len(result.Discarded))
+}
+
+func breakerHeldError(id string, state breaker.State) error {
+	return ActionRequiredError(
+		"message %s held: loop breaker is open on thread %s (%s); run amq breaker reset --thread %s --release or --discard",
+		id, state.Thread, state.Reason, state.Thread,
+	)
+}
+
+func reportHeldSend(jsonOut bool, id string, recipients []string, decision breaker.Decision) error {
+	heldErr := breakerHeldError(id, decision.State)
+	if !jsonOut {
+		return heldErr
+	}
+	out := map[string]any{
+		"id":      id,
+		"thread":  decision.State.Thread,
+		"to":      recipients,
+		"held":    true,
+		"tripped": decision.Tripped,
+		"breaker": decision.State,
+	}
+	if err := writeJSON(os.Stdout, out); err != nil {
+		return err
+	}
+	return heldErr
+}
@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/avivsinai/agent-message-queue/internal/breaker"
+	"github.com/avivsinai/agent-message-queue/internal/config"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+)
+
+func setBreakerConfigForTest(t *testing.T, root string, cfg *config.BreakerConfig) {
+	t.Helper()
+	path := filepath.Join(root, "meta", "config.json")
+	current, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current.Breaker = cfg
+	if err := config.WriteConfig(path, current, true); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSendLoopTripsBreakerHoldsAndResets(t *testing.T) {
+	root := initializedSendMailboxRoot(t, "claude", "codex")
+	if err := fsq.EnsureAgentDirs(root, reservedHumanHandle); err != nil {
+		t.Fatal(err)
+	}
+	setBreakerConfigForTest(t, root, &config.BreakerConfig{MaxAlternations: 2, MaxRepeats: -1})
+
+	send := func(me, to, body string) error {
+		_, _, err := captureEnvOutput(t, func() error {
+			return runSend([]string{"--root", root, "--me", me, "--to", to, "--body", body})
+		})
+		return err
+	}
+	if err := send("codex", "claude", "ping 1"); err != nil {
+		t.Fatalf("send 1: %v", err)
+	}
+	if err := send("claude", "codex", "pong 1"); err != nil {
+		t.Fatalf("send 2: %v", err)
+	}
+	err := send("codex", "claude", "ping 2")
+	if code := GetExitCode(err); code != ExitActionRequired || !strings.Contains(err.Error(), "breaker") {
+		t.Fatalf("tripping send = %d (%v), want action required", code, err)
+	}
+
+	// The notice goes to the human by default, not to the looping agents.
+	entries, err := os.ReadDir(fsq.AgentInboxNew(root, "codex"))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("initiator inbox entries = %d, %v; want only the pong", len(entries), err)
+	}
+	entries, err = os.ReadDir(fsq.AgentInboxNew(root, reservedHumanHandle))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("human inbox entries = %d, %v; want the notice", len(entries), err)
+	}
+
+	stdout, _, err := captureEnvOutput(t, func() error {
+		return runBreakerList([]string{"--root", root, "--open", "--json"})
+	})
+	if err != nil {
+		t.Fatalf("breaker list: %v", err)
+	}
+	var infos []breaker.Info
+	if err := unmarshalJSONOutput(stdout, &infos); err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].Thread != "p2p/claude__codex" || len(infos[0].Held) != 1 {
+		t.Fatalf("breaker list = %+v", infos)
+	}
+
+	if _, _, err := captureEnvOutput(t, func() error {
+		return runBreakerReset([]string{"--root", root, "--thread", "p2p/claude__codex"})
+	}); err == nil {
+		t.Fatal("reset without --release or --discard should refuse while messages are held")
+	}
+	if _, _, err := captureEnvOutput(t, func() error {
+		return runBreakerReset([]string{"--root", root, "--thread", "p2p/claude__codex", "--release"})
+	}); err != nil {
+		t.Fatalf("breaker reset: %v", err)
+	}
+	claudeInbox, err := os.ReadDir(fsq.AgentInboxNew(root, "claude"))
+	if err != nil || len(claudeInbox) != 2 {
+		t.Fatalf("claude inbox = %d, %v; want 2 after release", len(claudeInbox), err)
+	}
+	if err := send("codex", "claude", "ping 3"); err != nil {
+		t.Fatalf("send after reset: %v", err)
+	}
+}
+
+func TestSendLoopBreakerIsOnByDefault(t *testing.T) {
+	root := initializedSendMailboxRoot(t, "claude", "codex")
+	if err := fsq.EnsureAgentDirs(root, reservedHumanHandle); err != nil {
+		t.Fatal(err)
+	}
+	send := func(me, to string) error {
+		_, _, err := captureEnvOutput(t, func() error {
+			return runSend([]string{"--root", root, "--me", me, "--to", to, "--body", "ack"})
+		})
+		return err
+	}
+	if err := send("codex", "claude"); err != nil {
+		t.Fatalf("send 1: %v", err)
+	}
+	state := filepath.Join(root, "meta", "breakers", breaker.Key("p2p/claude__codex"), "state.json")
+	if _, err := os.Stat(state); err != nil {
+		t.Fatalf("default send did not record breaker state: %v", err)
+	}
+
+	// With no breaker config, the default repeat limit trips the thread.
+	pair := [2]string{"claude", "codex"}
+	for i := 2; i < config.DefaultBreakerMaxRepeats; i++ {
+		if err := send(pair[i%2], pair[(i+1)%2]); err != nil {
+			t.Fatalf("send %d: %v", i, err)
+		}
+	}
+	err := send(pair[config.DefaultBreakerMaxRepeats%2], pair[(config.DefaultBreakerMaxRepeats+1)%2])
+	if code := GetExitCode(err); code != ExitActionRequired {
+		t.Fatalf("send %d = %d (%v), want held by the default breaker", config.DefaultBreakerMaxRepeats, code, err)
+	}
+}
+
+func TestSendLoopBreakerDisabledRecordsNothing(t *testing.T) {
+	root := initializedSendMailboxRoot(t, "claude", "codex")
+	setBreakerConfigForTest(t, root, &config.BreakerConfig{Disabled: true})
+	if _, _, err := captureEnvOutput(t, func() error {
+		return runSend([]string{"--root", root, "--me", "codex", "--to", "claude", "--body", "ack"})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "meta", "breakers")); !os.IsNotExist(err) {
+		t.Fatalf("disabled breaker recorded state: %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/avivsinai/agent-message-queue/internal/delivery"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+)
+
+const (
+	capabilityPickLeastLoaded = delivery.PickLeastLoaded
+	capabilityPickRoundRobin  = delivery.PickRoundRobin
+
+	capabilityRoutingContextKey = delivery.RoutingContextKey
+	capabilityCursorsFile       = delivery.CursorsFile
+)
+
+// capabilityPick is a capability routing decision: which live agent was
+// chosen for a capability and why.
+type capabilityPick = delivery.CapabilityPick
+
+func parseCapabilityPick(raw string) (string, error) {
+	switch pick := strings.TrimSpace(raw); pick {
+	case "", capabilityPickLeastLoaded:
+		return capabilityPickLeastLoaded, nil
+	case capabilityPickRoundRobin:
+		return pick, nil
+	default:
+		return "", UsageError("--pick must be %s or %s", capabilityPickLeastLoaded, capabilityPickRoundRobin)
+	}
+}
+
+// pickCapabilityAgent chooses a live agent in deliveryFS advertising
+// capability, counting an agent with a live wake notifier as present. See
+// delivery.PickCapability.
+func pickCapabilityAgent(configFS, deliveryFS *fsq.DeliveryRoot, capability, pick, exclude string) (capabilityPick, error) {
+	if strings.TrimSpace(capability) == "" {
+		return capabilityPick{Pick: pick, Candidates: []delivery.Candidate{}}, UsageError("--to-capability must not be empty")
+	}
+	root := deliveryFS.Base()
+	decision, err := delivery.PickCapability(configFS, deliveryFS, capability, pick, exclude, func(agent string, recent bool) string {
+		return resolvePresenceSource(root, agent, recent)
+	})
+	var none *delivery.NoCandidateError
+	if errors.As(err, &none) {
+		return decision, NotFoundError("%s", none.Error())
+	}
+	return decision, err
+}
@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/config"
+	"github.com/avivsinai/agent-message-queue/internal/format"
+	"github.com/avivsinai/agent-message-queue/internal/presence"
+)
+
+func writeCapabilityPresenceForTest(t *testing.T, root, handle string, seen time.Time, capabilities ...string) {
+	t.Helper()
+	p := presence.New(handle, "active", "", seen)
+	p.Capabilities = capabilities
+	if err := presence.Write(root, p); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func sendCapabilityJSONForTest(t *testing.T, root string, args ...string) map[string]any {
+	t.Helper()
+	stdout, _, err := captureEnvOutput(t, func() error {
+		return runSend(append([]string{"--root", root, "--me", "claude", "--body", "who can take this?", "--json"}, args...))
+	})
+	if err != nil {
+		t.Fatalf("send %v: %v", args, err)
+	}
+	var out map[string]any
+	if err := unmarshalJSONOutput(stdout, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestSendToCapabilityPicksLiveAgentAndRecordsReason(t *testing.T) {
+	root := initializedSendMailboxRoot(t, "claude", "codex", "grok", "gemini")
+	now := time.Now()
+	writeCapabilityPresenceForTest(t, root, "claude", now, "go")
+	writeCapabilityPresenceForTest(t, root, "codex", now, "go", "frontend")
+	writeCapabilityPresenceForTest(t, root, "grok", now.Add(-time.Minute), "Go")
+	writeCapabilityPresenceForTest(t, root, "gemini", now.Add(-time.Hour), "go")
+	for i := 0; i < 2; i++ {
+		if _, _, err := captureEnvOutput(t, func() error {
+			return runSend([]string{"--root", root, "--me", "claude", "--to", "codex", "--body", "busy"})
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	explained := runRouteExplainJSONForTest(t, "--from-root", root, "--me", "claude", "--to-capability", "go")
+	if !explained.Routable || explained.Capability == nil || explained.Capability.Chosen != "grok" {
+		t.Fatalf("route explain = %+v, want grok", explained)
+	}
+	if len(explained.Capability.Candidates) != 3 {
+		t.Fatalf("candidates = %+v, want codex, gemini, grok (sender excluded)", explained.Capability.Candidates)
+	}
+
+	out := sendCapabilityJSONForTest(t, root, "--to-capability", "go")
+	if to, _ := out["to"].([]any); len(to) != 1 || to[0] != "grok" {
+		t.Fatalf("least-loaded to = %v, want grok", out["to"])
+	}
+	data, err := os.ReadFile(filepath.Join(root, "agents", "grok", "inbox", "new", out["id"].(string)+".md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := format.ParseMessage(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	routing, _ := msg.Header.Context[capabilityRoutingContextKey].(map[string]any)
+	if routing["chosen"] != "grok" || routing["pick"] != capabilityPickLeastLoaded || routing["reason"] == "" {
+		t.Fatalf("context routing = %v", msg.Header.Context)
+	}
+
+	var picked []string
+	for i := 0; i < 3; i++ {
+		out := sendCapabilityJSONForTest(t, root, "--to-capability", "go", "--pick", capabilityPickRoundRobin)
+		picked = append(picked, out["to"].([]any)[0].(string))
+	}
+	if picked[0] != "codex" || picked[1] != "grok" || picked[2] != "codex" {
+		t.Fatalf("round-robin picks = %v, want codex, grok, codex", picked)
+	}
+
+	if _, _, err := captureEnvOutput(t, func() error {
+		return runSend([]string{"--root", root, "--me", "claude", "--to-capability", "rust", "--body", "x"})
+	}); GetExitCode(err) != ExitNotFound {
+		t.Fatalf("unknown capability exit = %d (%v), want not found", GetExitCode(err), err)
+	}
+	if _, _, err := captureEnvOutput(t, func() error {
+		return runSend([]string{"--root", root, "--me", "claude", "--to", "codex", "--to-capability", "go", "--body", "x"})
+	}); GetExitCode(err) != ExitUsage {
+		t.Fatalf("--to with --to-capability exit = %d (%v), want usage", GetExitCode(err), err)
+	}
+}
+
+func TestRoundRobinCursorAdvancesOnlyAfterDelivery(t *testing.T) {
+	root := initializedSendMailboxRoot(t, "claude", "codex", "grok")
+	now := time.Now()
+	writeCapabilityPresenceForTest(t, root, "codex", now, "go")
+	writeCapabilityPresenceForTest(t, root, "grok", now, "go")
+	setQuotaConfigForTest(t, root, &config.QuotaConfig{
+		Agents: map[string]config.QuotaLimits{"codex": {MaxUndrained: 1}},
+	})
+	if _, _, err := captureEnvOutput(t, func() error {
+		return runSend([]string{"--root", root, "--me", "claude", "--to", "codex", "--body", "fill"})
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		_, _, err := captureEnvOutput(t, func() error {
+			return runSend([]string{"--root", root, "--me", "claude", "--to-capability", "go", "--pick", capabilityPickRoundRobin, "--body", "x"})
+		})
+		if GetExitCode(err) != ExitQuotaExceeded {
+			t.Fatalf("send %d exit = %d (%v), want quota exceeded on codex", i, GetExitCode(err), err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "meta", capabilityCursorsFile)); !os.IsNotExist(err) {
+		t.Fatalf("cursor file after refused sends: %v, want none", err)
+	}
+
+	if _, _, err := captureEnvOutput(t, func() error {
+		return runDrain([]string{"--root", root, "--me", "codex"})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	out := sendCapabilityJSONForTest(t, root, "--to-capability", "go", "--pick", capabilityPickRoundRobin)
+	if to := out["to"].([]any)[0]; to != "codex" {
+		t.Fatalf("first delivered pick = %v, want codex", to)
+	}
+	out = sendCapabilityJSONForTest(t, root, "--to-capability", "go", "--pick", capabilityPickRoundRobin)
+	if to := out["to"].([]any)[0]; to != "grok" {
+		t.Fatalf("second delivered pick = %v, want grok", to)
+	}
+}
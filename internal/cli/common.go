@@ -13,7 +13,11 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/avivsinai/agent-message-queue/internal/config"
+	"github.com/avivsinai/agent-message-queue/internal/format"
 	"github.com/avivsinai/agent-message-queue/internal/fsq"
+	"github.com/avivsinai/agent-message-queue/internal/mailbox"
+	"github.com/avivsinai/agent-message-queue/internal/sessionguard"
 )
 
 type commonFlags struct {
@@ -24,7 +28,7 @@ type commonFlags struct {
 	flagSet *flag.FlagSet
 }
 
-const reservedHumanHandle = "user"
+const reservedHumanHandle = config.ReservedHumanHandle
 
 var implicitRootFlagsBySet sync.Map
 
@@ -462,70 +466,20 @@ func loadKnownAgentsDeliveryRoot(root *fsq.DeliveryRoot, strict bool) ([]string,
 }
 
 func loadKnownAgentsWithRead(strict bool, read func() ([]byte, error)) ([]string, error) {
-	data, err := read()
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil // No config, no validation
-		}
-		// Config exists but unreadable
-		msg := fmt.Sprintf("cannot read config.json: %v", err)
-		if strict {
-			return nil, errors.New(msg)
-		}
-		_ = writeStderr("warning: %s\n", msg)
-		return nil, nil
-	}
-
-	var cfg struct {
-		Agents []string `json:"agents"`
-	}
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		// Config exists but invalid JSON
-		msg := fmt.Sprintf("invalid config.json: %v", err)
-		if strict {
-			return nil, errors.New(msg)
-		}
-		_ = writeStderr("warning: %s\n", msg)
-		return nil, nil
-	}
+	return mailbox.LoadKnownAgents(strict, warnKnownAgents, read)
+}
 
-	return withReservedHumanHandle(cfg.Agents), nil
+func warnKnownAgents(msg string) {
+	_ = writeStderr("warning: %s\n", msg)
 }
 
 func withReservedHumanHandle(agents []string) []string {
-	for _, agent := range agents {
-		if agent == reservedHumanHandle {
-			return agents
-		}
-	}
-	out := make([]string, 0, len(agents)+1)
-	out = append(out, agents...)
-	out = append(out, reservedHumanHandle)
-	return out
+	return mailbox.WithReservedHumanHandle(agents)
 }
 
 func loadKnownAgentSet(root string, strict bool) (map[string]struct{}, error) {
 	agents, err := loadKnownAgents(root, strict)
-	if err != nil || len(agents) == 0 {
-		return nil, err
-	}
-	known := make(map[string]struct{}, len(agents))
-	for _, agent := range agents {
-		known[agent] = struct{}{}
-	}
-	return known, nil
-}
-
-func loadKnownAgentSetDeliveryRoot(root *fsq.DeliveryRoot, strict bool) (map[string]struct{}, error) {
-	agents, err := loadKnownAgentsDeliveryRoot(root, strict)
-	if err != nil || len(agents) == 0 {
-		return nil, err
-	}
-	known := make(map[string]struct{}, len(agents))
-	for _, agent := range agents {
-		known[agent] = struct{}{}
-	}
-	return known, nil
+	return mailbox.KnownSet(agents), err
 }
 
 // validateKnownHandles validates handles against config.json.
@@ -543,40 +497,7 @@ func validateKnownHandlesDeliveryRoot(root *fsq.DeliveryRoot, strict bool, handl
 }
 
 func validateKnownHandlesFromAgents(agents []string, loadErr error, strict bool, handles ...string) error {
-	if loadErr != nil {
-		return loadErr
-	}
-	if agents == nil {
-		return nil // No config, no validation
-	}
-
-	known := make(map[string]bool, len(agents))
-	for _, a := range agents {
-		known[a] = true
-	}
-
-	var unknown []string
-	for _, h := range handles {
-		if !known[h] {
-			unknown = append(unknown, h)
-		}
-	}
-
-	if len(unknown) == 0 {
-		return nil
-	}
-
-	var msg string
-	if len(unknown) == 1 {
-		msg = fmt.Sprintf("handle %q not in config.json agents %v", unknown[0], agents)
-	} else {
-		msg = fmt.Sprintf("unknown handles %v (known: %v)", unknown, agents)
-	}
-	if strict {
-		return errors.New(msg)
-	}
-	_ = writeStderr("warning: %s\n", msg)
-	return nil
+	return mailbox.ValidateKnown(agents, loadErr, strict, warnKnownAgents, handles...)
 }
 
 func normalizeHandle(raw string) (string, error) {
@@ -594,14 +515,8 @@ func normalizeHandle(raw string) (string, error) {
 // directory names. Allows lowercase letters, digits, hyphens, and underscores
 // (same charset as handles).
 func validateSessionName(name string) error {
-	if name == "" {
-		return UsageError("session name cannot be empty")
-	}
-	for _, r := range name {
-		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
-			continue
-		}
-		return UsageError("invalid session name %q (allowed: a-z, 0-9, -, _)", name)
+	if err := sessionguard.ValidateSessionName(name); err != nil {
+		return UsageError("%v", err)
 	}
 	return nil
 }
@@ -917,26 +832,7 @@ func ensureFilename(id string) (string, error) {
 }
 
 func ensureSafeBaseName(name string) (string, error) {
-	name = strings.TrimSpace(name)
-	if name == "" {
-		return "", errors.New("name cannot be empty")
-	}
-	if strings.HasPrefix(name, ".") {
-		return "", fmt.Errorf("invalid name: %s", name)
-	}
-	if name == "." || name == ".." {
-		return "", fmt.Errorf("invalid name: %s", name)
-	}
-	if filepath.Base(name) != name {
-		return "", fmt.Errorf("invalid name: %s", name)
-	}
-	if strings.ContainsAny(name, "/\\") {
-		return "", fmt.Errorf("invalid name: %s", name)
-	}
-	if strings.HasSuffix(name, ".md") {
-		return "", fmt.Errorf("invalid name: %s", name)
-	}
-	return name, nil
+	return format.SafeBaseName(name)
 }
 
 func writeJSON(w io.Writer, v any) error {
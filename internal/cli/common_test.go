@@ -209,12 +209,12 @@ func TestHeaderValidatorAllowsReservedUserUnderStrict(t *testing.T) {
 		Thread:  "p2p/claude__user",
 		Created: time.Now().UTC().Format(time.RFC3339Nano),
 	}
-	if err := validator.validate(header); err != nil {
+	if err := validator.Validate(header); err != nil {
 		t.Fatalf("strict validator should accept reserved user recipient: %v", err)
 	}
 
 	header.To = []string{"unknown"}
-	if err := validator.validate(header); err == nil {
+	if err := validator.Validate(header); err == nil {
 		t.Fatal("strict validator should still reject unknown recipients")
 	}
 }
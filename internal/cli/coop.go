@@ -221,6 +221,7 @@ func runCoopInitInternal(args []string, printNextSteps bool) (returnErr error) {
 		}
 		amqrcWritten = true
 	}
+	registerProjectOwning("")
 
 	// Update .gitignore (creates if needed, only for relative paths) unless opted out
 	gitignoreUpdated := false
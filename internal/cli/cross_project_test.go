@@ -10,6 +10,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/avivsinai/agent-message-queue/internal/breaker"
 	"github.com/avivsinai/agent-message-queue/internal/fsq"
 )
 
@@ -413,6 +414,12 @@ func TestCrossProjectSendFromOutsideTree(t *testing.T) {
 	if len(entries) != 1 {
 		t.Errorf("expected 1 message in peer inbox, got %d", len(entries))
 	}
+
+	// The loop breaker runs on the peer's delivery root too.
+	thread, _ := result["thread"].(string)
+	if _, err := os.Stat(filepath.Join(peerSessionRoot, "meta", "breakers", breaker.Key(thread), "state.json")); err != nil {
+		t.Errorf("cross-project send did not record breaker state: %v", err)
+	}
 }
 
 func TestCrossProjectSendRejectsMultipleRecipients(t *testing.T) {
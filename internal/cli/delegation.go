@@ -0,0 +1,12 @@
+package cli
+
+import "github.com/avivsinai/agent-message-queue/internal/delivery"
+
+func reportDelegationHops(hops []delivery.Hop) error {
+	for _, hop := range hops {
+		if err := writeStdout("%s is away; forwarded (%s) to %s\n", hop.For, hop.Mode, hop.To); err != nil {
+			return err
+		}
+	}
+	return nil
+}
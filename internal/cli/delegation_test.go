@@ -0,0 +1,252 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/avivsinai/agent-message-queue/internal/config"
+	"github.com/avivsinai/agent-message-queue/internal/delivery"
+	"github.com/avivsinai/agent-message-queue/internal/format"
+	"github.com/avivsinai/agent-message-queue/internal/presence"
+)
+
+func readInboxMessageForTest(t *testing.T, root, agent, id string) (format.Message, bool) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(root, "agents", agent, "inbox", "new", id+".md"))
+	if os.IsNotExist(err) {
+		return format.Message{}, false
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := format.ParseMessage(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg, true
+}
+
+func TestAwayDelegateForwardsRepliesAndClearsOnReturn(t *testing.T) {
+	root := initializedSendMailboxRoot(t, "claude", "codex", "grok")
+	setAway := func(args ...string) {
+		t.Helper()
+		if _, _, err := captureEnvOutput(t, func() error {
+			return runPresenceSet(append([]string{"--root", root, "--me", "codex", "--status", "away", "--delegate", "grok"}, args...))
+		}); err != nil {
+			t.Fatalf("presence set %v: %v", args, err)
+		}
+	}
+	send := func(body string) map[string]any {
+		t.Helper()
+		stdout, _, err := captureEnvOutput(t, func() error {
+			return runSend([]string{"--root", root, "--me", "claude", "--to", "codex", "--body", body, "--json"})
+		})
+		if err != nil {
+			t.Fatalf("send: %v", err)
+		}
+		var out map[string]any
+		if err := unmarshalJSONOutput(stdout, &out); err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	setAway("--until", "1h")
+	out := send("copy mode")
+	id := out["id"].(string)
+	if hops, _ := out["delegated"].([]any); len(hops) != 1 {
+		t.Fatalf("delegated = %v, want one hop", out["delegated"])
+	}
+	if _, ok := readInboxMessageForTest(t, root, "codex", id); !ok {
+		t.Fatal("copy mode must keep the original in codex's inbox")
+	}
+	forwarded, ok := readInboxMessageForTest(t, root, "grok", id)
+	if !ok {
+		t.Fatal("grok did not receive the delegated copy")
+	}
+	hop, ok := delivery.HopFromContext(forwarded.Header.Context)
+	if !ok || hop.For != "codex" || hop.To != "grok" || hop.Mode != presence.DelegateCopy || hop.Until == "" {
+		t.Fatalf("delegated copy context = %v", forwarded.Header.Context)
+	}
+	if forwarded.Header.From != "claude" || len(forwarded.Header.To) != 1 || forwarded.Header.To[0] != "codex" {
+		t.Fatalf("delegated copy header = %+v, want the original addressing", forwarded.Header)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(root, "agents", "claude", "inbox", "new"))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("claude inbox = %v (%v), want one away reply", entries, err)
+	}
+	awayReply, ok := readInboxMessageForTest(t, root, "claude", entries[0].Name()[:len(entries[0].Name())-len(".md")])
+	if !ok || awayReply.Header.From != "codex" || awayReply.Header.Kind != format.KindStatus ||
+		len(awayReply.Header.Refs) != 1 || awayReply.Header.Refs[0] != id {
+		t.Fatalf("away reply = %+v", awayReply.Header)
+	}
+
+	trace := collectTrace(root, id)
+	found := false
+	for _, evidence := range trace.Legs["route"].Evidence {
+		if evidence.Authority == "delegation" && evidence.Agent == "grok" && evidence.Delegation != nil && evidence.Delegation.For == "codex" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("trace route leg = %+v, want the delegation hop", trace.Legs["route"].Evidence)
+	}
+
+	setAway("--delegate-mode", presence.DelegateMove)
+	moved := send("move mode")["id"].(string)
+	if _, ok := readInboxMessageForTest(t, root, "codex", moved); ok {
+		t.Fatal("move mode must not leave the original in codex's inbox")
+	}
+	if _, ok := readInboxMessageForTest(t, root, "grok", moved); !ok {
+		t.Fatal("move mode did not reach grok")
+	}
+
+	// codex comes back: its own drain clears the delegation.
+	if _, _, err := captureEnvOutput(t, func() error {
+		return runDrain([]string{"--root", root, "--me", "codex"})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	p, err := presence.Read(root, "codex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Delegate != "" || p.Status != presence.StatusActive {
+		t.Fatalf("presence after return = %+v, want active without delegate", p)
+	}
+	back := send("welcome back")
+	if _, ok := back["delegated"]; ok {
+		t.Fatalf("send after return delegated: %v", back["delegated"])
+	}
+	if _, ok := readInboxMessageForTest(t, root, "grok", back["id"].(string)); ok {
+		t.Fatal("grok must not receive mail after codex returned")
+	}
+
+	if _, _, err := captureEnvOutput(t, func() error {
+		return runPresenceSet([]string{"--root", root, "--me", "codex", "--status", "away", "--until", "1h"})
+	}); GetExitCode(err) != ExitUsage {
+		t.Fatalf("--until without --delegate exit = %d (%v), want usage", GetExitCode(err), err)
+	}
+}
+
+func TestDelegationCopiesAndAwayRepliesRespectQuota(t *testing.T) {
+	root := initializedSendMailboxRoot(t, "claude", "codex", "grok")
+	setAway := func() {
+		t.Helper()
+		if _, _, err := captureEnvOutput(t, func() error {
+			return runPresenceSet([]string{"--root", root, "--me", "codex", "--status", "away", "--delegate", "grok"})
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setAway()
+	setQuotaConfigForTest(t, root, &config.QuotaConfig{
+		Agents: map[string]config.QuotaLimits{"claude": {MaxUndrained: 1}, "grok": {MaxUndrained: 1}},
+	})
+	send := func() error {
+		_, _, err := captureEnvOutput(t, func() error {
+			return runSend([]string{"--root", root, "--me", "claude", "--to", "codex", "--body", "hi"})
+		})
+		return err
+	}
+	if err := send(); err != nil {
+		t.Fatalf("first send: %v", err)
+	}
+	// grok's inbox is now full, so the next forward is refused like any
+	// over-quota delivery and nothing is written.
+	if err := send(); GetExitCode(err) != ExitQuotaExceeded {
+		t.Fatalf("second send exit = %d (%v), want quota exceeded", GetExitCode(err), err)
+	}
+	if entries, err := os.ReadDir(filepath.Join(root, "agents", "codex", "inbox", "new")); err != nil || len(entries) != 1 {
+		t.Fatalf("codex inbox = %v (%v), want only the first message", entries, err)
+	}
+
+	// With room for the forward again, claude's full inbox still refuses the
+	// away reply of a new away period; the send itself succeeds.
+	if _, _, err := captureEnvOutput(t, func() error {
+		return runDrain([]string{"--root", root, "--me", "grok"})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	setAway()
+	if err := send(); err != nil {
+		t.Fatalf("third send: %v", err)
+	}
+	if entries, err := os.ReadDir(filepath.Join(root, "agents", "claude", "inbox", "new")); err != nil || len(entries) != 1 {
+		t.Fatalf("claude inbox = %v (%v), want the first away reply only", entries, err)
+	}
+}
+
+func TestAwayReplyIsSentOncePerAwayPeriod(t *testing.T) {
+	root := initializedSendMailboxRoot(t, "claude", "codex", "grok")
+	if _, _, err := captureEnvOutput(t, func() error {
+		return runPresenceSet([]string{"--root", root, "--me", "codex", "--status", "away", "--delegate", "grok"})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, body := range []string{"one", "two", "three"} {
+		if _, _, err := captureEnvOutput(t, func() error {
+			return runSend([]string{"--root", root, "--me", "claude", "--to", "codex", "--body", body})
+		}); err != nil {
+			t.Fatalf("send %s: %v", body, err)
+		}
+	}
+	if got := inboxCount(t, root, "grok"); got != 3 {
+		t.Fatalf("grok inbox = %d, want every message forwarded", got)
+	}
+	if got := inboxCount(t, root, "claude"); got != 1 {
+		t.Fatalf("claude inbox = %d, want one away reply", got)
+	}
+}
+
+func TestCrossProjectSendDelegatesInThePeerRoot(t *testing.T) {
+	clearSendMailboxTestEnv(t)
+	sourceProjectDir := filepath.Join(t.TempDir(), "source-project")
+	peerProjectDir := filepath.Join(t.TempDir(), "peer-project")
+	sourceBase := filepath.Join(sourceProjectDir, ".agent-mail")
+	peerBase := filepath.Join(peerProjectDir, ".agent-mail")
+	sourceRoot := filepath.Join(sourceBase, "collab")
+	peerRoot := filepath.Join(peerBase, "collab")
+	ensureRouteAgents(t, sourceRoot, "alice")
+	ensureRouteAgents(t, peerRoot, "bob", "carol")
+	writeRouteAmqrc(t, sourceProjectDir, map[string]any{
+		"root":    ".agent-mail",
+		"project": "source",
+		"peers":   map[string]string{"peer": peerBase},
+	})
+	resetAmqrcCache()
+	t.Cleanup(resetAmqrcCache)
+	if _, _, err := captureEnvOutput(t, func() error {
+		return runPresenceSet([]string{"--root", peerRoot, "--me", "bob", "--status", "away", "--delegate", "carol", "--delegate-mode", presence.DelegateMove})
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, _, err := captureEnvOutput(t, func() error {
+		return runSend([]string{"--root", sourceRoot, "--me", "alice", "--to", "bob", "--project", "peer", "--body", "hi", "--json"})
+	})
+	if err != nil {
+		t.Fatalf("cross-project send: %v", err)
+	}
+	var out map[string]any
+	if err := unmarshalJSONOutput(stdout, &out); err != nil {
+		t.Fatal(err)
+	}
+	id := out["id"].(string)
+	if hops, _ := out["delegated"].([]any); len(hops) != 1 {
+		t.Fatalf("delegated = %v, want one hop", out["delegated"])
+	}
+	if _, ok := readInboxMessageForTest(t, peerRoot, "bob", id); ok {
+		t.Fatal("move mode must not leave the original in bob's inbox")
+	}
+	if _, ok := readInboxMessageForTest(t, peerRoot, "carol", id); !ok {
+		t.Fatal("carol did not receive the delegated message")
+	}
+	awayReply := soleDeliveredMessage(t, sourceRoot, "alice")
+	if awayReply.Header.From != "bob" || awayReply.Header.Kind != format.KindStatus ||
+		awayReply.Header.ReplyProject != "peer" || awayReply.Header.ReplyTo != "bob@collab" {
+		t.Fatalf("away reply header = %+v, want one that routes back to bob in peer", awayReply.Header)
+	}
+}
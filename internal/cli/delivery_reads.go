@@ -1,32 +1,26 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 
 	"github.com/avivsinai/agent-message-queue/internal/format"
 	"github.com/avivsinai/agent-message-queue/internal/fsq"
+	"github.com/avivsinai/agent-message-queue/internal/mailbox"
 )
 
 func requireMailboxDeliveryRoot(root *fsq.DeliveryRoot, displayRoot, me string) error {
-	for _, dir := range []string{
-		filepath.Join("agents", me, "inbox", "new"),
-		filepath.Join("agents", me, "inbox", "cur"),
-		filepath.Join("agents", me, "dlq", "new"),
-	} {
-		info, err := root.Stat(dir)
-		if err != nil {
-			if os.IsNotExist(err) {
-				return NotFoundError("mailbox for %q is missing at root %s (missing %s); check AM_ROOT or use --session <name>", me, displayRoot, root.DisplayPath(dir))
-			}
-			return err
-		}
-		if !info.IsDir() {
-			return NotFoundError("mailbox path for %q is not a directory: %s", me, root.DisplayPath(dir))
-		}
+	err := mailbox.Require(root, me)
+	var missing *mailbox.MissingError
+	if !errors.As(err, &missing) {
+		return err
+	}
+	if missing.NotDir {
+		return NotFoundError("mailbox path for %q is not a directory: %s", me, root.DisplayPath(missing.Path))
 	}
-	return nil
+	return NotFoundError("mailbox for %q is missing at root %s (missing %s); check AM_ROOT or use --session <name>", me, displayRoot, root.DisplayPath(missing.Path))
 }
 
 func deliveryAgentExists(root *fsq.DeliveryRoot, agent string) bool {
@@ -4,9 +4,31 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/avivsinai/agent-message-queue/internal/acl"
 	"github.com/avivsinai/agent-message-queue/internal/fsq"
+	"github.com/avivsinai/agent-message-queue/internal/quota"
 )
 
+// localDeliveryError maps a delivery.Local refusal to its exit code: an ACL
+// refusal is ExitDeliveryDenied and a quota refusal ExitQuotaExceeded.
+func localDeliveryError(messageID string, err error) error {
+	var denied *acl.DeniedError
+	if errors.As(err, &denied) {
+		return DeliveryDeniedError(err)
+	}
+	var exceeded *quota.ExceededError
+	if errors.As(err, &exceeded) {
+		return QuotaExceededError(err)
+	}
+	return reportDeliveryError(messageID, err)
+}
+
+func reportDeliveryWarnings(warnings []string) {
+	for _, warning := range warnings {
+		_ = writeStderr("warning: %s\n", warning)
+	}
+}
+
 func reportDeliveryError(messageID string, err error) error {
 	var committed *fsq.CommittedDurabilityError
 	if !errors.As(err, &committed) {
@@ -53,6 +53,8 @@ func runDLQ(args []string) error {
 		return runDLQRetry(args[1:])
 	case "purge":
 		return runDLQPurge(args[1:])
+	case "fix":
+		return runDLQFix(args[1:])
 	default:
 		return formatUnknownSubcommand("dlq", args[0])
 	}
@@ -0,0 +1,395 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/format"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+)
+
+var fixDLQMessage = fsq.FixFromDLQ
+
+// editDLQFixFile opens path in the operator's editor and waits for it to exit.
+// Tests replace it to script an edit without a terminal.
+var editDLQFixFile = func(path string) error {
+	editor := strings.Fields(os.Getenv("EDITOR"))
+	if len(editor) == 0 {
+		editor = []string{"vi"}
+	}
+	cmd := exec.Command(editor[0], append(editor[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("run editor %q: %w", editor[0], err)
+	}
+	return nil
+}
+
+// dlqFixReservedFields are minted for every revision and cannot be patched.
+var dlqFixReservedFields = []string{"schema", "id", "created"}
+
+// dlqFixResult reports a repaired DLQ envelope.
+type dlqFixResult struct {
+	Fixed        string             `json:"fixed"`
+	OriginalID   string             `json:"original_id"`
+	RevisionID   string             `json:"revision_id"`
+	RevisionFile string             `json:"revision_file"`
+	Changes      []fsq.DLQFixChange `json:"changes"`
+	DryRun       bool               `json:"dry_run,omitempty"`
+}
+
+func runDLQFix(args []string) error {
+	fs := flag.NewFlagSet("dlq fix", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	idFlag := fs.String("id", "", "DLQ message ID to repair")
+	setFlag := fs.String("set", "", "JSON object of header fields to patch, or @file.json (default: open $EDITOR)")
+	dryRunFlag := fs.Bool("dry-run", false, "Validate and show the revision without delivering it")
+	sessionFlag := fs.String("session", "", "Target session under the resolved base root")
+	ignoreSessionPinFlag := fs.Bool("ignore-session-pin", false, "With explicit --root, ignore a conflicting AM_SESSION pin")
+
+	usage := usageWithFlags(fs, "amq dlq fix --me <agent> --id <dlq_id> [--set <json>|@file] [--dry-run] [--session <name>] [options]",
+		"Repairs the embedded original and delivers it as a new message whose refs",
+		"link back to the original. The corrected header must pass strict validation.",
+		"",
+		"Examples:",
+		"  amq dlq fix --me codex --id dlq_123              # edit in $EDITOR",
+		"  amq dlq fix --me codex --id dlq_123 --set '{\"kind\":\"question\"}'")
+	if handled, err := parseFlags(fs, args, usage); err != nil {
+		return err
+	} else if handled {
+		return nil
+	}
+	if err := requireMe(common.Me); err != nil {
+		return err
+	}
+	if *idFlag == "" {
+		return UsageError("--id is required")
+	}
+	me, err := normalizeHandle(common.Me)
+	if err != nil {
+		return UsageError("--me: %v", err)
+	}
+	common.Me = me
+	root, routed, err := resolveMailboxRoot(common, *sessionFlag)
+	if err != nil {
+		return err
+	}
+	if err := validatePinOverride(common, *ignoreSessionPinFlag, routed); err != nil {
+		return err
+	}
+	if err := guardMailboxContext("dlq fix", root, routed, *ignoreSessionPinFlag, common.rootExplicit()); err != nil {
+		return err
+	}
+	deliveryIdentity, err := snapshotMailboxDeliveryRoot(root, routed, *ignoreSessionPinFlag)
+	if err != nil {
+		return err
+	}
+	if err := requireMailbox(root, me); err != nil {
+		return err
+	}
+	if err := validateKnownHandles(root, common.Strict, me); err != nil {
+		return err
+	}
+	deliveryRoot, err := fsq.OpenDeliveryRoot(root, deliveryIdentity)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = deliveryRoot.Close() }()
+
+	filename, err := ensureFilename(*idFlag)
+	if err != nil {
+		return UsageError("--id: %v", err)
+	}
+	dlqPath, _, err := fsq.FindDLQMessage(deliveryRoot, me, filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return NotFoundError("DLQ message not found: %s", *idFlag)
+		}
+		return err
+	}
+	env, originalContent, err := fsq.ReadDLQEnvelope(deliveryRoot, dlqPath)
+	if err != nil {
+		return fmt.Errorf("read DLQ message: %w", err)
+	}
+	if env.RetryState == fsq.RetryStateDelivered {
+		return fmt.Errorf("DLQ message %s was already delivered; nothing to fix", *idFlag)
+	}
+
+	var patch map[string]json.RawMessage
+	if strings.TrimSpace(*setFlag) != "" {
+		patch, err = parseDLQFixPatch(*setFlag)
+		if err != nil {
+			return err
+		}
+	}
+	revised, err := reviseDLQOriginal(originalContent, patch)
+	if err != nil {
+		return err
+	}
+
+	validator, err := newHeaderValidatorDeliveryRoot(deliveryRoot, true)
+	if err != nil {
+		return err
+	}
+	original, parseErr := format.ParseMessage(originalContent)
+	revision, err := buildDLQFixRevision(me, env, original, parseErr, revised, validator, time.Now())
+	if err != nil {
+		return err
+	}
+	changes := dlqFixChanges(original, parseErr, revision.Message, revision.LinkedID)
+	if len(changes) == 0 {
+		return UsageError("revision is identical to the original; nothing to fix")
+	}
+
+	result := dlqFixResult{
+		Fixed:        strings.TrimSuffix(filename, ".md"),
+		OriginalID:   env.OriginalID,
+		RevisionID:   revision.Message.Header.ID,
+		RevisionFile: revision.Filename,
+		Changes:      changes,
+		DryRun:       *dryRunFlag,
+	}
+	var fixErr error
+	if !*dryRunFlag {
+		fix := fsq.DLQFix{
+			RevisionID:   result.RevisionID,
+			RevisionFile: result.RevisionFile,
+			FixedAt:      time.Now().UTC().Format(time.RFC3339),
+			Changes:      changes,
+		}
+		fixErr = fixDLQMessage(deliveryRoot, me, filename, sha256.Sum256(originalContent), revision.Data, fix)
+		if fixErr != nil && !committedDLQRetry(deliveryRoot, me, fixErr) {
+			return fixErr
+		}
+	}
+	return errors.Join(fixErr, outputDLQFixResult(common.JSON, result))
+}
+
+// parseDLQFixPatch parses a --set header patch. Only known header fields are
+// accepted, and fields minted for every revision are refused.
+func parseDLQFixPatch(raw string) (map[string]json.RawMessage, error) {
+	raw = strings.TrimSpace(raw)
+	data := []byte(raw)
+	if strings.HasPrefix(raw, "@") {
+		var err error
+		data, err = os.ReadFile(strings.TrimPrefix(raw, "@"))
+		if err != nil {
+			return nil, UsageError("--set: read patch file: %v", err)
+		}
+	}
+	var patch map[string]json.RawMessage
+	if err := json.Unmarshal(data, &patch); err != nil {
+		return nil, UsageError("--set: parse patch JSON: %v", err)
+	}
+	if len(patch) == 0 {
+		return nil, UsageError("--set: patch must name at least one header field")
+	}
+	for _, field := range dlqFixReservedFields {
+		if _, ok := patch[field]; ok {
+			return nil, UsageError("--set: %q is assigned to every revision and cannot be patched", field)
+		}
+	}
+	return patch, nil
+}
+
+// reviseDLQOriginal applies patch to the original's header, or opens the
+// original in $EDITOR when no patch was given. It returns the revised bytes.
+func reviseDLQOriginal(originalContent []byte, patch map[string]json.RawMessage) ([]byte, error) {
+	if patch == nil {
+		return editDLQOriginal(originalContent)
+	}
+	msg, err := format.ParseMessage(originalContent)
+	if err != nil {
+		return nil, UsageError("original message does not parse (%v); omit --set to repair it in $EDITOR", err)
+	}
+	encoded, err := json.Marshal(patch)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&msg.Header); err != nil {
+		return nil, UsageError("--set: %v", err)
+	}
+	return msg.Marshal()
+}
+
+func editDLQOriginal(originalContent []byte) ([]byte, error) {
+	file, err := os.CreateTemp("", "amq-dlq-fix-*.md")
+	if err != nil {
+		return nil, err
+	}
+	path := file.Name()
+	defer func() { _ = os.Remove(path) }()
+	if _, err := file.Write(originalContent); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+	if err := editDLQFixFile(path); err != nil {
+		return nil, err
+	}
+	edited, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(edited, originalContent) {
+		return nil, UsageError("original left unchanged in editor; fix aborted")
+	}
+	return edited, nil
+}
+
+type dlqFixRevision struct {
+	Message  format.Message
+	Filename string
+	Data     []byte
+	// LinkedID is the original id appended to refs, if it was not already there.
+	LinkedID string
+}
+
+// buildDLQFixRevision turns revised bytes into a new message revision: it
+// mints a fresh id and timestamp, links refs to the original, and applies the
+// same strict header validation consumers apply on drain.
+func buildDLQFixRevision(
+	me string,
+	env *fsq.DLQEnvelope,
+	original format.Message,
+	parseErr error,
+	revised []byte,
+	validator *headerValidator,
+	now time.Time,
+) (dlqFixRevision, error) {
+	msg, err := format.ParseMessage(revised)
+	if err != nil {
+		return dlqFixRevision{}, UsageError("revised message does not parse: %v", err)
+	}
+	id, err := format.NewMessageID(now)
+	if err != nil {
+		return dlqFixRevision{}, err
+	}
+	msg.Header.Schema = format.CurrentSchema
+	msg.Header.ID = id
+	msg.Header.Created = now.UTC().Format(time.RFC3339Nano)
+
+	originalID := env.OriginalID
+	if parseErr == nil && original.Header.ID != "" {
+		originalID = original.Header.ID
+	}
+	linkedID := ""
+	if safeID, ok := safeHeaderID(originalID); ok && !slices.Contains(msg.Header.Refs, safeID) {
+		msg.Header.Refs = append(msg.Header.Refs, safeID)
+		linkedID = safeID
+	}
+
+	if err := validator.Validate(msg.Header); err != nil {
+		return dlqFixRevision{}, UsageError("revised header is still invalid: %v", err)
+	}
+	if !slices.Contains(msg.Header.To, me) {
+		return dlqFixRevision{}, UsageError("revised header must keep %q as a recipient; the revision is redelivered to this mailbox", me)
+	}
+	data, err := msg.Marshal()
+	if err != nil {
+		return dlqFixRevision{}, err
+	}
+	if len(data) > format.MaxMessageSize {
+		return dlqFixRevision{}, UsageError("revised message is %d bytes; maximum is %d", len(data), format.MaxMessageSize)
+	}
+	return dlqFixRevision{Message: msg, Filename: id + ".md", Data: data, LinkedID: linkedID}, nil
+}
+
+// dlqFixChanges lists the header fields and body that differ between the
+// original and the revision. Fields minted for every revision, and the refs
+// link to the original itself, are omitted.
+func dlqFixChanges(original format.Message, parseErr error, revision format.Message, linkedID string) []fsq.DLQFixChange {
+	if parseErr != nil {
+		return []fsq.DLQFixChange{{
+			Field:  "message",
+			Before: "unparseable: " + parseErr.Error(),
+			After:  "valid",
+		}}
+	}
+	revisedRefs := revision.Header.Refs
+	if linkedID != "" && len(revisedRefs) > 0 && revisedRefs[len(revisedRefs)-1] == linkedID {
+		revisedRefs = revisedRefs[:len(revisedRefs)-1]
+	}
+	fields := []struct {
+		name          string
+		before, after any
+	}{
+		{"from", original.Header.From, revision.Header.From},
+		{"to", original.Header.To, revision.Header.To},
+		{"thread", original.Header.Thread, revision.Header.Thread},
+		{"subject", original.Header.Subject, revision.Header.Subject},
+		{"refs", original.Header.Refs, revisedRefs},
+		{"priority", original.Header.Priority, revision.Header.Priority},
+		{"kind", original.Header.Kind, revision.Header.Kind},
+		{"labels", original.Header.Labels, revision.Header.Labels},
+		{"context", original.Header.Context, revision.Header.Context},
+		{"reply_to", original.Header.ReplyTo, revision.Header.ReplyTo},
+		{"reply_project", original.Header.ReplyProject, revision.Header.ReplyProject},
+		{"from_project", original.Header.FromProject, revision.Header.FromProject},
+	}
+	var changes []fsq.DLQFixChange
+	for _, field := range fields {
+		before, after := dlqFixValue(field.before), dlqFixValue(field.after)
+		if before == after {
+			continue
+		}
+		changes = append(changes, fsq.DLQFixChange{Field: field.name, Before: before, After: after})
+	}
+	if strings.TrimRight(original.Body, "\n") != strings.TrimRight(revision.Body, "\n") {
+		changes = append(changes, fsq.DLQFixChange{
+			Field:  "body",
+			Before: fmt.Sprintf("%d bytes", len(original.Body)),
+			After:  fmt.Sprintf("%d bytes", len(revision.Body)),
+		})
+	}
+	return changes
+}
+
+func dlqFixValue(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	if reflect.ValueOf(value).Len() == 0 {
+		return ""
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+func outputDLQFixResult(jsonOutput bool, result dlqFixResult) error {
+	if jsonOutput {
+		return writeJSON(os.Stdout, result)
+	}
+	verb := "Fixed"
+	if result.DryRun {
+		verb = "Would fix"
+	}
+	if err := writeStdout("%s %s: revision %s replaces %s\n", verb, result.Fixed, result.RevisionID, result.OriginalID); err != nil {
+		return err
+	}
+	for _, change := range result.Changes {
+		if err := writeStdout("  %s: %q -> %q\n", change.Field, change.Before, change.After); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/format"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+)
+
+func TestRunDLQFixPatchDeliversLinkedRevision(t *testing.T) {
+	root := initializedSendMailboxRoot(t, "alice", "bob")
+	dlqID := moveMessageToDLQForFix(t, root, "alice", format.Header{
+		ID:      "bad-kind",
+		From:    "bob",
+		To:      []string{"alice"},
+		Thread:  "p2p/alice__bob",
+		Created: time.Now().UTC().Format(time.RFC3339Nano),
+		Kind:    "bogus",
+	})
+
+	stdout, _, err := captureEnvOutput(t, func() error {
+		return runDLQFix([]string{"--root", root, "--me", "alice", "--id", dlqID, "--set", `{"kind":"question"}`, "--json"})
+	})
+	if err != nil {
+		t.Fatalf("dlq fix: %v", err)
+	}
+	var result dlqFixResult
+	if err := unmarshalJSONOutput(stdout, &result); err != nil {
+		t.Fatalf("decode fix output: %v (output: %s)", err, stdout)
+	}
+	if len(result.Changes) != 1 || result.Changes[0].Field != "kind" || result.Changes[0].After != "question" {
+		t.Fatalf("fix changes = %#v, want only kind", result.Changes)
+	}
+
+	revision, err := format.ReadMessageFile(filepath.Join(fsq.AgentInboxNew(root, "alice"), result.RevisionFile))
+	if err != nil {
+		t.Fatalf("read revision: %v", err)
+	}
+	if revision.Header.Kind != format.KindQuestion || !slices.Contains(revision.Header.Refs, "bad-kind") || revision.Header.ID == "bad-kind" {
+		t.Fatalf("revision header = %#v, want new id, fixed kind, refs to original", revision.Header)
+	}
+	env, _, err := fsq.ReadDLQEnvelopePath(filepath.Join(fsq.AgentDLQCur(root, "alice"), dlqID+".md"))
+	if err != nil {
+		t.Fatalf("read fixed envelope: %v", err)
+	}
+	if env.RetryState != fsq.RetryStateDelivered || env.Fix == nil || env.Fix.RevisionID != result.RevisionID {
+		t.Fatalf("fixed envelope = %#v, want delivered fix audit", env)
+	}
+}
+
+func TestRunDLQFixRefusesStillInvalidRevisionWithoutMutation(t *testing.T) {
+	root := initializedSendMailboxRoot(t, "alice", "bob")
+	dlqID := moveMessageToDLQForFix(t, root, "alice", format.Header{
+		ID:      "bad-sender",
+		From:    "ghost",
+		To:      []string{"alice"},
+		Thread:  "p2p/alice__ghost",
+		Created: time.Now().UTC().Format(time.RFC3339Nano),
+	})
+
+	_, _, err := captureEnvOutput(t, func() error {
+		return runDLQFix([]string{"--root", root, "--me", "alice", "--id", dlqID, "--set", `{"subject":"still from ghost"}`})
+	})
+	if err == nil || GetExitCode(err) != ExitUsage || !strings.Contains(err.Error(), "unknown sender handle") {
+		t.Fatalf("fix of still-invalid header = %v, want strict usage refusal", err)
+	}
+	entries, readErr := os.ReadDir(fsq.AgentInboxNew(root, "alice"))
+	if readErr != nil || len(entries) != 0 {
+		t.Fatalf("refused fix left inbox entries %v (err=%v)", entries, readErr)
+	}
+	if _, statErr := os.Stat(filepath.Join(fsq.AgentDLQNew(root, "alice"), dlqID+".md")); statErr != nil {
+		t.Fatalf("refused fix moved envelope: %v", statErr)
+	}
+}
+
+func TestRunDLQFixEditorRepairsUnparseableOriginal(t *testing.T) {
+	root := initializedSendMailboxRoot(t, "alice", "bob")
+	deliverInvalidDLQTransitionFixture(t, root, "alice", "unparseable")
+	deliveryRoot := openDeliveryRootForCLITest(t, root)
+	dlqPath, err := fsq.MoveToDLQ(deliveryRoot, "alice", "unparseable.md", "unparseable", "parse_error", "missing frontmatter")
+	if err != nil {
+		t.Fatalf("move fixture to DLQ: %v", err)
+	}
+	dlqID := strings.TrimSuffix(filepath.Base(dlqPath), ".md")
+
+	oldEdit := editDLQFixFile
+	editDLQFixFile = func(path string) error {
+		msg := format.Message{
+			Header: format.Header{
+				ID:      "unparseable",
+				From:    "bob",
+				To:      []string{"alice"},
+				Thread:  "p2p/alice__bob",
+				Created: time.Now().UTC().Format(time.RFC3339Nano),
+			},
+			Body: "missing frontmatter",
+		}
+		data, err := msg.Marshal()
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(path, data, 0o600)
+	}
+	t.Cleanup(func() { editDLQFixFile = oldEdit })
+
+	stdout, _, err := captureEnvOutput(t, func() error {
+		return runDLQFix([]string{"--root", root, "--me", "alice", "--id", dlqID})
+	})
+	if err != nil {
+		t.Fatalf("editor fix: %v", err)
+	}
+	if !strings.HasPrefix(stdout, "Fixed "+dlqID+": revision ") || !strings.Contains(stdout, "message: ") {
+		t.Fatalf("editor fix output = %q", stdout)
+	}
+	entries, err := os.ReadDir(fsq.AgentInboxNew(root, "alice"))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("inbox after editor fix = %v (err=%v), want one revision", entries, err)
+	}
+}
+
+func moveMessageToDLQForFix(t *testing.T, root, agent string, header format.Header) string {
+	t.Helper()
+	data, err := format.Message{Header: header, Body: "body"}.Marshal()
+	if err != nil {
+		t.Fatalf("marshal fixture: %v", err)
+	}
+	if _, err := deliverToInboxForTest(t, root, agent, header.ID+".md", data); err != nil {
+		t.Fatalf("deliver fixture: %v", err)
+	}
+	dlqPath, err := fsq.MoveToDLQ(openDeliveryRootForCLITest(t, root), agent, header.ID+".md", header.ID, "invalid_header", "fixture")
+	if err != nil {
+		t.Fatalf("move fixture to DLQ: %v", err)
+	}
+	return strings.TrimSuffix(filepath.Base(dlqPath), ".md")
+}
@@ -23,6 +23,7 @@ type doctorResult struct {
 	Checks               []doctorCheck               `json:"checks"`
 	Mailboxes            []fsq.MailboxInspection     `json:"mailboxes,omitempty"`
 	MailboxRepair        *fsq.MailboxRepairResult    `json:"mailbox_repair,omitempty"`
+	QueueLeases          []doctorQueueLeases         `json:"queue_leases,omitempty"`
 	ExtensionManifests   []doctorExtensionManifest   `json:"extension_manifests,omitempty"`
 	ExtensionDiagnostics []doctorExtensionDiagnostic `json:"extension_diagnostics,omitempty"`
 	Summary              struct {
@@ -47,6 +48,7 @@ func runDoctor(args []string) error {
 	opsFlag := fs.Bool("ops", false, "Include runtime operational checks")
 	fixWakeLocksFlag := fs.Bool("fix-wake-locks", false, "With --ops, remove stale wake lock files")
 	fixMailboxesFlag := fs.Bool("fix-mailboxes", false, "Create missing required directories for configured mailboxes")
+	fixQueueLeasesFlag := fs.Bool("fix-queue-leases", false, "Return queue items whose claim outlived its lease")
 	rootFlag := fs.String("root", "", "Exact AMQ root to inspect or repair")
 	baseRootFlag := fs.String("base-root", "", "Config-authority root for an explicit session --root")
 	ignoreSessionPinFlag := fs.Bool("ignore-session-pin", false, "With explicit --root, allow repair outside the pinned session context")
@@ -59,6 +61,7 @@ func runDoctor(args []string) error {
 		"  - .amqrc configuration",
 		"  - Mailbox directory permissions",
 		"  - Agent configuration (config.json)",
+		"  - Expired queue claims",
 		"  - Extension metadata manifests and diagnostics",
 		"  - Skill installation (Claude Code / Codex / Grok Build)",
 		"",
@@ -149,6 +152,12 @@ func runDoctor(args []string) error {
 		result.Mailboxes = mailboxes
 		result.MailboxRepair = repair
 		result.Checks = append(result.Checks, check)
+
+		leases, leaseCheck := inspectDoctorQueueLeases(root, *fixQueueLeasesFlag, *ignoreSessionPinFlag)
+		result.QueueLeases = leases
+		if leaseCheck != nil {
+			result.Checks = append(result.Checks, *leaseCheck)
+		}
 	}
 
 	// Check 6: Extension metadata
@@ -270,7 +279,7 @@ func runDoctor(args []string) error {
 			if a.DLQCount > 0 {
 				line += fmt.Sprintf(", %d DLQ", a.DLQCount)
 			}
-			line += fmt.Sprintf(", presence %s (%.0fs ago)", a.PresenceStatus, a.PresenceAgeSeconds)
+			line += fmt.Sprintf(", presence %s (%.0fs ago, %s)", a.PresenceStatus, a.PresenceAgeSeconds, a.DerivedStatus)
 			if a.PresenceSource != "" {
 				line += ", source " + a.PresenceSource
 			}
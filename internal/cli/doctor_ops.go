@@ -12,7 +12,6 @@ import (
 
 	"github.com/avivsinai/agent-message-queue/internal/config"
 	"github.com/avivsinai/agent-message-queue/internal/fsq"
-	"github.com/avivsinai/agent-message-queue/internal/presence"
 )
 
 type doctorOpsResult struct {
@@ -36,20 +35,33 @@ type opsRoot struct {
 }
 
 type opsAgent struct {
-	Handle                 string  `json:"handle"`
-	UnreadCount            int     `json:"unread_count"`
-	OldestUnreadAgeSeconds float64 `json:"oldest_unread_age_seconds"`
-	DLQCount               int     `json:"dlq_count"`
-	OldestDLQAgeSeconds    float64 `json:"oldest_dlq_age_seconds"`
-	PresenceStatus         string  `json:"presence_status"`
-	PresenceAgeSeconds     float64 `json:"presence_age_seconds"`
-	PresenceSource         string  `json:"presence_source,omitempty"`
-	NotifierStatus         string  `json:"notifier_status,omitempty"`
-	NotifierMode           string  `json:"notifier_mode,omitempty"`
-	NotifierReason         string  `json:"notifier_reason,omitempty"`
-	DoorbellParked         bool    `json:"doorbell_parked,omitempty"`
-	DoorbellAttempts       uint    `json:"doorbell_attempts,omitempty"`
-}
+	Handle                 string    `json:"handle"`
+	UnreadCount            int       `json:"unread_count"`
+	OldestUnreadAgeSeconds float64   `json:"oldest_unread_age_seconds"`
+	DLQCount               int       `json:"dlq_count"`
+	OldestDLQAgeSeconds    float64   `json:"oldest_dlq_age_seconds"`
+	PresenceStatus         string    `json:"presence_status"`
+	PresenceAgeSeconds     float64   `json:"presence_age_seconds"`
+	PresenceSource         string    `json:"presence_source,omitempty"`
+	DerivedStatus          string    `json:"derived_status"`
+	NotifierStatus         string    `json:"notifier_status,omitempty"`
+	NotifierMode           string    `json:"notifier_mode,omitempty"`
+	NotifierReason         string    `json:"notifier_reason,omitempty"`
+	DoorbellParked         bool      `json:"doorbell_parked,omitempty"`
+	DoorbellAttempts       uint      `json:"doorbell_attempts,omitempty"`
+	Quota                  *opsQuota `json:"quota,omitempty"`
+}
+
+// opsQuota reports an agent's undrained usage against its configured limits.
+type opsQuota struct {
+	MaxUndrained int     `json:"max_undrained,omitempty"`
+	MaxBytes     int64   `json:"max_bytes,omitempty"`
+	Bytes        int64   `json:"bytes"`
+	UsedRatio    float64 `json:"used_ratio"`
+}
+
+// quotaNearRatio is the usage fraction at which doctor --ops warns.
+const quotaNearRatio = 0.8
 
 type opsOperatorGate struct {
 	OpenCount            int     `json:"open_count"`
@@ -172,7 +184,8 @@ func runOpsChecksWithSchema(
 
 	// Load the active root's config, falling back to the base config for normal
 	// session layouts where coop init owns the single config.json.
-	agents, err := loadOpsAgents(root, fixWakeLocks, explicitBaseRoot...)
+	cfg, err := loadOpsConfig(root, fixWakeLocks, explicitBaseRoot...)
+	agents := cfg.Agents
 	if err != nil {
 		result.Hints = append(result.Hints, opsHint{
 			Code:    "config_error",
@@ -190,6 +203,16 @@ func runOpsChecksWithSchema(
 		return result
 	}
 
+	thresholds, err := cfg.Presence.Thresholds()
+	if err != nil {
+		result.Hints = append(result.Hints, opsHint{
+			Code:    "config_error",
+			Status:  "error",
+			Message: fmt.Sprintf("Using default presence thresholds: %v", err),
+		})
+		thresholds, _ = (*config.PresenceConfig)(nil).Thresholds()
+	}
+
 	validatedAgents := make([]string, 0, len(agents))
 	for _, handle := range agents {
 		// Configured handles are untrusted input. Validate before deriving any
@@ -212,11 +235,13 @@ func runOpsChecksWithSchema(
 		// Unread count + oldest
 		inboxNew := fsq.AgentInboxNew(root, handle)
 		entries, err := os.ReadDir(inboxNew)
+		var unreadBytes int64
 		if err == nil {
 			agent.UnreadCount = len(entries)
 			for _, e := range entries {
 				info, err := e.Info()
 				if err == nil {
+					unreadBytes += info.Size()
 					age := now.Sub(info.ModTime()).Seconds()
 					if age > agent.OldestUnreadAgeSeconds {
 						agent.OldestUnreadAgeSeconds = age
@@ -242,29 +267,30 @@ func runOpsChecksWithSchema(
 		}
 
 		// Presence
-		recentActivity := false
-		p, err := presence.Read(root, handle)
-		if err == nil {
+		state := deriveAgentPresence(root, handle, thresholds, now)
+		if p := state.Presence; p != nil {
 			agent.PresenceStatus = p.Status
 			agent.NotifierStatus = p.NotifierStatus
 			agent.NotifierMode = p.NotifierMode
 			agent.NotifierReason = p.NotifierReason
 			agent.DoorbellParked = p.DoorbellParked
 			agent.DoorbellAttempts = p.DoorbellAttempts
-			if t, err := time.Parse(time.RFC3339Nano, p.LastSeen); err == nil {
-				agent.PresenceAgeSeconds = now.Sub(t).Seconds()
-				recentActivity = agent.PresenceAgeSeconds < (10 * time.Minute).Seconds()
-			}
+			agent.PresenceAgeSeconds = state.Age.Seconds()
 		} else {
 			agent.PresenceStatus = "unknown"
 		}
-		agent.PresenceSource = resolvePresenceSource(root, handle, recentActivity)
+		agent.PresenceSource = state.Source
+		agent.DerivedStatus = state.Status
 
 		// Round to reasonable precision
 		agent.OldestUnreadAgeSeconds = math.Round(agent.OldestUnreadAgeSeconds)
 		agent.OldestDLQAgeSeconds = math.Round(agent.OldestDLQAgeSeconds)
 		agent.PresenceAgeSeconds = math.Round(agent.PresenceAgeSeconds)
 
+		if hint := applyOpsQuota(&agent, cfg.Quotas, unreadBytes); hint != nil {
+			result.Hints = append(result.Hints, *hint)
+		}
+
 		result.Agents = append(result.Agents, agent)
 		if agent.DoorbellParked && agent.UnreadCount > 0 &&
 			agent.PresenceSource == presenceSourceNotifierLive {
@@ -361,34 +387,59 @@ func wakeLockProvesNotifierAbsent(lock opsWakeLock) bool {
 	return lock.NotifierAbsent
 }
 
-func loadOpsAgents(root string, fixWakeLocks bool, explicitBaseRoot ...string) ([]string, error) {
+func loadOpsConfig(root string, fixWakeLocks bool, explicitBaseRoot ...string) (config.Config, error) {
 	if len(explicitBaseRoot) > 0 && strings.TrimSpace(explicitBaseRoot[0]) != "" {
-		cfg, err := config.LoadConfig(filepath.Join(explicitBaseRoot[0], "meta", "config.json"))
-		if err != nil {
-			return nil, err
-		}
-		return cfg.Agents, nil
+		return config.LoadConfig(filepath.Join(explicitBaseRoot[0], "meta", "config.json"))
 	}
 	cfg, err := config.LoadConfig(filepath.Join(root, "meta", "config.json"))
 	if err == nil {
-		return cfg.Agents, nil
+		return cfg, nil
 	}
 	if !os.IsNotExist(err) {
-		return nil, err
+		return config.Config{}, err
 	}
 
 	if fixWakeLocks {
-		return nil, err
+		return config.Config{}, err
 	}
 	base := baseRootOfForDisplay(root)
 	if absPath(resolveRoot(base)) == absPath(resolveRoot(root)) {
-		return nil, err
+		return config.Config{}, err
+	}
+	return config.LoadConfig(filepath.Join(base, "meta", "config.json"))
+}
+
+// applyOpsQuota records quota usage on agent and returns a hint when the
+// mailbox is full or within quotaNearRatio of its undrained limits.
+func applyOpsQuota(agent *opsAgent, quotas *config.QuotaConfig, unreadBytes int64) *opsHint {
+	limits := quotas.For(agent.Handle)
+	if limits.MaxUndrained <= 0 && limits.MaxBytes <= 0 {
+		return nil
+	}
+	q := &opsQuota{MaxUndrained: limits.MaxUndrained, MaxBytes: limits.MaxBytes, Bytes: unreadBytes}
+	if limits.MaxUndrained > 0 {
+		q.UsedRatio = float64(agent.UnreadCount) / float64(limits.MaxUndrained)
 	}
-	baseCfg, baseErr := config.LoadConfig(filepath.Join(base, "meta", "config.json"))
-	if baseErr != nil {
-		return nil, baseErr
+	if limits.MaxBytes > 0 {
+		q.UsedRatio = math.Max(q.UsedRatio, float64(unreadBytes)/float64(limits.MaxBytes))
 	}
-	return baseCfg.Agents, nil
+	q.UsedRatio = math.Round(q.UsedRatio*100) / 100
+	agent.Quota = q
+	switch {
+	case q.UsedRatio >= 1:
+		return &opsHint{
+			Code:    "quota_full",
+			Status:  "error",
+			Message: fmt.Sprintf("Agent %s mailbox is at its quota (%d unread, %d bytes); senders are refused until it drains", agent.Handle, agent.UnreadCount, unreadBytes),
+		}
+	case q.UsedRatio >= quotaNearRatio:
+		return &opsHint{
+			Code:    "quota_near_limit",
+			Status:  "warn",
+			Message: fmt.Sprintf("Agent %s mailbox is at %.0f%% of its quota (%d unread, %d bytes)", agent.Handle, q.UsedRatio*100, agent.UnreadCount, unreadBytes),
+		}
+	}
+	return nil
 }
 
 func checkSiblingBacklogHints(root string, agents []string) []opsHint {
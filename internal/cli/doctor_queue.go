@@ -0,0 +1,146 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/config"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+	"github.com/avivsinai/agent-message-queue/internal/sessionguard"
+)
+
+// doctorQueueLeases reports the open claims on one queue handle. Expired
+// lists claims whose claimer has been quiet for longer than the lease;
+// Returned lists the ones --fix-queue-leases put back on the queue.
+type doctorQueueLeases struct {
+	Queue    string   `json:"queue"`
+	Open     int      `json:"open"`
+	Expired  []string `json:"expired,omitempty"`
+	Returned []string `json:"returned,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// inspectDoctorQueueLeases finds expired leases on every configured queue
+// and, with fix, returns their items to the queue. Without a doctor run (or
+// a member's next claim) an item claimed by an agent that went away would
+// stay in the queue's inbox/cur. The check is omitted when no queues exist.
+func inspectDoctorQueueLeases(root string, fix, ignoreSessionPins bool) ([]doctorQueueLeases, *doctorCheck) {
+	check := &doctorCheck{Name: "Queue leases"}
+	if fix {
+		decision, mismatch, pinErr := doctorRepairSessionGuard(root, ignoreSessionPins)
+		switch decision.Row {
+		case sessionguard.RowR13DoctorInvalidErr:
+			check.Status = "error"
+			check.Message = fmt.Sprintf("refusing to repair %s: invalid AMQ session context: %v", root, pinErr)
+			return nil, check
+		case sessionguard.RowR12DoctorMismatchErr:
+			check.Status = "error"
+			check.Message = fmt.Sprintf("refusing to repair %s because it does not match the pinned session context: %s", root, mismatch.Message)
+			return nil, check
+		}
+	}
+	identity, err := fsq.SnapshotDeliveryRoot(root)
+	if err != nil {
+		check.Status = "error"
+		check.Message = err.Error()
+		return nil, check
+	}
+	deliveryRoot, err := fsq.OpenDeliveryRoot(root, identity)
+	if err != nil {
+		check.Status = "error"
+		check.Message = err.Error()
+		return nil, check
+	}
+	defer func() { _ = deliveryRoot.Close() }()
+	cfg, err := config.ReadConfig(deliveryRoot)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		check.Status = "error"
+		check.Message = fmt.Sprintf("read queue config: %v", err)
+		return nil, check
+	}
+	if len(cfg.Queues) == 0 {
+		return nil, nil
+	}
+	queues := make([]string, 0, len(cfg.Queues))
+	for queue := range cfg.Queues {
+		queues = append(queues, queue)
+	}
+	sort.Strings(queues)
+
+	now := time.Now()
+	var reports []doctorQueueLeases
+	var expired, returned, failed int
+	for _, queue := range queues {
+		report := doctorQueueLeases{Queue: queue}
+		leaseDuration, err := cfg.Queues[queue].LeaseDuration()
+		if err == nil {
+			err = fsq.ValidateHandle(queue)
+		}
+		if err == nil {
+			report.Open, report.Expired, err = expiredQueueLeases(deliveryRoot, root, queue, leaseDuration, now)
+		}
+		if err == nil && fix && len(report.Expired) > 0 {
+			report.Returned, err = returnStaleQueueClaims(deliveryRoot, root, queue, leaseDuration, now)
+		}
+		if err != nil {
+			report.Error = err.Error()
+			failed++
+		}
+		expired += len(report.Expired)
+		returned += len(report.Returned)
+		reports = append(reports, report)
+	}
+
+	switch {
+	case failed > 0:
+		check.Status = "error"
+		check.Message = fmt.Sprintf("%d of %d queue(s) could not be inspected", failed, len(queues))
+	case returned > 0:
+		check.Status = "ok"
+		check.Message = fmt.Sprintf("returned %d expired claim(s) to their queues", returned)
+	case expired > 0:
+		check.Status = "warn"
+		check.Message = fmt.Sprintf("%d claim(s) have outlived their lease; run 'amq doctor --fix-queue-leases' to return them", expired)
+	default:
+		check.Status = "ok"
+		check.Message = fmt.Sprintf("%d queue(s), no expired claims", len(queues))
+	}
+	return reports, check
+}
+
+// expiredQueueLeases counts a queue's open leases and lists the message IDs
+// of those whose claimer is stale, without changing anything.
+func expiredQueueLeases(deliveryRoot *fsq.DeliveryRoot, root, queue string, leaseDuration time.Duration, now time.Time) (int, []string, error) {
+	entries, err := deliveryRoot.ReadDir(queueLeaseDir(queue))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil, nil
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	open := 0
+	var expired []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		lease, err := readQueueLease(deliveryRoot, queue, name)
+		if err != nil || lease.CompletedAt != "" {
+			continue
+		}
+		open++
+		if queueClaimerStale(deliveryRoot, root, lease, leaseDuration, now) {
+			expired = append(expired, lease.MsgID)
+		}
+	}
+	sort.Strings(expired)
+	return open, expired, nil
+}
@@ -72,6 +72,9 @@ func runDrain(args []string) error {
 		return err
 	}
 	defer func() { _ = deliveryRoot.Close() }()
+	if err := refuseQueueHandle(deliveryRoot, "drain", common.Me); err != nil {
+		return err
+	}
 
 	items, err := drainInboxItems(deliveryRoot, root, common.Me, *includeBodyFlag, *limitFlag, validator)
 	return finishDrainBatch(deliveryRoot, root, common.Me, common.JSON, *includeBodyFlag, items, err)
@@ -82,7 +85,7 @@ func finishDrainBatch(deliveryRoot *fsq.DeliveryRoot, root, me string, jsonOutpu
 	// even if a later claim fails. Emit accumulated results before returning the
 	// batch error so callers can both consume the committed work and retry.
 	if len(items) > 0 {
-		_ = presence.TouchDeliveryRoot(deliveryRoot, me)
+		_ = presence.ReturnDeliveryRoot(deliveryRoot, me)
 		if err := outputDrainItems(jsonOutput, me, includeBody, items); err != nil {
 			if drainErr != nil {
 				return errors.Join(drainErr, err)
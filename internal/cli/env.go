@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/avivsinai/agent-message-queue/internal/sessionguard"
 )
@@ -50,6 +52,18 @@ type envOutput struct {
 	Peers         map[string]string `json:"peers"`
 	Shell         string            `json:"shell,omitempty"`
 	Wake          bool              `json:"wake,omitempty"`
+	// DerivedStatus is me's derived presence status in root, when me has a
+	// mailbox there.
+	DerivedStatus string `json:"derived_status,omitempty"`
+}
+
+// envDerivedStatus is best effort: env must keep working for agents without
+// a mailbox yet or with a broken config.
+func envDerivedStatus(root, me string) string {
+	if me == "" || me == reservedHumanHandle || !dirExists(filepath.Join(root, "agents", me)) {
+		return ""
+	}
+	return deriveAgentPresence(root, me, presenceThresholds(root), time.Now()).Status
 }
 
 // errAmqrcNotFound is returned when .amqrc is not found (non-fatal).
@@ -241,6 +255,7 @@ func runEnv(args []string) error {
 			Peers:         peers,
 			Shell:         shell,
 			Wake:          *wakeFlag,
+			DerivedStatus: envDerivedStatus(root, me),
 		}
 		return writeJSON(os.Stdout, out)
 	}
@@ -1122,9 +1137,10 @@ func findAmqrcForRoot(root string) (amqrcResult, error) {
 	return findAndLoadAmqrc()
 }
 
-// resolvePeer looks up a peer project name in the .amqrc peers map and returns
-// the absolute base root path for that peer. Returns an error if .amqrc is not
-// found, has no peers, or the peer name is not registered.
+// resolvePeer looks up a peer project name in the .amqrc peers map, falling
+// back to the user-level project registry, and returns the absolute base root
+// path for that peer. Returns an error if .amqrc is not found or the peer name
+// is registered in neither source.
 func resolvePeer(root, project string) (string, error) {
 	result, err := findAmqrcForRoot(root)
 	if err != nil {
@@ -1134,22 +1150,36 @@ func resolvePeer(root, project string) (string, error) {
 }
 
 func resolvePeerFromAmqrcResult(result amqrcResult, project string) (string, error) {
-	if len(result.Config.Peers) == 0 {
-		return "", fmt.Errorf("no peers configured in .amqrc (looking for %q)", project)
-	}
-	peerPath, ok := result.Config.Peers[project]
-	if !ok {
-		known := make([]string, 0, len(result.Config.Peers))
-		for k := range result.Config.Peers {
-			known = append(known, k)
+	peerRoot, _, err := resolvePeerWithSource(result, project)
+	return peerRoot, err
+}
+
+// resolvePeerWithSource also reports which source resolved the peer:
+// peerSourceAmqrc or peerSourceRegistry.
+func resolvePeerWithSource(result amqrcResult, project string) (string, string, error) {
+	if peerPath, ok := result.Config.Peers[project]; ok {
+		abs, err := resolvePeerPath(result, peerPath)
+		if err != nil {
+			return "", "", fmt.Errorf("resolve peer path for %q: %w", project, err)
 		}
-		return "", fmt.Errorf("peer %q not found in .amqrc (known: %v)", project, known)
+		return abs, peerSourceAmqrc, nil
 	}
-	abs, err := resolvePeerPath(result, peerPath)
+	registered, ok, err := lookupRegisteredProject(project)
 	if err != nil {
-		return "", fmt.Errorf("resolve peer path for %q: %w", project, err)
+		return "", "", fmt.Errorf("resolve peer %q: %w", project, err)
+	}
+	if ok {
+		return registered, peerSourceRegistry, nil
+	}
+	if len(result.Config.Peers) == 0 {
+		return "", "", fmt.Errorf("peer %q not found: no peers configured in .amqrc and not in the project registry (see 'amq peers list')", project)
+	}
+	known := make([]string, 0, len(result.Config.Peers))
+	for k := range result.Config.Peers {
+		known = append(known, k)
 	}
-	return abs, nil
+	sort.Strings(known)
+	return "", "", fmt.Errorf("peer %q not found in .amqrc (known: %v) or the project registry (see 'amq peers list')", project, known)
 }
 
 func resolvePeerPath(result amqrcResult, peerPath string) (string, error) {
@@ -1,6 +1,12 @@
 package cli
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/avivsinai/agent-message-queue/internal/acl"
+	"github.com/avivsinai/agent-message-queue/internal/quota"
+)
 
 // Exit codes for CLI commands.
 // These provide semantic meaning for scripting and automation.
@@ -29,6 +35,14 @@ const (
 	// operator action (stale conversation token, Inspect unknown, untrusted
 	// config digest, refused committed-command shape, blocked auto-rebind).
 	ExitActionRequired = 6
+
+	// ExitQuotaExceeded indicates a delivery was refused because a recipient
+	// mailbox is over its configured quota.
+	ExitQuotaExceeded = 7
+
+	// ExitDeliveryDenied indicates a delivery was refused by a recipient's
+	// ACL policy.
+	ExitDeliveryDenied = 8
 )
 
 // SessionContextError identifies an unsafe or incoherent mailbox context.
@@ -59,6 +73,8 @@ func (e *ExitCodeError) Unwrap() error {
 // GetExitCode extracts the exit code from an error.
 // Returns ExitSuccess (0) if err is nil.
 // Returns the wrapped code if err is an *ExitCodeError.
+// Returns ExitDeliveryDenied or ExitQuotaExceeded for a wrapped ACL or quota
+// refusal.
 // Returns ExitError (1) for all other errors.
 func GetExitCode(err error) int {
 	if err == nil {
@@ -67,6 +83,16 @@ func GetExitCode(err error) int {
 	if exitErr, ok := err.(*ExitCodeError); ok {
 		return exitErr.Code
 	}
+	// Refusals raised below the CLI (integration, swarm, held-mail release)
+	// keep their typed exit status.
+	var denied *acl.DeniedError
+	if errors.As(err, &denied) {
+		return ExitDeliveryDenied
+	}
+	var exceeded *quota.ExceededError
+	if errors.As(err, &exceeded) {
+		return ExitQuotaExceeded
+	}
 	return ExitError
 }
 
@@ -118,6 +144,22 @@ func ActionRequiredError(format string, args ...any) error {
 	}
 }
 
+// QuotaExceededError wraps err with ExitQuotaExceeded code.
+func QuotaExceededError(err error) error {
+	return &ExitCodeError{
+		Code: ExitQuotaExceeded,
+		Err:  err,
+	}
+}
+
+// DeliveryDeniedError wraps err with ExitDeliveryDenied code.
+func DeliveryDeniedError(err error) error {
+	return &ExitCodeError{
+		Code: ExitDeliveryDenied,
+		Err:  err,
+	}
+}
+
 // AgentDisposition classifies a per-agent launch/resume outcome.
 type AgentDisposition string
 
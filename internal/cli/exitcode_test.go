@@ -4,6 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+
+	"github.com/avivsinai/agent-message-queue/internal/acl"
+	"github.com/avivsinai/agent-message-queue/internal/quota"
 )
 
 func TestGetExitCode(t *testing.T) {
@@ -19,6 +22,8 @@ func TestGetExitCode(t *testing.T) {
 		{"timeout error", TimeoutError("timed out"), ExitTimeout},
 		{"context mismatch", ContextMismatchError("unsafe context"), ExitContextMismatch},
 		{"wrapped exit code", WithExitCode(ExitNotFound, errors.New("custom")), ExitNotFound},
+		{"wrapped acl refusal", fmt.Errorf("deliver: %w", &acl.DeniedError{}), ExitDeliveryDenied},
+		{"wrapped quota refusal", fmt.Errorf("deliver: %w", &quota.ExceededError{}), ExitQuotaExceeded},
 	}
 
 	for _, tt := range tests {
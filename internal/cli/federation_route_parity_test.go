@@ -9,6 +9,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/avivsinai/agent-message-queue/internal/breaker"
 	"github.com/avivsinai/agent-message-queue/internal/format"
 	"github.com/avivsinai/agent-message-queue/internal/fsq"
 )
@@ -165,6 +166,12 @@ func TestFederationExplicitProjectRoundTripAndRouteArgvParity(t *testing.T) {
 	if response.Header.ReplyProject != "peer" || response.Header.FromProject != "peer" {
 		t.Fatalf("response project identity = reply:%q from:%q, want peer", response.Header.ReplyProject, response.Header.FromProject)
 	}
+	// Both cross-project hops ran the loop breaker on their delivery root.
+	for _, root := range []string{peerRoot, sourceRoot} {
+		if _, err := os.Stat(filepath.Join(root, "meta", "breakers", breaker.Key(request.Header.Thread), "state.json")); err != nil {
+			t.Fatalf("no breaker state in %s: %v", root, err)
+		}
+	}
 }
 
 func TestFederationStrictSendUsesUnpinnedSourceBaseConfig(t *testing.T) {
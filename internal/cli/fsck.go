@@ -0,0 +1,602 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/format"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+	"github.com/avivsinai/agent-message-queue/internal/receipt"
+)
+
+// Finding codes reported by amq fsck.
+const (
+	fsckUnparseableMessage  = "unparseable_message"
+	fsckFilenameIDMismatch  = "filename_id_mismatch"
+	fsckDuplicateInboxCopy  = "duplicate_inbox_copy"
+	fsckIDCollision         = "id_collision"
+	fsckOutboxMismatch      = "outbox_mismatch"
+	fsckReceiptUnparseable  = "receipt_unparseable"
+	fsckReceiptOrphan       = "receipt_orphan"
+	fsckDLQEnvelopeInvalid  = "dlq_envelope_invalid"
+	fsckDLQOriginalMissing  = "dlq_original_missing"
+	fsckDLQOriginalMismatch = "dlq_original_mismatch"
+	fsckDLQOriginalInvalid  = "dlq_original_invalid"
+	fsckStaleTmp            = "stale_tmp"
+)
+
+const (
+	fsckSeverityError = "error"
+	fsckSeverityWarn  = "warn"
+)
+
+// Repair actions planned by amq fsck.
+const (
+	fsckActionMoveToDLQ     = "move_to_dlq"
+	fsckActionRemoveNewCopy = "remove_new_copy"
+	fsckActionRemoveTmp     = "remove_tmp"
+)
+
+const fsckDefaultTmpAge = time.Hour
+
+type fsckFinding struct {
+	Code      string      `json:"code"`
+	Severity  string      `json:"severity"`
+	Agent     string      `json:"agent,omitempty"`
+	Path      string      `json:"path"`
+	MessageID string      `json:"message_id,omitempty"`
+	Detail    string      `json:"detail"`
+	Repair    *fsckRepair `json:"repair,omitempty"`
+
+	rel string
+}
+
+// fsckRepair is one planned repair. Applied is set only under --fix.
+type fsckRepair struct {
+	Action  string `json:"action"`
+	Applied bool   `json:"applied"`
+	Error   string `json:"error,omitempty"`
+}
+
+type fsckCounts struct {
+	Agents   int `json:"agents"`
+	Messages int `json:"messages"`
+	Receipts int `json:"receipts"`
+	DLQ      int `json:"dlq"`
+}
+
+type fsckResult struct {
+	Root     string        `json:"root"`
+	Fix      bool          `json:"fix"`
+	Checked  fsckCounts    `json:"checked"`
+	Findings []fsckFinding `json:"findings"`
+	Clean    bool          `json:"clean"`
+}
+
+// fsckCopy is one on-disk copy of a message id.
+type fsckCopy struct {
+	agent  string
+	box    string
+	rel    string
+	to     []string
+	digest [sha256.Size]byte
+}
+
+type fsckScan struct {
+	root     *fsq.DeliveryRoot
+	now      time.Time
+	tmpAge   time.Duration
+	result   *fsckResult
+	copies   map[string][]fsckCopy
+	knownIDs map[string]bool
+}
+
+func runFsck(args []string) error {
+	fs := flag.NewFlagSet("fsck", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	fixFlag := fs.Bool("fix", false, "Apply the repair plan (default is a dry run)")
+	tmpAgeFlag := fs.Duration("tmp-age", fsckDefaultTmpAge, "Report tmp files older than this as orphans")
+	sessionFlag := fs.String("session", "", "Target session under the resolved base root")
+	ignoreSessionPinFlag := fs.Bool("ignore-session-pin", false, "With explicit --root, ignore a conflicting AM_SESSION pin")
+
+	usage := usageWithFlags(fs, "amq fsck [--fix] [--tmp-age <dur>] [--session <name>] [options]",
+		"",
+		"Audits message content across every mailbox: parseability, filename/id",
+		"agreement, duplicate ids, outbox/inbox byte agreement, receipt references,",
+		"DLQ envelopes, and orphaned tmp files. Without --fix it only prints the",
+		"repair plan. Repairs are conservative: unparseable inbox messages move to",
+		"the DLQ, a new copy identical to its cur copy is removed, and stale tmp",
+		"files are deleted. Exits 1 while any finding remains unrepaired.")
+	if handled, err := parseFlags(fs, args, usage); err != nil {
+		return err
+	} else if handled {
+		return nil
+	}
+	if *tmpAgeFlag <= 0 {
+		return UsageError("--tmp-age must be positive")
+	}
+	deliveryRoot, err := openMailboxCommandRoot("fsck", common, *sessionFlag, *ignoreSessionPinFlag)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = deliveryRoot.Close() }()
+
+	result, err := checkMailboxIntegrity(deliveryRoot, time.Now(), *tmpAgeFlag)
+	if err != nil {
+		return err
+	}
+	if *fixFlag {
+		result.Fix = true
+		applyFsckRepairs(deliveryRoot, result)
+	}
+	result.Clean = true
+	for _, finding := range result.Findings {
+		if finding.Repair == nil || !finding.Repair.Applied {
+			result.Clean = false
+			break
+		}
+	}
+
+	var outputErr error
+	if common.JSON {
+		outputErr = writeJSON(os.Stdout, result)
+	} else {
+		outputErr = printFsckResult(result)
+	}
+	if outputErr != nil {
+		return outputErr
+	}
+	if !result.Clean {
+		return WithExitCode(ExitError, fmt.Errorf("fsck: %d finding(s) need attention", countOpenFsckFindings(result)))
+	}
+	return nil
+}
+
+// checkMailboxIntegrity scans every agent mailbox under root and returns
+// typed findings with a planned repair where one is safe.
+func checkMailboxIntegrity(root *fsq.DeliveryRoot, now time.Time, tmpAge time.Duration) (*fsckResult, error) {
+	scan := &fsckScan{
+		root:     root,
+		now:      now,
+		tmpAge:   tmpAge,
+		result:   &fsckResult{Root: root.Base(), Findings: []fsckFinding{}},
+		copies:   map[string][]fsckCopy{},
+		knownIDs: map[string]bool{},
+	}
+	agents, err := fsckAgents(root)
+	if err != nil {
+		return nil, err
+	}
+	scan.result.Checked.Agents = len(agents)
+	for _, agent := range agents {
+		for _, leaf := range []fsq.MailboxLeaf{fsq.MailboxInboxNew, fsq.MailboxInboxCur, fsq.MailboxOutboxSent} {
+			if err := scan.scanMessages(agent, leaf); err != nil {
+				return nil, err
+			}
+		}
+		if err := scan.scanDLQ(agent); err != nil {
+			return nil, err
+		}
+		if err := scan.scanTmp(agent); err != nil {
+			return nil, err
+		}
+	}
+	scan.checkCopies()
+	for _, agent := range agents {
+		if err := scan.scanReceipts(agent); err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(scan.result.Findings, func(i, j int) bool {
+		a, b := scan.result.Findings[i], scan.result.Findings[j]
+		if a.Severity != b.Severity {
+			return a.Severity == fsckSeverityError
+		}
+		return a.Path < b.Path
+	})
+	return scan.result, nil
+}
+
+func fsckAgents(root *fsq.DeliveryRoot) ([]string, error) {
+	entries, err := root.ReadDir("agents")
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var agents []string
+	for _, entry := range entries {
+		if entry.IsDir() && fsq.ValidateHandle(entry.Name()) == nil {
+			agents = append(agents, entry.Name())
+		}
+	}
+	sort.Strings(agents)
+	return agents, nil
+}
+
+// fsckEntries lists regular, non-dot files in a root-relative directory.
+func fsckEntries(root *fsq.DeliveryRoot, dir string) ([]os.DirEntry, error) {
+	entries, err := root.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	files := entries[:0]
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && !strings.HasPrefix(entry.Name(), ".") {
+			files = append(files, entry)
+		}
+	}
+	return files, nil
+}
+
+func (s *fsckScan) add(finding fsckFinding) {
+	finding.Path = s.root.DisplayPath(finding.rel)
+	s.result.Findings = append(s.result.Findings, finding)
+}
+
+func (s *fsckScan) scanMessages(agent string, leaf fsq.MailboxLeaf) error {
+	dir := fsq.MailboxRootRelativePath(agent, leaf)
+	entries, err := fsckEntries(s.root, dir)
+	if err != nil {
+		return err
+	}
+	box := filepath.Base(string(leaf))
+	for _, entry := range entries {
+		rel := filepath.Join(dir, entry.Name())
+		s.result.Checked.Messages++
+		data, err := s.root.ReadRegularNoFollow(rel)
+		if err != nil {
+			return err
+		}
+		fileID := strings.TrimSuffix(entry.Name(), ".md")
+		msg, err := format.ParseMessage(data)
+		if err != nil {
+			finding := fsckFinding{
+				Code: fsckUnparseableMessage, Severity: fsckSeverityError,
+				Agent: agent, MessageID: fileID, Detail: err.Error(), rel: rel,
+			}
+			if leaf != fsq.MailboxOutboxSent {
+				finding.Repair = &fsckRepair{Action: fsckActionMoveToDLQ}
+			}
+			s.add(finding)
+			continue
+		}
+		id := msg.Header.ID
+		s.knownIDs[id] = true
+		if entry.Name() != id+".md" {
+			s.add(fsckFinding{
+				Code: fsckFilenameIDMismatch, Severity: fsckSeverityError,
+				Agent: agent, MessageID: id, rel: rel,
+				Detail: fmt.Sprintf("filename %s does not match header id %s", entry.Name(), id),
+			})
+		}
+		s.copies[id] = append(s.copies[id], fsckCopy{
+			agent:  agent,
+			box:    box,
+			rel:    rel,
+			to:     msg.Header.To,
+			digest: sha256.Sum256(data),
+		})
+	}
+	return nil
+}
+
+// checkCopies compares every copy of each message id: one inbox copy per
+// agent, identical bytes across recipients, and outbox copies equal to the
+// delivered inbox bytes.
+func (s *fsckScan) checkCopies() {
+	ids := make([]string, 0, len(s.copies))
+	for id := range s.copies {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		copies := s.copies[id]
+		inbox := map[string][]fsckCopy{}
+		var sent []fsckCopy
+		var inboxDigest *[sha256.Size]byte
+		collision := false
+		for i := range copies {
+			c := copies[i]
+			if c.box == "sent" {
+				sent = append(sent, c)
+				continue
+			}
+			inbox[c.agent] = append(inbox[c.agent], c)
+			if inboxDigest == nil {
+				inboxDigest = &copies[i].digest
+			} else if *inboxDigest != c.digest {
+				collision = true
+			}
+		}
+		for agent, agentCopies := range inbox {
+			if len(agentCopies) < 2 {
+				continue
+			}
+			for _, c := range agentCopies {
+				if c.box != fsq.BoxNew {
+					continue
+				}
+				finding := fsckFinding{
+					Code: fsckDuplicateInboxCopy, Severity: fsckSeverityError,
+					Agent: agent, MessageID: id, rel: c.rel,
+					Detail: fmt.Sprintf("message also present in %s inbox/cur", agent),
+				}
+				if fsckIdenticalCur(agentCopies, c) {
+					finding.Detail += " with identical bytes"
+					finding.Repair = &fsckRepair{Action: fsckActionRemoveNewCopy}
+				}
+				s.add(finding)
+			}
+		}
+		if collision {
+			paths := make([]string, 0, len(copies))
+			for _, c := range copies {
+				if c.box != "sent" {
+					paths = append(paths, s.root.DisplayPath(c.rel))
+				}
+			}
+			s.add(fsckFinding{
+				Code: fsckIDCollision, Severity: fsckSeverityError,
+				MessageID: id, rel: copies[0].rel,
+				Detail: "inbox copies with this id differ: " + strings.Join(paths, ", "),
+			})
+		}
+		for _, c := range sent {
+			for _, recipient := range c.to {
+				for _, delivered := range inbox[recipient] {
+					if delivered.digest != c.digest {
+						s.add(fsckFinding{
+							Code: fsckOutboxMismatch, Severity: fsckSeverityError,
+							Agent: c.agent, MessageID: id, rel: c.rel,
+							Detail: fmt.Sprintf("outbox copy differs from delivered %s", s.root.DisplayPath(delivered.rel)),
+						})
+					}
+				}
+			}
+		}
+	}
+}
+
+func fsckIdenticalCur(copies []fsckCopy, candidate fsckCopy) bool {
+	for _, c := range copies {
+		if c.box == fsq.BoxCur && c.digest == candidate.digest {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *fsckScan) scanDLQ(agent string) error {
+	for _, leaf := range []fsq.MailboxLeaf{fsq.MailboxDLQNew, fsq.MailboxDLQCur} {
+		dir := fsq.MailboxRootRelativePath(agent, leaf)
+		entries, err := fsckEntries(s.root, dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			rel := filepath.Join(dir, entry.Name())
+			s.result.Checked.DLQ++
+			envelope, original, err := fsq.ReadDLQEnvelope(s.root, rel)
+			if err != nil {
+				s.add(fsckFinding{
+					Code: fsckDLQEnvelopeInvalid, Severity: fsckSeverityError,
+					Agent: agent, Detail: err.Error(), rel: rel,
+				})
+				continue
+			}
+			s.knownIDs[envelope.OriginalID] = true
+			if envelope.Fix != nil {
+				s.knownIDs[envelope.Fix.RevisionID] = true
+			}
+			if len(bytes.TrimSpace(original)) == 0 {
+				s.add(fsckFinding{
+					Code: fsckDLQOriginalMissing, Severity: fsckSeverityError,
+					Agent: agent, MessageID: envelope.OriginalID, rel: rel,
+					Detail: "envelope embeds no original message",
+				})
+				continue
+			}
+			header, err := format.ParseHeader(original)
+			switch {
+			case err != nil:
+				// Parse failures are why most messages land in the DLQ; only
+				// flag an unreadable original the envelope does not explain.
+				if envelope.FailureReason != "parse_error" && envelope.Fix == nil {
+					s.add(fsckFinding{
+						Code: fsckDLQOriginalInvalid, Severity: fsckSeverityWarn,
+						Agent: agent, MessageID: envelope.OriginalID, rel: rel,
+						Detail: fmt.Sprintf("embedded original does not parse (failure reason %q): %v", envelope.FailureReason, err),
+					})
+				}
+			case envelope.OriginalID != "" && header.ID != envelope.OriginalID:
+				s.add(fsckFinding{
+					Code: fsckDLQOriginalMismatch, Severity: fsckSeverityError,
+					Agent: agent, MessageID: envelope.OriginalID, rel: rel,
+					Detail: fmt.Sprintf("embedded original has id %s", header.ID),
+				})
+			}
+		}
+	}
+	return nil
+}
+
+func (s *fsckScan) scanReceipts(agent string) error {
+	dir := fsq.MailboxRootRelativePath(agent, fsq.MailboxReceipts)
+	entries, err := fsckEntries(s.root, dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		rel := filepath.Join(dir, entry.Name())
+		s.result.Checked.Receipts++
+		data, err := s.root.ReadRegularNoFollow(rel)
+		if err != nil {
+			return err
+		}
+		var r receipt.Receipt
+		if err := json.Unmarshal(data, &r); err != nil || r.MsgID == "" {
+			detail := "receipt has no msg_id"
+			if err != nil {
+				detail = err.Error()
+			}
+			s.add(fsckFinding{
+				Code: fsckReceiptUnparseable, Severity: fsckSeverityError,
+				Agent: agent, Detail: detail, rel: rel,
+			})
+			continue
+		}
+		if !s.knownIDs[r.MsgID] {
+			s.add(fsckFinding{
+				Code: fsckReceiptOrphan, Severity: fsckSeverityWarn,
+				Agent: agent, MessageID: r.MsgID, rel: rel,
+				Detail: fmt.Sprintf("%s receipt references a message not present in any mailbox", r.Stage),
+			})
+		}
+	}
+	return nil
+}
+
+// scanTmp reports tmp leaf files and atomic-write temporaries older than the
+// threshold; younger ones may belong to an in-flight delivery.
+func (s *fsckScan) scanTmp(agent string) error {
+	var candidates []string
+	for _, leaf := range []fsq.MailboxLeaf{fsq.MailboxInboxTmp, fsq.MailboxDLQTmp} {
+		dir := fsq.MailboxRootRelativePath(agent, leaf)
+		entries, err := s.root.ReadDir(dir)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		for _, entry := range entries {
+			if entry.Type().IsRegular() {
+				candidates = append(candidates, filepath.Join(dir, entry.Name()))
+			}
+		}
+	}
+	for _, leaf := range fsq.RequiredMailboxLeaves() {
+		if leaf == fsq.MailboxInboxTmp || leaf == fsq.MailboxDLQTmp {
+			continue
+		}
+		dir := fsq.MailboxRootRelativePath(agent, leaf)
+		entries, err := s.root.ReadDir(dir)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		for _, entry := range entries {
+			if entry.Type().IsRegular() && strings.HasPrefix(entry.Name(), ".") && strings.Contains(entry.Name(), ".tmp-") {
+				candidates = append(candidates, filepath.Join(dir, entry.Name()))
+			}
+		}
+	}
+	for _, rel := range candidates {
+		info, err := s.root.Stat(rel)
+		if err != nil {
+			continue
+		}
+		age := s.now.Sub(info.ModTime())
+		if age < s.tmpAge {
+			continue
+		}
+		s.add(fsckFinding{
+			Code: fsckStaleTmp, Severity: fsckSeverityWarn,
+			Agent: agent, rel: rel,
+			Detail: fmt.Sprintf("orphaned tmp file is %s old", age.Round(time.Second)),
+			Repair: &fsckRepair{Action: fsckActionRemoveTmp},
+		})
+	}
+	return nil
+}
+
+// applyFsckRepairs executes planned repairs in order, recording each outcome
+// on its finding. A failed repair does not stop later ones.
+func applyFsckRepairs(root *fsq.DeliveryRoot, result *fsckResult) {
+	for i := range result.Findings {
+		finding := &result.Findings[i]
+		if finding.Repair == nil {
+			continue
+		}
+		var err error
+		switch finding.Repair.Action {
+		case fsckActionMoveToDLQ:
+			filename := filepath.Base(finding.rel)
+			detail := "amq fsck: " + finding.Detail
+			if filepath.Base(filepath.Dir(finding.rel)) == fsq.BoxCur {
+				_, err = fsq.MoveCurToDLQ(root, finding.Agent, filename, finding.MessageID, "parse_error", detail)
+			} else {
+				_, err = fsq.MoveToDLQ(root, finding.Agent, filename, finding.MessageID, "parse_error", detail)
+			}
+		case fsckActionRemoveNewCopy, fsckActionRemoveTmp:
+			err = root.Remove(finding.rel)
+			if errors.Is(err, os.ErrNotExist) {
+				err = nil
+			}
+		default:
+			err = fmt.Errorf("unknown repair action %q", finding.Repair.Action)
+		}
+		var committed *fsq.CommittedDurabilityError
+		if err != nil && !errors.As(err, &committed) {
+			finding.Repair.Error = err.Error()
+			continue
+		}
+		finding.Repair.Applied = true
+	}
+}
+
+func countOpenFsckFindings(result *fsckResult) int {
+	open := 0
+	for _, finding := range result.Findings {
+		if finding.Repair == nil || !finding.Repair.Applied {
+			open++
+		}
+	}
+	return open
+}
+
+func printFsckResult(result *fsckResult) error {
+	checked := result.Checked
+	if err := writeStdout("Checked %d agent(s): %d message(s), %d DLQ envelope(s), %d receipt(s)\n",
+		checked.Agents, checked.Messages, checked.DLQ, checked.Receipts); err != nil {
+		return err
+	}
+	if len(result.Findings) == 0 {
+		return writeStdoutLine("No findings.")
+	}
+	planned := 0
+	for _, finding := range result.Findings {
+		line := fmt.Sprintf("[%s] %s %s: %s", finding.Severity, finding.Code, finding.Path, finding.Detail)
+		if finding.Repair != nil {
+			planned++
+			switch {
+			case finding.Repair.Applied:
+				line += fmt.Sprintf(" (repaired: %s)", finding.Repair.Action)
+			case finding.Repair.Error != "":
+				line += fmt.Sprintf(" (repair %s failed: %s)", finding.Repair.Action, finding.Repair.Error)
+			default:
+				line += fmt.Sprintf(" (plan: %s)", finding.Repair.Action)
+			}
+		}
+		if err := writeStdoutLine(line); err != nil {
+			return err
+		}
+	}
+	if !result.Fix && planned > 0 {
+		return writeStdout("%d repair(s) planned; re-run with --fix to apply.\n", planned)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+)
+
+func fsckFindingCodes(result fsckResult) map[string]fsckFinding {
+	codes := map[string]fsckFinding{}
+	for _, finding := range result.Findings {
+		codes[finding.Code] = finding
+	}
+	return codes
+}
+
+func runFsckJSONForTest(t *testing.T, args ...string) (fsckResult, error) {
+	t.Helper()
+	stdout, _, err := captureEnvOutput(t, func() error {
+		return runFsck(append([]string{"--json"}, args...))
+	})
+	var result fsckResult
+	if jsonErr := unmarshalJSONOutput(stdout, &result); jsonErr != nil {
+		t.Fatalf("fsck output %q: %v (run err %v)", stdout, jsonErr, err)
+	}
+	return result, err
+}
+
+func TestFsckReportsContentFindingsAndRepairsConservatively(t *testing.T) {
+	root := initializedSendMailboxRoot(t, "alice", "bob")
+	if _, _, err := captureEnvOutput(t, func() error {
+		return runSend([]string{"--root", root, "--me", "alice", "--to", "bob", "--body", "hello"})
+	}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	clean, err := runFsckJSONForTest(t, "--root", root)
+	if err != nil || !clean.Clean || len(clean.Findings) != 0 {
+		t.Fatalf("fresh root fsck = %+v, %v; want clean", clean, err)
+	}
+
+	bobNew := fsq.AgentInboxNew(root, "bob")
+	entries, err := os.ReadDir(bobNew)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("bob inbox = %v, %v", entries, err)
+	}
+	delivered := entries[0].Name()
+	data, err := os.ReadFile(filepath.Join(bobNew, delivered))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Duplicate consumed copy, tampered outbox copy, broken inbox message,
+	// stale tmp file, and a receipt for a message that never existed.
+	if err := os.WriteFile(filepath.Join(fsq.AgentInboxCur(root, "bob"), delivered), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(fsq.AgentOutboxSent(root, "alice"), delivered), append(data, "tampered"...), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(bobNew, "broken.md"), []byte("missing frontmatter"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tmpPath := filepath.Join(fsq.AgentInboxTmp(root, "bob"), "orphan.md")
+	if err := os.WriteFile(tmpPath, []byte("partial"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(tmpPath, old, old); err != nil {
+		t.Fatal(err)
+	}
+	receiptPath := filepath.Join(fsq.AgentReceipts(root, "bob"), "ghost__bob__drained.json")
+	if err := os.WriteFile(receiptPath, []byte(`{"schema":1,"msg_id":"ghost","consumer":"bob","stage":"drained"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := runFsckJSONForTest(t, "--root", root)
+	if code := GetExitCode(err); code != ExitError {
+		t.Fatalf("dry-run exit = %d (%v), want %d", code, err, ExitError)
+	}
+	codes := fsckFindingCodes(plan)
+	for _, code := range []string{fsckDuplicateInboxCopy, fsckOutboxMismatch, fsckUnparseableMessage, fsckStaleTmp, fsckReceiptOrphan} {
+		if _, ok := codes[code]; !ok {
+			t.Fatalf("missing %s finding in %+v", code, plan.Findings)
+		}
+	}
+	if codes[fsckDuplicateInboxCopy].Repair == nil || codes[fsckDuplicateInboxCopy].Repair.Applied {
+		t.Fatalf("dry run should plan but not apply duplicate repair: %+v", codes[fsckDuplicateInboxCopy])
+	}
+	if _, err := os.Stat(tmpPath); err != nil {
+		t.Fatalf("dry run removed tmp file: %v", err)
+	}
+
+	fixed, err := runFsckJSONForTest(t, "--root", root, "--fix")
+	if GetExitCode(err) != ExitError {
+		t.Fatalf("fix exit = %v, want unrepairable findings to remain", err)
+	}
+	for _, finding := range fixed.Findings {
+		if finding.Repair != nil && !finding.Repair.Applied {
+			t.Fatalf("repair not applied: %+v", finding)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(bobNew, delivered)); !os.IsNotExist(err) {
+		t.Fatalf("duplicate new copy still present: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(bobNew, "broken.md")); !os.IsNotExist(err) {
+		t.Fatalf("unparseable message not moved to DLQ: %v", err)
+	}
+	dlqEntries, err := os.ReadDir(fsq.AgentDLQNew(root, "bob"))
+	if err != nil || len(dlqEntries) != 1 {
+		t.Fatalf("bob DLQ = %v, %v; want one envelope", dlqEntries, err)
+	}
+
+	after, _ := runFsckJSONForTest(t, "--root", root)
+	codes = fsckFindingCodes(after)
+	if len(after.Findings) != 2 || codes[fsckOutboxMismatch].Code == "" || codes[fsckReceiptOrphan].Code == "" {
+		t.Fatalf("after fix findings = %+v; want only report-only findings", after.Findings)
+	}
+}
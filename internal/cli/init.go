@@ -56,6 +56,8 @@ func runInit(args []string) error {
 		return err
 	}
 
+	registerProjectOwning(root)
+
 	// Update .gitignore (creates if needed)
 	ensureGitignore(root)
 
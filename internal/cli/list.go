@@ -202,7 +202,7 @@ func runList(args []string) error {
 	if err != nil {
 		return err
 	}
-	validator.allowLegacyFlagHandles = legacyInspection
+	validator.AllowLegacyFlagHandles = legacyInspection
 
 	box := "new"
 	if *newFlag && *curFlag {
@@ -262,7 +262,7 @@ func runList(args []string) error {
 			}
 			continue
 		}
-		if err := validator.validate(header); err != nil {
+		if err := validator.Validate(header); err != nil {
 			if err := writeStderr("warning: skipping invalid message %s: %v\n", entry.Name(), err); err != nil {
 				return err
 			}
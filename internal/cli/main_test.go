@@ -63,6 +63,13 @@ func TestMain(m *testing.M) {
 		os.Exit(1)
 	}
 
+	// Keep setup and coop init from registering test projects in the
+	// developer's real project registry.
+	if err := os.Setenv(envProjectRegistry, filepath.Join(tempRoot, "projects.json")); err != nil {
+		_ = os.RemoveAll(tempRoot)
+		_, _ = fmt.Fprintf(os.Stderr, "isolate project registry: %v\n", err)
+		os.Exit(1)
+	}
 	cliSecureTempRoot = tempRoot
 	exitCode := m.Run()
 	cliSecureTempRoot = ""
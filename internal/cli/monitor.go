@@ -38,25 +38,42 @@ func runMonitor(args []string) error {
 	peekFlag := fs.Bool("peek", false, "Peek without moving messages to cur")
 	sessionFlag := fs.String("session", "", "Target session under the resolved base root")
 	ignoreSessionPinFlag := fs.Bool("ignore-session-pin", false, "With explicit --root, ignore a conflicting AM_SESSION pin")
+	agentsFlag := fs.String("agents", "", "Monitor these agents' inboxes instead of --me (comma-separated)")
+	allFlag := fs.Bool("all", false, "Monitor every agent mailbox in the root instead of --me")
 
-	usage := usageWithFlags(fs, "amq monitor --me <agent> [--session <name>] [options]",
+	usage := usageWithFlags(fs, "amq monitor (--me <agent> | --agents <a,b,...> | --all) [--session <name>] [options]",
 		"Combined watch+drain: waits for messages, drains them, outputs structured payload.",
 		"Use --peek to watch without moving messages to cur (no ack).",
-		"Ideal for co-op mode background watchers in Claude Code or Codex.")
+		"Ideal for co-op mode background watchers in Claude Code or Codex.",
+		"With --agents or --all, one process follows several inboxes; each agent is",
+		"drained as itself (own receipts, --limit per agent) and reported separately.")
 	if handled, err := parseFlags(fs, args, usage); err != nil {
 		return err
 	} else if handled {
 		return nil
 	}
-	if err := requireMe(common.Me); err != nil {
+	multi, err := multiAgentRequested(*agentsFlag, flagWasVisited(fs, "agents"), *allFlag)
+	if err != nil {
 		return err
 	}
+	if !multi {
+		if err := requireMe(common.Me); err != nil {
+			return err
+		}
+	}
 	if *timeoutFlag < 0 {
 		return UsageError("--timeout must be >= 0")
 	}
 	if *limitFlag < 0 {
 		return UsageError("--limit must be >= 0")
 	}
+	mode := "drain"
+	if *peekFlag {
+		mode = "peek"
+	}
+	if multi {
+		return runMonitorAgents(common, *sessionFlag, *ignoreSessionPinFlag, *agentsFlag, *allFlag, *timeoutFlag, *pollFlag, *includeBodyFlag, *limitFlag, mode)
+	}
 	me, err := normalizeHandle(common.Me)
 	if err != nil {
 		return UsageError("--me: %v", err)
@@ -84,6 +101,9 @@ func runMonitor(args []string) error {
 	if err := requireMailboxDeliveryRoot(deliveryRoot, root, me); err != nil {
 		return err
 	}
+	if err := refuseQueueHandle(deliveryRoot, "monitor", me); err != nil {
+		return err
+	}
 
 	if err := validateKnownHandlesDeliveryRoot(deliveryRoot, common.Strict, me); err != nil {
 		return err
@@ -104,11 +124,6 @@ func runMonitor(args []string) error {
 
 	session := resolveSessionName(root)
 
-	mode := "drain"
-	if *peekFlag {
-		mode = "peek"
-	}
-
 	// First, try to drain existing messages
 	items, err := monitorInboxItems(
 		deliveryRoot,
@@ -285,6 +300,17 @@ func monitorWithFsnotifyProbe(
 	inboxNewDisplay string,
 	hasMessages func() (bool, error),
 	revalidateContext func() error,
+) (string, error) {
+	return monitorDirsWithFsnotifyProbe(ctx, []string{inboxNewDisplay}, hasMessages, revalidateContext)
+}
+
+// monitorDirsWithFsnotifyProbe waits on one fsnotify watcher over every
+// inbox/new directory in inboxNewDisplays; hasMessages probes all of them.
+func monitorDirsWithFsnotifyProbe(
+	ctx context.Context,
+	inboxNewDisplays []string,
+	hasMessages func() (bool, error),
+	revalidateContext func() error,
 ) (string, error) {
 	if err := revalidateContext(); err != nil {
 		return "", err
@@ -295,8 +321,12 @@ func monitorWithFsnotifyProbe(
 	}
 	defer func() { _ = watcher.Close() }()
 
-	if err := watcher.Add(inboxNewDisplay); err != nil {
-		return monitorWithPollingProbe(ctx, hasMessages, revalidateContext)
+	watchedDirs := make(map[string]bool, len(inboxNewDisplays))
+	for _, dir := range inboxNewDisplays {
+		if err := watcher.Add(dir); err != nil {
+			return monitorWithPollingProbe(ctx, hasMessages, revalidateContext)
+		}
+		watchedDirs[filepath.Clean(dir)] = true
 	}
 
 	// Check for existing messages AFTER setting up watcher to avoid race condition
@@ -337,7 +367,7 @@ func monitorWithFsnotifyProbe(
 				return "", err
 			}
 			if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 &&
-				filepath.Clean(event.Name) == filepath.Clean(inboxNewDisplay) {
+				watchedDirs[filepath.Clean(event.Name)] {
 				return "", os.ErrNotExist
 			}
 			if event.Op&(fsnotify.Create|fsnotify.Rename) != 0 {
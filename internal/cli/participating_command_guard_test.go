@@ -41,6 +41,15 @@ func TestParticipatingCommandsRefusePinnedRootWhenCwdHasRepoLocalQueue(t *testin
 		{"dlq purge", "dlq purge", func() error {
 			return runDLQPurge([]string{"--me", "alice", "--yes"})
 		}},
+		{"dlq fix", "dlq fix", func() error {
+			return runDLQFix([]string{"--me", "alice", "--id", "missing", "--set", `{"kind":"question"}`})
+		}},
+		{"breaker reset", "breaker reset", func() error {
+			return runBreakerReset([]string{"--thread", "p2p/alice__bob", "--discard"})
+		}},
+		{"fsck", "fsck", func() error {
+			return runFsck([]string{"--fix"})
+		}},
 	}
 
 	for _, test := range tests {
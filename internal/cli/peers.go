@@ -0,0 +1,303 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type peerListEntry struct {
+	Name     string `json:"name"`
+	Root     string `json:"root"`
+	Source   string `json:"source"`
+	Status   string `json:"status"`
+	Shadowed bool   `json:"shadowed,omitempty"`
+}
+
+type peerListResult struct {
+	Registry string          `json:"registry"`
+	Peers    []peerListEntry `json:"peers"`
+}
+
+type peerPruneResult struct {
+	Registry string          `json:"registry"`
+	DryRun   bool            `json:"dry_run"`
+	Pruned   []peerListEntry `json:"pruned"`
+}
+
+func runPeers(args []string) error {
+	if len(args) == 0 || isHelp(args[0]) {
+		return printGroupUsage(findCommand("peers"))
+	}
+
+	switch args[0] {
+	case "list":
+		return runPeersList(args[1:])
+	case "add":
+		return runPeersAdd(args[1:])
+	case "rm":
+		return runPeersRm(args[1:])
+	case "prune":
+		return runPeersPrune(args[1:])
+	default:
+		return formatUnknownSubcommand("peers", args[0])
+	}
+}
+
+func runPeersList(args []string) error {
+	fs := flag.NewFlagSet("peers list", flag.ContinueOnError)
+	jsonFlag := fs.Bool("json", false, "Emit JSON output")
+	usage := usageWithFlags(fs, "amq peers list [--json]",
+		"Lists peer projects from the current .amqrc and the user-level project registry.",
+		".amqrc peers take precedence; a registry entry with the same name is marked shadowed.",
+	)
+	positional, handled, err := parsePeersArgs(fs, args, usage)
+	if err != nil || handled {
+		return err
+	}
+	if len(positional) > 0 {
+		return UsageError("unexpected argument %q", positional[0])
+	}
+
+	registry, path, err := loadProjectRegistry()
+	if err != nil {
+		return err
+	}
+	result := peerListResult{Registry: path, Peers: []peerListEntry{}}
+	configured := map[string]bool{}
+	if rc, rcErr := findAndLoadAmqrc(); rcErr == nil {
+		names := make([]string, 0, len(rc.Config.Peers))
+		for name := range rc.Config.Peers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			root, pathErr := resolvePeerPath(rc, rc.Config.Peers[name])
+			if pathErr != nil {
+				root = rc.Config.Peers[name]
+			}
+			configured[name] = true
+			result.Peers = append(result.Peers, peerListEntry{
+				Name: name, Root: root, Source: peerSourceAmqrc, Status: peerRootStatus(root),
+			})
+		}
+	}
+	for _, name := range sortedRegistryNames(registry) {
+		root := registry.Projects[name].Root
+		result.Peers = append(result.Peers, peerListEntry{
+			Name: name, Root: root, Source: peerSourceRegistry, Status: peerRootStatus(root), Shadowed: configured[name],
+		})
+	}
+
+	if *jsonFlag {
+		return writeJSON(os.Stdout, result)
+	}
+	if len(result.Peers) == 0 {
+		return writeStdoutLine("No peers configured. Run 'amq setup' in a project or 'amq peers add <name> <root>'.")
+	}
+	for _, peer := range result.Peers {
+		note := ""
+		if peer.Shadowed {
+			note = " (shadowed by .amqrc)"
+		}
+		if err := writeStdout("%-20s %-8s %-8s %s%s\n", peer.Name, peer.Source, peer.Status, peer.Root, note); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runPeersAdd(args []string) error {
+	fs := flag.NewFlagSet("peers add", flag.ContinueOnError)
+	jsonFlag := fs.Bool("json", false, "Emit JSON output")
+	usage := usageWithFlags(fs, "amq peers add <name> <root> [--json]",
+		"Registers a peer project in the user-level project registry.",
+		"<root> is the peer's base AMQ root, or a project directory whose .amqrc selects it.",
+	)
+	positional, handled, err := parsePeersArgs(fs, args, usage)
+	if err != nil || handled {
+		return err
+	}
+	if len(positional) != 2 {
+		return UsageError("usage: amq peers add <name> <root>")
+	}
+	name := positional[0]
+	if err := validateRegistryProjectName(name); err != nil {
+		return UsageError("%v", err)
+	}
+	root, err := peerAddRoot(positional[1])
+	if err != nil {
+		return err
+	}
+	previous, err := registerProject(name, root)
+	if err != nil {
+		return err
+	}
+	entry := peerListEntry{Name: name, Root: root, Source: peerSourceRegistry, Status: peerRootStatus(root)}
+	if *jsonFlag {
+		return writeJSON(os.Stdout, entry)
+	}
+	if previous != "" {
+		return writeStdout("Updated peer %s: %s (was %s)\n", name, root, previous)
+	}
+	return writeStdout("Registered peer %s: %s\n", name, root)
+}
+
+func runPeersRm(args []string) error {
+	fs := flag.NewFlagSet("peers rm", flag.ContinueOnError)
+	jsonFlag := fs.Bool("json", false, "Emit JSON output")
+	usage := usageWithFlags(fs, "amq peers rm <name> [--json]",
+		"Removes a peer project from the user-level project registry.",
+		".amqrc peers are edited in .amqrc, not here.",
+	)
+	positional, handled, err := parsePeersArgs(fs, args, usage)
+	if err != nil || handled {
+		return err
+	}
+	if len(positional) != 1 {
+		return UsageError("usage: amq peers rm <name>")
+	}
+	name := positional[0]
+	var removed projectRegistryEntry
+	found := false
+	if err := updateProjectRegistry(func(registry *projectRegistry) (bool, error) {
+		removed, found = registry.Projects[name]
+		delete(registry.Projects, name)
+		return found, nil
+	}); err != nil {
+		return err
+	}
+	if !found {
+		return NotFoundError("peer %q is not in the project registry", name)
+	}
+	entry := peerListEntry{Name: name, Root: removed.Root, Source: peerSourceRegistry, Status: peerRootStatus(removed.Root)}
+	if *jsonFlag {
+		return writeJSON(os.Stdout, entry)
+	}
+	return writeStdout("Removed peer %s (%s)\n", name, removed.Root)
+}
+
+func runPeersPrune(args []string) error {
+	fs := flag.NewFlagSet("peers prune", flag.ContinueOnError)
+	dryRunFlag := fs.Bool("dry-run", false, "Report stale entries without removing them")
+	jsonFlag := fs.Bool("json", false, "Emit JSON output")
+	usage := usageWithFlags(fs, "amq peers prune [--dry-run] [--json]",
+		"Removes project registry entries whose base root no longer exists.",
+		"Unreadable roots are kept; they may be a transient permission problem.",
+	)
+	positional, handled, err := parsePeersArgs(fs, args, usage)
+	if err != nil || handled {
+		return err
+	}
+	if len(positional) > 0 {
+		return UsageError("unexpected argument %q", positional[0])
+	}
+
+	path, err := projectRegistryPath()
+	if err != nil {
+		return err
+	}
+	result := peerPruneResult{Registry: path, DryRun: *dryRunFlag, Pruned: []peerListEntry{}}
+	if err := updateProjectRegistry(func(registry *projectRegistry) (bool, error) {
+		for _, name := range sortedRegistryNames(*registry) {
+			root := registry.Projects[name].Root
+			status := peerRootStatus(root)
+			if status != "missing" && status != "not_a_directory" {
+				continue
+			}
+			result.Pruned = append(result.Pruned, peerListEntry{Name: name, Root: root, Source: peerSourceRegistry, Status: status})
+			if !*dryRunFlag {
+				delete(registry.Projects, name)
+			}
+		}
+		return !*dryRunFlag && len(result.Pruned) > 0, nil
+	}); err != nil {
+		return err
+	}
+
+	if *jsonFlag {
+		return writeJSON(os.Stdout, result)
+	}
+	if len(result.Pruned) == 0 {
+		return writeStdoutLine("No stale peers.")
+	}
+	verb := "Pruned"
+	if *dryRunFlag {
+		verb = "Would prune"
+	}
+	for _, peer := range result.Pruned {
+		if err := writeStdout("%s %s (%s)\n", verb, peer.Name, peer.Root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parsePeersArgs parses flags interspersed with positional arguments.
+func parsePeersArgs(fs *flag.FlagSet, args []string, usage func()) ([]string, bool, error) {
+	var positional []string
+	for {
+		if handled, err := parseFlagsAllowPositionals(fs, args, usage); err != nil || handled {
+			return nil, handled, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, false, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// peerAddRoot accepts either a base root or a project directory containing an
+// .amqrc, and returns the absolute base root.
+func peerAddRoot(raw string) (string, error) {
+	abs, err := filepath.Abs(strings.TrimSpace(raw))
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", NotFoundError("peer root %s: %v", abs, err)
+	}
+	if !info.IsDir() {
+		return "", UsageError("peer root %s is not a directory", abs)
+	}
+	rcPath := filepath.Join(abs, ".amqrc")
+	if _, err := amqrcLstat(rcPath); err != nil {
+		return abs, nil
+	}
+	if err := validateAmqrcFile(rcPath); err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(rcPath)
+	if err != nil {
+		return "", fmt.Errorf("cannot read .amqrc at %s: %w", rcPath, err)
+	}
+	var rc amqrc
+	if err := json.Unmarshal(data, &rc); err != nil {
+		return "", fmt.Errorf("invalid .amqrc at %s: %w", rcPath, err)
+	}
+	if strings.TrimSpace(rc.Root) == "" {
+		return abs, nil
+	}
+	return resolvePeerPath(amqrcResult{Config: rc, Dir: abs, Path: rcPath}, rc.Root)
+}
+
+func peerRootStatus(root string) string {
+	info, err := os.Stat(root)
+	switch {
+	case err == nil && info.IsDir():
+		return "ok"
+	case err == nil:
+		return "not_a_directory"
+	case os.IsNotExist(err):
+		return "missing"
+	default:
+		return "unreadable"
+	}
+}
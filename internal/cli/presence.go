@@ -11,13 +11,17 @@ import (
 )
 
 type presenceListItem struct {
-	Schema             int    `json:"schema,omitempty"`
-	Handle             string `json:"handle"`
-	Status             string `json:"status"`
-	LastSeen           string `json:"last_seen,omitempty"`
-	Note               string `json:"note,omitempty"`
-	Kind               string `json:"kind"`
-	PresenceApplicable bool   `json:"presence_applicable"`
+	Schema             int      `json:"schema,omitempty"`
+	Handle             string   `json:"handle"`
+	Status             string   `json:"status"`
+	LastSeen           string   `json:"last_seen,omitempty"`
+	DerivedStatus      string   `json:"derived_status,omitempty"`
+	Note               string   `json:"note,omitempty"`
+	Capabilities       []string `json:"capabilities,omitempty"`
+	Delegate           string   `json:"delegate,omitempty"`
+	DelegateUntil      string   `json:"delegate_until,omitempty"`
+	Kind               string   `json:"kind"`
+	PresenceApplicable bool     `json:"presence_applicable"`
 }
 
 func runPresence(args []string) error {
@@ -29,6 +33,8 @@ func runPresence(args []string) error {
 		return runPresenceSet(args[1:])
 	case "list":
 		return runPresenceList(args[1:])
+	case "watch":
+		return runPresenceWatch(args[1:])
 	default:
 		return formatUnknownSubcommand("presence", args[0])
 	}
@@ -39,7 +45,11 @@ func runPresenceSet(args []string) error {
 	common := addCommonFlags(fs)
 	statusFlag := fs.String("status", "", "Status string")
 	noteFlag := fs.String("note", "", "Optional note")
-	usage := usageWithFlags(fs, "amq presence set --me <agent> --status <status> [options]")
+	capabilitiesFlag := fs.String("capabilities", "", "Comma-separated capability tags for --to-capability routing (empty clears; omitted keeps current)")
+	delegateFlag := fs.String("delegate", "", "Forward new mail to this handle while away (cleared when you next send, reply, drain, or claim)")
+	untilFlag := fs.String("until", "", "End the delegation at this RFC 3339 time or after this duration (e.g. 2h)")
+	delegateModeFlag := fs.String("delegate-mode", presence.DelegateCopy, "copy (keep the original too) or move (delegate only)")
+	usage := usageWithFlags(fs, "amq presence set --me <agent> --status <status> [--capabilities <tags>] [--delegate <handle> [--until <time|duration>]] [options]")
 	if handled, err := parseFlags(fs, args, usage); err != nil {
 		return err
 	} else if handled {
@@ -64,7 +74,16 @@ func runPresenceSet(args []string) error {
 	if status == "" {
 		return UsageError("--status is required")
 	}
-	p := presence.New(common.Me, status, strings.TrimSpace(*noteFlag), time.Now())
+	now := time.Now()
+	p := presence.New(common.Me, status, strings.TrimSpace(*noteFlag), now)
+	if err := applyPresenceDelegate(root, common.Strict, &p, *delegateFlag, *untilFlag, *delegateModeFlag, flagWasVisited(fs, "until") || flagWasVisited(fs, "delegate-mode"), now); err != nil {
+		return err
+	}
+	if flagWasVisited(fs, "capabilities") {
+		p.Capabilities = dedupeStrings(splitList(strings.ToLower(*capabilitiesFlag)))
+	} else if existing, err := presence.Read(root, common.Me); err == nil {
+		p.Capabilities = existing.Capabilities
+	}
 	if err := presence.Write(root, p); err != nil {
 		return err
 	}
@@ -74,6 +93,60 @@ func runPresenceSet(args []string) error {
 	if err := writeStdout("Presence updated for %s\n", common.Me); err != nil {
 		return err
 	}
+	if p.Delegate != "" {
+		until := "you are back"
+		if p.DelegateUntil != "" {
+			until = p.DelegateUntil
+		}
+		if err := writeStdout("New mail is forwarded (%s) to %s until %s\n", p.DelegateMode, p.Delegate, until); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyPresenceDelegate validates the delegation flags of presence set and
+// records them on p. delegationFlagsSet reports --until or --delegate-mode,
+// which only make sense with --delegate.
+func applyPresenceDelegate(root string, strict bool, p *presence.Presence, rawDelegate, rawUntil, rawMode string, delegationFlagsSet bool, now time.Time) error {
+	if strings.TrimSpace(rawDelegate) == "" {
+		if delegationFlagsSet {
+			return UsageError("--until and --delegate-mode require --delegate")
+		}
+		return nil
+	}
+	delegate, err := normalizeHandle(rawDelegate)
+	if err != nil {
+		return UsageError("--delegate: %v", err)
+	}
+	if delegate == p.Handle {
+		return UsageError("--delegate must name another agent")
+	}
+	if err := validateKnownHandles(root, strict, delegate); err != nil {
+		return err
+	}
+	mode := strings.TrimSpace(rawMode)
+	if mode != presence.DelegateCopy && mode != presence.DelegateMove {
+		return UsageError("--delegate-mode must be %s or %s", presence.DelegateCopy, presence.DelegateMove)
+	}
+	p.Delegate = delegate
+	p.DelegateMode = mode
+	p.DelegateSince = now.UTC().Format(time.RFC3339Nano)
+	if until := strings.TrimSpace(rawUntil); until != "" {
+		if d, err := time.ParseDuration(until); err == nil {
+			if d <= 0 {
+				return UsageError("--until duration must be > 0")
+			}
+			p.DelegateUntil = now.Add(d).UTC().Format(time.RFC3339Nano)
+		} else if t, err := time.Parse(time.RFC3339, until); err == nil {
+			if !t.After(now) {
+				return UsageError("--until must be in the future")
+			}
+			p.DelegateUntil = t.UTC().Format(time.RFC3339Nano)
+		} else {
+			return UsageError("--until must be an RFC 3339 time or a duration like 2h")
+		}
+	}
 	return nil
 }
 
@@ -100,6 +173,8 @@ func runPresenceList(args []string) error {
 		}
 	}
 
+	thresholds := presenceThresholds(root)
+	now := time.Now()
 	items := make([]presenceListItem, 0, len(agents))
 	for _, raw := range agents {
 		agent, err := normalizeHandle(raw)
@@ -135,7 +210,9 @@ func runPresenceList(args []string) error {
 			}
 			return err
 		}
-		items = append(items, presenceListItemFromPresence(p, "agent", true))
+		item := presenceListItemFromPresence(p, "agent", true)
+		item.DerivedStatus = deriveAgentPresence(root, agent, thresholds, now).Status
+		items = append(items, item)
 	}
 
 	if common.JSON {
@@ -159,9 +236,23 @@ func runPresenceList(args []string) error {
 			}
 			continue
 		}
-		if err := writeStdout("%s  %s  %s\n", item.Handle, item.Status, item.LastSeen); err != nil {
+		if err := writeStdout("%s  %s  %s  %s\n", item.Handle, item.Status, item.LastSeen, item.DerivedStatus); err != nil {
 			return err
 		}
+		if item.Delegate != "" {
+			line := "  delegating to " + item.Delegate
+			if item.DelegateUntil != "" {
+				line += " until " + item.DelegateUntil
+			}
+			if err := writeStdoutLine(line); err != nil {
+				return err
+			}
+		}
+		if len(item.Capabilities) > 0 {
+			if err := writeStdout("  capabilities: %s\n", strings.Join(item.Capabilities, ", ")); err != nil {
+				return err
+			}
+		}
 		if item.Note != "" {
 			if err := writeStdout("  %s\n", item.Note); err != nil {
 				return err
@@ -182,6 +273,9 @@ func presenceListItemFromPresence(p presence.Presence, kind string, presenceAppl
 		Status:             status,
 		LastSeen:           p.LastSeen,
 		Note:               p.Note,
+		Capabilities:       p.Capabilities,
+		Delegate:           p.Delegate,
+		DelegateUntil:      p.DelegateUntil,
 		Kind:               kind,
 		PresenceApplicable: presenceApplicable,
 	}
@@ -1,8 +1,10 @@
 package cli
 
+import "github.com/avivsinai/agent-message-queue/internal/delivery"
+
 const (
-	presenceSourceNotifierLive   = "notifier_live"
-	presenceSourceRecentActivity = "recent_activity"
+	presenceSourceNotifierLive   = delivery.SourceNotifierLive
+	presenceSourceRecentActivity = delivery.SourceRecentActivity
 )
 
 func resolvePresenceSource(root, agent string, recentActivity bool) string {
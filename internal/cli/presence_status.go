@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/config"
+	"github.com/avivsinai/agent-message-queue/internal/presence"
+)
+
+// agentPresenceState is one agent's presence together with the derived
+// status every command reports for it.
+type agentPresenceState struct {
+	Presence *presence.Presence
+	Source   string
+	Status   string
+	Age      time.Duration
+	HasAge   bool
+}
+
+// presenceThresholds returns root's derived-presence thresholds. Session roots
+// without their own config.json use the base root's, like doctor --ops. An
+// unreadable or invalid config falls back to the defaults with a warning, as
+// doctor --ops does, so one bad root never hides everyone's presence.
+func presenceThresholds(root string) config.PresenceThresholds {
+	cfg, err := loadOpsConfig(root, false)
+	if err != nil && !os.IsNotExist(err) {
+		err = fmt.Errorf("read presence config: %w", err)
+	} else {
+		var thresholds config.PresenceThresholds
+		if thresholds, err = cfg.Presence.Thresholds(); err == nil {
+			return thresholds
+		}
+	}
+	_ = writeStderr("warning: using default presence thresholds for %s: %v\n", root, err)
+	thresholds, _ := (*config.PresenceConfig)(nil).Thresholds()
+	return thresholds
+}
+
+// deriveAgentPresence reads agent's presence in root and derives its status.
+// Presence younger than the away threshold counts as recent activity; a
+// verified wake lock counts as a live notifier regardless of age.
+func deriveAgentPresence(root, agent string, thresholds config.PresenceThresholds, now time.Time) agentPresenceState {
+	var state agentPresenceState
+	recentActivity := false
+	if p, err := presence.Read(root, agent); err == nil {
+		state.Presence = &p
+		if seen, ok := p.LastSeenTime(); ok {
+			state.Age = now.Sub(seen)
+			state.HasAge = true
+			recentActivity = state.Age < thresholds.Away
+		}
+	}
+	state.Source = resolvePresenceSource(root, agent, recentActivity)
+	state.Status = presence.Derive(state.Presence, state.Source == presenceSourceNotifierLive, now, thresholds)
+	return state
+}
@@ -0,0 +1,250 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/config"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+	"github.com/avivsinai/agent-message-queue/internal/presence"
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	presenceWatchInitial = "initial"
+	presenceWatchChanged = "changed"
+	presenceWatchRemoved = "removed"
+)
+
+// presenceWatchEvent is one line of amq presence watch output.
+type presenceWatchEvent struct {
+	Event          string             `json:"event"`
+	At             string             `json:"at"`
+	Handle         string             `json:"handle"`
+	DerivedStatus  string             `json:"derived_status"`
+	PreviousStatus string             `json:"previous_status,omitempty"`
+	PresenceSource string             `json:"presence_source,omitempty"`
+	Presence       *presence.Presence `json:"presence,omitempty"`
+}
+
+func runPresenceWatch(args []string) error {
+	fs := flag.NewFlagSet("presence watch", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	timeoutFlag := fs.Duration("timeout", 0, "Stop after this long (0 = until interrupted)")
+	intervalFlag := fs.Duration("interval", 15*time.Second, "How often to re-derive status as presence ages")
+	pollFlag := fs.Bool("poll", false, "Use polling instead of fsnotify (for network filesystems)")
+	usage := usageWithFlags(fs, "amq presence watch [--json] [options]",
+		"Stream presence changes for every agent in the root.",
+		"",
+		"Prints the current presence of each agent, then one line (one JSON object",
+		"per line with --json) whenever an agent's presence.json changes or its",
+		"derived status (active, idle, away, stale, offline) crosses a threshold.",
+		"Refreshes that only move last_seen are not reported.",
+		"",
+		"Press Ctrl+C to stop.")
+	if handled, err := parseFlags(fs, args, usage); err != nil {
+		return err
+	} else if handled {
+		return nil
+	}
+	if *timeoutFlag < 0 {
+		return UsageError("--timeout must be >= 0")
+	}
+	if *intervalFlag <= 0 {
+		return UsageError("--interval must be > 0")
+	}
+	root := resolveRoot(common.Root)
+	thresholds := presenceThresholds(root)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if *timeoutFlag > 0 {
+		ctx, cancel = context.WithTimeout(ctx, *timeoutFlag)
+		defer cancel()
+	}
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+	go func() {
+		select {
+		case <-sigCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	err := watchPresence(ctx, root, thresholds, *intervalFlag, *pollFlag, func(event presenceWatchEvent) error {
+		return writePresenceWatchEvent(common.JSON, event)
+	})
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return nil
+	}
+	return err
+}
+
+// watchPresence reports the presence of every agent in root, then each change
+// until ctx ends. fsnotify on agents/ and agents/<handle>/ catches presence
+// writes promptly; the interval rescan catches status that degrades with age
+// and is the only trigger in polling mode.
+func watchPresence(ctx context.Context, root string, thresholds config.PresenceThresholds, interval time.Duration, poll bool, emit func(presenceWatchEvent) error) error {
+	agentsDir := filepath.Join(root, "agents")
+	var watcher *fsnotify.Watcher
+	if !poll {
+		if w, err := fsnotify.NewWatcher(); err == nil {
+			defer func() { _ = w.Close() }()
+			if err := w.Add(agentsDir); err == nil {
+				watcher = w
+			}
+		}
+	}
+	watched := map[string]bool{}
+	last := map[string]string{}
+	lastStatus := map[string]string{}
+	first := true
+
+	scan := func() error {
+		handles, err := fsq.ListAgents(root)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		now := time.Now()
+		present := map[string]bool{}
+		for _, handle := range handles {
+			if handle == reservedHumanHandle || fsq.ValidateHandle(handle) != nil {
+				continue
+			}
+			if watcher != nil && !watched[handle] {
+				if err := watcher.Add(filepath.Join(agentsDir, handle)); err == nil {
+					watched[handle] = true
+				}
+			}
+			state := deriveAgentPresence(root, handle, thresholds, now)
+			if state.Presence == nil {
+				continue
+			}
+			present[handle] = true
+			key := presenceWatchKey(state)
+			previous, known := last[handle]
+			if known && previous == key {
+				continue
+			}
+			event := presenceWatchEvent{
+				Event:          presenceWatchChanged,
+				At:             now.UTC().Format(time.RFC3339Nano),
+				Handle:         handle,
+				DerivedStatus:  state.Status,
+				PresenceSource: state.Source,
+				Presence:       state.Presence,
+			}
+			if first {
+				event.Event = presenceWatchInitial
+			}
+			if known && lastStatus[handle] != state.Status {
+				event.PreviousStatus = lastStatus[handle]
+			}
+			last[handle] = key
+			lastStatus[handle] = state.Status
+			if err := emit(event); err != nil {
+				return err
+			}
+		}
+		for handle := range last {
+			if present[handle] {
+				continue
+			}
+			event := presenceWatchEvent{
+				Event:          presenceWatchRemoved,
+				At:             now.UTC().Format(time.RFC3339Nano),
+				Handle:         handle,
+				DerivedStatus:  presence.StatusOffline,
+				PreviousStatus: lastStatus[handle],
+			}
+			delete(last, handle)
+			delete(lastStatus, handle)
+			if err := emit(event); err != nil {
+				return err
+			}
+		}
+		first = false
+		return nil
+	}
+
+	if err := scan(); err != nil {
+		return err
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var events <-chan fsnotify.Event
+	var watchErrors <-chan error
+	if watcher != nil {
+		events = watcher.Events
+		watchErrors = watcher.Errors
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := scan(); err != nil {
+				return err
+			}
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if filepath.Base(event.Name) != "presence.json" && filepath.Dir(event.Name) != agentsDir {
+				continue
+			}
+			if err := scan(); err != nil {
+				return err
+			}
+		case err, ok := <-watchErrors:
+			if !ok {
+				watchErrors = nil
+				continue
+			}
+			return err
+		}
+	}
+}
+
+// presenceWatchKey identifies what a watcher reports on: everything except
+// last_seen, which changes on every touch.
+func presenceWatchKey(state agentPresenceState) string {
+	p := *state.Presence
+	p.LastSeen = ""
+	data, _ := json.Marshal(p)
+	return state.Status + "\x00" + state.Source + "\x00" + string(data)
+}
+
+func writePresenceWatchEvent(jsonOut bool, event presenceWatchEvent) error {
+	if jsonOut {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		return writeStdout("%s\n", data)
+	}
+	line := event.At + "  " + event.Handle + "  " + event.DerivedStatus
+	if event.PreviousStatus != "" {
+		line += " (was " + event.PreviousStatus + ")"
+	}
+	if event.Event == presenceWatchRemoved {
+		line += "  presence removed"
+	}
+	if p := event.Presence; p != nil {
+		line += "  " + p.Status
+		if p.Note != "" {
+			line += " — " + p.Note
+		}
+	}
+	return writeStdoutLine(line)
+}
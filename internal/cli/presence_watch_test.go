@@ -0,0 +1,208 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/config"
+	"github.com/avivsinai/agent-message-queue/internal/presence"
+)
+
+func setPresenceConfigForTest(t *testing.T, root string, p *config.PresenceConfig) {
+	t.Helper()
+	path := filepath.Join(root, "meta", "config.json")
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Presence = p
+	if err := config.WriteConfig(path, cfg, true); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPresenceWatchStreamsChangesAndAging(t *testing.T) {
+	root := initializedSendMailboxRoot(t, "claude", "codex")
+	setPresenceConfigForTest(t, root, &config.PresenceConfig{IdleAfter: "300ms", AwayAfter: "30m"})
+	thresholds := presenceThresholds(root)
+	now := time.Now()
+	if err := presence.Write(root, presence.New("claude", "active", "", now)); err != nil {
+		t.Fatal(err)
+	}
+	if err := presence.Write(root, presence.New("codex", "busy", "", now.Add(-40*time.Minute))); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan presenceWatchEvent, 16)
+	done := make(chan error, 1)
+	go func() {
+		done <- watchPresence(ctx, root, thresholds, 50*time.Millisecond, false, func(event presenceWatchEvent) error {
+			events <- event
+			return nil
+		})
+	}()
+	next := func() presenceWatchEvent {
+		t.Helper()
+		select {
+		case event := <-events:
+			return event
+		case err := <-done:
+			t.Fatalf("watch ended early: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a presence event")
+		}
+		return presenceWatchEvent{}
+	}
+
+	if got := next(); got.Event != presenceWatchInitial || got.Handle != "claude" || got.DerivedStatus != presence.StatusActive {
+		t.Fatalf("first event = %+v, want initial claude active", got)
+	}
+	if got := next(); got.Event != presenceWatchInitial || got.Handle != "codex" || got.DerivedStatus != presence.StatusAway {
+		t.Fatalf("second event = %+v, want initial codex away", got)
+	}
+
+	// claude ages past idle_after with no write at all; codex comes back.
+	if got := next(); got.Handle != "claude" || got.DerivedStatus != presence.StatusIdle || got.PreviousStatus != presence.StatusActive {
+		t.Fatalf("aging event = %+v, want claude active -> idle", got)
+	}
+	if err := presence.Write(root, presence.New("codex", "busy", "back from lunch", time.Now())); err != nil {
+		t.Fatal(err)
+	}
+	got := next()
+	if got.Event != presenceWatchChanged || got.Handle != "codex" || got.DerivedStatus != presence.StatusActive ||
+		got.PreviousStatus != presence.StatusAway || got.Presence == nil || got.Presence.Note != "back from lunch" {
+		t.Fatalf("write event = %+v, want codex away -> active with note", got)
+	}
+
+	if err := os.Remove(filepath.Join(root, "agents", "codex", "presence.json")); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		got = next()
+		if got.Handle == "codex" {
+			break
+		}
+	}
+	if got.Event != presenceWatchRemoved || got.DerivedStatus != presence.StatusOffline {
+		t.Fatalf("remove event = %+v, want codex removed offline", got)
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("watch returned %v, want context.Canceled", err)
+	}
+}
+
+func TestDerivedPresenceAgreesAcrossCommands(t *testing.T) {
+	root := setupPresenceSourceFixture(t)
+	// Under these thresholds the hour-old "stale" agent is offline, where the
+	// defaults would call it stale.
+	setPresenceConfigForTest(t, root, &config.PresenceConfig{
+		IdleAfter:    "5m",
+		AwayAfter:    "10m",
+		StaleAfter:   "20m",
+		OfflineAfter: "30m",
+	})
+	want := map[string]string{
+		"notifier":   presence.StatusActive,
+		"recent":     presence.StatusActive,
+		"unverified": presence.StatusActive,
+		"stale":      presence.StatusOffline,
+	}
+
+	listOut, err := captureEnvStdout(t, func() error {
+		return runPresenceList([]string{"--root", root, "--json"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var items []presenceListItem
+	if err := json.Unmarshal([]byte(listOut), &items); err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range items {
+		if status, ok := want[item.Handle]; ok && item.DerivedStatus != status {
+			t.Errorf("presence list %s = %q, want %q", item.Handle, item.DerivedStatus, status)
+		}
+	}
+
+	whoOut, err := captureEnvStdout(t, func() error {
+		return runWho([]string{"--root", root, "--json"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sessions []struct {
+		Agents []struct {
+			Handle        string `json:"handle"`
+			Active        bool   `json:"active"`
+			DerivedStatus string `json:"derived_status"`
+		} `json:"agents"`
+	}
+	if err := json.Unmarshal([]byte(whoOut), &sessions); err != nil || len(sessions) != 1 {
+		t.Fatalf("who sessions = %s (%v)", whoOut, err)
+	}
+	for _, agent := range sessions[0].Agents {
+		if status, ok := want[agent.Handle]; ok && (agent.DerivedStatus != status || agent.Active != presence.IsAlive(status)) {
+			t.Errorf("who %s = %q active=%v, want %q", agent.Handle, agent.DerivedStatus, agent.Active, status)
+		}
+	}
+
+	for _, agent := range runOpsChecks(root, "test", false).Agents {
+		if status, ok := want[agent.Handle]; ok && agent.DerivedStatus != status {
+			t.Errorf("doctor --ops %s = %q, want %q", agent.Handle, agent.DerivedStatus, status)
+		}
+	}
+
+	t.Setenv("AM_ROOT", "")
+	t.Setenv("AM_ME", "")
+	t.Setenv("AM_BASE_ROOT", "")
+	t.Setenv("AM_SESSION", "")
+	for handle, status := range want {
+		if got := runEnvJSONForTest(t, "--root", root, "--me", handle); got.DerivedStatus != status {
+			t.Errorf("env --json %s = %q, want %q", handle, got.DerivedStatus, status)
+		}
+	}
+}
+
+func TestInvalidPresenceConfigFallsBackToDefaults(t *testing.T) {
+	root := setupPresenceSourceFixture(t)
+	setPresenceConfigForTest(t, root, &config.PresenceConfig{IdleAfter: "soon"})
+
+	whoOut, whoErr, err := captureEnvOutput(t, func() error {
+		return runWho([]string{"--root", root, "--json"})
+	})
+	if err != nil {
+		t.Fatalf("who with invalid presence config: %v", err)
+	}
+	if !strings.Contains(whoErr, "using default presence thresholds") {
+		t.Fatalf("who stderr = %q, want a default-thresholds warning", whoErr)
+	}
+	var sessions []struct {
+		Agents []struct {
+			Handle        string `json:"handle"`
+			DerivedStatus string `json:"derived_status"`
+		} `json:"agents"`
+	}
+	if err := json.Unmarshal([]byte(whoOut), &sessions); err != nil || len(sessions) != 1 {
+		t.Fatalf("who sessions = %s (%v)", whoOut, err)
+	}
+	for _, agent := range sessions[0].Agents {
+		if agent.Handle == "stale" && agent.DerivedStatus != presence.StatusStale {
+			t.Fatalf("who stale = %q, want %q under default thresholds", agent.DerivedStatus, presence.StatusStale)
+		}
+	}
+
+	if _, _, err := captureEnvOutput(t, func() error {
+		return runPresenceList([]string{"--root", root})
+	}); err != nil {
+		t.Fatalf("presence list with invalid presence config: %v", err)
+	}
+}
@@ -0,0 +1,206 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+	"github.com/avivsinai/agent-message-queue/internal/lock"
+)
+
+// The project registry is a user-level map from project name to base root.
+// amq setup, amq init, and amq coop init record the owning project so
+// cross-project routing can find peers without hand-maintained absolute
+// paths in .amqrc.
+// Project .amqrc peers always take precedence over registry entries.
+
+const (
+	envProjectRegistry     = "AMQ_PROJECT_REGISTRY"
+	projectRegistryVersion = 1
+
+	peerSourceAmqrc    = "amqrc"
+	peerSourceRegistry = "registry"
+)
+
+type projectRegistry struct {
+	Version  int                             `json:"version"`
+	Projects map[string]projectRegistryEntry `json:"projects"`
+}
+
+type projectRegistryEntry struct {
+	Root         string `json:"root"`
+	RegisteredAt string `json:"registered_at"`
+}
+
+// projectRegistryPath returns AMQ_PROJECT_REGISTRY when set, otherwise
+// ~/.amq/projects.json. The file is not created here.
+func projectRegistryPath() (string, error) {
+	if value := strings.TrimSpace(os.Getenv(envProjectRegistry)); value != "" {
+		return filepath.Abs(value)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("locate project registry: %w", err)
+	}
+	return filepath.Join(home, ".amq", "projects.json"), nil
+}
+
+func validateRegistryProjectName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("project name must not be empty")
+	}
+	if name != strings.TrimSpace(name) || strings.ContainsAny(name, "@:/\\ \t\r\n") {
+		return fmt.Errorf("invalid project name %q: must not contain whitespace, '@', ':', or path separators", name)
+	}
+	return nil
+}
+
+// loadProjectRegistry reads the registry. A missing file is an empty registry.
+func loadProjectRegistry() (projectRegistry, string, error) {
+	path, err := projectRegistryPath()
+	if err != nil {
+		return projectRegistry{}, "", err
+	}
+	registry := projectRegistry{Version: projectRegistryVersion, Projects: map[string]projectRegistryEntry{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return registry, path, nil
+	}
+	if err != nil {
+		return projectRegistry{}, path, fmt.Errorf("read project registry %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &registry); err != nil {
+		return projectRegistry{}, path, fmt.Errorf("invalid project registry %s: %w", path, err)
+	}
+	if registry.Version != projectRegistryVersion {
+		return projectRegistry{}, path, fmt.Errorf("unsupported project registry version %d in %s", registry.Version, path)
+	}
+	if registry.Projects == nil {
+		registry.Projects = map[string]projectRegistryEntry{}
+	}
+	return registry, path, nil
+}
+
+// updateProjectRegistry applies fn under an exclusive lock and writes the
+// result atomically when fn reports a change.
+func updateProjectRegistry(fn func(*projectRegistry) (bool, error)) error {
+	path, err := projectRegistryPath()
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("create project registry directory: %w", err)
+	}
+	return lock.WithExclusiveFileLock(path+".lock", func() error {
+		registry, _, err := loadProjectRegistry()
+		if err != nil {
+			return err
+		}
+		changed, err := fn(&registry)
+		if err != nil || !changed {
+			return err
+		}
+		data, err := json.MarshalIndent(registry, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fsq.WriteFileAtomic(dir, filepath.Base(path), append(data, '\n'), 0o600)
+		return err
+	})
+}
+
+// registerProject records name → baseRoot. It returns the previously
+// registered root when the entry pointed somewhere else.
+func registerProject(name, baseRoot string) (string, error) {
+	if err := validateRegistryProjectName(name); err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(baseRoot)
+	if err != nil {
+		return "", err
+	}
+	previous := ""
+	err = updateProjectRegistry(func(registry *projectRegistry) (bool, error) {
+		if existing, ok := registry.Projects[name]; ok {
+			if existing.Root == abs {
+				return false, nil
+			}
+			previous = existing.Root
+		}
+		registry.Projects[name] = projectRegistryEntry{
+			Root:         abs,
+			RegisteredAt: time.Now().UTC().Format(time.RFC3339),
+		}
+		return true, nil
+	})
+	return previous, err
+}
+
+// registerProjectOwning records the project whose .amqrc owns root, or the
+// .amqrc found from the current directory when root is empty. Registration is
+// a convenience for peers, so failures are reported as warnings instead of
+// failing setup or init.
+func registerProjectOwning(root string) {
+	resetAmqrcCache()
+	result, err := findAmqrcForRoot(root)
+	if err != nil || strings.TrimSpace(result.Config.Root) == "" {
+		return
+	}
+	name := projectFromAmqrcResult(result)
+	if validateRegistryProjectName(name) != nil {
+		return
+	}
+	baseRoot, err := resolvePeerPath(result, result.Config.Root)
+	if err != nil {
+		_ = writeStderr("warning: project registry: %v\n", err)
+		return
+	}
+	if root != "" && !isBaseOrSessionRoot(absPath(root), baseRoot) {
+		return
+	}
+	previous, err := registerProject(name, baseRoot)
+	if err != nil {
+		_ = writeStderr("warning: project registry: %v\n", err)
+		return
+	}
+	if previous != "" {
+		_ = writeStderr("warning: project registry: %q now points to %s (was %s)\n", name, baseRoot, previous)
+	}
+}
+
+// lookupRegisteredProject resolves a registry entry. ok is false when the
+// name is not registered.
+func lookupRegisteredProject(name string) (string, bool, error) {
+	registry, path, err := loadProjectRegistry()
+	if err != nil {
+		return "", false, err
+	}
+	entry, ok := registry.Projects[name]
+	if !ok {
+		return "", false, nil
+	}
+	info, err := os.Stat(entry.Root)
+	if err != nil || !info.IsDir() {
+		return "", false, fmt.Errorf(
+			"project registry %s maps %q to missing root %s; run 'amq peers prune' or 'amq peers add %s <root>'",
+			path, name, entry.Root, name,
+		)
+	}
+	return entry.Root, true, nil
+}
+
+func sortedRegistryNames(registry projectRegistry) []string {
+	names := make([]string, 0, len(registry.Projects))
+	for name := range registry.Projects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
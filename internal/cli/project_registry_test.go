@@ -0,0 +1,189 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func useTestProjectRegistry(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "projects.json")
+	t.Setenv(envProjectRegistry, path)
+	return path
+}
+
+func TestResolvePeerPrefersAmqrcThenProjectRegistry(t *testing.T) {
+	useTestProjectRegistry(t)
+	amqrcPeer := filepath.Join(t.TempDir(), ".agent-mail")
+	registryPeer := filepath.Join(t.TempDir(), ".agent-mail")
+	shadowedPeer := filepath.Join(t.TempDir(), ".agent-mail")
+	for _, dir := range []string{amqrcPeer, registryPeer, shadowedPeer} {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := registerProject("infra", shadowedPeer); err != nil {
+		t.Fatalf("register infra: %v", err)
+	}
+	if _, err := registerProject("web", registryPeer); err != nil {
+		t.Fatalf("register web: %v", err)
+	}
+	result := amqrcResult{
+		Config: amqrc{Root: ".agent-mail", Project: "app", Peers: map[string]string{"infra": amqrcPeer}},
+		Dir:    t.TempDir(),
+	}
+
+	root, source, err := resolvePeerWithSource(result, "infra")
+	if err != nil || root != amqrcPeer || source != peerSourceAmqrc {
+		t.Fatalf("infra = %q, %q, %v; want %q from .amqrc", root, source, err, amqrcPeer)
+	}
+	root, source, err = resolvePeerWithSource(result, "web")
+	if err != nil || root != registryPeer || source != peerSourceRegistry {
+		t.Fatalf("web = %q, %q, %v; want %q from registry", root, source, err, registryPeer)
+	}
+	if _, _, err := resolvePeerWithSource(result, "missing"); err == nil || !strings.Contains(err.Error(), "project registry") {
+		t.Fatalf("missing peer error = %v, want registry hint", err)
+	}
+
+	if err := os.RemoveAll(registryPeer); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := resolvePeerWithSource(result, "web"); err == nil || !strings.Contains(err.Error(), "amq peers prune") {
+		t.Fatalf("stale registry error = %v, want prune hint", err)
+	}
+}
+
+func TestPeersAddListRmPrune(t *testing.T) {
+	useTestProjectRegistry(t)
+	t.Chdir(t.TempDir())
+	resetAmqrcCache()
+	t.Cleanup(resetAmqrcCache)
+
+	projectDir := filepath.Join(t.TempDir(), "infra-lib")
+	writeRouteAmqrc(t, projectDir, map[string]any{"root": ".agent-mail"})
+	baseRoot := filepath.Join(projectDir, ".agent-mail")
+	ensureRouteAgents(t, baseRoot, "codex")
+	goneRoot := filepath.Join(t.TempDir(), "gone")
+	if err := os.MkdirAll(goneRoot, 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	var added peerListEntry
+	stdout, _, err := captureEnvOutput(t, func() error {
+		return runPeers([]string{"add", "infra-lib", projectDir, "--json"})
+	})
+	if err != nil {
+		t.Fatalf("peers add: %v", err)
+	}
+	if err := unmarshalJSONOutput(stdout, &added); err != nil {
+		t.Fatal(err)
+	}
+	if added.Root != baseRoot {
+		t.Fatalf("added root = %q, want project .amqrc root %q", added.Root, baseRoot)
+	}
+	if _, _, err := captureEnvOutput(t, func() error {
+		return runPeers([]string{"add", "gone", goneRoot})
+	}); err != nil {
+		t.Fatalf("peers add gone: %v", err)
+	}
+	if err := os.RemoveAll(goneRoot); err != nil {
+		t.Fatal(err)
+	}
+
+	var listed peerListResult
+	stdout, _, err = captureEnvOutput(t, func() error { return runPeers([]string{"list", "--json"}) })
+	if err != nil {
+		t.Fatalf("peers list: %v", err)
+	}
+	if err := unmarshalJSONOutput(stdout, &listed); err != nil {
+		t.Fatal(err)
+	}
+	if len(listed.Peers) != 2 || listed.Peers[0].Name != "gone" || listed.Peers[0].Status != "missing" || listed.Peers[1].Status != "ok" {
+		t.Fatalf("listed peers = %+v", listed.Peers)
+	}
+
+	var pruned peerPruneResult
+	stdout, _, err = captureEnvOutput(t, func() error { return runPeers([]string{"prune", "--json"}) })
+	if err != nil {
+		t.Fatalf("peers prune: %v", err)
+	}
+	if err := unmarshalJSONOutput(stdout, &pruned); err != nil {
+		t.Fatal(err)
+	}
+	if len(pruned.Pruned) != 1 || pruned.Pruned[0].Name != "gone" {
+		t.Fatalf("pruned = %+v, want only gone", pruned.Pruned)
+	}
+
+	if _, _, err := captureEnvOutput(t, func() error { return runPeers([]string{"rm", "infra-lib"}) }); err != nil {
+		t.Fatalf("peers rm: %v", err)
+	}
+	_, _, err = captureEnvOutput(t, func() error { return runPeers([]string{"rm", "infra-lib"}) })
+	if GetExitCode(err) != ExitNotFound {
+		t.Fatalf("second rm exit = %d (%v), want not found", GetExitCode(err), err)
+	}
+	registry, _, err := loadProjectRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(registry.Projects) != 0 {
+		t.Fatalf("registry = %+v, want empty", registry.Projects)
+	}
+}
+
+func TestCoopInitRegistersProjectForPeers(t *testing.T) {
+	useTestProjectRegistry(t)
+	projectDir := filepath.Join(t.TempDir(), "billing")
+	if err := os.MkdirAll(projectDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(projectDir)
+	resetAmqrcCache()
+	t.Cleanup(resetAmqrcCache)
+
+	if _, _, err := captureEnvOutput(t, func() error {
+		return runCoopInit([]string{"--agents", "claude,codex", "--no-gitignore", "--json"})
+	}); err != nil {
+		t.Fatalf("coop init: %v", err)
+	}
+	registered, ok, err := lookupRegisteredProject("billing")
+	if err != nil || !ok {
+		t.Fatalf("lookup billing = %q, %v, %v", registered, ok, err)
+	}
+	if want := filepath.Join(projectDir, ".agent-mail"); !sameCleanPath(registered, want) {
+		t.Fatalf("registered root = %q, want %q", registered, want)
+	}
+}
+
+func TestRouteExplainReportsRegistryPeerSource(t *testing.T) {
+	useTestProjectRegistry(t)
+	srcProjectDir := filepath.Join(t.TempDir(), "src-project")
+	sourceRoot := filepath.Join(srcProjectDir, ".agent-mail", "collab")
+	peerBaseRoot := filepath.Join(t.TempDir(), "peer-project", ".agent-mail")
+	ensureRouteAgents(t, sourceRoot, "alice")
+	ensureRouteAgents(t, filepath.Join(peerBaseRoot, "collab"), "bob")
+	writeRouteAmqrc(t, srcProjectDir, map[string]any{
+		"root":    ".agent-mail",
+		"project": "src-project",
+	})
+	if _, err := registerProject("peer-project", peerBaseRoot); err != nil {
+		t.Fatalf("register peer: %v", err)
+	}
+
+	result := runRouteExplainJSONForTest(t,
+		"--from-root", sourceRoot,
+		"--me", "alice",
+		"--to", "bob",
+		"--project", "peer-project",
+	)
+	if !result.Routable {
+		t.Fatalf("expected routable route, got error: %s", result.Error)
+	}
+	if result.PeerSource != peerSourceRegistry {
+		t.Fatalf("peer_source = %q, want %q", result.PeerSource, peerSourceRegistry)
+	}
+	if result.DeliveryRoot != filepath.Join(peerBaseRoot, "collab") {
+		t.Fatalf("delivery_root = %q", result.DeliveryRoot)
+	}
+}
@@ -0,0 +1,461 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/config"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+	"github.com/avivsinai/agent-message-queue/internal/lock"
+	"github.com/avivsinai/agent-message-queue/internal/presence"
+	"github.com/avivsinai/agent-message-queue/internal/receipt"
+)
+
+// A queue handle is an ordinary mailbox declared under "queues" in
+// meta/config.json. Members take items from its inbox/new with the same
+// exclusive claim rename drain uses, so each item has exactly one claimer.
+// Every claim records a lease under agents/<queue>/leases; when the claimer's
+// presence goes stale for longer than the lease, the next claim attempt by
+// any member returns the item to inbox/new.
+
+const queueLeaseSchema = 1
+
+type queueLease struct {
+	Schema      int    `json:"schema"`
+	Queue       string `json:"queue"`
+	MsgID       string `json:"msg_id"`
+	Filename    string `json:"filename"`
+	Claimer     string `json:"claimer"`
+	ClaimedAt   string `json:"claimed_at"`
+	Lease       string `json:"lease"`
+	CompletedAt string `json:"completed_at,omitempty"`
+}
+
+type queueClaimResult struct {
+	Queue     string      `json:"queue"`
+	Claimed   *inboxItem  `json:"claimed"`
+	Lease     *queueLease `json:"lease,omitempty"`
+	Completed *queueLease `json:"completed,omitempty"`
+	Returned  []string    `json:"returned,omitempty"`
+}
+
+func runClaim(args []string) error {
+	fs := flag.NewFlagSet("claim", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	queueFlag := fs.String("queue", "", "Queue handle to claim from (required)")
+	completeFlag := fs.String("complete", "", "Mark a message you claimed from the queue as completed")
+	includeBodyFlag := fs.Bool("include-body", false, "Include message body in output")
+	sessionFlag := fs.String("session", "", "Target session under the resolved base root")
+	ignoreSessionPinFlag := fs.Bool("ignore-session-pin", false, "With explicit --root, ignore a conflicting AM_SESSION pin")
+
+	usage := usageWithFlags(fs, "amq claim --queue <queue> --me <agent> [--complete <id>] [options]",
+		"Claims the oldest item in a queue handle's inbox for exactly one member.",
+		"Each claim carries a lease: if the claimer's presence goes stale for longer",
+		"than the queue's lease, the item returns to the queue on the next claim.",
+		"Emits claimed and completed receipts in the claimer's namespace.")
+	if handled, err := parseFlags(fs, args, usage); err != nil {
+		return err
+	} else if handled {
+		return nil
+	}
+	if err := requireMe(common.Me); err != nil {
+		return err
+	}
+	me, err := normalizeHandle(common.Me)
+	if err != nil {
+		return UsageError("--me: %v", err)
+	}
+	common.Me = me
+	if strings.TrimSpace(*queueFlag) == "" {
+		return UsageError("--queue is required")
+	}
+	queue, err := normalizeHandle(*queueFlag)
+	if err != nil {
+		return UsageError("--queue: %v", err)
+	}
+	if queue == me {
+		return UsageError("--me must be a queue member, not the queue handle itself")
+	}
+	completeID := strings.TrimSpace(*completeFlag)
+	if completeID != "" {
+		if err := fsq.ValidateMessageFilename(completeID + ".md"); err != nil {
+			return UsageError("--complete: %v", err)
+		}
+	}
+
+	root, routed, err := resolveMailboxRoot(common, *sessionFlag)
+	if err != nil {
+		return err
+	}
+	if err := validatePinOverride(common, *ignoreSessionPinFlag, routed); err != nil {
+		return err
+	}
+	if err := guardMailboxContext("claim", root, routed, *ignoreSessionPinFlag, common.rootExplicit()); err != nil {
+		return err
+	}
+	deliveryIdentity, err := snapshotMailboxDeliveryRoot(root, routed, *ignoreSessionPinFlag)
+	if err != nil {
+		return err
+	}
+	for _, handle := range []string{queue, me} {
+		if err := requireMailbox(root, handle); err != nil {
+			return err
+		}
+	}
+	if err := validateKnownHandles(root, common.Strict, queue, me); err != nil {
+		return err
+	}
+	validator, err := newHeaderValidator(root, common.Strict)
+	if err != nil {
+		return err
+	}
+	deliveryRoot, err := fsq.OpenDeliveryRoot(root, deliveryIdentity)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = deliveryRoot.Close() }()
+
+	queueCfg, err := loadQueueConfig(deliveryRoot, queue)
+	if err != nil {
+		return err
+	}
+	if !queueCfg.HasMember(me) {
+		return UsageError("%q is not a member of queue %q (members: %s)", me, queue, strings.Join(queueCfg.Members, ", "))
+	}
+	leaseDuration, err := queueCfg.LeaseDuration()
+	if err != nil {
+		return err
+	}
+
+	// Claiming is activity: refresh our own presence before judging anyone
+	// else's so our existing leases never look stale to ourselves.
+	_ = presence.ReturnDeliveryRoot(deliveryRoot, me)
+
+	result := queueClaimResult{Queue: queue}
+	result.Returned, err = returnStaleQueueClaims(deliveryRoot, root, queue, leaseDuration, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var claimErr error
+	if completeID != "" {
+		result.Completed, err = completeQueueClaim(deliveryRoot, queue, me, completeID, validator)
+		if err != nil {
+			return err
+		}
+	} else {
+		result.Claimed, result.Lease, claimErr = claimQueueItem(deliveryRoot, root, queue, me, leaseDuration, *includeBodyFlag, validator)
+		var committed *fsq.CommittedDurabilityError
+		if claimErr != nil && !errors.As(claimErr, &committed) {
+			return claimErr
+		}
+	}
+
+	if err := writeQueueClaimResult(common.JSON, *includeBodyFlag, result); err != nil {
+		if claimErr != nil {
+			return errors.Join(claimErr, err)
+		}
+		return err
+	}
+	return claimErr
+}
+
+func loadQueueConfig(deliveryRoot *fsq.DeliveryRoot, queue string) (config.QueueConfig, error) {
+	cfg, err := config.ReadConfig(deliveryRoot)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return config.QueueConfig{}, fmt.Errorf("read queue config: %w", err)
+	}
+	queueCfg, ok := cfg.Queues[queue]
+	if !ok {
+		return config.QueueConfig{}, UsageError("%q is not a queue handle; declare it under \"queues\" in meta/config.json", queue)
+	}
+	return queueCfg, nil
+}
+
+// refuseQueueHandle rejects consuming a queue handle's inbox outside amq
+// claim. Drain, read, monitor, and watch would take or race for items without
+// a lease, and an item drained without one is never returned to the queue.
+func refuseQueueHandle(deliveryRoot *fsq.DeliveryRoot, command string, handles ...string) error {
+	cfg, err := config.ReadConfig(deliveryRoot)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("read queue config: %w", err)
+	}
+	for _, handle := range handles {
+		if _, queue := cfg.Queues[handle]; queue {
+			return UsageError("%s: %q is a queue handle; take its items with 'amq claim --queue %s --me <member>' so each claim carries a lease", command, handle, handle)
+		}
+	}
+	return nil
+}
+
+func queueLeaseDir(queue string) string {
+	return filepath.Join("agents", queue, "leases")
+}
+
+func queueLeaseName(filename string) string {
+	return strings.TrimSuffix(filename, ".md") + ".json"
+}
+
+// withQueueLeaseLock serializes lease bookkeeping for one queue. The claim
+// rename itself stays lock-free; the lock only orders lease writes against
+// lease expiry so an expiring claim cannot delete a newer claimer's lease.
+func withQueueLeaseLock(deliveryRoot *fsq.DeliveryRoot, queue string, fn func() error) error {
+	file, err := deliveryRoot.OpenLockFile(queueLeaseDir(queue), ".lock", 0o600)
+	if err != nil {
+		return fmt.Errorf("open queue lease lock: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+	return lock.WithExclusiveFile(file, fn)
+}
+
+func readQueueLease(deliveryRoot *fsq.DeliveryRoot, queue, name string) (queueLease, error) {
+	data, err := deliveryRoot.ReadRegularNoFollow(filepath.Join(queueLeaseDir(queue), name))
+	if err != nil {
+		return queueLease{}, err
+	}
+	var lease queueLease
+	if err := json.Unmarshal(data, &lease); err != nil {
+		return queueLease{}, fmt.Errorf("invalid queue lease %s: %w", name, err)
+	}
+	return lease, nil
+}
+
+func writeQueueLease(deliveryRoot *fsq.DeliveryRoot, lease queueLease) error {
+	data, err := json.MarshalIndent(lease, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = deliveryRoot.WriteFileAtomic(queueLeaseDir(lease.Queue), queueLeaseName(lease.Filename), append(data, '\n'), 0o600)
+	return err
+}
+
+// claimQueueItem claims the oldest parsable item in the queue. Items that
+// fail to parse are dead-lettered under the queue handle and skipped.
+func claimQueueItem(
+	deliveryRoot *fsq.DeliveryRoot,
+	root, queue, me string,
+	leaseDuration time.Duration,
+	includeBody bool,
+	validator *headerValidator,
+) (*inboxItem, *queueLease, error) {
+	filenames, err := collectInboxFilenames(deliveryRoot, queue)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, NotFoundError("mailbox for queue %q disappeared at root %s", queue, root)
+		}
+		return nil, nil, err
+	}
+	for _, filename := range filenames {
+		claimErr := claimInboxNewToCur(deliveryRoot, queue, filename)
+		var committed *fsq.CommittedDurabilityError
+		if claimErr != nil && !errors.As(claimErr, &committed) {
+			if os.IsNotExist(claimErr) {
+				// Another member won this item.
+				continue
+			}
+			return nil, nil, claimErr
+		}
+
+		lease := queueLease{
+			Schema:    queueLeaseSchema,
+			Queue:     queue,
+			MsgID:     strings.TrimSuffix(filename, ".md"),
+			Filename:  filename,
+			Claimer:   me,
+			ClaimedAt: time.Now().UTC().Format(time.RFC3339Nano),
+			Lease:     leaseDuration.String(),
+		}
+		if err := withQueueLeaseLock(deliveryRoot, queue, func() error {
+			return writeQueueLease(deliveryRoot, lease)
+		}); err != nil {
+			return nil, nil, errors.Join(claimErr, fmt.Errorf("record lease for %s: %w", filename, err))
+		}
+
+		item, err := readInboxItem(deliveryRoot, filepath.Join("agents", queue, "inbox", "cur", filename), filename, includeBody, validator)
+		if err != nil {
+			item.ParseError = err.Error()
+			item.FailureReason = "parse_error"
+		}
+		if item.ParseError != "" {
+			if dlqErr := deadLetterQueueItem(deliveryRoot, queue, item, claimErr); dlqErr != nil {
+				return nil, nil, dlqErr
+			}
+			continue
+		}
+		item.MovedToCur = true
+		emitReceipt(deliveryRoot, me, &item, receipt.StageClaimed, "queue "+queue)
+		return &item, &lease, claimErr
+	}
+	return nil, nil, nil
+}
+
+// deadLetterQueueItem moves an unparsable claimed item to the queue's DLQ and
+// drops its lease so it is never returned to the queue.
+func deadLetterQueueItem(deliveryRoot *fsq.DeliveryRoot, queue string, item inboxItem, claimErr error) error {
+	reason := item.FailureReason
+	if reason == "" {
+		reason = "parse_error"
+	}
+	var err error
+	if claimErr != nil {
+		_, err = moveClaimedInboxCurToDLQ(deliveryRoot, queue, item.Filename, item.ID, reason, item.ParseError, claimErr)
+	} else {
+		_, err = moveInboxCurToDLQ(deliveryRoot, queue, item.Filename, item.ID, reason, item.ParseError)
+	}
+	if err != nil {
+		return fmt.Errorf("dead-letter unparsable queue item %s: %w", item.Filename, err)
+	}
+	emitReceipt(deliveryRoot, queue, &item, receipt.StageDLQ, item.ParseError)
+	_ = writeStderr("warning: moved unparsable queue item %s to %s DLQ: %s\n", item.Filename, queue, item.ParseError)
+	return withQueueLeaseLock(deliveryRoot, queue, func() error {
+		err := deliveryRoot.Remove(filepath.Join(queueLeaseDir(queue), queueLeaseName(item.Filename)))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	})
+}
+
+// completeQueueClaim marks me's claim on id as completed and emits the
+// completed receipt. Completing twice is a no-op.
+func completeQueueClaim(deliveryRoot *fsq.DeliveryRoot, queue, me, id string, validator *headerValidator) (*queueLease, error) {
+	var completed queueLease
+	alreadyCompleted := false
+	err := withQueueLeaseLock(deliveryRoot, queue, func() error {
+		lease, err := readQueueLease(deliveryRoot, queue, queueLeaseName(id))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return NotFoundError("no claim on %s in queue %q; it may have returned to the queue after its lease expired", id, queue)
+			}
+			return err
+		}
+		if lease.Claimer != me {
+			return UsageError("%s in queue %q is claimed by %q, not %q", id, queue, lease.Claimer, me)
+		}
+		if lease.CompletedAt != "" {
+			completed = lease
+			alreadyCompleted = true
+			return nil
+		}
+		lease.CompletedAt = time.Now().UTC().Format(time.RFC3339Nano)
+		if err := writeQueueLease(deliveryRoot, lease); err != nil {
+			return err
+		}
+		completed = lease
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if alreadyCompleted {
+		return &completed, nil
+	}
+	item, err := readInboxItem(deliveryRoot, filepath.Join("agents", queue, "inbox", "cur", completed.Filename), completed.Filename, false, validator)
+	if err != nil {
+		item = inboxItem{ID: completed.MsgID}
+	}
+	emitReceipt(deliveryRoot, me, &item, receipt.StageCompleted, "queue "+queue)
+	return &completed, nil
+}
+
+// returnStaleQueueClaims moves items whose claimer has gone quiet for longer
+// than the lease back to inbox/new. A claimer with a live notifier is never
+// stale. It returns the message IDs put back on the queue.
+func returnStaleQueueClaims(deliveryRoot *fsq.DeliveryRoot, root, queue string, leaseDuration time.Duration, now time.Time) ([]string, error) {
+	var returned []string
+	err := withQueueLeaseLock(deliveryRoot, queue, func() error {
+		entries, err := deliveryRoot.ReadDir(queueLeaseDir(queue))
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".json") {
+				continue
+			}
+			lease, err := readQueueLease(deliveryRoot, queue, name)
+			if err != nil {
+				_ = writeStderr("warning: skipping queue lease: %v\n", err)
+				continue
+			}
+			if lease.CompletedAt != "" || !queueClaimerStale(deliveryRoot, root, lease, leaseDuration, now) {
+				continue
+			}
+			moveErr := fsq.MoveCurToNew(deliveryRoot, queue, lease.Filename)
+			var committed *fsq.CommittedDurabilityError
+			switch {
+			case moveErr == nil, errors.As(moveErr, &committed):
+				returned = append(returned, lease.MsgID)
+			case os.IsNotExist(moveErr):
+				// The claimed copy is gone (dead-lettered or cleaned up);
+				// only the lease remains.
+			default:
+				return fmt.Errorf("return %s to queue %q: %w", lease.MsgID, queue, moveErr)
+			}
+			if err := deliveryRoot.Remove(filepath.Join(queueLeaseDir(queue), name)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		return nil
+	})
+	sort.Strings(returned)
+	return returned, err
+}
+
+func queueClaimerStale(deliveryRoot *fsq.DeliveryRoot, root string, lease queueLease, leaseDuration time.Duration, now time.Time) bool {
+	lastActive, err := time.Parse(time.RFC3339Nano, lease.ClaimedAt)
+	if err != nil {
+		lastActive = time.Time{}
+	}
+	if data, err := deliveryRoot.ReadFile(filepath.Join("agents", lease.Claimer, "presence.json")); err == nil {
+		var p presence.Presence
+		if json.Unmarshal(data, &p) == nil {
+			if seen, err := time.Parse(time.RFC3339Nano, p.LastSeen); err == nil && seen.After(lastActive) {
+				lastActive = seen
+			}
+		}
+	}
+	if now.Sub(lastActive) <= leaseDuration {
+		return false
+	}
+	return resolvePresenceSource(root, lease.Claimer, false) != presenceSourceNotifierLive
+}
+
+func writeQueueClaimResult(jsonOutput, includeBody bool, result queueClaimResult) error {
+	if jsonOutput {
+		return writeJSON(os.Stdout, result)
+	}
+	for _, id := range result.Returned {
+		if err := writeStdout("Returned %s to queue %s (lease expired)\n", id, result.Queue); err != nil {
+			return err
+		}
+	}
+	if result.Completed != nil {
+		return writeStdout("Completed %s from queue %s\n", result.Completed.MsgID, result.Queue)
+	}
+	if result.Claimed == nil {
+		return writeStdout("Queue %s has no unclaimed items\n", result.Queue)
+	}
+	item := result.Claimed
+	subject := item.Subject
+	if subject == "" {
+		subject = "(no subject)"
+	}
+	if err := writeStdout("Claimed %s from queue %s (lease %s)\n  From: %s\n  Thread: %s\n  Subject: %s\n  Created: %s\n",
+		item.ID, result.Queue, result.Lease.Lease, item.From, item.Thread, subject, item.Created); err != nil {
+		return err
+	}
+	if includeBody && item.Body != "" {
+		if err := writeStdout("  Body:\n%s\n", item.Body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/config"
+	"github.com/avivsinai/agent-message-queue/internal/presence"
+	"github.com/avivsinai/agent-message-queue/internal/receipt"
+)
+
+func setQueueConfigForTest(t *testing.T, root string, queues map[string]config.QueueConfig) {
+	t.Helper()
+	path := filepath.Join(root, "meta", "config.json")
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Queues = queues
+	if err := config.WriteConfig(path, cfg, true); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func runClaimJSONForTest(t *testing.T, args ...string) queueClaimResult {
+	t.Helper()
+	stdout, _, err := captureEnvOutput(t, func() error {
+		return runClaim(append(args, "--json"))
+	})
+	if err != nil {
+		t.Fatalf("claim %v: %v", args, err)
+	}
+	var result queueClaimResult
+	if err := unmarshalJSONOutput(stdout, &result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestQueueClaimIsExclusiveAndExpiredLeasesReturn(t *testing.T) {
+	root := initializedSendMailboxRoot(t, "claude", "codex", "gemini", "review-queue")
+	setQueueConfigForTest(t, root, map[string]config.QueueConfig{
+		"review-queue": {Members: []string{"codex", "gemini"}, Lease: "1m"},
+	})
+	for _, subject := range []string{"first", "second"} {
+		if _, _, err := captureEnvOutput(t, func() error {
+			return runSend([]string{"--root", root, "--me", "claude", "--to", "review-queue", "--subject", subject, "--body", "review " + subject})
+		}); err != nil {
+			t.Fatalf("send %s: %v", subject, err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	first := runClaimJSONForTest(t, "--root", root, "--me", "codex", "--queue", "review-queue")
+	if first.Claimed == nil || first.Claimed.Subject != "first" || first.Lease == nil || first.Lease.Claimer != "codex" {
+		t.Fatalf("codex claim = %+v", first)
+	}
+	second := runClaimJSONForTest(t, "--root", root, "--me", "gemini", "--queue", "review-queue")
+	if second.Claimed == nil || second.Claimed.Subject != "second" {
+		t.Fatalf("gemini claim = %+v", second)
+	}
+	if empty := runClaimJSONForTest(t, "--root", root, "--me", "codex", "--queue", "review-queue"); empty.Claimed != nil {
+		t.Fatalf("third claim = %+v, want empty queue", empty.Claimed)
+	}
+	if _, err := receipt.Read(filepath.Join(root, "agents", "gemini", "receipts", second.Claimed.ID+"__gemini__claimed.json")); err != nil {
+		t.Fatalf("gemini claimed receipt: %v", err)
+	}
+
+	// gemini goes quiet: age both its presence and its lease past the lease.
+	stale := time.Now().Add(-time.Hour)
+	if err := presence.Write(root, presence.New("gemini", "active", "", stale)); err != nil {
+		t.Fatal(err)
+	}
+	leasePath := filepath.Join(root, "agents", "review-queue", "leases", second.Claimed.ID+".json")
+	lease := *second.Lease
+	lease.ClaimedAt = stale.UTC().Format(time.RFC3339Nano)
+	data, err := json.Marshal(lease)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(leasePath, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	reclaimed := runClaimJSONForTest(t, "--root", root, "--me", "codex", "--queue", "review-queue")
+	if len(reclaimed.Returned) != 1 || reclaimed.Returned[0] != second.Claimed.ID {
+		t.Fatalf("returned = %v, want %s", reclaimed.Returned, second.Claimed.ID)
+	}
+	if reclaimed.Claimed == nil || reclaimed.Claimed.ID != second.Claimed.ID || reclaimed.Lease.Claimer != "codex" {
+		t.Fatalf("reclaim = %+v, want codex holding %s", reclaimed.Claimed, second.Claimed.ID)
+	}
+
+	done := runClaimJSONForTest(t, "--root", root, "--me", "codex", "--queue", "review-queue", "--complete", first.Claimed.ID)
+	if done.Completed == nil || done.Completed.CompletedAt == "" {
+		t.Fatalf("complete = %+v", done)
+	}
+	if _, _, err := captureEnvOutput(t, func() error {
+		return runClaim([]string{"--root", root, "--me", "gemini", "--queue", "review-queue", "--complete", second.Claimed.ID})
+	}); GetExitCode(err) != ExitUsage {
+		t.Fatalf("gemini completing codex's claim exit = %d (%v), want usage", GetExitCode(err), err)
+	}
+	if _, _, err := captureEnvOutput(t, func() error {
+		return runClaim([]string{"--root", root, "--me", "claude", "--queue", "review-queue"})
+	}); GetExitCode(err) != ExitUsage {
+		t.Fatalf("non-member claim exit = %d (%v), want usage", GetExitCode(err), err)
+	}
+
+	trace := collectTrace(root, first.Claimed.ID)
+	stages := map[string]bool{}
+	for _, evidence := range trace.Legs["receipts"].Evidence {
+		if evidence.Receipt != nil && evidence.Receipt.Consumer == "codex" {
+			stages[evidence.Receipt.Stage] = true
+		}
+	}
+	if !stages[receipt.StageClaimed] || !stages[receipt.StageCompleted] {
+		t.Fatalf("trace receipt stages = %v, want claimed and completed", stages)
+	}
+	leaseState := ""
+	for _, evidence := range trace.Legs["delivery"].Evidence {
+		if evidence.Authority == "queue_lease" {
+			leaseState = evidence.State
+		}
+	}
+	if leaseState != receipt.StageCompleted {
+		t.Fatalf("trace queue lease state = %q, want completed", leaseState)
+	}
+}
+
+func TestQueueHandleRefusesLeaseLessConsumers(t *testing.T) {
+	root := initializedSendMailboxRoot(t, "claude", "codex", "review-queue")
+	setQueueConfigForTest(t, root, map[string]config.QueueConfig{
+		"review-queue": {Members: []string{"codex"}},
+	})
+	if _, _, err := captureEnvOutput(t, func() error {
+		return runSend([]string{"--root", root, "--me", "claude", "--to", "review-queue", "--subject", "work", "--body", "review"})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(filepath.Join(root, "agents", "review-queue", "inbox", "new"))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("queue inbox/new = %v (%v), want one item", entries, err)
+	}
+	id := strings.TrimSuffix(entries[0].Name(), ".md")
+
+	for _, tc := range []struct {
+		name string
+		run  func([]string) error
+		args []string
+	}{
+		{"drain", runDrain, []string{"--me", "review-queue"}},
+		{"read", runRead, []string{"--me", "review-queue", "--id", id}},
+		{"monitor", runMonitor, []string{"--me", "review-queue", "--timeout", "1ms"}},
+		{"watch", runWatch, []string{"--me", "review-queue", "--timeout", "1ms"}},
+		{"monitor --agents", runMonitor, []string{"--agents", "codex,review-queue", "--timeout", "1ms"}},
+	} {
+		if _, _, err := captureEnvOutput(t, func() error {
+			return tc.run(append([]string{"--root", root}, tc.args...))
+		}); GetExitCode(err) != ExitUsage {
+			t.Fatalf("%s on a queue handle exit = %d (%v), want usage", tc.name, GetExitCode(err), err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "agents", "review-queue", "inbox", "new", id+".md")); err != nil {
+		t.Fatalf("queue item left inbox/new without a claim: %v", err)
+	}
+}
+
+func TestDoctorReturnsExpiredQueueClaims(t *testing.T) {
+	root := initializedSendMailboxRoot(t, "claude", "codex", "review-queue")
+	setQueueConfigForTest(t, root, map[string]config.QueueConfig{
+		"review-queue": {Members: []string{"codex"}, Lease: "1m"},
+	})
+	if _, _, err := captureEnvOutput(t, func() error {
+		return runSend([]string{"--root", root, "--me", "claude", "--to", "review-queue", "--subject", "work", "--body", "review"})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	claim := runClaimJSONForTest(t, "--root", root, "--me", "codex", "--queue", "review-queue")
+	if claim.Claimed == nil {
+		t.Fatalf("claim = %+v", claim)
+	}
+
+	if reports, check := inspectDoctorQueueLeases(root, false, false); check == nil || check.Status != "ok" || len(reports) != 1 || reports[0].Open != 1 {
+		t.Fatalf("fresh lease: reports = %+v, check = %+v", reports, check)
+	}
+
+	stale := time.Now().Add(-time.Hour)
+	if err := presence.Write(root, presence.New("codex", "active", "", stale)); err != nil {
+		t.Fatal(err)
+	}
+	lease := *claim.Lease
+	lease.ClaimedAt = stale.UTC().Format(time.RFC3339Nano)
+	data, err := json.Marshal(lease)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "agents", "review-queue", "leases", claim.Claimed.ID+".json"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	reports, check := inspectDoctorQueueLeases(root, false, false)
+	if check == nil || check.Status != "warn" || len(reports) != 1 || len(reports[0].Expired) != 1 || len(reports[0].Returned) != 0 {
+		t.Fatalf("expired lease without fix: reports = %+v, check = %+v", reports, check)
+	}
+	newPath := filepath.Join(root, "agents", "review-queue", "inbox", "new", claim.Claimed.ID+".md")
+	if _, err := os.Stat(newPath); !os.IsNotExist(err) {
+		t.Fatalf("inspection moved the claimed item: %v", err)
+	}
+
+	reports, check = inspectDoctorQueueLeases(root, true, false)
+	if check == nil || check.Status != "ok" || len(reports) != 1 || len(reports[0].Returned) != 1 || reports[0].Returned[0] != claim.Claimed.ID {
+		t.Fatalf("fix: reports = %+v, check = %+v", reports, check)
+	}
+	if _, err := os.Stat(newPath); err != nil {
+		t.Fatalf("expired claim not returned to inbox/new: %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+	"github.com/avivsinai/agent-message-queue/internal/quota"
+)
+
+// On-full policies for deliveries that would exceed a recipient quota.
+const (
+	onFullFail    = quota.OnFullFail
+	onFullWait    = quota.OnFullWait
+	onFullDropLow = quota.OnFullDropLow
+)
+
+const onFullFlagUsage = "When a recipient mailbox is over quota: fail, wait, drop-low (drop the message if --priority low)"
+
+func parseOnFull(raw string) (string, error) {
+	switch raw {
+	case "", onFullFail:
+		return onFullFail, nil
+	case onFullWait, onFullDropLow:
+		return raw, nil
+	default:
+		return "", UsageError("--on-full must be one of: fail, wait, drop-low")
+	}
+}
+
+// admitDelivery applies recipient quotas from configFS to a delivery into
+// deliveryFS. It reports dropped=true when the drop-low policy discarded the
+// message; the caller must then skip delivery. wait polls until the mailbox
+// drains or timeout elapses (0 waits forever).
+func admitDelivery(
+	configFS, deliveryFS *fsq.DeliveryRoot,
+	policy, sender string,
+	recipients []string,
+	size int64,
+	priority string,
+	timeout time.Duration,
+) (bool, error) {
+	ctx, cancel := onFullContext(timeout)
+	defer cancel()
+	dropped, err := quota.Admit(ctx, configFS, deliveryFS, policy, sender, recipients, size, priority)
+	var exceeded *quota.ExceededError
+	if errors.As(err, &exceeded) {
+		return false, QuotaExceededError(err)
+	}
+	return dropped, err
+}
+
+// onFullContext bounds an on-full wait by timeout (0 waits forever).
+func onFullContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeoutCause(context.Background(), timeout, fmt.Errorf("waited %s", timeout))
+}
@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/config"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+	"github.com/avivsinai/agent-message-queue/internal/quota"
+)
+
+func setQuotaConfigForTest(t *testing.T, root string, quotas *config.QuotaConfig) {
+	t.Helper()
+	path := filepath.Join(root, "meta", "config.json")
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Quotas = quotas
+	if err := config.WriteConfig(path, cfg, true); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func sendForQuotaTest(root string, extra ...string) func() error {
+	return func() error {
+		return runSend(append([]string{
+			"--root", root,
+			"--me", "codex",
+			"--to", "claude",
+			"--body", "hello",
+		}, extra...))
+	}
+}
+
+func TestSendRefusesOverQuotaWithTypedExitCode(t *testing.T) {
+	root := initializedSendMailboxRoot(t, "claude", "codex")
+	setQuotaConfigForTest(t, root, &config.QuotaConfig{
+		Agents: map[string]config.QuotaLimits{"claude": {MaxUndrained: 1}},
+	})
+
+	if _, _, err := captureEnvOutput(t, sendForQuotaTest(root)); err != nil {
+		t.Fatalf("first send: %v", err)
+	}
+	_, _, err := captureEnvOutput(t, sendForQuotaTest(root))
+	if code := GetExitCode(err); code != ExitQuotaExceeded {
+		t.Fatalf("second send exit = %d (%v), want %d", code, err, ExitQuotaExceeded)
+	}
+	entries, err := os.ReadDir(fsq.AgentInboxNew(root, "claude"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("inbox has %d messages, want 1", len(entries))
+	}
+
+	oldInterval := quota.PollInterval
+	quota.PollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { quota.PollInterval = oldInterval })
+	_, _, err = captureEnvOutput(t, sendForQuotaTest(root, "--on-full", "wait", "--on-full-timeout", "50ms"))
+	if code := GetExitCode(err); code != ExitQuotaExceeded {
+		t.Fatalf("wait send exit = %d (%v), want %d", code, err, ExitQuotaExceeded)
+	}
+}
+
+func TestSendOnFullDropLowDiscardsOnlyLowPriority(t *testing.T) {
+	root := initializedSendMailboxRoot(t, "claude", "codex")
+	setQuotaConfigForTest(t, root, &config.QuotaConfig{
+		Default: config.QuotaLimits{MaxUndrained: 1},
+	})
+	if _, _, err := captureEnvOutput(t, sendForQuotaTest(root)); err != nil {
+		t.Fatalf("first send: %v", err)
+	}
+
+	stdout, _, err := captureEnvOutput(t, sendForQuotaTest(root, "--on-full", "drop-low", "--priority", "low", "--json"))
+	if err != nil {
+		t.Fatalf("drop-low send: %v", err)
+	}
+	var out struct {
+		Dropped bool `json:"dropped"`
+	}
+	if err := unmarshalJSONOutput(stdout, &out); err != nil || !out.Dropped {
+		t.Fatalf("drop-low output = %q (%v), want dropped", stdout, err)
+	}
+
+	_, _, err = captureEnvOutput(t, sendForQuotaTest(root, "--on-full", "drop-low", "--priority", "urgent"))
+	if code := GetExitCode(err); code != ExitQuotaExceeded {
+		t.Fatalf("urgent drop-low send exit = %d (%v), want %d", code, err, ExitQuotaExceeded)
+	}
+}
+
+func TestRunOpsChecks_QuotaHints(t *testing.T) {
+	root := initializedSendMailboxRoot(t, "claude", "codex")
+	setQuotaConfigForTest(t, root, &config.QuotaConfig{
+		Default: config.QuotaLimits{MaxUndrained: 5},
+		Agents:  map[string]config.QuotaLimits{"codex": {MaxUndrained: 1}},
+	})
+	for i := 0; i < 4; i++ {
+		path := filepath.Join(fsq.AgentInboxNew(root, "claude"), "m"+string(rune('a'+i))+".md")
+		if err := os.WriteFile(path, []byte("x"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(fsq.AgentInboxNew(root, "codex"), "m.md"), []byte("x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	result := runOpsChecks(root, "test", false)
+	if _, ok := findOpsHint(result.Hints, "quota_near_limit"); !ok {
+		t.Fatalf("missing quota_near_limit hint: %+v", result.Hints)
+	}
+	if _, ok := findOpsHint(result.Hints, "quota_full"); !ok {
+		t.Fatalf("missing quota_full hint: %+v", result.Hints)
+	}
+	for _, agent := range result.Agents {
+		if agent.Quota == nil {
+			t.Fatalf("agent %s missing quota report", agent.Handle)
+		}
+	}
+}
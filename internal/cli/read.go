@@ -62,6 +62,9 @@ func runRead(args []string) error {
 	if err := requireMailboxDeliveryRoot(deliveryRoot, root, me); err != nil {
 		return err
 	}
+	if err := refuseQueueHandle(deliveryRoot, "read", me); err != nil {
+		return err
+	}
 
 	// Validate handle against config.json
 	if err := validateKnownHandlesDeliveryRoot(deliveryRoot, common.Strict, me); err != nil {
@@ -104,7 +107,7 @@ func runRead(args []string) error {
 		}
 		return readErr
 	}
-	if err := validator.validate(msg.Header); err != nil {
+	if err := validator.Validate(msg.Header); err != nil {
 		readErr := fmt.Errorf("invalid message header %s: %w", *idFlag, err)
 		if box == fsq.BoxNew {
 			item, transitionErr := moveReadFailureToDLQ(
@@ -5,14 +5,18 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"time"
 
+	"github.com/avivsinai/agent-message-queue/internal/bridge"
 	"github.com/avivsinai/agent-message-queue/internal/receipt"
 )
 
 var validStages = map[string]bool{
-	receipt.StageDrained: true,
-	receipt.StageDLQ:     true,
+	receipt.StageDrained:   true,
+	receipt.StageDLQ:       true,
+	receipt.StageClaimed:   true,
+	receipt.StageCompleted: true,
 }
 
 func validateStage(stage string) error {
@@ -20,7 +24,7 @@ func validateStage(stage string) error {
 		return nil
 	}
 	if !validStages[stage] {
-		return fmt.Errorf("invalid stage %q (valid: drained, dlq)", stage)
+		return fmt.Errorf("invalid stage %q (valid: drained, dlq, claimed, completed)", stage)
 	}
 	return nil
 }
@@ -48,7 +52,7 @@ func runReceiptsList(args []string) error {
 	fs := flag.NewFlagSet("receipts list", flag.ContinueOnError)
 	common := addCommonFlags(fs)
 	msgID := fs.String("msg-id", "", "Filter by message ID")
-	stage := fs.String("stage", "", "Filter by stage (drained, dlq)")
+	stage := fs.String("stage", "", "Filter by stage (drained, dlq, claimed, completed)")
 
 	usage := usageWithFlags(fs, "amq receipts list --me <agent> [--msg-id <id>] [--stage <stage>] [options]")
 	if handled, err := parseFlags(fs, args, usage); err != nil {
@@ -110,22 +114,30 @@ func runReceiptsWait(args []string) error {
 	fs := flag.NewFlagSet("receipts wait", flag.ContinueOnError)
 	common := addCommonFlags(fs)
 	msgID := fs.String("msg-id", "", "Message ID to wait for (required)")
-	stage := fs.String("stage", receipt.StageDrained, "Stage to wait for (drained, dlq)")
+	stage := fs.String("stage", receipt.StageDrained, "Stage to wait for (drained, dlq, claimed, completed)")
 	timeoutFlag := fs.Duration("timeout", 60*time.Second, "Maximum time to wait (0 = wait forever)")
 	pollInterval := fs.Duration("poll-interval", 1*time.Second, "Polling interval")
+	remote := fs.String("remote", "", "Wait for a bridged consumer's receipt, as host/agent, instead of --me")
 
-	usage := usageWithFlags(fs, "amq receipts wait --me <agent> --msg-id <id> [--stage <stage>] [--timeout <duration>] [options]")
+	usage := usageWithFlags(fs, "amq receipts wait (--me <agent> | --remote <host/agent>) --msg-id <id> [--stage <stage>] [--timeout <duration>] [options]")
 	if handled, err := parseFlags(fs, args, usage); err != nil {
 		return err
 	} else if handled {
 		return nil
 	}
-	if err := requireMe(common.Me); err != nil {
-		return err
-	}
-	me, err := normalizeHandle(common.Me)
-	if err != nil {
-		return UsageError("--me: %v", err)
+	var host, me string
+	var err error
+	if *remote != "" {
+		if host, me, err = bridge.ParseAlias(*remote); err != nil {
+			return UsageError("--remote: %v", err)
+		}
+	} else {
+		if err := requireMe(common.Me); err != nil {
+			return err
+		}
+		if me, err = normalizeHandle(common.Me); err != nil {
+			return UsageError("--me: %v", err)
+		}
 	}
 	if *msgID == "" {
 		return UsageError("--msg-id is required")
@@ -133,6 +145,9 @@ func runReceiptsWait(args []string) error {
 	if err := validateStage(*stage); err != nil {
 		return UsageError("--stage: %v", err)
 	}
+	if host != "" && *stage != receipt.StageDrained && *stage != receipt.StageDLQ {
+		return UsageError("--stage: amq-bridge forwards only drained and dlq receipts")
+	}
 	if *timeoutFlag < 0 {
 		return UsageError("--timeout must be >= 0")
 	}
@@ -141,7 +156,15 @@ func runReceiptsWait(args []string) error {
 	}
 	root := resolveRoot(common.Root)
 
-	r, err := receipt.WaitFor(root, *msgID, me, *stage, *timeoutFlag, *pollInterval)
+	var r receipt.Receipt
+	if host != "" {
+		// amq-bridge records a remote consumer's receipt on the sending host
+		// under bridge/remote-receipts, not in a local agent's namespace.
+		path := filepath.Join(root, bridge.RemoteReceiptPath(host, me, *msgID, *stage))
+		r, err = receipt.WaitForPath(path, *timeoutFlag, *pollInterval)
+	} else {
+		r, err = receipt.WaitFor(root, *msgID, me, *stage, *timeoutFlag, *pollInterval)
+	}
 	if errors.Is(err, os.ErrDeadlineExceeded) {
 		if common.JSON {
 			if err := writeJSON(os.Stdout, receiptsWaitResult{Event: "timeout"}); err != nil {
@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"io"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/avivsinai/agent-message-queue/internal/bridge"
 	"github.com/avivsinai/agent-message-queue/internal/format"
 	"github.com/avivsinai/agent-message-queue/internal/fsq"
 	"github.com/avivsinai/agent-message-queue/internal/receipt"
@@ -191,6 +193,48 @@ func TestReceiptsWaitDelayed(t *testing.T) {
 	}
 }
 
+func TestReceiptsWaitRemoteReadsBridgedReceipt(t *testing.T) {
+	root := setupReceiptsTestRoot(t)
+
+	// A local alice receipt must not satisfy a wait on mac/alice.
+	if err := receipt.Emit(root, receipt.New("msg-r-001", "", "bob", "alice", receipt.StageDrained, "")); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		data, err := receipt.New("msg-r-001", "", "bob", "alice", receipt.StageDrained, "amq-bridge: drained on mac").Marshal()
+		if err != nil {
+			return
+		}
+		rel := bridge.RemoteReceiptPath("mac", "alice", "msg-r-001", receipt.StageDrained)
+		_, _ = fsq.WriteFileAtomic(filepath.Join(root, filepath.Dir(rel)), filepath.Base(rel), data, 0o600)
+	}()
+
+	stdout, _ := captureOutput(t, func() error {
+		return runReceiptsWait([]string{
+			"--remote", "mac/alice", "--root", root,
+			"--msg-id", "msg-r-001", "--stage", "drained",
+			"--timeout", "5s", "--poll-interval", "100ms", "--json",
+		})
+	})
+
+	var result receiptsWaitResult
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("unmarshal: %v\nstdout: %s", err, stdout)
+	}
+	if result.Event != "matched" || result.Receipt == nil || result.Receipt.Detail != "amq-bridge: drained on mac" {
+		t.Fatalf("remote wait = %+v, want mac/alice's bridged receipt", result)
+	}
+
+	err := runReceiptsWait([]string{
+		"--remote", "mac/alice", "--root", root,
+		"--msg-id", "msg-r-001", "--stage", "claimed", "--timeout", "1ms",
+	})
+	if GetExitCode(err) != ExitUsage {
+		t.Fatalf("remote claimed wait exit = %d (%v), want usage", GetExitCode(err), err)
+	}
+}
+
 // captureOutput redirects stdout/stderr for a function call and returns both.
 func captureOutput(t *testing.T, fn func() error) (string, string) {
 	t.Helper()
@@ -38,20 +38,24 @@ func init() {
 			Description: "Agent presence metadata",
 			LongDescription: []string{
 				"Set or inspect agent availability, status, and optional notes.",
+				"Derived status (active, idle, away, stale, offline) comes from presence age and notifier liveness.",
 			},
 			Examples: []string{
 				"amq presence set --me claude --status busy --note \"reviewing PR\"",
 				"amq presence list --json",
+				"amq presence watch --json",
 			},
 			Handler: runPresence,
 			Children: []CommandInfo{
 				{Name: "set", Summary: "Update presence status", Handler: runPresenceSet},
 				{Name: "list", Summary: "List presence data", Handler: runPresenceList},
+				{Name: "watch", Summary: "Stream presence and derived status changes", Handler: runPresenceWatch},
 			},
 		},
 		{Name: "cleanup", Summary: "Remove selected tmp, wake quarantine, or launch recovery artifacts", Handler: runCleanup},
 		{Name: "watch", Summary: "Wait for new messages (uses fsnotify)", Handler: runWatch},
 		{Name: "drain", Summary: "Drain new messages (read, move to cur, emit receipts)", Handler: runDrain},
+		{Name: "claim", Summary: "Claim or complete an item from a shared queue handle", Handler: runClaim},
 		{Name: "monitor", Summary: "Combined watch+drain for co-op mode", Handler: runMonitor},
 		{Name: "reply", Summary: "Reply to a message (auto thread/refs)", Handler: runReply},
 		{
@@ -64,6 +68,23 @@ func init() {
 				{Name: "read", Summary: "Read a DLQ message with failure info", Handler: runDLQRead},
 				{Name: "retry", Summary: "Retry a DLQ message (move back to inbox)", Handler: runDLQRetry},
 				{Name: "purge", Summary: "Permanently remove DLQ messages", Handler: runDLQPurge},
+				{Name: "fix", Summary: "Repair a DLQ message and deliver it as a new revision", Handler: runDLQFix},
+			},
+		},
+		{
+			Name:        "breaker",
+			Summary:     "Per-thread loop breaker (list, reset)",
+			Description: "Inspect and reset ping-pong loop breakers",
+			LongDescription: []string{
+				"send and reply trip a thread's breaker on rapid alternating sends,",
+				"repeated near-identical bodies, or an overlong thread. While open, new",
+				"messages in the thread are held and the human handle is notified.",
+				"Thresholds live under \"breaker\" in meta/config.json.",
+			},
+			Handler: runBreaker,
+			Children: []CommandInfo{
+				{Name: "list", Summary: "List thread breakers and held messages", Handler: runBreakerList},
+				{Name: "reset", Summary: "Close a breaker and release or discard held messages", Handler: runBreakerReset},
 			},
 		},
 		{
@@ -170,23 +191,32 @@ func init() {
 		},
 		{
 			Name:        "session",
-			Summary:     "Create, list, and resume named AMQ sessions",
+			Summary:     "Create, list, resume, and retire named AMQ sessions",
 			Description: "Named session lifecycle",
 			LongDescription: []string{
 				"session create provisions a canonical named session and its roster mailboxes.",
 				"session list reports canonical sessions and safe legacy roots.",
 				"session resume uses the same fail-closed reconciliation engine as amq launch.",
+				"session archive, rm, and rename refuse while a wake lock or launch binding is live.",
+				"session fork seeds a new session with selected threads as read history.",
 			},
 			Examples: []string{
 				"amq session create feature-x",
 				"amq session list --json",
 				"amq session resume feature-x --json",
+				"amq session archive feature-x",
+				"amq session rename feature-x auth-rework",
+				"amq session fork auth-rework auth-alt --threads p2p/claude__codex",
 			},
 			Handler: runSession,
 			Children: []CommandInfo{
 				{Name: "create", Summary: "Create a named session and its roster mailboxes", Handler: runSessionCreate},
 				{Name: "list", Summary: "List sessions under the base root", Handler: runSessionList},
 				{Name: "resume", Summary: "Resume an existing session through launch reconciliation", Handler: runSession},
+				{Name: "archive", Summary: "Pack a session into an archive and remove it", Handler: runSessionArchive},
+				{Name: "rm", Summary: "Delete a session that has no live wake or launch binding", Handler: runSessionRm},
+				{Name: "rename", Summary: "Rename a session and carry its launch journal and reply routing", Handler: runSessionRename},
+				{Name: "fork", Summary: "Create a session seeded with threads from another", Handler: runSessionFork},
 			},
 		},
 		{Name: "who", Summary: "Show sessions and agents in current project", Handler: runWho},
@@ -203,7 +233,31 @@ func init() {
 				{Name: "explain", Summary: "Explain a send route as canonical JSON", Handler: runRouteExplain},
 			},
 		},
+		{
+			Name:        "peers",
+			Summary:     "Manage the user-level peer project registry",
+			Description: "Register project names to base roots for cross-project routing",
+			LongDescription: []string{
+				"amq setup and amq coop init register the current project automatically.",
+				"Peers listed in a project's .amqrc take precedence over registry entries.",
+			},
+			Examples: []string{
+				"amq peers list",
+				"amq peers add infra-lib ~/src/infra-lib",
+				"amq peers prune --dry-run",
+			},
+			Handler: runPeers,
+			Children: []CommandInfo{
+				{Name: "list", Summary: "List .amqrc and registry peers", Handler: runPeersList},
+				{Name: "add", Summary: "Register a peer project and its base root", Handler: runPeersAdd},
+				{Name: "rm", Summary: "Remove a peer from the registry", Handler: runPeersRm},
+				{Name: "prune", Summary: "Remove registry entries whose root is gone", Handler: runPeersPrune},
+			},
+		},
 		{Name: "doctor", Summary: "Verify installation and configuration", Handler: runDoctor},
+		{Name: "fsck", Summary: "Audit message content integrity and plan repairs", Handler: runFsck},
+		{Name: "backup", Summary: "Archive a mailbox root with a digest manifest", Handler: runBackup},
+		{Name: "restore", Summary: "Verify a backup and restore it into an empty root", Handler: runRestore},
 		{Name: "shell-setup", Summary: "Output shell aliases (amc/amx/amg)", Handler: runShellSetup},
 		// Handler is nil to avoid an init cycle (runCompletion references commands).
 		// Dispatch is handled by commandHandlers in cli.go.
@@ -223,6 +277,7 @@ var usageEnvironment = []string{
 	"  AM_BASE_ROOT_ID     Opaque physical identity token for AM_BASE_ROOT when available",
 	"  AM_SESSION          Pinned session identity (empty means exact-root context)",
 	"  AMQ_GLOBAL_ROOT     Global root fallback (for agents spawned by external orchestrators)",
+	"  AMQ_PROJECT_REGISTRY  Peer project registry file (default ~/.amq/projects.json)",
 	"  AMQ_NO_UPDATE_CHECK  Disable update check (1/true/yes/on)",
 	"  AMQ_WAKE_NO_SELF_UPGRADE  Disable automatic wake self-upgrade (1/true/yes/on)",
 }
@@ -235,6 +290,8 @@ var usageExitCodes = []string{
 	"  4  Timeout",
 	"  5  Context mismatch",
 	"  6  Action required",
+	"  7  Quota exceeded",
+	"  8  Delivery refused by recipient ACL",
 }
 
 func runUpgradeRegistry(args []string) error {
@@ -22,9 +22,11 @@ func TestCommandNames(t *testing.T) {
 		"cleanup",
 		"watch",
 		"drain",
+		"claim",
 		"monitor",
 		"reply",
 		"dlq",
+		"breaker",
 		"wake",
 		"upgrade",
 		"env",
@@ -35,7 +37,11 @@ func TestCommandNames(t *testing.T) {
 		"session",
 		"who",
 		"route",
+		"peers",
 		"doctor",
+		"fsck",
+		"backup",
+		"restore",
 		"shell-setup",
 		"completion",
 	}
@@ -86,14 +92,15 @@ func TestChildNames(t *testing.T) {
 		name string
 		want []string
 	}{
-		{name: "presence", want: []string{"set", "list"}},
-		{name: "dlq", want: []string{"list", "read", "retry", "purge"}},
+		{name: "presence", want: []string{"set", "list", "watch"}},
+		{name: "dlq", want: []string{"list", "read", "retry", "purge", "fix"}},
 		{name: "wake", want: []string{"check", "repair", "restart", "recover-owner", "retire"}},
 		{name: "coop", want: []string{"init", "exec"}},
 		{name: "swarm", want: []string{"list", "join", "leave", "tasks", "claim", "complete", "fail", "block", "bridge"}},
 		{name: "receipts", want: []string{"list", "wait"}},
-		{name: "session", want: []string{"create", "list", "resume"}},
+		{name: "session", want: []string{"create", "list", "resume", "archive", "rm", "rename", "fork"}},
 		{name: "route", want: []string{"explain"}},
+		{name: "peers", want: []string{"list", "add", "rm", "prune"}},
 	}
 
 	for _, tt := range tests {
@@ -177,7 +184,7 @@ func TestGroupUsageLinesPresence(t *testing.T) {
 	if !containsLine(lines, "amq presence - Agent presence metadata") {
 		t.Fatal("groupUsageLines(presence) missing header")
 	}
-	if !containsLine(lines, "set   Update presence status") {
+	if !containsLine(lines, "set    Update presence status") {
 		t.Fatal("groupUsageLines(presence) missing set subcommand")
 	}
 	if !containsLine(lines, `amq presence set --me claude --status busy --note "reviewing PR"`) {
@@ -220,7 +227,7 @@ func TestPrintUsageRegistry(t *testing.T) {
 	if !strings.Contains(output, "swarm        Claude Code Agent Teams integration") {
 		t.Fatalf("printUsageRegistry output missing swarm command:\n%s", output)
 	}
-	if !strings.Contains(output, "session") || !strings.Contains(output, "Create, list, and resume named AMQ sessions") {
+	if !strings.Contains(output, "session") || !strings.Contains(output, "Create, list, resume, and retire named AMQ sessions") {
 		t.Fatalf("printUsageRegistry output missing session command:\n%s", output)
 	}
 	if !strings.Contains(output, "Exit codes:") {
@@ -255,15 +262,18 @@ func TestPrintGroupUsageSession(t *testing.T) {
 	if !strings.Contains(output, "amq session - Named session lifecycle") {
 		t.Fatalf("printGroupUsage(session) missing header:\n%s", output)
 	}
-	if !strings.Contains(output, "create  Create a named session and its roster mailboxes") {
+	if !strings.Contains(output, "create   Create a named session and its roster mailboxes") {
 		t.Fatalf("printGroupUsage(session) missing create:\n%s", output)
 	}
-	if !strings.Contains(output, "list    List sessions under the base root") {
+	if !strings.Contains(output, "list     List sessions under the base root") {
 		t.Fatalf("printGroupUsage(session) missing list:\n%s", output)
 	}
-	if !strings.Contains(output, "resume  Resume an existing session through launch reconciliation") {
+	if !strings.Contains(output, "resume   Resume an existing session through launch reconciliation") {
 		t.Fatalf("printGroupUsage(session) missing resume:\n%s", output)
 	}
+	if !strings.Contains(output, "archive  Pack a session into an archive and remove it") {
+		t.Fatalf("printGroupUsage(session) missing archive:\n%s", output)
+	}
 	if findChild(findCommand("session"), "resume") == nil {
 		t.Fatal("session must register a resume subcommand")
 	}
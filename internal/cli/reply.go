@@ -9,6 +9,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/avivsinai/agent-message-queue/internal/acl"
+	"github.com/avivsinai/agent-message-queue/internal/delivery"
 	"github.com/avivsinai/agent-message-queue/internal/format"
 	"github.com/avivsinai/agent-message-queue/internal/fsq"
 	"github.com/avivsinai/agent-message-queue/internal/presence"
@@ -30,6 +32,8 @@ func runReply(args []string) error {
 	contextFlag := fs.String("context", "", "JSON context object or @file.json")
 	waitForFlag := fs.String("wait-for", "", "Wait for receipt stage after reply (e.g., drained)")
 	waitTimeoutFlag := fs.Duration("wait-timeout", 120*time.Second, "Timeout for --wait-for")
+	onFullFlag := fs.String("on-full", onFullFail, onFullFlagUsage)
+	onFullTimeoutFlag := fs.Duration("on-full-timeout", 60*time.Second, "Timeout for --on-full wait (0 = wait forever)")
 	ignoreSessionPinFlag := fs.Bool("ignore-session-pin", false, "With explicit --root, ignore a conflicting AM_SESSION source pin")
 
 	usage := usageWithFlags(fs, "amq reply --me <agent> --id <msg_id> [options]",
@@ -52,6 +56,10 @@ func runReply(args []string) error {
 		return UsageError("--me: %v", err)
 	}
 	common.Me = me
+	onFull, err := parseOnFull(*onFullFlag)
+	if err != nil {
+		return err
+	}
 	common.warnRootOverride()
 	root := resolveRoot(common.Root)
 	if err := validatePinOverride(common, *ignoreSessionPinFlag, false); err != nil {
@@ -165,7 +173,7 @@ func runReply(args []string) error {
 			return err
 		}
 		recipient = recipientNorm
-		targetSession = sessionNorm
+		targetSession = followSessionRename(root, sessionNorm)
 	} else {
 		// Local reply: send to original sender in current session.
 		rawRecipient := originalMsg.Header.From
@@ -371,26 +379,80 @@ func runReply(args []string) error {
 		Body: body,
 	}
 
-	data, err := msg.Marshal()
-	if err != nil {
-		return err
-	}
-	if localMailboxAuthorization != nil {
-		if err := prepareLocalSendMailboxes(
-			deliveryFS,
-			localMailboxAuthorization,
-			deliveryRoot,
-			[]string{recipient},
-		); err != nil {
+	// ACLs, quotas, and loop thresholds come from the destination's config.
+	quotaConfigFS := peerConfigFS
+	if quotaConfigFS == nil {
+		configBase, expectedBaseRootID := localMailboxConfigAuthority(deliveryRoot, pin, *ignoreSessionPinFlag)
+		selection, err := openMailboxConfigSelection(deliveryFS, deliveryRoot, configBase, expectedBaseRootID)
+		if err != nil {
 			return err
 		}
-		if err := localMailboxAuthorization.Verify(); err != nil {
-			return fmt.Errorf("destination mailbox authorization changed before delivery: %w", err)
-		}
+		defer selection.Close()
+		quotaConfigFS = selection.ConfigFS
 	}
 
 	filename := id + ".md"
-	if targetProject != "" {
+	var hops []delivery.Hop
+	var outboxErr error
+	if targetProject == "" {
+		ctx, cancel := onFullContext(*onFullTimeoutFlag)
+		defer cancel()
+		result, err := delivery.Local{
+			ConfigFS:   quotaConfigFS,
+			DeliveryFS: deliveryFS,
+			SourceFS:   sourceFS,
+			OnFull:     onFull,
+			Prepare: func() error {
+				if localMailboxAuthorization != nil {
+					if err := prepareLocalSendMailboxes(
+						deliveryFS,
+						localMailboxAuthorization,
+						deliveryRoot,
+						[]string{recipient},
+					); err != nil {
+						return err
+					}
+					if err := localMailboxAuthorization.Verify(); err != nil {
+						return fmt.Errorf("destination mailbox authorization changed before delivery: %w", err)
+					}
+				}
+				if targetSession != "" {
+					return sourceFS.VerifyBase()
+				}
+				return nil
+			},
+		}.Deliver(ctx, msg)
+		reportDeliveryWarnings(result.Warnings)
+		if err != nil {
+			return localDeliveryError(id, err)
+		}
+		if result.Dropped {
+			return reportDroppedSend(common.JSON, id, []string{recipient})
+		}
+		if result.Held {
+			return reportHeldSend(common.JSON, id, []string{recipient}, result.Breaker)
+		}
+		hops, outboxErr = result.Hops, result.OutboxErr
+	} else {
+		data, err := msg.Marshal()
+		if err != nil {
+			return err
+		}
+		if err := authorizeDelivery(quotaConfigFS, msg.Header); err != nil {
+			return err
+		}
+		// Away recipients in the peer root forward as they would locally.
+		delegation, err := delivery.PlanDelegation(quotaConfigFS, deliveryFS, msg.Header, now)
+		if err != nil {
+			return err
+		}
+		dropped, err := admitDelivery(quotaConfigFS, deliveryFS, onFull, me, delegation.All, int64(len(data)), priority, *onFullTimeoutFlag)
+		if err != nil {
+			return err
+		}
+		if dropped {
+			return reportDroppedSend(common.JSON, id, []string{recipient})
+		}
 		currentSourceAgents, currentSourceAgentsErr := revalidateSourceAgentsForSend(
 			sourceConfigFS,
 			sourceConfigPresent,
@@ -420,28 +482,42 @@ func runReply(args []string) error {
 		if err := sourceFS.VerifyBase(); err != nil {
 			return err
 		}
-		// Cross-project: use DeliverToExistingInbox (never creates dirs in peer).
-		if _, err := fsq.DeliverToExistingInbox(deliveryFS, recipient, filename, data); err != nil {
-			return reportDeliveryError(id, err)
+		decision, warning, err := delivery.AdmitBreaker(quotaConfigFS, deliveryFS, msg, filename, data, now)
+		if warning != "" {
+			reportDeliveryWarnings([]string{warning})
 		}
-	} else {
-		if targetSession != "" {
-			if err := sourceFS.VerifyBase(); err != nil {
-				return err
+		if err != nil {
+			return err
+		}
+		if decision.Held {
+			return reportHeldSend(common.JSON, id, []string{recipient}, decision)
+		}
+		// Cross-project: use DeliverToExistingInbox (never creates dirs in peer).
+		if len(delegation.Direct) > 0 {
+			if _, err := acl.DeliverToExistingInbox(quotaConfigFS, deliveryFS, msg.Header, recipient, filename, data); err != nil {
+				return reportDeliveryError(id, err)
 			}
 		}
-		if _, err := fsq.DeliverToInboxes(deliveryFS, []string{recipient}, filename, data); err != nil {
+		if err := delegation.DeliverCopies(quotaConfigFS, deliveryFS, msg, filename); err != nil {
 			return reportDeliveryError(id, err)
 		}
-	}
+		hops = delegation.Hops
 
-	// Best-effort presence touch.
-	_ = presence.TouchDeliveryRoot(sourceFS, me)
+		// Best-effort presence touch.
+		_ = presence.ReturnDeliveryRoot(sourceFS, me)
+		reportDeliveryWarnings(delegation.SendAwayReplies(
+			sourceConfigFS,
+			sourceFS,
+			deliveryFS,
+			msg,
+			delivery.ReplyRoute{Project: targetProject, Session: targetSession},
+			now,
+		))
 
-	outboxDir := filepath.Join("agents", me, "outbox", "sent")
-	outboxErr := error(nil)
-	if _, err := sourceFS.WriteFileAtomic(outboxDir, filename, data, 0o600); err != nil {
-		outboxErr = err
+		outboxDir := filepath.Join("agents", me, "outbox", "sent")
+		if _, err := sourceFS.WriteFileAtomic(outboxDir, filename, data, 0o600); err != nil {
+			outboxErr = err
+		}
 	}
 
 	// Wait for receipt if requested (mirrors amq send --wait-for).
@@ -491,6 +567,9 @@ func runReply(args []string) error {
 		if waitResult != nil {
 			out["wait"] = waitResult
 		}
+		if len(hops) > 0 {
+			out["delegated"] = hops
+		}
 		if err := writeJSON(os.Stdout, out); err != nil {
 			return err
 		}
@@ -500,6 +579,9 @@ func runReply(args []string) error {
 	if outboxErr != nil {
 		_ = reportOutboxError(outboxErr)
 	}
+	if err := reportDelegationHops(hops); err != nil {
+		return err
+	}
 	if waitResult != nil {
 		switch waitResult.Event {
 		case "matched":
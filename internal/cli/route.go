@@ -7,21 +7,26 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/avivsinai/agent-message-queue/internal/acl"
+	"github.com/avivsinai/agent-message-queue/internal/config"
 	"github.com/avivsinai/agent-message-queue/internal/fsq"
 )
 
 type routeExplainResult struct {
-	SchemaVersion  int      `json:"schema_version"`
-	Routable       bool     `json:"routable"`
-	Argv           []string `json:"argv"`
-	DisplayCommand string   `json:"display_command"`
-	SourceRoot     string   `json:"source_root"`
-	DeliveryRoot   string   `json:"delivery_root"`
-	SourceProject  string   `json:"source_project"`
-	TargetProject  string   `json:"target_project"`
-	SourceSession  string   `json:"source_session"`
-	TargetSession  string   `json:"target_session"`
-	Error          string   `json:"error,omitempty"`
+	SchemaVersion  int             `json:"schema_version"`
+	Routable       bool            `json:"routable"`
+	Argv           []string        `json:"argv"`
+	DisplayCommand string          `json:"display_command"`
+	SourceRoot     string          `json:"source_root"`
+	DeliveryRoot   string          `json:"delivery_root"`
+	SourceProject  string          `json:"source_project"`
+	TargetProject  string          `json:"target_project"`
+	SourceSession  string          `json:"source_session"`
+	TargetSession  string          `json:"target_session"`
+	PeerSource     string          `json:"peer_source,omitempty"`
+	ACL            *routeACL       `json:"acl,omitempty"`
+	Capability     *capabilityPick `json:"capability,omitempty"`
+	Error          string          `json:"error,omitempty"`
 }
 
 func runRoute(args []string) error {
@@ -40,6 +45,8 @@ func runRoute(args []string) error {
 func runRouteExplain(args []string) error {
 	fs := flag.NewFlagSet("route explain", flag.ContinueOnError)
 	toFlag := fs.String("to", "", "Receiver handle")
+	toCapabilityFlag := fs.String("to-capability", "", "Explain which live agent a --to-capability send would pick")
+	pickFlag := fs.String("pick", capabilityPickLeastLoaded, "Choice among --to-capability matches: least-loaded, round-robin")
 	projectFlag := fs.String("project", "", "Target peer project name")
 	sessionFlag := fs.String("session", "", "Target session")
 	fromRootFlag := fs.String("from-root", "", "Source AMQ root to explain from")
@@ -54,6 +61,7 @@ func runRouteExplain(args []string) error {
 		"Examples:",
 		"  amq route explain --to codex --json",
 		"  amq route explain --to qa --project project-b --session qa --json",
+		"  amq route explain --to-capability go --pick round-robin --json",
 	)
 	if handled, err := parseFlags(fs, args, usage); err != nil {
 		return err
@@ -76,21 +84,25 @@ func runRouteExplain(args []string) error {
 	defer restore()
 
 	result := explainRoute(routeExplainOptions{
-		To:       *toFlag,
-		Project:  *projectFlag,
-		Session:  *sessionFlag,
-		FromRoot: firstNonEmpty(*fromRootFlag, *rootFlag),
-		Me:       *meFlag,
+		To:           *toFlag,
+		ToCapability: *toCapabilityFlag,
+		Pick:         *pickFlag,
+		Project:      *projectFlag,
+		Session:      *sessionFlag,
+		FromRoot:     firstNonEmpty(*fromRootFlag, *rootFlag),
+		Me:           *meFlag,
 	})
 	return writeJSON(os.Stdout, result)
 }
 
 type routeExplainOptions struct {
-	To       string
-	Project  string
-	Session  string
-	FromRoot string
-	Me       string
+	To           string
+	ToCapability string
+	Pick         string
+	Project      string
+	Session      string
+	FromRoot     string
+	Me           string
 }
 
 func explainRoute(opts routeExplainOptions) routeExplainResult {
@@ -126,17 +138,31 @@ func explainRoute(opts routeExplainOptions) routeExplainResult {
 	result.TargetProject = targetProject
 	result.TargetSession = targetSession
 
-	recipients, err := splitRecipients(rawTo)
-	if err != nil {
-		result.Error = fmt.Sprintf("--to: %v", err)
-		return result
-	}
-	recipients = dedupeStrings(recipients)
-	if len(recipients) != 1 {
-		result.Error = fmt.Sprintf("--to requires exactly one recipient for route explain (got %d)", len(recipients))
-		return result
+	toCapability := strings.TrimSpace(opts.ToCapability)
+	target := ""
+	pick := ""
+	if toCapability != "" {
+		if rawTo != "" {
+			result.Error = "--to and --to-capability are mutually exclusive"
+			return result
+		}
+		if pick, err = parseCapabilityPick(opts.Pick); err != nil {
+			result.Error = err.Error()
+			return result
+		}
+	} else {
+		recipients, err := splitRecipients(rawTo)
+		if err != nil {
+			result.Error = fmt.Sprintf("--to: %v", err)
+			return result
+		}
+		recipients = dedupeStrings(recipients)
+		if len(recipients) != 1 {
+			result.Error = fmt.Sprintf("--to requires exactly one recipient for route explain (got %d)", len(recipients))
+			return result
+		}
+		target = recipients[0]
 	}
-	target := recipients[0]
 
 	// Validate the source context exactly as the emitted send will. An explicit
 	// --from-root confirms the cwd choice, but it does not waive an active pin or
@@ -152,16 +178,42 @@ func explainRoute(opts routeExplainOptions) routeExplainResult {
 	plan, err := planDeliveryRoute(sourceRoot, targetProject, targetSession, deliveryRouteOptions{
 		MirrorPeerSession: true,
 	})
+	result.PeerSource = plan.PeerSource
 	if err != nil {
 		result.TargetSession = plan.TargetSession
 		result.Error = err.Error()
 		return result
 	}
+	if toCapability != "" {
+		exclude := ""
+		if targetProject == "" && targetSession == "" {
+			exclude = me
+		}
+		var decision capabilityPick
+		err := withRouteConfig(plan, func(configFS, deliveryFS *fsq.DeliveryRoot) error {
+			var err error
+			decision, err = pickCapabilityAgent(configFS, deliveryFS, toCapability, pick, exclude)
+			return err
+		})
+		result.Capability = &decision
+		if err != nil {
+			result.TargetSession = plan.TargetSession
+			result.Error = err.Error()
+			return result
+		}
+		target = decision.Chosen
+	}
 	if err := validatePlannedMailbox(plan, target); err != nil {
 		result.TargetSession = plan.TargetSession
 		result.Error = err.Error()
 		return result
 	}
+	result.ACL, err = explainRouteACL(plan, target)
+	if err != nil {
+		result.TargetSession = plan.TargetSession
+		result.Error = err.Error()
+		return result
+	}
 
 	result.Routable = true
 	result.DeliveryRoot = plan.DeliveryRoot
@@ -176,6 +228,10 @@ func explainRoute(opts routeExplainOptions) routeExplainResult {
 		result.TargetSession = result.SourceSession
 	}
 	result.Argv = buildRouteArgv(sourceRoot, me, target, targetProject, plan.TargetSession)
+	if result.Capability != nil {
+		// The emitted send re-evaluates the pick rather than pinning today's choice.
+		result.Argv = withCapabilityRecipient(result.Argv, result.Capability.Capability, pick)
+	}
 	result.DisplayCommand = displayCommand(result.Argv)
 	return result
 }
@@ -211,6 +267,7 @@ func resolveRouteSource(fromRoot, meFlag string) (sourceRoot, me string, err err
 type deliveryRoutePlan struct {
 	DeliveryRoot  string
 	PeerBaseRoot  string
+	PeerSource    string
 	SourceProject string
 	TargetProject string
 	TargetSession string
@@ -240,11 +297,12 @@ func planDeliveryRoute(sourceRoot, targetProject, targetSession string, opts del
 		}
 		plan.SourceProject = sourceProject
 
-		peerBaseRoot, err := resolvePeerFromAmqrcResult(routeConfig, targetProject)
+		peerBaseRoot, peerSource, err := resolvePeerWithSource(routeConfig, targetProject)
 		if err != nil {
 			return plan, err
 		}
 		plan.PeerBaseRoot = peerBaseRoot
+		plan.PeerSource = peerSource
 
 		if plan.TargetSession != "" {
 			normalized, err := normalizeHandle(plan.TargetSession)
@@ -366,6 +424,63 @@ func validatePlannedMailbox(plan deliveryRoutePlan, target string) error {
 	return validateAuthorizedLocalMailbox(root, authorization, target)
 }
 
+// routeACL is the recipient policy a send along this route must satisfy.
+type routeACL struct {
+	// Scope is "agent" for a per-handle policy or "default" for the root-wide one.
+	Scope  string           `json:"scope"`
+	Policy config.ACLPolicy `json:"policy"`
+}
+
+// withRouteConfig opens plan's delivery root and the config authority send
+// would use for it, and runs fn with both.
+func withRouteConfig(plan deliveryRoutePlan, fn func(configFS, deliveryFS *fsq.DeliveryRoot) error) error {
+	identity, err := fsq.SnapshotDeliveryRoot(plan.DeliveryRoot)
+	if err != nil {
+		return err
+	}
+	deliveryFS, err := fsq.OpenDeliveryRoot(plan.DeliveryRoot, identity)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = deliveryFS.Close() }()
+	configBase, expectedBaseRootID := plan.PeerBaseRoot, ""
+	if plan.TargetProject == "" {
+		pin, err := loadSessionPin()
+		if err != nil {
+			return err
+		}
+		configBase, expectedBaseRootID = localMailboxConfigAuthority(plan.DeliveryRoot, pin, false)
+	}
+	selection, err := openMailboxConfigSelection(deliveryFS, plan.DeliveryRoot, configBase, expectedBaseRootID)
+	if err != nil {
+		return err
+	}
+	defer selection.Close()
+	return fn(selection.ConfigFS, deliveryFS)
+}
+
+// explainRouteACL loads the target's ACL from the same config authority send
+// uses. It returns nil when no policy applies.
+func explainRouteACL(plan deliveryRoutePlan, target string) (*routeACL, error) {
+	var cfg *config.ACLConfig
+	if err := withRouteConfig(plan, func(configFS, _ *fsq.DeliveryRoot) error {
+		var err error
+		cfg, err = acl.Load(configFS)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	policy, ok := cfg.For(target)
+	if !ok {
+		return nil, nil
+	}
+	scope := "default"
+	if _, perAgent := cfg.Agents[target]; perAgent {
+		scope = "agent"
+	}
+	return &routeACL{Scope: scope, Policy: policy}, nil
+}
+
 func buildRouteArgv(sourceRoot, me, target, targetProject, targetSession string) []string {
 	// Keep argv self-contained for tooling; display_command is presentation only.
 	argv := []string{"amq", "send", "--root", sourceRoot, "--me", me, "--to", target}
@@ -378,6 +493,21 @@ func buildRouteArgv(sourceRoot, me, target, targetProject, targetSession string)
 	return argv
 }
 
+// withCapabilityRecipient replaces the --to pair in argv with capability
+// routing flags.
+func withCapabilityRecipient(argv []string, capability, pick string) []string {
+	out := make([]string, 0, len(argv)+2)
+	for i := 0; i < len(argv); i++ {
+		if argv[i] == "--to" && i+1 < len(argv) {
+			out = append(out, "--to-capability", capability, "--pick", pick)
+			i++
+			continue
+		}
+		out = append(out, argv[i])
+	}
+	return out
+}
+
 func displayCommand(argv []string) string {
 	parts := make([]string, 0, len(argv))
 	for _, arg := range argv {
@@ -10,6 +10,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/avivsinai/agent-message-queue/internal/acl"
+	"github.com/avivsinai/agent-message-queue/internal/delivery"
 	"github.com/avivsinai/agent-message-queue/internal/format"
 	"github.com/avivsinai/agent-message-queue/internal/fsq"
 	"github.com/avivsinai/agent-message-queue/internal/presence"
@@ -17,7 +19,7 @@ import (
 	"github.com/avivsinai/agent-message-queue/internal/sessionguard"
 )
 
-var deliverToExistingInbox = fsq.DeliverToExistingInbox
+var deliverToExistingInbox = acl.DeliverToExistingInbox
 
 func runSend(args []string) error {
 	return runSendWithAfterBodyRead(args, nil)
@@ -27,6 +29,8 @@ func runSendWithAfterBodyRead(args []string, afterBodyRead func()) error {
 	fs := flag.NewFlagSet("send", flag.ContinueOnError)
 	common := addCommonFlags(fs)
 	toFlag := fs.String("to", "", "Receiver handle (comma-separated)")
+	toCapabilityFlag := fs.String("to-capability", "", "Send to one live agent advertising this capability instead of --to")
+	pickFlag := fs.String("pick", capabilityPickLeastLoaded, "Choice among --to-capability matches: least-loaded, round-robin")
 	subjectFlag := fs.String("subject", "", "Message subject")
 	threadFlag := fs.String("thread", "", "Thread id (required for multiple recipients; default p2p/<a>__<b> for single-recipient sends)")
 	bodyFlag := fs.String("body", "", "Body string, @file, or - / empty to read stdin")
@@ -35,6 +39,8 @@ func runSendWithAfterBodyRead(args []string, afterBodyRead func()) error {
 	refsFlag := fs.String("refs", "", "Comma-separated related message ids")
 	waitForFlag := fs.String("wait-for", "", "Wait for receipt stage after send (drained, dlq)")
 	waitTimeoutFlag := fs.Duration("wait-timeout", 120*time.Second, "Timeout for --wait-for (0 = wait forever)")
+	onFullFlag := fs.String("on-full", onFullFail, onFullFlagUsage)
+	onFullTimeoutFlag := fs.Duration("on-full-timeout", 60*time.Second, "Timeout for --on-full wait (0 = wait forever)")
 
 	// Co-op mode flags
 	priorityFlag := fs.String("priority", "", "Message priority: urgent, normal, low (default: normal if kind set)")
@@ -50,6 +56,9 @@ func runSendWithAfterBodyRead(args []string, afterBodyRead func()) error {
 	// Cross-project flag
 	projectFlag := fs.String("project", "", "Target peer project name (delivers to a peer project's inbox)")
 
+	// Cross-host flag
+	bridgeFlag := fs.String("bridge", "", "Remote host/agent alias; spools the message for amq-bridge instead of delivering locally")
+
 	usage := usageWithFlags(fs, "amq send --me <agent> --to <recipients> [--project <name>] [--session <name>] [--from-session <name>] [options]",
 		"",
 		"Cross-session example:",
@@ -62,6 +71,12 @@ func runSendWithAfterBodyRead(args []string, afterBodyRead func()) error {
 		"Cross-project examples:",
 		"  amq send --to codex --project infra-lib --body \"hello from here\"",
 		"  amq send --to codex@infra-lib:collab --body \"inline syntax\"",
+		"",
+		"Capability routing example:",
+		"  amq send --to-capability go --pick least-loaded --body \"who can take this?\"",
+		"",
+		"Cross-host example (through amq-bridge):",
+		"  amq send --bridge grok/claude --body \"hello\" --wait-for drained",
 	)
 	if handled, err := parseFlags(fs, args, usage); err != nil {
 		return err
@@ -76,9 +91,36 @@ func runSendWithAfterBodyRead(args []string, afterBodyRead func()) error {
 		return UsageError("--me: %v", err)
 	}
 	common.Me = me
+	onFull, err := parseOnFull(*onFullFlag)
+	if err != nil {
+		return err
+	}
 	common.warnRootOverride()
 	root := resolveRoot(common.Root)
 
+	if alias := strings.TrimSpace(*bridgeFlag); alias != "" {
+		for _, name := range bridgeSendConflicts {
+			if flagWasVisited(fs, name) {
+				return UsageError("--bridge cannot be combined with --%s", name)
+			}
+		}
+		return runSendBridged(common, root, bridgeSendOptions{
+			Alias:       alias,
+			Subject:     *subjectFlag,
+			Thread:      *threadFlag,
+			Body:        *bodyFlag,
+			AllowEmpty:  *allowEmptyFlag,
+			Refs:        *refsFlag,
+			Priority:    *priorityFlag,
+			Kind:        *kindFlag,
+			Labels:      *labelsFlag,
+			Context:     *contextFlag,
+			WaitFor:     strings.TrimSpace(*waitForFlag),
+			WaitTimeout: *waitTimeoutFlag,
+			IgnorePin:   *ignoreSessionPinFlag,
+		})
+	}
+
 	// Parse inline agent@project:session syntax from --to BEFORE handle validation,
 	// since normalizeHandle rejects '@' and ':'.
 	targetProject := strings.TrimSpace(*projectFlag)
@@ -92,14 +134,29 @@ func runSendWithAfterBodyRead(args []string, afterBodyRead func()) error {
 		}
 	}
 
-	recipients, err := splitRecipients(rawTo)
-	if err != nil {
-		if _, ok := err.(*ExitCodeError); ok {
+	toCapability := strings.TrimSpace(*toCapabilityFlag)
+	capabilityPickMode := ""
+	var recipients []string
+	if toCapability != "" {
+		if rawTo != "" {
+			return UsageError("--to and --to-capability are mutually exclusive")
+		}
+		if capabilityPickMode, err = parseCapabilityPick(*pickFlag); err != nil {
 			return err
 		}
-		return UsageError("--to: %v", err)
+	} else {
+		if flagWasVisited(fs, "pick") {
+			return UsageError("--pick requires --to-capability")
+		}
+		recipients, err = splitRecipients(rawTo)
+		if err != nil {
+			if _, ok := err.(*ExitCodeError); ok {
+				return err
+			}
+			return UsageError("--to: %v", err)
+		}
+		recipients = dedupeStrings(recipients)
 	}
-	recipients = dedupeStrings(recipients)
 	if targetProject != "" && len(recipients) > 1 {
 		return UsageError("--project supports exactly one recipient; got %d. Send one message per recipient.", len(recipients))
 	}
@@ -110,7 +167,7 @@ func runSendWithAfterBodyRead(args []string, afterBodyRead func()) error {
 		if err := validateStage(waitFor); err != nil {
 			return UsageError("--wait-for: %v", err)
 		}
-		if len(recipients) != 1 {
+		if len(recipients) != 1 && toCapability == "" {
 			return UsageError("--wait-for requires exactly one recipient (got %d)", len(recipients))
 		}
 		if *waitTimeoutFlag < 0 {
@@ -331,6 +388,23 @@ func runSendWithAfterBodyRead(args []string, afterBodyRead func()) error {
 		defer func() { _ = mailboxAuthorization.Close() }()
 	}
 
+	// Capability routing picks the recipient from the delivery root's
+	// presence once the route and its config are pinned. The sender never
+	// picks itself on a same-root send.
+	var capabilityDecision *capabilityPick
+	if toCapability != "" {
+		exclude := ""
+		if !routed {
+			exclude = me
+		}
+		decision, err := pickCapabilityAgent(configFS, deliveryFS, toCapability, capabilityPickMode, exclude)
+		if err != nil {
+			return err
+		}
+		capabilityDecision = &decision
+		recipients = []string{decision.Chosen}
+	}
+
 	if fromSession != "" && !deliveryAgentExists(sourceFS, me) {
 		return fmt.Errorf("agent %q not found in source session %q", me, fromSession)
 	}
@@ -441,6 +515,15 @@ func runSendWithAfterBodyRead(args []string, afterBodyRead func()) error {
 			return err
 		}
 	}
+	if capabilityDecision != nil {
+		if _, taken := context[capabilityRoutingContextKey]; taken {
+			return UsageError("--context key %q is reserved for --to-capability", capabilityRoutingContextKey)
+		}
+		if context == nil {
+			context = map[string]any{}
+		}
+		context[capabilityRoutingContextKey] = capabilityDecision.ContextValue()
+	}
 
 	// Detect whether sender is inside a session (needed for reply_to and thread IDs).
 	senderInSession := sourceSession != "" || classifyRoot(root) != ""
@@ -520,21 +603,62 @@ func runSendWithAfterBodyRead(args []string, afterBodyRead func()) error {
 		Body: body,
 	}
 
-	data, err := msg.Marshal()
-	if err != nil {
-		return err
-	}
+	filename := id + ".md"
+	var hops []delivery.Hop
+	var outboxErr error
 	if targetProject == "" {
-		if err := prepareLocalSendMailboxes(deliveryFS, mailboxAuthorization, deliveryRoot, recipients); err != nil {
+		ctx, cancel := onFullContext(*onFullTimeoutFlag)
+		defer cancel()
+		result, err := delivery.Local{
+			ConfigFS:   configFS,
+			DeliveryFS: deliveryFS,
+			SourceFS:   sourceFS,
+			OnFull:     onFull,
+			Prepare: func() error {
+				if err := prepareLocalSendMailboxes(deliveryFS, mailboxAuthorization, deliveryRoot, recipients); err != nil {
+					return err
+				}
+				if err := mailboxAuthorization.Verify(); err != nil {
+					return fmt.Errorf("destination mailbox authorization changed before delivery: %w", err)
+				}
+				if targetSession != "" {
+					return sourceFS.VerifyBase()
+				}
+				return nil
+			},
+			Capability: capabilityDecision,
+		}.Deliver(ctx, msg)
+		reportDeliveryWarnings(result.Warnings)
+		if err != nil {
+			return localDeliveryError(id, err)
+		}
+		if result.Dropped {
+			return reportDroppedSend(common.JSON, id, recipients)
+		}
+		if result.Held {
+			return reportHeldSend(common.JSON, id, recipients, result.Breaker)
+		}
+		hops, outboxErr = result.Hops, result.OutboxErr
+	} else {
+		data, err := msg.Marshal()
+		if err != nil {
 			return err
 		}
-		if err := mailboxAuthorization.Verify(); err != nil {
-			return fmt.Errorf("destination mailbox authorization changed before delivery: %w", err)
+		if err := authorizeDelivery(configFS, msg.Header); err != nil {
+			return err
+		}
+		// Away recipients in the peer root forward as they would locally.
+		delegation, err := delivery.PlanDelegation(configFS, deliveryFS, msg.Header, now)
+		if err != nil {
+			return err
+		}
+		dropped, err := admitDelivery(configFS, deliveryFS, onFull, me, delegation.All, int64(len(data)), priority, *onFullTimeoutFlag)
+		if err != nil {
+			return err
+		}
+		if dropped {
+			return reportDroppedSend(common.JSON, id, recipients)
 		}
-	}
-
-	filename := id + ".md"
-	if targetProject != "" {
 		currentSourceAgents, currentSourceAgentsErr := revalidateSourceAgentsForSend(
 			sourceConfigFS,
 			sourceConfigPresent,
@@ -555,9 +679,19 @@ func runSendWithAfterBodyRead(args []string, afterBodyRead func()) error {
 		if err := sourceFS.VerifyBase(); err != nil {
 			return err
 		}
+		decision, warning, err := delivery.AdmitBreaker(configFS, deliveryFS, msg, filename, data, now)
+		if warning != "" {
+			reportDeliveryWarnings([]string{warning})
+		}
+		if err != nil {
+			return err
+		}
+		if decision.Held {
+			return reportHeldSend(common.JSON, id, recipients, decision)
+		}
 		// Cross-project: use DeliverToExistingInbox (never creates dirs in peer).
-		for _, r := range recipients {
-			if _, err := deliverToExistingInbox(deliveryFS, r, filename, data); err != nil {
+		for _, r := range delegation.Direct {
+			if _, err := deliverToExistingInbox(configFS, deliveryFS, msg.Header, r, filename, data); err != nil {
 				var committed *fsq.CommittedDurabilityError
 				if errors.As(err, &committed) {
 					return reportDeliveryError(id, err)
@@ -580,25 +714,32 @@ func runSendWithAfterBodyRead(args []string, afterBodyRead func()) error {
 				return reportDeliveryError(id, err)
 			}
 		}
-	} else {
-		if targetSession != "" {
-			if err := sourceFS.VerifyBase(); err != nil {
-				return err
-			}
-		}
-		if _, err := fsq.DeliverToInboxes(deliveryFS, recipients, filename, data); err != nil {
+		if err := delegation.DeliverCopies(configFS, deliveryFS, msg, filename); err != nil {
 			return reportDeliveryError(id, err)
 		}
-	}
+		hops = delegation.Hops
+		if capabilityDecision != nil {
+			if err := delivery.AdvanceCursor(deliveryFS, *capabilityDecision); err != nil {
+				_ = writeStderr("warning: %v\n", err)
+			}
+		}
 
-	// Best-effort presence touch.
-	_ = presence.TouchDeliveryRoot(sourceFS, common.Me)
+		// Best-effort presence touch.
+		_ = presence.ReturnDeliveryRoot(sourceFS, common.Me)
+		reportDeliveryWarnings(delegation.SendAwayReplies(
+			sourceConfigFS,
+			sourceFS,
+			deliveryFS,
+			msg,
+			delivery.ReplyRoute{Project: targetProject, Session: targetSession},
+			now,
+		))
 
-	// Copy to sender outbox/sent for audit (always in sender's root).
-	outboxDir := filepath.Join("agents", common.Me, "outbox", "sent")
-	outboxErr := error(nil)
-	if _, err := sourceFS.WriteFileAtomic(outboxDir, filename, data, 0o600); err != nil {
-		outboxErr = err
+		// Copy to sender outbox/sent for audit (always in sender's root).
+		outboxDir := filepath.Join("agents", common.Me, "outbox", "sent")
+		if _, err := sourceFS.WriteFileAtomic(outboxDir, filename, data, 0o600); err != nil {
+			outboxErr = err
+		}
 	}
 
 	session := ""
@@ -655,6 +796,12 @@ func runSendWithAfterBodyRead(args []string, afterBodyRead func()) error {
 		if waitResult != nil {
 			out["wait"] = waitResult
 		}
+		if capabilityDecision != nil {
+			out["capability"] = capabilityDecision
+		}
+		if len(hops) > 0 {
+			out["delegated"] = hops
+		}
 		if err := writeJSON(os.Stdout, out); err != nil {
 			return err
 		}
@@ -665,6 +812,14 @@ func runSendWithAfterBodyRead(args []string, afterBodyRead func()) error {
 			return err
 		}
 	}
+	if err := reportDelegationHops(hops); err != nil {
+		return err
+	}
+	if capabilityDecision != nil {
+		if err := writeStdout("Routed capability %s to %s (%s)\n", capabilityDecision.Capability, capabilityDecision.Chosen, capabilityDecision.Reason); err != nil {
+			return err
+		}
+	}
 	if waitResult != nil {
 		switch waitResult.Event {
 		case "matched":
@@ -689,6 +844,18 @@ func runSendWithAfterBodyRead(args []string, afterBodyRead func()) error {
 	return nil
 }
 
+func reportDroppedSend(jsonOut bool, id string, recipients []string) error {
+	if jsonOut {
+		return writeJSON(os.Stdout, map[string]any{
+			"id":      id,
+			"to":      recipients,
+			"dropped": true,
+			"reason":  "quota",
+		})
+	}
+	return writeStdout("Dropped %s to %s: recipient mailbox over quota (--on-full drop-low)\n", id, strings.Join(recipients, ","))
+}
+
 func loadPeerAgentsForSend(root *fsq.DeliveryRoot, strict bool) ([]string, bool, error) {
 	configPresent := true
 	agents, err := loadKnownAgentsWithRead(strict, func() ([]byte, error) {
@@ -0,0 +1,194 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/bridge"
+	"github.com/avivsinai/agent-message-queue/internal/format"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+	"github.com/avivsinai/agent-message-queue/internal/receipt"
+)
+
+// bridgeSendOptions are the send flags that apply to a bridged send.
+type bridgeSendOptions struct {
+	Alias       string
+	Subject     string
+	Thread      string
+	Body        string
+	AllowEmpty  bool
+	Refs        string
+	Priority    string
+	Kind        string
+	Labels      string
+	Context     string
+	WaitFor     string
+	WaitTimeout time.Duration
+	IgnorePin   bool
+}
+
+// bridgeSendConflicts are send flags with no meaning for a bridged send: the
+// alias names the one recipient, and delivery happens on the remote host.
+var bridgeSendConflicts = []string{"to", "to-capability", "pick", "project", "session", "from-session", "on-full", "on-full-timeout", "allow-self"}
+
+// runSendBridged spools one message for amq-bridge to carry to a remote
+// host/agent. --wait-for then waits on the receipt the courier brings back
+// under bridge/remote-receipts, as amq receipts wait --remote does.
+func runSendBridged(common *commonFlags, root string, opts bridgeSendOptions) error {
+	host, agent, err := bridge.ParseAlias(opts.Alias)
+	if err != nil {
+		return UsageError("--bridge: %v", err)
+	}
+	if opts.WaitFor != "" {
+		if err := validateStage(opts.WaitFor); err != nil {
+			return UsageError("--wait-for: %v", err)
+		}
+		if opts.WaitFor != receipt.StageDrained && opts.WaitFor != receipt.StageDLQ {
+			return UsageError("--wait-for: amq-bridge forwards only drained and dlq receipts")
+		}
+		if opts.WaitTimeout < 0 {
+			return UsageError("--wait-timeout must be >= 0")
+		}
+	}
+	if opts.IgnorePin && !common.rootExplicit() {
+		return UsageError("--ignore-session-pin requires an explicit --root")
+	}
+	if err := guardPinnedSourceContext("send", root, false, opts.IgnorePin, common.rootExplicit()); err != nil {
+		return err
+	}
+	identity, err := fsq.SnapshotDeliveryRoot(root)
+	if err != nil {
+		return err
+	}
+	sourceFS, err := fsq.OpenDeliveryRoot(root, identity)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = sourceFS.Close() }()
+	if err := validateKnownHandlesDeliveryRoot(sourceFS, common.Strict, common.Me); err != nil {
+		return err
+	}
+	if err := requireMailboxDeliveryRoot(sourceFS, root, common.Me); err != nil {
+		return err
+	}
+
+	body, err := readBody(opts.Body, opts.AllowEmpty)
+	if err != nil {
+		return err
+	}
+	priority := strings.TrimSpace(opts.Priority)
+	kind := strings.TrimSpace(opts.Kind)
+	if !format.IsValidPriority(priority) {
+		return UsageError("--priority must be one of: urgent, normal, low")
+	}
+	if !format.IsValidKind(kind) {
+		return UsageError("--kind must be one of: %s", format.ValidKindsList())
+	}
+	if kind != "" && priority == "" {
+		priority = format.PriorityNormal
+	}
+	var context map[string]any
+	if opts.Context != "" {
+		if context, err = parseContext(opts.Context); err != nil {
+			return err
+		}
+	}
+	threadID := strings.TrimSpace(opts.Thread)
+	if threadID == "" {
+		threadID = canonicalP2P(common.Me, agent)
+	}
+
+	now := time.Now()
+	id, err := format.NewMessageID(now)
+	if err != nil {
+		return err
+	}
+	msg := format.Message{
+		Header: format.Header{
+			Schema:   format.CurrentSchema,
+			ID:       id,
+			From:     common.Me,
+			To:       []string{agent},
+			Thread:   threadID,
+			Subject:  strings.TrimSpace(opts.Subject),
+			Created:  now.UTC().Format(time.RFC3339Nano),
+			Refs:     splitList(opts.Refs),
+			Priority: priority,
+			Kind:     kind,
+			Labels:   splitList(opts.Labels),
+			Context:  context,
+		},
+		Body: body,
+	}
+	data, err := msg.Marshal()
+	if err != nil {
+		return err
+	}
+	spooled, err := bridge.Spool(root, common.Me, opts.Alias, data)
+	if err != nil {
+		return err
+	}
+	// Copy to sender outbox/sent for audit, as a local send does.
+	outboxDir := filepath.Join("agents", common.Me, "outbox", "sent")
+	_, outboxErr := sourceFS.WriteFileAtomic(outboxDir, id+".md", data, 0o600)
+
+	var waitResult *waitForResult
+	var waitErr error
+	if opts.WaitFor != "" {
+		path := filepath.Join(root, bridge.RemoteReceiptPath(host, agent, id, opts.WaitFor))
+		r, err := receipt.WaitForPath(path, opts.WaitTimeout, 1*time.Second)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			waitResult = &waitForResult{Event: "timeout", Stage: opts.WaitFor, Timeout: opts.WaitTimeout.String()}
+			waitErr = TimeoutError("send --wait-for %s timed out after %s waiting for %s; run amq-bridge status --root %s to check the courier", opts.WaitFor, opts.WaitTimeout, opts.Alias, shellQuoteArg(root))
+		} else if err != nil {
+			waitResult = &waitForResult{Event: "error", Stage: opts.WaitFor, Detail: err.Error()}
+			waitErr = fmt.Errorf("send --wait-for: %w", err)
+		} else {
+			waitResult = &waitForResult{Event: "matched", Stage: opts.WaitFor, Receipt: &r}
+		}
+	}
+
+	if common.JSON {
+		out := map[string]any{
+			"id":      id,
+			"thread":  threadID,
+			"to":      []string{opts.Alias},
+			"subject": msg.Header.Subject,
+			"root":    root,
+			"bridge":  map[string]any{"dest_alias": opts.Alias, "spool": spooled.Path},
+			"outbox":  outboxResult(outboxErr),
+		}
+		if waitResult != nil {
+			out["wait"] = waitResult
+		}
+		if err := writeJSON(os.Stdout, out); err != nil {
+			return err
+		}
+		return waitErr
+	}
+	if err := reportOutboxError(outboxErr); err != nil {
+		return err
+	}
+	if waitResult == nil {
+		return writeStdout("Queued %s for %s via amq-bridge (spool: %s)\n", id, opts.Alias, spooled.Path)
+	}
+	switch waitResult.Event {
+	case "matched":
+		if err := writeStdout("Sent %s to %s; %s at %s\n", id, opts.Alias, opts.WaitFor, waitResult.Receipt.EmittedAt); err != nil {
+			return err
+		}
+	case "timeout":
+		if err := writeStdout("Queued %s for %s; timed out waiting %s for %s receipt\n", id, opts.Alias, opts.WaitTimeout, opts.WaitFor); err != nil {
+			return err
+		}
+	default:
+		if err := writeStdout("Queued %s for %s; wait error: %s\n", id, opts.Alias, waitResult.Detail); err != nil {
+			return err
+		}
+	}
+	return waitErr
+}
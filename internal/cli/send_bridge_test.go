@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/bridge"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+	"github.com/avivsinai/agent-message-queue/internal/receipt"
+)
+
+func TestSendBridgeSpoolsAndWaitsOnRemoteReceipt(t *testing.T) {
+	root := setupReceiptsTestRoot(t)
+	spool := filepath.Join(root, "bridge", "outbox", "alice", "new")
+
+	// Play the courier: once the message is spooled, bring mac/claude's
+	// drained receipt back under bridge/remote-receipts.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			entries, _ := os.ReadDir(spool)
+			for _, entry := range entries {
+				id, ok := strings.CutSuffix(entry.Name(), ".md")
+				if !ok {
+					continue
+				}
+				data, err := receipt.New(id, "", "alice", "claude", receipt.StageDrained, "amq-bridge: drained on mac").Marshal()
+				if err != nil {
+					return
+				}
+				rel := bridge.RemoteReceiptPath("mac", "claude", id, receipt.StageDrained)
+				_, _ = fsq.WriteFileAtomic(filepath.Join(root, filepath.Dir(rel)), filepath.Base(rel), data, 0o600)
+				return
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}()
+
+	stdout, _ := captureOutput(t, func() error {
+		return runSend([]string{
+			"--root", root, "--me", "alice", "--bridge", "mac/claude",
+			"--body", "hello", "--wait-for", "drained", "--wait-timeout", "5s", "--json",
+		})
+	})
+	<-done
+
+	var result struct {
+		ID     string `json:"id"`
+		Thread string `json:"thread"`
+		Bridge struct {
+			DestAlias string `json:"dest_alias"`
+			Spool     string `json:"spool"`
+		} `json:"bridge"`
+		Wait waitForResult `json:"wait"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("unmarshal: %v\nstdout: %s", err, stdout)
+	}
+	if result.Wait.Event != "matched" || result.Wait.Receipt == nil || result.Wait.Receipt.Detail != "amq-bridge: drained on mac" {
+		t.Fatalf("wait = %+v, want mac/claude's bridged receipt", result.Wait)
+	}
+	if result.Thread != "p2p/alice__claude" || result.Bridge.DestAlias != "mac/claude" {
+		t.Fatalf("result = %+v", result)
+	}
+	if result.Bridge.Spool != filepath.Join(spool, result.ID+".md") {
+		t.Fatalf("spool = %q, want %s.md under %s", result.Bridge.Spool, result.ID, spool)
+	}
+	dest, err := os.ReadFile(bridge.DestSidecarPath(result.Bridge.Spool))
+	if err != nil || strings.TrimSpace(string(dest)) != "mac/claude" {
+		t.Fatalf("dest sidecar = %q, %v", dest, err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "agents", "alice", "outbox", "sent", result.ID+".md")); err != nil {
+		t.Fatalf("outbox copy: %v", err)
+	}
+	if entries, _ := os.ReadDir(fsq.AgentInboxNew(root, "claude")); len(entries) != 0 {
+		t.Fatalf("bridged send delivered locally: %v", entries)
+	}
+}
+
+func TestSendBridgeRejectsLocalRoutingFlags(t *testing.T) {
+	root := setupReceiptsTestRoot(t)
+	for _, args := range [][]string{
+		{"--to", "bob"},
+		{"--project", "infra"},
+		{"--session", "qa"},
+		{"--wait-for", "claimed"},
+	} {
+		err := runSend(append([]string{"--root", root, "--me", "alice", "--bridge", "mac/claude", "--body", "x"}, args...))
+		if GetExitCode(err) != ExitUsage {
+			t.Fatalf("send --bridge %v exit = %d (%v), want usage", args, GetExitCode(err), err)
+		}
+	}
+}
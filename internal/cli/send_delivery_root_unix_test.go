@@ -986,8 +986,8 @@ func TestCrossProjectSendPreservesCommittedDeliveryWhenLayoutAlsoChanges(t *test
 	}
 
 	originalDeliver := deliverToExistingInbox
-	deliverToExistingInbox = func(root *fsq.DeliveryRoot, agent, filename string, data []byte) (string, error) {
-		path, deliverErr := originalDeliver(root, agent, filename, data)
+	deliverToExistingInbox = func(configFS, root *fsq.DeliveryRoot, header format.Header, agent, filename string, data []byte) (string, error) {
+		path, deliverErr := originalDeliver(configFS, root, header, agent, filename, data)
 		if deliverErr != nil {
 			return path, deliverErr
 		}
@@ -22,6 +22,14 @@ func runSession(args []string) error {
 		return runSessionCreate(args[1:])
 	case "list":
 		return runSessionList(args[1:])
+	case "archive":
+		return runSessionArchive(args[1:])
+	case "rm":
+		return runSessionRm(args[1:])
+	case "rename":
+		return runSessionRename(args[1:])
+	case "fork":
+		return runSessionFork(args[1:])
 	case "resume":
 		if len(args) == 1 || (len(args) > 1 && isHelp(args[1])) {
 			return runLaunchEngine([]string{"--help"}, launchCLIOptions{resumeOnly: true})
@@ -67,7 +75,7 @@ func runSessionCreate(args []string) error {
 	usage := usageWithFlags(fs, "amq session create <name> [options]",
 		"Create a named session and its roster mailboxes under the base root.",
 		"Canonical names only ([a-z0-9_-]+). Existing sessions fail loudly; create is never silent.")
-	name, flagArgs, peelErr := peelSessionCreateName(fs, args)
+	name, flagArgs, peelErr := peelSessionName(fs, args, "create")
 	if peelErr != nil {
 		flagArgs = args
 	}
@@ -252,13 +260,13 @@ func loadLaunchRosterHandles() ([]string, bool, error) {
 	return handles, true, nil
 }
 
-func peelSessionCreateName(fs *flag.FlagSet, args []string) (string, []string, error) {
+func peelSessionName(fs *flag.FlagSet, args []string, command string) (string, []string, error) {
 	i := 0
 	for i < len(args) {
 		arg := args[i]
 		if arg == "--" {
 			if i+1 >= len(args) {
-				return "", nil, UsageError("session name required (e.g., amq session create feature-x)")
+				return "", nil, UsageError("session name required (e.g., amq session %s feature-x)", command)
 			}
 			name := args[i+1]
 			rest := append(append([]string{}, args[:i]...), args[i+2:]...)
@@ -275,7 +283,7 @@ func peelSessionCreateName(fs *flag.FlagSet, args []string) (string, []string, e
 		rest := append(append([]string{}, args[:i]...), args[i+1:]...)
 		return arg, rest, nil
 	}
-	return "", nil, UsageError("session name required (e.g., amq session create feature-x)")
+	return "", nil, UsageError("session name required (e.g., amq session %s feature-x)", command)
 }
 
 func sessionCreateFlagSpan(fs *flag.FlagSet, arg string) int {
@@ -11,24 +11,10 @@ import (
 	"github.com/avivsinai/agent-message-queue/internal/sessionguard"
 )
 
-type sessionPin struct {
-	Present      bool
-	Session      string
-	BaseRoot     string
-	ExpectedRoot string
-	BaseRootID   string
-	RootID       string
-	IdentityPin  bool
-}
+type sessionPin = sessionguard.Pin
 
 func sessionGuardPinStateFor(pin sessionPin) sessionguard.PinState {
-	if !pin.Present {
-		return sessionguard.PinAbsent
-	}
-	if pin.IdentityPin {
-		return sessionguard.PinIdentity
-	}
-	return sessionguard.PinLegacy
+	return pin.State()
 }
 
 func sessionGuardInputForContext(kind sessionguard.Kind, channel sessionguard.Channel, pin sessionguard.PinState, mismatch *SessionContextError, flags sessionguard.Flags) sessionguard.Input {
@@ -45,71 +31,15 @@ func sessionGuardDecisionForContext(kind sessionguard.Kind, channel sessionguard
 	return sessionguard.Decide(sessionGuardInputForContext(kind, channel, pin, mismatch, flags))
 }
 
-// loadSessionPin distinguishes an absent legacy pin from an explicitly empty
-// base-root pin. New shell writers always replace the complete context set.
+// loadSessionPin reads the shell's session pin. A malformed or incomplete
+// pin is a context mismatch.
 func loadSessionPin() (sessionPin, error) {
-	rawSession, present := os.LookupEnv(envSession)
-	_, rootIDPresent := os.LookupEnv(envRootID)
-	_, baseRootIDPresent := os.LookupEnv(envBaseRootID)
-	if !present && !rootIDPresent && !baseRootIDPresent {
-		return sessionPin{}, nil
-	}
-
-	pin := sessionPin{Present: true, Session: strings.TrimSpace(rawSession)}
-	if pin.Session != "" {
-		if err := validateSessionName(pin.Session); err != nil {
-			return sessionPin{}, ContextMismatchError("invalid %s=%q: %v", envSession, rawSession, err)
-		}
-	}
-
-	base := strings.TrimSpace(os.Getenv(envBaseRoot))
-	if base != "" {
-		if !filepath.IsAbs(base) {
-			return sessionPin{}, ContextMismatchError("invalid %s=%q: pinned base root must be absolute", envBaseRoot, base)
-		}
-		pin.BaseRoot = absPath(filepath.Clean(base))
-	}
-
-	if pin.BaseRoot == "" {
-		evidence := make([]string, 0, 3)
-		if present {
-			evidence = append(evidence, envSession)
-		}
-		if rootIDPresent {
-			evidence = append(evidence, envRootID)
-		}
-		if baseRootIDPresent {
-			evidence = append(evidence, envBaseRootID)
-		}
-		return sessionPin{}, ContextMismatchError(
-			"incomplete AMQ session pin: evidence from %s requires an exact %s; re-run amq env with explicit --root <path> to replace the complete context, or clear stale pin variables before using --session <name>",
-			strings.Join(evidence, ", "),
-			envBaseRoot,
-		)
-	}
-	if pin.Session != "" {
-		pin.ExpectedRoot = filepath.Join(pin.BaseRoot, pin.Session)
-	} else {
-		pin.ExpectedRoot = pin.BaseRoot
-	}
-
-	rootID, rootIDPresent := os.LookupEnv(envRootID)
-	baseRootID, baseRootIDPresent := os.LookupEnv(envBaseRootID)
-	if !rootIDPresent && !baseRootIDPresent {
-		return pin, nil
-	}
-	rootID = strings.TrimSpace(rootID)
-	baseRootID = strings.TrimSpace(baseRootID)
-	if !rootIDPresent || !baseRootIDPresent || rootID == "" || baseRootID == "" {
-		return sessionPin{}, ContextMismatchError("incomplete AMQ identity pin: %s and %s must both be present and non-empty", envRootID, envBaseRootID)
+	pin, err := sessionguard.LoadPin(validTreeIdentityToken)
+	var pinErr *sessionguard.PinError
+	if errors.As(err, &pinErr) {
+		return sessionPin{}, ContextMismatchError("%s", pinErr.Message)
 	}
-	if !validTreeIdentityToken(rootID) || !validTreeIdentityToken(baseRootID) {
-		return sessionPin{}, ContextMismatchError("unverifiable AMQ identity pin: unsupported or malformed %s/%s", envRootID, envBaseRootID)
-	}
-	pin.RootID = rootID
-	pin.BaseRootID = baseRootID
-	pin.IdentityPin = true
-	return pin, nil
+	return pin, err
 }
 
 // validateLegacySessionPinRoot keeps a complete legacy base/session pin from
@@ -139,29 +69,29 @@ func validateLegacySessionPinRoot(pin sessionPin) error {
 // verifyRootUnderBase authenticates the base and proves that session is a
 // direct, non-symlink child of it before authenticating the resulting root.
 func verifyRootUnderBase(base, baseID, session, root, rootID string) error {
-	if verifyTreeIdentityToken(base, baseID) != TreeRelationSame {
-		return ContextMismatchError("pinned base root identity is not current: %s", base)
+	err := sessionguard.VerifyRootUnderBase(absPath(resolveRoot(base)), baseID, session, absPath(resolveRoot(root)), rootID, verifiedTreeIdentityToken)
+	return sessionMismatchError(err)
+}
+
+// verifiedTreeIdentityToken is resolveTreeIdentityToken restricted to tokens
+// this platform can verify.
+func verifiedTreeIdentityToken(path string) (string, error) {
+	token, err := resolveTreeIdentityToken(path)
+	if err != nil {
+		return "", err
 	}
-	base = absPath(resolveRoot(base))
-	root = absPath(resolveRoot(root))
-	if session == "" {
-		if !sameTreeIdentity(base, root) {
-			return ContextMismatchError("root is not the pinned base root")
-		}
-	} else {
-		entry := filepath.Join(base, session)
-		info, err := os.Lstat(entry)
-		if err != nil || !info.IsDir() || info.Mode()&os.ModeSymlink != 0 {
-			return ContextMismatchError("pinned session %q is not a direct directory under base", session)
-		}
-		if !sameTreeIdentity(entry, root) {
-			return ContextMismatchError("root is not the pinned session directory")
-		}
+	if !validTreeIdentityToken(token) {
+		return "", fmt.Errorf("unverifiable tree identity for %s", path)
 	}
-	if verifyTreeIdentityToken(root, rootID) != TreeRelationSame {
-		return ContextMismatchError("target root identity is not current: %s", root)
+	return token, nil
+}
+
+func sessionMismatchError(err error) error {
+	var mismatch *sessionguard.MismatchError
+	if errors.As(err, &mismatch) {
+		return ContextMismatchError("%s", mismatch.Message)
 	}
-	return nil
+	return err
 }
 
 func sessionPinMismatch(target string) (*SessionContextError, error) {
@@ -179,20 +109,10 @@ func sessionPinMismatchWithPin(target string, pin sessionPin) (*SessionContextEr
 		if err := validateLegacySessionPinRoot(pin); err != nil {
 			return &SessionContextError{Message: err.Error()}, nil
 		}
-		if pin.IdentityPin {
-			if err := verifyRootUnderBase(pin.BaseRoot, pin.BaseRootID, pin.Session, target, pin.RootID); err != nil {
-				return &SessionContextError{Message: err.Error()}, nil
-			}
-			return nil, nil
-		}
-		expected := pin.ExpectedRoot
-		if target == expected {
-			return nil, nil
+		if err := pin.Verify(target, verifiedTreeIdentityToken); err != nil {
+			return &SessionContextError{Message: err.Error()}, nil
 		}
-		return &SessionContextError{Message: fmt.Sprintf(
-			"session context mismatch: target root %s differs from pinned root %s (AM_SESSION=%q)",
-			target, expected, pin.Session,
-		)}, nil
+		return nil, nil
 	}
 
 	if source, ok := conflictingSourceRoot(target); ok {
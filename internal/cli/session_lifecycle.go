@@ -0,0 +1,580 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/format"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+	"github.com/avivsinai/agent-message-queue/internal/launch"
+)
+
+const (
+	// sessionArchiveDir lives under the base root's meta/, which has no
+	// agents/ directory, so session list, who, and sibling-backlog hints never
+	// report archived sessions.
+	sessionArchiveDir = "meta/archive/sessions"
+
+	// sessionRenamesFile maps renamed session names to their successors so
+	// reply_to hints stamped before a rename still route.
+	sessionRenamesFile    = "session-renames.json"
+	sessionRenamesVersion = 1
+)
+
+type sessionArchiveResult struct {
+	Name           string `json:"name"`
+	Path           string `json:"path"`
+	Archive        string `json:"archive"`
+	Files          int    `json:"files"`
+	Bytes          int64  `json:"bytes"`
+	ManifestSHA256 string `json:"manifest_sha256"`
+}
+
+type sessionRemoveResult struct {
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	Removed bool   `json:"removed"`
+}
+
+type sessionRenameResult struct {
+	From           string `json:"from"`
+	To             string `json:"to"`
+	Path           string `json:"path"`
+	JournalRebound bool   `json:"journal_rebound"`
+}
+
+type sessionForkResult struct {
+	Source         string   `json:"source"`
+	Name           string   `json:"name"`
+	Path           string   `json:"path"`
+	Agents         []string `json:"agents"`
+	Threads        []string `json:"threads"`
+	MissingThreads []string `json:"missing_threads,omitempty"`
+	Messages       int      `json:"messages"`
+	Skipped        int      `json:"skipped"`
+}
+
+type sessionRenames struct {
+	Version int               `json:"version"`
+	Renames map[string]string `json:"renames"`
+}
+
+func runSessionArchive(args []string) error {
+	fs := flag.NewFlagSet("session archive", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	outFlag := fs.String("out", "", "Archive path (default: <base>/meta/archive/sessions/<name>-<utc>.tar.gz)")
+	usage := usageWithFlags(fs, "amq session archive <name> [--out <file.tar.gz>] [options]",
+		"Pack a session into a verified backup archive, then remove it from the base root.",
+		"Refuses while any wake lock or launch binding in the session is live.",
+		"Bring it back with: amq restore --from <archive> --root <base>/<name>")
+	names, err := parseSessionLifecycleArgs(fs, args, usage, "archive", 1)
+	if err != nil || names == nil {
+		return err
+	}
+	name := names[0]
+	base, sessionRoot, err := openSessionLifecycleTarget(common.Root, name)
+	if err != nil {
+		return err
+	}
+	closeSession := func() { _ = sessionRoot.Close() }
+	defer closeSession()
+	if err := refuseLiveSession(name, sessionRoot); err != nil {
+		return err
+	}
+
+	out := strings.TrimSpace(*outFlag)
+	if out == "" {
+		stamp := time.Now().UTC().Format("20060102T150405Z")
+		out = filepath.Join(base, filepath.FromSlash(sessionArchiveDir), name+"-"+stamp+".tar.gz")
+		if err := os.MkdirAll(filepath.Dir(out), 0o700); err != nil {
+			return err
+		}
+	}
+	outAbs, err := filepath.Abs(out)
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(sessionRoot.Base(), outAbs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return UsageError("--out must be outside the session being archived")
+	}
+	if _, err := os.Lstat(outAbs); err == nil {
+		return UsageError("--out %s already exists", out)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	files, excluded, err := collectBackupFiles(sessionRoot)
+	if err != nil {
+		return fmt.Errorf("scan session: %w", err)
+	}
+	manifestData, err := json.MarshalIndent(backupManifest{
+		Version:    backupManifestVersion,
+		CreatedUTC: time.Now().UTC().Format(time.RFC3339),
+		SourceRoot: sessionRoot.Base(),
+		Files:      files,
+		Excluded:   excluded,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	total, err := writeBackupArchive(sessionRoot, outAbs, manifestData, files)
+	if err != nil {
+		return err
+	}
+	// The archive is complete on disk; only now is the live tree removed.
+	if _, err := verifyBackupArchive(outAbs); err != nil {
+		return fmt.Errorf("verify archive %s: %w", outAbs, err)
+	}
+	path := sessionRoot.Base()
+	closeSession()
+	if err := removeSessionChild(base, name); err != nil {
+		return fmt.Errorf("archive written to %s but removing session failed: %w", outAbs, err)
+	}
+
+	manifestSum := sha256.Sum256(manifestData)
+	result := sessionArchiveResult{
+		Name: name, Path: path, Archive: outAbs, Files: len(files), Bytes: total,
+		ManifestSHA256: hex.EncodeToString(manifestSum[:]),
+	}
+	if common.JSON {
+		return writeJSON(os.Stdout, result)
+	}
+	return writeStdout("Archived session %q (%d file(s)) to %s\n", name, result.Files, result.Archive)
+}
+
+func runSessionRm(args []string) error {
+	fs := flag.NewFlagSet("session rm", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	yesFlag := fs.Bool("yes", false, "Skip confirmation prompt")
+	usage := usageWithFlags(fs, "amq session rm <name> [--yes] [options]",
+		"Permanently delete a session and every message in it.",
+		"Refuses while any wake lock or launch binding in the session is live.",
+		"Use amq session archive to keep a restorable copy instead.")
+	names, err := parseSessionLifecycleArgs(fs, args, usage, "rm", 1)
+	if err != nil || names == nil {
+		return err
+	}
+	name := names[0]
+	base, sessionRoot, err := openSessionLifecycleTarget(common.Root, name)
+	if err != nil {
+		return err
+	}
+	path := sessionRoot.Base()
+	liveErr := refuseLiveSession(name, sessionRoot)
+	_ = sessionRoot.Close()
+	if liveErr != nil {
+		return liveErr
+	}
+	if !*yesFlag {
+		ok, err := confirmPrompt(fmt.Sprintf("Permanently delete session %q at %s?", name, path))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return writeStdoutLine("Aborted.")
+		}
+	}
+	if err := removeSessionChild(base, name); err != nil {
+		return err
+	}
+	result := sessionRemoveResult{Name: name, Path: path, Removed: true}
+	if common.JSON {
+		return writeJSON(os.Stdout, result)
+	}
+	return writeStdout("Removed session %q\n", name)
+}
+
+func runSessionRename(args []string) error {
+	fs := flag.NewFlagSet("session rename", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	usage := usageWithFlags(fs, "amq session rename <old> <new> [options]",
+		"Rename a session in place.",
+		"The retained launch journal is rebound to the new name, and replies to",
+		"messages stamped reply_to <handle>@<old> route to the new session.",
+		"Refuses while any wake lock or launch binding in the session is live.")
+	names, err := parseSessionLifecycleArgs(fs, args, usage, "rename", 2)
+	if err != nil || names == nil {
+		return err
+	}
+	from, to := names[0], names[1]
+	if from == to {
+		return UsageError("old and new session names are the same")
+	}
+	base, sessionRoot, err := openSessionLifecycleTarget(common.Root, from)
+	if err != nil {
+		return err
+	}
+	// The pinned session root cannot follow a rename; close it first.
+	liveErr := refuseLiveSession(from, sessionRoot)
+	_ = sessionRoot.Close()
+	if liveErr != nil {
+		return liveErr
+	}
+	baseRoot, err := openBaseDeliveryRoot(base)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = baseRoot.Close() }()
+	if err := baseRoot.RenameDirectChild(from, to); err != nil {
+		var exists *fsq.DirectChildExistsError
+		if errors.As(err, &exists) {
+			return &sessionExistsError{Name: to, Path: filepath.Join(base, to)}
+		}
+		return err
+	}
+	if err := recordSessionRename(baseRoot, from, to); err != nil {
+		return fmt.Errorf("renamed session but recording reply routing failed: %w", err)
+	}
+
+	renamed, err := openSessionDeliveryRoot(base, to)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = renamed.Close() }()
+	rebound, err := rebindSessionJournal(renamed, to)
+	if err != nil {
+		return ActionRequiredError("renamed session %q to %q but the launch journal could not follow: %v; run 'amq cleanup --launch-journal --root %s'", from, to, err, renamed.Base())
+	}
+
+	result := sessionRenameResult{From: from, To: to, Path: renamed.Base(), JournalRebound: rebound}
+	if common.JSON {
+		return writeJSON(os.Stdout, result)
+	}
+	return writeStdout("Renamed session %q to %q\n", from, to)
+}
+
+func runSessionFork(args []string) error {
+	fs := flag.NewFlagSet("session fork", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	threadsFlag := fs.String("threads", "", "Comma-separated thread ids to copy (default: every thread)")
+	usage := usageWithFlags(fs, "amq session fork <src> <dst> [--threads <id,...>] [options]",
+		"Create a new session with the source roster, seeded with selected threads.",
+		"Seeded inbox messages land in inbox/cur as history, so nothing is re-delivered",
+		"as new work; outbox copies are kept so amq thread shows both sides.")
+	names, err := parseSessionLifecycleArgs(fs, args, usage, "fork", 2)
+	if err != nil || names == nil {
+		return err
+	}
+	src, dst := names[0], names[1]
+	if src == dst {
+		return UsageError("source and destination session names are the same")
+	}
+	selected := map[string]bool{}
+	for _, id := range splitList(*threadsFlag) {
+		selected[id] = false
+	}
+
+	base, srcRoot, err := openSessionLifecycleTarget(common.Root, src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = srcRoot.Close() }()
+	agents := listSessionAgents(srcRoot.Base())
+	created, err := provisionNewNamedSession(base, dst, agents)
+	if err != nil {
+		var exists *fsq.DirectChildExistsError
+		if errors.As(err, &exists) {
+			return &sessionExistsError{Name: dst, Path: filepath.Join(base, dst)}
+		}
+		return err
+	}
+	dstRoot, err := openSessionDeliveryRoot(base, dst)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = dstRoot.Close() }()
+
+	result := sessionForkResult{Source: src, Name: dst, Path: created, Agents: agents, Threads: []string{}}
+	copiedThreads := map[string]bool{}
+	for _, agent := range agents {
+		for _, leaf := range []struct{ from, to fsq.MailboxLeaf }{
+			{fsq.MailboxInboxNew, fsq.MailboxInboxCur},
+			{fsq.MailboxInboxCur, fsq.MailboxInboxCur},
+			{fsq.MailboxOutboxSent, fsq.MailboxOutboxSent},
+		} {
+			copied, skipped, err := forkMailboxLeaf(srcRoot, dstRoot, agent, leaf.from, leaf.to, selected, copiedThreads)
+			if err != nil {
+				return fmt.Errorf("fork %s %s: %w", agent, leaf.from, err)
+			}
+			result.Messages += copied
+			result.Skipped += skipped
+		}
+	}
+	for thread := range copiedThreads {
+		result.Threads = append(result.Threads, thread)
+	}
+	sort.Strings(result.Threads)
+	for thread, found := range selected {
+		if !found {
+			result.MissingThreads = append(result.MissingThreads, thread)
+		}
+	}
+	sort.Strings(result.MissingThreads)
+
+	if common.JSON {
+		return writeJSON(os.Stdout, result)
+	}
+	if err := writeStdout("Forked session %q to %q: %d message(s) across %d thread(s)\n", src, dst, result.Messages, len(result.Threads)); err != nil {
+		return err
+	}
+	if len(result.MissingThreads) > 0 {
+		return writeStderr("warning: thread(s) not found in %q: %s\n", src, strings.Join(result.MissingThreads, ", "))
+	}
+	return nil
+}
+
+// forkMailboxLeaf copies the messages in one source leaf whose thread is
+// selected (every thread when selected is empty). Unparseable files and
+// files already present at the destination are skipped, never overwritten.
+func forkMailboxLeaf(src, dst *fsq.DeliveryRoot, agent string, from, to fsq.MailboxLeaf, selected, copiedThreads map[string]bool) (int, int, error) {
+	fromDir := filepath.Join("agents", agent, filepath.FromSlash(string(from)))
+	toDir := filepath.Join("agents", agent, filepath.FromSlash(string(to)))
+	entries, err := src.ReadDir(fromDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, 0, nil
+		}
+		return 0, 0, err
+	}
+	copied, skipped := 0, 0
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !strings.HasSuffix(entry.Name(), ".md") {
+			continue
+		}
+		data, err := src.ReadRegularNoFollow(filepath.Join(fromDir, entry.Name()))
+		if err != nil {
+			skipped++
+			continue
+		}
+		msg, err := format.ParseMessage(data)
+		if err != nil {
+			skipped++
+			continue
+		}
+		thread := msg.Header.Thread
+		if len(selected) > 0 {
+			if _, ok := selected[thread]; !ok {
+				continue
+			}
+			selected[thread] = true
+		}
+		if _, err := dst.WriteFileExclusive(toDir, entry.Name(), data, 0o600); err != nil {
+			if errors.Is(err, os.ErrExist) {
+				skipped++
+				continue
+			}
+			return copied, skipped, err
+		}
+		copiedThreads[thread] = true
+		copied++
+	}
+	return copied, skipped, nil
+}
+
+func parseSessionLifecycleArgs(fs *flag.FlagSet, args []string, usage func(), command string, count int) ([]string, error) {
+	var names []string
+	flagArgs := args
+	var peelErr error
+	for len(names) < count {
+		name, rest, err := peelSessionName(fs, flagArgs, command)
+		if err != nil {
+			peelErr = err
+			break
+		}
+		names = append(names, name)
+		flagArgs = rest
+	}
+	if peelErr != nil {
+		flagArgs = args
+	}
+	if handled, err := parseFlags(fs, flagArgs, usage); err != nil {
+		return nil, err
+	} else if handled {
+		return nil, nil
+	}
+	if peelErr != nil {
+		return nil, peelErr
+	}
+	if fs.NArg() > 0 {
+		return nil, UsageError("unexpected argument %q", fs.Arg(0))
+	}
+	for _, name := range names {
+		if err := validateSessionName(name); err != nil {
+			return nil, err
+		}
+	}
+	return names, nil
+}
+
+// openSessionLifecycleTarget resolves an existing direct session under the
+// base root and pins it.
+func openSessionLifecycleTarget(root, name string) (string, *fsq.DeliveryRoot, error) {
+	base, err := sessionBaseRoot(root)
+	if err != nil {
+		return "", nil, err
+	}
+	if _, err := resolveSessionRoot(base, name); err != nil {
+		return "", nil, err
+	}
+	sessionRoot, err := openSessionDeliveryRoot(base, name)
+	if err != nil {
+		return "", nil, err
+	}
+	return base, sessionRoot, nil
+}
+
+func openSessionDeliveryRoot(base, name string) (*fsq.DeliveryRoot, error) {
+	baseRoot, err := openBaseDeliveryRoot(base)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = baseRoot.Close() }()
+	return baseRoot.OpenDirectChild(name)
+}
+
+func openBaseDeliveryRoot(base string) (*fsq.DeliveryRoot, error) {
+	identity, err := fsq.SnapshotDeliveryRoot(base)
+	if err != nil {
+		return nil, err
+	}
+	return fsq.OpenDeliveryRoot(base, identity)
+}
+
+func removeSessionChild(base, name string) error {
+	baseRoot, err := openBaseDeliveryRoot(base)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = baseRoot.Close() }()
+	return baseRoot.RemoveDirectChild(name)
+}
+
+// refuseLiveSession fails closed when anything may still be attached to the
+// session: a wake lock that is not proven stale, a held or unverifiable
+// launch lease, or a launch binding whose backend does not report it absent.
+func refuseLiveSession(name string, root *fsq.DeliveryRoot) error {
+	var reasons []string
+	for _, agent := range listSessionAgents(root.Base()) {
+		inspection := inspectWakeLock(root.Base(), agent)
+		if inspection.Exists && inspection.Status != wakeLockStale {
+			reasons = append(reasons, fmt.Sprintf("wake lock for %s is %s (pid %d)", agent, inspection.Status, inspection.PID))
+		}
+	}
+	if lease, err := launch.InspectLease(root); err != nil {
+		reasons = append(reasons, fmt.Sprintf("launch lease: %v", err))
+	} else if lease.State == launch.LeaseValid || lease.State == launch.LeaseUnverified {
+		reasons = append(reasons, fmt.Sprintf("launch lease is %s", lease.State))
+	}
+	binding, err := launch.LoadBinding(root)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		reasons = append(reasons, fmt.Sprintf("launch binding: %v", err))
+	default:
+		backend := launchBackends()[binding.Backend]
+		if backend == nil {
+			reasons = append(reasons, fmt.Sprintf("launch binding uses unknown backend %q", binding.Backend))
+			break
+		}
+		inspection, err := backend.Inspect(launch.InspectRequest{Binding: binding, Root: root})
+		if err != nil {
+			reasons = append(reasons, fmt.Sprintf("launch binding (%s): %v", binding.Backend, err))
+		} else if inspection.Status != launch.InspectAbsent {
+			reasons = append(reasons, fmt.Sprintf("launch binding (%s) is %s", binding.Backend, inspection.Status))
+		}
+	}
+	if len(reasons) == 0 {
+		return nil
+	}
+	return ActionRequiredError("session %q is live: %s; stop its agents and wakes first", name, strings.Join(reasons, "; "))
+}
+
+func rebindSessionJournal(root *fsq.DeliveryRoot, session string) (rebound bool, err error) {
+	// Acquiring a lease writes launch state; skip it when there is no journal.
+	if _, err := os.Lstat(launch.JournalPath(root.Base())); errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	lease, err := launch.AcquireLease(root, "")
+	if err != nil {
+		return false, err
+	}
+	defer func() { err = errors.Join(err, lease.Release()) }()
+	return launch.RebindJournalSession(root, lease, session)
+}
+
+func loadSessionRenames(baseRoot *fsq.DeliveryRoot) (sessionRenames, error) {
+	renames := sessionRenames{Version: sessionRenamesVersion, Renames: map[string]string{}}
+	data, err := baseRoot.ReadRegularNoFollow(filepath.Join("meta", sessionRenamesFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return renames, nil
+	}
+	if err != nil {
+		return renames, err
+	}
+	if err := json.Unmarshal(data, &renames); err != nil {
+		return renames, fmt.Errorf("parse %s: %w", sessionRenamesFile, err)
+	}
+	if renames.Version != sessionRenamesVersion {
+		return renames, fmt.Errorf("unsupported %s version %d", sessionRenamesFile, renames.Version)
+	}
+	if renames.Renames == nil {
+		renames.Renames = map[string]string{}
+	}
+	return renames, nil
+}
+
+func recordSessionRename(baseRoot *fsq.DeliveryRoot, from, to string) error {
+	renames, err := loadSessionRenames(baseRoot)
+	if err != nil {
+		return err
+	}
+	renames.Renames[from] = to
+	// The new name is live again; an older alias for it must not shadow it.
+	delete(renames.Renames, to)
+	data, err := json.MarshalIndent(renames, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = baseRoot.WriteFileAtomic("meta", sessionRenamesFile, append(data, '\n'), 0o600)
+	return err
+}
+
+// followSessionRename maps a reply_to session that no longer exists to the
+// session it was renamed to. It is best-effort: any read failure, a name that
+// still exists, or a cycle leaves session unchanged.
+func followSessionRename(root, session string) string {
+	base := absPath(baseRootOf(root))
+	if session == "" || dirExists(filepath.Join(base, session)) {
+		return session
+	}
+	baseRoot, err := openBaseDeliveryRoot(base)
+	if err != nil {
+		return session
+	}
+	defer func() { _ = baseRoot.Close() }()
+	renames, err := loadSessionRenames(baseRoot)
+	if err != nil {
+		return session
+	}
+	current := session
+	for range len(renames.Renames) {
+		next, ok := renames.Renames[current]
+		if !ok {
+			break
+		}
+		current = next
+		if dirExists(filepath.Join(base, current)) {
+			return current
+		}
+	}
+	return session
+}
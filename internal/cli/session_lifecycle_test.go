@@ -0,0 +1,199 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/avivsinai/agent-message-queue/internal/format"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+)
+
+func TestSessionArchiveRefusesLiveWakeThenHidesSession(t *testing.T) {
+	tmp := t.TempDir()
+	base := filepath.Join(tmp, ".agent-mail")
+	root := sessionRoot(t, tmp, "feature", "claude", "codex")
+	sessionRoot(t, tmp, "main", "claude", "codex")
+	if err := runSend([]string{"--root", root, "--me", "claude", "--to", "codex", "--body", "keep me"}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	writeWakeLockForTest(t, root, "codex", wakeLock{PID: 4242, ProcessStart: "start-1", BootID: "boot-1"})
+	running := true
+	stubInspectWakeProcess(t, func(pid int) wakeProcessInfo {
+		return wakeProcessInfo{PID: pid, Running: running, StartToken: "start-1", BootID: "boot-1",
+			Args: []string{"amq", "wake", "--root", root, "--me", "codex"}}
+	})
+
+	for _, run := range []func() error{
+		func() error { return runSessionArchive([]string{"--root", base, "feature"}) },
+		func() error { return runSessionRm([]string{"--root", base, "feature", "--yes"}) },
+		func() error { return runSessionRename([]string{"--root", base, "feature", "renamed"}) },
+	} {
+		_, _, err := captureEnvOutput(t, run)
+		if GetExitCode(err) != ExitActionRequired || !strings.Contains(err.Error(), "wake lock for codex") {
+			t.Fatalf("live session mutation = %v, want action-required wake refusal", err)
+		}
+	}
+	if !dirExists(root) {
+		t.Fatal("refused mutation removed the session")
+	}
+
+	running = false
+	var archived sessionArchiveResult
+	stdout, _, err := captureEnvOutput(t, func() error {
+		return runSessionArchive([]string{"--root", base, "feature", "--json"})
+	})
+	if err != nil {
+		t.Fatalf("archive stale session: %v", err)
+	}
+	if err := unmarshalJSONOutput(stdout, &archived); err != nil {
+		t.Fatal(err)
+	}
+	if dirExists(root) {
+		t.Fatal("archived session still present")
+	}
+	if rel, _ := filepath.Rel(base, archived.Archive); !strings.HasPrefix(filepath.ToSlash(rel), sessionArchiveDir+"/feature-") {
+		t.Fatalf("archive = %s, want under %s", archived.Archive, sessionArchiveDir)
+	}
+	var listed sessionListResult
+	stdout, _, err = captureEnvOutput(t, func() error {
+		return runSessionList([]string{"--root", base, "--json"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := unmarshalJSONOutput(stdout, &listed); err != nil {
+		t.Fatal(err)
+	}
+	for _, skipped := range listed.Skipped {
+		if skipped.Name != "meta" {
+			t.Fatalf("session list skipped %+v after archive", skipped)
+		}
+	}
+	if len(listed.Sessions) != 1 || listed.Sessions[0].Name != "main" {
+		t.Fatalf("session list after archive = %+v", listed)
+	}
+
+	if _, _, err := captureEnvOutput(t, func() error {
+		return runRestore([]string{"--from", archived.Archive, "--root", root})
+	}); err != nil {
+		t.Fatalf("restore archived session: %v", err)
+	}
+	if n := inboxCount(t, root, "codex"); n != 1 {
+		t.Fatalf("restored inbox = %d messages, want 1", n)
+	}
+}
+
+func TestSessionRmDeletesIdleSession(t *testing.T) {
+	tmp := t.TempDir()
+	base := filepath.Join(tmp, ".agent-mail")
+	root := sessionRoot(t, tmp, "scratch", "claude")
+	if _, _, err := captureEnvOutput(t, func() error {
+		return runSessionRm([]string{"--root", base, "scratch", "--yes"})
+	}); err != nil {
+		t.Fatalf("rm: %v", err)
+	}
+	if _, err := os.Lstat(root); !os.IsNotExist(err) {
+		t.Fatalf("session still present: %v", err)
+	}
+	_, _, err := captureEnvOutput(t, func() error {
+		return runSessionRm([]string{"--root", base, "scratch", "--yes"})
+	})
+	if GetExitCode(err) != ExitNotFound {
+		t.Fatalf("rm missing session = %v, want not found", err)
+	}
+}
+
+func TestSessionRenameRoutesOldReplyToHints(t *testing.T) {
+	tmp := t.TempDir()
+	base := filepath.Join(tmp, ".agent-mail")
+	collab := sessionRoot(t, tmp, "collab", "claude")
+	auth := sessionRoot(t, tmp, "auth", "codex")
+	t.Setenv("AM_ROOT", collab)
+	t.Setenv("AM_BASE_ROOT", base)
+	if err := runSend([]string{"--me", "claude", "--to", "codex", "--session", "auth", "--body", "hi"}); err != nil {
+		t.Fatalf("cross-session send: %v", err)
+	}
+	original := soleDeliveredHeader(t, auth, "codex")
+	if original.ReplyTo != "claude@collab" {
+		t.Fatalf("reply_to = %q", original.ReplyTo)
+	}
+
+	t.Setenv("AM_ROOT", "")
+	t.Setenv("AM_BASE_ROOT", "")
+	var renamed sessionRenameResult
+	stdout, _, err := captureEnvOutput(t, func() error {
+		return runSessionRename([]string{"--root", base, "collab", "pairing", "--json"})
+	})
+	if err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	if err := unmarshalJSONOutput(stdout, &renamed); err != nil {
+		t.Fatal(err)
+	}
+	pairing := filepath.Join(base, "pairing")
+	if renamed.To != "pairing" || dirExists(collab) || !dirExists(pairing) || renamed.JournalRebound {
+		t.Fatalf("rename = %+v", renamed)
+	}
+
+	t.Setenv("AM_ROOT", auth)
+	t.Setenv("AM_BASE_ROOT", base)
+	if _, _, err := captureEnvOutput(t, func() error {
+		return runReply([]string{"--me", "codex", "--id", original.ID, "--body", "ack"})
+	}); err != nil {
+		t.Fatalf("reply after rename: %v", err)
+	}
+	reply := soleDeliveredHeader(t, pairing, "claude")
+	if reply.Thread != original.Thread {
+		t.Fatalf("reply thread = %q, want %q", reply.Thread, original.Thread)
+	}
+}
+
+func TestSessionForkSeedsSelectedThreadsAsHistory(t *testing.T) {
+	tmp := t.TempDir()
+	base := filepath.Join(tmp, ".agent-mail")
+	src := sessionRoot(t, tmp, "design", "claude", "codex")
+	for _, args := range [][]string{
+		{"--root", src, "--me", "claude", "--to", "codex", "--thread", "api", "--body", "option a"},
+		{"--root", src, "--me", "codex", "--to", "claude", "--thread", "api", "--body", "option b"},
+		{"--root", src, "--me", "claude", "--to", "codex", "--thread", "other", "--body", "unrelated"},
+	} {
+		if err := runSend(args); err != nil {
+			t.Fatalf("send: %v", err)
+		}
+	}
+
+	var forked sessionForkResult
+	stdout, _, err := captureEnvOutput(t, func() error {
+		return runSessionFork([]string{"--root", base, "design", "design-alt", "--threads", "api,missing", "--json"})
+	})
+	if err != nil {
+		t.Fatalf("fork: %v", err)
+	}
+	if err := unmarshalJSONOutput(stdout, &forked); err != nil {
+		t.Fatal(err)
+	}
+	// Two messages, each with one inbox copy and one outbox copy.
+	if forked.Messages != 4 || strings.Join(forked.Threads, ",") != "api" ||
+		strings.Join(forked.MissingThreads, ",") != "missing" || strings.Join(forked.Agents, ",") != "claude,codex" {
+		t.Fatalf("fork = %+v", forked)
+	}
+	dst := filepath.Join(base, "design-alt")
+	for _, agent := range []string{"claude", "codex"} {
+		if n := inboxCount(t, dst, agent); n != 0 {
+			t.Fatalf("%s inbox/new = %d, want seeded history only", agent, n)
+		}
+		entries, err := os.ReadDir(fsq.AgentInboxCur(dst, agent))
+		if err != nil || len(entries) != 1 {
+			t.Fatalf("%s inbox/cur = %v, %v", agent, entries, err)
+		}
+		header, err := format.ReadHeaderFile(filepath.Join(fsq.AgentInboxCur(dst, agent), entries[0].Name()))
+		if err != nil || header.Thread != "api" {
+			t.Fatalf("%s seeded header = %+v, %v", agent, header, err)
+		}
+	}
+	if n := inboxCount(t, src, "codex"); n != 2 {
+		t.Fatalf("source inbox changed: %d", n)
+	}
+}
@@ -237,6 +237,7 @@ func runSetup(args []string) (returnErr error) {
 	if err != nil {
 		return err
 	}
+	registerProjectOwning("")
 	status := "configured"
 	if len(written) == 0 {
 		status = "unchanged"
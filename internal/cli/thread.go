@@ -19,8 +19,14 @@ func runThread(args []string) error {
 	agentsFlag := fs.String("agents", "", "Comma-separated agent handles (optional)")
 	includeBody := fs.Bool("include-body", false, "Include body in output")
 	limitFlag := fs.Int("limit", 0, "Limit number of messages (0 = no limit)")
+	acrossFlag := fs.String("across", "", "Also follow peer projects and/or sibling sessions (peers,registry,sessions)")
 
-	usage := usageWithFlags(fs, "amq thread --id <thread_id> [options]")
+	usage := usageWithFlags(fs, "amq thread --id <thread_id> [options]",
+		"--across peers,sessions merges the thread from configured peer projects and",
+		"sibling sessions by message ID and annotates each entry with its origins.",
+		"peers reads the .amqrc peers; registry also reads every project in the",
+		"local project registry.",
+	)
 	if handled, err := parseFlags(fs, args, usage); err != nil {
 		return err
 	} else if handled {
@@ -39,22 +45,17 @@ func runThread(args []string) error {
 	if err != nil {
 		return UsageError("--agents: %v", err)
 	}
-	if len(agents) == 0 {
-		if cfg, err := config.LoadConfig(filepath.Join(root, "meta", "config.json")); err == nil {
-			agents, err = parseHandles(strings.Join(cfg.Agents, ","))
-			if err != nil {
-				return err
-			}
-		} else {
-			var listErr error
-			agents, listErr = fsq.ListAgents(root)
-			if listErr != nil {
-				return fmt.Errorf("list agents: %w", listErr)
-			}
+	if strings.TrimSpace(*acrossFlag) != "" {
+		across, err := parseThreadAcross(*acrossFlag)
+		if err != nil {
+			return err
 		}
+		return runThreadAcross(root, threadID, agents, across, *includeBody, *limitFlag, common.JSON)
 	}
 	if len(agents) == 0 {
-		return fmt.Errorf("no agents found; provide --agents")
+		if agents, err = threadAgents(root); err != nil {
+			return err
+		}
 	}
 
 	entries, err := thread.Collect(root, threadID, agents, *includeBody, func(path string, parseErr error) error {
@@ -90,3 +91,62 @@ func runThread(args []string) error {
 	}
 	return nil
 }
+
+func runThreadAcross(root, threadID string, agents []string, across map[string]bool, includeBody bool, limit int, jsonOut bool) error {
+	roots, err := threadScanRoots(root, across)
+	if err != nil {
+		return err
+	}
+	entries, err := collectThreadAcross(roots, threadID, agents, includeBody)
+	if err != nil {
+		return err
+	}
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+
+	if jsonOut {
+		return writeJSON(os.Stdout, entries)
+	}
+
+	for _, entry := range entries {
+		subject := entry.Subject
+		if subject == "" {
+			subject = "(no subject)"
+		}
+		if err := writeStdout("%s  %s  %s  %s\n", entry.Created, entry.From, formatThreadOrigins(entry.Origins), subject); err != nil {
+			return err
+		}
+		if includeBody {
+			if err := writeStdoutLine(entry.Body); err != nil {
+				return err
+			}
+			if err := writeStdoutLine("---"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// threadAgents returns the configured roster for root, falling back to the
+// agent directories present on disk.
+func threadAgents(root string) ([]string, error) {
+	var agents []string
+	if cfg, err := config.LoadConfig(filepath.Join(root, "meta", "config.json")); err == nil {
+		agents, err = parseHandles(strings.Join(cfg.Agents, ","))
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		var listErr error
+		agents, listErr = fsq.ListAgents(root)
+		if listErr != nil {
+			return nil, fmt.Errorf("list agents: %w", listErr)
+		}
+	}
+	if len(agents) == 0 {
+		return nil, fmt.Errorf("no agents found; provide --agents")
+	}
+	return agents, nil
+}
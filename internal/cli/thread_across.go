@@ -0,0 +1,235 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/avivsinai/agent-message-queue/internal/format"
+	"github.com/avivsinai/agent-message-queue/internal/thread"
+)
+
+const (
+	threadAcrossPeers    = "peers"
+	threadAcrossRegistry = "registry"
+	threadAcrossSessions = "sessions"
+)
+
+// threadOrigin names one root where a copy of a thread message was found.
+type threadOrigin struct {
+	Project string `json:"project,omitempty"`
+	Session string `json:"session,omitempty"`
+	Root    string `json:"root"`
+}
+
+// threadAcrossEntry is a thread entry merged by message ID across roots.
+type threadAcrossEntry struct {
+	thread.Entry
+	Origins []threadOrigin `json:"origins"`
+}
+
+func parseThreadAcross(raw string) (map[string]bool, error) {
+	across := map[string]bool{}
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		switch part {
+		case "":
+			continue
+		case threadAcrossPeers, threadAcrossRegistry, threadAcrossSessions:
+			across[part] = true
+		default:
+			return nil, UsageError("--across: unknown scope %q (want peers, registry, sessions)", part)
+		}
+	}
+	if len(across) == 0 {
+		return nil, UsageError("--across requires peers, registry, sessions, or a combination")
+	}
+	return across, nil
+}
+
+// threadScanRoots lists the roots a cross-root thread view reads: the current
+// root, sibling sessions, .amqrc peers, and, only when asked for, every
+// project in the local registry. Peer roots go through the same
+// planDeliveryRoute identity checks as send --project; a peer that fails them
+// is skipped with a warning rather than aborting the view.
+func threadScanRoots(root string, across map[string]bool) ([]threadOrigin, error) {
+	root = absPath(resolveRoot(root))
+	sourceProject := resolveProject(root)
+	var roots []threadOrigin
+	seen := map[string]bool{}
+	add := func(path, project, session string) {
+		path = absPath(path)
+		if seen[path] {
+			return
+		}
+		seen[path] = true
+		roots = append(roots, threadOrigin{Project: project, Session: session, Root: path})
+	}
+
+	base := classifyRoot(root)
+	currentSession := ""
+	if base != "" {
+		currentSession = sessionName(root)
+	}
+	add(root, sourceProject, currentSession)
+
+	if across[threadAcrossSessions] {
+		if base == "" {
+			base = root
+		}
+		for _, name := range threadSessionNames(base) {
+			sessionRoot, err := resolveSessionRoot(base, name)
+			if err != nil {
+				_ = writeStderr("warning: skipping session %q: %v\n", name, err)
+				continue
+			}
+			add(sessionRoot, sourceProject, name)
+		}
+	}
+
+	if !across[threadAcrossPeers] && !across[threadAcrossRegistry] {
+		return roots, nil
+	}
+	routeConfig, err := findDeliveryRouteAmqrc(root)
+	if err != nil {
+		return nil, federationSourceProjectError(root)
+	}
+	if sourceProject, err = requireFederationSourceProject(routeConfig, root); err != nil {
+		return nil, err
+	}
+	for _, peer := range threadPeerNames(routeConfig, sourceProject, across[threadAcrossRegistry]) {
+		if !across[threadAcrossSessions] {
+			plan, err := planDeliveryRoute(root, peer, "", deliveryRouteOptions{MirrorPeerSession: true})
+			if err != nil {
+				warnThreadPeerSkip(peer, err)
+				continue
+			}
+			add(plan.DeliveryRoot, peer, plan.TargetSession)
+			continue
+		}
+		plan, err := planDeliveryRoute(root, peer, "", deliveryRouteOptions{})
+		if err != nil {
+			_ = writeStderr("warning: skipping peer %q: %v\n", peer, err)
+			continue
+		}
+		if dirExists(filepath.Join(plan.PeerBaseRoot, "agents")) {
+			add(plan.PeerBaseRoot, peer, "")
+		}
+		for _, name := range threadSessionNames(plan.PeerBaseRoot) {
+			sessionPlan, err := planDeliveryRoute(root, peer, name, deliveryRouteOptions{})
+			if err != nil {
+				_ = writeStderr("warning: skipping peer %q session %q: %v\n", peer, name, err)
+				continue
+			}
+			add(sessionPlan.DeliveryRoot, peer, sessionPlan.TargetSession)
+		}
+	}
+	return roots, nil
+}
+
+// threadSessionNames returns canonical session names under base.
+func threadSessionNames(base string) []string {
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, entry := range entries {
+		item, _, skip := classifySessionChild(base, entry.Name())
+		if skip || item.Kind != sessionKindCanonical {
+			continue
+		}
+		names = append(names, item.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// threadPeerNames returns the configured .amqrc peers, plus every registry
+// project with withRegistry, excluding the source project itself. The
+// registry lists every project this user has run amq in, so it is read only
+// on request.
+func threadPeerNames(routeConfig amqrcResult, sourceProject string, withRegistry bool) []string {
+	set := map[string]bool{}
+	for name := range routeConfig.Config.Peers {
+		set[name] = true
+	}
+	if withRegistry {
+		if registry, _, err := loadProjectRegistry(); err == nil {
+			for name := range registry.Projects {
+				set[name] = true
+			}
+		} else {
+			_ = writeStderr("warning: %v\n", err)
+		}
+	}
+	delete(set, sourceProject)
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// collectThreadAcross merges a thread's entries from every scan root by
+// message ID. A message seen in several roots (for example the sender's
+// outbox and a peer's inbox) is reported once with all of its origins.
+func collectThreadAcross(roots []threadOrigin, threadID string, explicitAgents []string, includeBody bool) ([]threadAcrossEntry, error) {
+	byID := map[string]int{}
+	merged := []threadAcrossEntry{}
+	for _, scan := range roots {
+		agents := explicitAgents
+		if len(agents) == 0 {
+			var err error
+			agents, err = threadAgents(scan.Root)
+			if err != nil {
+				_ = writeStderr("warning: skipping %s: %v\n", scan.Root, err)
+				continue
+			}
+		}
+		entries, err := thread.Collect(scan.Root, threadID, agents, includeBody, func(path string, parseErr error) error {
+			return writeStderr("warning: skipping corrupt message %s: %v\n", filepath.Base(path), parseErr)
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if idx, ok := byID[entry.ID]; ok {
+				merged[idx].Origins = append(merged[idx].Origins, scan)
+				continue
+			}
+			byID[entry.ID] = len(merged)
+			merged = append(merged, threadAcrossEntry{Entry: entry, Origins: []threadOrigin{scan}})
+		}
+	}
+	format.SortByTimestamp(merged)
+	return merged, nil
+}
+
+func formatThreadOrigins(origins []threadOrigin) string {
+	labels := make([]string, 0, len(origins))
+	for _, origin := range origins {
+		label := origin.Project
+		if origin.Session != "" {
+			label += ":" + origin.Session
+		}
+		if label == "" {
+			label = origin.Root
+		}
+		labels = append(labels, "@"+label)
+	}
+	return fmt.Sprintf("[%s]", strings.Join(labels, " "))
+}
+
+// warnThreadPeerSkip reports a peer that cannot be read. A peer without the
+// mirrored session is expected (most projects never join a given session) and
+// is skipped quietly.
+func warnThreadPeerSkip(peer string, err error) {
+	if GetExitCode(err) == ExitNotFound {
+		return
+	}
+	_ = writeStderr("warning: skipping peer %q: %v\n", peer, err)
+}
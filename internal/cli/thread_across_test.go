@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/avivsinai/agent-message-queue/internal/format"
+)
+
+func writeThreadCopyForTest(t *testing.T, dir, id, from, to, created string) {
+	t.Helper()
+	msg := format.Message{
+		Header: format.Header{
+			Schema:  format.CurrentSchema,
+			ID:      id,
+			From:    from,
+			To:      []string{to},
+			Thread:  "decision/release",
+			Subject: id,
+			Created: created,
+		},
+		Body: "body of " + id,
+	}
+	data, err := msg.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, id+".md"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestThreadAcrossPeersAndSessionsMergesByID(t *testing.T) {
+	useTestProjectRegistry(t)
+	srcProjectDir := filepath.Join(t.TempDir(), "app")
+	srcBase := filepath.Join(srcProjectDir, ".agent-mail")
+	collab := filepath.Join(srcBase, "collab")
+	qa := filepath.Join(srcBase, "qa")
+	peerBase := filepath.Join(t.TempDir(), "infra", ".agent-mail")
+	peerCollab := filepath.Join(peerBase, "collab")
+	ensureRouteAgents(t, collab, "claude", "codex")
+	ensureRouteAgents(t, qa, "tester")
+	ensureRouteAgents(t, peerCollab, "codex")
+	writeRouteAmqrc(t, srcProjectDir, map[string]any{
+		"root":    ".agent-mail",
+		"project": "app",
+		"peers":   map[string]string{"infra": peerBase},
+	})
+	t.Setenv("AM_BASE_ROOT", srcBase)
+
+	// m1 crossed projects: sender outbox in app, recipient inbox in infra.
+	writeThreadCopyForTest(t, filepath.Join(collab, "agents", "claude", "outbox", "sent"), "m1", "claude", "codex", "2026-01-01T00:00:01Z")
+	writeThreadCopyForTest(t, filepath.Join(peerCollab, "agents", "codex", "inbox", "new"), "m1", "claude", "codex", "2026-01-01T00:00:01Z")
+	writeThreadCopyForTest(t, filepath.Join(qa, "agents", "tester", "inbox", "cur"), "m2", "claude", "tester", "2026-01-01T00:00:02Z")
+	writeThreadCopyForTest(t, filepath.Join(peerCollab, "agents", "codex", "outbox", "sent"), "m3", "codex", "claude", "2026-01-01T00:00:03Z")
+
+	stdout, _, err := captureEnvOutput(t, func() error {
+		return runThread([]string{"--root", collab, "--id", "decision/release", "--across", "peers,sessions", "--json"})
+	})
+	if err != nil {
+		t.Fatalf("thread --across: %v", err)
+	}
+	var entries []threadAcrossEntry
+	if err := unmarshalJSONOutput(stdout, &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[0].ID != "m1" || entries[1].ID != "m2" || entries[2].ID != "m3" {
+		t.Fatalf("entries = %+v, want m1, m2, m3", entries)
+	}
+	if got := entries[0].Origins; len(got) != 2 || got[0].Project != "app" || got[0].Session != "collab" || got[1].Project != "infra" || got[1].Session != "collab" {
+		t.Fatalf("m1 origins = %+v, want app:collab then infra:collab", got)
+	}
+	if got := entries[1].Origins; len(got) != 1 || got[0].Session != "qa" {
+		t.Fatalf("m2 origins = %+v, want app:qa", got)
+	}
+
+	stdout, _, err = captureEnvOutput(t, func() error {
+		return runThread([]string{"--root", collab, "--id", "decision/release", "--across", "sessions"})
+	})
+	if err != nil {
+		t.Fatalf("thread --across sessions: %v", err)
+	}
+	if !strings.Contains(stdout, "[@app:collab]") || !strings.Contains(stdout, "[@app:qa]") || strings.Contains(stdout, "@infra") {
+		t.Fatalf("sessions-only text output = %q", stdout)
+	}
+
+	if _, _, err := captureEnvOutput(t, func() error {
+		return runThread([]string{"--root", collab, "--id", "decision/release", "--across", "projects"})
+	}); GetExitCode(err) != ExitUsage {
+		t.Fatalf("unknown scope exit = %d (%v), want usage", GetExitCode(err), err)
+	}
+}
+
+func TestThreadAcrossPeersReadsRegistryOnlyOnRequest(t *testing.T) {
+	useTestProjectRegistry(t)
+	srcProjectDir := filepath.Join(t.TempDir(), "app")
+	srcBase := filepath.Join(srcProjectDir, ".agent-mail")
+	collab := filepath.Join(srcBase, "collab")
+	unrelatedBase := filepath.Join(t.TempDir(), "unrelated", ".agent-mail")
+	unrelatedCollab := filepath.Join(unrelatedBase, "collab")
+	ensureRouteAgents(t, collab, "claude")
+	ensureRouteAgents(t, unrelatedCollab, "codex")
+	writeRouteAmqrc(t, srcProjectDir, map[string]any{
+		"root":    ".agent-mail",
+		"project": "app",
+		"peers":   map[string]string{},
+	})
+	if _, err := registerProject("unrelated", unrelatedBase); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AM_BASE_ROOT", srcBase)
+	writeThreadCopyForTest(t, filepath.Join(unrelatedCollab, "agents", "codex", "inbox", "new"), "m1", "claude", "codex", "2026-01-01T00:00:01Z")
+
+	stdout, _, err := captureEnvOutput(t, func() error {
+		return runThread([]string{"--root", collab, "--id", "decision/release", "--across", "peers"})
+	})
+	if err != nil {
+		t.Fatalf("thread --across peers: %v", err)
+	}
+	if strings.Contains(stdout, "@unrelated") {
+		t.Fatalf("--across peers read a registry project that is not an .amqrc peer: %q", stdout)
+	}
+
+	stdout, _, err = captureEnvOutput(t, func() error {
+		return runThread([]string{"--root", collab, "--id", "decision/release", "--across", "registry"})
+	})
+	if err != nil {
+		t.Fatalf("thread --across registry: %v", err)
+	}
+	if !strings.Contains(stdout, "[@unrelated:collab]") {
+		t.Fatalf("--across registry output = %q, want the registry project", stdout)
+	}
+}
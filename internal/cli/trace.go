@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
@@ -8,6 +9,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/avivsinai/agent-message-queue/internal/delivery"
 	"github.com/avivsinai/agent-message-queue/internal/format"
 	"github.com/avivsinai/agent-message-queue/internal/fsq"
 	"github.com/avivsinai/agent-message-queue/internal/receipt"
@@ -21,6 +23,7 @@ var traceLegOrder = []string{
 	"delivery",
 	"dlq",
 	"receipts",
+	"bridge",
 	"thread",
 	"notification",
 }
@@ -42,19 +45,21 @@ type traceLeg struct {
 }
 
 type traceEvidence struct {
-	Authority  string              `json:"authority"`
-	Path       string              `json:"path,omitempty"`
-	Agent      string              `json:"agent,omitempty"`
-	Area       string              `json:"area,omitempty"`
-	Box        string              `json:"box,omitempty"`
-	Message    *traceMessage       `json:"message,omitempty"`
-	Route      *traceRouteEvidence `json:"route,omitempty"`
-	DLQ        *fsq.DLQEnvelope    `json:"dlq,omitempty"`
-	Receipt    *receipt.Receipt    `json:"receipt,omitempty"`
-	Relation   *traceRelation      `json:"relation,omitempty"`
-	State      string              `json:"state,omitempty"`
-	Durability string              `json:"durability,omitempty"`
-	Limitation string              `json:"limitation,omitempty"`
+	Authority  string               `json:"authority"`
+	Path       string               `json:"path,omitempty"`
+	Agent      string               `json:"agent,omitempty"`
+	Area       string               `json:"area,omitempty"`
+	Box        string               `json:"box,omitempty"`
+	Message    *traceMessage        `json:"message,omitempty"`
+	Route      *traceRouteEvidence  `json:"route,omitempty"`
+	DLQ        *fsq.DLQEnvelope     `json:"dlq,omitempty"`
+	Receipt    *receipt.Receipt     `json:"receipt,omitempty"`
+	Relation   *traceRelation       `json:"relation,omitempty"`
+	Delegation *delivery.Hop        `json:"delegation,omitempty"`
+	Bridge     *traceBridgeEvidence `json:"bridge,omitempty"`
+	State      string               `json:"state,omitempty"`
+	Durability string               `json:"durability,omitempty"`
+	Limitation string               `json:"limitation,omitempty"`
 }
 
 type traceMessage struct {
@@ -111,7 +116,8 @@ func runTrace(args []string) error {
 		"Join current on-disk evidence for one message without mutating the queue.",
 		"",
 		"Phase A reports message copies, route fields, visible delivery artifacts, DLQ entries,",
-		"delivery receipts, thread references, and explicit no_evidence for notification attempts.",
+		"delivery receipts, amq-bridge transfers, thread references, and explicit no_evidence for",
+		"notification attempts. An xfer-* filename from a bridge resolves to its message id.",
 	)
 
 	messageID := ""
@@ -189,9 +195,13 @@ func collectTrace(root, messageID string) traceResult {
 	defer func() { _ = deliveryRoot.Close() }()
 
 	collector.agents = collector.listAgents()
+	collector.resolveTransferName()
 	collector.scanMessages()
 	collector.scanDLQ()
+	collector.scanBridge()
 	collector.scanReceipts()
+	collector.scanRemoteReceipts()
+	collector.scanQueueLeases()
 	collector.joinHeaders()
 	collector.finishLegs()
 	return collector.result()
@@ -400,6 +410,38 @@ func (c *traceCollector) scanReceipts() {
 	}
 }
 
+// scanQueueLeases reports queue claims on the message. A lease names the
+// member currently holding a queue item, or the one that completed it.
+func (c *traceCollector) scanQueueLeases() {
+	for _, agent := range c.agents {
+		path := filepath.Join(queueLeaseDir(agent), queueLeaseName(c.messageID+".md"))
+		data, err := c.deliveryRoot.ReadRegularNoFollow(path)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				c.addError("delivery", fmt.Sprintf("read %s: %v", c.relative(path), err))
+			}
+			continue
+		}
+		var lease queueLease
+		if err := json.Unmarshal(data, &lease); err != nil {
+			c.addError("delivery", fmt.Sprintf("parse %s: %v", c.relative(path), err))
+			continue
+		}
+		state := receipt.StageClaimed
+		if lease.CompletedAt != "" {
+			state = receipt.StageCompleted
+		}
+		c.addEvidence("delivery", traceEvidence{
+			Authority: "queue_lease",
+			Path:      c.relative(path),
+			Agent:     lease.Claimer,
+			Area:      "queue",
+			Box:       agent,
+			State:     state,
+		})
+	}
+}
+
 func (c *traceCollector) addTarget(located traceLocatedHeader, authority string) {
 	c.targets = append(c.targets, located)
 	header := located.header
@@ -431,6 +473,17 @@ func (c *traceCollector) addTarget(located traceLocatedHeader, authority string)
 		})
 	}
 
+	// A delegate's copy records the out-of-office hop that put it there.
+	if hop, ok := delivery.HopFromContext(header.Context); ok {
+		c.addEvidence("route", traceEvidence{
+			Authority:  "delegation",
+			Path:       located.path,
+			Agent:      hop.To,
+			State:      hop.Mode,
+			Delegation: &hop,
+		})
+	}
+
 	routeKey := strings.Join([]string{
 		header.From,
 		strings.Join(header.To, ","),
@@ -534,9 +587,13 @@ func (c *traceCollector) finishLegs() {
 			next:   "if a DLQ transition was expected, run 'amq dlq list --me <consumer> --json' in the target root",
 		},
 		"receipts": {
-			detail: "no drained or dlq delivery receipt was found",
+			detail: "no drained, dlq, claimed, or completed receipt was found",
 			next:   "run 'amq receipts list --me <consumer> --msg-id " + c.messageID + " --json' for the expected consumer",
 		},
+		"bridge": {
+			detail: "no bridge receipt, bridge spool copy, or xfer transfer file mentions this message",
+			next:   "if the message crossed hosts, run 'amq-bridge status' on the sending host; a courier with a custom --receipt-dir or --spool is not scanned",
+		},
 		"thread": {
 			detail: "no message refs connect another message to this id",
 			next:   "inspect the message thread with 'amq thread --id <thread-id> --json' when a parsable header is available",
@@ -558,6 +615,8 @@ func (c *traceCollector) finishLegs() {
 			case "route":
 				leg.Detail = "addressing metadata found; persisted send-time resolver decisions are no_evidence"
 				leg.NextStep = "use 'amq route explain' only to inspect current routing; do not treat it as historical evidence"
+			case "bridge":
+				leg.Detail = "amq-bridge transfer evidence found; transport_accepted alone does not prove a destination commit"
 			case "delivery":
 				leg.Detail = "current file visibility found; original directory sync durability is no_evidence"
 				leg.NextStep = "inspect retained send output before retrying; do not infer retry safety from current file presence"
@@ -582,7 +641,7 @@ func (c *traceCollector) addError(legName, detail string) {
 }
 
 func (c *traceCollector) addRootError(err error) {
-	for _, name := range []string{"message", "dlq", "receipts", "thread"} {
+	for _, name := range []string{"message", "dlq", "receipts", "bridge", "thread"} {
 		c.addError(name, fmt.Sprintf("open root: %v", err))
 	}
 }
@@ -655,7 +714,13 @@ func traceEvidenceText(legName string, evidence traceEvidence) string {
 		if evidence.Route != nil {
 			return fmt.Sprintf("%s -> %s; thread %s", evidence.Route.From, strings.Join(evidence.Route.To, ","), evidence.Route.Thread)
 		}
+		if evidence.Delegation != nil {
+			return fmt.Sprintf("delegated: %s away, %s to %s at %s", evidence.Delegation.For, evidence.Delegation.Mode, evidence.Delegation.To, evidence.Delegation.ForwardedAt)
+		}
 	case "delivery":
+		if evidence.Authority == "queue_lease" {
+			return fmt.Sprintf("%s: %s by %s", evidence.Path, evidence.State, evidence.Agent)
+		}
 		return fmt.Sprintf("%s visible; durability %s", evidence.Path, evidence.Durability)
 	case "dlq":
 		if evidence.DLQ != nil {
@@ -665,6 +730,13 @@ func traceEvidenceText(legName string, evidence traceEvidence) string {
 		if evidence.Receipt != nil {
 			return fmt.Sprintf("%s by %s at %s", evidence.Receipt.Stage, evidence.Receipt.Consumer, evidence.Receipt.EmittedAt)
 		}
+	case "bridge":
+		if evidence.Bridge != nil {
+			if evidence.Authority == "transfer_filename" {
+				return fmt.Sprintf("%s: transfer %s from %s", evidence.Path, valueOrUnknown(evidence.Bridge.TransferID), valueOrUnknown(evidence.Bridge.SourceHost))
+			}
+			return fmt.Sprintf("%s: %s -> %s", evidence.Path, evidence.State, valueOrUnknown(evidence.Bridge.DestAlias))
+		}
 	case "thread":
 		if evidence.Relation != nil {
 			return fmt.Sprintf("%s %s", evidence.Relation.Relation, evidence.Relation.MessageID)
@@ -0,0 +1,302 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+	"github.com/avivsinai/agent-message-queue/internal/receipt"
+)
+
+const (
+	traceBridgeReceiptsDir = "bridge/receipts"
+	traceRemoteReceiptsDir = "bridge/remote-receipts"
+	traceBridgeOutboxDir   = "bridge/outbox"
+	traceTransferPrefix    = "xfer-"
+)
+
+// traceBridgeEvidence is one amq-bridge observation of a transfer. Receipts
+// are the courier's own files in bridge/receipts; a spool copy is the
+// message as enqueued for the bridge.
+type traceBridgeEvidence struct {
+	TransferID      string `json:"transfer_id,omitempty"`
+	Stage           string `json:"stage,omitempty"`
+	Kind            string `json:"kind,omitempty"`
+	SourceMessageID string `json:"source_message_id,omitempty"`
+	SourceHost      string `json:"source_host,omitempty"`
+	SourceHandle    string `json:"source_handle,omitempty"`
+	DestAlias       string `json:"dest_alias,omitempty"`
+	PayloadSHA256   string `json:"payload_sha256,omitempty"`
+	Replayed        bool   `json:"replayed,omitempty"`
+	CommittedPath   string `json:"committed_path,omitempty"`
+	EmittedAt       string `json:"emitted_at,omitempty"`
+}
+
+// resolveTransferName maps an xfer-<host>-<transfer_id> filename, as written
+// by amq-bridge on the receiving host, to the message id in its header so
+// the rest of the trace joins on the original id.
+func (c *traceCollector) resolveTransferName() {
+	name := strings.TrimSuffix(c.messageID, ".md")
+	if !strings.HasPrefix(name, traceTransferPrefix) {
+		return
+	}
+	for _, agent := range c.agents {
+		for _, box := range []string{"new", "cur"} {
+			candidate := filepath.Join("agents", agent, "inbox", box, name+".md")
+			header, err := c.readHeader(candidate)
+			if err != nil {
+				if !errors.Is(err, os.ErrNotExist) {
+					c.addError("bridge", fmt.Sprintf("parse %s: %v", c.relative(candidate), err))
+				}
+				continue
+			}
+			if header.ID == "" {
+				continue
+			}
+			c.messageID = header.ID
+			return
+		}
+	}
+}
+
+// scanBridge joins amq-bridge state on either side of a hop: spool copies
+// and receipts naming the message id on the sending host, and receipts for
+// the xfer file that holds it on the receiving host.
+func (c *traceCollector) scanBridge() {
+	c.scanBridgeSpool()
+
+	// On the receiving host a copy is named xfer-<source_host>-<transfer_id>.md.
+	transferFiles := map[string]traceLocatedHeader{}
+	for _, target := range c.targets {
+		base := path.Base(target.path)
+		if target.area == "inbox" && strings.HasPrefix(base, traceTransferPrefix) {
+			transferFiles[strings.TrimSuffix(base, ".md")] = target
+		}
+	}
+
+	entries, err := c.readDir(traceBridgeReceiptsDir)
+	if errors.Is(err, os.ErrNotExist) {
+		c.addTransferFiles(transferFiles, nil)
+		return
+	}
+	if err != nil {
+		c.addError("bridge", fmt.Sprintf("scan %s: %v", traceBridgeReceiptsDir, err))
+		return
+	}
+	// A routed courier keeps each peer's receipts in bridge/receipts/<host>/.
+	var receiptPaths []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() {
+			receiptPaths = append(receiptPaths, path.Join(traceBridgeReceiptsDir, name))
+			continue
+		}
+		if fsq.ValidateHandle(name) != nil {
+			continue
+		}
+		routeDir := path.Join(traceBridgeReceiptsDir, name)
+		routed, err := c.readDir(routeDir)
+		if err != nil {
+			c.addError("bridge", fmt.Sprintf("scan %s: %v", routeDir, err))
+			continue
+		}
+		for _, r := range routed {
+			if !r.IsDir() {
+				receiptPaths = append(receiptPaths, path.Join(routeDir, r.Name()))
+			}
+		}
+	}
+	matchedFiles := map[string]traceBridgeEvidence{}
+	for _, receiptPath := range receiptPaths {
+		name := path.Base(receiptPath)
+		if strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		data, err := c.deliveryRoot.ReadRegularNoFollow(receiptPath)
+		if err != nil {
+			c.addError("bridge", fmt.Sprintf("read %s: %v", receiptPath, err))
+			continue
+		}
+		var item traceBridgeEvidence
+		if err := json.Unmarshal(data, &item); err != nil {
+			c.addError("bridge", fmt.Sprintf("parse %s: %v", receiptPath, err))
+			continue
+		}
+		matched := item.SourceMessageID == c.messageID
+		for file := range transferFiles {
+			if item.TransferID != "" && strings.HasSuffix(file, "-"+item.TransferID) {
+				matched = true
+				matchedFiles[file] = item
+			}
+		}
+		if !matched {
+			continue
+		}
+		itemCopy := item
+		c.addEvidence("bridge", traceEvidence{
+			Authority: "bridge_receipt",
+			Path:      receiptPath,
+			State:     item.Stage,
+			Bridge:    &itemCopy,
+		})
+	}
+	c.addTransferFiles(transferFiles, matchedFiles)
+}
+
+func (c *traceCollector) addTransferFiles(files map[string]traceLocatedHeader, receipts map[string]traceBridgeEvidence) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		target := files[name]
+		mapping := traceBridgeEvidence{SourceMessageID: target.header.ID}
+		if item, ok := receipts[name]; ok {
+			mapping.TransferID = item.TransferID
+			mapping.SourceHost = item.SourceHost
+			mapping.SourceHandle = item.SourceHandle
+			mapping.DestAlias = item.DestAlias
+		}
+		evidence := traceEvidence{
+			Authority: "transfer_filename",
+			Path:      target.path,
+			Agent:     target.agent,
+			Area:      target.area,
+			Box:       target.box,
+			Bridge:    &mapping,
+		}
+		if mapping.TransferID == "" {
+			evidence.Limitation = "no bridge receipt names this transfer; the courier may use a custom --receipt-dir"
+		}
+		c.addEvidence("bridge", evidence)
+	}
+}
+
+// scanRemoteReceipts reports the drained and dlq receipts amq-bridge brought
+// back from consumers on other hosts, kept under
+// bridge/remote-receipts/<host>/<consumer>. The agent is host/consumer.
+func (c *traceCollector) scanRemoteReceipts() {
+	hosts, err := c.readDir(traceRemoteReceiptsDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		c.addError("receipts", fmt.Sprintf("scan %s: %v", traceRemoteReceiptsDir, err))
+		return
+	}
+	for _, host := range hosts {
+		if !host.IsDir() {
+			continue
+		}
+		consumers, err := c.readDir(path.Join(traceRemoteReceiptsDir, host.Name()))
+		if err != nil {
+			c.addError("receipts", fmt.Sprintf("scan %s: %v", path.Join(traceRemoteReceiptsDir, host.Name()), err))
+			continue
+		}
+		for _, consumer := range consumers {
+			if !consumer.IsDir() {
+				continue
+			}
+			dir := path.Join(traceRemoteReceiptsDir, host.Name(), consumer.Name())
+			entries, err := c.readDir(dir)
+			if err != nil {
+				c.addError("receipts", fmt.Sprintf("scan %s: %v", dir, err))
+				continue
+			}
+			for _, entry := range entries {
+				if entry.IsDir() || !strings.HasPrefix(entry.Name(), c.messageID+"__") || !strings.HasSuffix(entry.Name(), ".json") {
+					continue
+				}
+				receiptPath := path.Join(dir, entry.Name())
+				item, err := receipt.ReadDeliveryRoot(c.deliveryRoot, receiptPath)
+				if err != nil {
+					c.addError("receipts", fmt.Sprintf("parse candidate %s: %v", receiptPath, err))
+					continue
+				}
+				if item.MsgID != c.messageID {
+					continue
+				}
+				itemCopy := item
+				c.addEvidence("receipts", traceEvidence{
+					Authority: "remote_receipt",
+					Path:      receiptPath,
+					Agent:     host.Name() + "/" + consumer.Name(),
+					Receipt:   &itemCopy,
+				})
+			}
+		}
+	}
+}
+
+// scanBridgeSpool finds the message in bridge/outbox/<handle>/{new,sent}.
+// A copy there is also a message copy for the message and route legs.
+func (c *traceCollector) scanBridgeSpool() {
+	handles, err := c.readDir(traceBridgeOutboxDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		c.addError("bridge", fmt.Sprintf("scan %s: %v", traceBridgeOutboxDir, err))
+		return
+	}
+	for _, handle := range handles {
+		if !handle.IsDir() {
+			continue
+		}
+		for _, box := range []string{"new", "sent"} {
+			dir := path.Join(traceBridgeOutboxDir, handle.Name(), box)
+			entries, err := c.readDir(dir)
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				c.addError("bridge", fmt.Sprintf("scan %s: %v", dir, err))
+				continue
+			}
+			for _, entry := range entries {
+				if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || !strings.HasSuffix(entry.Name(), ".md") {
+					continue
+				}
+				spoolPath := path.Join(dir, entry.Name())
+				header, err := c.readHeader(spoolPath)
+				if err != nil || header.ID != c.messageID {
+					continue
+				}
+				located := traceLocatedHeader{header: header, path: spoolPath, agent: handle.Name(), area: "bridge_outbox", box: box}
+				c.headers = append(c.headers, located)
+				c.addTarget(located, "bridge_spool")
+				state := "archived"
+				spooled := traceBridgeEvidence{SourceMessageID: header.ID, SourceHandle: handle.Name()}
+				if box == "new" {
+					state = "pending"
+					if dest, err := c.deliveryRoot.ReadRegularNoFollow(strings.TrimSuffix(spoolPath, ".md") + ".dest"); err == nil {
+						spooled.DestAlias = strings.TrimSpace(string(dest))
+					}
+				}
+				c.addEvidence("bridge", traceEvidence{
+					Authority: "bridge_spool",
+					Path:      spoolPath,
+					Agent:     handle.Name(),
+					Area:      "bridge_outbox",
+					Box:       box,
+					State:     state,
+					Bridge:    &spooled,
+				})
+			}
+		}
+	}
+}
+
+func valueOrUnknown(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	return value
+}
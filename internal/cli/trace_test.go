@@ -8,7 +8,10 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/avivsinai/agent-message-queue/internal/bridge"
+	"github.com/avivsinai/agent-message-queue/internal/format"
 	"github.com/avivsinai/agent-message-queue/internal/fsq"
+	"github.com/avivsinai/agent-message-queue/internal/receipt"
 )
 
 type traceResultJSON struct {
@@ -264,6 +267,109 @@ func TestTraceDegradesOnIncompleteMailboxLayout(t *testing.T) {
 	}
 }
 
+func TestTraceReadsRemoteReceiptOutsideAgents(t *testing.T) {
+	root := t.TempDir()
+	for _, agent := range []string{"alice", "carol"} {
+		if err := fsq.EnsureAgentDirs(root, agent); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// amq-bridge keeps mac/carol's receipt under bridge/remote-receipts; the
+	// local carol is a different agent and must not be credited with it.
+	data, err := receipt.New("msg-remote", "thread", "alice", "carol", receipt.StageDrained, "amq-bridge: drained on mac").Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(root, bridge.RemoteReceiptPath("mac", "carol", "msg-remote", receipt.StageDrained))
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	result := collectTrace(root, "msg-remote")
+	if result.Status != "found" {
+		t.Fatalf("status = %q, want found: %#v", result.Status, result.Legs)
+	}
+	leg := result.Legs["receipts"]
+	if len(leg.Evidence) != 1 || leg.Evidence[0].Authority != "remote_receipt" || leg.Evidence[0].Agent != "mac/carol" {
+		t.Fatalf("receipts leg = %#v, want mac/carol's remote receipt", leg)
+	}
+}
+
+func TestTraceJoinsBridgeLegOnBothHosts(t *testing.T) {
+	message, err := (format.Message{Header: format.Header{
+		Schema:  1,
+		ID:      "msg-bridged",
+		From:    "codex",
+		To:      []string{"claude"},
+		Thread:  "thread-bridged",
+		Created: "2026-09-01T12:00:00Z",
+	}, Body: "across hosts"}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFixture := func(root, rel string, data []byte) {
+		t.Helper()
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const transferID = "xfer-0123456789abcdef0123456789abcdef"
+	routing := `"source_message_id":"msg-bridged","source_host":"grok","source_handle":"codex","dest_alias":"mac/claude"`
+
+	// Sending host: an archived spool copy and its transport receipt.
+	sender := t.TempDir()
+	if err := fsq.EnsureAgentDirs(sender, "codex"); err != nil {
+		t.Fatal(err)
+	}
+	writeFixture(sender, "bridge/outbox/codex/sent/msg-bridged.md", message)
+	writeFixture(sender, "bridge/receipts/"+transferID+"__transport_accepted.json",
+		[]byte(`{"stage":"transport_accepted","transfer_id":"`+transferID+`","payload_sha256":"ab",`+routing+`,"emitted_at":"2026-09-01T12:00:01Z"}`))
+	result := collectTrace(sender, "msg-bridged")
+	if result.Status != "found" || result.Legs["message"].Status != "evidence" {
+		t.Fatalf("sender trace = %s %#v, want the spool copy as the message", result.Status, result.Legs["message"])
+	}
+	bridgeLeg := result.Legs["bridge"]
+	if bridgeLeg.Status != "evidence" || len(bridgeLeg.Evidence) != 2 {
+		t.Fatalf("sender bridge leg = %#v, want spool and receipt", bridgeLeg)
+	}
+	if got := bridgeLeg.Evidence[0]; got.Authority != "bridge_spool" || got.State != "archived" {
+		t.Fatalf("sender spool evidence = %#v", got)
+	}
+
+	// Receiving host: the xfer file and its commit receipt.
+	receiver := t.TempDir()
+	if err := fsq.EnsureAgentDirs(receiver, "claude"); err != nil {
+		t.Fatal(err)
+	}
+	xferName := "xfer-grok-" + transferID
+	writeFixture(receiver, "agents/claude/inbox/new/"+xferName+".md", message)
+	writeFixture(receiver, "bridge/receipts/"+transferID+"__destination_maildir_committed.json",
+		[]byte(`{"stage":"destination_maildir_committed","transfer_id":"`+transferID+`","payload_sha256":"ab","emitted_at":"2026-09-01T12:00:02Z"}`))
+	result = collectTrace(receiver, xferName)
+	if result.MessageID != "msg-bridged" || result.Status != "found" {
+		t.Fatalf("receiver trace by xfer name = %s %s, want the original message id", result.MessageID, result.Status)
+	}
+	bridgeLeg = result.Legs["bridge"]
+	if len(bridgeLeg.Evidence) != 2 {
+		t.Fatalf("receiver bridge leg = %#v, want the receipt and the filename mapping", bridgeLeg)
+	}
+	mapping := bridgeLeg.Evidence[1]
+	if mapping.Authority != "transfer_filename" || mapping.Bridge == nil || mapping.Bridge.TransferID != transferID || mapping.Bridge.SourceMessageID != "msg-bridged" {
+		t.Fatalf("transfer mapping = %#v", mapping)
+	}
+
+	if leg := collectTrace(sender, "msg-other").Legs["bridge"]; leg.Status != "no_evidence" || leg.NextStep == "" {
+		t.Fatalf("unrelated bridge leg = %#v", leg)
+	}
+}
+
 func TestTraceDoesNotFollowAgentsSymlinkOutsidePinnedRoot(t *testing.T) {
 	root := t.TempDir()
 	outside := t.TempDir()
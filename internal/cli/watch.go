@@ -21,6 +21,7 @@ type watchResult struct {
 }
 
 type msgInfo struct {
+	Agent        string   `json:"agent,omitempty"`
 	ID           string   `json:"id"`
 	From         string   `json:"from"`
 	Subject      string   `json:"subject"`
@@ -44,8 +45,12 @@ func runWatch(args []string) error {
 	pollFlag := fs.Bool("poll", false, "Use polling fallback instead of fsnotify (for network filesystems)")
 	sessionFlag := fs.String("session", "", "Target session under the resolved base root")
 	ignoreSessionPinFlag := fs.Bool("ignore-session-pin", false, "With explicit --root, ignore a conflicting AM_SESSION pin")
+	agentsFlag := fs.String("agents", "", "Watch these agents' inboxes instead of --me (comma-separated)")
+	allFlag := fs.Bool("all", false, "Watch every agent mailbox in the root instead of --me")
 
-	usage := usageWithFlags(fs, "amq watch --me <agent> [--session <name>] [options]")
+	usage := usageWithFlags(fs, "amq watch (--me <agent> | --agents <a,b,...> | --all) [--session <name>] [options]",
+		"With --agents or --all, one watcher follows several inboxes and each",
+		"message carries the agent it was delivered to.")
 	if handled, err := parseFlags(fs, args, usage); err != nil {
 		return err
 	} else if handled {
@@ -54,6 +59,11 @@ func runWatch(args []string) error {
 	if *timeoutFlag < 0 {
 		return UsageError("--timeout must be >= 0")
 	}
+	if multi, err := multiAgentRequested(*agentsFlag, flagWasVisited(fs, "agents"), *allFlag); err != nil {
+		return err
+	} else if multi {
+		return runWatchAgents(common, *sessionFlag, *ignoreSessionPinFlag, *agentsFlag, *allFlag, *timeoutFlag, *pollFlag)
+	}
 	if err := requireMe(common.Me); err != nil {
 		return err
 	}
@@ -85,6 +95,9 @@ func runWatch(args []string) error {
 	if err := requireMailboxDeliveryRoot(deliveryRoot, root, me); err != nil {
 		return err
 	}
+	if err := refuseQueueHandle(deliveryRoot, "watch", me); err != nil {
+		return err
+	}
 
 	// Validate handle against config.json
 	if err := validateKnownHandlesDeliveryRoot(deliveryRoot, common.Strict, me); err != nil {
@@ -334,7 +347,7 @@ func listNewMessages(
 			})
 			continue
 		}
-		if err := validator.validate(header); err != nil {
+		if err := validator.Validate(header); err != nil {
 			parseErr := "invalid header: " + err.Error()
 			id := baseID
 			if safeID, ok := safeHeaderID(header.ID); ok {
@@ -403,6 +416,9 @@ func outputWatchResult(jsonOutput bool, event string, messages []msgInfo) error
 		if msg.FromProject != "" {
 			fromDisplay = msg.From + " (project: " + msg.FromProject + ")"
 		}
+		if msg.Agent != "" {
+			fromDisplay += " -> " + msg.Agent
+		}
 		if err := writeStdout("  %s  %s  %s  %s\n", msg.Created, fromDisplay, msg.ID, subject); err != nil {
 			return err
 		}
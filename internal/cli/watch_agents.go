@@ -0,0 +1,365 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/config"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+)
+
+// monitorAgentsResult is amq monitor output when following several agents:
+// one monitorResult per agent that had messages, each tagged by its me.
+type monitorAgentsResult struct {
+	Event      string          `json:"event"`
+	WatchEvent string          `json:"watch_event,omitempty"`
+	Mode       string          `json:"mode,omitempty"`
+	Session    string          `json:"session,omitempty"`
+	Agents     []string        `json:"agents"`
+	Count      int             `json:"count"`
+	Results    []monitorResult `json:"results"`
+}
+
+// agentMailboxes is one delivery root opened for several agents' inboxes.
+type agentMailboxes struct {
+	root              string
+	deliveryRoot      *fsq.DeliveryRoot
+	agents            []string
+	validator         *headerValidator
+	revalidateContext func() error
+}
+
+// multiAgentRequested validates --agents and --all and reports whether the
+// command should follow several agents instead of --me.
+func multiAgentRequested(agentsRaw string, agentsSet, all bool) (bool, error) {
+	if agentsSet && all {
+		return false, UsageError("--agents and --all are mutually exclusive")
+	}
+	if agentsSet {
+		handles, err := parseHandles(agentsRaw)
+		if err != nil {
+			return false, UsageError("--agents: %v", err)
+		}
+		if len(handles) == 0 {
+			return false, UsageError("--agents requires at least one handle")
+		}
+	}
+	return agentsSet || all, nil
+}
+
+// openAgentMailboxes runs the same root resolution and session guard as the
+// single-agent path once, then checks every selected agent's mailbox.
+func openAgentMailboxes(command string, common *commonFlags, session string, ignorePin bool, agentsRaw string, all bool) (*agentMailboxes, error) {
+	root, routed, err := resolveMailboxRoot(common, session)
+	if err != nil {
+		return nil, err
+	}
+	if err := validatePinOverride(common, ignorePin, routed); err != nil {
+		return nil, err
+	}
+	if err := guardMailboxContext(command, root, routed, ignorePin, common.rootExplicit()); err != nil {
+		return nil, err
+	}
+	deliveryIdentity, err := snapshotMailboxDeliveryRoot(root, routed, ignorePin)
+	if err != nil {
+		return nil, err
+	}
+	deliveryRoot, err := fsq.OpenDeliveryRoot(root, deliveryIdentity)
+	if err != nil {
+		return nil, err
+	}
+	mailboxes := &agentMailboxes{
+		root:         root,
+		deliveryRoot: deliveryRoot,
+		revalidateContext: func() error {
+			if err := guardMailboxContext(command, root, routed, ignorePin, common.rootExplicit()); err != nil {
+				return err
+			}
+			return deliveryRoot.VerifyBase()
+		},
+	}
+	if err := mailboxes.selectAgents(command, agentsRaw, all, common.Strict); err != nil {
+		_ = deliveryRoot.Close()
+		return nil, err
+	}
+	mailboxes.validator, err = newHeaderValidatorDeliveryRoot(deliveryRoot, common.Strict)
+	if err != nil {
+		_ = deliveryRoot.Close()
+		return nil, err
+	}
+	return mailboxes, nil
+}
+
+func (m *agentMailboxes) selectAgents(command, agentsRaw string, all bool, strict bool) error {
+	if !all {
+		handles, err := parseHandles(agentsRaw)
+		if err != nil {
+			return UsageError("--agents: %v", err)
+		}
+		m.agents = dedupeStrings(handles)
+		for _, agent := range m.agents {
+			if err := requireMailboxDeliveryRoot(m.deliveryRoot, m.root, agent); err != nil {
+				return err
+			}
+		}
+		if err := validateKnownHandlesDeliveryRoot(m.deliveryRoot, strict, m.agents...); err != nil {
+			return err
+		}
+		return refuseQueueHandle(m.deliveryRoot, command, m.agents...)
+	}
+
+	// --all follows every agent mailbox in the root. The human handle and
+	// queue handles are left out: a dashboard must not drain the operator's
+	// inbox, and queue items are taken with amq claim, not drained.
+	cfg, err := config.ReadConfig(m.deliveryRoot)
+	if err != nil && !errors.Is(err, os.ErrNotExist) && strict {
+		return err
+	}
+	entries, err := m.deliveryRoot.ReadDir("agents")
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, entry := range entries {
+		handle := entry.Name()
+		if !entry.IsDir() || handle == reservedHumanHandle || fsq.ValidateHandle(handle) != nil {
+			continue
+		}
+		if _, queue := cfg.Queues[handle]; queue {
+			continue
+		}
+		if requireMailboxDeliveryRoot(m.deliveryRoot, m.root, handle) != nil {
+			continue
+		}
+		m.agents = append(m.agents, handle)
+	}
+	sort.Strings(m.agents)
+	if len(m.agents) == 0 {
+		return NotFoundError("no agent mailboxes at root %s", m.root)
+	}
+	return nil
+}
+
+func (m *agentMailboxes) inboxNew(agent string) string {
+	return filepath.Join("agents", agent, "inbox", "new")
+}
+
+// waitForMessages blocks until any selected inbox has a message file, using
+// one fsnotify watcher over all of them (or polling).
+func (m *agentMailboxes) waitForMessages(ctx context.Context, poll bool) (string, error) {
+	hasMessages := func() (bool, error) {
+		for _, agent := range m.agents {
+			found, err := hasMessageFilesDeliveryRoot(m.deliveryRoot, m.inboxNew(agent))
+			if err != nil || found {
+				return found, err
+			}
+		}
+		return false, nil
+	}
+	if poll {
+		return monitorWithPollingProbe(ctx, hasMessages, m.revalidateContext)
+	}
+	dirs := make([]string, 0, len(m.agents))
+	for _, agent := range m.agents {
+		dirs = append(dirs, m.deliveryRoot.DisplayPath(m.inboxNew(agent)))
+	}
+	return monitorDirsWithFsnotifyProbe(ctx, dirs, hasMessages, m.revalidateContext)
+}
+
+// waitError turns a failed wait into the command's error, naming the agent
+// whose mailbox disappeared.
+func (m *agentMailboxes) waitError(command string, err error) error {
+	if os.IsNotExist(err) {
+		for _, agent := range m.agents {
+			if missing := requireMailboxDeliveryRoot(m.deliveryRoot, m.root, agent); missing != nil {
+				return missing
+			}
+		}
+		return NotFoundError("a mailbox disappeared while %s root %s", command, m.root)
+	}
+	return err
+}
+
+// listNewMessages lists every selected agent's inbox/new, each message tagged
+// with the agent it is for.
+func (m *agentMailboxes) listNewMessages() ([]msgInfo, error) {
+	var all []msgInfo
+	for _, agent := range m.agents {
+		messages, err := listNewMessages(m.deliveryRoot, m.inboxNew(agent), m.validator, m.revalidateContext)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, NotFoundError("mailbox for %q disappeared while watching root %s", agent, m.root)
+			}
+			return nil, err
+		}
+		for i := range messages {
+			messages[i].Agent = agent
+		}
+		all = append(all, messages...)
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].Created < all[j].Created
+	})
+	return all, nil
+}
+
+// collect drains or peeks each agent's inbox as that agent: every batch is
+// authorized on its own and drains emit that agent's receipts. Results for
+// agents that already committed claims are returned with any later error.
+func (m *agentMailboxes) collect(watchEvent, mode, session string, includeBody bool, limit int) (monitorAgentsResult, error) {
+	result := monitorAgentsResult{
+		Event:      "messages",
+		WatchEvent: watchEvent,
+		Mode:       mode,
+		Session:    session,
+		Agents:     m.agents,
+		Results:    []monitorResult{},
+	}
+	for _, agent := range m.agents {
+		if err := requireMailboxDeliveryRoot(m.deliveryRoot, m.root, agent); err != nil {
+			return result, err
+		}
+		items, err := monitorInboxItems(m.deliveryRoot, m.root, agent, includeBody, limit, m.validator, mode, m.revalidateContext)
+		if len(items) > 0 {
+			result.Results = append(result.Results, monitorResult{
+				Event:      "messages",
+				WatchEvent: watchEvent,
+				Mode:       mode,
+				Session:    session,
+				Me:         agent,
+				Count:      len(items),
+				Drained:    items,
+			})
+			result.Count += len(items)
+		}
+		if err != nil {
+			var committed *fsq.CommittedDurabilityError
+			if !errors.As(err, &committed) && os.IsNotExist(err) {
+				err = NotFoundError("mailbox for %q disappeared while monitoring root %s", agent, m.root)
+			}
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func runWatchAgents(common *commonFlags, session string, ignorePin bool, agentsRaw string, all bool, timeout time.Duration, poll bool) error {
+	mailboxes, err := openAgentMailboxes("watch", common, session, ignorePin, agentsRaw, all)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = mailboxes.deliveryRoot.Close() }()
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	for {
+		event, waitErr := mailboxes.waitForMessages(ctx, poll)
+		if err := mailboxes.revalidateContext(); err != nil {
+			return err
+		}
+		if waitErr != nil {
+			if errors.Is(waitErr, context.DeadlineExceeded) {
+				if err := outputWatchResult(common.JSON, "timeout", nil); err != nil {
+					return err
+				}
+				return TimeoutError("watch timed out")
+			}
+			return mailboxes.waitError("watching", waitErr)
+		}
+		messages, err := mailboxes.listNewMessages()
+		if err != nil {
+			return err
+		}
+		// Another consumer may have drained the inbox that woke us; keep
+		// waiting rather than report an empty batch.
+		if len(messages) > 0 {
+			return outputWatchResult(common.JSON, event, messages)
+		}
+	}
+}
+
+func runMonitorAgents(common *commonFlags, session string, ignorePin bool, agentsRaw string, all bool, timeout time.Duration, poll, includeBody bool, limit int, mode string) error {
+	mailboxes, err := openAgentMailboxes("monitor", common, session, ignorePin, agentsRaw, all)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = mailboxes.deliveryRoot.Close() }()
+	sessionName := resolveSessionName(mailboxes.root)
+
+	result, err := mailboxes.collect("existing", mode, sessionName, includeBody, limit)
+	if handled, finishErr := finishMonitorAgentsCollection(common.JSON, result, err); handled {
+		return finishErr
+	}
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	watchEvent, waitErr := mailboxes.waitForMessages(ctx, poll)
+	if err := mailboxes.revalidateContext(); err != nil {
+		return err
+	}
+	if waitErr != nil {
+		if errors.Is(waitErr, context.DeadlineExceeded) {
+			if err := outputMonitorAgentsResult(common.JSON, monitorAgentsResult{
+				Event:   "timeout",
+				Mode:    mode,
+				Session: sessionName,
+				Agents:  mailboxes.agents,
+				Results: []monitorResult{},
+			}); err != nil {
+				return err
+			}
+			return TimeoutError("monitor timed out")
+		}
+		return mailboxes.waitError("monitoring", waitErr)
+	}
+
+	result, err = mailboxes.collect(watchEvent, mode, sessionName, includeBody, limit)
+	if handled, finishErr := finishMonitorAgentsCollection(common.JSON, result, err); handled {
+		return finishErr
+	}
+	result.Event = "empty"
+	return outputMonitorAgentsResult(common.JSON, result)
+}
+
+// finishMonitorAgentsCollection mirrors finishMonitorCollection: committed
+// payloads are printed before a later agent's error is returned.
+func finishMonitorAgentsCollection(jsonOutput bool, result monitorAgentsResult, collectErr error) (bool, error) {
+	if result.Count > 0 {
+		if err := outputMonitorAgentsResult(jsonOutput, result); err != nil {
+			if collectErr != nil {
+				return true, errors.Join(collectErr, err)
+			}
+			return true, err
+		}
+	}
+	if collectErr != nil {
+		return true, collectErr
+	}
+	return result.Count > 0, nil
+}
+
+func outputMonitorAgentsResult(jsonOutput bool, result monitorAgentsResult) error {
+	if jsonOutput {
+		return writeJSON(os.Stdout, result)
+	}
+	if result.Event != "messages" {
+		return outputMonitorResult(false, monitorResult{Event: result.Event, Mode: result.Mode})
+	}
+	for _, agentResult := range result.Results {
+		if err := outputMonitorResult(false, agentResult); err != nil {
+			return err
+		}
+	}
+	return nil
+}
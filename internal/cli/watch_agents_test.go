@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/avivsinai/agent-message-queue/internal/receipt"
+)
+
+func TestWatchAndMonitorFollowSeveralAgents(t *testing.T) {
+	root := initializedSendMailboxRoot(t, "claude", "codex", "grok")
+	send := func(to, body string) string {
+		t.Helper()
+		stdout, _, err := captureEnvOutput(t, func() error {
+			return runSend([]string{"--root", root, "--me", "claude", "--to", to, "--body", body, "--json"})
+		})
+		if err != nil {
+			t.Fatalf("send to %s: %v", to, err)
+		}
+		var out map[string]any
+		if err := unmarshalJSONOutput(stdout, &out); err != nil {
+			t.Fatal(err)
+		}
+		return out["id"].(string)
+	}
+	forCodex := send("codex", "for codex")
+	forGrok := send("grok", "for grok")
+
+	stdout, _, err := captureEnvOutput(t, func() error {
+		return runWatch([]string{"--root", root, "--agents", "codex,grok", "--json", "--timeout", "1s"})
+	})
+	if err != nil {
+		t.Fatalf("watch --agents: %v", err)
+	}
+	var watched watchResult
+	if err := unmarshalJSONOutput(stdout, &watched); err != nil {
+		t.Fatal(err)
+	}
+	tagged := map[string]string{}
+	for _, msg := range watched.Messages {
+		tagged[msg.ID] = msg.Agent
+	}
+	if watched.Event != "existing" || len(tagged) != 2 || tagged[forCodex] != "codex" || tagged[forGrok] != "grok" {
+		t.Fatalf("watch --agents = %+v, want both messages tagged by agent", watched)
+	}
+
+	stdout, _, err = captureEnvOutput(t, func() error {
+		return runMonitor([]string{"--root", root, "--all", "--json", "--timeout", "1s"})
+	})
+	if err != nil {
+		t.Fatalf("monitor --all: %v", err)
+	}
+	var drained monitorAgentsResult
+	if err := unmarshalJSONOutput(stdout, &drained); err != nil {
+		t.Fatal(err)
+	}
+	if drained.Event != "messages" || drained.Count != 2 || len(drained.Results) != 2 {
+		t.Fatalf("monitor --all = %+v, want one result per agent", drained)
+	}
+	for _, result := range drained.Results {
+		want := map[string]string{"codex": forCodex, "grok": forGrok}[result.Me]
+		if result.Count != 1 || result.Drained[0].ID != want {
+			t.Fatalf("monitor --all result for %q = %+v, want %s", result.Me, result, want)
+		}
+		if _, err := os.Stat(filepath.Join(root, "agents", result.Me, "inbox", "cur", want+".md")); err != nil {
+			t.Fatalf("%s was not drained into %s's cur: %v", want, result.Me, err)
+		}
+		receipts, err := receipt.List(root, result.Me, receipt.ListFilter{MsgID: want, Stage: receipt.StageDrained})
+		if err != nil || len(receipts) != 1 || receipts[0].Consumer != result.Me {
+			t.Fatalf("drained receipts for %s = %+v (%v), want one by the consumer", result.Me, receipts, err)
+		}
+	}
+
+	// One fsnotify watcher covers every inbox: mail for grok wakes a monitor
+	// that is idle on both.
+	var late string
+	monitorIdleForTest = func() { late = send("grok", "while idle") }
+	t.Cleanup(func() { monitorIdleForTest = nil })
+	stdout, _, err = captureEnvOutput(t, func() error {
+		return runMonitor([]string{"--root", root, "--agents", "codex,grok", "--json", "--timeout", "5s"})
+	})
+	if err != nil {
+		t.Fatalf("monitor --agents: %v", err)
+	}
+	drained = monitorAgentsResult{}
+	if err := unmarshalJSONOutput(stdout, &drained); err != nil {
+		t.Fatal(err)
+	}
+	if drained.WatchEvent != "new_message" || len(drained.Results) != 1 || drained.Results[0].Me != "grok" ||
+		drained.Results[0].Drained[0].ID != late {
+		t.Fatalf("monitor --agents after idle = %+v, want grok's new message", drained)
+	}
+
+	if _, _, err := captureEnvOutput(t, func() error {
+		return runMonitor([]string{"--root", root, "--agents", "codex", "--all"})
+	}); GetExitCode(err) != ExitUsage {
+		t.Fatalf("--agents with --all exit = %d (%v), want usage", GetExitCode(err), err)
+	}
+}
@@ -16,7 +16,7 @@ func runWho(args []string) error {
 
 	usage := usageWithFlags(fs, "amq who [options]",
 		"List sessions and agents in the current project.",
-		"Shows active/stale status and whether activity comes from a verified notifier or recent commands.")
+		"Shows derived status (active, idle, away, stale, offline) and whether activity comes from a verified notifier or recent commands.")
 	if handled, err := parseFlags(fs, args, usage); err != nil {
 		return err
 	} else if handled {
@@ -49,6 +49,7 @@ func runWho(args []string) error {
 		PresenceApplicable bool   `json:"presence_applicable"`
 		Active             bool   `json:"active"`
 		PresenceSource     string `json:"presence_source,omitempty"`
+		DerivedStatus      string `json:"derived_status,omitempty"`
 		NotifierStatus     string `json:"notifier_status,omitempty"`
 		NotifierMode       string `json:"notifier_mode,omitempty"`
 		NotifierReason     string `json:"notifier_reason,omitempty"`
@@ -61,6 +62,7 @@ func runWho(args []string) error {
 
 	var sessions []sessionInfo
 	currentSession := sessionName(root)
+	now := time.Now()
 
 	for _, e := range entries {
 		if !e.IsDir() || strings.HasPrefix(e.Name(), "_") || strings.HasPrefix(e.Name(), ".") {
@@ -72,6 +74,7 @@ func runWho(args []string) error {
 		if err != nil {
 			continue // not a session
 		}
+		thresholds := presenceThresholds(sessDir)
 
 		var agents []agentInfo
 		for _, ae := range agentEntries {
@@ -100,18 +103,16 @@ func runWho(args []string) error {
 
 			// Recent presence proves activity only; a verified wake lock separately
 			// proves that a prompt notifier is currently attached.
-			recentActivity := false
-			if p, err := presence.Read(sessDir, ae.Name()); err == nil {
-				if t, err := time.Parse(time.RFC3339Nano, p.LastSeen); err == nil {
-					recentActivity = time.Since(t) < 10*time.Minute
-				}
+			state := deriveAgentPresence(sessDir, ae.Name(), thresholds, now)
+			if p := state.Presence; p != nil {
 				ai.Note = p.Note
 				ai.NotifierStatus = p.NotifierStatus
 				ai.NotifierMode = p.NotifierMode
 				ai.NotifierReason = p.NotifierReason
 			}
-			ai.PresenceSource = resolvePresenceSource(sessDir, ae.Name(), recentActivity)
-			ai.Active = recentActivity || ai.PresenceSource == presenceSourceNotifierLive
+			ai.PresenceSource = state.Source
+			ai.DerivedStatus = state.Status
+			ai.Active = presence.IsAlive(state.Status)
 			agents = append(agents, ai)
 		}
 
@@ -151,11 +152,10 @@ func runWho(args []string) error {
 			return err
 		}
 		for _, a := range s.Agents {
-			status := "stale"
+			status := a.DerivedStatus
 			if !a.PresenceApplicable {
 				status = "human"
 			} else if a.Active {
-				status = "active"
 				if a.PresenceSource != "" {
 					status += " (" + a.PresenceSource + ")"
 				}
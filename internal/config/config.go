@@ -5,15 +5,309 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/avivsinai/agent-message-queue/internal/fsq"
 )
 
+// ReservedHumanHandle is the handle reserved for the human operator; it is
+// never an agent.
+const ReservedHumanHandle = "user"
+
 // Config is persisted to meta/config.json and captures the initial setup.
 type Config struct {
 	Version    int      `json:"version"`
 	CreatedUTC string   `json:"created_utc"`
 	Agents     []string `json:"agents"`
+
+	// Quotas bounds how much undrained mail each agent may accumulate.
+	Quotas *QuotaConfig `json:"quotas,omitempty"`
+
+	// Breaker tunes per-thread loop detection on the send path.
+	Breaker *BreakerConfig `json:"breaker,omitempty"`
+
+	// ACL restricts which senders may deliver to each handle.
+	ACL *ACLConfig `json:"acl,omitempty"`
+
+	// Queues declares competing-consumer queue handles, keyed by handle.
+	Queues map[string]QueueConfig `json:"queues,omitempty"`
+
+	// Presence tunes the ages at which derived presence status degrades.
+	Presence *PresenceConfig `json:"presence,omitempty"`
+}
+
+// Default derived-presence thresholds, used when PresenceConfig leaves a
+// field unset. DefaultPresenceAwayAfter is the recent-activity window: an
+// agent is alive until its presence is this old.
+const (
+	DefaultPresenceIdleAfter    = 2 * time.Minute
+	DefaultPresenceAwayAfter    = 10 * time.Minute
+	DefaultPresenceStaleAfter   = time.Hour
+	DefaultPresenceOfflineAfter = 24 * time.Hour
+)
+
+// PresenceConfig holds the presence ages, as Go durations, after which an
+// agent's derived status becomes idle, away, stale, and offline.
+type PresenceConfig struct {
+	IdleAfter    string `json:"idle_after,omitempty"`
+	AwayAfter    string `json:"away_after,omitempty"`
+	StaleAfter   string `json:"stale_after,omitempty"`
+	OfflineAfter string `json:"offline_after,omitempty"`
+}
+
+// PresenceThresholds is the parsed form of PresenceConfig.
+type PresenceThresholds struct {
+	Idle    time.Duration
+	Away    time.Duration
+	Stale   time.Duration
+	Offline time.Duration
+}
+
+// Thresholds returns the effective thresholds with defaults applied. Each
+// threshold must be positive and later than the one before it.
+func (p *PresenceConfig) Thresholds() (PresenceThresholds, error) {
+	t := PresenceThresholds{
+		Idle:    DefaultPresenceIdleAfter,
+		Away:    DefaultPresenceAwayAfter,
+		Stale:   DefaultPresenceStaleAfter,
+		Offline: DefaultPresenceOfflineAfter,
+	}
+	if p == nil {
+		return t, nil
+	}
+	for _, field := range []struct {
+		name string
+		raw  string
+		dst  *time.Duration
+	}{
+		{"idle_after", p.IdleAfter, &t.Idle},
+		{"away_after", p.AwayAfter, &t.Away},
+		{"stale_after", p.StaleAfter, &t.Stale},
+		{"offline_after", p.OfflineAfter, &t.Offline},
+	} {
+		if field.raw == "" {
+			continue
+		}
+		d, err := time.ParseDuration(field.raw)
+		if err != nil || d <= 0 {
+			return PresenceThresholds{}, fmt.Errorf("invalid presence %s %q", field.name, field.raw)
+		}
+		*field.dst = d
+	}
+	if t.Idle >= t.Away || t.Away >= t.Stale || t.Stale >= t.Offline {
+		return PresenceThresholds{}, fmt.Errorf("presence thresholds must increase: idle_after %s, away_after %s, stale_after %s, offline_after %s", t.Idle, t.Away, t.Stale, t.Offline)
+	}
+	return t, nil
+}
+
+// DefaultQueueLease is how long a claimer's presence may go without an
+// update before its queue claims return to the queue.
+const DefaultQueueLease = 10 * time.Minute
+
+// QueueConfig describes one queue handle. Its inbox/new is shared: each item
+// is claimed by exactly one member.
+type QueueConfig struct {
+	// Members may claim from the queue; empty admits every configured agent.
+	Members []string `json:"members,omitempty"`
+	// Lease is a Go duration; it defaults to DefaultQueueLease.
+	Lease string `json:"lease,omitempty"`
+}
+
+// HasMember reports whether handle may claim from the queue.
+func (q QueueConfig) HasMember(handle string) bool {
+	if len(q.Members) == 0 {
+		return true
+	}
+	for _, member := range q.Members {
+		if member == handle {
+			return true
+		}
+	}
+	return false
+}
+
+// LeaseDuration returns the parsed lease, defaulting to DefaultQueueLease.
+func (q QueueConfig) LeaseDuration() (time.Duration, error) {
+	if q.Lease == "" {
+		return DefaultQueueLease, nil
+	}
+	lease, err := time.ParseDuration(q.Lease)
+	if err != nil || lease <= 0 {
+		return 0, fmt.Errorf("invalid queue lease %q", q.Lease)
+	}
+	return lease, nil
+}
+
+// ACLLocalProject matches senders in this root, whose messages carry no
+// from_project. "@" cannot appear in a project name, so it never collides.
+const ACLLocalProject = "@local"
+
+// ACLConfig holds per-recipient delivery policies. An Agents entry replaces
+// Default for that handle; a handle with neither accepts every sender.
+type ACLConfig struct {
+	Default *ACLPolicy           `json:"default,omitempty"`
+	Agents  map[string]ACLPolicy `json:"agents,omitempty"`
+}
+
+// ACLPolicy is evaluated deny-first: a matching Deny rule refuses the
+// delivery. When Allow is non-empty, a delivery must match one of its rules.
+type ACLPolicy struct {
+	Allow []ACLRule `json:"allow,omitempty"`
+	Deny  []ACLRule `json:"deny,omitempty"`
+}
+
+// ACLRule matches a delivery when every non-empty field matches. Values in a
+// field are alternatives. Projects compares from_project, with
+// ACLLocalProject standing for local senders; an empty message priority
+// matches "normal".
+type ACLRule struct {
+	From       []string `json:"from,omitempty"`
+	Projects   []string `json:"projects,omitempty"`
+	Kinds      []string `json:"kinds,omitempty"`
+	Priorities []string `json:"priorities,omitempty"`
+}
+
+// For returns the policy governing deliveries to agent. ok is false when no
+// policy applies.
+func (a *ACLConfig) For(agent string) (ACLPolicy, bool) {
+	if a == nil {
+		return ACLPolicy{}, false
+	}
+	if policy, ok := a.Agents[agent]; ok {
+		return policy, true
+	}
+	if a.Default != nil {
+		return *a.Default, true
+	}
+	return ACLPolicy{}, false
+}
+
+// Default loop-detection thresholds, used when BreakerConfig leaves a field
+// unset.
+const (
+	DefaultBreakerWindow          = 2 * time.Minute
+	DefaultBreakerMaxAlternations = 12
+	DefaultBreakerMaxRepeats      = 6
+)
+
+// BreakerConfig holds thresholds for ping-pong loop detection. Detection is
+// on when the config has no breaker section. A zero field takes its default;
+// a negative field disables that check. The depth check has no default and
+// stays off until MaxDepth is set.
+type BreakerConfig struct {
+	// Disabled turns loop detection off entirely.
+	Disabled bool `json:"disabled,omitempty"`
+	// Window is the Go duration over which alternations and repeats count.
+	Window string `json:"window,omitempty"`
+	// MaxAlternations trips after this many sender switches within Window.
+	MaxAlternations int `json:"max_alternations,omitempty"`
+	// MaxRepeats trips after this many near-identical bodies within Window.
+	MaxRepeats int `json:"max_repeats,omitempty"`
+	// MaxDepth trips once a thread carries more than this many messages
+	// within Window.
+	MaxDepth int `json:"max_depth,omitempty"`
+	// Notify is the handle told when a breaker trips. It defaults to the
+	// reserved human handle, since the agents in the loop can't be trusted
+	// to act on the notice.
+	Notify string `json:"notify,omitempty"`
+}
+
+// Thresholds returns the effective thresholds with defaults applied.
+func (b *BreakerConfig) Thresholds() (BreakerConfig, error) {
+	var t BreakerConfig
+	if b != nil {
+		t = *b
+	}
+	if t.MaxAlternations == 0 {
+		t.MaxAlternations = DefaultBreakerMaxAlternations
+	}
+	if t.MaxRepeats == 0 {
+		t.MaxRepeats = DefaultBreakerMaxRepeats
+	}
+	if t.Window == "" {
+		t.Window = DefaultBreakerWindow.String()
+	}
+	if t.Notify == "" {
+		t.Notify = ReservedHumanHandle
+	}
+	window, err := time.ParseDuration(t.Window)
+	if err != nil || window <= 0 {
+		return BreakerConfig{}, fmt.Errorf("invalid breaker window %q", t.Window)
+	}
+	return t, nil
+}
+
+// WindowDuration returns the parsed Window of thresholds from Thresholds.
+func (b BreakerConfig) WindowDuration() time.Duration {
+	window, err := time.ParseDuration(b.Window)
+	if err != nil {
+		return DefaultBreakerWindow
+	}
+	return window
+}
+
+// QuotaConfig holds mailbox limits. Default applies to every agent; an Agents
+// entry overrides individual non-zero fields for that handle.
+type QuotaConfig struct {
+	Default QuotaLimits            `json:"default"`
+	Agents  map[string]QuotaLimits `json:"agents,omitempty"`
+}
+
+// QuotaLimits are per-mailbox limits. A zero field is unlimited.
+type QuotaLimits struct {
+	// MaxUndrained caps the number of messages in inbox/new.
+	MaxUndrained int `json:"max_undrained,omitempty"`
+	// MaxBytes caps the total size of messages in inbox/new.
+	MaxBytes int64 `json:"max_bytes,omitempty"`
+	// SenderRate caps messages from one sender within RateWindow.
+	SenderRate int `json:"sender_rate,omitempty"`
+	// RateWindow is a Go duration; it defaults to one minute.
+	RateWindow string `json:"rate_window,omitempty"`
+}
+
+// For returns the effective limits for agent.
+func (q *QuotaConfig) For(agent string) QuotaLimits {
+	if q == nil {
+		return QuotaLimits{}
+	}
+	limits := q.Default
+	override, ok := q.Agents[agent]
+	if !ok {
+		return limits
+	}
+	if override.MaxUndrained != 0 {
+		limits.MaxUndrained = override.MaxUndrained
+	}
+	if override.MaxBytes != 0 {
+		limits.MaxBytes = override.MaxBytes
+	}
+	if override.SenderRate != 0 {
+		limits.SenderRate = override.SenderRate
+	}
+	if override.RateWindow != "" {
+		limits.RateWindow = override.RateWindow
+	}
+	return limits
+}
+
+// Enabled reports whether any limit is set.
+func (l QuotaLimits) Enabled() bool {
+	return l.MaxUndrained > 0 || l.MaxBytes > 0 || l.SenderRate > 0
+}
+
+// Window returns the parsed rate window, defaulting to one minute.
+func (l QuotaLimits) Window() (time.Duration, error) {
+	if l.RateWindow == "" {
+		return time.Minute, nil
+	}
+	window, err := time.ParseDuration(l.RateWindow)
+	if err != nil {
+		return 0, fmt.Errorf("invalid quota rate_window %q: %w", l.RateWindow, err)
+	}
+	if window <= 0 {
+		return 0, fmt.Errorf("invalid quota rate_window %q: must be positive", l.RateWindow)
+	}
+	return window, nil
 }
 
 func WriteConfig(path string, cfg Config, force bool) error {
@@ -36,6 +330,20 @@ func LoadConfig(path string) (Config, error) {
 	if err != nil {
 		return Config{}, err
 	}
+	return ParseConfig(data)
+}
+
+// ReadConfig reads meta/config.json through a pinned delivery root.
+func ReadConfig(root *fsq.DeliveryRoot) (Config, error) {
+	data, err := root.ReadRegularNoFollow(filepath.Join("meta", "config.json"))
+	if err != nil {
+		return Config{}, err
+	}
+	return ParseConfig(data)
+}
+
+// ParseConfig decodes config.json content.
+func ParseConfig(data []byte) (Config, error) {
 	var cfg Config
 	if err := json.Unmarshal(data, &cfg); err != nil {
 		return Config{}, err
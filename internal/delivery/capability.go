@@ -0,0 +1,276 @@
+package delivery
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/config"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+	"github.com/avivsinai/agent-message-queue/internal/lock"
+	"github.com/avivsinai/agent-message-queue/internal/presence"
+)
+
+const (
+	PickLeastLoaded = "least-loaded"
+	PickRoundRobin  = "round-robin"
+
+	// RoutingContextKey is the message context key that records a capability
+	// routing decision.
+	RoutingContextKey = "routing"
+
+	// CursorsFile, under meta/, holds the round-robin cursor for each
+	// capability so every sender into a root shares one rotation.
+	CursorsFile = "capability-cursors.json"
+)
+
+// Presence sources a capability candidate can report.
+const (
+	SourceNotifierLive   = "notifier_live"
+	SourceRecentActivity = "recent_activity"
+)
+
+// PresenceSource names what makes agent count as present given whether its
+// presence is recent: SourceNotifierLive, SourceRecentActivity, or "". The
+// CLI consults wake locks; nil judges by presence age alone.
+type PresenceSource func(agent string, recentActivity bool) string
+
+// CapabilityPick is a capability routing decision: which live agent was
+// chosen for a capability and why.
+type CapabilityPick struct {
+	Capability string      `json:"capability"`
+	Pick       string      `json:"pick"`
+	Chosen     string      `json:"chosen"`
+	Reason     string      `json:"reason"`
+	Candidates []Candidate `json:"candidates"`
+}
+
+// Candidate is one agent advertising the capability.
+type Candidate struct {
+	Handle         string `json:"handle"`
+	InboxDepth     int    `json:"inbox_depth"`
+	LastSeen       string `json:"last_seen,omitempty"`
+	Status         string `json:"derived_status"`
+	PresenceSource string `json:"presence_source,omitempty"`
+	Live           bool   `json:"live"`
+
+	seen time.Time
+}
+
+// ContextValue is the compact form recorded in message context.
+func (p CapabilityPick) ContextValue() map[string]any {
+	return map[string]any{
+		"capability": p.Capability,
+		"pick":       p.Pick,
+		"chosen":     p.Chosen,
+		"reason":     p.Reason,
+	}
+}
+
+// NoCandidateError reports that no live agent in Root advertises Capability.
+// Stale counts matching agents whose presence is not live.
+type NoCandidateError struct {
+	Root       string
+	Capability string
+	Stale      int
+}
+
+func (e *NoCandidateError) Error() string {
+	if e.Stale == 0 {
+		return fmt.Sprintf("no agent in %s advertises capability %q; publish one with 'amq presence set --capabilities %s'", e.Root, e.Capability, e.Capability)
+	}
+	return fmt.Sprintf("no live agent in %s advertises capability %q (%d matching agent(s) have stale presence)", e.Root, e.Capability, e.Stale)
+}
+
+// PickCapability chooses a live agent in deliveryFS advertising capability,
+// taking the roster and presence thresholds from configFS. exclude (the
+// sender, on same-root sends) is never chosen. A round-robin pick only reads
+// the shared cursor; Local.Deliver advances it once the message is delivered,
+// so a refused send does not skip an agent.
+func PickCapability(configFS, deliveryFS *fsq.DeliveryRoot, capability, pick, exclude string, source PresenceSource) (CapabilityPick, error) {
+	capability = strings.ToLower(strings.TrimSpace(capability))
+	result := CapabilityPick{Capability: capability, Pick: pick, Candidates: []Candidate{}}
+	if capability == "" {
+		return result, errors.New("capability must not be empty")
+	}
+	candidates, err := capabilityCandidates(configFS, deliveryFS, capability, exclude, source, time.Now())
+	if err != nil {
+		return result, err
+	}
+	result.Candidates = candidates
+	var live []Candidate
+	for _, candidate := range candidates {
+		if candidate.Live {
+			live = append(live, candidate)
+		}
+	}
+	if len(live) == 0 {
+		return result, &NoCandidateError{Root: deliveryFS.Base(), Capability: capability, Stale: len(candidates)}
+	}
+
+	switch pick {
+	case PickRoundRobin:
+		chosen, previous, err := nextRoundRobin(deliveryFS, capability, live)
+		if err != nil {
+			return result, err
+		}
+		result.Chosen = chosen.Handle
+		if previous == "" {
+			result.Reason = fmt.Sprintf("round-robin: first of %d live candidate(s)", len(live))
+		} else {
+			result.Reason = fmt.Sprintf("round-robin: next after %s among %d live candidate(s)", previous, len(live))
+		}
+	default:
+		sort.SliceStable(live, func(i, j int) bool {
+			if live[i].InboxDepth != live[j].InboxDepth {
+				return live[i].InboxDepth < live[j].InboxDepth
+			}
+			if !live[i].seen.Equal(live[j].seen) {
+				return live[i].seen.After(live[j].seen)
+			}
+			return live[i].Handle < live[j].Handle
+		})
+		chosen := live[0]
+		result.Chosen = chosen.Handle
+		result.Reason = fmt.Sprintf("least-loaded: inbox depth %d, lowest of %d live candidate(s)", chosen.InboxDepth, len(live))
+		if len(live) > 1 && live[1].InboxDepth == chosen.InboxDepth {
+			result.Reason += "; tie broken by freshest presence"
+		}
+	}
+	return result, nil
+}
+
+// capabilityCandidates lists agents in deliveryFS whose presence advertises
+// capability, sorted by handle. An agent is live when its derived presence
+// status is active or idle.
+func capabilityCandidates(configFS, deliveryFS *fsq.DeliveryRoot, capability, exclude string, source PresenceSource, now time.Time) ([]Candidate, error) {
+	cfg, err := config.ReadConfig(configFS)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("read presence config: %w", err)
+	}
+	thresholds, err := cfg.Presence.Thresholds()
+	if err != nil {
+		return nil, err
+	}
+	agents := cfg.Agents
+	if len(agents) == 0 {
+		entries, err := deliveryFS.ReadDir("agents")
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				agents = append(agents, entry.Name())
+			}
+		}
+	}
+	seenAgent := map[string]bool{}
+	candidates := []Candidate{}
+	for _, agent := range agents {
+		if seenAgent[agent] || agent == exclude || agent == config.ReservedHumanHandle {
+			continue
+		}
+		seenAgent[agent] = true
+		p, err := presence.ReadDeliveryRoot(deliveryFS, agent)
+		if err != nil || !p.HasCapability(capability) {
+			continue
+		}
+		seen, hasSeen := p.LastSeenTime()
+		recent := hasSeen && now.Sub(seen) < thresholds.Away
+		presenceSource := ""
+		if source != nil {
+			presenceSource = source(agent, recent)
+		} else if recent {
+			presenceSource = SourceRecentActivity
+		}
+		status := presence.Derive(&p, presenceSource == SourceNotifierLive, now, thresholds)
+		candidates = append(candidates, Candidate{
+			Handle:         agent,
+			LastSeen:       p.LastSeen,
+			Status:         status,
+			PresenceSource: presenceSource,
+			Live:           presence.IsAlive(status),
+			InboxDepth:     inboxDepth(deliveryFS, agent),
+			seen:           seen,
+		})
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Handle < candidates[j].Handle })
+	return candidates, nil
+}
+
+func inboxDepth(root *fsq.DeliveryRoot, agent string) int {
+	entries, err := root.ReadDir(filepath.Join("agents", agent, "inbox", "new"))
+	if err != nil {
+		return 0
+	}
+	depth := 0
+	for _, entry := range entries {
+		if !entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") && strings.HasSuffix(entry.Name(), ".md") {
+			depth++
+		}
+	}
+	return depth
+}
+
+func readCursors(root *fsq.DeliveryRoot) (map[string]string, error) {
+	cursors := map[string]string{}
+	name := filepath.Join("meta", CursorsFile)
+	data, err := root.ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return cursors, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read capability cursors: %w", err)
+	}
+	if err := json.Unmarshal(data, &cursors); err != nil {
+		return nil, fmt.Errorf("invalid capability cursors %s: %w", root.DisplayPath(name), err)
+	}
+	return cursors, nil
+}
+
+// nextRoundRobin returns the live candidate after the last one picked for
+// capability in root, without moving the cursor.
+func nextRoundRobin(root *fsq.DeliveryRoot, capability string, live []Candidate) (Candidate, string, error) {
+	cursors, err := readCursors(root)
+	if err != nil {
+		return Candidate{}, "", err
+	}
+	previous := cursors[capability]
+	for _, candidate := range live {
+		if candidate.Handle > previous {
+			return candidate, previous, nil
+		}
+	}
+	return live[0], previous, nil
+}
+
+// AdvanceCursor records a delivered round-robin pick so the next send moves
+// on to the following agent. Other picks leave the cursors alone.
+func AdvanceCursor(root *fsq.DeliveryRoot, pick CapabilityPick) error {
+	if pick.Pick != PickRoundRobin || pick.Chosen == "" {
+		return nil
+	}
+	file, err := root.OpenLockFile("meta", CursorsFile+".lock", 0o600)
+	if err != nil {
+		return fmt.Errorf("open capability cursor lock: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+	return lock.WithExclusiveFile(file, func() error {
+		cursors, err := readCursors(root)
+		if err != nil {
+			return err
+		}
+		cursors[pick.Capability] = pick.Chosen
+		out, err := json.MarshalIndent(cursors, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = root.WriteFileAtomic("meta", CursorsFile, append(out, '\n'), 0o600)
+		return err
+	})
+}
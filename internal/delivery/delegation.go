@@ -0,0 +1,313 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/acl"
+	"github.com/avivsinai/agent-message-queue/internal/format"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+	"github.com/avivsinai/agent-message-queue/internal/presence"
+	"github.com/avivsinai/agent-message-queue/internal/quota"
+)
+
+// DelegationContextKey is the message context key that records an
+// out-of-office forward on the delegate's copy and on the away reply.
+const DelegationContextKey = "delegation"
+
+// Hop is one out-of-office forward: mail for For went to To.
+type Hop struct {
+	For         string `json:"for"`
+	To          string `json:"to"`
+	Mode        string `json:"mode"`
+	Until       string `json:"until,omitempty"`
+	ForwardedAt string `json:"forwarded_at,omitempty"`
+}
+
+// Delegation is the out-of-office part of one delivery: the forwards it
+// makes, the recipients that still get the original, and every inbox it
+// writes to, which is what quotas count. Local.Deliver runs it itself;
+// a cross-project send that writes the original into an existing peer
+// mailbox plans it with PlanDelegation and runs the steps around its own
+// delivery.
+type Delegation struct {
+	Hops   []Hop
+	Direct []string
+	All    []string
+	// periods[i] identifies the delegation period of Hops[i].
+	periods []string
+}
+
+// ReplyRoute is how the sender of a message reaches the away recipient's
+// root: the peer project and session its side routes by. Away replies carry
+// it so that replying to one goes back to the away agent. It is empty for a
+// delivery within one project.
+type ReplyRoute struct {
+	Project string
+	Session string
+}
+
+// PlanDelegation plans the forwards for delivering header to its
+// recipients in deliveryFS.
+func PlanDelegation(configFS, deliveryFS *fsq.DeliveryRoot, header format.Header, now time.Time) (Delegation, error) {
+	hops, periods, err := planDelegation(configFS, deliveryFS, header, header.To, now)
+	if err != nil {
+		return Delegation{}, err
+	}
+	direct, all := delegationTargets(header.To, hops)
+	return Delegation{Hops: hops, Direct: direct, All: all, periods: periods}, nil
+}
+
+// DeliverCopies writes each delegate's copy of msg.
+func (d Delegation) DeliverCopies(configFS, deliveryFS *fsq.DeliveryRoot, msg format.Message, filename string) error {
+	return deliverDelegatedCopies(configFS, deliveryFS, msg, filename, d.Hops)
+}
+
+// SendAwayReplies tells the sender of msg where its message went, under
+// sourceConfigFS's ACL and quotas, and returns a warning per failure.
+func (d Delegation) SendAwayReplies(sourceConfigFS, sourceFS, deliveryFS *fsq.DeliveryRoot, msg format.Message, route ReplyRoute, now time.Time) []string {
+	return sendAwayReplies(sourceConfigFS, sourceFS, deliveryFS, msg, d, route, now)
+}
+
+// HopFromContext decodes the hop recorded in a message context.
+func HopFromContext(ctx map[string]any) (Hop, bool) {
+	raw, ok := ctx[DelegationContextKey]
+	if !ok {
+		return Hop{}, false
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return Hop{}, false
+	}
+	var hop Hop
+	if err := json.Unmarshal(data, &hop); err != nil || hop.For == "" || hop.To == "" {
+		return Hop{}, false
+	}
+	return hop, true
+}
+
+// planDelegation returns the forwards a local delivery of header to
+// recipients should make, each with its delegation period. A recipient's delegate is skipped when it is the
+// sender, already a recipient, already a delegate for someone else, has no
+// mailbox, or may not receive from the sender under the ACL; that recipient
+// then gets the message normally.
+func planDelegation(configFS, deliveryFS *fsq.DeliveryRoot, header format.Header, recipients []string, now time.Time) ([]Hop, []string, error) {
+	var hops []Hop
+	var periods []string
+	taken := map[string]bool{header.From: true}
+	for _, recipient := range recipients {
+		taken[recipient] = true
+	}
+	for _, recipient := range recipients {
+		if recipient == header.From {
+			continue
+		}
+		p, err := presence.ReadDeliveryRoot(deliveryFS, recipient)
+		if err != nil {
+			continue
+		}
+		delegate := p.ActiveDelegate(now)
+		if delegate == "" || taken[delegate] {
+			continue
+		}
+		if fsq.ValidateExistingMailboxLayout(deliveryFS, delegate) != nil {
+			continue
+		}
+		forwarded := header
+		forwarded.To = []string{delegate}
+		if err := acl.Authorize(configFS, forwarded); err != nil {
+			var denied *acl.DeniedError
+			if errors.As(err, &denied) {
+				continue
+			}
+			return nil, nil, err
+		}
+		mode := p.DelegateMode
+		if mode != presence.DelegateMove {
+			mode = presence.DelegateCopy
+		}
+		taken[delegate] = true
+		hops = append(hops, Hop{
+			For:         recipient,
+			To:          delegate,
+			Mode:        mode,
+			Until:       p.DelegateUntil,
+			ForwardedAt: now.UTC().Format(time.RFC3339Nano),
+		})
+		periods = append(periods, delegationPeriod(p))
+	}
+	return hops, periods, nil
+}
+
+// delegationTargets splits recipients by hops: direct still receive the
+// original, and all is every inbox the delivery writes to, for quotas.
+func delegationTargets(recipients []string, hops []Hop) (direct, all []string) {
+	moved := map[string]bool{}
+	for _, hop := range hops {
+		if hop.Mode == presence.DelegateMove {
+			moved[hop.For] = true
+		}
+	}
+	for _, recipient := range recipients {
+		if !moved[recipient] {
+			direct = append(direct, recipient)
+		}
+	}
+	all = append([]string{}, direct...)
+	for _, hop := range hops {
+		all = append(all, hop.To)
+	}
+	return direct, all
+}
+
+// deliverDelegatedCopies writes each delegate's copy of msg. The copy keeps
+// the original header, so replies go to the original sender and trace finds
+// it under the same id; the hop itself is recorded in context.
+func deliverDelegatedCopies(configFS, deliveryFS *fsq.DeliveryRoot, msg format.Message, filename string, hops []Hop) error {
+	for _, hop := range hops {
+		copied := msg
+		copied.Header.Context = make(map[string]any, len(msg.Header.Context)+1)
+		for key, value := range msg.Header.Context {
+			copied.Header.Context[key] = value
+		}
+		copied.Header.Context[DelegationContextKey] = hop
+		data, err := copied.Marshal()
+		if err != nil {
+			return err
+		}
+		if err := deliverAdmitted(configFS, deliveryFS, copied.Header, hop.To, filename, data); err != nil {
+			return fmt.Errorf("forward to delegate %s of %s: %w", hop.To, hop.For, err)
+		}
+	}
+	return nil
+}
+
+// awayRepliesDir holds, per away agent, one record per sender that already
+// got an away reply in the current delegation period.
+const awayRepliesDir = "meta/away-replies"
+
+// awayReplyRecord is meta/away-replies/<away>/<sender>.json.
+type awayReplyRecord struct {
+	Period      string `json:"period"`
+	FromProject string `json:"from_project,omitempty"`
+	MessageID   string `json:"message_id"`
+}
+
+// sendAwayReplies tells the sender of msg where its message went, once per
+// away recipient and delegation period: later messages from the same sender
+// while the agent is still away are forwarded silently. It is best effort
+// and returns a warning per failure. Status messages never trigger one, so
+// two away agents cannot reply to each other forever.
+func sendAwayReplies(configFS, sourceFS, deliveryFS *fsq.DeliveryRoot, msg format.Message, delegation Delegation, route ReplyRoute, now time.Time) []string {
+	if msg.Header.Kind == format.KindStatus {
+		return nil
+	}
+	var warnings []string
+	for i, hop := range delegation.Hops {
+		record := awayReplyRecord{Period: delegation.periods[i], FromProject: msg.Header.FromProject}
+		if awayReplied(deliveryFS, hop.For, msg.Header.From, record) {
+			continue
+		}
+		id, err := sendAwayReply(configFS, sourceFS, deliveryFS, msg, hop, route, now)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("away reply from %s: %v", hop.For, err))
+			continue
+		}
+		record.MessageID = id
+		if err := recordAwayReply(deliveryFS, hop.For, msg.Header.From, record); err != nil {
+			warnings = append(warnings, fmt.Sprintf("record away reply from %s: %v", hop.For, err))
+		}
+	}
+	return warnings
+}
+
+// delegationPeriod identifies p's current delegation. Presence written
+// before delegate_since existed falls back to the delegate and its end time.
+func delegationPeriod(p presence.Presence) string {
+	if p.DelegateSince != "" {
+		return p.DelegateSince
+	}
+	return p.Delegate + " " + p.DelegateUntil
+}
+
+func awayReplied(deliveryFS *fsq.DeliveryRoot, away, sender string, want awayReplyRecord) bool {
+	data, err := deliveryFS.ReadFile(filepath.Join(awayRepliesDir, away, sender+".json"))
+	if err != nil {
+		return false
+	}
+	var got awayReplyRecord
+	if err := json.Unmarshal(data, &got); err != nil {
+		return false
+	}
+	return got.Period == want.Period && got.FromProject == want.FromProject
+}
+
+func recordAwayReply(deliveryFS *fsq.DeliveryRoot, away, sender string, record awayReplyRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = deliveryFS.WriteFileAtomic(filepath.Join(awayRepliesDir, away), sender+".json", data, 0o600)
+	return err
+}
+
+func sendAwayReply(configFS, sourceFS, deliveryFS *fsq.DeliveryRoot, msg format.Message, hop Hop, route ReplyRoute, now time.Time) (string, error) {
+	id, err := format.NewMessageID(now)
+	if err != nil {
+		return "", err
+	}
+	body := fmt.Sprintf("%s is away. Your message %s was forwarded (%s) to %s", hop.For, msg.Header.ID, hop.Mode, hop.To)
+	if hop.Until != "" {
+		body += " until " + hop.Until
+	}
+	body += ".\n"
+	reply := format.Message{
+		Header: format.Header{
+			Schema:   format.CurrentSchema,
+			ID:       id,
+			From:     hop.For,
+			To:       []string{msg.Header.From},
+			Thread:   msg.Header.Thread,
+			Subject:  "Away: forwarded to " + hop.To,
+			Created:  now.UTC().Format(time.RFC3339Nano),
+			Refs:     []string{msg.Header.ID},
+			Priority: format.PriorityLow,
+			Kind:     format.KindStatus,
+			Context:  map[string]any{DelegationContextKey: hop},
+		},
+		Body: body,
+	}
+	if route.Project != "" {
+		reply.Header.ReplyTo = hop.For
+		if route.Session != "" {
+			reply.Header.ReplyTo += "@" + route.Session
+		}
+		reply.Header.ReplyProject = route.Project
+		reply.Header.FromProject = route.Project
+	}
+	data, err := reply.Marshal()
+	if err != nil {
+		return "", err
+	}
+	filename := id + ".md"
+	if err := deliverAdmitted(configFS, sourceFS, reply.Header, msg.Header.From, filename, data); err != nil {
+		return "", err
+	}
+	_, err = deliveryFS.WriteFileAtomic(filepath.Join("agents", hop.For, "outbox", "sent"), filename, data, 0o600)
+	return id, err
+}
+
+// deliverAdmitted writes a delegate copy or away reply into agent's existing
+// inbox on the same admitted path as a send: the recipient's ACL and quota
+// both apply. Neither can wait or be dropped, so a full inbox refuses it.
+func deliverAdmitted(configFS, deliveryFS *fsq.DeliveryRoot, header format.Header, agent, filename string, data []byte) error {
+	if _, err := quota.Admit(context.Background(), configFS, deliveryFS, quota.OnFullFail, header.From, []string{agent}, int64(len(data)), header.Priority); err != nil {
+		return err
+	}
+	_, err := acl.DeliverToExistingInbox(configFS, deliveryFS, header, agent, filename, data)
+	return err
+}
@@ -0,0 +1,163 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/acl"
+	"github.com/avivsinai/agent-message-queue/internal/breaker"
+	"github.com/avivsinai/agent-message-queue/internal/config"
+	"github.com/avivsinai/agent-message-queue/internal/format"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+	"github.com/avivsinai/agent-message-queue/internal/presence"
+	"github.com/avivsinai/agent-message-queue/internal/quota"
+)
+
+// Local delivers a message to recipients in the same root tree as its
+// sender. ConfigFS holds the ACL, quota, and breaker policy; DeliveryFS holds
+// the recipients' mailboxes; SourceFS, when it differs from DeliveryFS, holds
+// the sender's outbox and presence (a cross-session send).
+type Local struct {
+	ConfigFS   *fsq.DeliveryRoot
+	DeliveryFS *fsq.DeliveryRoot
+	SourceFS   *fsq.DeliveryRoot
+
+	// OnFull is the quota.OnFull* policy for a recipient over quota; the
+	// context passed to Deliver bounds how long OnFullWait may block.
+	OnFull string
+
+	// Prepare, when set, runs after admission and before anything is
+	// written: the CLI creates and re-verifies recipient mailboxes here.
+	Prepare func() error
+
+	// Capability is the routing decision that chose the recipient, if any.
+	// Its round-robin cursor advances only once the message is delivered.
+	Capability *CapabilityPick
+}
+
+// Result reports what Deliver did with a message.
+type Result struct {
+	// Dropped is set when a full recipient's OnFullDrop policy discarded
+	// the message; nothing was written.
+	Dropped bool
+	// Held is set when the thread's loop breaker quarantined the message;
+	// Breaker carries the decision.
+	Held    bool
+	Breaker breaker.Decision
+	// Hops are the out-of-office forwards the delivery made.
+	Hops []Hop
+	// OutboxErr is a failure to copy the message to the sender's outbox,
+	// which does not undo the delivery.
+	OutboxErr error
+	// Warnings are best-effort steps that failed after delivery.
+	Warnings []string
+}
+
+// Deliver runs the local send pipeline for msg, the same one amq send and
+// amq reply use: recipient ACLs, out-of-office delegation, quotas, the loop
+// breaker, delivery, delegate copies, the capability cursor, the sender's
+// presence, away replies, and the outbox copy. ACL refusals are
+// *acl.DeniedError and quota refusals *quota.ExceededError.
+func (l Local) Deliver(ctx context.Context, msg format.Message) (Result, error) {
+	var result Result
+	sourceFS := l.SourceFS
+	if sourceFS == nil {
+		sourceFS = l.DeliveryFS
+	}
+	data, err := msg.Marshal()
+	if err != nil {
+		return result, err
+	}
+	if err := acl.Authorize(l.ConfigFS, msg.Header); err != nil {
+		return result, err
+	}
+	// Away recipients with a delegate have their mail forwarded; quotas
+	// count every inbox the delivery actually writes to.
+	now := time.Now()
+	delegation, err := PlanDelegation(l.ConfigFS, l.DeliveryFS, msg.Header, now)
+	if err != nil {
+		return result, err
+	}
+	dropped, err := quota.Admit(ctx, l.ConfigFS, l.DeliveryFS, l.OnFull, msg.Header.From, delegation.All, int64(len(data)), msg.Header.Priority)
+	if err != nil {
+		return result, err
+	}
+	if dropped {
+		result.Dropped = true
+		return result, nil
+	}
+	if l.Prepare != nil {
+		if err := l.Prepare(); err != nil {
+			return result, err
+		}
+	}
+
+	filename := msg.Header.ID + ".md"
+	decision, warning, err := AdmitBreaker(l.ConfigFS, l.DeliveryFS, msg, filename, data, now)
+	if err != nil {
+		return result, err
+	}
+	if warning != "" {
+		result.Warnings = append(result.Warnings, warning)
+	}
+	result.Breaker = decision
+	if decision.Held {
+		result.Held = true
+		return result, nil
+	}
+	if len(delegation.Direct) > 0 {
+		if _, err := acl.DeliverToInboxes(l.ConfigFS, l.DeliveryFS, msg.Header, delegation.Direct, filename, data); err != nil {
+			return result, err
+		}
+	}
+	if err := delegation.DeliverCopies(l.ConfigFS, l.DeliveryFS, msg, filename); err != nil {
+		return result, err
+	}
+	result.Hops = delegation.Hops
+
+	if l.Capability != nil {
+		if err := AdvanceCursor(l.DeliveryFS, *l.Capability); err != nil {
+			result.Warnings = append(result.Warnings, err.Error())
+		}
+	}
+	// Best-effort presence touch; sending is how an away agent returns.
+	_ = presence.ReturnDeliveryRoot(sourceFS, msg.Header.From)
+	result.Warnings = append(result.Warnings, delegation.SendAwayReplies(l.ConfigFS, sourceFS, l.DeliveryFS, msg, ReplyRoute{}, now)...)
+
+	// Copy to sender outbox/sent for audit (always in sender's root).
+	outboxDir := filepath.Join("agents", msg.Header.From, "outbox", "sent")
+	if _, err := sourceFS.WriteFileAtomic(outboxDir, filename, data, 0o600); err != nil {
+		result.OutboxErr = err
+	}
+	return result, nil
+}
+
+// AdmitBreaker runs loop detection for a delivery of msg into deliveryFS.
+// Detection is on unless the root config sets breaker.disabled, so every
+// admitted send updates meta/breakers/<key>/state.json. When this message
+// trips the breaker, the configured notify handle is told; a failed notice
+// is returned as a warning rather than refusing the send.
+func AdmitBreaker(configFS, deliveryFS *fsq.DeliveryRoot, msg format.Message, filename string, data []byte, now time.Time) (breaker.Decision, string, error) {
+	cfg, err := config.ReadConfig(configFS)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return breaker.Decision{}, "", fmt.Errorf("read breaker config: %w", err)
+	}
+	thresholds, err := cfg.Breaker.Thresholds()
+	if err != nil {
+		return breaker.Decision{}, "", err
+	}
+	decision, err := breaker.Admit(deliveryFS, thresholds, msg.Header.Thread, msg.Header.From, msg.Body, filename, data, now)
+	if err != nil {
+		return decision, "", fmt.Errorf("loop breaker: %w", err)
+	}
+	if decision.Tripped {
+		if err := breaker.Notify(configFS, deliveryFS, thresholds.Notify, msg.Header.From, decision.State, now); err != nil {
+			return decision, fmt.Sprintf("loop breaker tripped on %s but notifying %s failed: %v", decision.State.Thread, thresholds.Notify, err), nil
+		}
+	}
+	return decision, "", nil
+}
@@ -0,0 +1,167 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/acl"
+	"github.com/avivsinai/agent-message-queue/internal/config"
+	"github.com/avivsinai/agent-message-queue/internal/format"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+	"github.com/avivsinai/agent-message-queue/internal/presence"
+	"github.com/avivsinai/agent-message-queue/internal/quota"
+)
+
+func setupRoot(t *testing.T, cfg config.Config, agents ...string) (string, *fsq.DeliveryRoot) {
+	t.Helper()
+	root := t.TempDir()
+	for _, agent := range agents {
+		if err := fsq.EnsureAgentDirs(root, agent); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(root, "meta"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Version = 1
+	cfg.Agents = agents
+	if err := config.WriteConfig(filepath.Join(root, "meta", "config.json"), cfg, true); err != nil {
+		t.Fatal(err)
+	}
+	identity, err := fsq.SnapshotDeliveryRoot(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deliveryRoot, err := fsq.OpenDeliveryRoot(root, identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = deliveryRoot.Close() })
+	return root, deliveryRoot
+}
+
+func testMessage(t *testing.T, from string, to ...string) format.Message {
+	t.Helper()
+	now := time.Now()
+	id, err := format.NewMessageID(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return format.Message{
+		Header: format.Header{
+			Schema:   format.CurrentSchema,
+			ID:       id,
+			From:     from,
+			To:       to,
+			Thread:   "p2p/test",
+			Created:  now.UTC().Format(time.RFC3339Nano),
+			Priority: format.PriorityNormal,
+			Kind:     format.KindQuestion,
+		},
+		Body: "hello\n",
+	}
+}
+
+func inboxHas(root, agent, id string) bool {
+	_, err := os.Stat(filepath.Join(fsq.AgentMailboxPath(root, agent, fsq.MailboxInboxNew), id+".md"))
+	return err == nil
+}
+
+func TestDeliverMovesMailToDelegateAndRepliesOnce(t *testing.T) {
+	root, deliveryFS := setupRoot(t, config.Config{}, "claude", "codex", "grok")
+	away := presence.New("codex", "away", "", time.Now())
+	away.Delegate = "grok"
+	away.DelegateMode = presence.DelegateMove
+	if err := presence.WriteDeliveryRoot(deliveryFS, away); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := testMessage(t, "claude", "codex")
+	result, err := Local{ConfigFS: deliveryFS, DeliveryFS: deliveryFS, OnFull: quota.OnFullFail}.Deliver(context.Background(), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Hops) != 1 || result.Hops[0].To != "grok" || len(result.Warnings) != 0 || result.OutboxErr != nil {
+		t.Fatalf("result = %+v", result)
+	}
+	if inboxHas(root, "codex", msg.Header.ID) || !inboxHas(root, "grok", msg.Header.ID) {
+		t.Fatal("move mode must deliver only to the delegate")
+	}
+	entries, err := os.ReadDir(fsq.AgentMailboxPath(root, "claude", fsq.MailboxInboxNew))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("claude inbox = %v (%v), want one away reply", entries, err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "agents", "claude", "outbox", "sent", msg.Header.ID+".md")); err != nil {
+		t.Fatalf("outbox copy: %v", err)
+	}
+}
+
+func TestAwayReplyIsSentOncePerSenderAndPeriod(t *testing.T) {
+	root, deliveryFS := setupRoot(t, config.Config{}, "claude", "codex", "grok")
+	setAway := func(since time.Time) {
+		away := presence.New("codex", "away", "", since)
+		away.Delegate = "grok"
+		away.DelegateMode = presence.DelegateCopy
+		away.DelegateSince = since.UTC().Format(time.RFC3339Nano)
+		if err := presence.WriteDeliveryRoot(deliveryFS, away); err != nil {
+			t.Fatal(err)
+		}
+	}
+	awayReplies := func() int {
+		entries, err := os.ReadDir(fsq.AgentMailboxPath(root, "claude", fsq.MailboxInboxNew))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(entries)
+	}
+	deliver := func() Result {
+		result, err := Local{ConfigFS: deliveryFS, DeliveryFS: deliveryFS, OnFull: quota.OnFullFail}.Deliver(context.Background(), testMessage(t, "claude", "codex"))
+		if err != nil || len(result.Hops) != 1 || len(result.Warnings) != 0 {
+			t.Fatalf("Deliver = %+v, %v", result, err)
+		}
+		return result
+	}
+
+	setAway(time.Now().Add(-time.Hour))
+	deliver()
+	deliver()
+	if got := awayReplies(); got != 1 {
+		t.Fatalf("away replies in one period = %d, want 1", got)
+	}
+	// Going away again starts a new period, so the sender is told again.
+	setAway(time.Now())
+	deliver()
+	if got := awayReplies(); got != 2 {
+		t.Fatalf("away replies after a new period = %d, want 2", got)
+	}
+}
+
+func TestDeliverRefusesDeniedSenderBeforeWriting(t *testing.T) {
+	cfg := config.Config{ACL: &config.ACLConfig{Agents: map[string]config.ACLPolicy{"codex": {Deny: []config.ACLRule{{From: []string{"claude"}}}}}}}
+	root, deliveryFS := setupRoot(t, cfg, "claude", "codex")
+	msg := testMessage(t, "claude", "codex")
+	_, err := Local{ConfigFS: deliveryFS, DeliveryFS: deliveryFS, OnFull: quota.OnFullFail}.Deliver(context.Background(), msg)
+	var denied *acl.DeniedError
+	if !errors.As(err, &denied) {
+		t.Fatalf("Deliver error = %v, want *acl.DeniedError", err)
+	}
+	if inboxHas(root, "codex", msg.Header.ID) {
+		t.Fatal("a denied message was delivered")
+	}
+}
+
+func TestDeliverAdmittedRefusesFullInbox(t *testing.T) {
+	_, deliveryFS := setupRoot(t, config.Config{Quotas: &config.QuotaConfig{Default: config.QuotaLimits{MaxUndrained: 1}}}, "claude", "codex")
+	header := format.Header{From: "codex", Priority: format.PriorityLow}
+	if err := deliverAdmitted(deliveryFS, deliveryFS, header, "claude", "a.md", []byte("a")); err != nil {
+		t.Fatalf("first delivery: %v", err)
+	}
+	var exceeded *quota.ExceededError
+	if err := deliverAdmitted(deliveryFS, deliveryFS, header, "claude", "b.md", []byte("b")); !errors.As(err, &exceeded) {
+		t.Fatalf("second delivery = %v, want quota exceeded", err)
+	}
+}
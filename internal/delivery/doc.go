@@ -0,0 +1,6 @@
+// Package delivery is the local send pipeline shared by amq send, amq reply,
+// and the amqapi client. Capability routing picks a recipient from presence;
+// Local.Deliver then applies the recipient ACL, plans out-of-office forwards,
+// admits the quota, runs the loop breaker, writes the inboxes and forwards,
+// and records the sender's outbox copy, in that order.
+package delivery
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"sort"
 	"strings"
 	"time"
@@ -187,6 +188,21 @@ func ReadMessageFile(path string) (Message, error) {
 		return Message{}, err
 	}
 	defer func() { _ = file.Close() }()
+	return readMessage(file, info)
+}
+
+// ReadMessageDeliveryRoot is ReadMessageFile for a root-relative name read
+// through a pinned root capability.
+func ReadMessageDeliveryRoot(root *fsq.DeliveryRoot, name string) (Message, error) {
+	file, info, err := root.OpenRegularNoFollow(name)
+	if err != nil {
+		return Message{}, err
+	}
+	defer func() { _ = file.Close() }()
+	return readMessage(file, info)
+}
+
+func readMessage(file *os.File, info os.FileInfo) (Message, error) {
 	if info.Size() > MaxMessageSize {
 		return Message{}, fmt.Errorf("%w: %d bytes", ErrMessageTooLarge, info.Size())
 	}
@@ -206,6 +222,16 @@ func ReadHeaderFile(path string) (Header, error) {
 	return ReadHeader(file)
 }
 
+// ReadHeaderDeliveryRoot is ReadHeaderFile through a pinned root capability.
+func ReadHeaderDeliveryRoot(root *fsq.DeliveryRoot, name string) (Header, error) {
+	file, _, err := root.OpenRegularNoFollow(name)
+	if err != nil {
+		return Header{}, err
+	}
+	defer func() { _ = file.Close() }()
+	return ReadHeader(file)
+}
+
 func ReadHeader(r io.Reader) (Header, error) {
 	lr := io.LimitReader(r, MaxMessageSize)
 	br := bufio.NewReader(lr)
@@ -0,0 +1,104 @@
+package format
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+)
+
+// ValidateHandle checks that handle is already a normalized mailbox handle.
+// label names the field in errors ("sender", "recipient").
+func ValidateHandle(label, handle string) error {
+	if strings.TrimSpace(handle) == "" {
+		return fmt.Errorf("%s handle is empty", label)
+	}
+	norm := strings.TrimSpace(handle)
+	if strings.ContainsAny(norm, "/\\") {
+		return fmt.Errorf("invalid %s handle: invalid handle (slashes not allowed): %s", label, norm)
+	}
+	if err := fsq.ValidateHandle(norm); err != nil {
+		return fmt.Errorf("invalid %s handle: %w", label, err)
+	}
+	if norm != handle {
+		return fmt.Errorf("invalid %s handle (not normalized): %s", label, handle)
+	}
+	return nil
+}
+
+// SafeBaseName rejects names that could escape a directory or shadow a
+// message filename. It is used for message ids embedded in headers.
+func SafeBaseName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("name cannot be empty")
+	}
+	if strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid name: %s", name)
+	}
+	if name == "." || name == ".." {
+		return "", fmt.Errorf("invalid name: %s", name)
+	}
+	if filepath.Base(name) != name {
+		return "", fmt.Errorf("invalid name: %s", name)
+	}
+	if strings.ContainsAny(name, "/\\") {
+		return "", fmt.Errorf("invalid name: %s", name)
+	}
+	if strings.HasSuffix(name, ".md") {
+		return "", fmt.Errorf("invalid name: %s", name)
+	}
+	return name, nil
+}
+
+// ValidateHeader checks every header field except the schema version, which
+// only strict callers enforce.
+func ValidateHeader(header Header) error {
+	return ValidateHeaderWith(header, ValidateHandle)
+}
+
+// ValidateHeaderWith is ValidateHeader with a caller-supplied handle check,
+// for inspection paths that must tolerate legacy handles.
+func ValidateHeaderWith(header Header, validateHandle func(label, handle string) error) error {
+	if _, err := SafeBaseName(header.ID); err != nil {
+		return fmt.Errorf("invalid message id: %w", err)
+	}
+	if err := validateHandle("sender", header.From); err != nil {
+		return err
+	}
+	if len(header.To) == 0 {
+		return errors.New("missing recipients")
+	}
+	for _, recipient := range header.To {
+		if err := validateHandle("recipient", recipient); err != nil {
+			return err
+		}
+	}
+	thread := strings.TrimSpace(header.Thread)
+	if thread == "" {
+		return errors.New("missing thread")
+	}
+	if thread != header.Thread {
+		return errors.New("thread contains leading/trailing whitespace")
+	}
+	created := strings.TrimSpace(header.Created)
+	if created == "" {
+		return errors.New("missing created timestamp")
+	}
+	if created != header.Created {
+		return errors.New("created timestamp contains leading/trailing whitespace")
+	}
+	if _, err := time.Parse(time.RFC3339Nano, header.Created); err != nil {
+		return fmt.Errorf("invalid created timestamp: %w", err)
+	}
+	if !IsValidPriority(header.Priority) {
+		return fmt.Errorf("invalid priority: %s", header.Priority)
+	}
+	if !IsValidKind(header.Kind) {
+		return fmt.Errorf("invalid kind: %s", header.Kind)
+	}
+	return nil
+}
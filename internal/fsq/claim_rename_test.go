@@ -110,3 +110,31 @@ func TestMoveNewToCurLoserForMissingMessage(t *testing.T) {
 		t.Fatalf("error = %T %v, want os.IsNotExist", err, err)
 	}
 }
+
+func TestMoveCurToNewReturnsClaimWithoutReplacing(t *testing.T) {
+	base := setupClaimAgent(t, "queue")
+	writeClaimMessage(t, base, "queue", "lease.md")
+
+	root := openDeliveryRootForTest(t, base)
+	if err := MoveNewToCur(root, "queue", "lease.md"); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if err := MoveCurToNew(root, "queue", "lease.md"); err != nil {
+		t.Fatalf("return: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(AgentInboxNew(base, "queue"), "lease.md")); err != nil {
+		t.Fatalf("returned message missing from new: %v", err)
+	}
+	if err := MoveCurToNew(root, "queue", "lease.md"); !os.IsNotExist(err) {
+		t.Fatalf("second return error = %T %v, want os.IsNotExist", err, err)
+	}
+
+	if err := MoveNewToCur(root, "queue", "lease.md"); err != nil {
+		t.Fatalf("reclaim: %v", err)
+	}
+	writeClaimMessage(t, base, "queue", "lease.md")
+	var collision *ClaimCollisionError
+	if err := MoveCurToNew(root, "queue", "lease.md"); !errors.As(err, &collision) {
+		t.Fatalf("return onto existing new copy error = %T %v, want ClaimCollisionError", err, err)
+	}
+}
@@ -687,6 +687,60 @@ func (r *DeliveryRoot) Remove(name string) error {
 	return r.root.Remove(name)
 }
 
+// RenameDirectChild renames one direct child directory without replacing an
+// existing entry. The child must be a real directory, never a symlink.
+func (r *DeliveryRoot) RenameDirectChild(oldName, newName string) error {
+	for _, name := range []string{oldName, newName} {
+		if name == "" || name == "." || name == ".." || filepath.Base(name) != name {
+			return fmt.Errorf("invalid direct child name %q", name)
+		}
+	}
+	if err := r.VerifyBase(); err != nil {
+		return err
+	}
+	info, err := r.root.Lstat(oldName)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() || info.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("%q is not a direct directory under delivery root", oldName)
+	}
+	if _, err := r.root.Lstat(newName); err == nil {
+		return &DirectChildExistsError{Name: newName}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := r.renameDirectChildNoReplace(oldName, newName); err != nil {
+		if _, statErr := r.root.Lstat(newName); statErr == nil {
+			return &DirectChildExistsError{Name: newName}
+		}
+		return err
+	}
+	return r.SyncDir(".")
+}
+
+// RemoveDirectChild removes one direct child directory tree through the
+// pinned root. Symlinks inside the tree are removed, never followed.
+func (r *DeliveryRoot) RemoveDirectChild(name string) error {
+	if name == "" || name == "." || name == ".." || filepath.Base(name) != name {
+		return fmt.Errorf("invalid direct child name %q", name)
+	}
+	if err := r.VerifyBase(); err != nil {
+		return err
+	}
+	info, err := r.root.Lstat(name)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() || info.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("%q is not a direct directory under delivery root", name)
+	}
+	if err := r.root.RemoveAll(name); err != nil {
+		return err
+	}
+	return r.SyncDir(".")
+}
+
 // OpenLockFile opens or creates a root-relative file for advisory locking.
 // The file is opened O_CREATE on its stable name and is never replaced, so
 // flock serializes on one inode. Callers must close the returned file.
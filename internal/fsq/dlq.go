@@ -3,6 +3,7 @@ package fsq
 import (
 	"bytes"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
@@ -33,6 +34,10 @@ var (
 	// recorded as pending but its destination is no longer visible. AMQ cannot
 	// safely distinguish a never-committed delivery from one already consumed.
 	ErrDLQRetryIndeterminate = errors.New("DLQ envelope retry outcome is indeterminate")
+
+	// ErrDLQFixStale marks a fix prepared against an envelope whose embedded
+	// original changed before the per-envelope lock was acquired.
+	ErrDLQFixStale = errors.New("DLQ envelope changed while the fix was prepared")
 )
 
 // DLQEnvelope wraps a failed message with failure metadata.
@@ -49,6 +54,27 @@ type DLQEnvelope struct {
 	RetryPending   bool   `json:"retry_pending,omitempty"`
 	RetryDelivered bool   `json:"retry_delivered,omitempty"`
 	SourceDir      string `json:"source_dir"`
+
+	// Fix is the audit of a repaired revision delivered in place of the
+	// embedded original. It is set only by FixFromDLQ.
+	Fix *DLQFix `json:"fix,omitempty"`
+}
+
+// DLQFix records a corrected revision of a dead-lettered message. The
+// revision is a new message whose refs link back to the original.
+type DLQFix struct {
+	RevisionID   string         `json:"revision_id"`
+	RevisionFile string         `json:"revision_file"`
+	FixedAt      string         `json:"fixed_at"`
+	Changes      []DLQFixChange `json:"changes"`
+}
+
+// DLQFixChange describes one header field (or the body) that differs between
+// the embedded original and the delivered revision.
+type DLQFixChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
 }
 
 // normalizeRetryState makes retry_state the durable authority and exposes the
@@ -488,6 +514,11 @@ func retryFromDLQLocked(root *DeliveryRoot, agent, dlqFilename string, force boo
 			envelope.OriginalFile,
 		)
 	}
+	if envelope.Fix != nil {
+		// A pending fix delivered a revision, not the original. Reconcile it by
+		// the revision filename instead of redelivering the broken original.
+		return reconcilePendingDLQFixLocked(root, agent, dlqFilename, dlqPath, box, envelope, originalContent)
+	}
 
 	// Refuse every retained original before mutating the envelope. Checking cur
 	// as well as new prevents a partial DLQ transition from being retried into a
@@ -611,6 +642,164 @@ func retryFromDLQLocked(root *DeliveryRoot, agent, dlqFilename string, force boo
 	return nil
 }
 
+// FixFromDLQ delivers revision to the agent's inbox as a new message that
+// replaces the embedded original, and records fix as the envelope's terminal
+// audit. The caller builds the revision outside the per-envelope lock from an
+// original whose SHA-256 is originalDigest; the fix is refused with
+// ErrDLQFixStale when the envelope changed in the meantime.
+//
+// Fixes share the retry state machine: the envelope is persisted as pending
+// before delivery and as delivered afterwards, so a later dlq retry cannot
+// resurrect the broken original.
+func FixFromDLQ(root *DeliveryRoot, agent, dlqFilename string, originalDigest [sha256.Size]byte, revision []byte, fix DLQFix) error {
+	return root.WithDLQEnvelopeLock(agent, dlqFilename, func(batch *DeliveryRoot) error {
+		return fixFromDLQLocked(batch, agent, dlqFilename, originalDigest, revision, fix)
+	})
+}
+
+func fixFromDLQLocked(root *DeliveryRoot, agent, dlqFilename string, originalDigest [sha256.Size]byte, revision []byte, fix DLQFix) error {
+	if err := ValidateMessageFilename(fix.RevisionFile); err != nil {
+		return fmt.Errorf("invalid revision file %q: %w", fix.RevisionFile, err)
+	}
+	dlqPath, box, err := FindDLQMessage(root, agent, dlqFilename)
+	if err != nil {
+		return err
+	}
+	envelope, originalContent, err := ReadDLQEnvelope(root, dlqPath)
+	if err != nil {
+		return fmt.Errorf("read dlq envelope: %w", err)
+	}
+	if box == BoxCur {
+		if _, err := reconcileDLQCurAuthorityLocked(root, agent, dlqFilename); err != nil {
+			return err
+		}
+	}
+	if sha256.Sum256(originalContent) != originalDigest {
+		return fmt.Errorf("%w: %s", ErrDLQFixStale, dlqFilename)
+	}
+
+	switch envelope.RetryState {
+	case RetryStateDelivered:
+		return fmt.Errorf("%w: %s (already delivered)", ErrDLQRetryDelivered, dlqFilename)
+	case RetryStatePending, RetryStateIndeterminate:
+		if envelope.Fix != nil {
+			return reconcilePendingDLQFixLocked(root, agent, dlqFilename, dlqPath, box, envelope, originalContent)
+		}
+		return fmt.Errorf(
+			"%w: %s retry for %s must be reconciled with dlq retry before fixing",
+			ErrDLQRetryIndeterminate,
+			envelope.RetryState,
+			envelope.OriginalFile,
+		)
+	}
+	for _, inboxBox := range []string{BoxNew, BoxCur} {
+		path := filepath.Join("agents", agent, "inbox", inboxBox, fix.RevisionFile)
+		if _, err := root.Stat(path); err == nil {
+			return fmt.Errorf("revision file already exists in inbox/%s: %s (refusing fix)", inboxBox, fix.RevisionFile)
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("stat inbox/%s revision: %w", inboxBox, err)
+		}
+	}
+
+	envelope.RetryCount++
+	envelope.Fix = &fix
+	setRetryState(envelope, RetryStatePending)
+	updatedData, err := serializeDLQMessage(*envelope, originalContent)
+	if err != nil {
+		return fmt.Errorf("serialize fixed dlq envelope: %w", err)
+	}
+	if err := updateRetriedDLQEnvelope(root, agent, dlqFilename, dlqPath, box, updatedData); err != nil {
+		return err
+	}
+	dlqPath = filepath.Join("agents", agent, "dlq", BoxCur, dlqFilename)
+	box = BoxCur
+
+	inboxPath, deliveryErr := DeliverToInbox(root, agent, fix.RevisionFile, revision)
+	if deliveryErr != nil {
+		var committed *CommittedDurabilityError
+		if !errors.As(deliveryErr, &committed) {
+			envelope.Fix = nil
+			setRetryState(envelope, RetryStateReady)
+			updatedData, err := serializeDLQMessage(*envelope, originalContent)
+			if err != nil {
+				return errors.Join(
+					fmt.Errorf("deliver fixed revision: %w", deliveryErr),
+					fmt.Errorf("serialize reset dlq envelope: %w", err),
+				)
+			}
+			if err := updateRetriedDLQEnvelope(root, agent, dlqFilename, dlqPath, box, updatedData); err != nil {
+				return errors.Join(
+					fmt.Errorf("deliver fixed revision: %w", deliveryErr),
+					fmt.Errorf("reset fixed dlq envelope: %w", err),
+				)
+			}
+			return fmt.Errorf("deliver fixed revision: %w", deliveryErr)
+		}
+		inboxPath = committed.FinalPath
+	}
+	setRetryState(envelope, RetryStateDelivered)
+	updatedData, err = serializeDLQMessage(*envelope, originalContent)
+	if err != nil {
+		return fmt.Errorf("serialize completed fixed dlq envelope: %w", err)
+	}
+	if err := updateRetriedDLQEnvelope(root, agent, dlqFilename, dlqPath, box, updatedData); err != nil {
+		if deliveryErr != nil {
+			return errors.Join(
+				fmt.Errorf("deliver fixed revision: %w", deliveryErr),
+				fmt.Errorf("finalize fixed dlq envelope: %w", err),
+			)
+		}
+		return &CommittedDurabilityError{
+			FinalPath: inboxPath,
+			Recipient: agent,
+			Err:       fmt.Errorf("finalize fixed dlq envelope: %w", err),
+		}
+	}
+	if deliveryErr != nil {
+		return fmt.Errorf("deliver fixed revision: %w", deliveryErr)
+	}
+	return nil
+}
+
+// reconcilePendingDLQFixLocked finishes the audit of a fix interrupted after
+// its pending state was persisted. A visible revision proves delivery; an
+// absent one is indeterminate because it may already have been consumed.
+func reconcilePendingDLQFixLocked(root *DeliveryRoot, agent, dlqFilename, dlqPath, box string, envelope *DLQEnvelope, originalContent []byte) error {
+	presentBox := ""
+	for _, inboxBox := range []string{BoxNew, BoxCur} {
+		path := filepath.Join("agents", agent, "inbox", inboxBox, envelope.Fix.RevisionFile)
+		if _, err := root.Stat(path); err == nil {
+			presentBox = inboxBox
+			break
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("stat inbox/%s revision: %w", inboxBox, err)
+		}
+	}
+	if presentBox == "" {
+		return fmt.Errorf(
+			"%w: %s fix for %s has no visible inbox revision %s; do not retry blindly",
+			ErrDLQRetryIndeterminate,
+			envelope.RetryState,
+			envelope.OriginalFile,
+			envelope.Fix.RevisionFile,
+		)
+	}
+	setRetryState(envelope, RetryStateDelivered)
+	updatedData, err := serializeDLQMessage(*envelope, originalContent)
+	if err != nil {
+		return fmt.Errorf("serialize recovered fixed dlq envelope: %w", err)
+	}
+	if err := updateRetriedDLQEnvelope(root, agent, dlqFilename, dlqPath, box, updatedData); err != nil {
+		return fmt.Errorf("fix revision exists but finalize recovered dlq envelope: %w", err)
+	}
+	return fmt.Errorf(
+		"%w: revision exists in inbox/%s: %s (fix already delivered)",
+		ErrDLQRetryDelivered,
+		presentBox,
+		envelope.Fix.RevisionFile,
+	)
+}
+
 func updateRetriedDLQEnvelope(root *DeliveryRoot, agent, dlqFilename, dlqPath, box string, updatedData []byte) error {
 	curDir := filepath.Join("agents", agent, "dlq", "cur")
 	if err := root.root.MkdirAll(curDir, 0o700); err != nil {
@@ -2,6 +2,7 @@ package fsq
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"errors"
 	"os"
 	"path/filepath"
@@ -1501,3 +1502,116 @@ func TestRetryFromDLQRedeliveryPostRenameSyncFailureReportsCommittedInboxResult(
 		t.Fatalf("committed retry cur envelope = %#v, err=%v; want completed retry audit", env, readErr)
 	}
 }
+
+func TestFixFromDLQDeliversRevisionAndAuditsEnvelope(t *testing.T) {
+	root := t.TempDir()
+	if err := EnsureAgentDirs(root, "alice"); err != nil {
+		t.Fatalf("EnsureAgentDirs: %v", err)
+	}
+	original := []byte("broken original")
+	dlqPath := createDLQMessage(t, root, "alice", "broken.md", original)
+	dlqFilename := filepath.Base(dlqPath)
+	revision := []byte("---json\n{\"id\":\"fixed\"}\n---\nrepaired\n")
+	fix := DLQFix{
+		RevisionID:   "fixed",
+		RevisionFile: "fixed.md",
+		FixedAt:      "2026-10-19T00:00:00Z",
+		Changes:      []DLQFixChange{{Field: "kind", Before: "bogus", After: "question"}},
+	}
+
+	if err := FixFromDLQ(openDeliveryRootForTest(t, root), "alice", dlqFilename, sha256.Sum256(original), revision, fix); err != nil {
+		t.Fatalf("FixFromDLQ: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(AgentInboxNew(root, "alice"), "fixed.md"))
+	if err != nil || !bytes.Equal(got, revision) {
+		t.Fatalf("revision in inbox = %q err=%v, want %q", got, err, revision)
+	}
+	if _, err := os.Stat(filepath.Join(AgentInboxNew(root, "alice"), "broken.md")); !os.IsNotExist(err) {
+		t.Fatalf("broken original was redelivered: %v", err)
+	}
+	env, body, err := ReadDLQEnvelopePath(filepath.Join(AgentDLQCur(root, "alice"), dlqFilename))
+	if err != nil {
+		t.Fatalf("read fixed envelope: %v", err)
+	}
+	if env.RetryState != RetryStateDelivered || env.Fix == nil || env.Fix.RevisionID != "fixed" || len(env.Fix.Changes) != 1 {
+		t.Fatalf("fixed envelope = %#v fix=%#v, want delivered audit", env, env.Fix)
+	}
+	if !bytes.Equal(body, original) {
+		t.Fatalf("envelope original = %q, want retained %q", body, original)
+	}
+
+	if err := RetryFromDLQ(openDeliveryRootForTest(t, root), "alice", dlqFilename, true); !errors.Is(err, ErrDLQRetryDelivered) {
+		t.Fatalf("retry after fix = %v, want ErrDLQRetryDelivered", err)
+	}
+	if err := FixFromDLQ(openDeliveryRootForTest(t, root), "alice", dlqFilename, sha256.Sum256(original), revision, fix); !errors.Is(err, ErrDLQRetryDelivered) {
+		t.Fatalf("second fix = %v, want ErrDLQRetryDelivered", err)
+	}
+}
+
+func TestFixFromDLQRefusesStaleOriginalWithoutMutation(t *testing.T) {
+	root := t.TempDir()
+	if err := EnsureAgentDirs(root, "alice"); err != nil {
+		t.Fatalf("EnsureAgentDirs: %v", err)
+	}
+	dlqPath := createDLQMessage(t, root, "alice", "stale.md", []byte("current"))
+	before, err := os.ReadFile(dlqPath)
+	if err != nil {
+		t.Fatalf("read envelope: %v", err)
+	}
+	fix := DLQFix{RevisionID: "rev", RevisionFile: "rev.md"}
+
+	err = FixFromDLQ(openDeliveryRootForTest(t, root), "alice", filepath.Base(dlqPath), sha256.Sum256([]byte("older")), []byte("rev"), fix)
+	if !errors.Is(err, ErrDLQFixStale) {
+		t.Fatalf("stale fix = %v, want ErrDLQFixStale", err)
+	}
+	after, err := os.ReadFile(dlqPath)
+	if err != nil || !bytes.Equal(after, before) {
+		t.Fatalf("stale fix mutated envelope: err=%v", err)
+	}
+	if _, err := os.Stat(filepath.Join(AgentInboxNew(root, "alice"), "rev.md")); !os.IsNotExist(err) {
+		t.Fatalf("stale fix delivered revision: %v", err)
+	}
+}
+
+func TestRetryFromDLQFinalizesPendingFixByRevision(t *testing.T) {
+	root := t.TempDir()
+	if err := EnsureAgentDirs(root, "alice"); err != nil {
+		t.Fatalf("EnsureAgentDirs: %v", err)
+	}
+	original := []byte("broken")
+	dlqPath := createDLQMessage(t, root, "alice", "pending-fix.md", original)
+	dlqFilename := filepath.Base(dlqPath)
+	env, _, err := ReadDLQEnvelopePath(dlqPath)
+	if err != nil {
+		t.Fatalf("read envelope: %v", err)
+	}
+	env.RetryCount = 1
+	env.Fix = &DLQFix{RevisionID: "rev", RevisionFile: "rev.md"}
+	setRetryState(env, RetryStatePending)
+	data, err := serializeDLQMessage(*env, original)
+	if err != nil {
+		t.Fatalf("serialize pending fix: %v", err)
+	}
+	if err := os.WriteFile(dlqPath, data, 0o600); err != nil {
+		t.Fatalf("write pending fix: %v", err)
+	}
+
+	err = RetryFromDLQ(openDeliveryRootForTest(t, root), "alice", dlqFilename, true)
+	if !errors.Is(err, ErrDLQRetryIndeterminate) {
+		t.Fatalf("retry of invisible pending fix = %v, want indeterminate", err)
+	}
+	if _, err := os.Stat(filepath.Join(AgentInboxNew(root, "alice"), "pending-fix.md")); !os.IsNotExist(err) {
+		t.Fatalf("pending fix retry redelivered broken original: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(AgentInboxCur(root, "alice"), "rev.md"), []byte("rev"), 0o600); err != nil {
+		t.Fatalf("write visible revision: %v", err)
+	}
+	if err := RetryFromDLQ(openDeliveryRootForTest(t, root), "alice", dlqFilename, true); !errors.Is(err, ErrDLQRetryDelivered) {
+		t.Fatalf("retry of visible pending fix = %v, want ErrDLQRetryDelivered", err)
+	}
+	finalized, _, err := ReadDLQEnvelopePath(filepath.Join(AgentDLQCur(root, "alice"), dlqFilename))
+	if err != nil || finalized.RetryState != RetryStateDelivered {
+		t.Fatalf("finalized envelope = %#v err=%v, want delivered", finalized, err)
+	}
+}
@@ -35,6 +35,18 @@ func FindMessage(root, agent, filename string) (string, string, error) {
 }
 
 func MoveNewToCur(root *DeliveryRoot, agent, filename string) error {
+	return moveInboxBox(root, agent, filename, BoxNew, BoxCur)
+}
+
+// MoveCurToNew returns a claimed message to inbox/new, for example when a
+// queue claim's lease expires. It uses the same exclusive no-replace rename as
+// MoveNewToCur: one concurrent caller wins, losers observe os.IsNotExist, and
+// a copy already present in new is never overwritten.
+func MoveCurToNew(root *DeliveryRoot, agent, filename string) error {
+	return moveInboxBox(root, agent, filename, BoxCur, BoxNew)
+}
+
+func moveInboxBox(root *DeliveryRoot, agent, filename, fromBox, toBox string) error {
 	if err := ValidateHandle(agent); err != nil {
 		return err
 	}
@@ -44,23 +56,23 @@ func MoveNewToCur(root *DeliveryRoot, agent, filename string) error {
 	if err := root.VerifyBase(); err != nil {
 		return err
 	}
-	newPath := filepath.Join("agents", agent, "inbox", "new", filename)
-	curDir := filepath.Join("agents", agent, "inbox", "cur")
-	curPath := filepath.Join(curDir, filename)
-	if err := root.root.MkdirAll(curDir, 0o700); err != nil {
+	fromPath := filepath.Join("agents", agent, "inbox", fromBox, filename)
+	toDir := filepath.Join("agents", agent, "inbox", toBox)
+	toPath := filepath.Join(toDir, filename)
+	if err := root.root.MkdirAll(toDir, 0o700); err != nil {
 		return err
 	}
 	// claimRename is the exclusive-claim point: exactly one concurrent caller
 	// wins; losers observe os.IsNotExist. Windows cannot use os.Root.Rename
 	// here — it renames by handle and lets every contender succeed (#485).
-	if err := claimRename(root, newPath, curPath); err != nil {
+	if err := claimRename(root, fromPath, toPath); err != nil {
 		var residue *claimCommittedResidueError
 		if errors.As(err, &residue) {
 			// The destination name exists and this caller owns the claim; the
 			// leftover source name is reconciled by a later claimer. Same
 			// contract as a post-rename sync failure: committed, not failed.
 			return &CommittedDurabilityError{
-				FinalPath: root.displayPath(curPath),
+				FinalPath: root.displayPath(toPath),
 				Recipient: agent,
 				Err:       residue.Err,
 			}
@@ -74,15 +86,15 @@ func MoveNewToCur(root *DeliveryRoot, agent, filename string) error {
 	// rename is a committed claim with indeterminate durability, not a failed
 	// claim that callers may safely ignore or retry.
 	var durabilityErr error
-	if err := root.syncDir(curDir); err != nil {
-		durabilityErr = errors.Join(durabilityErr, fmt.Errorf("sync inbox/cur dir: %w", err))
+	if err := root.syncDir(toDir); err != nil {
+		durabilityErr = errors.Join(durabilityErr, fmt.Errorf("sync inbox/%s dir: %w", toBox, err))
 	}
-	if err := root.syncDir(filepath.Dir(newPath)); err != nil {
-		durabilityErr = errors.Join(durabilityErr, fmt.Errorf("sync inbox/new dir: %w", err))
+	if err := root.syncDir(filepath.Dir(fromPath)); err != nil {
+		durabilityErr = errors.Join(durabilityErr, fmt.Errorf("sync inbox/%s dir: %w", fromBox, err))
 	}
 	if durabilityErr != nil {
 		return &CommittedDurabilityError{
-			FinalPath: root.displayPath(curPath),
+			FinalPath: root.displayPath(toPath),
 			Recipient: agent,
 			Err:       durabilityErr,
 		}
@@ -1,16 +1,21 @@
 package common
 
 import (
+	"context"
 	"fmt"
 	"time"
 
+	"github.com/avivsinai/agent-message-queue/internal/acl"
 	"github.com/avivsinai/agent-message-queue/internal/format"
 	"github.com/avivsinai/agent-message-queue/internal/fsq"
+	"github.com/avivsinai/agent-message-queue/internal/quota"
 )
 
 // DeliverIntegrationMessage builds and delivers a standard integration message
 // to the specified recipient's inbox. It uses the same Maildir atomic delivery
-// as the rest of AMQ.
+// as the rest of AMQ. Recipient ACLs configured for the root are enforced and
+// yield an *acl.DeniedError. Mailbox quotas are enforced with the fail policy:
+// an over-quota recipient yields a *quota.ExceededError.
 //
 // Parameters:
 //   - root: AMQ root directory
@@ -62,7 +67,10 @@ func DeliverIntegrationMessage(root, from, to, subject, body string, ctx map[str
 		return "", fmt.Errorf("open delivery root: %w", err)
 	}
 	defer func() { _ = deliveryRoot.Close() }()
-	paths, err := fsq.DeliverToInboxes(deliveryRoot, msg.Header.To, filename, data)
+	if _, err := quota.Admit(context.Background(), deliveryRoot, deliveryRoot, quota.OnFullFail, from, msg.Header.To, int64(len(data)), priority); err != nil {
+		return "", err
+	}
+	paths, err := acl.DeliverToInboxes(deliveryRoot, deliveryRoot, msg.Header, msg.Header.To, filename, data)
 	if err != nil {
 		return "", fmt.Errorf("deliver message: %w", err)
 	}
@@ -404,6 +404,36 @@ func ClearJournalRaw(root *fsq.DeliveryRoot, lease *Lease, expected []byte) erro
 	return root.Remove(filepath.Join(bindingDirectory, journalFilename))
 }
 
+// RebindJournalSession points a retained launch journal at its session root
+// after amq session rename. It reports false when no journal exists. A journal
+// that records a joined tmux session is refused: that backend session is named
+// after the old AMQ session and cannot follow the rename.
+func RebindJournalSession(root *fsq.DeliveryRoot, lease *Lease, session string) (bool, error) {
+	record, err := LoadJournal(root)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if record.JoinBinding != nil {
+		return false, fmt.Errorf("launch journal records a joined tmux session named after %q", record.Session)
+	}
+	rootIdentity, err := canonicalIdentity(root.Base())
+	if err != nil {
+		return false, fmt.Errorf("resolve session root identity: %w", err)
+	}
+	rootPhysical, err := stableTreeIdentityInfo(root.FileInfo())
+	if err != nil {
+		return false, fmt.Errorf("resolve session root physical identity: %w", err)
+	}
+	record.Session, record.RootIdentity, record.RootPhysical = session, rootIdentity, rootPhysical
+	if err := WriteJournal(root, lease, record); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 func JournalPath(sessionRoot string) string {
 	return filepath.Join(sessionRoot, bindingDirectory, journalFilename)
 }
@@ -238,3 +238,46 @@ func TestLaunchJournalPersistsPlacementAndRejectsDrift(t *testing.T) {
 		t.Fatalf("journal accepted placement drift: %v", err)
 	}
 }
+
+func TestRebindJournalSessionFollowsRenamedSession(t *testing.T) {
+	backend := &reconcileBackend{name: "test", inspect: InspectAbsent}
+	request := reconcileFixture(t, backend)
+	nonce := "019c8a2f-2b13-7000-8000-000000000011"
+	plan, agents, conversations := journalFixturePlan(nonce)
+	digest, err := plan.SemanticDigest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lease, err := AcquireLease(request.Root, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = lease.Release() }()
+	if rebound, err := RebindJournalSession(request.Root, lease, "renamed"); err != nil || rebound {
+		t.Fatalf("rebind without journal = %v, %v", rebound, err)
+	}
+	record, err := NewLaunchJournal(request, backend.name, backend.Detect(), plan, digest, nonce, agents, conversations, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteJournal(request.Root, lease, record); err != nil {
+		t.Fatal(err)
+	}
+	if rebound, err := RebindJournalSession(request.Root, lease, "renamed"); err != nil || !rebound {
+		t.Fatalf("rebind = %v, %v", rebound, err)
+	}
+	loaded, err := LoadJournal(request.Root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Session != "renamed" {
+		t.Fatalf("journal session = %q", loaded.Session)
+	}
+	if err := loaded.ValidateRequest(request); err == nil {
+		t.Fatal("rebound journal still matches the old session")
+	}
+	request.Session = "renamed"
+	if err := loaded.ValidateRequest(request); err != nil {
+		t.Fatalf("rebound journal rejected renamed session: %v", err)
+	}
+}
@@ -2,6 +2,8 @@
 
 package lock
 
+import "os"
+
 // WithExclusiveFileLock is a best-effort no-op on unsupported platforms.
 //
 // Swarm interop is primarily used on macOS/Linux; keep non-unix builds compiling
@@ -9,3 +11,8 @@ package lock
 func WithExclusiveFileLock(_ string, fn func() error) error {
 	return fn()
 }
+
+// WithExclusiveFile is a best-effort no-op on unsupported platforms.
+func WithExclusiveFile(_ *os.File, fn func() error) error {
+	return fn()
+}
@@ -21,7 +21,12 @@ func WithExclusiveFileLock(lockPath string, fn func() error) error {
 		return fmt.Errorf("open lock file: %w", err)
 	}
 	defer func() { _ = f.Close() }()
+	return WithExclusiveFile(f, fn)
+}
 
+// WithExclusiveFile runs fn while holding an exclusive advisory lock on an
+// already-open lock file. The caller owns f and must close it.
+func WithExclusiveFile(f *os.File, fn func() error) error {
 	if err := unix.Flock(int(f.Fd()), unix.LOCK_EX); err != nil {
 		return fmt.Errorf("acquire lock: %w", err)
 	}
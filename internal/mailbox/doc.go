@@ -0,0 +1,6 @@
+// Package mailbox holds the inbox rules shared by the amq CLI and the amqapi
+// library: header and roster validation, and the claim-first drain that moves
+// inbox/new messages to inbox/cur (or the DLQ) and emits their receipts.
+// Messages are always addressed by their on-disk filename, which for bridged
+// mail differs from the header ID.
+package mailbox
@@ -0,0 +1,371 @@
+package mailbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/format"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+	"github.com/avivsinai/agent-message-queue/internal/receipt"
+)
+
+// ReceiptSenderUnavailableDetail is appended to a DLQ receipt whose sender
+// could not be read from the message.
+const ReceiptSenderUnavailableDetail = "sender unavailable because message metadata could not be parsed"
+
+// DrainOptions configures Drain. The Claim and DLQ hooks default to the fsq
+// transitions; tests substitute them to inject failures.
+type DrainOptions struct {
+	IncludeBody bool
+	// Limit caps the number of claimed messages; 0 drains everything.
+	Limit     int
+	Validator *HeaderValidator
+	// AfterClaim runs after each successful claim, before the message is read.
+	AfterClaim func(filename string) error
+	// Warn receives non-fatal problems, such as a receipt that could not be
+	// written. Nil discards them.
+	Warn func(format string, args ...any)
+
+	Claim            func(root *fsq.DeliveryRoot, agent, filename string) error
+	MoveToDLQ        func(root *fsq.DeliveryRoot, agent, filename, originalID, failureReason, failureDetail string) (string, error)
+	MoveClaimedToDLQ func(root *fsq.DeliveryRoot, agent, filename, originalID, failureReason, failureDetail string, claimErr error) (string, error)
+}
+
+// VanishedError reports a mailbox whose inbox disappeared while a drain was
+// claiming Filename from it.
+type VanishedError struct {
+	Handle   string
+	Filename string
+}
+
+func (e *VanishedError) Error() string {
+	return fmt.Sprintf("mailbox for %q disappeared while claiming %s", e.Handle, e.Filename)
+}
+
+// Drain claims inbox/new messages before parsing them, inside one pinned
+// batch, then reports and emits receipts only for messages this process
+// actually claimed. Claimed messages that fail parsing or validation are moved
+// to the DLQ. On error the items processed so far are still returned.
+func Drain(root *fsq.DeliveryRoot, me string, opts DrainOptions) ([]Item, error) {
+	var items []Item
+	err := root.WithPinnedBatch(func(batch *fsq.DeliveryRoot) error {
+		var err error
+		items, err = DrainPinned(batch, me, opts)
+		return err
+	})
+	return items, err
+}
+
+// DrainPinned is Drain for a root that is already pinned.
+func DrainPinned(root *fsq.DeliveryRoot, me string, opts DrainOptions) ([]Item, error) {
+	opts.defaults()
+	filenames, err := Filenames(root, me)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]Item, 0, len(filenames))
+	for _, filename := range filenames {
+		if opts.Limit > 0 && len(items) >= opts.Limit {
+			break
+		}
+		claimErr := opts.Claim(root, me, filename)
+		var committedClaim *fsq.CommittedDurabilityError
+		claimCommitted := errors.As(claimErr, &committedClaim)
+		if claimErr != nil && !claimCommitted {
+			if os.IsNotExist(claimErr) {
+				exists, checkErr := inboxDirsExist(root, me)
+				if checkErr != nil {
+					return finishBatch(items, checkErr)
+				}
+				if exists {
+					continue // claimed concurrently by another consumer
+				}
+				return finishBatch(items, &VanishedError{Handle: me, Filename: filename})
+			}
+			return finishBatch(items, claimErr)
+		}
+		if opts.AfterClaim != nil {
+			if err := opts.AfterClaim(filename); err != nil {
+				items = append(items, failedClaimedItem(filename, "processing_error", err))
+				if claimCommitted {
+					return finishBatch(items, errors.Join(claimErr, err))
+				}
+				return finishBatch(items, err)
+			}
+		}
+
+		item, err := ReadItem(
+			root,
+			filepath.Join("agents", me, "inbox", "cur", filename),
+			filename,
+			opts.IncludeBody,
+			opts.Validator,
+		)
+		if err != nil {
+			// The claim already committed. Preserve that outcome in the batch
+			// instead of returning an error that would hide this and all earlier
+			// claimed messages from the caller. The existing parse-failure path
+			// attempts DLQ placement and reports the retained claim if that
+			// placement cannot read the artifact either.
+			item.ParseError = err.Error()
+			item.FailureReason = "parse_error"
+		}
+
+		// Move parse errors to DLQ instead of cur
+		if item.ParseError != "" {
+			reason := item.FailureReason
+			if reason == "" {
+				reason = "parse_error"
+			}
+			var dlqPath string
+			var dlqErr error
+			if claimCommitted {
+				dlqPath, dlqErr = opts.MoveClaimedToDLQ(root, me, item.Filename, item.ID, reason, item.ParseError, claimErr)
+			} else {
+				dlqPath, dlqErr = opts.MoveToDLQ(root, me, item.Filename, item.ID, reason, item.ParseError)
+			}
+			if dlqErr == nil {
+				item.MovedToDLQ = true
+				EmitReceipt(root, me, &item, receipt.StageDLQ, item.ParseError, opts.Warn)
+				items = append(items, item)
+				continue
+			}
+			var partial *fsq.DLQTransitionError
+			var committed *fsq.CommittedDurabilityError
+			switch {
+			case errors.As(dlqErr, &partial):
+				// The DLQ envelope is visible but the claimed source remains
+				// in cur. Surface the item before returning a typed error so
+				// callers cannot mistake the duplicate state for completion.
+				items = append(items, item)
+				return finishBatch(items, dlqErr)
+			case dlqPath != "" && errors.As(dlqErr, &committed):
+				// The source transition completed, but its durability is
+				// indeterminate. Report the completed logical outcome and
+				// still fail the command so operators do not retry blindly.
+				item.MovedToDLQ = true
+				EmitReceipt(root, me, &item, receipt.StageDLQ, item.ParseError, opts.Warn)
+				items = append(items, item)
+				return finishBatch(items, dlqErr)
+			default:
+				// No DLQ envelope is visible and the claimed source remains
+				// in cur. Preserve the item, but fail the batch instead of
+				// reducing a transport failure to a warning-only success.
+				items = append(items, item)
+				return finishBatch(items, dlqErr)
+			}
+		}
+
+		item.MovedToCur = true
+		EmitReceipt(root, me, &item, receipt.StageDrained, "", opts.Warn)
+		items = append(items, item)
+		if claimCommitted {
+			return finishBatch(items, claimErr)
+		}
+	}
+
+	return finishBatch(items, nil)
+}
+
+func (o *DrainOptions) defaults() {
+	if o.Validator == nil {
+		o.Validator = &HeaderValidator{}
+	}
+	if o.Claim == nil {
+		o.Claim = fsq.MoveNewToCur
+	}
+	if o.MoveToDLQ == nil {
+		o.MoveToDLQ = fsq.MoveCurToDLQ
+	}
+	if o.MoveClaimedToDLQ == nil {
+		o.MoveClaimedToDLQ = fsq.MoveClaimedCurToDLQ
+	}
+}
+
+func finishBatch(items []Item, err error) ([]Item, error) {
+	format.SortByTimestamp(items)
+	return items, err
+}
+
+func failedClaimedItem(filename, reason string, err error) Item {
+	return Item{
+		ID:            strings.TrimSuffix(filename, ".md"),
+		Filename:      filename,
+		ParseError:    err.Error(),
+		FailureReason: reason,
+	}
+}
+
+func inboxDirsExist(root *fsq.DeliveryRoot, me string) (bool, error) {
+	for _, dir := range []string{filepath.Join("agents", me, "inbox", "new"), filepath.Join("agents", me, "inbox", "cur")} {
+		info, err := root.Stat(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		if !info.IsDir() {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// EmitReceipt records stage for item on behalf of consumer. Receipt failures
+// never fail the drain; they go to warn when it is set.
+func EmitReceipt(root *fsq.DeliveryRoot, consumer string, item *Item, stage, detail string, warn func(string, ...any)) {
+	if warn == nil {
+		warn = func(string, ...any) {}
+	}
+	sender := ""
+	if strings.TrimSpace(item.From) != "" {
+		var err error
+		sender, err = normalizeSender(item.From)
+		if err != nil {
+			sender = ""
+			warn("receipt sender normalization failed for %q: %v", item.From, err)
+		}
+	} else if stage == receipt.StageDLQ {
+		if strings.TrimSpace(detail) == "" {
+			detail = ReceiptSenderUnavailableDetail
+		} else {
+			detail += "; " + ReceiptSenderUnavailableDetail
+		}
+	}
+
+	r := receipt.New(item.ID, item.Thread, sender, consumer, stage, detail)
+	if err := receipt.EmitDeliveryRoot(root, r); err != nil {
+		warn("failed to emit %s receipt for %s: %v", stage, item.ID, err)
+	}
+}
+
+func normalizeSender(raw string) (string, error) {
+	handle := strings.TrimSpace(raw)
+	if strings.ContainsAny(handle, "/\\") {
+		return "", fmt.Errorf("invalid handle (slashes not allowed): %s", handle)
+	}
+	if err := fsq.ValidateHandle(handle); err != nil {
+		return "", err
+	}
+	return handle, nil
+}
+
+// Filenames lists the message files in me's inbox/new, sorted. Dotfiles and
+// non-.md entries are skipped.
+func Filenames(root *fsq.DeliveryRoot, me string) ([]string, error) {
+	entries, err := root.ReadDir(filepath.Join("agents", me, "inbox", "new"))
+	if err != nil {
+		return nil, err
+	}
+
+	filenames := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		filename := entry.Name()
+		// Skip dotfiles (like .DS_Store) and non-.md files
+		if strings.HasPrefix(filename, ".") || !strings.HasSuffix(filename, ".md") {
+			continue
+		}
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	return filenames, nil
+}
+
+// ReadItem parses the message at path. Parse and validation failures are
+// reported in the item rather than as an error; the error is reserved for
+// filesystem failures, including the message having vanished.
+func ReadItem(
+	root *fsq.DeliveryRoot,
+	path, filename string,
+	includeBody bool,
+	validator *HeaderValidator,
+) (Item, error) {
+	if validator == nil {
+		validator = &HeaderValidator{}
+	}
+	baseID := strings.TrimSuffix(filename, ".md")
+	item := Item{
+		ID:       baseID, // fallback to filename base if parse fails
+		Filename: filename,
+	}
+
+	// Try to parse the message
+	var header format.Header
+	var body string
+	var parseErr error
+
+	if includeBody {
+		info, err := root.Stat(path)
+		if err != nil {
+			return item, err
+		}
+		if info.Size() > format.MaxMessageSize {
+			parseErr = fmt.Errorf("%w: %d bytes", format.ErrMessageTooLarge, info.Size())
+		} else if data, err := root.ReadRegularNoFollow(path); err != nil {
+			return item, err
+		} else if len(data) > format.MaxMessageSize {
+			parseErr = fmt.Errorf("%w: %d bytes", format.ErrMessageTooLarge, len(data))
+		} else if msg, err := format.ParseMessage(data); err != nil {
+			parseErr = err
+		} else {
+			header = msg.Header
+			body = msg.Body
+		}
+	} else {
+		file, _, err := root.OpenRegularNoFollow(path)
+		if err != nil {
+			return item, err
+		}
+		header, parseErr = format.ReadHeader(file)
+		if err := file.Close(); err != nil && parseErr == nil {
+			return item, err
+		}
+	}
+
+	if parseErr != nil {
+		item.ParseError = parseErr.Error()
+		item.FailureReason = "parse_error"
+		// Still move corrupt message to DLQ to avoid reprocessing.
+	} else if err := validator.Validate(header); err != nil {
+		item.From = header.From
+		item.Thread = header.Thread
+		item.ParseError = "invalid header: " + err.Error()
+		item.FailureReason = "invalid_header"
+		if safeID, ok := SafeHeaderID(header.ID); ok {
+			item.ID = safeID
+		}
+	} else {
+		item.ID = header.ID
+		item.From = header.From
+		item.To = header.To
+		item.Thread = header.Thread
+		item.Subject = header.Subject
+		item.Created = header.Created
+		item.Priority = header.Priority
+		item.Kind = header.Kind
+		item.Labels = header.Labels
+		item.Context = header.Context
+		item.FromProject = header.FromProject
+		item.ReplyProject = header.ReplyProject
+		item.Refs = header.Refs
+		item.ReplyTo = header.ReplyTo
+		if includeBody {
+			item.Body = body
+		}
+		if ts, err := time.Parse(time.RFC3339Nano, header.Created); err == nil {
+			item.SortKey = ts
+		}
+	}
+
+	return item, nil
+}
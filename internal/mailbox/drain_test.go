@@ -0,0 +1,109 @@
+package mailbox
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/format"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+)
+
+func openRoot(t *testing.T, base string) *fsq.DeliveryRoot {
+	t.Helper()
+	identity, err := fsq.SnapshotDeliveryRoot(base)
+	if err != nil {
+		t.Fatalf("SnapshotDeliveryRoot: %v", err)
+	}
+	root, err := fsq.OpenDeliveryRoot(base, identity)
+	if err != nil {
+		t.Fatalf("OpenDeliveryRoot: %v", err)
+	}
+	t.Cleanup(func() { _ = root.Close() })
+	return root
+}
+
+func writeInbox(t *testing.T, base, agent, filename string, header format.Header) {
+	t.Helper()
+	data, err := format.Message{Header: header, Body: "body"}.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(fsq.AgentInboxNew(base, agent), filename), data, 0o600); err != nil {
+		t.Fatalf("write %s: %v", filename, err)
+	}
+}
+
+func TestDrainClaimsByFilenameNotHeaderID(t *testing.T) {
+	base := t.TempDir()
+	if err := fsq.EnsureAgentDirs(base, "alice"); err != nil {
+		t.Fatalf("EnsureAgentDirs: %v", err)
+	}
+	// Bridged mail lands under its transfer name, not its message ID.
+	writeInbox(t, base, "alice", "xfer-hostb-t1.md", format.Header{
+		Schema:  format.CurrentSchema,
+		ID:      "2026-10-19T10-00-00.000Z_pid1_abcd",
+		From:    "bob",
+		To:      []string{"alice"},
+		Thread:  "p2p/alice__bob",
+		Created: time.Now().UTC().Format(time.RFC3339Nano),
+	})
+
+	items, err := Drain(openRoot(t, base), "alice", DrainOptions{IncludeBody: true})
+	if err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	if len(items) != 1 || !items[0].MovedToCur || items[0].Filename != "xfer-hostb-t1.md" {
+		t.Fatalf("items = %+v, want one claimed xfer item", items)
+	}
+	if items[0].ID != "2026-10-19T10-00-00.000Z_pid1_abcd" || items[0].Body != "body\n" {
+		t.Fatalf("item = %+v, want header ID and body", items[0])
+	}
+	if _, err := os.Stat(filepath.Join(fsq.AgentInboxCur(base, "alice"), "xfer-hostb-t1.md")); err != nil {
+		t.Fatalf("claimed file not in cur: %v", err)
+	}
+}
+
+func TestDrainLimitLeavesUnclaimedFailuresInNew(t *testing.T) {
+	base := t.TempDir()
+	if err := fsq.EnsureAgentDirs(base, "alice"); err != nil {
+		t.Fatalf("EnsureAgentDirs: %v", err)
+	}
+	writeInbox(t, base, "alice", "a.md", format.Header{
+		Schema: format.CurrentSchema, ID: "a", From: "bob", To: []string{"alice"},
+		Thread: "p2p/alice__bob", Created: time.Now().UTC().Format(time.RFC3339Nano),
+	})
+	if err := os.WriteFile(filepath.Join(fsq.AgentInboxNew(base, "alice"), "b.md"), []byte("not a message"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := Drain(openRoot(t, base), "alice", DrainOptions{Limit: 1})
+	if err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	if len(items) != 1 || items[0].ID != "a" {
+		t.Fatalf("items = %+v, want only a", items)
+	}
+	if _, err := os.Stat(filepath.Join(fsq.AgentInboxNew(base, "alice"), "b.md")); err != nil {
+		t.Fatalf("corrupt message beyond the limit must stay in new: %v", err)
+	}
+}
+
+func TestInboxDirsExistRejectsMissingCur(t *testing.T) {
+	base := t.TempDir()
+	if err := fsq.EnsureAgentDirs(base, "alice"); err != nil {
+		t.Fatalf("EnsureAgentDirs: %v", err)
+	}
+	if err := os.RemoveAll(fsq.AgentInboxCur(base, "alice")); err != nil {
+		t.Fatalf("remove inbox/cur: %v", err)
+	}
+
+	exists, err := inboxDirsExist(openRoot(t, base), "alice")
+	if err != nil {
+		t.Fatalf("inboxDirsExist: %v", err)
+	}
+	if exists {
+		t.Fatal("missing inbox/cur must not be classified as a concurrent message claim")
+	}
+}
@@ -0,0 +1,42 @@
+package mailbox
+
+import "time"
+
+// Item is one inbox message as seen by a drain or a peek. A message that
+// failed parsing or validation carries ParseError and FailureReason and only
+// the header fields that could be trusted.
+type Item struct {
+	ID            string         `json:"id"`
+	From          string         `json:"from"`
+	To            []string       `json:"to"`
+	Thread        string         `json:"thread"`
+	Subject       string         `json:"subject"`
+	Created       string         `json:"created"`
+	Body          string         `json:"body,omitempty"`
+	Priority      string         `json:"priority,omitempty"`
+	Kind          string         `json:"kind,omitempty"`
+	Labels        []string       `json:"labels,omitempty"`
+	Context       map[string]any `json:"context,omitempty"`
+	FromProject   string         `json:"from_project,omitempty"`
+	ReplyProject  string         `json:"reply_project,omitempty"`
+	MovedToCur    bool           `json:"moved_to_cur"`
+	MovedToDLQ    bool           `json:"moved_to_dlq,omitempty"`
+	ParseError    string         `json:"parse_error,omitempty"`
+	Refs          []string       `json:"-"`
+	ReplyTo       string         `json:"-"`
+	FailureReason string         `json:"-"`
+	Filename      string         `json:"-"` // actual filename on disk
+	SortKey       time.Time      `json:"-"`
+}
+
+func (i Item) GetCreated() string {
+	return i.Created
+}
+
+func (i Item) GetID() string {
+	return i.ID
+}
+
+func (i Item) GetRawTime() time.Time {
+	return i.SortKey
+}
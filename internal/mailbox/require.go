@@ -0,0 +1,46 @@
+package mailbox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+)
+
+// MissingError reports that a handle's mailbox is absent or malformed. Path
+// is the first offending directory, relative to the root.
+type MissingError struct {
+	Handle string
+	Path   string
+	NotDir bool
+}
+
+func (e *MissingError) Error() string {
+	if e.NotDir {
+		return fmt.Sprintf("mailbox path for %q is not a directory: %s", e.Handle, e.Path)
+	}
+	return fmt.Sprintf("mailbox for %q is missing (missing %s)", e.Handle, e.Path)
+}
+
+// Require checks that handle has a complete mailbox in root: inbox/new,
+// inbox/cur, and dlq/new must all be directories.
+func Require(root *fsq.DeliveryRoot, handle string) error {
+	for _, dir := range []string{
+		filepath.Join("agents", handle, "inbox", "new"),
+		filepath.Join("agents", handle, "inbox", "cur"),
+		filepath.Join("agents", handle, "dlq", "new"),
+	} {
+		info, err := root.Stat(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return &MissingError{Handle: handle, Path: dir}
+			}
+			return err
+		}
+		if !info.IsDir() {
+			return &MissingError{Handle: handle, Path: dir, NotDir: true}
+		}
+	}
+	return nil
+}
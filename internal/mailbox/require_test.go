@@ -0,0 +1,61 @@
+package mailbox
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+)
+
+func TestRequireReportsFirstMissingMailboxDir(t *testing.T) {
+	base := t.TempDir()
+	if err := fsq.EnsureAgentDirs(base, "alice"); err != nil {
+		t.Fatalf("EnsureAgentDirs: %v", err)
+	}
+	root := openRoot(t, base)
+	if err := Require(root, "alice"); err != nil {
+		t.Fatalf("Require(alice): %v", err)
+	}
+
+	var missing *MissingError
+	if err := Require(root, "bob"); !errors.As(err, &missing) || missing.NotDir {
+		t.Fatalf("Require(bob) = %v, want missing mailbox", err)
+	}
+	if want := filepath.Join("agents", "bob", "inbox", "new"); missing.Path != want {
+		t.Fatalf("missing path = %q, want %q", missing.Path, want)
+	}
+
+	dlq := filepath.Join(base, "agents", "alice", "dlq", "new")
+	if err := os.RemoveAll(dlq); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dlq, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Require(root, "alice"); !errors.As(err, &missing) || !missing.NotDir {
+		t.Fatalf("Require(alice) with file dlq/new = %v, want not-a-directory", err)
+	}
+}
+
+func TestValidateKnownStrictAndWarn(t *testing.T) {
+	agents := WithReservedHumanHandle([]string{"alice"})
+	if err := ValidateKnown(agents, nil, true, nil, "alice", "user"); err != nil {
+		t.Fatalf("known handles: %v", err)
+	}
+	if err := ValidateKnown(nil, nil, true, nil, "anyone"); err != nil {
+		t.Fatalf("no roster: %v", err)
+	}
+	if err := ValidateKnown(agents, nil, true, nil, "mallory"); err == nil {
+		t.Fatal("strict unknown handle accepted")
+	}
+	var warned []string
+	warn := func(msg string) { warned = append(warned, msg) }
+	if err := ValidateKnown(agents, nil, false, warn, "mallory", "eve"); err != nil {
+		t.Fatalf("non-strict unknown handles: %v", err)
+	}
+	if len(warned) != 1 {
+		t.Fatalf("warnings = %v, want one", warned)
+	}
+}
@@ -0,0 +1,112 @@
+package mailbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/avivsinai/agent-message-queue/internal/config"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+)
+
+// KnownAgents loads the agent roster from meta/config.json in root, plus the
+// reserved human handle. It returns nil when the config doesn't exist.
+// If strict, an unreadable or corrupt config is an error; otherwise it is
+// passed to warn (which may be nil) and roster validation is skipped.
+func KnownAgents(root *fsq.DeliveryRoot, strict bool, warn func(string)) ([]string, error) {
+	return LoadKnownAgents(strict, warn, func() ([]byte, error) {
+		return root.ReadRegularNoFollow(filepath.Join("meta", "config.json"))
+	})
+}
+
+// LoadKnownAgents is KnownAgents over an arbitrary config.json reader.
+func LoadKnownAgents(strict bool, warn func(string), read func() ([]byte, error)) ([]string, error) {
+	data, err := read()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil // No config, no validation
+		}
+		// Config exists but unreadable
+		return nil, rosterProblem(strict, warn, fmt.Sprintf("cannot read config.json: %v", err))
+	}
+
+	var cfg struct {
+		Agents []string `json:"agents"`
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		// Config exists but invalid JSON
+		return nil, rosterProblem(strict, warn, fmt.Sprintf("invalid config.json: %v", err))
+	}
+
+	return WithReservedHumanHandle(cfg.Agents), nil
+}
+
+func rosterProblem(strict bool, warn func(string), msg string) error {
+	if strict {
+		return errors.New(msg)
+	}
+	if warn != nil {
+		warn(msg)
+	}
+	return nil
+}
+
+// WithReservedHumanHandle returns agents with the reserved human handle
+// appended when it is not already present.
+func WithReservedHumanHandle(agents []string) []string {
+	for _, agent := range agents {
+		if agent == config.ReservedHumanHandle {
+			return agents
+		}
+	}
+	out := make([]string, 0, len(agents)+1)
+	out = append(out, agents...)
+	out = append(out, config.ReservedHumanHandle)
+	return out
+}
+
+// KnownSet indexes a roster; it returns nil for an empty one.
+func KnownSet(agents []string) map[string]struct{} {
+	if len(agents) == 0 {
+		return nil
+	}
+	known := make(map[string]struct{}, len(agents))
+	for _, agent := range agents {
+		known[agent] = struct{}{}
+	}
+	return known
+}
+
+// ValidateKnown checks handles against a roster loaded by KnownAgents; loadErr
+// is that load's error and a nil roster disables the check. Unknown handles
+// are an error if strict; otherwise they are passed to warn (which may be nil).
+func ValidateKnown(agents []string, loadErr error, strict bool, warn func(string), handles ...string) error {
+	if loadErr != nil {
+		return loadErr
+	}
+	if agents == nil {
+		return nil // No config, no validation
+	}
+
+	known := KnownSet(agents)
+	var unknown []string
+	for _, h := range handles {
+		if _, ok := known[h]; !ok {
+			unknown = append(unknown, h)
+		}
+	}
+
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	var msg string
+	if len(unknown) == 1 {
+		msg = fmt.Sprintf("handle %q not in config.json agents %v", unknown[0], agents)
+	} else {
+		msg = fmt.Sprintf("unknown handles %v (known: %v)", unknown, agents)
+	}
+	return rosterProblem(strict, warn, msg)
+}
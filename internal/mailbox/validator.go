@@ -0,0 +1,81 @@
+package mailbox
+
+import (
+	"fmt"
+
+	"github.com/avivsinai/agent-message-queue/internal/format"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+)
+
+// HeaderValidator applies the header rules every consumer enforces. Strict
+// validators also check the schema version and, when Known is set, that the
+// sender and recipients are on the roster. The zero value checks fields only.
+type HeaderValidator struct {
+	Strict bool
+	Known  map[string]struct{}
+	// AllowLegacyFlagHandles accepts handles that predate the current handle
+	// charset, for read-only inspection of old mail.
+	AllowLegacyFlagHandles bool
+}
+
+// NewHeaderValidator builds a validator for root. Strict validators load the
+// roster, failing on an unreadable or corrupt config.
+func NewHeaderValidator(root *fsq.DeliveryRoot, strict bool) (*HeaderValidator, error) {
+	if !strict {
+		return &HeaderValidator{}, nil
+	}
+	agents, err := KnownAgents(root, strict, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &HeaderValidator{Strict: true, Known: KnownSet(agents)}, nil
+}
+
+// Validate checks header against the validator's rules.
+func (v *HeaderValidator) Validate(header format.Header) error {
+	if err := v.validateBasic(header); err != nil {
+		return err
+	}
+	if len(v.Known) == 0 {
+		return nil
+	}
+	if _, ok := v.Known[header.From]; !ok {
+		return fmt.Errorf("unknown sender handle: %s", header.From)
+	}
+	for _, recipient := range header.To {
+		if _, ok := v.Known[recipient]; !ok {
+			return fmt.Errorf("unknown recipient handle: %s", recipient)
+		}
+	}
+	return nil
+}
+
+func (v *HeaderValidator) validateBasic(header format.Header) error {
+	// Schema check only in strict mode - allows interop with older/newer clients
+	if v.Strict && header.Schema != format.CurrentSchema {
+		return fmt.Errorf("unsupported schema: %d (expected %d)", header.Schema, format.CurrentSchema)
+	}
+	if v.AllowLegacyFlagHandles {
+		return format.ValidateHeaderWith(header, validateLegacyInspectionHandle)
+	}
+	return format.ValidateHeader(header)
+}
+
+func validateLegacyInspectionHandle(label, handle string) error {
+	if err := format.ValidateHandle(label, handle); err == nil {
+		return nil
+	}
+	if err := fsq.ValidateLegacyHandleForInspection(handle); err != nil {
+		return fmt.Errorf("invalid %s handle: %w", label, err)
+	}
+	return nil
+}
+
+// SafeHeaderID returns id when it is safe to use as a base filename, so a
+// failure report can name the message without trusting a hostile header.
+func SafeHeaderID(id string) (string, bool) {
+	if _, err := format.SafeBaseName(id); err != nil {
+		return "", false
+	}
+	return id, true
+}
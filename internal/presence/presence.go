@@ -4,11 +4,23 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/avivsinai/agent-message-queue/internal/config"
 	"github.com/avivsinai/agent-message-queue/internal/fsq"
 )
 
+// Derived statuses, from most to least reachable. Active and idle agents are
+// alive; away, stale, and offline agents are not.
+const (
+	StatusActive  = "active"
+	StatusIdle    = "idle"
+	StatusAway    = "away"
+	StatusStale   = "stale"
+	StatusOffline = "offline"
+)
+
 // Presence captures the current presence for an agent handle.
 type Presence struct {
 	Schema           int    `json:"schema"`
@@ -21,6 +33,101 @@ type Presence struct {
 	NotifierReason   string `json:"notifier_reason,omitempty"`
 	DoorbellParked   bool   `json:"doorbell_parked,omitempty"`
 	DoorbellAttempts uint   `json:"doorbell_attempts,omitempty"`
+	// Capabilities are free-form tags (for example "go" or "can-run-tests")
+	// that capability routing matches against.
+	Capabilities []string `json:"capabilities,omitempty"`
+	// Delegate receives this agent's new mail while it is away, until
+	// DelegateUntil (RFC 3339) passes or the agent comes back.
+	Delegate      string `json:"delegate,omitempty"`
+	DelegateUntil string `json:"delegate_until,omitempty"`
+	DelegateMode  string `json:"delegate_mode,omitempty"`
+	// DelegateSince (RFC 3339) is when the delegation was set. It tells one
+	// away period from the next.
+	DelegateSince string `json:"delegate_since,omitempty"`
+}
+
+// Delegation modes: copy leaves the original in the away agent's inbox; move
+// delivers only to the delegate.
+const (
+	DelegateCopy = "copy"
+	DelegateMove = "move"
+)
+
+// ActiveDelegate returns the delegate in effect at now, or "" when none is.
+func (p Presence) ActiveDelegate(now time.Time) string {
+	if p.Delegate == "" {
+		return ""
+	}
+	if p.DelegateUntil != "" {
+		until, err := time.Parse(time.RFC3339Nano, p.DelegateUntil)
+		if err != nil || !now.Before(until) {
+			return ""
+		}
+	}
+	return p.Delegate
+}
+
+// ClearDelegate ends a delegation. An agent that was away is active again.
+func (p *Presence) ClearDelegate() {
+	if p.Delegate == "" {
+		return
+	}
+	p.Delegate = ""
+	p.DelegateUntil = ""
+	p.DelegateMode = ""
+	p.DelegateSince = ""
+	if strings.EqualFold(p.Status, StatusAway) {
+		p.Status = StatusActive
+	}
+}
+
+// HasCapability reports whether p advertises capability, ignoring case.
+func (p Presence) HasCapability(capability string) bool {
+	for _, c := range p.Capabilities {
+		if strings.EqualFold(c, capability) {
+			return true
+		}
+	}
+	return false
+}
+
+// LastSeenTime parses LastSeen; ok is false when it is missing or malformed.
+func (p Presence) LastSeenTime() (time.Time, bool) {
+	t, err := time.Parse(time.RFC3339Nano, p.LastSeen)
+	return t, err == nil
+}
+
+// Derive computes an agent's status from the age of its presence. A nil p
+// means the agent has no presence file. A verified live notifier keeps the
+// agent active however old its presence is, because prompts still reach it.
+func Derive(p *Presence, notifierLive bool, now time.Time, t config.PresenceThresholds) string {
+	if notifierLive {
+		return StatusActive
+	}
+	if p == nil {
+		return StatusOffline
+	}
+	seen, ok := p.LastSeenTime()
+	if !ok {
+		return StatusOffline
+	}
+	switch age := now.Sub(seen); {
+	case age < t.Idle:
+		return StatusActive
+	case age < t.Away:
+		return StatusIdle
+	case age < t.Stale:
+		return StatusAway
+	case age < t.Offline:
+		return StatusStale
+	default:
+		return StatusOffline
+	}
+}
+
+// IsAlive reports whether a derived status counts as alive.
+func IsAlive(status string) bool {
+	return status == StatusActive || status == StatusIdle
 }
 
 func New(handle, status, note string, now time.Time) Presence {
@@ -93,8 +200,20 @@ func SetNotifierStatus(root, handle, status, mode, reason string) error {
 	return Write(root, p)
 }
 
-// TouchDeliveryRoot updates presence through a pinned root capability.
+// TouchDeliveryRoot updates last_seen through a pinned root capability. It
+// leaves status and any delegation alone.
 func TouchDeliveryRoot(root *fsq.DeliveryRoot, handle string) error {
+	return touchDeliveryRoot(root, handle, false)
+}
+
+// ReturnDeliveryRoot is TouchDeliveryRoot for an agent that is back: it also
+// ends any delegation. Commands the agent runs itself to work its own mail
+// (send, reply, drain, claim) call it.
+func ReturnDeliveryRoot(root *fsq.DeliveryRoot, handle string) error {
+	return touchDeliveryRoot(root, handle, true)
+}
+
+func touchDeliveryRoot(root *fsq.DeliveryRoot, handle string, back bool) error {
 	path := filepath.Join("agents", handle, "presence.json")
 	data, err := root.ReadFile(path)
 	var p Presence
@@ -108,10 +227,26 @@ func TouchDeliveryRoot(root *fsq.DeliveryRoot, handle string) error {
 			return err
 		}
 		p.LastSeen = time.Now().UTC().Format(time.RFC3339Nano)
+		if back {
+			p.ClearDelegate()
+		}
 	}
 	return WriteDeliveryRoot(root, p)
 }
 
+// ReadDeliveryRoot reads presence through a pinned root capability.
+func ReadDeliveryRoot(root *fsq.DeliveryRoot, handle string) (Presence, error) {
+	data, err := root.ReadFile(filepath.Join("agents", handle, "presence.json"))
+	if err != nil {
+		return Presence{}, err
+	}
+	var p Presence
+	if err := json.Unmarshal(data, &p); err != nil {
+		return Presence{}, err
+	}
+	return p, nil
+}
+
 func Read(root, handle string) (Presence, error) {
 	path := filepath.Join(root, "agents", handle, "presence.json")
 	data, err := os.ReadFile(path)
@@ -4,6 +4,9 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/config"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
 )
 
 func TestPresenceWriteRead(t *testing.T) {
@@ -96,3 +99,91 @@ func TestSetNotifierStatusPreservesAgentPresenceAndTouchPreservesNotifierStatus(
 		t.Fatalf("notifier status was not preserved: %#v", got)
 	}
 }
+
+func TestDeriveFollowsThresholdsAndNotifier(t *testing.T) {
+	thresholds, err := (&config.PresenceConfig{IdleAfter: "1m", AwayAfter: "5m"}).Thresholds()
+	if err != nil {
+		t.Fatalf("Thresholds: %v", err)
+	}
+	if thresholds.Stale != config.DefaultPresenceStaleAfter || thresholds.Offline != config.DefaultPresenceOfflineAfter {
+		t.Fatalf("unset thresholds = %+v, want defaults", thresholds)
+	}
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		age  time.Duration
+		want string
+	}{
+		{0, StatusActive},
+		{2 * time.Minute, StatusIdle},
+		{30 * time.Minute, StatusAway},
+		{3 * time.Hour, StatusStale},
+		{48 * time.Hour, StatusOffline},
+	} {
+		p := New("codex", "busy", "", now.Add(-tc.age))
+		if got := Derive(&p, false, now, thresholds); got != tc.want {
+			t.Errorf("age %s: Derive = %q, want %q", tc.age, got, tc.want)
+		}
+	}
+	old := New("codex", "busy", "", now.Add(-48*time.Hour))
+	if got := Derive(&old, true, now, thresholds); got != StatusActive {
+		t.Fatalf("live notifier Derive = %q, want active", got)
+	}
+	if got := Derive(nil, false, now, thresholds); got != StatusOffline {
+		t.Fatalf("missing presence Derive = %q, want offline", got)
+	}
+	if !IsAlive(StatusIdle) || IsAlive(StatusAway) {
+		t.Fatal("IsAlive must accept idle and reject away")
+	}
+
+	if _, err := (&config.PresenceConfig{IdleAfter: "20m"}).Thresholds(); err == nil {
+		t.Fatal("idle_after beyond the default away_after must be rejected")
+	}
+}
+
+func TestActiveDelegateExpiresAndClears(t *testing.T) {
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	p := New("codex", StatusAway, "", now)
+	p.Delegate = "grok"
+	if got := p.ActiveDelegate(now); got != "grok" {
+		t.Fatalf("open-ended ActiveDelegate = %q, want grok", got)
+	}
+	p.DelegateUntil = now.Add(time.Hour).Format(time.RFC3339Nano)
+	if got := p.ActiveDelegate(now.Add(2 * time.Hour)); got != "" {
+		t.Fatalf("expired ActiveDelegate = %q, want none", got)
+	}
+	p.ClearDelegate()
+	if p.Delegate != "" || p.DelegateUntil != "" || p.Status != StatusActive {
+		t.Fatalf("after ClearDelegate = %+v, want active without delegate", p)
+	}
+}
+
+func TestTouchDeliveryRootKeepsDelegationUntilReturn(t *testing.T) {
+	base := t.TempDir()
+	away := New("codex", StatusAway, "", time.Now())
+	away.Delegate = "grok"
+	if err := Write(base, away); err != nil {
+		t.Fatal(err)
+	}
+	identity, err := fsq.SnapshotDeliveryRoot(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := fsq.OpenDeliveryRoot(base, identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = root.Close() }()
+
+	if err := TouchDeliveryRoot(root, "codex"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Read(base, "codex"); err != nil || got.Delegate != "grok" || got.Status != StatusAway {
+		t.Fatalf("after touch = %+v (%v), want the delegation kept", got, err)
+	}
+	if err := ReturnDeliveryRoot(root, "codex"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Read(base, "codex"); err != nil || got.Delegate != "" || got.Status != StatusActive {
+		t.Fatalf("after return = %+v (%v), want active without delegate", got, err)
+	}
+}
@@ -0,0 +1,5 @@
+// Package quota enforces the per-agent mailbox limits configured under
+// "quotas" in meta/config.json: undrained message count, undrained bytes,
+// and a per-sender delivery rate. Checks are advisory admission control
+// performed before delivery; concurrent senders may briefly overshoot.
+package quota
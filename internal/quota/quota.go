@@ -0,0 +1,246 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/config"
+	"github.com/avivsinai/agent-message-queue/internal/format"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+)
+
+// Limit names reported in violations.
+const (
+	LimitUndrained  = "max_undrained"
+	LimitBytes      = "max_bytes"
+	LimitSenderRate = "sender_rate"
+)
+
+// On-full policies for a delivery that would exceed a recipient quota.
+const (
+	OnFullFail    = "fail"
+	OnFullWait    = "wait"
+	OnFullDropLow = "drop-low"
+)
+
+// PollInterval paces the wait policy in Admit.
+var PollInterval = 500 * time.Millisecond
+
+// Usage is the undrained state of one mailbox.
+type Usage struct {
+	Undrained int   `json:"undrained"`
+	Bytes     int64 `json:"bytes"`
+}
+
+// Violation describes one limit a delivery would exceed.
+type Violation struct {
+	Agent   string `json:"agent"`
+	Limit   string `json:"limit"`
+	Current int64  `json:"current"`
+	Max     int64  `json:"max"`
+}
+
+// ExceededError reports that a delivery would exceed one or more quotas.
+type ExceededError struct {
+	Violations []Violation
+}
+
+func (e *ExceededError) Error() string {
+	parts := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		parts = append(parts, fmt.Sprintf("%s %s %d/%d", v.Agent, v.Limit, v.Current, v.Max))
+	}
+	return "mailbox quota exceeded: " + strings.Join(parts, ", ")
+}
+
+// Load reads the quota configuration from root. A missing config file or
+// quotas section yields nil, which disables enforcement.
+func Load(root *fsq.DeliveryRoot) (*config.QuotaConfig, error) {
+	cfg, err := config.ReadConfig(root)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read quota config: %w", err)
+	}
+	return cfg.Quotas, nil
+}
+
+// Measure returns the undrained usage of agent's inbox.
+func Measure(root *fsq.DeliveryRoot, agent string) (Usage, error) {
+	var usage Usage
+	entries, err := root.ReadDir(fsq.AgentInboxNew("", agent))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return usage, nil
+		}
+		return usage, err
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return usage, err
+		}
+		usage.Undrained++
+		usage.Bytes += info.Size()
+	}
+	return usage, nil
+}
+
+// SenderCount counts messages from sender delivered to agent's inbox (new
+// and cur) since the given time, using file modification times.
+func SenderCount(root *fsq.DeliveryRoot, agent, sender string, since time.Time) (int, error) {
+	count := 0
+	for _, dir := range []string{fsq.AgentInboxNew("", agent), fsq.AgentInboxCur("", agent)} {
+		entries, err := root.ReadDir(dir)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return 0, err
+		}
+		for _, entry := range entries {
+			if !entry.Type().IsRegular() || !strings.HasSuffix(entry.Name(), ".md") {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil || info.ModTime().Before(since) {
+				continue
+			}
+			if messageSender(root, filepath.Join(dir, entry.Name())) == sender {
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+func messageSender(root *fsq.DeliveryRoot, rel string) string {
+	file, _, err := root.OpenRegularNoFollow(rel)
+	if err != nil {
+		return ""
+	}
+	defer func() { _ = file.Close() }()
+	header, err := format.ReadHeader(file)
+	if err != nil {
+		return ""
+	}
+	return header.From
+}
+
+// Check verifies that delivering one message of size bytes from sender to
+// each recipient stays within the configured limits. It returns an
+// *ExceededError listing every violated limit, or nil.
+func Check(root *fsq.DeliveryRoot, cfg *config.QuotaConfig, sender string, recipients []string, size int64, now time.Time) error {
+	if cfg == nil {
+		return nil
+	}
+	var violations []Violation
+	for _, recipient := range recipients {
+		limits := cfg.For(recipient)
+		if !limits.Enabled() {
+			continue
+		}
+		found, err := checkRecipient(root, limits, sender, recipient, size, now)
+		if err != nil {
+			return err
+		}
+		violations = append(violations, found...)
+	}
+	if len(violations) > 0 {
+		return &ExceededError{Violations: violations}
+	}
+	return nil
+}
+
+func checkRecipient(root *fsq.DeliveryRoot, limits config.QuotaLimits, sender, recipient string, size int64, now time.Time) ([]Violation, error) {
+	var violations []Violation
+	if limits.MaxUndrained > 0 || limits.MaxBytes > 0 {
+		usage, err := Measure(root, recipient)
+		if err != nil {
+			return nil, fmt.Errorf("measure %s mailbox: %w", recipient, err)
+		}
+		if limits.MaxUndrained > 0 && usage.Undrained+1 > limits.MaxUndrained {
+			violations = append(violations, Violation{
+				Agent: recipient, Limit: LimitUndrained,
+				Current: int64(usage.Undrained), Max: int64(limits.MaxUndrained),
+			})
+		}
+		if limits.MaxBytes > 0 && usage.Bytes+size > limits.MaxBytes {
+			violations = append(violations, Violation{
+				Agent: recipient, Limit: LimitBytes,
+				Current: usage.Bytes, Max: limits.MaxBytes,
+			})
+		}
+	}
+	if limits.SenderRate > 0 && sender != "" {
+		window, err := limits.Window()
+		if err != nil {
+			return nil, err
+		}
+		count, err := SenderCount(root, recipient, sender, now.Add(-window))
+		if err != nil {
+			return nil, fmt.Errorf("count %s deliveries to %s: %w", sender, recipient, err)
+		}
+		if count+1 > limits.SenderRate {
+			violations = append(violations, Violation{
+				Agent: recipient, Limit: LimitSenderRate,
+				Current: int64(count), Max: int64(limits.SenderRate),
+			})
+		}
+	}
+	return violations, nil
+}
+
+// Admit applies the quotas configured in configFS to a delivery of size bytes
+// from sender into each recipient mailbox of deliveryFS, following policy.
+// It reports dropped=true when drop-low discarded a low-priority message; the
+// caller must then skip delivery. wait polls until the mailboxes drain or ctx
+// is done. Every refusal wraps the *ExceededError.
+func Admit(
+	ctx context.Context,
+	configFS, deliveryFS *fsq.DeliveryRoot,
+	policy, sender string,
+	recipients []string,
+	size int64,
+	priority string,
+) (bool, error) {
+	cfg, err := Load(configFS)
+	if err != nil || cfg == nil {
+		return false, err
+	}
+	for {
+		err := Check(deliveryFS, cfg, sender, recipients, size, time.Now())
+		var exceeded *ExceededError
+		if !errors.As(err, &exceeded) {
+			return false, err
+		}
+		switch policy {
+		case OnFullDropLow:
+			if priority == format.PriorityLow {
+				return true, nil
+			}
+			return false, exceeded
+		case OnFullWait:
+			select {
+			case <-ctx.Done():
+				return false, fmt.Errorf("%w (%v)", exceeded, context.Cause(ctx))
+			case <-time.After(PollInterval):
+			}
+		default:
+			return false, exceeded
+		}
+	}
+}
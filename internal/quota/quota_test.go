@@ -0,0 +1,155 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/avivsinai/agent-message-queue/internal/config"
+	"github.com/avivsinai/agent-message-queue/internal/format"
+	"github.com/avivsinai/agent-message-queue/internal/fsq"
+)
+
+func openRoot(t *testing.T, root string) *fsq.DeliveryRoot {
+	t.Helper()
+	identity, err := fsq.SnapshotDeliveryRoot(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deliveryRoot, err := fsq.OpenDeliveryRoot(root, identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = deliveryRoot.Close() })
+	return deliveryRoot
+}
+
+func writeInboxMessage(t *testing.T, root, agent string, dir fsq.MailboxLeaf, id, from string) {
+	t.Helper()
+	msg := format.Message{
+		Header: format.Header{Schema: format.CurrentSchema, ID: id, From: from, To: []string{agent}},
+		Body:   "hello",
+	}
+	data, err := msg.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(fsq.AgentMailboxPath(root, agent, dir), id+".md")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQuotaConfigForMergesAgentOverrides(t *testing.T) {
+	cfg := &config.QuotaConfig{
+		Default: config.QuotaLimits{MaxUndrained: 10, MaxBytes: 1000, SenderRate: 5},
+		Agents:  map[string]config.QuotaLimits{"bob": {MaxUndrained: 2}},
+	}
+	got := cfg.For("bob")
+	if got.MaxUndrained != 2 || got.MaxBytes != 1000 || got.SenderRate != 5 {
+		t.Fatalf("For(bob) = %+v", got)
+	}
+	if got := cfg.For("alice"); got.MaxUndrained != 10 {
+		t.Fatalf("For(alice) = %+v", got)
+	}
+	var unset *config.QuotaConfig
+	if unset.For("alice").Enabled() {
+		t.Fatal("nil quota config should be unlimited")
+	}
+}
+
+func TestCheckReportsUndrainedAndRateViolations(t *testing.T) {
+	root := t.TempDir()
+	if err := fsq.EnsureAgentDirs(root, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	writeInboxMessage(t, root, "bob", fsq.MailboxInboxNew, "m1", "alice")
+	writeInboxMessage(t, root, "bob", fsq.MailboxInboxCur, "m2", "alice")
+	writeInboxMessage(t, root, "bob", fsq.MailboxInboxNew, "m3", "carol")
+	deliveryRoot := openRoot(t, root)
+
+	usage, err := Measure(deliveryRoot, "bob")
+	if err != nil {
+		t.Fatalf("Measure: %v", err)
+	}
+	if usage.Undrained != 2 || usage.Bytes == 0 {
+		t.Fatalf("usage = %+v, want 2 undrained with bytes", usage)
+	}
+
+	cfg := &config.QuotaConfig{Default: config.QuotaLimits{MaxUndrained: 3, SenderRate: 2}}
+	if err := Check(deliveryRoot, cfg, "carol", []string{"bob"}, 10, time.Now()); err != nil {
+		t.Fatalf("Check(carol) = %v, want admitted", err)
+	}
+	err = Check(deliveryRoot, cfg, "alice", []string{"bob"}, 10, time.Now())
+	var exceeded *ExceededError
+	if !errors.As(err, &exceeded) {
+		t.Fatalf("Check(alice) = %v, want ExceededError", err)
+	}
+	if len(exceeded.Violations) != 1 || exceeded.Violations[0].Limit != LimitSenderRate {
+		t.Fatalf("violations = %+v, want sender_rate only", exceeded.Violations)
+	}
+
+	cfg.Default.MaxUndrained = 2
+	err = Check(deliveryRoot, cfg, "carol", []string{"bob"}, 10, time.Now())
+	if !errors.As(err, &exceeded) || exceeded.Violations[0].Limit != LimitUndrained {
+		t.Fatalf("Check with full inbox = %v, want max_undrained", err)
+	}
+
+	// Deliveries older than the window do not count toward the rate.
+	if err := Check(deliveryRoot, &config.QuotaConfig{Default: config.QuotaLimits{SenderRate: 2}}, "alice", []string{"bob"}, 10, time.Now().Add(2*time.Minute)); err != nil {
+		t.Fatalf("Check after window = %v, want admitted", err)
+	}
+}
+
+func TestLoadMissingConfigDisablesQuotas(t *testing.T) {
+	root := t.TempDir()
+	cfg, err := Load(openRoot(t, root))
+	if err != nil || cfg != nil {
+		t.Fatalf("Load = %v, %v; want nil, nil", cfg, err)
+	}
+}
+
+func TestAdmitAppliesOnFullPolicies(t *testing.T) {
+	root := t.TempDir()
+	if err := fsq.EnsureAgentDirs(root, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Config{Quotas: &config.QuotaConfig{Default: config.QuotaLimits{MaxUndrained: 1}}}
+	if err := config.WriteConfig(filepath.Join(root, "meta", "config.json"), cfg, true); err != nil {
+		t.Fatal(err)
+	}
+	writeInboxMessage(t, root, "bob", fsq.MailboxInboxNew, "m1", "alice")
+	deliveryRoot := openRoot(t, root)
+	admit := func(ctx context.Context, policy, priority string) (bool, error) {
+		return Admit(ctx, deliveryRoot, deliveryRoot, policy, "alice", []string{"bob"}, 10, priority)
+	}
+
+	var exceeded *ExceededError
+	if dropped, err := admit(context.Background(), OnFullFail, format.PriorityLow); dropped || !errors.As(err, &exceeded) {
+		t.Fatalf("fail = %v, %v; want ExceededError", dropped, err)
+	}
+	if dropped, err := admit(context.Background(), OnFullDropLow, format.PriorityLow); !dropped || err != nil {
+		t.Fatalf("drop-low on low priority = %v, %v; want dropped", dropped, err)
+	}
+	if dropped, err := admit(context.Background(), OnFullDropLow, format.PriorityUrgent); dropped || !errors.As(err, &exceeded) {
+		t.Fatalf("drop-low on urgent = %v, %v; want ExceededError", dropped, err)
+	}
+
+	old := PollInterval
+	PollInterval = 5 * time.Millisecond
+	t.Cleanup(func() { PollInterval = old })
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if _, err := admit(ctx, OnFullWait, ""); !errors.As(err, &exceeded) {
+		t.Fatalf("wait past deadline = %v, want ExceededError", err)
+	}
+	if err := os.Remove(filepath.Join(fsq.AgentInboxNew(root, "bob"), "m1.md")); err != nil {
+		t.Fatal(err)
+	}
+	if dropped, err := admit(context.Background(), OnFullWait, ""); dropped || err != nil {
+		t.Fatalf("wait on drained mailbox = %v, %v; want admitted", dropped, err)
+	}
+}
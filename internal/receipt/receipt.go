@@ -16,6 +16,10 @@ import (
 const (
 	StageDrained = "drained"
 	StageDLQ     = "dlq"
+
+	// Queue claims are recorded in the claiming member's namespace.
+	StageClaimed   = "claimed"
+	StageCompleted = "completed"
 )
 
 type Receipt struct {
@@ -88,8 +92,12 @@ func EmitDeliveryRoot(root *fsq.DeliveryRoot, r Receipt) error {
 // Returns the receipt on match, or an error on timeout.
 func WaitFor(root, msgID, consumer, stage string, timeout, pollInterval time.Duration) (Receipt, error) {
 	name := fmt.Sprintf("%s__%s__%s.json", msgID, consumer, stage)
-	path := filepath.Join(fsq.AgentReceipts(root, consumer), name)
+	return WaitForPath(filepath.Join(fsq.AgentReceipts(root, consumer), name), timeout, pollInterval)
+}
 
+// WaitForPath polls for a receipt at an exact path, such as a remote
+// consumer's receipt that amq-bridge recorded outside agents/.
+func WaitForPath(path string, timeout, pollInterval time.Duration) (Receipt, error) {
 	deadline := time.Time{}
 	if timeout > 0 {
 		deadline = time.Now().Add(timeout)
@@ -0,0 +1,82 @@
+// Package semver implements the small semantic-version range grammar used by
+// AMQ's public contract negotiation: an exact version, or space-separated
+// comparator terms (>=, <=, >, <, =) that must all match.
+package semver
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+var versionPattern = regexp.MustCompile(`^(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)$`)
+
+// Parse accepts only bare MAJOR.MINOR.PATCH without prefixes or suffixes.
+func Parse(value string) (Version, bool) {
+	match := versionPattern.FindStringSubmatch(value)
+	if match == nil {
+		return Version{}, false
+	}
+	major, _ := strconv.Atoi(match[1])
+	minor, _ := strconv.Atoi(match[2])
+	patch, _ := strconv.Atoi(match[3])
+	return Version{Major: major, Minor: minor, Patch: patch}, true
+}
+
+func Compare(left, right Version) int {
+	if left.Major != right.Major {
+		return left.Major - right.Major
+	}
+	if left.Minor != right.Minor {
+		return left.Minor - right.Minor
+	}
+	return left.Patch - right.Patch
+}
+
+// RangeContains reports whether candidate satisfies requirement. Malformed
+// requirements never match.
+func RangeContains(requirement, candidate string) bool {
+	candidateVersion, ok := Parse(candidate)
+	if !ok || strings.TrimSpace(requirement) != requirement || requirement == "" {
+		return false
+	}
+	terms := strings.Fields(requirement)
+	if len(terms) == 1 {
+		if exact, exactOK := Parse(terms[0]); exactOK {
+			return Compare(candidateVersion, exact) == 0
+		}
+	}
+	for _, term := range terms {
+		operator := ""
+		versionText := term
+		for _, prefix := range []string{">=", "<=", ">", "<", "="} {
+			if strings.HasPrefix(term, prefix) {
+				operator = prefix
+				versionText = strings.TrimPrefix(term, prefix)
+				break
+			}
+		}
+		version, parsed := Parse(versionText)
+		if !parsed || operator == "" {
+			return false
+		}
+		comparison := Compare(candidateVersion, version)
+		matches := map[string]bool{
+			">=": comparison >= 0,
+			"<=": comparison <= 0,
+			">":  comparison > 0,
+			"<":  comparison < 0,
+			"=":  comparison == 0,
+		}[operator]
+		if !matches {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,26 @@
+package semver
+
+import "testing"
+
+func TestRangeContains(t *testing.T) {
+	tests := []struct {
+		requirement string
+		candidate   string
+		want        bool
+	}{
+		{"1.0.0", "1.0.0", true},
+		{"1.0.0", "1.0.1", false},
+		{">=1.0.0 <2.0.0", "1.4.2", true},
+		{">=1.0.0 <2.0.0", "2.0.0", false},
+		{">1.0.0", "1.0.0", false},
+		{"^1.0", "1.0.0", false},
+		{" >=1.0.0", "1.0.0", false},
+		{"", "1.0.0", false},
+		{">=1.0.0", "v1.0.0", false},
+	}
+	for _, test := range tests {
+		if got := RangeContains(test.requirement, test.candidate); got != test.want {
+			t.Errorf("RangeContains(%q, %q) = %v, want %v", test.requirement, test.candidate, got, test.want)
+		}
+	}
+}
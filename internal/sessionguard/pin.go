@@ -0,0 +1,136 @@
+package sessionguard
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Environment variables amq env exports to pin a shell to one root.
+const (
+	EnvSession    = "AM_SESSION"
+	EnvBaseRoot   = "AM_BASE_ROOT"
+	EnvRootID     = "AM_ROOT_ID"
+	EnvBaseRootID = "AM_BASE_ROOT_ID"
+)
+
+// Pin is the session context read from the environment. ExpectedRoot is the
+// exact root it pins: BaseRoot, or BaseRoot/Session.
+type Pin struct {
+	Present      bool
+	Session      string
+	BaseRoot     string
+	ExpectedRoot string
+	BaseRootID   string
+	RootID       string
+	IdentityPin  bool
+}
+
+// State is the pin's row input for Decide.
+func (p Pin) State() PinState {
+	if !p.Present {
+		return PinAbsent
+	}
+	if p.IdentityPin {
+		return PinIdentity
+	}
+	return PinLegacy
+}
+
+// PinError reports a malformed or incomplete pin. Callers surface it as a
+// session context mismatch.
+type PinError struct {
+	Message string
+}
+
+func (e *PinError) Error() string {
+	return e.Message
+}
+
+func pinError(format string, args ...any) error {
+	return &PinError{Message: fmt.Sprintf(format, args...)}
+}
+
+// LoadPin reads the pin from the environment. It distinguishes an absent
+// legacy pin from an explicitly empty base-root pin; new shell writers always
+// replace the complete context set. validToken checks the format of identity
+// tokens; nil leaves that to the caller's identity comparison.
+func LoadPin(validToken func(string) bool) (Pin, error) {
+	rawSession, present := os.LookupEnv(EnvSession)
+	_, rootIDPresent := os.LookupEnv(EnvRootID)
+	_, baseRootIDPresent := os.LookupEnv(EnvBaseRootID)
+	if !present && !rootIDPresent && !baseRootIDPresent {
+		return Pin{}, nil
+	}
+
+	pin := Pin{Present: true, Session: strings.TrimSpace(rawSession)}
+	if pin.Session != "" {
+		if err := ValidateSessionName(pin.Session); err != nil {
+			return Pin{}, pinError("invalid %s=%q: %v", EnvSession, rawSession, err)
+		}
+	}
+
+	base := strings.TrimSpace(os.Getenv(EnvBaseRoot))
+	if base != "" {
+		if !filepath.IsAbs(base) {
+			return Pin{}, pinError("invalid %s=%q: pinned base root must be absolute", EnvBaseRoot, base)
+		}
+		pin.BaseRoot = filepath.Clean(base)
+	}
+
+	if pin.BaseRoot == "" {
+		evidence := make([]string, 0, 3)
+		if present {
+			evidence = append(evidence, EnvSession)
+		}
+		if rootIDPresent {
+			evidence = append(evidence, EnvRootID)
+		}
+		if baseRootIDPresent {
+			evidence = append(evidence, EnvBaseRootID)
+		}
+		return Pin{}, pinError(
+			"incomplete AMQ session pin: evidence from %s requires an exact %s; re-run amq env with explicit --root <path> to replace the complete context, or clear stale pin variables before using --session <name>",
+			strings.Join(evidence, ", "),
+			EnvBaseRoot,
+		)
+	}
+	if pin.Session != "" {
+		pin.ExpectedRoot = filepath.Join(pin.BaseRoot, pin.Session)
+	} else {
+		pin.ExpectedRoot = pin.BaseRoot
+	}
+
+	if !rootIDPresent && !baseRootIDPresent {
+		return pin, nil
+	}
+	rootID := strings.TrimSpace(os.Getenv(EnvRootID))
+	baseRootID := strings.TrimSpace(os.Getenv(EnvBaseRootID))
+	if !rootIDPresent || !baseRootIDPresent || rootID == "" || baseRootID == "" {
+		return Pin{}, pinError("incomplete AMQ identity pin: %s and %s must both be present and non-empty", EnvRootID, EnvBaseRootID)
+	}
+	if validToken != nil && (!validToken(rootID) || !validToken(baseRootID)) {
+		return Pin{}, pinError("unverifiable AMQ identity pin: unsupported or malformed %s/%s", EnvRootID, EnvBaseRootID)
+	}
+	pin.RootID = rootID
+	pin.BaseRootID = baseRootID
+	pin.IdentityPin = true
+	return pin, nil
+}
+
+// ValidateSessionName checks that a session name is safe as a directory name:
+// lowercase letters, digits, hyphens, and underscores, the handle charset.
+func ValidateSessionName(name string) error {
+	if name == "" {
+		return errors.New("session name cannot be empty")
+	}
+	for _, r := range name {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
+			continue
+		}
+		return fmt.Errorf("invalid session name %q (allowed: a-z, 0-9, -, _)", name)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package sessionguard
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// MismatchError reports that a target root is not the one a pin names.
+// Callers surface it as a session context mismatch.
+type MismatchError struct {
+	Message string
+}
+
+func (e *MismatchError) Error() string {
+	return e.Message
+}
+
+func mismatch(format string, args ...any) error {
+	return &MismatchError{Message: fmt.Sprintf(format, args...)}
+}
+
+// VerifyRootUnderBase authenticates base against baseID and proves that
+// session is a direct, non-symlink child of it before authenticating root
+// against rootID. An empty session pins base itself. identity returns the
+// current tree identity token of a directory; base and root must already be
+// absolute.
+func VerifyRootUnderBase(base, baseID, session, root, rootID string, identity func(string) (string, error)) error {
+	if !identityIs(identity, base, baseID) {
+		return mismatch("pinned base root identity is not current: %s", base)
+	}
+	if session == "" {
+		if !sameIdentity(identity, base, root) {
+			return mismatch("root is not the pinned base root")
+		}
+	} else {
+		entry := filepath.Join(base, session)
+		info, err := os.Lstat(entry)
+		if err != nil || !info.IsDir() || info.Mode()&os.ModeSymlink != 0 {
+			return mismatch("pinned session %q is not a direct directory under base", session)
+		}
+		if !sameIdentity(identity, entry, root) {
+			return mismatch("root is not the pinned session directory")
+		}
+	}
+	if !identityIs(identity, root, rootID) {
+		return mismatch("target root identity is not current: %s", root)
+	}
+	return nil
+}
+
+// Verify checks that target is the root p pins: an identity pin goes through
+// VerifyRootUnderBase, a legacy pin compares paths exactly. target must
+// already be absolute.
+func (p Pin) Verify(target string, identity func(string) (string, error)) error {
+	if p.IdentityPin {
+		return VerifyRootUnderBase(p.BaseRoot, p.BaseRootID, p.Session, target, p.RootID, identity)
+	}
+	if target == p.ExpectedRoot {
+		return nil
+	}
+	return mismatch(
+		"session context mismatch: target root %s differs from pinned root %s (%s=%q)",
+		target, p.ExpectedRoot, EnvSession, p.Session,
+	)
+}
+
+// BaseOnly is p narrowed to its base root, for targets routed to a session
+// under the pinned base.
+func (p Pin) BaseOnly() Pin {
+	p.Session = ""
+	p.ExpectedRoot = p.BaseRoot
+	p.RootID = p.BaseRootID
+	return p
+}
+
+func identityIs(identity func(string) (string, error), path, token string) bool {
+	current, err := identity(path)
+	return err == nil && token != "" && current == token
+}
+
+func sameIdentity(identity func(string) (string, error), a, b string) bool {
+	left, errA := identity(a)
+	right, errB := identity(b)
+	return errA == nil && errB == nil && left == right
+}
@@ -9,6 +9,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/avivsinai/agent-message-queue/internal/acl"
 	"github.com/avivsinai/agent-message-queue/internal/format"
 	"github.com/avivsinai/agent-message-queue/internal/fsq"
 	"github.com/fsnotify/fsnotify"
@@ -391,6 +392,6 @@ func deliverBridgeEvent(cfg BridgeConfig, event BridgeEvent) error {
 		return err
 	}
 	defer func() { _ = deliveryRoot.Close() }()
-	_, err = fsq.DeliverToInboxes(deliveryRoot, msg.Header.To, filename, data)
+	_, err = acl.DeliverToInboxes(deliveryRoot, deliveryRoot, msg.Header, msg.Header.To, filename, data)
 	return err
 }
@@ -23,6 +23,7 @@ type Entry struct {
 	Priority string    `json:"priority,omitempty"`
 	Kind     string    `json:"kind,omitempty"`
 	Labels   []string  `json:"labels,omitempty"`
+	Refs     []string  `json:"refs,omitempty"`
 	RawTime  time.Time `json:"-"`
 }
 
@@ -41,16 +42,46 @@ func (e Entry) GetRawTime() time.Time {
 // Collect scans agent mailboxes and returns messages for a thread.
 // onError is called when a message cannot be parsed; returning a non-nil error aborts the scan.
 func Collect(root, threadID string, agents []string, includeBody bool, onError func(path string, err error) error) ([]Entry, error) {
+	return collect(mailboxReader{
+		root:        root,
+		readDir:     os.ReadDir,
+		readMessage: format.ReadMessageFile,
+		readHeader:  format.ReadHeaderFile,
+	}, threadID, agents, includeBody, onError)
+}
+
+// CollectDeliveryRoot is Collect through a pinned root capability. Paths
+// passed to onError are relative to root.
+func CollectDeliveryRoot(root *fsq.DeliveryRoot, threadID string, agents []string, includeBody bool, onError func(path string, err error) error) ([]Entry, error) {
+	return collect(mailboxReader{
+		readDir: root.ReadDir,
+		readMessage: func(name string) (format.Message, error) {
+			return format.ReadMessageDeliveryRoot(root, name)
+		},
+		readHeader: func(name string) (format.Header, error) {
+			return format.ReadHeaderDeliveryRoot(root, name)
+		},
+	}, threadID, agents, includeBody, onError)
+}
+
+type mailboxReader struct {
+	root        string
+	readDir     func(string) ([]os.DirEntry, error)
+	readMessage func(string) (format.Message, error)
+	readHeader  func(string) (format.Header, error)
+}
+
+func collect(reader mailboxReader, threadID string, agents []string, includeBody bool, onError func(path string, err error) error) ([]Entry, error) {
 	entries := []Entry{}
 	seen := make(map[string]struct{})
 	for _, agent := range agents {
 		dirs := []string{
-			fsq.AgentInboxNew(root, agent),
-			fsq.AgentInboxCur(root, agent),
-			fsq.AgentOutboxSent(root, agent),
+			fsq.AgentInboxNew(reader.root, agent),
+			fsq.AgentInboxCur(reader.root, agent),
+			fsq.AgentOutboxSent(reader.root, agent),
 		}
 		for _, dir := range dirs {
-			files, err := os.ReadDir(dir)
+			files, err := reader.readDir(dir)
 			if err != nil {
 				if os.IsNotExist(err) {
 					continue
@@ -67,7 +98,7 @@ func Collect(root, threadID string, agents []string, includeBody bool, onError f
 				}
 				path := filepath.Join(dir, name)
 				if includeBody {
-					msg, err := format.ReadMessageFile(path)
+					msg, err := reader.readMessage(path)
 					if err != nil {
 						if onError == nil {
 							return nil, fmt.Errorf("parse message %s: %w", path, err)
@@ -95,6 +126,7 @@ func Collect(root, threadID string, agents []string, includeBody bool, onError f
 						Priority: msg.Header.Priority,
 						Kind:     msg.Header.Kind,
 						Labels:   msg.Header.Labels,
+						Refs:     msg.Header.Refs,
 					}
 					if ts, err := time.Parse(time.RFC3339Nano, msg.Header.Created); err == nil {
 						entry.RawTime = ts
@@ -103,7 +135,7 @@ func Collect(root, threadID string, agents []string, includeBody bool, onError f
 					continue
 				}
 
-				header, err := format.ReadHeaderFile(path)
+				header, err := reader.readHeader(path)
 				if err != nil {
 					if onError == nil {
 						return nil, fmt.Errorf("parse message %s: %w", path, err)
@@ -130,6 +162,7 @@ func Collect(root, threadID string, agents []string, includeBody bool, onError f
 					Priority: header.Priority,
 					Kind:     header.Kind,
 					Labels:   header.Labels,
+					Refs:     header.Refs,
 				}
 				if ts, err := time.Parse(time.RFC3339Nano, header.Created); err == nil {
 					entry.RawTime = ts
@@ -2,10 +2,9 @@ package launchapi
 
 import (
 	"fmt"
-	"regexp"
 	"slices"
-	"strconv"
-	"strings"
+
+	"github.com/avivsinai/agent-message-queue/internal/semver"
 )
 
 const ContractSemverV1 = "0.61.1"
@@ -55,71 +54,6 @@ func Negotiate(requirement RequirementV1) (NegotiatedV1, error) {
 	}, nil
 }
 
-type semanticVersion struct {
-	major int
-	minor int
-	patch int
-}
-
-var semanticVersionPattern = regexp.MustCompile(`^(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)$`)
-
-func parseSemanticVersion(value string) (semanticVersion, bool) {
-	match := semanticVersionPattern.FindStringSubmatch(value)
-	if match == nil {
-		return semanticVersion{}, false
-	}
-	major, _ := strconv.Atoi(match[1])
-	minor, _ := strconv.Atoi(match[2])
-	patch, _ := strconv.Atoi(match[3])
-	return semanticVersion{major: major, minor: minor, patch: patch}, true
-}
-
-func compareSemanticVersions(left, right semanticVersion) int {
-	if left.major != right.major {
-		return left.major - right.major
-	}
-	if left.minor != right.minor {
-		return left.minor - right.minor
-	}
-	return left.patch - right.patch
-}
-
 func semverRangeContains(requirement, candidate string) bool {
-	candidateVersion, ok := parseSemanticVersion(candidate)
-	if !ok || strings.TrimSpace(requirement) != requirement || requirement == "" {
-		return false
-	}
-	terms := strings.Fields(requirement)
-	if len(terms) == 1 {
-		if exact, exactOK := parseSemanticVersion(terms[0]); exactOK {
-			return compareSemanticVersions(candidateVersion, exact) == 0
-		}
-	}
-	for _, term := range terms {
-		operator := ""
-		versionText := term
-		for _, prefix := range []string{">=", "<=", ">", "<", "="} {
-			if strings.HasPrefix(term, prefix) {
-				operator = prefix
-				versionText = strings.TrimPrefix(term, prefix)
-				break
-			}
-		}
-		version, parsed := parseSemanticVersion(versionText)
-		if !parsed || operator == "" {
-			return false
-		}
-		comparison := compareSemanticVersions(candidateVersion, version)
-		matches := map[string]bool{
-			">=": comparison >= 0,
-			"<=": comparison <= 0,
-			">":  comparison > 0,
-			"<":  comparison < 0,
-			"=":  comparison == 0,
-		}[operator]
-		if !matches {
-			return false
-		}
-	}
-	return true
+	return semver.RangeContains(requirement, candidate)
 }
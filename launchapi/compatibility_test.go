@@ -5,6 +5,8 @@ import (
 	"slices"
 	"strings"
 	"testing"
+
+	"github.com/avivsinai/agent-message-queue/internal/semver"
 )
 
 func TestCompatibilityAndNegotiateV1(t *testing.T) {
@@ -92,18 +94,18 @@ func TestNegotiateV1FailsClosed(t *testing.T) {
 }
 
 func TestCompareSemanticVersionsOrdersPatch(t *testing.T) {
-	left, ok := parseSemanticVersion("1.0.1")
+	left, ok := semver.Parse("1.0.1")
 	if !ok {
 		t.Fatal("parse 1.0.1")
 	}
-	right, ok := parseSemanticVersion("1.0.2")
+	right, ok := semver.Parse("1.0.2")
 	if !ok {
 		t.Fatal("parse 1.0.2")
 	}
-	if cmp := compareSemanticVersions(left, right); cmp >= 0 {
+	if cmp := semver.Compare(left, right); cmp >= 0 {
 		t.Fatalf("compare(1.0.1, 1.0.2) = %d, want negative", cmp)
 	}
-	if cmp := compareSemanticVersions(right, left); cmp <= 0 {
+	if cmp := semver.Compare(right, left); cmp <= 0 {
 		t.Fatalf("compare(1.0.2, 1.0.1) = %d, want positive", cmp)
 	}
 }